@@ -0,0 +1 @@
+au BufWritePost *.go silent!make tags > /dev/null 2>&1
@@ -0,0 +1,21 @@
+package shipyard
+
+import (
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+// AccountExport holds all data stored about an account: its events, the
+// audit entries of its requests and the resources it created
+type AccountExport struct {
+	ExportedAt   time.Time          `json:"exported_at,omitempty"`
+	Account      *auth.Account      `json:"account,omitempty"`
+	Sessions     []*auth.AuthToken  `json:"sessions,omitempty"`
+	Events       []*Event           `json:"events,omitempty"`
+	AuditEntries []*AuditEntry      `json:"audit_entries,omitempty"`
+	Jobs         []*Job             `json:"jobs,omitempty"`
+	ShareLinks   []*ShareLink       `json:"share_links,omitempty"`
+	Freezes      []*Freeze          `json:"freezes,omitempty"`
+	ServiceKeys  []*auth.ServiceKey `json:"service_keys,omitempty"`
+}
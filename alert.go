@@ -0,0 +1,43 @@
+package shipyard
+
+import (
+	"time"
+)
+
+const (
+	AlertNodeDown     = "node-down"
+	AlertCrashLoop    = "crash-loop"
+	AlertCertExpiring = "cert-expiring"
+	AlertClockSkew    = "clock-skew"
+
+	AlertStatusActive       = "active"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
+type (
+	// Alert is a condition that needs attention; it stays in the inbox
+	// until it is resolved, either by hand or once the condition clears
+	Alert struct {
+		ID             string          `json:"id,omitempty" gorethink:"id,omitempty"`
+		Type           string          `json:"type,omitempty" gorethink:"type"`
+		Subject        string          `json:"subject,omitempty" gorethink:"subject"`
+		Severity       string          `json:"severity,omitempty" gorethink:"severity"`
+		Message        string          `json:"message,omitempty" gorethink:"message"`
+		Status         string          `json:"status,omitempty" gorethink:"status"`
+		CreatedAt      time.Time       `json:"created_at,omitempty" gorethink:"created_at"`
+		AssignedTo     string          `json:"assigned_to,omitempty" gorethink:"assigned_to"`
+		AcknowledgedBy string          `json:"acknowledged_by,omitempty" gorethink:"acknowledged_by"`
+		AcknowledgedAt *time.Time      `json:"acknowledged_at,omitempty" gorethink:"acknowledged_at,omitempty"`
+		ResolvedAt     *time.Time      `json:"resolved_at,omitempty" gorethink:"resolved_at,omitempty"`
+		Comments       []*AlertComment `json:"comments,omitempty" gorethink:"comments"`
+		// Notes of the subject when the alert was raised
+		Notes []*Note `json:"notes,omitempty" gorethink:"notes,omitempty"`
+	}
+
+	AlertComment struct {
+		Username string    `json:"username,omitempty" gorethink:"username"`
+		Time     time.Time `json:"time,omitempty" gorethink:"time"`
+		Text     string    `json:"text,omitempty" gorethink:"text"`
+	}
+)
@@ -0,0 +1,86 @@
+package shipyard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type (
+	// AuditRecord is an entry in the append-only audit log; Data holds the
+	// event as JSON and Hash covers it along with the previous record hash
+	AuditRecord struct {
+		Sequence     int64  `json:"sequence" gorethink:"id"`
+		Data         string `json:"data,omitempty" gorethink:"data"`
+		PreviousHash string `json:"previous_hash,omitempty" gorethink:"previous_hash"`
+		Hash         string `json:"hash,omitempty" gorethink:"hash"`
+		// DataHash is the hash of Data covered by Hash, so Data can be
+		// redacted without breaking the chain; records written before it
+		// was added cover Data itself
+		DataHash string `json:"data_hash,omitempty" gorethink:"data_hash,omitempty"`
+		// Redacted is set when Data was replaced after the record was
+		// written (i.e. when an account was anonymized)
+		Redacted bool `json:"redacted,omitempty" gorethink:"redacted,omitempty"`
+	}
+
+	// AuditEntry records a state-changing request to the api or a Docker
+	// mutation proxied to swarm
+	AuditEntry struct {
+		ID         string    `json:"id,omitempty" gorethink:"id,omitempty"`
+		Time       time.Time `json:"time" gorethink:"time"`
+		Username   string    `json:"username,omitempty" gorethink:"username"`
+		RemoteAddr string    `json:"remote_addr,omitempty" gorethink:"remote_addr"`
+		Method     string    `json:"method" gorethink:"method"`
+		Route      string    `json:"route" gorethink:"route"`
+		// Action names Docker mutations (i.e. container-stop)
+		Action string `json:"action,omitempty" gorethink:"action,omitempty"`
+		// Payload summarizes the request body with secrets redacted
+		Payload string `json:"payload,omitempty" gorethink:"payload,omitempty"`
+		Status  int    `json:"status" gorethink:"status"`
+		// RequestID is the X-Request-Id the request was logged with
+		RequestID string `json:"request_id,omitempty" gorethink:"request_id,omitempty"`
+	}
+
+	AuditVerification struct {
+		Valid   bool  `json:"valid"`
+		Records int64 `json:"records"`
+		// Redacted counts the records whose data was redacted
+		Redacted       int64  `json:"redacted"`
+		FailedSequence int64  `json:"failed_sequence,omitempty"`
+		Message        string `json:"message,omitempty"`
+	}
+)
+
+// HashAuditData returns the hash of the data of a record
+func HashAuditData(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+// ComputeHash returns the hash of the record contents chained to the
+// previous record hash
+func (a *AuditRecord) ComputeHash() string {
+	h := sha256.New()
+	if a.DataHash != "" {
+		// data is json so it never starts with the prefix
+		fmt.Fprintf(h, "%d\n%s\nsha256:%s", a.Sequence, a.PreviousHash, a.DataHash)
+	} else {
+		fmt.Fprintf(h, "%d\n%s\n%s", a.Sequence, a.PreviousHash, a.Data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Redact replaces the data of the record; the record hash still covers the
+// hash of the original data so the chain stays valid. Records written
+// before data hashes were added cannot be redacted.
+func (a *AuditRecord) Redact(data string) bool {
+	if a.DataHash == "" {
+		return false
+	}
+
+	a.Data = data
+	a.Redacted = true
+
+	return true
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"strings"
@@ -17,15 +19,50 @@ type (
 		ID        string       `json:"id,omitempty" gorethink:"id,omitempty"`
 		FirstName string       `json:"first_name,omitempty" gorethink:"first_name,omitempty"`
 		LastName  string       `json:"last_name,omitempty" gorethink:"last_name,omitempty"`
+		Email     string       `json:"email,omitempty" gorethink:"email,omitempty"`
 		Username  string       `json:"username,omitempty" gorethink:"username"`
 		Password  string       `json:"password,omitempty" gorethink:"password"`
 		Tokens    []*AuthToken `json:"-" gorethink:"tokens"`
 		Roles     []string     `json:"roles,omitempty" gorethink:"roles"`
+		// LabelScope restricts the account to containers with one of
+		// the labels; it replaces the scopes of the roles
+		LabelScope LabelScope `json:"label_scope,omitempty" gorethink:"label_scope,omitempty"`
+		// TOTPSecret is the secret of the authenticator app of the account;
+		// it is never returned by the api
+		TOTPSecret  string `json:"-" gorethink:"totp_secret,omitempty"`
+		TOTPEnabled bool   `json:"totp_enabled,omitempty" gorethink:"totp_enabled"`
+		// TOTPLastStep is the period of the last code used to login
+		TOTPLastStep int64 `json:"-" gorethink:"totp_last_step,omitempty"`
+		// FailedLogins counts the failed logins since the last successful
+		// one; LockedUntil refuses logins until then once too many failed
+		FailedLogins int       `json:"failed_logins,omitempty" gorethink:"failed_logins"`
+		LockedUntil  time.Time `json:"locked_until,omitempty" gorethink:"locked_until,omitempty"`
+		// PasswordResetHash is the hash of the one-time token an admin
+		// created for the user to set a new password with
+		PasswordResetHash    string    `json:"-" gorethink:"password_reset_hash,omitempty"`
+		PasswordResetExpires time.Time `json:"-" gorethink:"password_reset_expires,omitempty"`
+		// ForcePasswordChange limits the account to changing its
+		// password until it does
+		ForcePasswordChange bool `json:"force_password_change,omitempty" gorethink:"force_password_change,omitempty"`
+		// OIDCIssuer and OIDCSubject link the account to the OpenID
+		// Connect identity it was created for; only that identity signs
+		// in to it through the provider
+		OIDCIssuer  string `json:"oidc_issuer,omitempty" gorethink:"oidc_issuer,omitempty"`
+		OIDCSubject string `json:"oidc_subject,omitempty" gorethink:"oidc_subject,omitempty"`
 	}
 
 	AuthToken struct {
-		Token     string `json:"auth_token,omitempty" gorethink:"auth_token"`
-		UserAgent string `json:"user_agent,omitempty" gorethink:"user_agent"`
+		// ID identifies the token without revealing it; see TokenID
+		ID        string    `json:"id,omitempty" gorethink:"-"`
+		Token     string    `json:"auth_token,omitempty" gorethink:"auth_token"`
+		UserAgent string    `json:"user_agent,omitempty" gorethink:"user_agent"`
+		CreatedAt time.Time `json:"created_at,omitempty" gorethink:"created_at,omitempty"`
+		LastUsed  time.Time `json:"last_used,omitempty" gorethink:"last_used,omitempty"`
+		// ExpiresAt is zero for tokens that do not expire
+		ExpiresAt time.Time `json:"expires_at,omitempty" gorethink:"expires_at,omitempty"`
+		// ForcePasswordChange tells clients at login the password has
+		// to be changed before anything else
+		ForcePasswordChange bool `json:"force_password_change,omitempty" gorethink:"-"`
 	}
 
 	AccessToken struct {
@@ -34,8 +71,26 @@ type (
 	}
 
 	ServiceKey struct {
-		Key         string `json:"key,omitempty" gorethink:"key"`
+		Key string `json:"key,omitempty" gorethink:"key"`
+		// ID names the key in responses without revealing it (see KeyID)
+		ID          string `json:"id,omitempty" gorethink:"-"`
 		Description string `json:"description,omitempty" gorethink:"description"`
+		// Scopes are the permissions of the key (i.e. events:read); keys
+		// without scopes have full access
+		Scopes []string `json:"scopes,omitempty" gorethink:"scopes,omitempty"`
+		// ExpiresAt is zero for keys that do not expire
+		ExpiresAt time.Time `json:"expires_at,omitempty" gorethink:"expires_at,omitempty"`
+		CreatedBy string    `json:"created_by,omitempty" gorethink:"created_by,omitempty"`
+	}
+
+	ServiceKeyUsage struct {
+		Key string `json:"key,omitempty" gorethink:"id"`
+		// ID names the key in responses without revealing it (see KeyID)
+		ID           string         `json:"id,omitempty" gorethink:"-"`
+		RequestCount int            `json:"request_count" gorethink:"request_count"`
+		LastUsed     time.Time      `json:"last_used,omitempty" gorethink:"last_used"`
+		SourceIPs    []string       `json:"source_ips,omitempty" gorethink:"source_ips"`
+		Routes       map[string]int `json:"routes,omitempty" gorethink:"routes"`
 	}
 
 	Authenticator interface {
@@ -46,13 +101,29 @@ type (
 	}
 )
 
-func Hash(data string) (string, error) {
-	h, err := bcrypt.GenerateFromPassword([]byte(data), bcrypt.DefaultCost)
-	return string(h[:]), err
+// HasRole reports whether the account has the role
+func (a *Account) HasRole(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
 }
 
+// GenerateToken returns a random token; tokens are independent of the
+// password hash policy
 func GenerateToken() (string, error) {
-	return Hash(time.Now().String())
+	h, err := bcrypt.GenerateFromPassword([]byte(time.Now().String()), bcrypt.DefaultCost)
+	return string(h[:]), err
+}
+
+// TokenID returns a public identifier for an auth token so sessions can be
+// listed and revoked without exposing the token
+func TokenID(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:8])
 }
 
 // GetAccessToken returns an AccessToken from the access header
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"testing"
+	"time"
 )
 
 const (
@@ -40,3 +41,315 @@ func TestGetAccessToken(t *testing.T) {
 	}
 
 }
+
+func TestNewAccessReport(t *testing.T) {
+	accounts := []*Account{
+		{
+			Username: "admin",
+			Roles:    []string{"admin"},
+		},
+		{
+			Username: "viewer",
+			Roles:    []string{"containers:ro"},
+		},
+	}
+
+	report := NewAccessReport(accounts, DefaultACLs())
+
+	if len(report.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts; received %d", len(report.Accounts))
+	}
+
+	for _, p := range report.Permissions {
+		if !report.Accounts[0].Permissions[p] {
+			t.Fatalf("expected admin to have %q", p)
+		}
+	}
+
+	viewer := report.Accounts[1]
+	if !viewer.Permissions["GET /containers"] {
+		t.Fatalf("expected viewer to have GET /containers")
+	}
+
+	if viewer.Permissions["POST /containers"] {
+		t.Fatalf("expected viewer to not have POST /containers")
+	}
+}
+
+func TestExecPolicyAllowsWithoutCommands(t *testing.T) {
+	p := &ExecPolicy{Role: "containers:rw"}
+	if p.Allows([]string{"ps"}) {
+		t.Fatalf("expected policy without commands to deny exec")
+	}
+
+	p.ReadOnly = true
+	if !p.Allows([]string{"ps"}) {
+		t.Fatalf("expected read only policy without commands to allow exec")
+	}
+}
+
+func TestExecAccess(t *testing.T) {
+	policies := []*ExecPolicy{
+		{
+			Role:            "containers:rw",
+			AllowedCommands: []string{"ps *", "df -h"},
+		},
+		{
+			Role:         "containers:rw",
+			Environments: []string{"prod"},
+			Disabled:     true,
+		},
+	}
+
+	roles := []string{"containers:rw"}
+
+	if allowed, _ := ExecAccess(roles, policies, "dev", []string{"ps", "aux"}); !allowed {
+		t.Fatalf("expected ps aux to be allowed")
+	}
+
+	if allowed, _ := ExecAccess(roles, policies, "dev", []string{"df", "-h", "/"}); allowed {
+		t.Fatalf("expected df -h / to be denied")
+	}
+
+	if allowed, _ := ExecAccess(roles, policies, "dev", []string{"sh"}); allowed {
+		t.Fatalf("expected sh to be denied")
+	}
+
+	if allowed, _ := ExecAccess(roles, policies, "prod", []string{"ps"}); allowed {
+		t.Fatalf("expected exec in prod to be denied")
+	}
+
+	if allowed, readOnly := ExecAccess([]string{"admin"}, policies, "prod", []string{"sh"}); !allowed || readOnly {
+		t.Fatalf("expected role without policies to be unrestricted")
+	}
+
+	policies = append(policies, &ExecPolicy{
+		Role:     "containers:ro",
+		ReadOnly: true,
+	})
+
+	if allowed, readOnly := ExecAccess([]string{"containers:ro"}, policies, "prod", []string{"sh"}); !allowed || !readOnly {
+		t.Fatalf("expected read only exec")
+	}
+
+	if _, readOnly := ExecAccess([]string{"containers:ro", "admin"}, policies, "prod", []string{"sh"}); readOnly {
+		t.Fatalf("expected unrestricted role to allow read write exec")
+	}
+}
+
+func TestRequiredPermission(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		perm   string
+	}{
+		{"GET", "/containers/json", PermContainersRead},
+		{"GET", "/v1.20/containers/abc/logs", PermContainersRead},
+		{"POST", "/v1.20/containers/create", PermContainersWrite},
+		{"POST", "/containers/abc/exec", PermContainersExec},
+		{"GET", "/containers/abc/attach/ws", PermContainersExec},
+		{"DELETE", "/containers/abc", PermContainersDelete},
+		{"POST", "/images/create", PermImagesWrite},
+		{"DELETE", "/images/abc", PermImagesDelete},
+		{"GET", "/v1.24/volumes", PermVolumesRead},
+		{"DELETE", "/v1.24/volumes/data", PermVolumesManage},
+		{"HEAD", "/v1.24/containers/abc/archive", PermContainersRead},
+		{"PUT", "/v1.24/containers/abc/archive", PermContainersWrite},
+		{"POST", "/v1.24/containers/abc/update", PermContainersWrite},
+		{"POST", "/api/registries", PermRegistriesManage},
+		{"GET", "/api/registries/abc/repositories", PermRegistriesRead},
+		{"GET", "/api/registries/abc/repositories/team/web/scan", PermRegistriesRead},
+		{"POST", "/api/registries/abc/repositories/team/web/scan", PermRegistriesManage},
+		{"DELETE", "/api/registries/abc/repositories/web/tags/old", PermRegistriesManage},
+		{"DELETE", "/api/sharelinks/abc", PermShareLinksManage},
+		{"GET", "/api/notes/node/node-1", PermNotesRead},
+		{"PUT", "/api/notes/container/web", PermNotesManage},
+		{"GET", "/api/accounts/admin/export", PermAccountsManage},
+		{"POST", "/api/accounts/alice/unlock", PermAccountsManage},
+		{"POST", "/api/accounts/admin/2fa", PermAuthenticated},
+		{"PUT", "/api/account/me", PermAuthenticated},
+		{"GET", "/api/container-defaults", PermStacksRead},
+		{"PUT", "/api/container-defaults", ""},
+		{"POST", "/api/accounts/alice/reset", PermAccountsManage},
+		{"GET", "/api/auditlogs", PermAuditRead},
+		{"DELETE", "/api/auditlogs", ""},
+		{"GET", "/api/freezes", PermFreezesRead},
+		{"GET", "/api/ws/containers", PermContainersRead},
+		{"GET", "/api/ws/stacks", ""},
+		{"POST", "/api/freezes", PermFreezesManage},
+		{"GET", "/api/stacks/shop", PermStacksRead},
+		{"GET", "/api/stacks/shop/top", PermStacksRead},
+		{"POST", "/api/stacks/shop/redeploy", PermStacksManage},
+		{"GET", "/api/templates/wordpress/export", PermTemplatesRead},
+		{"POST", "/api/templates/import", PermTemplatesManage},
+		{"POST", "/api/templates/wordpress/deploy", PermStacksManage},
+		{"POST", "/api/nodes", PermNodesManage},
+		{"GET", "/api/clusters", PermNodesRead},
+		{"POST", "/api/debug/launch", PermContainersExec},
+		{"POST", "/api/clusters", ""},
+		{"DELETE", "/api/clusters/staging", ""},
+		{"POST", "/api/nodes/node-1/drain", PermNodesManage},
+		{"GET", "/api/volumes", PermVolumesRead},
+		{"POST", "/api/volumes/prune", PermVolumesManage},
+		{"GET", "/api/networks/abc", PermNetworksRead},
+		{"GET", "/api/registries/mirror", PermRegistriesRead},
+		{"PUT", "/api/registries/mirror", PermRegistriesManage},
+		{"POST", "/api/networks/abc/connect", PermNetworksManage},
+		{"PUT", "/api/containers/abc/restart-policy", PermContainersWrite},
+		{"POST", "/api/containers/abc/update", PermContainersWrite},
+		{"GET", "/api/containers/abc/stats", PermContainersRead},
+		{"GET", "/api/servicekeys", ""},
+		{"POST", "/api/webhookkeys/abc/test", ""},
+		{"POST", "/api/clientrules", ""},
+		{"GET", "/api/jobs", PermJobsRead},
+		{"POST", "/api/jobs/0/run", PermJobsManage},
+		{"POST", "/api/query", PermContainersRead},
+		{"GET", "/api/pulls", PermImagesRead},
+		{"GET", "/api/stacks/shop/canary", PermStacksRead},
+		{"POST", "/api/stacks/shop/canary/rollback", PermStacksManage},
+		{"GET", "/api/exec/sessions", PermContainersExec},
+		{"GET", "/api/exec/sessions/recorded", PermAuditRead},
+		{"GET", "/api/exec/sessions/exec-0/recording", PermAuditRead},
+		{"GET", "/api/actions", PermAuthenticated},
+		{"POST", "/api/admin/seed-demo", ""},
+		{"POST", "/api/admin/housekeeping", ""},
+		{"GET", "/api/backup", ""},
+		{"POST", "/api/restore", ""},
+		{"POST", "/api/admin/cleanup/container/abc", ""},
+		{"GET", "/api/admin/deprecations", ""},
+		{"GET", "/api/usage/accounts", ""},
+	}
+
+	for _, tt := range tests {
+		if perm := RequiredPermission(tt.method, tt.path); perm != tt.perm {
+			t.Errorf("%s %s: expected %q; received %q", tt.method, tt.path, tt.perm, perm)
+		}
+	}
+}
+
+func TestACLPermissions(t *testing.T) {
+	deploy := &ACL{
+		RoleName:    "ci",
+		Permissions: []string{PermContainersRead, PermContainersWrite, "images:*"},
+	}
+
+	if !deploy.Allows("/v1.20/containers/create", "POST") {
+		t.Fatal("expected deploy role to create containers")
+	}
+
+	if !deploy.Allows("/images/abc", "DELETE") {
+		t.Fatal("expected images:* to grant image deletes")
+	}
+
+	if deploy.Allows("/containers/abc/exec", "POST") {
+		t.Fatal("expected deploy role to not exec")
+	}
+
+	if deploy.Allows("/api/servicekeys", "GET") {
+		t.Fatal("expected routes without a permission to be denied")
+	}
+
+	for _, p := range []string{"*", "containers:*", PermNodesManage} {
+		if !ValidPermission(p) {
+			t.Fatalf("expected %q to be valid", p)
+		}
+	}
+
+	if ValidPermission("containers:fly") {
+		t.Fatal("expected unknown permission to be invalid")
+	}
+}
+
+func TestAllowsProxied(t *testing.T) {
+	// a custom role relying on access rules alone
+	user := &ACL{
+		RoleName: "user",
+		Rules: []*AccessRule{
+			{Path: "/", Methods: []string{"GET", "POST"}},
+		},
+	}
+
+	deploy := &ACL{
+		RoleName:    "deploy",
+		Permissions: []string{PermImagesRead, PermImagesWrite},
+	}
+
+	tests := []struct {
+		acl    *ACL
+		method string
+		path   string
+		level  string
+		allow  bool
+	}{
+		{user, "GET", "/v1.24/images/json", ProxyRead, true},
+		{user, "POST", "/v1.24/images/web/push", ProxyWrite, false},
+		{user, "POST", "/build", ProxyWrite, false},
+		{user, "POST", "/containers/create", ProxyWrite, false},
+		{deploy, "POST", "/v1.24/images/web/push", ProxyWrite, true},
+		{deploy, "POST", "/build", ProxyWrite, true},
+		{deploy, "GET", "/swarm", ProxyAdmin, false},
+		{user, "GET", "/swarm", ProxyAdmin, false},
+	}
+
+	for _, tt := range tests {
+		if level := ProxyLevel(tt.method, tt.path); level != tt.level {
+			t.Errorf("%s %s: expected level %q; received %q", tt.method, tt.path, tt.level, level)
+		}
+
+		if allowed := tt.acl.AllowsProxied(tt.path, tt.method); allowed != tt.allow {
+			t.Errorf("%s %s %s: expected %v; received %v", tt.acl.RoleName, tt.method, tt.path, tt.allow, allowed)
+		}
+	}
+
+	admin := DefaultACLs()[0]
+	if !admin.AllowsProxied("/swarm", "GET") {
+		t.Fatal("expected admin to use routes without a permission")
+	}
+}
+
+func TestServiceKeyScopes(t *testing.T) {
+	unscoped := &ServiceKey{Key: "full"}
+	scoped := &ServiceKey{Key: "ci", Scopes: []string{PermEventsRead, ScopeWebhookDeploy}}
+
+	tests := []struct {
+		key    *ServiceKey
+		method string
+		path   string
+		allow  bool
+	}{
+		{unscoped, "POST", "/api/servicekeys", true},
+		{scoped, "GET", "/api/events", true},
+		{scoped, "GET", "/v1.24/events", true},
+		{scoped, "DELETE", "/api/events", false},
+		{scoped, "POST", "/api/stacks/shop/redeploy", true},
+		{scoped, "DELETE", "/api/stacks/shop", false},
+		{scoped, "POST", "/api/stacks", false},
+		{scoped, "GET", "/api/servicekeys", false},
+		{scoped, "POST", "/api/accounts/admin/2fa", false},
+		{scoped, "PUT", "/api/account/me", false},
+		{scoped, "GET", "/api/actions", false},
+		{scoped, "GET", "/_ping", false},
+		{&ServiceKey{Key: "ping", Scopes: []string{PermAuthenticated}}, "GET", "/_ping", true},
+		{&ServiceKey{Key: "all", Scopes: []string{"*"}}, "GET", "/api/actions", true},
+	}
+
+	for _, tt := range tests {
+		if allowed := tt.key.Allows(tt.path, tt.method); allowed != tt.allow {
+			t.Errorf("%s %s %s: expected %v; received %v", tt.key.Key, tt.method, tt.path, tt.allow, allowed)
+		}
+	}
+
+	now := time.Now()
+	if unscoped.Expired(now) {
+		t.Fatal("expected keys without an expiry not to expire")
+	}
+
+	scoped.ExpiresAt = now
+	if !scoped.Expired(now) {
+		t.Fatal("expected the key to expire at its expiry")
+	}
+
+	if ValidScope("everything") || !ValidScope(ScopeWebhookDeploy) || !ValidScope("stacks:*") || !ValidScope(PermAuthenticated) {
+		t.Fatal("expected scopes to be permissions or webhook:deploy")
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"time"
+)
+
+const (
+	// BreakGlassUsername is the account used for break-glass access
+	BreakGlassUsername = "breakglass"
+)
+
+type (
+	// BreakGlass is the sealed emergency credential; only its hash is
+	// stored and it can be used once before it has to be sealed again
+	BreakGlass struct {
+		ID             string     `json:"-" gorethink:"id"`
+		CredentialHash string     `json:"-" gorethink:"credential_hash"`
+		SealedAt       time.Time  `json:"sealed_at,omitempty" gorethink:"sealed_at"`
+		SealedBy       string     `json:"sealed_by,omitempty" gorethink:"sealed_by"`
+		UsedAt         *time.Time `json:"used_at,omitempty" gorethink:"used_at,omitempty"`
+		UsedFrom       string     `json:"used_from,omitempty" gorethink:"used_from,omitempty"`
+		Reason         string     `json:"reason,omitempty" gorethink:"reason,omitempty"`
+		ExpiresAt      *time.Time `json:"expires_at,omitempty" gorethink:"expires_at,omitempty"`
+	}
+)
+
+// Sealed reports whether the credential is available for use
+func (b *BreakGlass) Sealed() bool {
+	return b.CredentialHash != "" && b.UsedAt == nil
+}
+
+// Active reports whether break-glass access is currently granted
+func (b *BreakGlass) Active(now time.Time) bool {
+	return b.ExpiresAt != nil && now.Before(*b.ExpiresAt)
+}
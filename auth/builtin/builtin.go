@@ -2,7 +2,6 @@ package builtin
 
 import (
 	"github.com/shipyard/shipyard/auth"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type (
@@ -26,7 +25,7 @@ func (a BuiltinAuthenticator) Name() string {
 }
 
 func (a BuiltinAuthenticator) Authenticate(username, password, hash string) (bool, error) {
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err == nil {
+	if err := auth.CompareHash(hash, password); err == nil {
 		return true, nil
 	}
 	return false, nil
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"strings"
+)
+
+type (
+	// ExecPolicy restricts the commands a role may exec in containers.
+	// Commands are matched argument by argument; a trailing "*" matches
+	// any remaining arguments (i.e. "ps *") and no commands deny every
+	// command. A disabled policy denies all exec and a read only policy
+	// only attaches stdout and stderr; a read only policy without commands
+	// allows any command. Policies with environments only apply to
+	// containers labeled with one of them.
+	ExecPolicy struct {
+		ID              string   `json:"id,omitempty" gorethink:"id,omitempty"`
+		Role            string   `json:"role,omitempty" gorethink:"role"`
+		Environments    []string `json:"environments,omitempty" gorethink:"environments"`
+		Disabled        bool     `json:"disabled" gorethink:"disabled"`
+		ReadOnly        bool     `json:"read_only" gorethink:"read_only"`
+		AllowedCommands []string `json:"allowed_commands,omitempty" gorethink:"allowed_commands"`
+	}
+)
+
+// Applies reports whether the policy applies to a container in the
+// environment
+func (p *ExecPolicy) Applies(environment string) bool {
+	if len(p.Environments) == 0 {
+		return true
+	}
+
+	for _, e := range p.Environments {
+		if e == environment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether the policy permits the command
+func (p *ExecPolicy) Allows(cmd []string) bool {
+	if p.Disabled {
+		return false
+	}
+
+	if len(p.AllowedCommands) == 0 {
+		return p.ReadOnly
+	}
+
+	for _, allowed := range p.AllowedCommands {
+		if commandMatches(strings.Fields(allowed), cmd) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func commandMatches(pattern, cmd []string) bool {
+	for i, p := range pattern {
+		if p == "*" && i == len(pattern)-1 {
+			return true
+		}
+
+		if i >= len(cmd) || cmd[i] != p {
+			return false
+		}
+	}
+
+	return len(pattern) == len(cmd)
+}
+
+// ExecAccess reports whether an account with the roles may exec the
+// command in a container in the environment and whether the session must
+// be read only. Each role is checked on its own: a role without applicable
+// policies is unrestricted, otherwise every applicable policy must allow
+// the command and any read only policy makes the role read only. The
+// account gets the least restricted access of its roles.
+func ExecAccess(roles []string, policies []*ExecPolicy, environment string, cmd []string) (allowed bool, readOnly bool) {
+	readOnly = true
+	for _, role := range roles {
+		roleAllowed := true
+		roleReadOnly := false
+		for _, p := range policies {
+			if p.Role != role || !p.Applies(environment) {
+				continue
+			}
+
+			if !p.Allows(cmd) {
+				roleAllowed = false
+				break
+			}
+
+			if p.ReadOnly {
+				roleReadOnly = true
+			}
+		}
+
+		if roleAllowed {
+			allowed = true
+			readOnly = readOnly && roleReadOnly
+		}
+	}
+
+	if !allowed {
+		return false, false
+	}
+
+	return allowed, readOnly
+}
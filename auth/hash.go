@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashScrypt   = "scrypt"
+	HashArgon2id = "argon2id"
+
+	saltLength = 16
+	keyLength  = 32
+)
+
+var (
+	ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+	ErrInvalidHash          = errors.New("invalid password hash")
+	ErrMismatchedPassword   = errors.New("password does not match")
+
+	// DefaultHashPolicy keeps bcrypt at its default cost
+	DefaultHashPolicy = HashPolicy{
+		Algorithm:     HashBcrypt,
+		BcryptCost:    bcrypt.DefaultCost,
+		ScryptN:       1 << 15,
+		ScryptR:       8,
+		ScryptP:       1,
+		Argon2Time:    3,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 2,
+	}
+
+	hashPolicy   = DefaultHashPolicy
+	hashPolicyMu sync.RWMutex
+
+	// schemeHashes are the RFC 2307 style hashes found in htpasswd files
+	// and LDAP exports ({SHA}base64); they can be imported and are
+	// rehashed on login
+	schemeHashes = map[string]schemeHash{
+		"SHA":     {sha1.New, false},
+		"SSHA":    {sha1.New, true},
+		"SHA256":  {sha256.New, false},
+		"SSHA256": {sha256.New, true},
+		"SHA512":  {sha512.New, false},
+		"SSHA512": {sha512.New, true},
+	}
+)
+
+type schemeHash struct {
+	hash   func() hash.Hash
+	salted bool
+}
+
+// HashPolicy is the algorithm and parameters new password hashes use;
+// stored hashes not matching the policy are rehashed on login
+type HashPolicy struct {
+	Algorithm  string
+	BcryptCost int
+	ScryptN    int
+	ScryptR    int
+	ScryptP    int
+	// Argon2Memory is in KiB
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+// SetHashPolicy sets the policy used by Hash
+func SetHashPolicy(p HashPolicy) error {
+	switch p.Algorithm {
+	case HashBcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashScrypt, HashArgon2id:
+	default:
+		return ErrUnknownHashAlgorithm
+	}
+
+	hashPolicyMu.Lock()
+	defer hashPolicyMu.Unlock()
+
+	hashPolicy = p
+
+	return nil
+}
+
+// CurrentHashPolicy returns the policy used by Hash
+func CurrentHashPolicy() HashPolicy {
+	hashPolicyMu.RLock()
+	defer hashPolicyMu.RUnlock()
+
+	return hashPolicy
+}
+
+// Hash hashes the password with the current policy
+func Hash(data string) (string, error) {
+	p := CurrentHashPolicy()
+
+	switch p.Algorithm {
+	case HashScrypt:
+		return hashScrypt(data, p)
+	case HashArgon2id:
+		return hashArgon2id(data, p)
+	}
+
+	h, err := bcrypt.GenerateFromPassword([]byte(data), p.BcryptCost)
+	return string(h[:]), err
+}
+
+// HashAlgorithm returns the algorithm of a stored hash or an empty string
+// when the hash is not supported
+func HashAlgorithm(hash string) string {
+	if scheme, _, ok := splitScheme(hash); ok {
+		return strings.ToLower(scheme)
+	}
+
+	switch {
+	case strings.HasPrefix(hash, "$"+HashScrypt+"$"):
+		return HashScrypt
+	case strings.HasPrefix(hash, "$"+HashArgon2id+"$"):
+		return HashArgon2id
+	case strings.HasPrefix(hash, "$2"):
+		return HashBcrypt
+	}
+
+	return ""
+}
+
+// CompareHash checks the password against a hash of any supported
+// algorithm
+func CompareHash(hash, password string) error {
+	var (
+		expected []byte
+		actual   []byte
+	)
+
+	switch HashAlgorithm(hash) {
+	case HashBcrypt:
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			return ErrMismatchedPassword
+		}
+
+		return nil
+	case HashScrypt:
+		p, salt, key, err := parseScrypt(hash)
+		if err != nil {
+			return err
+		}
+
+		k, err := scrypt.Key([]byte(password), salt, p.ScryptN, p.ScryptR, p.ScryptP, len(key))
+		if err != nil {
+			return err
+		}
+
+		expected, actual = key, k
+	case HashArgon2id:
+		p, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return err
+		}
+
+		expected = key
+		actual = argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, uint32(len(key)))
+	case "":
+		return ErrInvalidHash
+	default:
+		k, a, err := compareScheme(hash, password)
+		if err != nil {
+			return err
+		}
+
+		expected, actual = k, a
+	}
+
+	if subtle.ConstantTimeCompare(expected, actual) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+// NeedsRehash reports whether the hash was made with a different algorithm
+// or parameters than the current policy
+func NeedsRehash(hash string) bool {
+	p := CurrentHashPolicy()
+
+	if HashAlgorithm(hash) != p.Algorithm {
+		return true
+	}
+
+	switch p.Algorithm {
+	case HashBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != p.BcryptCost
+	case HashScrypt:
+		hp, _, _, err := parseScrypt(hash)
+		return err != nil || hp.ScryptN != p.ScryptN || hp.ScryptR != p.ScryptR || hp.ScryptP != p.ScryptP
+	case HashArgon2id:
+		hp, _, _, err := parseArgon2id(hash)
+		return err != nil || hp.Argon2Time != p.Argon2Time || hp.Argon2Memory != p.Argon2Memory || hp.Argon2Threads != p.Argon2Threads
+	}
+
+	return false
+}
+
+// splitScheme splits a {SCHEME}value hash
+func splitScheme(hash string) (string, string, bool) {
+	if !strings.HasPrefix(hash, "{") {
+		return "", "", false
+	}
+
+	i := strings.Index(hash, "}")
+	if i < 0 {
+		return "", "", false
+	}
+
+	scheme := strings.ToUpper(hash[1:i])
+	if _, ok := schemeHashes[scheme]; !ok {
+		return "", "", false
+	}
+
+	return scheme, hash[i+1:], true
+}
+
+// compareScheme returns the stored digest and the digest of the password;
+// salted hashes store the salt after the digest
+func compareScheme(hash, password string) ([]byte, []byte, error) {
+	scheme, value, _ := splitScheme(hash)
+	s := schemeHashes[scheme]
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil, ErrInvalidHash
+	}
+
+	h := s.hash()
+	if len(data) < h.Size() || !s.salted && len(data) != h.Size() {
+		return nil, nil, ErrInvalidHash
+	}
+
+	digest, salt := data[:h.Size()], data[h.Size():]
+	h.Write([]byte(password))
+	h.Write(salt)
+
+	return digest, h.Sum(nil), nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// hashScrypt encodes as $scrypt$n=N,r=R,p=P$salt$key
+func hashScrypt(data string, p HashPolicy) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(data), salt, p.ScryptN, p.ScryptR, p.ScryptP, keyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$%s$n=%d,r=%d,p=%d$%s$%s", HashScrypt, p.ScryptN, p.ScryptR, p.ScryptP, encode(salt), encode(key)), nil
+}
+
+// hashArgon2id uses the PHC string format $argon2id$v=19$m=M,t=T,p=P$salt$key
+func hashArgon2id(data string, p HashPolicy) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(data), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, keyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", HashArgon2id, argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads, encode(salt), encode(key)), nil
+}
+
+// splitHash returns the parameters, salt and key of a $alg$...$salt$key
+// hash
+func splitHash(hash string, fields int) ([]string, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != fields {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-2])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	return parts, salt, key, nil
+}
+
+func parseScrypt(hash string) (HashPolicy, []byte, []byte, error) {
+	p := HashPolicy{Algorithm: HashScrypt}
+
+	parts, salt, key, err := splitHash(hash, 5)
+	if err != nil {
+		return p, nil, nil, err
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "n=%d,r=%d,p=%d", &p.ScryptN, &p.ScryptR, &p.ScryptP); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	return p, salt, key, nil
+}
+
+func parseArgon2id(hash string) (HashPolicy, []byte, []byte, error) {
+	p := HashPolicy{Algorithm: HashArgon2id}
+
+	parts, salt, key, err := splitHash(hash, 6)
+	if err != nil {
+		return p, nil, nil, err
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Argon2Memory, &p.Argon2Time, &p.Argon2Threads); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"testing"
+)
+
+func testHashPolicy(algorithm string) HashPolicy {
+	p := DefaultHashPolicy
+	p.Algorithm = algorithm
+	p.BcryptCost = 4
+	// keep the tests fast
+	p.ScryptN = 1 << 4
+	p.Argon2Time = 1
+	p.Argon2Memory = 64
+
+	return p
+}
+
+func TestHashAlgorithms(t *testing.T) {
+	defer SetHashPolicy(DefaultHashPolicy)
+
+	for _, alg := range []string{HashBcrypt, HashScrypt, HashArgon2id} {
+		if err := SetHashPolicy(testHashPolicy(alg)); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := Hash(testPass)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if a := HashAlgorithm(h); a != alg {
+			t.Fatalf("expected algorithm %s; received %s", alg, a)
+		}
+
+		if err := CompareHash(h, testPass); err != nil {
+			t.Fatalf("%s: expected password to match: %s", alg, err)
+		}
+
+		if err := CompareHash(h, "wrong"); err != ErrMismatchedPassword {
+			t.Fatalf("%s: expected ErrMismatchedPassword; received %v", alg, err)
+		}
+
+		if NeedsRehash(h) {
+			t.Fatalf("%s: expected hash to match the policy", alg)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	defer SetHashPolicy(DefaultHashPolicy)
+
+	if err := SetHashPolicy(testHashPolicy(HashBcrypt)); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := Hash(testPass)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := testHashPolicy(HashBcrypt)
+	p.BcryptCost = 5
+	if err := SetHashPolicy(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !NeedsRehash(legacy) {
+		t.Fatal("expected a rehash when the bcrypt cost changes")
+	}
+
+	if err := SetHashPolicy(testHashPolicy(HashArgon2id)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !NeedsRehash(legacy) {
+		t.Fatal("expected a rehash when the algorithm changes")
+	}
+
+	// legacy hashes keep working
+	if err := CompareHash(legacy, testPass); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetHashPolicyInvalid(t *testing.T) {
+	p := DefaultHashPolicy
+	p.Algorithm = "md5"
+	if err := SetHashPolicy(p); err != ErrUnknownHashAlgorithm {
+		t.Fatalf("expected ErrUnknownHashAlgorithm; received %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseHtpasswd reads user:hash lines as written by htpasswd; hashes must
+// be bcrypt or {SHA} style (also used by LDAP exports). Blank lines and
+// lines starting with # are skipped.
+func ParseHtpasswd(r io.Reader) (map[string]string, error) {
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(l, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("htpasswd line %d: expected user:hash", line)
+		}
+
+		if HashAlgorithm(parts[1]) == "" {
+			return nil, fmt.Errorf("htpasswd line %d: unsupported hash for %s; use bcrypt (htpasswd -B) or {SHA}", line, parts[0])
+		}
+
+		hashes[parts[0]] = parts[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
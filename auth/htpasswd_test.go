@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+const testHtpasswd = `# exported users
+alice:$2y$05$UavsXF4.HHy5CX63Hnu2y.AayKxe6t05nBZbcGCboTMUzChxGWkx2
+bob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+
+carol:{SSHA}Wcm1xEisNjqp921ALcHfuQ7avFdzYWx0MTIzNA==
+dave:{SSHA512}Enu6C74BUnsH3FJ5DbcJeTfPKMqMpMZOm66wJaf/iZqtrDfdcDyFhEmcwbcWyY5CUEDqbpdskEI2yPdeCZGVPXNhbHQxMjM0
+`
+
+func TestParseHtpasswd(t *testing.T) {
+	hashes, err := ParseHtpasswd(strings.NewReader(testHtpasswd))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hashes) != 4 {
+		t.Fatalf("expected 4 users; received %d", len(hashes))
+	}
+
+	for user, hash := range hashes {
+		if err := CompareHash(hash, "secret"); err != nil {
+			t.Fatalf("%s: expected password to match: %s", user, err)
+		}
+
+		if err := CompareHash(hash, "wrong"); err != ErrMismatchedPassword {
+			t.Fatalf("%s: expected ErrMismatchedPassword; received %v", user, err)
+		}
+
+		// imported hashes are upgraded on login
+		if user != "alice" && !NeedsRehash(hash) {
+			t.Fatalf("%s: expected a rehash", user)
+		}
+	}
+}
+
+func TestParseHtpasswdUnsupported(t *testing.T) {
+	// apache md5
+	if _, err := ParseHtpasswd(strings.NewReader("alice:$apr1$salt$hash\n")); err == nil {
+		t.Fatal("expected an error for an unsupported hash")
+	}
+
+	if _, err := ParseHtpasswd(strings.NewReader("alice\n")); err == nil {
+		t.Fatal("expected an error for a line without a hash")
+	}
+}
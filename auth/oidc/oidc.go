@@ -0,0 +1,549 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	DefaultUsernameClaim = "preferred_username"
+	DefaultGroupsClaim   = "groups"
+
+	// clockSkew is how far the clock of the provider can be off when the
+	// expiry of id tokens is checked
+	clockSkew = time.Minute
+	// keysRefreshInterval limits how often the signing keys are fetched
+	// again for tokens signed with an unknown key
+	keysRefreshInterval = time.Minute
+)
+
+var (
+	ErrInvalidToken      = errors.New("invalid id token")
+	ErrUnknownKey        = errors.New("id token is signed with an unknown key")
+	ErrNoUsername        = errors.New("id token has no username claim")
+	ErrIssuerMismatch    = errors.New("the provider configuration is for another issuer")
+	ErrNoIDToken         = errors.New("the provider returned no id token")
+	ErrUnsupportedSigner = errors.New("id token is signed with an unsupported algorithm")
+	ErrInvalidGroupRole  = errors.New("invalid group mapping; expected group=role")
+)
+
+type (
+	// Provider signs users in with the authorization code flow of an
+	// OpenID Connect provider such as Keycloak, Okta or Google
+	Provider struct {
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		// RedirectURL is the callback of the controller registered
+		// with the provider (i.e. https://shipyard/auth/oidc/callback)
+		RedirectURL string
+		// Scopes are requested besides openid
+		Scopes []string
+		// UsernameClaim names the account; the email, when verified, and
+		// then sub are used when the token does not have it
+		UsernameClaim string
+		GroupsClaim   string
+		// GroupRoles maps a group of the provider to a shipyard role
+		GroupRoles map[string]string
+		// DefaultRole is given to new accounts without a mapped group
+		DefaultRole string
+		Client      *http.Client
+
+		mu          *sync.Mutex
+		config      *providerConfig
+		keys        map[string]crypto.PublicKey
+		keysFetched time.Time
+	}
+
+	// Identity is the user of a verified id token
+	Identity struct {
+		Subject       string
+		Username      string
+		Email         string
+		EmailVerified bool
+		Groups        []string
+	}
+
+	providerConfig struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+
+	tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+)
+
+func NewProvider(issuer, clientID, clientSecret, redirectURL string) *Provider {
+	log.Infof("Using OpenID Connect authentication: issuer=%s client=%s", issuer, clientID)
+	return &Provider{
+		Issuer:        strings.TrimSuffix(issuer, "/"),
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		RedirectURL:   redirectURL,
+		Scopes:        []string{"profile", "email"},
+		UsernameClaim: DefaultUsernameClaim,
+		GroupsClaim:   DefaultGroupsClaim,
+		GroupRoles:    map[string]string{},
+		Client:        &http.Client{Timeout: 30 * time.Second},
+		mu:            &sync.Mutex{},
+	}
+}
+
+// getJSON decodes the json document at the url
+func (p *Provider) getJSON(u string, v interface{}) error {
+	resp, err := p.Client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("error getting %s: %s: %s", u, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover returns the configuration of the provider; it is fetched once
+func (p *Provider) discover() (*providerConfig, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config != nil {
+		return p.config, nil
+	}
+
+	var config *providerConfig
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &config); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(config.Issuer, "/") != p.Issuer {
+		return nil, ErrIssuerMismatch
+	}
+
+	p.config = config
+
+	return config, nil
+}
+
+// AuthCodeURL returns the url of the provider the user signs in at; the
+// state and nonce are checked on the callback and the verifier is the
+// PKCE secret of the code
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	config, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(config.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return config.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange redeems the code of the callback for an id token and returns
+// the user once the token is verified
+func (p *Provider) Exchange(code, verifier, nonce string) (*Identity, error) {
+	config, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequest("POST", config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token *tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("error reading the token response: %s: %s", resp.Status, err)
+	}
+
+	if token.Error != "" {
+		return nil, fmt.Errorf("the provider refused the code: %s %s", token.Error, token.ErrorDescription)
+	}
+
+	if token.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	claims, err := p.verify(token.IDToken, nonce, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.identity(claims)
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of the
+// id token and returns its claims
+func (p *Provider) verify(rawToken, nonce string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.Issuer {
+		return nil, fmt.Errorf("%s: issued by %q", ErrInvalidToken, iss)
+	}
+
+	if !hasAudience(claims["aud"], p.ClientID) {
+		return nil, fmt.Errorf("%s: issued for another client", ErrInvalidToken)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("%s: expired", ErrInvalidToken)
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("%s: nonce mismatch", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// identity returns the user of the claims
+func (p *Provider) identity(claims map[string]interface{}) (*Identity, error) {
+	id := &Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+
+	// some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = verified
+	case string:
+		id.EmailVerified = verified == "true"
+	}
+
+	for _, claim := range []string{p.UsernameClaim, "email", "sub"} {
+		// anyone can set an unverified email to the one of another user
+		if claim == "email" && !id.EmailVerified {
+			continue
+		}
+
+		if username, _ := claims[claim].(string); username != "" {
+			id.Username = username
+			break
+		}
+	}
+
+	if id.Username == "" {
+		return nil, ErrNoUsername
+	}
+
+	switch groups := claims[p.GroupsClaim].(type) {
+	case string:
+		id.Groups = []string{groups}
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// ParseGroupRoles parses group=role mappings; the last "=" separates the
+// role so groups can contain one
+func ParseGroupRoles(mappings []string) (map[string]string, error) {
+	groupRoles := map[string]string{}
+	for _, m := range mappings {
+		i := strings.LastIndex(m, "=")
+		if i <= 0 || i == len(m)-1 {
+			return nil, ErrInvalidGroupRole
+		}
+
+		groupRoles[strings.ToLower(strings.TrimSpace(m[:i]))] = strings.TrimSpace(m[i+1:])
+	}
+
+	return groupRoles, nil
+}
+
+// Roles returns the roles mapped from the groups; groups are matched
+// ignoring case
+func (p *Provider) Roles(groups []string) []string {
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, g := range groups {
+		role, ok := p.GroupRoles[strings.ToLower(g)]
+		if !ok || seen[role] {
+			continue
+		}
+
+		seen[role] = true
+		roles = append(roles, role)
+	}
+
+	return roles
+}
+
+// key returns the signing key with the id; the keys are fetched again
+// when the provider rotated them
+func (p *Provider) key(kid string) (crypto.PublicKey, error) {
+	config, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(config.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			log.Warnf("oidc: skipping signing key %s: %s", k.Kid, err)
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// verifySignature checks a RS256, RS384, RS512, ES256 or ES384 signature
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var h crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		h = crypto.SHA256
+	case "RS384", "ES384":
+		h = crypto.SHA384
+	case "RS512":
+		h = crypto.SHA512
+	default:
+		return ErrUnsupportedSigner
+	}
+
+	hasher := h.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return ErrInvalidToken
+		}
+
+		if err := rsa.VerifyPKCS1v15(key, h, digest, signature); err != nil {
+			return ErrInvalidToken
+		}
+
+		return nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return ErrInvalidToken
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return ErrInvalidToken
+		}
+
+		return nil
+	}
+
+	return ErrUnsupportedSigner
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether the aud claim, a string or a list, holds
+// the client
+func hasAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,243 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProvider is an identity provider issuing id tokens with the claims
+// of the test
+type testProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+	// verifier is the PKCE verifier the token endpoint received
+	verifier string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "shipyard" || secret != "secret" || r.FormValue("code") != "code-1" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		p.verifier = r.FormValue("code_verifier")
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, "key-1", p.claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func (p *testProvider) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *testProvider) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                p.URL,
+		"aud":                []string{"shipyard"},
+		"sub":                "1234",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              "nonce-1",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"groups":             []string{"Ops", "dev"},
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newTestProvider(t)
+	defer idp.Close()
+
+	p := NewProvider(idp.URL+"/", "shipyard", "secret", "https://shipyard/auth/oidc/callback")
+
+	u, err := p.AuthCodeURL("state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := parsed.Query()
+	if !strings.HasPrefix(u, idp.URL+"/authorize?") || q.Get("state") != "state-1" || q.Get("nonce") != "nonce-1" {
+		t.Fatalf("unexpected url %s", u)
+	}
+
+	challenge := sha256.Sum256([]byte("verifier-1"))
+	if q.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("expected a S256 code challenge; received %s", u)
+	}
+
+	if q.Get("scope") != "openid profile email" {
+		t.Fatalf("expected the openid scope; received %q", q.Get("scope"))
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := newTestProvider(t)
+	defer idp.Close()
+
+	p := NewProvider(idp.URL, "shipyard", "secret", "https://shipyard/auth/oidc/callback")
+	p.GroupRoles = map[string]string{"ops": "admin", "dev": "containers:ro"}
+
+	idp.claims = idp.validClaims()
+	id, err := p.Exchange("code-1", "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Username != "alice" || id.Email != "alice@example.com" || id.Subject != "1234" {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+
+	if idp.verifier != "verifier-1" {
+		t.Fatalf("expected the code verifier to be sent; received %q", idp.verifier)
+	}
+
+	if roles := p.Roles(id.Groups); len(roles) != 2 || roles[0] != "admin" || roles[1] != "containers:ro" {
+		t.Fatalf("expected the roles of both groups; received %v", roles)
+	}
+
+	if _, err := p.Exchange("code-2", "verifier-1", "nonce-1"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected the provider error; received %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	idp := newTestProvider(t)
+	defer idp.Close()
+
+	p := NewProvider(idp.URL, "shipyard", "secret", "https://shipyard/auth/oidc/callback")
+	now := time.Now()
+
+	if _, err := p.verify(idp.sign(t, "key-1", idp.validClaims()), "nonce-1", now); err != nil {
+		t.Fatalf("expected a valid token; received %s", err)
+	}
+
+	for name, change := range map[string]func(c map[string]interface{}){
+		"issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"audience": func(c map[string]interface{}) { c["aud"] = "other" },
+		"expired":  func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"nonce":    func(c map[string]interface{}) { c["nonce"] = "replayed" },
+	} {
+		claims := idp.validClaims()
+		change(claims)
+
+		if _, err := p.verify(idp.sign(t, "key-1", claims), "nonce-1", now); err == nil {
+			t.Errorf("%s: expected the token to be refused", name)
+		}
+	}
+
+	token := idp.sign(t, "key-1", idp.validClaims())
+	tampered := token[:strings.LastIndex(token, ".")-2] + "xx" + token[strings.LastIndex(token, "."):]
+	if _, err := p.verify(tampered, "nonce-1", now); err == nil {
+		t.Error("expected a tampered token to be refused")
+	}
+
+	if _, err := p.verify(idp.sign(t, "key-2", idp.validClaims()), "nonce-1", now); err != ErrUnknownKey {
+		t.Errorf("expected %s; received %v", ErrUnknownKey, err)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	p := NewProvider("https://idp.example.com", "shipyard", "secret", "")
+
+	id, err := p.identity(map[string]interface{}{"sub": "1234", "email": "bob@example.com", "email_verified": true, "groups": "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Username != "bob@example.com" || len(id.Groups) != 1 {
+		t.Fatalf("expected the email as username and a single group; received %+v", id)
+	}
+
+	for _, verified := range []interface{}{nil, false, "false"} {
+		claims := map[string]interface{}{"sub": "1234", "email": "bob@example.com"}
+		if verified != nil {
+			claims["email_verified"] = verified
+		}
+
+		id, err := p.identity(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id.Username != "1234" {
+			t.Fatalf("expected the subject as username without a verified email (%v); received %+v", verified, id)
+		}
+	}
+
+	if _, err := p.identity(map[string]interface{}{}); err != ErrNoUsername {
+		t.Fatalf("expected %s; received %v", ErrNoUsername, err)
+	}
+}
+
+func TestParseGroupRoles(t *testing.T) {
+	groupRoles, err := ParseGroupRoles([]string{"Ops=admin", "team=dev=containers:ro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if groupRoles["ops"] != "admin" || groupRoles["team=dev"] != "containers:ro" {
+		t.Fatalf("unexpected group roles %v", groupRoles)
+	}
+
+	for _, m := range []string{"ops", "=admin", "ops="} {
+		if _, err := ParseGroupRoles([]string{m}); err != ErrInvalidGroupRole {
+			t.Fatalf("expected %s for %q; received %v", ErrInvalidGroupRole, m, err)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Permissions granted to roles; a role can also hold "*" for everything or
+// "<resource>:*" for every permission on a resource
+const (
+	PermContainersRead   = "containers:read"
+	PermContainersWrite  = "containers:write"
+	PermContainersDelete = "containers:delete"
+	PermContainersExec   = "containers:exec"
+	PermImagesRead       = "images:read"
+	PermImagesWrite      = "images:write"
+	PermImagesDelete     = "images:delete"
+	PermNetworksRead     = "networks:read"
+	PermNetworksManage   = "networks:manage"
+	PermVolumesRead      = "volumes:read"
+	PermVolumesManage    = "volumes:manage"
+	PermEventsRead       = "events:read"
+	PermEventsManage     = "events:manage"
+	PermAlertsRead       = "alerts:read"
+	PermAlertsManage     = "alerts:manage"
+	PermNodesRead        = "nodes:read"
+	PermNodesManage      = "nodes:manage"
+	PermNodesDirect      = "nodes:direct"
+	PermRegistriesRead   = "registries:read"
+	PermRegistriesManage = "registries:manage"
+	PermShareLinksRead   = "sharelinks:read"
+	PermShareLinksManage = "sharelinks:manage"
+	PermNotesRead        = "notes:read"
+	PermNotesManage      = "notes:manage"
+	PermAccountsRead     = "accounts:read"
+	PermAccountsManage   = "accounts:manage"
+	PermAuditRead        = "audit:read"
+	PermFreezesRead      = "freezes:read"
+	PermFreezesManage    = "freezes:manage"
+	PermFreezesOverride  = "freezes:override"
+	PermStacksRead       = "stacks:read"
+	PermStacksManage     = "stacks:manage"
+	PermTemplatesRead    = "templates:read"
+	PermTemplatesManage  = "templates:manage"
+	PermJobsRead         = "jobs:read"
+	PermJobsManage       = "jobs:manage"
+
+	// PermAuthenticated is held by every account
+	PermAuthenticated = "authenticated"
+)
+
+var (
+	apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+)
+
+// Permissions returns every permission that can be granted
+func Permissions() []string {
+	return []string{
+		PermContainersRead,
+		PermContainersWrite,
+		PermContainersDelete,
+		PermContainersExec,
+		PermImagesRead,
+		PermImagesWrite,
+		PermImagesDelete,
+		PermNetworksRead,
+		PermNetworksManage,
+		PermVolumesRead,
+		PermVolumesManage,
+		PermEventsRead,
+		PermEventsManage,
+		PermAlertsRead,
+		PermAlertsManage,
+		PermNodesRead,
+		PermNodesManage,
+		PermNodesDirect,
+		PermRegistriesRead,
+		PermRegistriesManage,
+		PermShareLinksRead,
+		PermShareLinksManage,
+		PermNotesRead,
+		PermNotesManage,
+		PermAccountsRead,
+		PermAccountsManage,
+		PermAuditRead,
+		PermFreezesRead,
+		PermFreezesManage,
+		PermFreezesOverride,
+		PermStacksRead,
+		PermStacksManage,
+		PermTemplatesRead,
+		PermTemplatesManage,
+		PermJobsRead,
+		PermJobsManage,
+	}
+}
+
+// ValidPermission reports whether the permission can be granted to a role
+func ValidPermission(perm string) bool {
+	if perm == "*" {
+		return true
+	}
+
+	for _, p := range Permissions() {
+		if p == perm || strings.HasSuffix(perm, ":*") && strings.HasPrefix(p, strings.TrimSuffix(perm, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPermission reports whether the role grants the permission
+func (acl *ACL) HasPermission(perm string) bool {
+	if perm == PermAuthenticated {
+		return true
+	}
+
+	resource := strings.SplitN(perm, ":", 2)[0]
+	for _, p := range acl.Permissions {
+		if p == "*" || p == perm || p == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether the role grants the method on the path through
+// either its rules or its permissions
+func (acl *ACL) Allows(path, method string) bool {
+	for _, rule := range acl.Rules {
+		if rule.Allows(path, method) {
+			return true
+		}
+	}
+
+	if perm := RequiredPermission(method, path); perm != "" {
+		return acl.HasPermission(perm)
+	}
+
+	return false
+}
+
+// readOrManage returns read for GET requests and manage otherwise
+func readOrManage(method, read, manage string) string {
+	if method == "GET" || method == "HEAD" {
+		return read
+	}
+
+	return manage
+}
+
+// RequiredPermission returns the permission needed for a request to the
+// Shipyard api or the proxied Docker api; routes without a permission are
+// only available to roles with a matching rule (i.e. admin)
+func RequiredPermission(method, path string) string {
+	path = apiVersionPrefix.ReplaceAllString(path, "/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch parts[0] {
+	case "containers":
+		if len(parts) > 2 && (parts[2] == "exec" || parts[2] == "attach") {
+			return PermContainersExec
+		}
+
+		switch method {
+		case "GET", "HEAD":
+			return PermContainersRead
+		case "DELETE":
+			return PermContainersDelete
+		}
+
+		// copying files out of a container does not change it
+		if len(parts) > 2 && parts[2] == "copy" {
+			return PermContainersRead
+		}
+
+		return PermContainersWrite
+	case "exec":
+		return PermContainersExec
+	case "images":
+		switch method {
+		case "GET", "HEAD":
+			return PermImagesRead
+		case "DELETE":
+			return PermImagesDelete
+		}
+
+		return PermImagesWrite
+	case "build", "commit", "auth":
+		return PermImagesWrite
+	case "networks":
+		return readOrManage(method, PermNetworksRead, PermNetworksManage)
+	case "volumes":
+		return readOrManage(method, PermVolumesRead, PermVolumesManage)
+	case "events":
+		return PermEventsRead
+	case "info":
+		return PermNodesRead
+	case "_ping", "version":
+		return PermAuthenticated
+	case "api":
+		return apiPermission(method, parts[1:])
+	}
+
+	return ""
+}
+
+func apiPermission(method string, parts []string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+
+	switch parts[0] {
+	case "containers":
+		return readOrManage(method, PermContainersRead, PermContainersWrite)
+	case "consolesession", "exec":
+		// recordings of exec sessions are kept for audits
+		if len(parts) > 2 && parts[1] == "sessions" {
+			return PermAuditRead
+		}
+
+		return PermContainersExec
+	case "events":
+		return readOrManage(method, PermEventsRead, PermEventsManage)
+	case "ws":
+		// websockets pushing updates of a resource need its read
+		// permission
+		if len(parts) > 1 && parts[1] == "containers" {
+			return PermContainersRead
+		}
+	case "alerts":
+		return readOrManage(method, PermAlertsRead, PermAlertsManage)
+	case "nodes":
+		return readOrManage(method, PermNodesRead, PermNodesManage)
+	case "debug":
+		// debug containers are exec sessions in their own container
+		return PermContainersExec
+	case "clusters":
+		// clusters hold the tls material of their endpoint
+		return readOrManage(method, PermNodesRead, "")
+	case "volumes":
+		return readOrManage(method, PermVolumesRead, PermVolumesManage)
+	case "networks":
+		return readOrManage(method, PermNetworksRead, PermNetworksManage)
+	case "registries":
+		return readOrManage(method, PermRegistriesRead, PermRegistriesManage)
+	case "sharelinks":
+		return readOrManage(method, PermShareLinksRead, PermShareLinksManage)
+	case "notes":
+		return readOrManage(method, PermNotesRead, PermNotesManage)
+	case "accounts":
+		// exports include personal data
+		if len(parts) > 2 && parts[2] == "export" {
+			return PermAccountsManage
+		}
+
+		// users enroll their own second factor; the api checks the account
+		if len(parts) > 2 && parts[2] == "2fa" {
+			return PermAuthenticated
+		}
+
+		return readOrManage(method, PermAccountsRead, PermAccountsManage)
+	case "account":
+		// users view and change their own profile
+		return PermAuthenticated
+	case "roles", "permissions", "access-report":
+		return readOrManage(method, PermAccountsRead, PermAccountsManage)
+	case "stacks":
+		return readOrManage(method, PermStacksRead, PermStacksManage)
+	case "container-defaults":
+		// the defaults change every container deployed so only admins
+		// set them
+		return readOrManage(method, PermStacksRead, "")
+	case "templates":
+		// deploying a template creates a stack
+		if len(parts) > 2 && parts[2] == "deploy" {
+			return PermStacksManage
+		}
+
+		return readOrManage(method, PermTemplatesRead, PermTemplatesManage)
+	case "freezes":
+		return readOrManage(method, PermFreezesRead, PermFreezesManage)
+	case "jobs":
+		return readOrManage(method, PermJobsRead, PermJobsManage)
+	case "pulls":
+		return PermImagesRead
+	case "query":
+		// queries only read; related resources are checked by the
+		// manager
+		return PermContainersRead
+	case "actions":
+		// the actions are filtered by the roles of the account
+		return PermAuthenticated
+	case "auditlogs":
+		// audit entries cannot be changed through the api
+		if method == "GET" || method == "HEAD" {
+			return PermAuditRead
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,38 @@
+package auth
+
+// Levels of the requests to the proxied Docker api
+const (
+	ProxyRead  = "read"
+	ProxyWrite = "write"
+	ProxyAdmin = "admin"
+)
+
+// ProxyLevel returns whether a request to the proxied Docker api reads or
+// changes the cluster; routes without a permission (i.e. new Docker
+// endpoints) are admin only
+func ProxyLevel(method, path string) string {
+	switch RequiredPermission(method, path) {
+	case "":
+		return ProxyAdmin
+	case PermContainersRead, PermImagesRead, PermNetworksRead, PermVolumesRead,
+		PermEventsRead, PermNodesRead, PermAuthenticated:
+		return ProxyRead
+	}
+
+	return ProxyWrite
+}
+
+// AllowsProxied reports whether the role grants a request to the proxied
+// Docker api. Access rules only match on a path prefix and a method so they
+// only grant reads; writes (i.e. pushing or building images) need the
+// permission of the route and admin routes need every permission.
+func (acl *ACL) AllowsProxied(path, method string) bool {
+	switch ProxyLevel(method, path) {
+	case ProxyRead:
+		return acl.Allows(path, method)
+	case ProxyWrite:
+		return acl.HasPermission(RequiredPermission(method, path))
+	}
+
+	return acl.HasPermission("*")
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+)
+
+type (
+	AccessReport struct {
+		Permissions []string         `json:"permissions,omitempty"`
+		Accounts    []*AccountAccess `json:"accounts,omitempty"`
+	}
+
+	AccountAccess struct {
+		Username    string          `json:"username,omitempty"`
+		Roles       []string        `json:"roles,omitempty"`
+		Permissions map[string]bool `json:"permissions,omitempty"`
+	}
+)
+
+// permissionName returns the report column name for a method and path
+func permissionName(method, path string) string {
+	return fmt.Sprintf("%s %s", method, path)
+}
+
+// NewAccessReport builds the effective access of each account against
+// every permission defined by the acls and every grantable permission
+func NewAccessReport(accounts []*Account, acls []*ACL) *AccessReport {
+	type permission struct {
+		path   string
+		method string
+		// perm is set for granted permissions (i.e. containers:read)
+		perm string
+	}
+
+	perms := map[string]permission{}
+	for _, acl := range acls {
+		for _, rule := range acl.Rules {
+			// wildcard rules grant everything; they do not define a permission
+			if rule.Path == "*" {
+				continue
+			}
+
+			for _, m := range rule.Methods {
+				perms[permissionName(m, rule.Path)] = permission{
+					path:   rule.Path,
+					method: m,
+				}
+			}
+		}
+	}
+
+	for _, p := range Permissions() {
+		perms[p] = permission{perm: p}
+	}
+
+	names := []string{}
+	for n := range perms {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	roles := map[string]*ACL{}
+	for _, acl := range acls {
+		roles[acl.RoleName] = acl
+	}
+
+	report := &AccessReport{
+		Permissions: names,
+		Accounts:    []*AccountAccess{},
+	}
+
+	for _, acct := range accounts {
+		access := &AccountAccess{
+			Username:    acct.Username,
+			Roles:       acct.Roles,
+			Permissions: map[string]bool{},
+		}
+
+		for _, n := range names {
+			p := perms[n]
+			allowed := false
+			for _, role := range acct.Roles {
+				acl, ok := roles[role]
+				if !ok {
+					continue
+				}
+
+				if p.perm != "" {
+					allowed = acl.HasPermission(p.perm)
+				} else {
+					allowed = acl.Allows(p.path, p.method)
+				}
+
+				if allowed {
+					break
+				}
+			}
+
+			access.Permissions[n] = allowed
+		}
+
+		report.Accounts = append(report.Accounts, access)
+	}
+
+	return report
+}
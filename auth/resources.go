@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidResourceLimit = errors.New("resource limits cannot be negative")
+)
+
+type (
+	// ResourceLimit caps the memory and cpu accounts with the role may give
+	// containers when updating them; limits with environments only apply
+	// to containers labeled with one of them. Zero values are not capped.
+	ResourceLimit struct {
+		Environments []string `json:"environments,omitempty" gorethink:"environments"`
+		MaxMemory    int64    `json:"max_memory,omitempty" gorethink:"max_memory"`
+		MaxCPUShares int64    `json:"max_cpu_shares,omitempty" gorethink:"max_cpu_shares"`
+		MaxCPUs      float64  `json:"max_cpus,omitempty" gorethink:"max_cpus"`
+	}
+
+	// Resources are the limits requested for a container; cpus is the
+	// quota over the period
+	Resources struct {
+		Memory    int64
+		CPUShares int64
+		CPUs      float64
+	}
+)
+
+// Validate checks the caps are not negative
+func (l *ResourceLimit) Validate() error {
+	if l.MaxMemory < 0 || l.MaxCPUShares < 0 || l.MaxCPUs < 0 {
+		return ErrInvalidResourceLimit
+	}
+
+	return nil
+}
+
+// Applies reports whether the limit applies to a container in the
+// environment
+func (l *ResourceLimit) Applies(environment string) bool {
+	if len(l.Environments) == 0 {
+		return true
+	}
+
+	for _, e := range l.Environments {
+		if e == environment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether the resources are within the caps; resources
+// left unset (i.e. unlimited memory) exceed any cap
+func (l *ResourceLimit) Allows(r Resources) bool {
+	if l.MaxMemory > 0 && (r.Memory <= 0 || r.Memory > l.MaxMemory) {
+		return false
+	}
+
+	if l.MaxCPUShares > 0 && (r.CPUShares <= 0 || r.CPUShares > l.MaxCPUShares) {
+		return false
+	}
+
+	if l.MaxCPUs > 0 && (r.CPUs <= 0 || r.CPUs > l.MaxCPUs) {
+		return false
+	}
+
+	return true
+}
+
+// ResourceAccess reports whether an account may give a container in the
+// environment the resources; like RestartPolicyAccess any role whose
+// applicable limits all allow the resources is enough
+func ResourceAccess(acct *Account, acls []*ACL, environment string, r Resources) bool {
+	for _, acl := range acls {
+		if !acct.HasRole(acl.RoleName) {
+			continue
+		}
+
+		allowed := true
+		for _, l := range acl.ResourceLimits {
+			if l.Applies(environment) && !l.Allows(r) {
+				allowed = false
+				break
+			}
+		}
+
+		if allowed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestResourceAccess(t *testing.T) {
+	acls := []*ACL{
+		{
+			RoleName: "operator",
+			ResourceLimits: []*ResourceLimit{
+				{Environments: []string{"prod"}, MaxMemory: 1024, MaxCPUs: 2},
+			},
+		},
+		{RoleName: "admin"},
+	}
+
+	operator := &Account{Roles: []string{"operator"}}
+
+	tests := []struct {
+		acct        *Account
+		environment string
+		resources   Resources
+		allowed     bool
+	}{
+		{operator, "prod", Resources{Memory: 512, CPUs: 1}, true},
+		{operator, "prod", Resources{Memory: 2048, CPUs: 1}, false},
+		{operator, "prod", Resources{Memory: 512, CPUs: 4}, false},
+		// unlimited memory exceeds any cap
+		{operator, "prod", Resources{CPUs: 1}, false},
+		{operator, "dev", Resources{Memory: 2048}, true},
+		{&Account{Roles: []string{"operator", "admin"}}, "prod", Resources{Memory: 2048}, true},
+	}
+
+	for _, test := range tests {
+		if allowed := ResourceAccess(test.acct, acls, test.environment, test.resources); allowed != test.allowed {
+			t.Errorf("roles %v %s %+v: expected %v; received %v", test.acct.Roles, test.environment, test.resources, test.allowed, allowed)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+)
+
+// Restart policies of the engine
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartOnFailure     = "on-failure"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+var (
+	ErrInvalidRestartPolicy = errors.New("restart policies are no, always, on-failure or unless-stopped")
+)
+
+type (
+	// RestartPolicyLimit restricts the restart policies accounts with the
+	// role may set on containers; limits with environments only apply to
+	// containers labeled with one of them (i.e. prod containers must be
+	// always)
+	RestartPolicyLimit struct {
+		Environments []string `json:"environments,omitempty" gorethink:"environments"`
+		Policies     []string `json:"policies" gorethink:"policies"`
+	}
+)
+
+// ValidRestartPolicy reports whether the engine knows the policy
+func ValidRestartPolicy(name string) bool {
+	switch name {
+	case RestartNo, RestartAlways, RestartOnFailure, RestartUnlessStopped:
+		return true
+	}
+
+	return false
+}
+
+// Validate checks the limit only allows known policies
+func (l *RestartPolicyLimit) Validate() error {
+	if len(l.Policies) == 0 {
+		return ErrInvalidRestartPolicy
+	}
+
+	for _, p := range l.Policies {
+		if !ValidRestartPolicy(p) {
+			return ErrInvalidRestartPolicy
+		}
+	}
+
+	return nil
+}
+
+// Applies reports whether the limit applies to a container in the
+// environment
+func (l *RestartPolicyLimit) Applies(environment string) bool {
+	if len(l.Environments) == 0 {
+		return true
+	}
+
+	for _, e := range l.Environments {
+		if e == environment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether the limit permits the policy
+func (l *RestartPolicyLimit) Allows(policy string) bool {
+	for _, p := range l.Policies {
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RestartPolicyAccess reports whether an account may set the policy on a
+// container in the environment. Like ExecAccess each role is checked on
+// its own: a role without applicable limits is unrestricted, otherwise
+// every applicable limit must allow the policy. Any role allowing the
+// policy is enough.
+func RestartPolicyAccess(acct *Account, acls []*ACL, environment, policy string) bool {
+	for _, acl := range acls {
+		if !acct.HasRole(acl.RoleName) {
+			continue
+		}
+
+		allowed := true
+		for _, l := range acl.RestartPolicies {
+			if l.Applies(environment) && !l.Allows(policy) {
+				allowed = false
+				break
+			}
+		}
+
+		if allowed {
+			return true
+		}
+	}
+
+	return false
+}
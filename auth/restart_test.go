@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestRestartPolicyAccess(t *testing.T) {
+	acls := []*ACL{
+		{
+			RoleName: "operator",
+			RestartPolicies: []*RestartPolicyLimit{
+				{Environments: []string{"prod"}, Policies: []string{RestartAlways}},
+			},
+		},
+		{RoleName: "admin"},
+	}
+
+	operator := &Account{Roles: []string{"operator"}}
+
+	tests := []struct {
+		acct        *Account
+		environment string
+		policy      string
+		allowed     bool
+	}{
+		{operator, "prod", RestartAlways, true},
+		{operator, "prod", RestartNo, false},
+		{operator, "dev", RestartNo, true},
+		{&Account{Roles: []string{"operator", "admin"}}, "prod", RestartNo, true},
+		{&Account{}, "dev", RestartNo, false},
+	}
+
+	for _, test := range tests {
+		if allowed := RestartPolicyAccess(test.acct, acls, test.environment, test.policy); allowed != test.allowed {
+			t.Errorf("roles %v %s %s: expected %v; received %v", test.acct.Roles, test.environment, test.policy, test.allowed, allowed)
+		}
+	}
+}
+
+func TestRestartPolicyLimitValidate(t *testing.T) {
+	if err := (&RestartPolicyLimit{Policies: []string{RestartAlways, RestartUnlessStopped}}).Validate(); err != nil {
+		t.Fatalf("expected valid limit: %s", err)
+	}
+
+	if err := (&RestartPolicyLimit{}).Validate(); err != ErrInvalidRestartPolicy {
+		t.Fatalf("expected ErrInvalidRestartPolicy for a limit without policies; received %v", err)
+	}
+
+	if err := (&RestartPolicyLimit{Policies: []string{"sometimes"}}).Validate(); err != ErrInvalidRestartPolicy {
+		t.Fatalf("expected ErrInvalidRestartPolicy; received %v", err)
+	}
+}
@@ -1,18 +1,53 @@
 package auth
 
+import (
+	"strings"
+)
+
 type (
 	ACL struct {
-		RoleName    string        `json:"role_name,omitempty"`
-		Description string        `json:"description,omitempty"`
-		Rules       []*AccessRule `json:"rules,omitempty"`
+		RoleName    string        `json:"role_name,omitempty" gorethink:"id"`
+		Description string        `json:"description,omitempty" gorethink:"description"`
+		Rules       []*AccessRule `json:"rules,omitempty" gorethink:"rules"`
+		Permissions []string      `json:"permissions,omitempty" gorethink:"permissions"`
+		// LabelScope restricts the accounts with the role to containers
+		// with one of the labels
+		LabelScope LabelScope `json:"label_scope,omitempty" gorethink:"label_scope,omitempty"`
+		// RestartPolicies limits the restart policies accounts with the
+		// role may set
+		RestartPolicies []*RestartPolicyLimit `json:"restart_policies,omitempty" gorethink:"restart_policies,omitempty"`
+		// ResourceLimits caps the memory and cpu accounts with the role
+		// may give containers
+		ResourceLimits []*ResourceLimit `json:"resource_limits,omitempty" gorethink:"resource_limits,omitempty"`
+		Builtin        bool             `json:"builtin,omitempty" gorethink:"-"`
 	}
 
 	AccessRule struct {
-		Path    string   `json:"path,omitempty"`
-		Methods []string `json:"methods,omitempty"`
+		Path    string   `json:"path,omitempty" gorethink:"path"`
+		Methods []string `json:"methods,omitempty" gorethink:"methods"`
 	}
 )
 
+// Allows reports whether the rule grants the method on the path
+func (rule *AccessRule) Allows(path, method string) bool {
+	// check wildcard
+	if rule.Path == "*" {
+		return true
+	}
+
+	// check path
+	if strings.HasPrefix(path, rule.Path) {
+		// check method
+		for _, m := range rule.Methods {
+			if m == method {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func DefaultACLs() []*ACL {
 	acls := []*ACL{}
 	adminACL := &ACL{
@@ -24,6 +59,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"*"},
 			},
 		},
+		Permissions: []string{"*"},
 	}
 	acls = append(acls, adminACL)
 
@@ -36,6 +72,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET"},
 			},
 		},
+		Permissions: []string{PermContainersRead},
 	}
 	acls = append(acls, containersACLRO)
 
@@ -48,6 +85,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET", "POST", "DELETE"},
 			},
 		},
+		Permissions: []string{PermContainersRead, PermContainersWrite, PermContainersDelete, PermContainersExec},
 	}
 	acls = append(acls, containersACLRW)
 
@@ -60,6 +98,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET"},
 			},
 		},
+		Permissions: []string{PermEventsRead},
 	}
 	acls = append(acls, eventsACLRO)
 
@@ -72,6 +111,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET", "POST", "DELETE"},
 			},
 		},
+		Permissions: []string{PermEventsRead, PermEventsManage},
 	}
 	acls = append(acls, eventsACLRW)
 
@@ -84,6 +124,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET"},
 			},
 		},
+		Permissions: []string{PermImagesRead},
 	}
 	acls = append(acls, imagesACLRO)
 
@@ -96,6 +137,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET", "POST", "DELETE"},
 			},
 		},
+		Permissions: []string{PermImagesRead, PermImagesWrite, PermImagesDelete},
 	}
 	acls = append(acls, imagesACLRW)
 
@@ -108,6 +150,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET"},
 			},
 		},
+		Permissions: []string{PermNodesRead},
 	}
 	acls = append(acls, nodesACLRO)
 
@@ -120,6 +163,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET", "POST", "DELETE"},
 			},
 		},
+		Permissions: []string{PermNodesRead, PermNodesManage},
 	}
 	acls = append(acls, nodesACLRW)
 
@@ -132,6 +176,7 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET"},
 			},
 		},
+		Permissions: []string{PermRegistriesRead},
 	}
 	acls = append(acls, registriesACLRO)
 
@@ -144,8 +189,40 @@ func DefaultACLs() []*ACL {
 				Methods: []string{"GET", "POST", "DELETE"},
 			},
 		},
+		Permissions: []string{PermRegistriesRead, PermRegistriesManage},
 	}
 	acls = append(acls, registriesACLRW)
 
+	operatorACLRO := &ACL{
+		RoleName:    "operator:ro",
+		Description: "Read Only Operator",
+		Permissions: []string{
+			PermContainersRead,
+			PermImagesRead,
+			PermNetworksRead,
+			PermEventsRead,
+			PermAlertsRead,
+			PermNodesRead,
+			PermRegistriesRead,
+		},
+	}
+	acls = append(acls, operatorACLRO)
+
+	deployACL := &ACL{
+		RoleName:    "deploy",
+		Description: "Deploy (CI)",
+		Permissions: []string{
+			PermContainersRead,
+			PermContainersWrite,
+			PermImagesRead,
+			PermImagesWrite,
+		},
+	}
+	acls = append(acls, deployACL)
+
+	for _, acl := range acls {
+		acl.Builtin = true
+	}
+
 	return acls
 }
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidLabelScope = errors.New("label scopes are a label key or key=value")
+)
+
+// LabelScope restricts an account to the containers carrying one of its
+// labels; entries are key=value or a key matching any value
+type LabelScope []string
+
+// Matches reports whether the labels are in the scope; a nil scope is
+// unrestricted
+func (s LabelScope) Matches(labels map[string]string) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, entry := range s {
+		parts := strings.SplitN(entry, "=", 2)
+		v, ok := labels[parts[0]]
+		if !ok {
+			continue
+		}
+
+		if len(parts) == 1 || parts[1] == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks every entry has a label key
+func (s LabelScope) Validate() error {
+	for _, entry := range s {
+		if strings.TrimSpace(strings.SplitN(entry, "=", 2)[0]) == "" {
+			return ErrInvalidLabelScope
+		}
+	}
+
+	return nil
+}
+
+// ContainerScope returns the containers the account is restricted to; nil
+// when unrestricted. The scope of the account replaces the ones of its
+// roles and roles only restrict the account when every role it has is
+// scoped; the scopes of the roles are combined.
+func ContainerScope(acct *Account, acls []*ACL) LabelScope {
+	if len(acct.LabelScope) > 0 {
+		return acct.LabelScope
+	}
+
+	var scope LabelScope
+	for _, acl := range acls {
+		if !acct.HasRole(acl.RoleName) {
+			continue
+		}
+
+		if len(acl.LabelScope) == 0 {
+			return nil
+		}
+
+		scope = append(scope, acl.LabelScope...)
+	}
+
+	return scope
+}
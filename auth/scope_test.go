@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestLabelScopeMatches(t *testing.T) {
+	labels := map[string]string{"team": "payments", "tier": "web"}
+
+	tests := []struct {
+		scope LabelScope
+		match bool
+	}{
+		{nil, true},
+		{LabelScope{}, false},
+		{LabelScope{"team=payments"}, true},
+		{LabelScope{"team=search"}, false},
+		{LabelScope{"team"}, true},
+		{LabelScope{"owner"}, false},
+		{LabelScope{"team=search", "tier=web"}, true},
+	}
+
+	for _, test := range tests {
+		if m := test.scope.Matches(labels); m != test.match {
+			t.Errorf("scope %v: expected match %v; received %v", test.scope, test.match, m)
+		}
+	}
+}
+
+func TestLabelScopeValidate(t *testing.T) {
+	if err := (LabelScope{"team=payments", "tier"}).Validate(); err != nil {
+		t.Fatalf("expected valid scope: %s", err)
+	}
+
+	if err := (LabelScope{"=payments"}).Validate(); err != ErrInvalidLabelScope {
+		t.Fatalf("expected ErrInvalidLabelScope; received %v", err)
+	}
+}
+
+func TestContainerScope(t *testing.T) {
+	acls := []*ACL{
+		{RoleName: "payments", LabelScope: LabelScope{"team=payments"}},
+		{RoleName: "search", LabelScope: LabelScope{"team=search"}},
+		{RoleName: "containers:ro"},
+	}
+
+	acct := &Account{Roles: []string{"payments", "search"}}
+	scope := ContainerScope(acct, acls)
+	if len(scope) != 2 {
+		t.Fatalf("expected the scopes of both roles; received %v", scope)
+	}
+
+	acct = &Account{Roles: []string{"payments", "containers:ro"}}
+	if scope := ContainerScope(acct, acls); scope != nil {
+		t.Fatalf("expected an unscoped role to lift the scope; received %v", scope)
+	}
+
+	acct = &Account{Roles: []string{"containers:ro"}, LabelScope: LabelScope{"team=web"}}
+	scope = ContainerScope(acct, acls)
+	if len(scope) != 1 || scope[0] != "team=web" {
+		t.Fatalf("expected the scope of the account; received %v", scope)
+	}
+}
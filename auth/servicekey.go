@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// ScopeWebhookDeploy lets a service key redeploy stacks (i.e. from a
+	// CI webhook) without the rest of stacks:manage
+	ScopeWebhookDeploy = "webhook:deploy"
+)
+
+// ValidScope reports whether the scope can be given to a service key
+func ValidScope(scope string) bool {
+	return scope == ScopeWebhookDeploy || scope == PermAuthenticated || ValidPermission(scope)
+}
+
+// KeyID identifies a service key without revealing it
+func KeyID(key string) string {
+	return TokenID(key)
+}
+
+// Expired reports whether the key can no longer be used
+func (k *ServiceKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// Allows reports whether the scopes of the key grant the method on the
+// path; unlike accounts, scoped keys only get the routes every account may
+// use (i.e. /api/account) when a scope lists authenticated
+func (k *ServiceKey) Allows(path, method string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+
+	switch perm := RequiredPermission(method, path); perm {
+	case "":
+	case PermAuthenticated:
+		for _, scope := range k.Scopes {
+			if scope == PermAuthenticated || scope == "*" {
+				return true
+			}
+		}
+	default:
+		scoped := &ACL{Permissions: k.Scopes}
+		if scoped.HasPermission(perm) {
+			return true
+		}
+	}
+
+	for _, scope := range k.Scopes {
+		if scope == ScopeWebhookDeploy && method == "POST" && isStackRedeploy(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isStackRedeploy reports whether the path redeploys a stack
+func isStackRedeploy(path string) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	return len(parts) == 4 && parts[0] == "api" && parts[1] == "stacks" && parts[3] == "redeploy"
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP codes (RFC 6238) as generated by authenticator apps
+const (
+	totpDigits       = 6
+	totpModulo       = 1000000
+	totpPeriod       = 30
+	totpSecretLength = 20
+	// totpSkew is how many periods before and after the current one are
+	// accepted for clocks that drifted
+	totpSkew = 1
+)
+
+var (
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// NewTOTPSecret returns a random base32 secret for an authenticator app
+func NewTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPStep returns the period of the time
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// TOTPCode returns the code of the secret for the period
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulo), nil
+}
+
+// ValidateTOTP returns the period of the code when it is valid at the
+// time; codes of periods up to lastStep are rejected so a code cannot be
+// used twice
+func ValidateTOTP(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// TOTPProvisioningURI returns the otpauth uri authenticator apps read
+// from a QR code
+func TOTPProvisioningURI(issuer, username, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + username)
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
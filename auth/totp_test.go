@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// base32 of the secret of the test vectors of RFC 6238
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, test := range tests {
+		code, err := TOTPCode(testTOTPSecret, TOTPStep(time.Unix(test.time, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != test.code {
+			t.Errorf("time %d: expected %s; received %s", test.time, test.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+
+	code, err := TOTPCode(testTOTPSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := ValidateTOTP(testTOTPSecret, code, now, 0)
+	if !ok || s != step {
+		t.Fatalf("expected the code to be valid for step %d; received %d %v", step, s, ok)
+	}
+
+	// clocks can drift a period
+	if _, ok := ValidateTOTP(testTOTPSecret, code, now.Add(totpPeriod*time.Second), 0); !ok {
+		t.Fatal("expected the code of the previous period to be valid")
+	}
+
+	if _, ok := ValidateTOTP(testTOTPSecret, code, now.Add(3*totpPeriod*time.Second), 0); ok {
+		t.Fatal("expected an old code to be rejected")
+	}
+
+	if _, ok := ValidateTOTP(testTOTPSecret, code, now, step); ok {
+		t.Fatal("expected a used code to be rejected")
+	}
+
+	if _, ok := ValidateTOTP(testTOTPSecret, "12345", now, 0); ok {
+		t.Fatal("expected a short code to be rejected")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Fatalf("expected a valid secret: %s", err)
+	}
+
+	uri := TOTPProvisioningURI("Shipyard", "admin", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Shipyard:admin?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected provisioning uri %s", uri)
+	}
+}
@@ -0,0 +1,35 @@
+package shipyard
+
+import (
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/dockerhub"
+)
+
+// BackupVersion is the version of the backup format written by this
+// release; backups of other versions are refused on restore
+const BackupVersion = 1
+
+type (
+	// Backup holds the configuration of a controller needed to rebuild it
+	// on a new datastore; it includes password hashes and keys and has to
+	// be kept as safe as the datastore
+	Backup struct {
+		Version     int                     `json:"version"`
+		CreatedAt   time.Time               `json:"created_at"`
+		Datastore   string                  `json:"datastore,omitempty"`
+		Accounts    []*BackupAccount        `json:"accounts"`
+		Roles       []*auth.ACL             `json:"roles"`
+		ServiceKeys []*auth.ServiceKey      `json:"service_keys"`
+		WebhookKeys []*dockerhub.WebhookKey `json:"webhook_keys"`
+		Registries  []*Registry             `json:"registries"`
+	}
+
+	// BackupAccount keeps the totp secret which is not part of the json
+	// of an account; sessions are not backed up
+	BackupAccount struct {
+		*auth.Account
+		TOTPSecret string `json:"totp_secret,omitempty"`
+	}
+)
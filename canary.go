@@ -0,0 +1,40 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// Statuses of a canary
+const (
+	CanaryBaking     = "baking"
+	CanaryPromoted   = "promoted"
+	CanaryRolledBack = "rolled-back"
+	// CanaryFailed is set when the promotion or the rollback could not
+	// recreate every container
+	CanaryFailed = "failed"
+)
+
+// Canary is a new image tried on part of the replicas of a stack service
+// for a bake period; it then replaces the image of the other replicas or
+// is rolled back when a canary fails its checks
+type Canary struct {
+	Stack         string `json:"stack"`
+	Service       string `json:"service"`
+	Image         string `json:"image"`
+	PreviousImage string `json:"previous_image"`
+	Percent       int    `json:"percent"`
+	Replicas      int    `json:"replicas"`
+	// Containers are the replicas running the new image
+	Containers []string `json:"containers"`
+	// Weight is the share of the replicas, and so of the traffic spread
+	// over them, on the new image
+	Weight        int        `json:"weight"`
+	MaxRestarts   int        `json:"max_restarts"`
+	MaxCPUPercent float64    `json:"max_cpu_percent,omitempty"`
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason,omitempty"`
+	StartedBy     string     `json:"started_by"`
+	StartedAt     time.Time  `json:"started_at"`
+	BakeUntil     time.Time  `json:"bake_until"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
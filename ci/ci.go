@@ -0,0 +1,163 @@
+// Package ci reads the push and pipeline webhooks of GitHub and GitLab so
+// CI builds can trigger image redeploys like Docker Hub pushes do
+package ci
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+
+	// headers of the event type and of the signature or token
+	GitHubEventHeader     = "X-GitHub-Event"
+	GitHubSignatureHeader = "X-Hub-Signature-256"
+	GitLabEventHeader     = "X-Gitlab-Event"
+	GitLabTokenHeader     = "X-Gitlab-Token"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown webhook provider")
+)
+
+// Event is a webhook delivery; only events with Trigger set redeploy
+type Event struct {
+	Provider string
+	// Kind is the event type sent by the provider (i.e. push)
+	Kind string
+	// Ref is the branch or tag of the push or pipeline without the
+	// refs/heads/ or refs/tags/ prefix
+	Ref     string
+	Trigger bool
+}
+
+// Verify checks the signature (GitHub) or the secret token (GitLab) sent
+// with the webhook body against the secret of the key
+func Verify(provider, secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	switch provider {
+	case ProviderGitHub:
+		if !strings.HasPrefix(signature, "sha256=") {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+		return hmac.Equal([]byte(expected), []byte(signature))
+	case ProviderGitLab:
+		// gitlab sends the secret token itself
+		return subtle.ConstantTimeCompare([]byte(secret), []byte(signature)) == 1
+	}
+
+	return false
+}
+
+// Parse reads the webhook body of the event type
+func Parse(provider, kind string, body []byte) (*Event, error) {
+	switch provider {
+	case ProviderGitHub:
+		return parseGitHub(kind, body)
+	case ProviderGitLab:
+		return parseGitLab(kind, body)
+	}
+
+	return nil, ErrUnknownProvider
+}
+
+// shortRef strips the prefix of branch and tag refs
+func shortRef(ref string) string {
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	return strings.TrimPrefix(ref, "refs/tags/")
+}
+
+// parseGitHub triggers on pushes, successful workflow runs and published
+// packages; other events (i.e. ping) are accepted without a trigger
+func parseGitHub(kind string, body []byte) (*Event, error) {
+	evt := &Event{Provider: ProviderGitHub, Kind: kind}
+
+	switch kind {
+	case "push":
+		var push struct {
+			Ref     string `json:"ref"`
+			Deleted bool   `json:"deleted"`
+		}
+		if err := json.Unmarshal(body, &push); err != nil {
+			return nil, err
+		}
+
+		evt.Ref = shortRef(push.Ref)
+		evt.Trigger = !push.Deleted
+	case "workflow_run":
+		var run struct {
+			Action      string `json:"action"`
+			WorkflowRun struct {
+				HeadBranch string `json:"head_branch"`
+				Conclusion string `json:"conclusion"`
+			} `json:"workflow_run"`
+		}
+		if err := json.Unmarshal(body, &run); err != nil {
+			return nil, err
+		}
+
+		evt.Ref = run.WorkflowRun.HeadBranch
+		evt.Trigger = run.Action == "completed" && run.WorkflowRun.Conclusion == "success"
+	case "package", "registry_package":
+		var pkg struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(body, &pkg); err != nil {
+			return nil, err
+		}
+
+		evt.Trigger = pkg.Action == "published"
+	}
+
+	return evt, nil
+}
+
+// parseGitLab triggers on pushes, tag pushes and successful pipelines
+func parseGitLab(kind string, body []byte) (*Event, error) {
+	evt := &Event{Provider: ProviderGitLab, Kind: kind}
+
+	switch kind {
+	case "Push Hook", "Tag Push Hook":
+		var push struct {
+			Ref   string `json:"ref"`
+			After string `json:"after"`
+		}
+		if err := json.Unmarshal(body, &push); err != nil {
+			return nil, err
+		}
+
+		evt.Ref = shortRef(push.Ref)
+		// removed branches and tags push an empty commit
+		evt.Trigger = strings.Trim(push.After, "0") != ""
+	case "Pipeline Hook":
+		var pipeline struct {
+			ObjectAttributes struct {
+				Ref    string `json:"ref"`
+				Status string `json:"status"`
+			} `json:"object_attributes"`
+		}
+		if err := json.Unmarshal(body, &pipeline); err != nil {
+			return nil, err
+		}
+
+		evt.Ref = pipeline.ObjectAttributes.Ref
+		evt.Trigger = pipeline.ObjectAttributes.Status == "success"
+	}
+
+	return evt, nil
+}
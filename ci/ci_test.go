@@ -0,0 +1,75 @@
+package ci
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/master"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		provider  string
+		secret    string
+		body      []byte
+		signature string
+		valid     bool
+	}{
+		{ProviderGitHub, "secret", body, signature, true},
+		{ProviderGitHub, "other", body, signature, false},
+		{ProviderGitHub, "secret", []byte(`{"ref":"refs/heads/dev"}`), signature, false},
+		{ProviderGitHub, "secret", body, signature[len("sha256="):], false},
+		{ProviderGitHub, "", body, "", false},
+		{ProviderGitLab, "secret", body, "secret", true},
+		{ProviderGitLab, "secret", body, "guess", false},
+		{ProviderGitLab, "", body, "", false},
+		{"bitbucket", "secret", body, "secret", false},
+	}
+
+	for i, test := range tests {
+		if valid := Verify(test.provider, test.secret, test.body, test.signature); valid != test.valid {
+			t.Errorf("test %d: expected valid=%v; received %v", i, test.valid, valid)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		provider string
+		kind     string
+		body     string
+		ref      string
+		trigger  bool
+	}{
+		{ProviderGitHub, "push", `{"ref":"refs/heads/master"}`, "master", true},
+		{ProviderGitHub, "push", `{"ref":"refs/heads/old","deleted":true}`, "old", false},
+		{ProviderGitHub, "workflow_run", `{"action":"completed","workflow_run":{"head_branch":"master","conclusion":"success"}}`, "master", true},
+		{ProviderGitHub, "workflow_run", `{"action":"completed","workflow_run":{"head_branch":"master","conclusion":"failure"}}`, "master", false},
+		{ProviderGitHub, "registry_package", `{"action":"published"}`, "", true},
+		{ProviderGitHub, "ping", `{"zen":"Keep it simple."}`, "", false},
+		{ProviderGitLab, "Push Hook", `{"ref":"refs/heads/master","after":"da1560886d4f094c3e6c9ef40349f7d38b5d27d7"}`, "master", true},
+		{ProviderGitLab, "Tag Push Hook", `{"ref":"refs/tags/v1.0","after":"0000000000000000000000000000000000000000"}`, "v1.0", false},
+		{ProviderGitLab, "Pipeline Hook", `{"object_attributes":{"ref":"master","status":"success"}}`, "master", true},
+		{ProviderGitLab, "Pipeline Hook", `{"object_attributes":{"ref":"master","status":"running"}}`, "master", false},
+	}
+
+	for _, test := range tests {
+		evt, err := Parse(test.provider, test.kind, []byte(test.body))
+		if err != nil {
+			t.Fatalf("%s %s: %s", test.provider, test.kind, err)
+		}
+
+		if evt.Ref != test.ref || evt.Trigger != test.trigger {
+			t.Errorf("%s %s %s: expected ref=%s trigger=%v; received ref=%s trigger=%v", test.provider, test.kind, test.body, test.ref, test.trigger, evt.Ref, evt.Trigger)
+		}
+	}
+
+	if _, err := Parse("bitbucket", "push", nil); err != ErrUnknownProvider {
+		t.Fatalf("expected %s; received %v", ErrUnknownProvider, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package clair submits image layers to a Clair (v1 api) scanner and reads
+// the vulnerabilities it found in them
+package clair
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTimeout is how long a request to clair may take; clair
+	// downloads and extracts a layer before answering its submission
+	defaultTimeout = 5 * time.Minute
+)
+
+// Layer is a layer as submitted to and returned by clair
+type Layer struct {
+	Name       string            `json:"Name"`
+	Path       string            `json:"Path,omitempty"`
+	ParentName string            `json:"ParentName,omitempty"`
+	Format     string            `json:"Format,omitempty"`
+	Headers    map[string]string `json:"Headers,omitempty"`
+	Features   []*Feature        `json:"Features,omitempty"`
+}
+
+// Feature is a package clair found in a layer or its parents
+type Feature struct {
+	Name            string           `json:"Name"`
+	NamespaceName   string           `json:"NamespaceName"`
+	Version         string           `json:"Version"`
+	Vulnerabilities []*Vulnerability `json:"Vulnerabilities"`
+}
+
+// Vulnerability is a vulnerability of a feature
+type Vulnerability struct {
+	Name          string `json:"Name"`
+	NamespaceName string `json:"NamespaceName"`
+	Link          string `json:"Link"`
+	Severity      string `json:"Severity"`
+	FixedBy       string `json:"FixedBy"`
+}
+
+type layerEnvelope struct {
+	Layer *Layer `json:"Layer,omitempty"`
+	Error *struct {
+		Message string `json:"Message"`
+	} `json:"Error,omitempty"`
+}
+
+// Error is an error returned by clair
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("clair: %d %s", e.StatusCode, e.Message)
+}
+
+// Client talks to the api of a clair instance
+type Client struct {
+	URL        *url.URL
+	httpClient *http.Client
+}
+
+func NewClient(clairURL string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(clairURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		URL:        u,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+func (c *Client) do(method, path string, in interface{}) (*Layer, error) {
+	var body []byte
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+
+	req, err := http.NewRequest(method, c.URL.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope layerEnvelope
+	json.Unmarshal(data, &envelope)
+
+	if resp.StatusCode >= 400 {
+		msg := strings.TrimSpace(string(data))
+		if envelope.Error != nil {
+			msg = envelope.Error.Message
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	return envelope.Layer, nil
+}
+
+// AnalyzeLayer submits a layer clair downloads from its path with the
+// headers; the parent has to be submitted first
+func (c *Client) AnalyzeLayer(layer *Layer) error {
+	if layer.Format == "" {
+		layer.Format = "Docker"
+	}
+
+	_, err := c.do("POST", "/v1/layers", &layerEnvelope{Layer: layer})
+	return err
+}
+
+// Vulnerabilities returns the features of the layer and its parents with
+// their vulnerabilities
+func (c *Client) Vulnerabilities(name string) ([]*Feature, error) {
+	layer, err := c.do("GET", "/v1/layers/"+url.PathEscape(name)+"?features&vulnerabilities", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if layer == nil {
+		return []*Feature{}, nil
+	}
+
+	return layer.Features, nil
+}
@@ -0,0 +1,75 @@
+package clair
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnalyzeLayer(t *testing.T) {
+	var received *Layer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope layerEnvelope
+		json.NewDecoder(r.Body).Decode(&envelope)
+		received = envelope.Layer
+
+		if received.Path == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Error":{"Message":"could not find layer"}}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&layerEnvelope{Layer: received})
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.AnalyzeLayer(&Layer{Name: "app", ParentName: "base", Path: "http://registry/v2/web/blobs/sha256:app"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Name != "app" || received.ParentName != "base" || received.Format != "Docker" {
+		t.Fatalf("unexpected layer %+v", received)
+	}
+
+	err = c.AnalyzeLayer(&Layer{Name: "app"})
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusBadRequest || e.Message != "could not find layer" {
+		t.Fatalf("expected the error of clair; received %v", err)
+	}
+}
+
+func TestVulnerabilities(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/layers/app" || r.URL.RawQuery != "features&vulnerabilities" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(`{"Layer":{"Name":"app","Features":[{"Name":"openssl","Version":"1.0.1","Vulnerabilities":[{"Name":"CVE-2016-2107","Severity":"High","FixedBy":"1.0.2"}]}]}}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	features, err := c.Vulnerabilities("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(features) != 1 || features[0].Vulnerabilities[0].Severity != "High" || features[0].Vulnerabilities[0].FixedBy != "1.0.2" {
+		t.Fatalf("unexpected features %+v", features)
+	}
+
+	if _, err := c.Vulnerabilities("missing"); err == nil {
+		t.Fatal("expected an error for an unknown layer")
+	}
+}
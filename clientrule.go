@@ -0,0 +1,20 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// ClientRule refuses clients older than the minimum version on the routes
+// under the prefix; clients are told apart by the product of their user
+// agent (i.e. shipyard-cli/3.1.0) and clients without it are let through
+type ClientRule struct {
+	ID         string `json:"id,omitempty" gorethink:"id,omitempty"`
+	Client     string `json:"client" gorethink:"client"`
+	MinVersion string `json:"min_version" gorethink:"min_version"`
+	// RoutePrefix is the group of routes (i.e. /api/stacks or /containers);
+	// / covers every route
+	RoutePrefix  string    `json:"route_prefix" gorethink:"route_prefix"`
+	Instructions string    `json:"instructions,omitempty" gorethink:"instructions,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty" gorethink:"created_by"`
+	CreatedAt    time.Time `json:"created_at,omitempty" gorethink:"created_at"`
+}
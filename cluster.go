@@ -0,0 +1,41 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// DefaultClusterName is the cluster the controller was started with; it
+// is configured with the settings of the controller rather than stored
+const DefaultClusterName = "default"
+
+// ClusterEndpointID is the id of the endpoint setting of the default
+// cluster
+const ClusterEndpointID = "cluster-endpoint"
+
+// ClusterEndpoint is the endpoint of the default cluster set at runtime;
+// it replaces the endpoint the controllers were started with
+type ClusterEndpoint struct {
+	ID            string    `json:"-" gorethink:"id"`
+	DockerURL     string    `json:"docker_url" gorethink:"docker_url"`
+	TLSCACert     string    `json:"tls_ca_cert,omitempty" gorethink:"tls_ca_cert,omitempty"`
+	TLSCert       string    `json:"tls_cert,omitempty" gorethink:"tls_cert,omitempty"`
+	TLSKey        string    `json:"tls_key,omitempty" gorethink:"tls_key,omitempty"`
+	AllowInsecure bool      `json:"allow_insecure,omitempty" gorethink:"allow_insecure,omitempty"`
+	UpdatedBy     string    `json:"updated_by,omitempty" gorethink:"updated_by,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty" gorethink:"updated_at,omitempty"`
+}
+
+// Cluster is a swarm (or docker) endpoint managed by the controller
+// besides the one it was started with; tls material is pem encoded
+type Cluster struct {
+	Name          string    `json:"name" gorethink:"id"`
+	DockerURL     string    `json:"docker_url" gorethink:"docker_url"`
+	TLSCACert     string    `json:"tls_ca_cert,omitempty" gorethink:"tls_ca_cert,omitempty"`
+	TLSCert       string    `json:"tls_cert,omitempty" gorethink:"tls_cert,omitempty"`
+	TLSKey        string    `json:"tls_key,omitempty" gorethink:"tls_key,omitempty"`
+	AllowInsecure bool      `json:"allow_insecure,omitempty" gorethink:"allow_insecure,omitempty"`
+	CreatedBy     string    `json:"created_by,omitempty" gorethink:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitempty" gorethink:"created_at,omitempty"`
+	UpdatedBy     string    `json:"updated_by,omitempty" gorethink:"updated_by,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty" gorethink:"updated_at,omitempty"`
+}
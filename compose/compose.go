@@ -0,0 +1,372 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Labels set on containers created for a stack; they match the labels of
+// docker-compose so stacks can be managed with either
+const (
+	LabelProject         = "com.docker.compose.project"
+	LabelService         = "com.docker.compose.service"
+	LabelContainerNumber = "com.docker.compose.container-number"
+)
+
+var (
+	ErrNoServices        = errors.New("the compose file has no services")
+	ErrDependencyCycle   = errors.New("the services depend on each other in a cycle")
+	ErrInvalidName       = errors.New("names can only contain lowercase letters, digits, dashes and underscores")
+	ErrImageNeeded       = errors.New("every service needs an image; builds are not supported")
+	ErrUnknownService    = errors.New("a service depends on an unknown service")
+	ErrInvalidPort       = errors.New("invalid port")
+	ErrInvalidReplicas   = errors.New("replicas cannot be negative")
+	ErrUnsupportedField  = errors.New("unsupported field")
+	ErrUnterminatedQuote = errors.New("unterminated quote in command")
+	ErrNoSuchService     = errors.New("the compose file has no such service")
+	ErrInvalidSmokeTest  = errors.New("a smoke test needs either a url or an image")
+
+	validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+)
+
+type (
+	// File is the subset of a docker-compose file (version 2 or 3) that
+	// can be deployed to swarm
+	File struct {
+		Version  string              `yaml:"version"`
+		Services map[string]*Service `yaml:"services"`
+		// SmokeTests run after each deploy of the stack; the extension
+		// field is ignored by docker-compose
+		SmokeTests []*SmokeTest `yaml:"x-smoke-tests"`
+	}
+
+	Service struct {
+		Image         string     `yaml:"image"`
+		ContainerName string     `yaml:"container_name"`
+		Command       StringList `yaml:"command"`
+		Entrypoint    StringList `yaml:"entrypoint"`
+		Environment   StringMap  `yaml:"environment"`
+		Labels        StringMap  `yaml:"labels"`
+		Ports         []string   `yaml:"ports"`
+		Expose        []string   `yaml:"expose"`
+		Volumes       []string   `yaml:"volumes"`
+		Links         []string   `yaml:"links"`
+		DependsOn     StringList `yaml:"depends_on"`
+		Hostname      string     `yaml:"hostname"`
+		User          string     `yaml:"user"`
+		WorkingDir    string     `yaml:"working_dir"`
+		Restart       string     `yaml:"restart"`
+		Privileged    bool       `yaml:"privileged"`
+		NetworkMode   string     `yaml:"network_mode"`
+		Scale         int        `yaml:"scale"`
+		Deploy        *Deploy    `yaml:"deploy"`
+	}
+
+	Deploy struct {
+		Replicas *int `yaml:"replicas"`
+	}
+
+	// StringList is a list which can also be written as a single string
+	// which is split like a shell would (i.e. command)
+	StringList []string
+
+	// StringMap is a map which can also be written as a list of key=value
+	// (i.e. environment)
+	StringMap map[string]string
+)
+
+func (l *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		words, err := splitWords(s)
+		if err != nil {
+			return err
+		}
+		*l = words
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*l = list
+		return nil
+	}
+
+	// depends_on can also be a map of service to condition
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+
+	*l = []string{}
+	for k := range m {
+		*l = append(*l, k)
+	}
+	sort.Strings(*l)
+
+	return nil
+}
+
+// splitWords splits a command on spaces outside of quotes
+func splitWords(s string) ([]string, error) {
+	words := []string{}
+	word := []rune{}
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			word = append(word, c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word = append(word, c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, string(word))
+				word = word[:0]
+				inWord = false
+			}
+		default:
+			word = append(word, c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, ErrUnterminatedQuote
+	}
+
+	if inWord {
+		words = append(words, string(word))
+	}
+
+	return words, nil
+}
+
+func (m *StringMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*m = StringMap{}
+		for _, kv := range list {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				(*m)[parts[0]] = parts[1]
+			} else {
+				(*m)[parts[0]] = ""
+			}
+		}
+		return nil
+	}
+
+	var values map[string]interface{}
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+
+	*m = StringMap{}
+	for k, v := range values {
+		if v == nil {
+			(*m)[k] = ""
+			continue
+		}
+		(*m)[k] = fmt.Sprint(v)
+	}
+
+	return nil
+}
+
+// ValidName reports whether the name can be used for a stack or service
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// Parse reads and validates a compose file
+func Parse(data []byte) (*File, error) {
+	var f *File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	if f == nil || len(f.Services) == 0 {
+		return nil, ErrNoServices
+	}
+
+	for name, svc := range f.Services {
+		if svc == nil {
+			return nil, fmt.Errorf("%s: %s", name, ErrImageNeeded)
+		}
+
+		if !ValidName(name) {
+			return nil, fmt.Errorf("%s: %s", name, ErrInvalidName)
+		}
+
+		if svc.Image == "" {
+			return nil, fmt.Errorf("%s: %s", name, ErrImageNeeded)
+		}
+
+		if svc.ContainerName != "" {
+			// fixed names cannot be scaled nor told apart between stacks
+			return nil, fmt.Errorf("%s: %s: container_name", name, ErrUnsupportedField)
+		}
+
+		if svc.Scale < 0 || (svc.Deploy != nil && svc.Deploy.Replicas != nil && *svc.Deploy.Replicas < 0) {
+			return nil, fmt.Errorf("%s: %s", name, ErrInvalidReplicas)
+		}
+
+		for _, dep := range svc.dependencies() {
+			if _, ok := f.Services[dep]; !ok {
+				return nil, fmt.Errorf("%s: %s: %s", name, ErrUnknownService, dep)
+			}
+		}
+
+		for _, port := range svc.Ports {
+			if _, _, _, err := parsePort(port); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+		}
+	}
+
+	for i, test := range f.SmokeTests {
+		if err := test.validate(); err != nil {
+			return nil, fmt.Errorf("x-smoke-tests[%d]: %s", i, err)
+		}
+	}
+
+	if _, err := f.Order(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Replicas returns how many containers run the service
+func (s *Service) Replicas() int {
+	if s.Deploy != nil && s.Deploy.Replicas != nil {
+		return *s.Deploy.Replicas
+	}
+
+	if s.Scale > 0 {
+		return s.Scale
+	}
+
+	return 1
+}
+
+// SetImage returns the compose file with the image of the service replaced;
+// the order of the file is kept but comments are dropped
+func SetImage(data []byte, service, image string) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, top := range doc {
+		if top.Key != "services" {
+			continue
+		}
+
+		services, ok := top.Value.(yaml.MapSlice)
+		if !ok {
+			break
+		}
+
+		for _, svc := range services {
+			if svc.Key != service {
+				continue
+			}
+
+			fields, ok := svc.Value.(yaml.MapSlice)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s", service, ErrImageNeeded)
+			}
+
+			for i := range fields {
+				if fields[i].Key == "image" {
+					fields[i].Value = image
+					return yaml.Marshal(doc)
+				}
+			}
+
+			return nil, fmt.Errorf("%s: %s", service, ErrImageNeeded)
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %s", service, ErrNoSuchService)
+}
+
+// dependencies returns the services that have to be created first
+func (s *Service) dependencies() []string {
+	deps := append([]string{}, s.DependsOn...)
+	for _, link := range s.Links {
+		deps = append(deps, strings.SplitN(link, ":", 2)[0])
+	}
+
+	return deps
+}
+
+// Order returns the service names so that every service comes after its
+// dependencies; services without an order between them are sorted by name
+func (f *File) Order() ([]string, error) {
+	names := []string{}
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := []string{}
+	// 1 is being visited, 2 is done
+	state := map[string]int{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return ErrDependencyCycle
+		case 2:
+			return nil
+		}
+
+		svc, ok := f.Services[name]
+		if !ok || svc == nil {
+			return ErrUnknownService
+		}
+
+		state[name] = 1
+		deps := svc.dependencies()
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+
+		ordered = append(ordered, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,149 @@
+package compose
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCompose = `
+version: "3"
+services:
+  web:
+    image: nginx:1.11
+    command: nginx -g "daemon off;"
+    ports:
+      - "8080:80"
+      - "127.0.0.1:8443:443/tcp"
+    environment:
+      - MODE=production
+    labels:
+      com.shipyard.environment: prod
+    depends_on:
+      - app
+    restart: on-failure:3
+  app:
+    image: shop/app
+    links:
+      - db:database
+    environment:
+      WORKERS: 4
+    deploy:
+      replicas: 2
+  db:
+    image: postgres:9.6
+    volumes:
+      - /data/db:/var/lib/postgresql/data
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(testCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := f.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"db", "app", "web"}, order, "expected dependencies first")
+	assert.Equal(t, 2, f.Services["app"].Replicas())
+	assert.Equal(t, 1, f.Services["web"].Replicas())
+	assert.Equal(t, "4", f.Services["app"].Environment["WORKERS"])
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		compose string
+		err     error
+	}{
+		{"version: '3'\n", ErrNoServices},
+		{"services:\n  web:\n    build: .\n", ErrImageNeeded},
+		{"services:\n  Web:\n    image: nginx\n", ErrInvalidName},
+		{"services:\n  web:\n    image: nginx\n    depends_on: [db]\n", ErrUnknownService},
+		{"services:\n  web:\n    image: nginx\n    ports: ['80:http']\n", ErrInvalidPort},
+		{"services:\n  a:\n    image: a\n    depends_on: [b]\n  b:\n    image: b\n    links: [a]\n", ErrDependencyCycle},
+		{"services:\n  web:\n    image: nginx\nx-smoke-tests:\n  - name: empty\n", ErrInvalidSmokeTest},
+		{"services:\n  web:\n    image: nginx\nx-smoke-tests:\n  - url: http://web\n    image: curl\n", ErrInvalidSmokeTest},
+		{"services:\n  web:\n    image: nginx\nx-smoke-tests:\n  - url: http://web\n    timeout: soon\n", errors.New("invalid timeout")},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.compose))
+		if err == nil || !strings.Contains(err.Error(), test.err.Error()) {
+			t.Errorf("expected %q for %q; received %v", test.err, test.compose, err)
+		}
+	}
+}
+
+func TestParseSmokeTests(t *testing.T) {
+	f, err := Parse([]byte(testCompose + `
+x-smoke-tests:
+  - url: http://shop.local/health
+    status: 204
+  - name: checkout
+    image: shop/e2e
+    command: run --suite checkout
+    network_mode: container:shop_web_1
+    timeout: 2m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, f.SmokeTests, 2)
+	assert.Equal(t, "http://shop.local/health", f.SmokeTests[0].Name, "expected the url to name the test")
+	assert.Equal(t, 204, f.SmokeTests[0].Status)
+	assert.Equal(t, DefaultSmokeTestTimeout, f.SmokeTests[0].Deadline())
+	assert.Equal(t, StringList{"run", "--suite", "checkout"}, f.SmokeTests[1].Command)
+	assert.Equal(t, 2*time.Minute, f.SmokeTests[1].Deadline())
+}
+
+func TestContainerConfig(t *testing.T) {
+	f, err := Parse([]byte(testCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := f.ContainerConfig("shop", "web", 1)
+
+	assert.Equal(t, "nginx:1.11", config.Image)
+	assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, config.Cmd, "expected a string command to be split like a shell")
+	assert.Equal(t, []string{"MODE=production"}, config.Env)
+	assert.Equal(t, "shop", config.Labels[LabelProject])
+	assert.Equal(t, "web", config.Labels[LabelService])
+	assert.Equal(t, "prod", config.Labels["com.shipyard.environment"])
+	assert.Equal(t, []dockerclient.PortBinding{{HostIp: "127.0.0.1", HostPort: "8443"}}, config.HostConfig.PortBindings["443/tcp"])
+	assert.Equal(t, []string{"shop_app_1:app"}, config.HostConfig.Links)
+	assert.Equal(t, dockerclient.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}, config.HostConfig.RestartPolicy)
+
+	config = f.ContainerConfig("shop", "app", 2)
+	assert.Equal(t, []string{"shop_db_1:database"}, config.HostConfig.Links)
+	assert.Equal(t, "2", config.Labels[LabelContainerNumber])
+}
+
+func TestSetImage(t *testing.T) {
+	data, err := SetImage([]byte(testCompose), "web", "nginx:1.12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "nginx:1.12", f.Services["web"].Image)
+	assert.Equal(t, "shop/app", f.Services["app"].Image, "expected other services to be kept")
+	assert.Equal(t, 2, f.Services["app"].Replicas())
+	assert.True(t, strings.Index(string(data), "web:") < strings.Index(string(data), "app:"), "expected the order of the file to be kept")
+
+	if _, err := SetImage([]byte(testCompose), "cache", "redis"); err == nil || !strings.Contains(err.Error(), ErrNoSuchService.Error()) {
+		t.Fatalf("expected %s; received %v", ErrNoSuchService, err)
+	}
+}
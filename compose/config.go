@@ -0,0 +1,145 @@
+package compose
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+// ContainerName returns the name of a container of a service like
+// docker-compose does
+func ContainerName(project, service string, number int) string {
+	return fmt.Sprintf("%s_%s_%d", project, service, number)
+}
+
+// parsePort splits a port mapping like [ip:][host:]container[/proto]
+func parsePort(mapping string) (hostIP, hostPort, containerPort string, err error) {
+	spec := mapping
+	proto := "tcp"
+	if i := strings.LastIndex(spec, "/"); i > -1 {
+		spec, proto = spec[:i], spec[i+1:]
+	}
+
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		containerPort = parts[0]
+	case 2:
+		hostPort, containerPort = parts[0], parts[1]
+	case 3:
+		hostIP, hostPort, containerPort = parts[0], parts[1], parts[2]
+		if net.ParseIP(hostIP) == nil {
+			return "", "", "", fmt.Errorf("%s: %s", ErrInvalidPort, mapping)
+		}
+	default:
+		return "", "", "", fmt.Errorf("%s: %s", ErrInvalidPort, mapping)
+	}
+
+	if proto != "tcp" && proto != "udp" {
+		return "", "", "", fmt.Errorf("%s: %s", ErrInvalidPort, mapping)
+	}
+
+	if containerPort == "" {
+		return "", "", "", fmt.Errorf("%s: %s", ErrInvalidPort, mapping)
+	}
+
+	for _, p := range []string{hostPort, containerPort} {
+		if p == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return "", "", "", fmt.Errorf("%s: %s", ErrInvalidPort, mapping)
+		}
+	}
+
+	return hostIP, hostPort, containerPort + "/" + proto, nil
+}
+
+// ContainerConfig returns the config of a container of the service in the
+// stack of the file; dependencies are linked by their service name
+func (f *File) ContainerConfig(project, service string, number int) *dockerclient.ContainerConfig {
+	svc := f.Services[service]
+
+	env := []string{}
+	for k, v := range svc.Environment {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+
+	labels := map[string]string{}
+	for k, v := range svc.Labels {
+		labels[k] = v
+	}
+	labels[LabelProject] = project
+	labels[LabelService] = service
+	labels[LabelContainerNumber] = strconv.Itoa(number)
+
+	exposed := map[string]struct{}{}
+	bindings := map[string][]dockerclient.PortBinding{}
+	for _, port := range svc.Ports {
+		// ports are validated when the file is parsed
+		hostIP, hostPort, containerPort, _ := parsePort(port)
+		exposed[containerPort] = struct{}{}
+		bindings[containerPort] = append(bindings[containerPort], dockerclient.PortBinding{
+			HostIp:   hostIP,
+			HostPort: hostPort,
+		})
+	}
+	for _, port := range svc.Expose {
+		if !strings.Contains(port, "/") {
+			port = port + "/tcp"
+		}
+		exposed[port] = struct{}{}
+	}
+
+	links := []string{}
+	linked := map[string]bool{}
+	for _, link := range svc.Links {
+		parts := strings.SplitN(link, ":", 2)
+		alias := parts[0]
+		if len(parts) == 2 {
+			alias = parts[1]
+		}
+		links = append(links, ContainerName(project, parts[0], 1)+":"+alias)
+		linked[parts[0]] = true
+	}
+	for _, dep := range svc.DependsOn {
+		if !linked[dep] && f.Services[dep].Replicas() > 0 {
+			links = append(links, ContainerName(project, dep, 1)+":"+dep)
+		}
+	}
+
+	hostConfig := dockerclient.HostConfig{
+		Binds:        svc.Volumes,
+		PortBindings: bindings,
+		Links:        links,
+		Privileged:   svc.Privileged,
+		NetworkMode:  svc.NetworkMode,
+	}
+
+	switch {
+	case svc.Restart == "":
+	case strings.HasPrefix(svc.Restart, "on-failure:"):
+		retries, _ := strconv.ParseInt(strings.TrimPrefix(svc.Restart, "on-failure:"), 10, 64)
+		hostConfig.RestartPolicy = dockerclient.RestartPolicy{Name: "on-failure", MaximumRetryCount: retries}
+	default:
+		hostConfig.RestartPolicy = dockerclient.RestartPolicy{Name: svc.Restart}
+	}
+
+	return &dockerclient.ContainerConfig{
+		Image:        svc.Image,
+		Cmd:          svc.Command,
+		Entrypoint:   svc.Entrypoint,
+		Env:          env,
+		Labels:       labels,
+		Hostname:     svc.Hostname,
+		User:         svc.User,
+		WorkingDir:   svc.WorkingDir,
+		ExposedPorts: exposed,
+		HostConfig:   hostConfig,
+	}
+}
@@ -0,0 +1,58 @@
+package compose
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultSmokeTestTimeout is how long a smoke test has to pass
+	DefaultSmokeTestTimeout = 30 * time.Second
+)
+
+// SmokeTest checks a stack after it is deployed; it either requests the
+// url until it answers with the status or runs a container from the image
+// which has to exit with 0
+type SmokeTest struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Status is the expected status of the url; any 2xx when unset
+	Status  int        `yaml:"status"`
+	Image   string     `yaml:"image"`
+	Command StringList `yaml:"command"`
+	// NetworkMode lets the container reach the stack (i.e.
+	// container:shop_web_1)
+	NetworkMode string `yaml:"network_mode"`
+	Timeout     string `yaml:"timeout"`
+}
+
+func (t *SmokeTest) validate() error {
+	if t == nil || (t.URL == "") == (t.Image == "") {
+		return ErrInvalidSmokeTest
+	}
+
+	if t.Timeout != "" {
+		d, err := time.ParseDuration(t.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", t.Timeout)
+		}
+	}
+
+	if t.Name == "" {
+		t.Name = t.URL
+		if t.Image != "" {
+			t.Name = t.Image
+		}
+	}
+
+	return nil
+}
+
+// Deadline returns how long the test has to pass
+func (t *SmokeTest) Deadline() time.Duration {
+	if d, err := time.ParseDuration(t.Timeout); err == nil && d > 0 {
+		return d
+	}
+
+	return DefaultSmokeTestTimeout
+}
@@ -4,4 +4,9 @@ type ConsoleSession struct {
 	ID          string `json:"id,omitempty" gorethink:"id,omitempty"`
 	ContainerID string `json:"container_id,omitempty" gorethink:"container_id,omitempty"`
 	Token       string `json:"token,omitempty" gorethink:"token,omitempty"`
+	Username    string `json:"username,omitempty" gorethink:"username,omitempty"`
+	// ServiceKeyID identifies the service key the session was created
+	// with (see auth.KeyID); sessions without a user or key were created
+	// from a whitelisted address
+	ServiceKeyID string `json:"service_key_id,omitempty" gorethink:"service_key_id,omitempty"`
 }
@@ -0,0 +1,35 @@
+package shipyard
+
+import (
+	"time"
+)
+
+const (
+	// ContainerDefaultsID is the id of the container defaults setting
+	ContainerDefaultsID = "container-defaults"
+
+	// LabelDefaultsExclude on a service lists the env vars and labels of
+	// the container defaults it does not get, comma separated; * excludes
+	// them all
+	LabelDefaultsExclude = "com.shipyard.defaults.exclude"
+)
+
+// ContainerDefaults are env vars and labels (i.e. proxy settings or log
+// tags) added to every container of the stacks and templates deployed
+// through Shipyard; the env vars and labels of a service take precedence
+type ContainerDefaults struct {
+	ID     string            `json:"-" gorethink:"id"`
+	Env    map[string]string `json:"env,omitempty" gorethink:"env,omitempty"`
+	Labels map[string]string `json:"labels,omitempty" gorethink:"labels,omitempty"`
+	// Environments override the defaults for the services with the
+	// environment label (com.shipyard.environment)
+	Environments map[string]*ContainerDefaultsOverride `json:"environments,omitempty" gorethink:"environments,omitempty"`
+	UpdatedBy    string                                `json:"updated_by,omitempty" gorethink:"updated_by,omitempty"`
+	UpdatedAt    time.Time                             `json:"updated_at,omitempty" gorethink:"updated_at,omitempty"`
+}
+
+// ContainerDefaultsOverride replaces or adds defaults for an environment
+type ContainerDefaultsOverride struct {
+	Env    map[string]string `json:"env,omitempty" gorethink:"env,omitempty"`
+	Labels map[string]string `json:"labels,omitempty" gorethink:"labels,omitempty"`
+}
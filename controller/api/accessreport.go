@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (a *Api) accessReport(w http.ResponseWriter, r *http.Request) {
+	report, err := a.manager.AccessReport()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("format") == "csv" {
+		w.Header().Set("content-type", "text/csv")
+		w.Header().Set("content-disposition", "attachment; filename=access-report.csv")
+
+		cw := csv.NewWriter(w)
+		header := append([]string{"username", "roles"}, report.Permissions...)
+		if err := cw.Write(header); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, acct := range report.Accounts {
+			row := []string{acct.Username, strings.Join(acct.Roles, " ")}
+			for _, p := range report.Permissions {
+				row = append(row, strconv.FormatBool(acct.Permissions[p]))
+			}
+
+			if err := cw.Write(row); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
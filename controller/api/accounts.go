@@ -2,17 +2,42 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/controller/manager"
 )
 
+// accounts returns accounts sorted by username (order=desc reverses it);
+// they can be filtered by role and username prefix and are paged with
+// offset and limit
 func (a *Api) accounts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
-	accounts, err := a.manager.Accounts()
+	query := &datastore.AccountQuery{
+		Role:   r.FormValue("role"),
+		Prefix: r.FormValue("prefix"),
+	}
+
+	var err error
+	if query.Offset, query.Limit, err = pageParams(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sort, err := sortParams(r, manager.AccountSortFields, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	manager.SortAccountQuery(query, sort)
+
+	accounts, err := a.manager.Accounts(query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -32,6 +57,10 @@ func (a *Api) saveAccount(w http.ResponseWriter, r *http.Request) {
 
 	if err := a.manager.SaveAccount(account); err != nil {
 		log.Errorf("error saving account: %s", err)
+		if err == auth.ErrInvalidLabelScope || err == manager.ErrInvalidEmail {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -75,3 +104,130 @@ func (a *Api) deleteAccount(w http.ResponseWriter, r *http.Request) {
 	log.Infof("deleted account: username=%s id=%s", account.Username, account.ID)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (a *Api) exportAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	export, err := a.manager.ExportAccount(username)
+	if err != nil {
+		if err == manager.ErrAccountDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("error exporting account: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%s.json", username))
+
+	if err := json.NewEncoder(w).Encode(export); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) anonymizeAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	pseudonym, err := a.manager.AnonymizeAccount(username)
+	if err != nil {
+		log.Errorf("error anonymizing account: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("anonymized account: pseudonym=%s", pseudonym)
+
+	if err := json.NewEncoder(w).Encode(map[string]string{"username": pseudonym}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// unlockAccount lifts the lockout of an account after too many failed
+// logins
+func (a *Api) unlockAccount(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	if err := a.manager.UnlockAccount(username); err != nil {
+		if err == manager.ErrAccountDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("error unlocking account: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("unlocked account: username=%s", username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// accountImport is an account with an existing password hash
+type accountImport struct {
+	Username     string   `json:"username"`
+	FirstName    string   `json:"first_name,omitempty"`
+	LastName     string   `json:"last_name,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	PasswordHash string   `json:"password_hash"`
+	Roles        []string `json:"roles,omitempty"`
+}
+
+// importAccounts creates accounts from existing password hashes; the body
+// is either an htpasswd file (text/plain; roles are set with ?role=) or a
+// json list of accounts with a password_hash
+func (a *Api) importAccounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	accounts := []*auth.Account{}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		hashes, err := auth.ParseHtpasswd(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for username, hash := range hashes {
+			accounts = append(accounts, &auth.Account{
+				Username: username,
+				Password: hash,
+				Roles:    r.URL.Query()["role"],
+			})
+		}
+	} else {
+		imports := []*accountImport{}
+		if err := json.NewDecoder(r.Body).Decode(&imports); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for _, i := range imports {
+			accounts = append(accounts, &auth.Account{
+				Username:  i.Username,
+				FirstName: i.FirstName,
+				LastName:  i.LastName,
+				Email:     i.Email,
+				Password:  i.PasswordHash,
+				Roles:     i.Roles,
+			})
+		}
+	}
+
+	result := a.manager.ImportAccounts(accounts)
+	log.Infof("imported accounts: imported=%d skipped=%d errors=%d", len(result.Imported), len(result.Skipped), len(result.Errors))
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
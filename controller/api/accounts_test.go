@@ -8,7 +8,10 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
 	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
 	"github.com/shipyard/shipyard/controller/mock_test"
 	"github.com/stretchr/testify/assert"
 )
@@ -40,6 +43,48 @@ func TestApiGetAccounts(t *testing.T) {
 	assert.Equal(t, acct.ID, mock_test.TestAccount.ID, fmt.Sprintf("expected ID %s; got %s", mock_test.TestAccount.ID, acct.ID))
 }
 
+func TestApiGetAccountsFiltered(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.accounts))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?prefix=test&limit=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	accts := []*auth.Account{}
+	if err := json.NewDecoder(res.Body).Decode(&accts); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(accts), 1, "expected the account with the prefix")
+
+	res, err = http.Get(ts.URL + "?role=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accts = []*auth.Account{}
+	if err := json.NewDecoder(res.Body).Decode(&accts); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(accts), 0, "expected no accounts with the role")
+
+	res, err = http.Get(ts.URL + "?offset=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 400, "expected response code 400")
+}
+
 func TestApiPostAccounts(t *testing.T) {
 	api, err := getTestApi()
 	if err != nil {
@@ -85,3 +130,104 @@ func TestApiDeleteAccount(t *testing.T) {
 
 	assert.Equal(t, res.StatusCode, 204, "expected response code 204")
 }
+
+func TestApiExportAccount(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/accounts/{username}/export", api.exportAccount).Methods("GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/accounts/" + mock_test.TestAccount.Username + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	export := &shipyard.AccountExport{}
+	if err := json.NewDecoder(res.Body).Decode(&export); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, len(export.Events), 0, "expected events; received none")
+
+	res, err = http.Get(ts.URL + "/api/accounts/missing/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 404, "expected response code 404")
+}
+
+func TestApiImportAccounts(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.importAccounts))
+	defer ts.Close()
+
+	data := []byte("testuser:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\nalice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+
+	res, err := http.Post(ts.URL+"?role=user", "text/plain", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	result := manager.AccountImportResult{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"alice"}, result.Imported)
+	assert.Equal(t, []string{"testuser"}, result.Skipped)
+
+	res, err = http.Post(ts.URL, "text/plain", bytes.NewBufferString("alice:plaintext\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 400, res.StatusCode, "expected response code 400")
+}
+
+func TestApiUnlockAccount(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.unlockAccount))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 204, "expected response code 204")
+}
+
+func TestApiLoginLocked(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.login))
+	defer ts.Close()
+
+	body := `{"username": "` + mock_test.TestLockedUsername + `", "password": "secret"}`
+	res, err := http.Post(ts.URL, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, http.StatusLocked, "expected the locked account to be refused")
+}
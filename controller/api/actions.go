@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// actions lists the actions the user may take on a resource
+// (i.e. ?type=container&id=web) in its current state
+func (a *Api) actions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	resource := r.FormValue("type")
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "the id of the resource is required", http.StatusBadRequest)
+		return
+	}
+
+	actions, err := a.manager.Actions(getUsername(r), resource, id)
+	if err != nil {
+		switch err {
+		case manager.ErrUnknownActionResource:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case dockerclient.ErrNotFound, manager.ErrNodeDoesNotExist, manager.ErrStackDoesNotExist:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(actions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
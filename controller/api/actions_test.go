@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiActions(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.actions))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?type=container&id=" + mock_test.TestContainerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	actions := []*manager.Action{}
+	if err := json.NewDecoder(res.Body).Decode(&actions); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(actions), "expected the actions of the container")
+	assert.Equal(t, "stop", actions[0].Name)
+
+	for query, code := range map[string]int{
+		"?type=container":           http.StatusBadRequest,
+		"?type=image&id=abc":        http.StatusBadRequest,
+		"?type=container&id=absent": http.StatusNotFound,
+	} {
+		res, err := http.Get(ts.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, code, res.StatusCode, query)
+	}
+}
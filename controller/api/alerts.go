@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeAlertError(w http.ResponseWriter, err error) {
+	if err == manager.ErrAlertDoesNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (a *Api) alerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	alerts, err := a.manager.Alerts(r.FormValue("status"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(alerts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) alert(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	alert, err := a.manager.Alert(id)
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(alert); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) acknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.AcknowledgeAlert(id, getUsername(r)); err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) assignAlert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var assignment struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&assignment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.AssignAlert(id, assignment.Username); err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) addAlertComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var comment *shipyard.AlertComment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if comment == nil || comment.Text == "" {
+		http.Error(w, "comment text is required", http.StatusBadRequest)
+		return
+	}
+
+	comment.Username = getUsername(r)
+
+	if err := a.manager.AddAlertComment(id, comment); err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiGetAlerts(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.alerts))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	alerts := []*shipyard.Alert{}
+
+	if err := json.NewDecoder(res.Body).Decode(&alerts); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, len(alerts), 0, "expected alerts; received none")
+}
+
+func TestApiAddAlertCommentWithoutText(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.addAlertComment))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL, "application/json", bytes.NewBufferString(`{"text": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 400, "expected response code 400")
+}
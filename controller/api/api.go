@@ -1,22 +1,24 @@
 package api
 
 import (
-	"fmt"
-	"io/ioutil"
 	"net/http"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/negroni"
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
-	"github.com/mailgun/oxy/forward"
-	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth/oidc"
+	"github.com/shipyard/shipyard/ci"
 	"github.com/shipyard/shipyard/controller/manager"
 	"github.com/shipyard/shipyard/controller/middleware/access"
+	"github.com/shipyard/shipyard/controller/metrics"
 	"github.com/shipyard/shipyard/controller/middleware/audit"
 	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
-	"github.com/shipyard/shipyard/tlsutils"
-	"golang.org/x/net/websocket"
+	"github.com/shipyard/shipyard/controller/middleware/requestlog"
+	"github.com/shipyard/shipyard/controller/preflight"
+	"github.com/shipyard/shipyard/utils/syslog"
 )
 
 type (
@@ -24,125 +26,326 @@ type (
 		listenAddr         string
 		manager            manager.Manager
 		authWhitelistCIDRs []string
-		enableCors         bool
+		corsOrigins        []string
 		serverVersion      string
 		allowInsecure      bool
 		tlsCACertPath      string
 		tlsCertPath        string
 		tlsKeyPath         string
-		dUrl               string
-		fwd                *forward.Forwarder
+		tlsClientCAPath    string
+		httpRedirectAddr   string
+		proxy              *swarmProxy
+		clusterProxies     *clusterProxies
+		auditSyslogAddr    string
+		execSessions       *execSessions
+		execRecord         bool
+		sessionLimits      sessionLimits
+		preflightChecks    func() *preflight.Report
+		cache              *responseCache
+		offline            bool
+		deprecations       *deprecationTracker
+		usage              *usageTracker
+		oidc               *oidc.Provider
+		admin              AdminBootstrap
 	}
 
 	ApiConfig struct {
 		ListenAddr         string
 		Manager            manager.Manager
 		AuthWhiteListCIDRs []string
-		EnableCORS         bool
-		AllowInsecure      bool
-		TLSCACertPath      string
-		TLSCertPath        string
-		TLSKeyPath         string
+		// CORSOrigins are the origins allowed to call the apis from a
+		// browser; * allows every origin and none disables cors
+		CORSOrigins     []string
+		AllowInsecure   bool
+		TLSCACertPath   string
+		TLSCertPath     string
+		TLSKeyPath      string
+		AuditSyslogAddr string
+		// TLSClientCAPath requires clients to present a certificate
+		// signed by the ca (mutual tls)
+		TLSClientCAPath string
+		// HTTPRedirectAddr is a plain http listener redirecting to the
+		// https listener
+		HTTPRedirectAddr string
+		// ExecMaxDuration and ExecIdleTimeout limit exec and attach
+		// connections; zero disables the limit
+		ExecMaxDuration time.Duration
+		ExecIdleTimeout time.Duration
+		// ExecRecord records the output of every exec session
+		ExecRecord bool
+		// Preflight runs the startup checks for /api/preflight
+		Preflight func() *preflight.Report
+		// ResponseCacheTTL is how long image search and registry
+		// queries are cached; zero disables the cache
+		ResponseCacheTTL time.Duration
+		// Offline disables features that need internet access
+		Offline bool
+		// APISunset is announced in the Sunset header of deprecated
+		// routes; zero omits the header
+		APISunset time.Time
+		// OIDC signs users in with an OpenID Connect provider; nil
+		// disables it
+		OIDC *oidc.Provider
+		// Admin is the account created when it does not exist
+		Admin AdminBootstrap
 	}
 
 	Credentials struct {
 		Username string `json:"username,omitempty"`
 		Password string `json:"password,omitempty"`
+		// Code is the two-factor code of accounts with it enabled
+		Code string `json:"code,omitempty"`
 	}
 )
 
-func writeCorsHeaders(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Access-Control-Allow-Origin", "*")
-	w.Header().Add("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept")
-	w.Header().Add("Access-Control-Allow-Methods", "GET, POST, DELETE, PUT, OPTIONS")
+// getUsername returns the account making the request once the auth
+// middleware verified its access token; requests with a service key or
+// from a whitelisted address have none. The username in X-Access-Token
+// is not verified for them, so it is never used to authorize a request.
+func getUsername(r *http.Request) string {
+	return mAuth.Username(r)
 }
 
 func NewApi(config ApiConfig) (*Api, error) {
+	if err := validateTLS(config); err != nil {
+		return nil, err
+	}
+
 	return &Api{
 		listenAddr:         config.ListenAddr,
 		manager:            config.Manager,
 		authWhitelistCIDRs: config.AuthWhiteListCIDRs,
-		enableCors:         config.EnableCORS,
+		corsOrigins:        config.CORSOrigins,
 		allowInsecure:      config.AllowInsecure,
 		tlsCertPath:        config.TLSCertPath,
 		tlsKeyPath:         config.TLSKeyPath,
 		tlsCACertPath:      config.TLSCACertPath,
+		tlsClientCAPath:    config.TLSClientCAPath,
+		httpRedirectAddr:   config.HTTPRedirectAddr,
+		auditSyslogAddr:    config.AuditSyslogAddr,
+		execSessions:       newExecSessions(),
+		clusterProxies:     newClusterProxies(),
+		execRecord:         config.ExecRecord,
+		sessionLimits: sessionLimits{
+			MaxDuration: config.ExecMaxDuration,
+			IdleTimeout: config.ExecIdleTimeout,
+		},
+		preflightChecks: config.Preflight,
+		cache:           newResponseCache(config.ResponseCacheTTL),
+		offline:         config.Offline,
+		deprecations:    newDeprecationTracker(config.APISunset),
+		usage:           newUsageTracker(),
+		oidc:            config.OIDC,
+		admin:           config.Admin,
 	}, nil
 }
 
 func (a *Api) Run() error {
 	globalMux := http.NewServeMux()
 	controllerManager := a.manager
-	client := a.manager.DockerClient()
-
-	// forwarder for swarm
-	var err error
-	a.fwd, err = forward.New()
+	// forwarder for swarm; replaced when the cluster endpoint changes
+	proxy, err := newSwarmProxy(a.manager.DockerClient())
 	if err != nil {
 		return err
 	}
-
-	u := client.URL
-
-	// setup redirect target to swarm
-	scheme := "http://"
-
-	// check if TLS is enabled and configure if so
-	if client.TLSConfig != nil {
-		log.Debug("configuring ssl for swarm redirect")
-		scheme = "https://"
-		// setup custom roundtripper with TLS transport
-		r := forward.RoundTripper(
-			&http.Transport{
-				TLSClientConfig: client.TLSConfig,
-			})
-		f, err := forward.New(r)
-		if err != nil {
-			return err
-		}
-
-		a.fwd = f
-	}
-
-	a.dUrl = fmt.Sprintf("%s%s", scheme, u.Host)
-
-	log.Debugf("configured docker proxy target: %s", a.dUrl)
+	a.proxy = proxy
 
 	swarmRedirect := http.HandlerFunc(a.swarmRedirect)
 
 	swarmHijack := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		a.swarmHijack(client.TLSConfig, a.dUrl, w, req)
+		proxy, err := a.clusterProxy(req)
+		if err != nil {
+			writeClusterError(w, err)
+			return
+		}
+		swarm, _, tlsConfig := proxy.target()
+		target, status, err := a.nodeTarget(req, swarm)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		req.Header.Del(clusterHeader)
+		if target == swarm {
+			proxy.negotiateVersion(req)
+		}
+		a.swarmHijack(tlsConfig, target, w, req)
 	})
 
 	apiRouter := mux.NewRouter()
 	apiRouter.HandleFunc("/api/accounts", a.accounts).Methods("GET")
 	apiRouter.HandleFunc("/api/accounts", a.saveAccount).Methods("POST")
+	apiRouter.HandleFunc("/api/accounts/import", a.importAccounts).Methods("POST")
 	apiRouter.HandleFunc("/api/accounts/{username}", a.account).Methods("GET")
 	apiRouter.HandleFunc("/api/accounts/{username}", a.deleteAccount).Methods("DELETE")
+	apiRouter.HandleFunc("/api/accounts/{username}/export", a.exportAccount).Methods("GET")
+	apiRouter.HandleFunc("/api/accounts/{username}/anonymize", a.anonymizeAccount).Methods("POST")
+	apiRouter.HandleFunc("/api/accounts/{username}/unlock", a.unlockAccount).Methods("POST")
+	apiRouter.HandleFunc("/api/accounts/{username}/reset", a.resetPassword).Methods("POST")
+	apiRouter.HandleFunc("/api/account/me", a.profile).Methods("GET")
+	apiRouter.HandleFunc("/api/account/me", a.updateProfile).Methods("PUT")
+	apiRouter.HandleFunc("/api/account/me/password", a.changeOwnPassword).Methods("PUT")
+	apiRouter.HandleFunc("/api/accounts/{username}/tokens", a.authTokens).Methods("GET")
+	apiRouter.HandleFunc("/api/accounts/{username}/tokens", a.revokeAuthTokens).Methods("DELETE")
+	apiRouter.HandleFunc("/api/accounts/{username}/tokens/{id}", a.revokeAuthToken).Methods("DELETE")
+	apiRouter.HandleFunc("/api/accounts/{username}/2fa", a.enrollTOTP).Methods("POST")
+	apiRouter.HandleFunc("/api/accounts/{username}/2fa", a.confirmTOTP).Methods("PUT")
+	apiRouter.HandleFunc("/api/accounts/{username}/2fa", a.disableTOTP).Methods("DELETE")
 	apiRouter.HandleFunc("/api/roles", a.roles).Methods("GET")
+	apiRouter.HandleFunc("/api/roles", a.saveRole).Methods("POST")
 	apiRouter.HandleFunc("/api/roles/{name}", a.role).Methods("GET")
+	apiRouter.HandleFunc("/api/roles/{name}", a.deleteRole).Methods("DELETE")
+	apiRouter.HandleFunc("/api/permissions", a.permissions).Methods("GET")
+	apiRouter.HandleFunc("/api/access-report", a.accessReport).Methods("GET")
+	apiRouter.HandleFunc("/api/actions", a.actions).Methods("GET")
 	apiRouter.HandleFunc("/api/nodes", a.nodes).Methods("GET")
+	apiRouter.HandleFunc("/api/nodes", a.addNode).Methods("POST")
+	apiRouter.HandleFunc("/api/nodes/inventory", a.collectInventory).Methods("POST")
+	// before /api/nodes/{name} so drift is not taken for a node name
+	apiRouter.HandleFunc("/api/nodes/drift", a.nodeDrift).Methods("GET")
 	apiRouter.HandleFunc("/api/nodes/{name}", a.node).Methods("GET")
+	apiRouter.HandleFunc("/api/nodes/{name}", a.removeNode).Methods("DELETE")
+	apiRouter.HandleFunc("/api/nodes/{name}/drain", a.drainNode).Methods("POST")
+	apiRouter.HandleFunc("/api/nodes/{name}/drain", a.undrainNode).Methods("DELETE")
+	apiRouter.HandleFunc("/api/debug/launch", a.launchDebugContainer).Methods("POST")
+	apiRouter.HandleFunc("/api/clusters", a.clusters).Methods("GET")
+	apiRouter.HandleFunc("/api/clusters", a.addCluster).Methods("POST")
+	apiRouter.HandleFunc("/api/clusters/{name}", a.cluster).Methods("GET")
+	apiRouter.HandleFunc("/api/clusters/{name}", a.replaceCluster).Methods("PUT")
+	apiRouter.HandleFunc("/api/clusters/{name}", a.removeCluster).Methods("DELETE")
+	apiRouter.HandleFunc("/api/volumes", a.volumes).Methods("GET")
+	apiRouter.HandleFunc("/api/volumes", a.createVolume).Methods("POST")
+	apiRouter.HandleFunc("/api/volumes/prune", a.pruneVolumes).Methods("POST")
+	apiRouter.HandleFunc("/api/volumes/{node}/{name}", a.removeVolume).Methods("DELETE")
+	apiRouter.HandleFunc("/api/networks", a.networks).Methods("GET")
+	apiRouter.HandleFunc("/api/networks", a.createNetwork).Methods("POST")
+	apiRouter.HandleFunc("/api/networks/{id}", a.network).Methods("GET")
+	apiRouter.HandleFunc("/api/networks/{id}", a.removeNetwork).Methods("DELETE")
+	apiRouter.HandleFunc("/api/networks/{id}/connect", a.connectNetwork).Methods("POST")
+	apiRouter.HandleFunc("/api/networks/{id}/disconnect", a.disconnectNetwork).Methods("POST")
 	apiRouter.HandleFunc("/api/containers/{id}/scale", a.scaleContainer).Methods("POST")
+	apiRouter.HandleFunc("/api/containers/{id}/restart-policy", a.setRestartPolicy).Methods("PUT")
+	apiRouter.HandleFunc("/api/containers/{id}/update", a.updateContainerResources).Methods("POST")
+	apiRouter.HandleFunc("/api/containers/{id}/stats", a.containerStats).Methods("GET")
+	apiRouter.HandleFunc("/api/containers/{id}/fs-changes", a.containerFsChanges).Methods("GET")
+	apiRouter.HandleFunc("/api/containers/{id}/logs", a.containerLogs).Methods("GET")
+	apiRouter.Handle("/api/ws/containers", websocketHandler(a.containerUpdates)).Methods("GET")
 	apiRouter.HandleFunc("/api/events", a.events).Methods("GET")
 	apiRouter.HandleFunc("/api/events", a.purgeEvents).Methods("DELETE")
+	apiRouter.HandleFunc("/api/auditlog/verify", a.verifyAuditLog).Methods("GET")
+	apiRouter.HandleFunc("/api/auditlogs", a.auditEntries).Methods("GET")
+	apiRouter.HandleFunc("/api/container-defaults", a.containerDefaults).Methods("GET")
+	apiRouter.HandleFunc("/api/container-defaults", a.setContainerDefaults).Methods("PUT")
+	apiRouter.HandleFunc("/api/container-defaults", a.removeContainerDefaults).Methods("DELETE")
+	apiRouter.HandleFunc("/api/stacks", a.stacks).Methods("GET")
+	apiRouter.HandleFunc("/api/stacks", a.deployStack).Methods("POST")
+	apiRouter.HandleFunc("/api/stacks/{name}", a.stack).Methods("GET")
+	apiRouter.HandleFunc("/api/stacks/{name}", a.removeStack).Methods("DELETE")
+	apiRouter.HandleFunc("/api/stacks/{name}/redeploy", a.redeployStack).Methods("POST")
+	apiRouter.HandleFunc("/api/stacks/{name}/deployments", a.stackDeployments).Methods("GET")
+	apiRouter.HandleFunc("/api/stacks/{name}/top", a.stackTop).Methods("GET")
+	apiRouter.HandleFunc("/api/stacks/{name}/{action:stop|start}", a.controlStack).Methods("POST")
+	apiRouter.HandleFunc("/api/stacks/{name}/export", a.exportStack).Methods("GET")
+	apiRouter.HandleFunc("/api/stacks/{name}/canary", a.canary).Methods("GET")
+	apiRouter.HandleFunc("/api/stacks/{name}/canary", a.startCanary).Methods("POST")
+	apiRouter.HandleFunc("/api/stacks/{name}/canary/{action:promote|rollback}", a.decideCanary).Methods("POST")
+	apiRouter.HandleFunc("/api/templates", a.templates).Methods("GET")
+	apiRouter.HandleFunc("/api/templates/import", a.importTemplate).Methods("POST")
+	apiRouter.HandleFunc("/api/templates/{name}", a.template).Methods("GET")
+	apiRouter.HandleFunc("/api/templates/{name}", a.deleteTemplate).Methods("DELETE")
+	apiRouter.HandleFunc("/api/templates/{name}/export", a.exportTemplate).Methods("GET")
+	apiRouter.HandleFunc("/api/templates/{name}/deploy", a.deployTemplate).Methods("POST")
+	apiRouter.HandleFunc("/api/freezes", a.freezes).Methods("GET")
+	apiRouter.HandleFunc("/api/freezes", a.saveFreeze).Methods("POST")
+	apiRouter.HandleFunc("/api/freezes/{id}", a.freeze).Methods("GET")
+	apiRouter.HandleFunc("/api/freezes/{id}", a.saveFreeze).Methods("PUT")
+	apiRouter.HandleFunc("/api/freezes/{id}", a.deleteFreeze).Methods("DELETE")
+	apiRouter.HandleFunc("/api/clientrules", a.clientRules).Methods("GET")
+	apiRouter.HandleFunc("/api/clientrules", a.saveClientRule).Methods("POST")
+	apiRouter.HandleFunc("/api/clientrules/{id}", a.clientRule).Methods("GET")
+	apiRouter.HandleFunc("/api/clientrules/{id}", a.saveClientRule).Methods("PUT")
+	apiRouter.HandleFunc("/api/clientrules/{id}", a.deleteClientRule).Methods("DELETE")
+	apiRouter.HandleFunc("/api/jobs", a.jobs).Methods("GET")
+	apiRouter.HandleFunc("/api/jobs", a.saveJob).Methods("POST")
+	apiRouter.HandleFunc("/api/jobs/{id}", a.job).Methods("GET")
+	apiRouter.HandleFunc("/api/jobs/{id}", a.saveJob).Methods("PUT")
+	apiRouter.HandleFunc("/api/jobs/{id}", a.deleteJob).Methods("DELETE")
+	apiRouter.HandleFunc("/api/jobs/{id}/run", a.runJob).Methods("POST")
+	apiRouter.HandleFunc("/api/query", a.query).Methods("POST")
+	apiRouter.HandleFunc("/api/pulls", a.pulls).Methods("GET")
 	apiRouter.HandleFunc("/api/registries", a.registries).Methods("GET")
 	apiRouter.HandleFunc("/api/registries", a.addRegistry).Methods("POST")
+	// before /api/registries/{registryId} so mirror is not taken for an id
+	apiRouter.HandleFunc("/api/registries/mirror", a.registryMirror).Methods("GET")
+	apiRouter.HandleFunc("/api/registries/mirror", a.setRegistryMirror).Methods("PUT")
+	apiRouter.HandleFunc("/api/registries/mirror", a.removeRegistryMirror).Methods("DELETE")
 	apiRouter.HandleFunc("/api/registries/{registryId}", a.registry).Methods("GET")
 	apiRouter.HandleFunc("/api/registries/{registryId}", a.removeRegistry).Methods("DELETE")
-	apiRouter.HandleFunc("/api/registries/{registryId}/repositories", a.repositories).Methods("GET")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories", a.cache.handler(a.repositories)).Methods("GET")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/tags", a.cache.handler(a.repositoryTags)).Methods("GET")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/tags/{tag}", a.deleteRepositoryTag).Methods("DELETE")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/manifests/{reference}", a.repositoryManifest).Methods("GET")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/manifests/{reference}", a.deleteRepositoryManifest).Methods("DELETE")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/scan", a.imageScan).Methods("GET")
+	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/scan", a.scanImage).Methods("POST")
 	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}", a.repository).Methods("GET")
 	apiRouter.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}", a.deleteRepository).Methods("DELETE")
 	apiRouter.HandleFunc("/api/servicekeys", a.serviceKeys).Methods("GET")
 	apiRouter.HandleFunc("/api/servicekeys", a.addServiceKey).Methods("POST")
 	apiRouter.HandleFunc("/api/servicekeys", a.removeServiceKey).Methods("DELETE")
+	apiRouter.HandleFunc("/api/servicekeys/{id}/usage", a.serviceKeyUsage).Methods("GET")
 	apiRouter.HandleFunc("/api/webhookkeys", a.webhookKeys).Methods("GET")
 	apiRouter.HandleFunc("/api/webhookkeys/{id}", a.webhookKey).Methods("GET")
 	apiRouter.HandleFunc("/api/webhookkeys", a.addWebhookKey).Methods("POST")
+	apiRouter.HandleFunc("/api/webhookkeys/{id}/test", a.testWebhookKey).Methods("POST")
 	apiRouter.HandleFunc("/api/webhookkeys/{id}", a.deleteWebhookKey).Methods("DELETE")
 	apiRouter.HandleFunc("/api/consolesession/{container}", a.createConsoleSession).Methods("GET")
 	apiRouter.HandleFunc("/api/consolesession/{token}", a.consoleSession).Methods("GET")
 	apiRouter.HandleFunc("/api/consolesession/{token}", a.removeConsoleSession).Methods("DELETE")
+	apiRouter.HandleFunc("/api/sharelinks", a.shareLinks).Methods("GET")
+	apiRouter.HandleFunc("/api/sharelinks", a.addShareLink).Methods("POST")
+	apiRouter.HandleFunc("/api/sharelinks/{id}", a.revokeShareLink).Methods("DELETE")
+	apiRouter.HandleFunc("/api/notes", a.notes).Methods("GET")
+	apiRouter.HandleFunc("/api/notes/{kind}/{target}", a.note).Methods("GET")
+	apiRouter.HandleFunc("/api/notes/{kind}/{target}", a.saveNote).Methods("PUT")
+	apiRouter.HandleFunc("/api/notes/{kind}/{target}", a.deleteNote).Methods("DELETE")
+	apiRouter.HandleFunc("/api/notifiers", a.notifiers).Methods("GET")
+	apiRouter.HandleFunc("/api/notifiers", a.saveNotifier).Methods("POST")
+	apiRouter.HandleFunc("/api/notifiers/{id}", a.notifier).Methods("GET")
+	apiRouter.HandleFunc("/api/notifiers/{id}", a.deleteNotifier).Methods("DELETE")
+	apiRouter.HandleFunc("/api/notifications/rules", a.notificationRules).Methods("GET")
+	apiRouter.HandleFunc("/api/notifications/rules", a.saveNotificationRule).Methods("POST")
+	apiRouter.HandleFunc("/api/notifications/rules/{id}", a.notificationRule).Methods("GET")
+	apiRouter.HandleFunc("/api/notifications/rules/{id}", a.deleteNotificationRule).Methods("DELETE")
+	apiRouter.HandleFunc("/api/notifications/escalations", a.escalations).Methods("GET")
+	apiRouter.HandleFunc("/api/notifications/escalations/{id}/ack", a.acknowledgeEscalation).Methods("POST")
+	apiRouter.HandleFunc("/api/exec/sessions", a.listExecSessions).Methods("GET")
+	apiRouter.HandleFunc("/api/exec/sessions/recorded", a.execRecordings).Methods("GET")
+	apiRouter.HandleFunc("/api/exec/sessions/{id}/recording", a.execRecording).Methods("GET")
+	apiRouter.HandleFunc("/api/cluster/controllers", a.controllers).Methods("GET")
+	apiRouter.HandleFunc("/api/preflight", a.preflight).Methods("GET")
+	apiRouter.HandleFunc("/api/settings/cluster", a.clusterSettings).Methods("GET")
+	apiRouter.HandleFunc("/api/settings/cluster", a.updateClusterSettings).Methods("PUT")
+	apiRouter.HandleFunc("/api/breakglass", a.breakGlass).Methods("GET")
+	apiRouter.HandleFunc("/api/breakglass", a.sealBreakGlass).Methods("POST")
+	apiRouter.HandleFunc("/api/breakglass", a.endBreakGlass).Methods("DELETE")
+	apiRouter.HandleFunc("/api/admin/seed-demo", a.seedDemo).Methods("POST")
+	apiRouter.HandleFunc("/api/admin/seed-demo", a.teardownDemo).Methods("DELETE")
+	apiRouter.HandleFunc("/api/admin/housekeeping", a.housekeeping).Methods("POST")
+	apiRouter.HandleFunc("/api/backup", a.backup).Methods("GET")
+	apiRouter.HandleFunc("/api/restore", a.restore).Methods("POST")
+	apiRouter.HandleFunc("/api/admin/cleanup", a.cleanupReport).Methods("GET")
+	apiRouter.HandleFunc("/api/admin/deprecations", a.deprecatedUsage).Methods("GET")
+	apiRouter.HandleFunc("/api/usage/accounts", a.accountUsage).Methods("GET")
+	apiRouter.HandleFunc("/api/admin/cleanup/{kind}/{id}", a.remediate).Methods("POST")
+	apiRouter.HandleFunc("/api/execpolicies", a.execPolicies).Methods("GET")
+	apiRouter.HandleFunc("/api/execpolicies", a.saveExecPolicy).Methods("POST")
+	apiRouter.HandleFunc("/api/execpolicies/{id}", a.execPolicy).Methods("GET")
+	apiRouter.HandleFunc("/api/execpolicies/{id}", a.deleteExecPolicy).Methods("DELETE")
+	apiRouter.HandleFunc("/api/alerts", a.alerts).Methods("GET")
+	apiRouter.HandleFunc("/api/alerts/{id}", a.alert).Methods("GET")
+	apiRouter.HandleFunc("/api/alerts/{id}/ack", a.acknowledgeAlert).Methods("POST")
+	apiRouter.HandleFunc("/api/alerts/{id}/assign", a.assignAlert).Methods("POST")
+	apiRouter.HandleFunc("/api/alerts/{id}/comments", a.addAlertComment).Methods("POST")
 
 	// global handler
 	globalMux.Handle("/", http.FileServer(http.Dir("static")))
@@ -153,13 +356,25 @@ func (a *Api) Run() error {
 		"^/images/json",
 		"^/api/events",
 	}
-	apiAuditor := audit.NewAuditor(controllerManager, auditExcludes)
+	var auditSyslog *syslog.Writer
+	if a.auditSyslogAddr != "" {
+		w, err := syslog.NewWriter(a.auditSyslogAddr, nil)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("streaming audit records to syslog: %s", a.auditSyslogAddr)
+		auditSyslog = w
+	}
+
+	apiAuditor := audit.NewAuditor(controllerManager, auditExcludes, auditSyslog)
 
 	// api router; protected by auth
 	apiAuthRouter := negroni.New()
 	apiAuthRequired := mAuth.NewAuthRequired(controllerManager, a.authWhitelistCIDRs)
 	apiAccessRequired := access.NewAccessRequired(controllerManager)
 	apiAuthRouter.Use(negroni.HandlerFunc(apiAuthRequired.HandlerFuncWithNext))
+	apiAuthRouter.Use(negroni.HandlerFunc(a.usage.HandlerFuncWithNext))
 	apiAuthRouter.Use(negroni.HandlerFunc(apiAccessRequired.HandlerFuncWithNext))
 	apiAuthRouter.Use(negroni.HandlerFunc(apiAuditor.HandlerFuncWithNext))
 	apiAuthRouter.UseHandler(apiRouter)
@@ -171,6 +386,7 @@ func (a *Api) Run() error {
 	accountAuthRouter := negroni.New()
 	accountAuthRequired := mAuth.NewAuthRequired(controllerManager, a.authWhitelistCIDRs)
 	accountAuthRouter.Use(negroni.HandlerFunc(accountAuthRequired.HandlerFuncWithNext))
+	accountAuthRouter.Use(negroni.HandlerFunc(a.usage.HandlerFuncWithNext))
 	accountAuthRouter.Use(negroni.HandlerFunc(apiAuditor.HandlerFuncWithNext))
 	accountAuthRouter.UseHandler(accountRouter)
 	globalMux.Handle("/account/", accountAuthRouter)
@@ -178,14 +394,29 @@ func (a *Api) Run() error {
 	// login handler; public
 	loginRouter := mux.NewRouter()
 	loginRouter.HandleFunc("/auth/login", a.login).Methods("POST")
+	loginRouter.HandleFunc("/auth/refresh", a.refreshToken).Methods("POST")
+	loginRouter.HandleFunc("/auth/reset", a.completePasswordReset).Methods("POST")
+	loginRouter.HandleFunc("/auth/breakglass", a.useBreakGlass).Methods("POST")
+	loginRouter.HandleFunc("/auth/oidc/login", a.oidcLogin).Methods("GET")
+	loginRouter.HandleFunc("/auth/oidc/callback", a.oidcCallback).Methods("GET")
 	globalMux.Handle("/auth/", loginRouter)
-	globalMux.Handle("/exec", websocket.Handler(a.execContainer))
+	globalMux.Handle("/exec", websocketHandler(a.execContainer))
 
 	// hub handler; public
 	hubRouter := mux.NewRouter()
-	hubRouter.HandleFunc("/hub/webhook/{id}", a.hubWebhook).Methods("POST")
+	hubRouter.HandleFunc("/hub/webhook/{id}", a.onlineOnly(offlineHubWebhook, a.hubWebhook)).Methods("POST")
+	hubRouter.HandleFunc("/hub/github/{id}", a.ciWebhook(ci.ProviderGitHub)).Methods("POST")
+	hubRouter.HandleFunc("/hub/gitlab/{id}", a.ciWebhook(ci.ProviderGitLab)).Methods("POST")
 	globalMux.Handle("/hub/", hubRouter)
 
+	// share link handler; public, the token grants read-only views
+	shareRouter := mux.NewRouter()
+	shareRouter.HandleFunc("/share/{token}", a.sharedLink).Methods("GET")
+	shareRouter.HandleFunc("/share/{token}/logs", a.sharedContainerView(shipyard.ShareViewLogs)).Methods("GET")
+	shareRouter.HandleFunc("/share/{token}/stats", a.sharedContainerView(shipyard.ShareViewStats)).Methods("GET")
+	shareRouter.HandleFunc("/share/{token}/dashboard", a.sharedDashboard).Methods("GET")
+	globalMux.Handle("/share/", shareRouter)
+
 	// swarm
 	swarmRouter := mux.NewRouter()
 	// these are pulled from the swarm api code to proxy and allow
@@ -198,13 +429,15 @@ func (a *Api) Run() error {
 			"/version":                        swarmRedirect,
 			"/images/json":                    swarmRedirect,
 			"/images/viz":                     swarmRedirect,
-			"/images/search":                  swarmRedirect,
+			"/images/search":                  a.onlineOnly(offlineHubSearch, a.cache.handler(swarmRedirect)),
 			"/images/get":                     swarmRedirect,
 			"/images/{name:.*}/get":           swarmRedirect,
 			"/images/{name:.*}/history":       swarmRedirect,
 			"/images/{name:.*}/json":          swarmRedirect,
 			"/networks":                       swarmRedirect,
 			"/networks/{name:.*}":             swarmRedirect,
+			"/volumes":                        swarmRedirect,
+			"/volumes/{name:.*}":              swarmRedirect,
 			"/containers/ps":                  swarmRedirect,
 			"/containers/json":                swarmRedirect,
 			"/containers/{name:.*}/export":    swarmRedirect,
@@ -213,6 +446,7 @@ func (a *Api) Run() error {
 			"/containers/{name:.*}/top":       swarmRedirect,
 			"/containers/{name:.*}/logs":      swarmRedirect,
 			"/containers/{name:.*}/stats":     swarmRedirect,
+			"/containers/{name:.*}/archive":   swarmRedirect,
 			"/containers/{name:.*}/attach/ws": swarmHijack,
 			"/exec/{execid:.*}/json":          swarmRedirect,
 		},
@@ -227,7 +461,8 @@ func (a *Api) Run() error {
 			"/networks/create":              swarmRedirect,
 			"/networks/{name:.*}/connect":	 swarmRedirect,
 			"/networks/{name:.*}/disconnect": swarmRedirect,
-			"/containers/create":            swarmRedirect,
+			"/volumes/create":               swarmRedirect,
+			"/containers/create":            a.ttlGuard(a.freezeGuard(a.drainGuard(swarmRedirect))),
 			"/containers/{name:.*}/kill":    swarmRedirect,
 			"/containers/{name:.*}/pause":   swarmRedirect,
 			"/containers/{name:.*}/unpause": swarmRedirect,
@@ -239,12 +474,20 @@ func (a *Api) Run() error {
 			"/containers/{name:.*}/resize":  swarmRedirect,
 			"/containers/{name:.*}/attach":  swarmHijack,
 			"/containers/{name:.*}/copy":    swarmRedirect,
+			"/containers/{name:.*}/update":  a.proxyContainerUpdate,
 			"/containers/{name:.*}/exec":    swarmRedirect,
 			"/exec/{execid:.*}/start":       swarmHijack,
 			"/exec/{execid:.*}/resize":      swarmRedirect,
 		},
+		"HEAD": {
+			"/containers/{name:.*}/archive": swarmRedirect,
+		},
+		"PUT": {
+			"/containers/{name:.*}/archive": swarmRedirect,
+		},
 		"DELETE": {
 			"/networks/{name:.*}":	 swarmRedirect,
+			"/volumes/{name:.*}":    swarmRedirect,
 			"/containers/{name:.*}": swarmRedirect,
 			"/images/{name:.*}":     swarmRedirect,
 		},
@@ -257,29 +500,26 @@ func (a *Api) Run() error {
 		for route, fct := range routes {
 			localRoute := route
 			localFct := fct
-			wrap := func(w http.ResponseWriter, r *http.Request) {
-				if a.enableCors {
-					writeCorsHeaders(w, r)
-				}
-				localFct(w, r)
-			}
 			localMethod := method
 
 			// add the new route
-			swarmRouter.Path("/v{version:[0-9.]+}" + localRoute).Methods(localMethod).HandlerFunc(wrap)
-			swarmRouter.Path(localRoute).Methods(localMethod).HandlerFunc(wrap)
+			swarmRouter.Path("/v{version:[0-9.]+}" + localRoute).Methods(localMethod).HandlerFunc(localFct)
+			swarmRouter.Path(localRoute).Methods(localMethod).HandlerFunc(localFct)
 		}
 	}
 
 	swarmAuthRouter := negroni.New()
 	swarmAuthRequired := mAuth.NewAuthRequired(controllerManager, a.authWhitelistCIDRs)
-	swarmAccessRequired := access.NewAccessRequired(controllerManager)
+	swarmAccessRequired := access.NewProxyAccessRequired(controllerManager)
 	swarmAuthRouter.Use(negroni.HandlerFunc(swarmAuthRequired.HandlerFuncWithNext))
+	swarmAuthRouter.Use(negroni.HandlerFunc(a.usage.HandlerFuncWithNext))
 	swarmAuthRouter.Use(negroni.HandlerFunc(swarmAccessRequired.HandlerFuncWithNext))
 	swarmAuthRouter.Use(negroni.HandlerFunc(apiAuditor.HandlerFuncWithNext))
 	swarmAuthRouter.UseHandler(swarmRouter)
 	globalMux.Handle("/networks", swarmAuthRouter)
 	globalMux.Handle("/networks/", swarmAuthRouter)
+	globalMux.Handle("/volumes", swarmAuthRouter)
+	globalMux.Handle("/volumes/", swarmAuthRouter)
 	globalMux.Handle("/containers/", swarmAuthRouter)
 	globalMux.Handle("/_ping", swarmAuthRouter)
 	globalMux.Handle("/commit", swarmAuthRouter)
@@ -288,77 +528,69 @@ func (a *Api) Run() error {
 	globalMux.Handle("/version", swarmAuthRouter)
 	globalMux.Handle("/images/", swarmAuthRouter)
 	globalMux.Handle("/exec/", swarmAuthRouter)
-	globalMux.Handle("/v1.14/", swarmAuthRouter)
-	globalMux.Handle("/v1.15/", swarmAuthRouter)
-	globalMux.Handle("/v1.16/", swarmAuthRouter)
-	globalMux.Handle("/v1.17/", swarmAuthRouter)
-	globalMux.Handle("/v1.18/", swarmAuthRouter)
-	globalMux.Handle("/v1.19/", swarmAuthRouter)
-	globalMux.Handle("/v1.20/", swarmAuthRouter)
+	// every handler above serves the cluster of the path prefix
+	globalMux.Handle("/clusters/", clusterPrefixHandler(globalMux))
 
-	// check for admin user
-	if _, err := controllerManager.Account("admin"); err == manager.ErrAccountDoesNotExist {
-		// create roles
-		acct := &auth.Account{
-			Username:  "admin",
-			Password:  "shipyard",
-			FirstName: "Shipyard",
-			LastName:  "Admin",
-			Roles:     []string{"admin"},
-		}
-		if err := controllerManager.SaveAccount(acct); err != nil {
-			log.Fatal(err)
+	// request metrics use the route templates so every router is
+	// instrumented once all routes are registered
+	for _, router := range []*mux.Router{apiRouter, accountRouter, loginRouter, hubRouter, shareRouter, swarmRouter} {
+		if err := instrumentRouter(router); err != nil {
+			return err
 		}
-		log.Infof("created admin user: username: admin password: shipyard")
 	}
+	a.registerMetrics()
 
-	log.Infof("controller listening on %s", a.listenAddr)
+	// metrics handler; public for scraping
+	globalMux.Handle("/metrics", metrics.Handler())
 
-	s := &http.Server{
-		Addr:    a.listenAddr,
-		Handler: context.ClearHandler(globalMux),
-	}
+	// health checks; public for load balancers and orchestrators
+	globalMux.HandleFunc("/healthz", a.healthz)
+	globalMux.HandleFunc("/readyz", a.readyz)
 
-	var runErr error
+	// check for admin user
+	if err := a.bootstrapAdmin(); err != nil {
+		log.Fatal(err)
+	}
 
-	if a.tlsCertPath != "" && a.tlsKeyPath != "" {
-		log.Infof("using TLS for communication: cert=%s key=%s",
-			a.tlsCertPath,
-			a.tlsKeyPath,
-		)
+	log.Infof("controller listening on %s", a.listenAddr)
 
-		// setup TLS config
-		var caCert []byte
-		if a.tlsCACertPath != "" {
-			ca, err := ioutil.ReadFile(a.tlsCACertPath)
-			if err != nil {
-				return err
-			}
+	// every request is logged with its id, which the handlers below see
+	requestLogger := negroni.New()
+	requestLogger.Use(negroni.HandlerFunc(requestlog.NewRequestLogger().HandlerFuncWithNext))
+	// versioned docker paths go to swarm whatever the version
+	requestLogger.UseHandler(a.corsHandler(a.deprecations.handler(a.clientGate(fieldsHandler(dockerAPIHandler(swarmAuthRouter, globalMux))))))
 
-			caCert = ca
-		}
+	s := &http.Server{
+		Addr:    a.listenAddr,
+		Handler: context.ClearHandler(requestLogger),
+	}
 
-		serverCert, err := ioutil.ReadFile(a.tlsCertPath)
-		if err != nil {
-			return err
-		}
+	if !a.tlsEnabled() {
+		return s.ListenAndServe()
+	}
 
-		serverKey, err := ioutil.ReadFile(a.tlsKeyPath)
-		if err != nil {
-			return err
-		}
+	log.Infof("using TLS for communication: cert=%s key=%s",
+		a.tlsCertPath,
+		a.tlsKeyPath,
+	)
 
-		tlsConfig, err := tlsutils.GetServerTLSConfig(caCert, serverCert, serverKey, a.allowInsecure)
-		if err != nil {
-			return err
-		}
+	tlsConfig, err := a.serverTLSConfig()
+	if err != nil {
+		return err
+	}
 
-		s.TLSConfig = tlsConfig
+	s.TLSConfig = tlsConfig
 
-		runErr = s.ListenAndServeTLS(a.tlsCertPath, a.tlsKeyPath)
-	} else {
-		runErr = s.ListenAndServe()
+	errs := make(chan error, 2)
+	if a.httpRedirectAddr != "" {
+		go func() {
+			errs <- a.serveHTTPRedirect()
+		}()
 	}
 
-	return runErr
+	go func() {
+		errs <- s.ListenAndServeTLS(a.tlsCertPath, a.tlsKeyPath)
+	}()
+
+	return <-errs
 }
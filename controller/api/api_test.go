@@ -1,7 +1,11 @@
 package api
 
 import (
+	"net/http"
+
 	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
 	"github.com/shipyard/shipyard/controller/mock_test"
 )
 
@@ -12,7 +16,7 @@ func getTestApi() (*Api, error) {
 		ListenAddr:         "",
 		Manager:            m,
 		AuthWhiteListCIDRs: nil,
-		EnableCORS:         false,
+		CORSOrigins:        []string{"https://ops.example.com"},
 		AllowInsecure:      true,
 		TLSCertPath:        "",
 		TLSKeyPath:         "",
@@ -20,3 +24,18 @@ func getTestApi() (*Api, error) {
 
 	return NewApi(config)
 }
+
+// authenticated stands in for the auth middleware, which records the
+// account of the access token once it is verified; as there, the token of
+// service key requests is not checked
+func authenticated(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Service-Key") == "" {
+			if tk, err := auth.GetAccessToken(mAuth.AccessToken(r)); err == nil {
+				mAuth.SetUsername(r, tk.Username)
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+// auditEntries returns audit entries newest first; they can be filtered by
+// username and an RFC 3339 time range with since and until
+func (a *Api) auditEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	query := &datastore.AuditQuery{
+		Username: r.FormValue("username"),
+	}
+
+	if v := r.FormValue("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.After = since
+	}
+
+	if v := r.FormValue("until"); v != "" {
+		until, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Before = until
+	}
+
+	if v := r.FormValue("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+
+	entries, err := a.manager.AuditEntries(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) verifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	result, err := a.manager.VerifyAuditLog()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !result.Valid {
+		log.Warnf("audit log verification failed: sequence=%d %s", result.FailedSequence, result.Message)
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func getAuditLogRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/auditlogs", api.auditEntries).Methods("GET")
+
+	return router
+}
+
+func TestApiGetAuditEntries(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getAuditLogRouter(api))
+	defer ts.Close()
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"", 1},
+		{"?username=testuser", 1},
+		{"?username=admin", 0},
+		{"?since=2016-01-01T00:00:00Z&until=2016-01-03T00:00:00Z", 1},
+		{"?since=2016-01-03T00:00:00Z", 0},
+	}
+
+	for _, test := range tests {
+		res, err := http.Get(ts.URL + "/api/auditlogs" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+		entries := []*shipyard.AuditEntry{}
+		if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, entries, test.count, "unexpected entries for %q", test.query)
+	}
+
+	res, err := http.Get(ts.URL + "/api/auditlogs?since=yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 400, res.StatusCode, "expected an invalid time to be refused")
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// backup returns the configuration of the controller as a download
+func (a *Api) backup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	backup, err := a.manager.Backup()
+	if err != nil {
+		log.Errorf("error creating backup: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=shipyard-backup-%s.json", backup.CreatedAt.Format("20060102-150405")))
+
+	if err := json.NewEncoder(w).Encode(backup); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// restore imports the backup in the body; with dry_run=true the changes
+// are returned without saving them
+func (a *Api) restore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var backup *shipyard.Backup
+	if err := json.NewDecoder(r.Body).Decode(&backup); err != nil || backup == nil {
+		http.Error(w, fmt.Sprintf("invalid backup: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+
+	result, err := a.manager.Restore(backup, dryRun)
+	if err != nil {
+		if _, ok := err.(*manager.BackupError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Errorf("error restoring backup: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !dryRun {
+		log.Infof("restored backup of %s by %s: changes=%d", backup.CreatedAt.Format(time.RFC3339), getUsername(r), len(result.Changes))
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/backup", nil)
+	api.backup(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Header().Get("content-disposition"), "shipyard-backup-")
+
+	body := res.Body.Bytes()
+
+	var backup *shipyard.Backup
+	if err := json.Unmarshal(body, &backup); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, shipyard.BackupVersion, backup.Version)
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/restore?dry_run=true", bytes.NewReader(body))
+	api.restore(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var result *manager.RestoreResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, result.DryRun)
+
+	for _, body := range []string{`{"version":99}`, `not json`} {
+		res = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/api/restore", bytes.NewBufferString(body))
+		api.restore(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code, body)
+	}
+}
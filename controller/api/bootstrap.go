@@ -0,0 +1,77 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+const (
+	DefaultAdminUsername = "admin"
+	DefaultAdminPassword = "shipyard"
+
+	// generatedPasswordLength is the random bytes of generated admin
+	// passwords
+	generatedPasswordLength = 18
+)
+
+// AdminBootstrap is the admin account created on the first start
+type AdminBootstrap struct {
+	// Username is admin when empty
+	Username string
+	// Password is shipyard when empty
+	Password string
+	// RandomPassword generates the password and logs it once
+	RandomPassword bool
+}
+
+// bootstrapAdmin creates the admin account when it does not exist; the
+// default and generated passwords have to be changed on first login
+func (a *Api) bootstrapAdmin() error {
+	username := a.admin.Username
+	if username == "" {
+		username = DefaultAdminUsername
+	}
+
+	if _, err := a.manager.Account(username); err != manager.ErrAccountDoesNotExist {
+		return err
+	}
+
+	password := a.admin.Password
+	if a.admin.RandomPassword {
+		buf := make([]byte, generatedPasswordLength)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	} else if password == "" {
+		password = DefaultAdminPassword
+	}
+
+	acct := &auth.Account{
+		Username:            username,
+		Password:            password,
+		FirstName:           "Shipyard",
+		LastName:            "Admin",
+		Roles:               []string{"admin"},
+		ForcePasswordChange: a.admin.RandomPassword || password == DefaultAdminPassword,
+	}
+	if err := a.manager.SaveAccount(acct); err != nil {
+		return err
+	}
+
+	switch {
+	case a.admin.RandomPassword:
+		// the password is only ever shown here
+		log.Warnf("created admin user: username: %s password: %s (change it on first login; it is not shown again)", username, password)
+	case password == DefaultAdminPassword:
+		log.Infof("created admin user: username: %s password: %s (change it on first login)", username, password)
+	default:
+		log.Infof("created admin user: username: %s", username)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+// bootstrapManager is a manager without accounts which keeps the account
+// saved last
+type bootstrapManager struct {
+	mock_test.MockManager
+	saved *auth.Account
+}
+
+func (m *bootstrapManager) Account(username string) (*auth.Account, error) {
+	return nil, manager.ErrAccountDoesNotExist
+}
+
+func (m *bootstrapManager) SaveAccount(account *auth.Account) error {
+	m.saved = account
+	return nil
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	for _, test := range []struct {
+		admin AdminBootstrap
+		force bool
+	}{
+		{AdminBootstrap{}, true},
+		{AdminBootstrap{Username: "root", Password: "secret"}, false},
+		{AdminBootstrap{Username: "root", Password: "secret", RandomPassword: true}, true},
+	} {
+		m := &bootstrapManager{}
+		a := &Api{manager: m, admin: test.admin}
+
+		if err := a.bootstrapAdmin(); err != nil {
+			t.Fatal(err)
+		}
+
+		if test.admin.Username == "" {
+			assert.Equal(t, DefaultAdminUsername, m.saved.Username, "expected the default username")
+			assert.Equal(t, DefaultAdminPassword, m.saved.Password, "expected the default password")
+		} else {
+			assert.Equal(t, test.admin.Username, m.saved.Username, "expected the configured username")
+		}
+
+		if test.admin.RandomPassword {
+			assert.NotEqual(t, test.admin.Password, m.saved.Password, "expected a generated password")
+			assert.Len(t, m.saved.Password, 24, "expected 18 random bytes")
+		}
+
+		assert.Equal(t, []string{"admin"}, m.saved.Roles, "expected the admin role")
+		assert.Equal(t, test.force, m.saved.ForcePasswordChange, "unexpected forced password change")
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/utils"
+)
+
+type breakGlassRequest struct {
+	Credential string `json:"credential,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+func (a *Api) breakGlass(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	b, err := a.manager.BreakGlass()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"sealed":      b.Sealed(),
+		"break_glass": b,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) sealBreakGlass(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	credential, err := a.manager.SealBreakGlass(getUsername(r))
+	if err != nil {
+		log.Errorf("error sealing break-glass credential: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warnf("break-glass credential sealed by %s", getUsername(r))
+
+	if err := json.NewEncoder(w).Encode(breakGlassRequest{Credential: credential}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) endBreakGlass(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.EndBreakGlass(getUsername(r)); err != nil {
+		log.Errorf("error ending break-glass access: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// useBreakGlass is public; it returns a token for the break-glass account
+func (a *Api) useBreakGlass(w http.ResponseWriter, r *http.Request) {
+	var req *breakGlassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, err := a.manager.UseBreakGlass(req.Credential, req.Reason, utils.RemoteIP(r.RemoteAddr), r.UserAgent())
+	if err != nil {
+		log.Warnf("break-glass attempt from %s failed: %s", r.RemoteAddr, err)
+		switch err {
+		case manager.ErrBreakGlassReasonNeeded:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case manager.ErrBreakGlassNotSealed, manager.ErrInvalidBreakGlass:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Warnf("break-glass access granted to %s: reason=%s", r.RemoteAddr, req.Reason)
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
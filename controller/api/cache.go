@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCache caches successful GET responses for a short time and
+// coalesces concurrent identical requests so only one reaches the
+// upstream (Docker Hub search or a registry)
+type responseCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	entries  map[string]*cachedResponse
+	inflight map[string]*cacheCall
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type cacheCall struct {
+	done chan struct{}
+	res  *cachedResponse
+}
+
+// cacheRecorder buffers a response so it can be shared
+type cacheRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *cacheRecorder) Header() http.Header {
+	return c.header
+}
+
+func (c *cacheRecorder) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	return c.body.Write(b)
+}
+
+func (c *cacheRecorder) WriteHeader(code int) {
+	c.status = code
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:      ttl,
+		entries:  map[string]*cachedResponse{},
+		inflight: map[string]*cacheCall{},
+	}
+}
+
+// cacheKey includes the registry credentials used for the hub search
+func cacheKey(r *http.Request) string {
+	return r.URL.RequestURI() + "\x00" + r.Header.Get("X-Registry-Auth")
+}
+
+// handler serves GET requests through the cache; a zero ttl disables it
+func (c *responseCache) handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c == nil || c.ttl <= 0 || r.Method != "GET" {
+			next(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+
+		c.mu.Lock()
+		if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+			c.mu.Unlock()
+			cacheRequests.Inc("hit")
+			e.write(w, "HIT")
+			return
+		}
+
+		if call, ok := c.inflight[key]; ok {
+			c.mu.Unlock()
+			cacheRequests.Inc("coalesced")
+			<-call.done
+			call.res.write(w, "HIT")
+			return
+		}
+
+		call := &cacheCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		c.mu.Unlock()
+
+		cacheRequests.Inc("miss")
+
+		rec := &cacheRecorder{header: http.Header{}}
+		defer func() {
+			// waiters receive the response even when it is not cached
+			if call.res == nil {
+				call.res = &cachedResponse{status: http.StatusInternalServerError, header: http.Header{}}
+			}
+
+			c.mu.Lock()
+			delete(c.inflight, key)
+			c.mu.Unlock()
+			close(call.done)
+		}()
+
+		next(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		call.res = &cachedResponse{
+			status:  rec.status,
+			header:  rec.header,
+			body:    rec.body.Bytes(),
+			expires: time.Now().Add(c.ttl),
+		}
+
+		if rec.status == http.StatusOK {
+			c.store(key, call.res)
+		}
+
+		call.res.write(w, "MISS")
+	}
+}
+
+func (c *responseCache) store(key string, res *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// drop expired entries so the cache does not grow with every query
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = res
+}
+
+// purge removes cached responses for paths with the prefix; used when a
+// change makes them stale (i.e. deleting a repository)
+func (c *responseCache) purge(prefix string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (e *cachedResponse) write(w http.ResponseWriter, status string) {
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("X-Shipyard-Cache", status)
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheCoalesce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := newResponseCache(time.Minute)
+	h := c.handler(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		fmt.Fprint(w, "results")
+	})
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+			if w.Body.String() != "results" {
+				t.Errorf("expected results; received %q", w.Body.String())
+			}
+		}()
+	}
+
+	// wait for the requests to queue behind the first
+	for i := 0; i < 100 && atomic.LoadInt32(&calls) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+	if w.Header().Get("X-Shipyard-Cache") != "HIT" {
+		t.Fatalf("expected a cache hit")
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected 1 upstream request; received %d", n)
+	}
+}
+
+func TestResponseCacheSkipsErrors(t *testing.T) {
+	calls := 0
+	c := newResponseCache(time.Minute)
+	h := c.handler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "unavailable", http.StatusBadGateway)
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected %d; received %d", http.StatusBadGateway, w.Code)
+		}
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected errors not to be cached; received %d requests", calls)
+	}
+}
+
+func TestResponseCachePurge(t *testing.T) {
+	calls := 0
+	c := newResponseCache(time.Minute)
+	h := c.handler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, "[]")
+	})
+
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/registries/1/repositories", nil))
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/registries/1/repositories", nil))
+	c.purge("/api/registries/1/")
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/registries/1/repositories", nil))
+
+	if calls != 2 {
+		t.Fatalf("expected 2 upstream requests; received %d", calls)
+	}
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	calls := 0
+	c := newResponseCache(0)
+	h := c.handler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+
+	if calls != 2 {
+		t.Fatalf("expected the cache to be disabled; received %d requests", calls)
+	}
+}
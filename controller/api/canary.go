@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// canaryRequest starts a canary; bake is a duration (i.e. 10m)
+type canaryRequest struct {
+	manager.CanaryOptions
+	Bake string `json:"bake"`
+}
+
+func writeCanaryError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrCanaryDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrCanaryNotBaking:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrCanaryReplicas, manager.ErrCanaryPercent, manager.ErrCanaryBake, manager.ErrCanaryImage, manager.ErrCanaryDecision:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeStackError(w, err)
+	}
+}
+
+func (a *Api) canary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	c, err := a.manager.Canary(mux.Vars(r)["name"])
+	if err != nil {
+		writeCanaryError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// startCanary starts a canary of a service of the stack; it bakes in the
+// background so the response is the canary as it starts baking
+func (a *Api) startCanary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var req canaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Bake != "" {
+		bake, err := time.ParseDuration(req.Bake)
+		if err != nil {
+			http.Error(w, "invalid bake: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.CanaryOptions.Bake = bake
+	}
+
+	c, err := a.manager.StartCanary(mux.Vars(r)["name"], &req.CanaryOptions, getUsername(r))
+	if err != nil {
+		writeCanaryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// decideCanary promotes or rolls back the canary of the stack before the
+// end of its bake period
+func (a *Api) decideCanary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := a.manager.DecideCanary(vars["name"], vars["action"], getUsername(r)); err != nil {
+		writeCanaryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
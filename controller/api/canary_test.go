@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func getCanaryRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/stacks/{name}/canary", api.canary).Methods("GET")
+	router.HandleFunc("/api/stacks/{name}/canary", api.startCanary).Methods("POST")
+	router.HandleFunc("/api/stacks/{name}/canary/{action:promote|rollback}", api.decideCanary).Methods("POST")
+
+	return router
+}
+
+func TestApiStartCanary(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getCanaryRouter(api))
+	defer ts.Close()
+
+	tests := []struct {
+		stack string
+		body  string
+		code  int
+	}{
+		{"shop", `{"service": "web", "image": "nginx:1.13", "percent": 25, "bake": "10m"}`, http.StatusAccepted},
+		{"shop", `{"service": "web", "image": "nginx:1.13", "percent": 100}`, http.StatusBadRequest},
+		{"shop", `{"service": "web", "image": "nginx:1.13", "percent": 25, "bake": "soon"}`, http.StatusBadRequest},
+		{"cart", `{"service": "web", "image": "nginx:1.13", "percent": 25}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		res, err := http.Post(ts.URL+"/api/stacks/"+test.stack+"/canary", "application/json", bytes.NewBufferString(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.code, res.StatusCode, test.body)
+
+		if res.StatusCode == http.StatusAccepted {
+			c := &shipyard.Canary{}
+			if err := json.NewDecoder(res.Body).Decode(c); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "nginx:1.13", c.Image)
+			assert.Equal(t, shipyard.CanaryBaking, c.Status)
+		}
+	}
+}
+
+func TestApiCanary(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getCanaryRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/stacks/shop/canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	c := &shipyard.Canary{}
+	if err := json.NewDecoder(res.Body).Decode(c); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 25, c.Weight)
+
+	res, err = http.Get(ts.URL + "/api/stacks/cart/canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+}
+
+func TestApiDecideCanary(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getCanaryRouter(api))
+	defer ts.Close()
+
+	for path, code := range map[string]int{
+		"/api/stacks/shop/canary/promote":  http.StatusAccepted,
+		"/api/stacks/shop/canary/rollback": http.StatusAccepted,
+		"/api/stacks/cart/canary/rollback": http.StatusNotFound,
+		"/api/stacks/shop/canary/pause":    http.StatusNotFound,
+	} {
+		res, err := http.Post(ts.URL+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, code, res.StatusCode, path)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeCleanupError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*manager.FreezeError); ok {
+		writeFreezeError(w, err)
+		return
+	}
+
+	switch err {
+	case manager.ErrCleanupItemDoesNotExist, manager.ErrWebhookKeyDoesNotExist, manager.ErrRegistryDoesNotExist, dockerclient.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// cleanupReport lists orphaned containers, webhook keys and registries
+func (a *Api) cleanupReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	report, err := a.manager.CleanupReport()
+	if err != nil {
+		log.Errorf("error generating the cleanup report: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// remediate cleans up a resource of the cleanup report
+func (a *Api) remediate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kind := vars["kind"]
+	id := vars["id"]
+
+	if err := a.manager.Remediate(kind, id, getUsername(r)); err != nil {
+		log.Errorf("error cleaning up %s %s: %s", kind, id, err)
+		writeCleanupError(w, err)
+		return
+	}
+
+	log.Infof("cleaned up %s %s", kind, id)
+	w.WriteHeader(http.StatusNoContent)
+}
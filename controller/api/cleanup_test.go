@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getCleanupRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/cleanup", api.cleanupReport).Methods("GET")
+	router.HandleFunc("/api/admin/cleanup/{kind}/{id}", api.remediate).Methods("POST")
+
+	return router
+}
+
+func TestApiCleanupReport(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getCleanupRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/admin/cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	report := &manager.CleanupReport{}
+	if err := json.NewDecoder(res.Body).Decode(report); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(report.Items), "expected the items of the report")
+}
+
+func TestApiRemediate(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getCleanupRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/admin/cleanup/"+manager.CleanupWebhookKey+"/"+mock_test.TestWebhookKey.ID, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+
+	res, err = http.Post(ts.URL+"/api/admin/cleanup/"+manager.CleanupRegistry+"/missing", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+}
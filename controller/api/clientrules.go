@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// clientOutdated is the body of the 426 returned to outdated clients so
+// they can tell users how to upgrade
+type clientOutdated struct {
+	Error        string `json:"error"`
+	Message      string `json:"message"`
+	Client       string `json:"client"`
+	Version      string `json:"version"`
+	MinVersion   string `json:"min_version"`
+	RoutePrefix  string `json:"route_prefix"`
+	Instructions string `json:"instructions,omitempty"`
+}
+
+func writeClientRuleError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrClientRuleDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrInvalidClientRule:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// clientGate refuses clients older than the client rules allow with 426;
+// requests are let through when the rules cannot be loaded
+func (a *Api) clientGate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := a.manager.CheckClient(r.UserAgent(), r.URL.Path)
+		if err == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cErr, ok := err.(*manager.ClientError)
+		if !ok {
+			log.Errorf("error checking client %q: %s", r.UserAgent(), err)
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("content-type", "application/json")
+		w.Header().Set("Upgrade", cErr.Rule.Client+"/"+cErr.Rule.MinVersion)
+		w.WriteHeader(http.StatusUpgradeRequired)
+
+		if err := json.NewEncoder(w).Encode(&clientOutdated{
+			Error:        "client_outdated",
+			Message:      cErr.Error(),
+			Client:       cErr.Rule.Client,
+			Version:      cErr.Version,
+			MinVersion:   cErr.Rule.MinVersion,
+			RoutePrefix:  cErr.Rule.RoutePrefix,
+			Instructions: cErr.Rule.Instructions,
+		}); err != nil {
+			log.Errorf("error writing client response: %s", err)
+		}
+	})
+}
+
+func (a *Api) clientRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	rules, err := a.manager.ClientRules()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) clientRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	rule, err := a.manager.ClientRule(mux.Vars(r)["id"])
+	if err != nil {
+		writeClientRuleError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// saveClientRule creates a rule or, with an id in the route, replaces it
+func (a *Api) saveClientRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var rule *shipyard.ClientRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule.ID = mux.Vars(r)["id"]
+	rule.CreatedBy = getUsername(r)
+
+	if err := a.manager.SaveClientRule(rule); err != nil {
+		writeClientRuleError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteClientRule(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.DeleteClientRule(mux.Vars(r)["id"]); err != nil {
+		writeClientRuleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientGate(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(api.clientGate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer ts.Close()
+
+	get := func(path, userAgent string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	res := get("/api/stacks", "shipyard-cli/1.0.0")
+	assert.Equal(t, http.StatusUpgradeRequired, res.StatusCode, "expected the outdated client to be refused")
+	assert.Equal(t, "shipyard-cli/3.1.0", res.Header.Get("Upgrade"))
+
+	body := &clientOutdated{}
+	if err := json.NewDecoder(res.Body).Decode(body); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "client_outdated", body.Error)
+	assert.Equal(t, "1.0.0", body.Version)
+	assert.Equal(t, "3.1.0", body.MinVersion)
+	assert.Equal(t, "run shipyard-cli upgrade", body.Instructions)
+
+	res = get("/api/events", "shipyard-cli/1.0.0")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected other routes to be let through")
+
+	res = get("/api/stacks", "curl/7.47.0")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected other clients to be let through")
+}
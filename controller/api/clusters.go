@@ -0,0 +1,234 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/middleware/access"
+)
+
+// clusterHeader selects the cluster a request operates against
+const clusterHeader = access.ClusterHeader
+
+// selectedCluster returns the cluster selected by the request (see
+// access.SelectedCluster); empty for the default cluster
+func selectedCluster(r *http.Request) string {
+	return access.SelectedCluster(r)
+}
+
+// selectedClusterName returns the name of the cluster selected by the
+// request, including the default cluster
+func selectedClusterName(r *http.Request) string {
+	if name := selectedCluster(r); name != "" {
+		return name
+	}
+
+	return shipyard.DefaultClusterName
+}
+
+// clusterManager returns the manager of the cluster selected by the
+// request; it writes the error when the cluster does not exist
+func (a *Api) clusterManager(w http.ResponseWriter, r *http.Request) (manager.Manager, bool) {
+	m, err := a.manager.ForCluster(selectedCluster(r))
+	if err != nil {
+		writeClusterError(w, err)
+		return nil, false
+	}
+
+	return m, true
+}
+
+// clusterPrefixHandler serves /clusters/<name>/<path> as /<path> with the
+// cluster selected so docker clients can be pointed at a cluster
+func clusterPrefixHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/clusters/"), "/", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		r.Header.Set(clusterHeader, parts[0])
+		r.URL.Path = "/" + parts[1]
+		r.URL.RawPath = ""
+		r.RequestURI = r.URL.RequestURI()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clusterProxies are the docker proxies of the added clusters
+type clusterProxies struct {
+	mu      sync.Mutex
+	proxies map[string]*swarmProxy
+}
+
+func newClusterProxies() *clusterProxies {
+	return &clusterProxies{
+		proxies: map[string]*swarmProxy{},
+	}
+}
+
+// clusterProxy returns the docker proxy of the cluster selected by the
+// request; the proxy is recreated when the endpoint of the cluster changes
+func (a *Api) clusterProxy(r *http.Request) (*swarmProxy, error) {
+	name := selectedCluster(r)
+	if name == "" || name == shipyard.DefaultClusterName {
+		return a.defaultProxy()
+	}
+
+	m, err := a.manager.ForCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	client := m.DockerClient()
+
+	a.clusterProxies.mu.Lock()
+	defer a.clusterProxies.mu.Unlock()
+
+	if p, ok := a.clusterProxies.proxies[name]; ok && p.targets(client) {
+		return p, nil
+	}
+
+	p, err := newSwarmProxy(client)
+	if err != nil {
+		return nil, err
+	}
+	a.clusterProxies.proxies[name] = p
+
+	return p, nil
+}
+
+// defaultProxy returns the docker proxy of the default cluster; it is
+// updated when another controller changed the endpoint of the cluster
+func (a *Api) defaultProxy() (*swarmProxy, error) {
+	client := a.manager.DockerClient()
+	if client != nil && !a.proxy.targets(client) {
+		if err := a.proxy.update(client); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.proxy, nil
+}
+
+func writeClusterError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*manager.ClusterUnreachableError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch err {
+	case manager.ErrClusterDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrClusterExists, manager.ErrDefaultCluster:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrInvalidClusterName, manager.ErrDockerURLRequired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Errorf("error managing clusters: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// withoutClusterKey returns a copy of the cluster without its TLS key for
+// responses
+func withoutClusterKey(cluster *shipyard.Cluster) *shipyard.Cluster {
+	c := *cluster
+	c.TLSKey = ""
+	return &c
+}
+
+func (a *Api) clusters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	clusters, err := a.manager.Clusters()
+	if err != nil {
+		writeClusterError(w, err)
+		return
+	}
+
+	for i, c := range clusters {
+		clusters[i] = withoutClusterKey(c)
+	}
+
+	if err := json.NewEncoder(w).Encode(clusters); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) cluster(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	cluster, err := a.manager.Cluster(mux.Vars(r)["name"])
+	if err != nil {
+		writeClusterError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(withoutClusterKey(cluster)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// addCluster adds the cluster in the body with its endpoint and TLS
+// material
+func (a *Api) addCluster(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var cluster *shipyard.Cluster
+	if err := json.NewDecoder(r.Body).Decode(&cluster); err != nil || cluster == nil {
+		http.Error(w, "invalid cluster", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.AddCluster(cluster, getUsername(r)); err != nil {
+		writeClusterError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(withoutClusterKey(cluster)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// replaceCluster changes the endpoint of the cluster of the path
+func (a *Api) replaceCluster(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var cluster *shipyard.Cluster
+	if err := json.NewDecoder(r.Body).Decode(&cluster); err != nil || cluster == nil {
+		http.Error(w, "invalid cluster", http.StatusBadRequest)
+		return
+	}
+	cluster.Name = mux.Vars(r)["name"]
+
+	if err := a.manager.ReplaceCluster(cluster, getUsername(r)); err != nil {
+		writeClusterError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(withoutClusterKey(cluster)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) removeCluster(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.RemoveCluster(mux.Vars(r)["name"], getUsername(r)); err != nil {
+		writeClusterError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getClustersRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/clusters", api.clusters).Methods("GET")
+	router.HandleFunc("/api/clusters", api.addCluster).Methods("POST")
+	router.HandleFunc("/api/clusters/{name}", api.cluster).Methods("GET")
+	router.HandleFunc("/api/clusters/{name}", api.replaceCluster).Methods("PUT")
+	router.HandleFunc("/api/clusters/{name}", api.removeCluster).Methods("DELETE")
+	router.HandleFunc("/api/nodes", api.nodes).Methods("GET")
+
+	return router
+}
+
+func TestApiClusters(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := getClustersRouter(api)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/clusters", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var clusters []*shipyard.Cluster
+	if err := json.NewDecoder(res.Body).Decode(&clusters); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(clusters))
+	assert.Equal(t, mock_test.TestCluster.Name, clusters[1].Name)
+	assert.Equal(t, "", clusters[1].TLSKey, "expected the tls key not to be returned")
+
+	for _, c := range []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/api/clusters/staging", "", http.StatusOK},
+		{"GET", "/api/clusters/unknown", "", http.StatusNotFound},
+		{"POST", "/api/clusters", `{"name":"production","docker_url":"tcp://prod.local:2376"}`, http.StatusCreated},
+		{"POST", "/api/clusters", `{"name":"staging","docker_url":"tcp://staging.local:2376"}`, http.StatusConflict},
+		{"POST", "/api/clusters", `{"name":"production"}`, http.StatusBadRequest},
+		{"PUT", "/api/clusters/staging", `{"docker_url":"tcp://staging.local:2377"}`, http.StatusOK},
+		{"PUT", "/api/clusters/unknown", `{"docker_url":"tcp://unknown.local:2376"}`, http.StatusNotFound},
+		{"DELETE", "/api/clusters/default", "", http.StatusConflict},
+		{"DELETE", "/api/clusters/staging", "", http.StatusNoContent},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, c.path, bytes.NewBufferString(c.body))
+		router.ServeHTTP(res, req)
+
+		assert.Equal(t, c.status, res.Code, c.method+" "+c.path)
+	}
+}
+
+func TestApiNodesSelectedCluster(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := getClustersRouter(api)
+
+	for cluster, status := range map[string]int{
+		"":        http.StatusOK,
+		"staging": http.StatusOK,
+		"unknown": http.StatusNotFound,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/nodes", nil)
+		req.Header.Set(clusterHeader, cluster)
+		router.ServeHTTP(res, req)
+
+		assert.Equal(t, status, res.Code, cluster)
+	}
+}
+
+func TestClusterPrefixHandler(t *testing.T) {
+	var cluster, path string
+	handler := clusterPrefixHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cluster = r.Header.Get(clusterHeader)
+		path = r.URL.RequestURI()
+	}))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/clusters/staging/v1.24/containers/json?all=1", nil)
+	handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "staging", cluster)
+	assert.Equal(t, "/v1.24/containers/json?all=1", path)
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/clusters/staging", nil)
+	handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/nu7hatch/gouuid"
 	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
 )
 
 func (a *Api) createConsoleSession(w http.ResponseWriter, r *http.Request) {
@@ -27,6 +28,14 @@ func (a *Api) createConsoleSession(w http.ResponseWriter, r *http.Request) {
 	cs := &shipyard.ConsoleSession{
 		ContainerID: containerId,
 		Token:       token,
+		Username:    getUsername(r),
+	}
+
+	// service keys take priority over access tokens in the auth
+	// middleware so the user of the token was not verified
+	if key := r.Header.Get("X-Service-Key"); key != "" {
+		cs.Username = ""
+		cs.ServiceKeyID = auth.KeyID(key)
 	}
 
 	if err := a.manager.CreateConsoleSession(cs); err != nil {
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
 	"github.com/shipyard/shipyard/controller/mock_test"
 	"github.com/stretchr/testify/assert"
 )
@@ -57,6 +58,38 @@ func TestApiPostConsoleSessions(t *testing.T) {
 	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
 }
 
+func TestApiPostConsoleSessionServiceKey(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.createConsoleSession))
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Service-Key", "ci-key")
+	req.Header.Set("X-Access-Token", "admin:abc")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	cs := &shipyard.ConsoleSession{}
+	if err := json.NewDecoder(res.Body).Decode(&cs); err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is used by the auth middleware so the token is ignored
+	assert.Equal(t, "", cs.Username, "expected no user")
+	assert.Equal(t, auth.KeyID("ci-key"), cs.ServiceKeyID, "expected the id of the key")
+}
+
 func TestApiDeleteConsoleSession(t *testing.T) {
 	api, err := getTestApi()
 	if err != nil {
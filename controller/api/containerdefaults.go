@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeContainerDefaultsError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrContainerDefaultsNotSet:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrInvalidContainerDefaults:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Errorf("error managing the container defaults: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *Api) containerDefaults(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	defaults, err := a.manager.ContainerDefaults()
+	if err != nil {
+		writeContainerDefaultsError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(defaults); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// setContainerDefaults replaces the env vars and labels added to the
+// containers of stacks and templates
+func (a *Api) setContainerDefaults(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var defaults *shipyard.ContainerDefaults
+	if err := json.NewDecoder(r.Body).Decode(&defaults); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defaults, err := a.manager.SetContainerDefaults(defaults, getUsername(r))
+	if err != nil {
+		writeContainerDefaultsError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(defaults); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) removeContainerDefaults(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.RemoveContainerDefaults(getUsername(r)); err != nil {
+		writeContainerDefaultsError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
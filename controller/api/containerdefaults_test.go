@@ -0,0 +1,50 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerDefaults(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/container-defaults", nil)
+	api.containerDefaults(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var defaults *shipyard.ContainerDefaults
+	if err := json.NewDecoder(res.Body).Decode(&defaults); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "http://proxy.prod.local:3128", defaults.Environments["production"].Env["HTTP_PROXY"])
+
+	for body, status := range map[string]int{
+		`{"env":{"TZ":"UTC"}}`: http.StatusOK,
+		`{"env":{"":"UTC"}}`:   http.StatusBadRequest,
+		`not json`:             http.StatusBadRequest,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/container-defaults", bytes.NewBufferString(body))
+		api.setContainerDefaults(res, req)
+
+		assert.Equal(t, status, res.Code, body)
+	}
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/container-defaults", nil)
+	api.removeContainerDefaults(res, req)
+
+	assert.Equal(t, http.StatusNoContent, res.Code)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+	"golang.org/x/net/websocket"
+)
+
+// containerScope returns the containers the user of the request may see;
+// nil when unrestricted, as for service keys and whitelisted addresses
+func (a *Api) containerScope(r *http.Request) (auth.LabelScope, error) {
+	username := getUsername(r)
+	if username == "" {
+		return nil, nil
+	}
+
+	acct, err := a.manager.Account(username)
+	if err != nil || acct == nil {
+		return nil, err
+	}
+
+	acls, err := a.manager.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.ContainerScope(acct, acls), nil
+}
+
+// deltaInScope reports whether the delta is about a container in the
+// scope; destroys only have the id so they are sent for the containers
+// the client was sent before, which are kept in seen
+func deltaInScope(scope auth.LabelScope, seen map[string]bool, d *manager.ContainerDelta) bool {
+	if scope == nil || d.Action == manager.ContainerResync {
+		return true
+	}
+
+	if d.Action == manager.ContainerDestroyed {
+		ok := seen[d.ID]
+		delete(seen, d.ID)
+		return ok
+	}
+
+	if d.Container == nil || !scope.Matches(d.Container.Labels) {
+		return false
+	}
+
+	seen[d.ID] = true
+	return true
+}
+
+// containerUpdates pushes the changes of the container list (create, start,
+// die and destroy) as json messages so the container list stays current
+// without polling. A resync message, or the connection closing, means
+// changes were missed and the containers have to be reloaded. Accounts
+// restricted to labelled containers only get the changes of those.
+func (a *Api) containerUpdates(ws *websocket.Conn) {
+	defer ws.Close()
+
+	scope, err := a.containerScope(ws.Request())
+	if err != nil {
+		log.Errorf("error loading the container scope: %s", err)
+		return
+	}
+	seen := map[string]bool{}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	deltas := a.manager.WatchContainers(done)
+
+	// the client only listens; the read ends when it goes away
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case d, ok := <-deltas:
+			if !ok {
+				return
+			}
+
+			if !deltaInScope(scope, seen, d) {
+				continue
+			}
+
+			if err := websocket.JSON.Send(ws, d); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// scopedUpdatesManager has accounts restricted to team=web and sends
+// the changes of a web and a db container
+type scopedUpdatesManager struct {
+	mock_test.MockManager
+}
+
+func (m *scopedUpdatesManager) Account(username string) (*auth.Account, error) {
+	return &auth.Account{Username: username, LabelScope: auth.LabelScope{"team=web"}}, nil
+}
+
+func (m *scopedUpdatesManager) WatchContainers(done <-chan struct{}) <-chan *manager.ContainerDelta {
+	deltas := make(chan *manager.ContainerDelta)
+	go func() {
+		defer close(deltas)
+		for _, d := range []*manager.ContainerDelta{
+			{Action: manager.ContainerStarted, ID: "db", Container: &dockerclient.Container{Id: "db", Labels: map[string]string{"team": "db"}}},
+			{Action: manager.ContainerDestroyed, ID: "db"},
+			{Action: manager.ContainerStarted, ID: "web", Container: &dockerclient.Container{Id: "web", Labels: map[string]string{"team": "web"}}},
+			{Action: manager.ContainerDestroyed, ID: "web"},
+		} {
+			select {
+			case deltas <- d:
+			case <-done:
+				return
+			}
+		}
+		<-done
+	}()
+
+	return deltas
+}
+
+func TestContainerUpdatesScope(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.manager = &scopedUpdatesManager{}
+
+	router := mux.NewRouter()
+	router.Handle("/api/ws/containers", websocketHandler(api.containerUpdates)).Methods("GET")
+
+	ts := httptest.NewServer(authenticated(router))
+	defer ts.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/api/ws/containers?access_token=alice:token", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	for _, action := range []string{manager.ContainerStarted, manager.ContainerDestroyed} {
+		d := &manager.ContainerDelta{}
+		if err := websocket.JSON.Receive(ws, d); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, action, d.Action, "expected the deltas in order")
+		assert.Equal(t, "web", d.ID, "expected only the container in scope")
+	}
+}
+
+func TestContainerUpdates(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/api/ws/containers", websocketHandler(api.containerUpdates)).Methods("GET")
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/api/ws/containers", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	for _, action := range []string{manager.ContainerStarted, manager.ContainerDied} {
+		d := &manager.ContainerDelta{}
+		if err := websocket.JSON.Receive(ws, d); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, action, d.Action, "expected the deltas in order")
+		assert.Equal(t, mock_test.TestContainerId, d.ID, "expected the id of the container")
+	}
+}
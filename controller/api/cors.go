@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowHeaders = "Origin, X-Requested-With, Content-Type, Accept, X-Access-Token, X-Service-Key"
+	corsAllowMethods = "GET, HEAD, POST, DELETE, PUT, OPTIONS"
+)
+
+// corsOrigin returns the value of Access-Control-Allow-Origin for the
+// origin or "" when it is not allowed; * allows every origin
+func (a *Api) corsOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+
+	for _, allowed := range a.corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// writeCorsHeaders adds the cors headers when the origin of the request is
+// allowed and reports whether it was
+func (a *Api) writeCorsHeaders(w http.ResponseWriter, r *http.Request) bool {
+	origin := a.corsOrigin(r.Header.Get("Origin"))
+	if origin == "" {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if origin != "*" {
+		w.Header().Add("Vary", "Origin")
+	}
+	w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+	w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+
+	return true
+}
+
+// corsHandler adds the cors headers to the responses of every route and
+// answers the preflight requests of allowed origins, which carry no
+// credentials, before authentication
+func (a *Api) corsHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.writeCorsHeaders(w, r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorsHandler(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(api.corsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer ts.Close()
+
+	do := func(method, origin string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+"/api/events", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", origin)
+		if method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "DELETE")
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	res := do("GET", "https://ops.example.com")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "https://ops.example.com", res.Header.Get("Access-Control-Allow-Origin"), "expected the allowed origin")
+	assert.Equal(t, "Origin", res.Header.Get("Vary"))
+
+	res = do("OPTIONS", "https://ops.example.com")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected the preflight to be answered")
+	assert.Contains(t, res.Header.Get("Access-Control-Allow-Headers"), "X-Access-Token")
+
+	res = do("GET", "https://evil.example.com")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "", res.Header.Get("Access-Control-Allow-Origin"), "expected other origins not to be allowed")
+
+	res = do("OPTIONS", "https://evil.example.com")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected the preflight of other origins to reach the routes")
+}
+
+func TestCorsOriginWildcard(t *testing.T) {
+	api := &Api{corsOrigins: []string{"*"}}
+
+	assert.Equal(t, "*", api.corsOrigin("https://ops.example.com"))
+	assert.Equal(t, "", api.corsOrigin(""), "expected requests without an origin to get no headers")
+}
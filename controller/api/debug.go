@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeDebugError(w http.ResponseWriter, err error) {
+	switch err {
+	case dockerclient.ErrNotFound:
+		http.Error(w, "container not found", http.StatusNotFound)
+	case manager.ErrDebugTargetRequired, manager.ErrDebugImageNotApproved, manager.ErrInvalidDebugTTL:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case manager.ErrDebugDisabled, manager.ErrDebugTargetNotRunning:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrDebugNotAllowed:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Errorf("error launching debug container: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// launchDebugContainer starts an approved debug image next to the
+// container in the body; users then exec into the debug container
+func (a *Api) launchDebugContainer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	// debug containers are launched by people, who are recorded with them
+	username := getUsername(r)
+	if username == "" {
+		http.Error(w, "debug containers are launched by users", http.StatusForbidden)
+		return
+	}
+
+	var launch *manager.DebugLaunch
+	if err := json.NewDecoder(r.Body).Decode(&launch); err != nil || launch == nil {
+		http.Error(w, "invalid debug launch", http.StatusBadRequest)
+		return
+	}
+
+	debug, err := a.manager.LaunchDebugContainer(launch, username)
+	if err != nil {
+		writeDebugError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(debug); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiLaunchDebugContainer(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/debug/launch", api.launchDebugContainer).Methods("POST")
+	ts := httptest.NewServer(authenticated(router))
+	defer ts.Close()
+
+	res := totpRequest(t, "POST", ts.URL+"/api/debug/launch", "alice", `{"container":"`+mock_test.TestContainerId+`","reason":"dns"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var debug *manager.DebugContainer
+	if err := json.NewDecoder(res.Body).Decode(&debug); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, mock_test.TestDebugImage, debug.Image)
+	assert.Equal(t, "alice", debug.Username)
+
+	for body, status := range map[string]int{
+		`{"container":""}`:        http.StatusBadRequest,
+		`{"container":"unknown"}`: http.StatusNotFound,
+		`{"container":"` + mock_test.TestContainerId + `","image":"alpine"}`: http.StatusBadRequest,
+		`not json`: http.StatusBadRequest,
+	} {
+		res := totpRequest(t, "POST", ts.URL+"/api/debug/launch", "alice", body)
+		assert.Equal(t, status, res.StatusCode, body)
+	}
+
+	res = totpRequest(t, "POST", ts.URL+"/api/debug/launch", "", `{"container":"`+mock_test.TestContainerId+`"}`)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "expected requests without a user to be refused")
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeDemoError(w http.ResponseWriter, err error) {
+	switch err.(type) {
+	case *manager.ComposeError, *manager.TemplateError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case *manager.FreezeError:
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	}
+
+	switch err {
+	case manager.ErrDemoSeeded:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// seedDemo populates the install with demo accounts, roles, templates and
+// a stack; the passwords of the accounts are in the response
+func (a *Api) seedDemo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	result, err := a.manager.SeedDemo(getUsername(r))
+	if err != nil {
+		writeDemoError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) teardownDemo(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.TeardownDemo(getUsername(r)); err != nil {
+		writeDemoError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func getDemoRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/seed-demo", api.seedDemo).Methods("POST")
+	router.HandleFunc("/api/admin/seed-demo", api.teardownDemo).Methods("DELETE")
+
+	return router
+}
+
+func TestApiSeedDemo(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getDemoRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/admin/seed-demo", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected response code 201")
+
+	result := &manager.DemoResult{}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "demo", result.Stack)
+	assert.NotEqual(t, "", result.Accounts[0].Password, "expected the demo passwords")
+
+	req, err := http.NewRequest("DELETE", ts.URL+"/api/admin/seed-demo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
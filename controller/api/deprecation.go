@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/metrics"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+	"github.com/shipyard/shipyard/utils"
+)
+
+const (
+	// apiV1Prefix is the versioned prefix of the Shipyard api; the
+	// unversioned /api/ is deprecated
+	apiV1Prefix = "/api/v1/"
+	// minDockerAPIVersion is the oldest docker api version proxied without
+	// deprecation; older clients predate networks and volumes
+	minDockerAPIVersion = "1.21"
+	// maxDeprecationClients bounds the usage kept as the clients are not
+	// authenticated yet
+	maxDeprecationClients = 1000
+
+	deprecatedShipyardAPI = "shipyard-api"
+	deprecatedDockerAPI   = "docker-api"
+)
+
+var (
+	deprecatedRequests = metrics.Default.NewCounter("shipyard_deprecated_requests_total",
+		"Requests to deprecated routes by kind.", "kind")
+)
+
+type (
+	// deprecatedUsage is how often a client used a kind of deprecated
+	// route; the client is the service key, user or address it claims
+	deprecatedUsage struct {
+		Kind      string    `json:"kind"`
+		Client    string    `json:"client"`
+		UserAgent string    `json:"user_agent"`
+		Count     int64     `json:"count"`
+		LastPath  string    `json:"last_path"`
+		FirstSeen time.Time `json:"first_seen"`
+		LastSeen  time.Time `json:"last_seen"`
+	}
+
+	// deprecationTracker marks the responses of deprecated routes and
+	// counts their usage per client since the controller started
+	deprecationTracker struct {
+		sunset time.Time
+		mu     sync.Mutex
+		usage  map[string]*deprecatedUsage
+	}
+)
+
+func newDeprecationTracker(sunset time.Time) *deprecationTracker {
+	return &deprecationTracker{
+		sunset: sunset,
+		usage:  map[string]*deprecatedUsage{},
+	}
+}
+
+// requestClient identifies the client of a request from its credentials;
+// service keys are named by their id. Deprecated routes are recorded
+// before authentication, so the username of the access token only names
+// the client in reports.
+func requestClient(r *http.Request) string {
+	if key := r.Header.Get("X-Service-Key"); key != "" {
+		return "service-key:" + auth.KeyID(key)
+	}
+
+	if username := getUsername(r); username != "" {
+		return "user:" + username
+	}
+
+	if tk, err := auth.GetAccessToken(mAuth.AccessToken(r)); err == nil {
+		return "user:" + tk.Username
+	}
+
+	return "addr:" + utils.RemoteIP(r.RemoteAddr)
+}
+
+// deprecation returns the kind of deprecated route of the request and the
+// route replacing it, if any
+func deprecation(path string) (string, string) {
+	if strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, apiV1Prefix) {
+		return deprecatedShipyardAPI, apiV1Prefix + strings.TrimPrefix(path, "/api/")
+	}
+
+	if match := apiVersionPath.FindStringSubmatch(path); match != nil && apiVersionNewer(minDockerAPIVersion, match[1]+"."+match[2]) {
+		return deprecatedDockerAPI, ""
+	}
+
+	return "", ""
+}
+
+// record counts the request to the deprecated route
+func (d *deprecationTracker) record(kind string, r *http.Request, now time.Time) {
+	deprecatedRequests.Inc(kind)
+
+	client := requestClient(r)
+	userAgent := r.UserAgent()
+	k := kind + "|" + client + "|" + userAgent
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	u, ok := d.usage[k]
+	if !ok {
+		if len(d.usage) >= maxDeprecationClients {
+			return
+		}
+
+		u = &deprecatedUsage{
+			Kind:      kind,
+			Client:    client,
+			UserAgent: userAgent,
+			FirstSeen: now,
+		}
+		d.usage[k] = u
+	}
+
+	u.Count++
+	u.LastPath = r.URL.Path
+	u.LastSeen = now
+}
+
+// list returns the usage, most used first
+func (d *deprecationTracker) list() []*deprecatedUsage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	usage := []*deprecatedUsage{}
+	for _, u := range d.usage {
+		c := *u
+		usage = append(usage, &c)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Count != usage[j].Count {
+			return usage[i].Count > usage[j].Count
+		}
+
+		return usage[i].Client < usage[j].Client
+	})
+
+	return usage
+}
+
+// handler serves the versioned Shipyard api as the unversioned one and
+// adds the Deprecation, Sunset and successor Link headers to requests to
+// deprecated routes
+func (d *deprecationTracker) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, apiV1Prefix) {
+			r.URL.Path = "/api/" + strings.TrimPrefix(r.URL.Path, apiV1Prefix)
+			r.URL.RawPath = ""
+			r.RequestURI = r.URL.RequestURI()
+
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		kind, successor := deprecation(r.URL.Path)
+		if kind != "" {
+			d.record(kind, r, time.Now())
+
+			w.Header().Set("Deprecation", "true")
+			if !d.sunset.IsZero() {
+				w.Header().Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+			}
+			if successor != "" {
+				w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// deprecatedUsage lists the clients still using deprecated routes so their
+// automations can be updated before the routes are removed
+func (a *Api) deprecatedUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(a.deprecations.list()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecationHandler(t *testing.T) {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newDeprecationTracker(sunset)
+
+	ts := httptest.NewServer(tracker.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})))
+	defer ts.Close()
+
+	get := func(path string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Access-Token", "alice:abc")
+		req.Header.Set("User-Agent", "deploy-script")
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	res := get("/api/v1/events")
+	assert.Equal(t, "", res.Header.Get("Deprecation"), "expected the versioned api not to be deprecated")
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/api/events", string(body), "expected the versioned api to serve the api")
+
+	res = get("/api/events?limit=1")
+	assert.Equal(t, "true", res.Header.Get("Deprecation"))
+	assert.Equal(t, "Fri, 01 Jan 2027 00:00:00 GMT", res.Header.Get("Sunset"))
+	assert.Equal(t, `</api/v1/events>; rel="successor-version"`, res.Header.Get("Link"))
+
+	res = get("/v1.18/info")
+	assert.Equal(t, "true", res.Header.Get("Deprecation"), "expected old docker api versions to be deprecated")
+
+	for _, path := range []string{"/v1.24/info", "/containers/json", "/auth/login"} {
+		res = get(path)
+		assert.Equal(t, "", res.Header.Get("Deprecation"), path)
+	}
+
+	get("/api/events")
+
+	usage := tracker.list()
+	if len(usage) != 2 {
+		t.Fatalf("expected usage of 2 deprecated kinds; received %d", len(usage))
+	}
+
+	assert.Equal(t, deprecatedShipyardAPI, usage[0].Kind)
+	assert.Equal(t, "user:alice", usage[0].Client)
+	assert.Equal(t, "deploy-script", usage[0].UserAgent)
+	assert.Equal(t, int64(2), usage[0].Count)
+	assert.Equal(t, deprecatedDockerAPI, usage[1].Kind)
+}
@@ -2,34 +2,309 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/controller/manager"
 )
 
+const (
+	// eventStreamKeepAlive is how often a comment is sent on an idle event
+	// stream so proxies do not close it
+	eventStreamKeepAlive = 30 * time.Second
+	// eventStreamRetry is how long clients wait before reconnecting
+	eventStreamRetry = 3 * time.Second
+	// eventResumePage is how many missed events are read at once when a
+	// stream resumes
+	eventResumePage = 500
+)
+
+// pageParams returns the offset and limit query parameters; both default
+// to zero which returns every result
+func pageParams(r *http.Request) (int, int, error) {
+	values := []int{0, 0}
+	for i, name := range []string{"offset", "limit"} {
+		s := r.FormValue(name)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %s", name, err)
+		}
+		values[i] = n
+	}
+
+	return values[0], values[1], nil
+}
+
+// sortParams returns the sort and order query parameters checked against
+// the fields of the list; desc is the default order
+func sortParams(r *http.Request, fields []string, desc bool) (*manager.Sort, error) {
+	return manager.ParseSort(r.FormValue("sort"), r.FormValue("order"), fields, desc)
+}
+
+// timeParam returns the RFC 3339 time of the query parameter; the zero
+// time is returned when it is not set
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %s", name, err)
+	}
+
+	return t, nil
+}
+
+// eventInScope reports whether the event may be seen with the scope;
+// events of containers out of the scope are left out
+func eventInScope(scope auth.LabelScope, evt *shipyard.Event) bool {
+	if scope == nil || evt.ContainerInfo == nil {
+		return true
+	}
+
+	labels := map[string]string{}
+	if evt.ContainerInfo.Config != nil {
+		labels = evt.ContainerInfo.Config.Labels
+	}
+
+	return scope.Matches(labels)
+}
+
+// events returns events newest first; they can be filtered by type,
+// container id prefix, username and a time range with since and until and
+// are paged with offset and limit. Passing the time of the last event of a
+// page as until returns the next page even while new events are logged.
+// sort=type or username and order=asc change the order. Accounts limited
+// to container labels do not get the events of other containers, so their
+// pages can be shorter than the limit.
 func (a *Api) events(w http.ResponseWriter, r *http.Request) {
+	if follow, _ := strconv.ParseBool(r.FormValue("follow")); follow {
+		a.streamEvents(w, r)
+		return
+	}
+
 	w.Header().Set("content-type", "application/json")
 
-	limit := -1
-	l := r.FormValue("limit")
-	if l != "" {
-		lt, err := strconv.Atoi(l)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	scope, err := a.containerScope(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := &datastore.EventQuery{
+		Type:        r.FormValue("type"),
+		ContainerId: r.FormValue("container"),
+		Username:    r.FormValue("username"),
+	}
+
+	if query.After, err = timeParam(r, "since"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if query.Before, err = timeParam(r, "until"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if query.Offset, query.Limit, err = pageParams(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sort, err := sortParams(r, manager.EventSortFields, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	manager.SortEventQuery(query, sort)
+
+	events, err := a.manager.Events(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	visible := []*shipyard.Event{}
+	for _, evt := range events {
+		if eventInScope(scope, evt) {
+			visible = append(visible, evt)
 		}
-		limit = lt
 	}
-	events, err := a.manager.Events(limit)
+
+	if err := json.NewEncoder(w).Encode(visible); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// streamEvents sends new events as server-sent events; the stream can be
+// filtered by event type and tag. Every event has a token as its id and a
+// stream resumed with the token (the Last-Event-ID header browsers send
+// when reconnecting or ?resume=) first sends the events missed since.
+// The stream is limited to the containers of the account like events.
+func (a *Api) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	scope, err := a.containerScope(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if err := json.NewEncoder(w).Encode(events); err != nil {
+
+	token := r.Header.Get("Last-Event-ID")
+	if token == "" {
+		token = r.FormValue("resume")
+	}
+
+	var resumeAfter time.Time
+	if token != "" {
+		t, err := manager.ParseEventToken(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resumeAfter = t
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// the stream is watched before the missed events are read so none are
+	// lost in between
+	events, err := a.manager.EventStream(done)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry/time.Millisecond)
+	flusher.Flush()
+
+	eventType := r.FormValue("type")
+	tag := r.FormValue("tag")
+
+	// replayed is the time of the last missed event sent; the stream may
+	// return it again
+	replayed := time.Time{}
+	if !resumeAfter.IsZero() {
+		for {
+			missed, err := a.manager.Events(&datastore.EventQuery{
+				Type:      eventType,
+				After:     resumeAfter,
+				Limit:     eventResumePage,
+				Ascending: true,
+			})
+			if err != nil {
+				log.Errorf("error reading missed events: %s", err)
+				return
+			}
+
+			for _, evt := range missed {
+				if !eventMatches(evt, eventType, tag) || !eventInScope(scope, evt) {
+					continue
+				}
+
+				if err := writeStreamEvent(w, evt); err != nil {
+					return
+				}
+			}
+
+			if len(missed) > 0 {
+				resumeAfter = missed[len(missed)-1].Time
+				replayed = resumeAfter
+			}
+
+			if len(missed) < eventResumePage {
+				break
+			}
+		}
+		flusher.Flush()
+	}
+
+	var closed <-chan bool
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed = cn.CloseNotify()
+	}
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if !eventMatches(evt, eventType, tag) || !eventInScope(scope, evt) || (!replayed.IsZero() && !evt.Time.After(replayed)) {
+				continue
+			}
+
+			if err := writeStreamEvent(w, evt); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-closed:
+			return
+		}
+	}
+}
+
+// writeStreamEvent writes the event with its token as the id
+func writeStreamEvent(w io.Writer, evt *shipyard.Event) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		log.Errorf("error encoding event: %s", err)
+		return nil
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", manager.EventToken(evt), evt.Type, data)
+	return err
+}
+
+func eventMatches(evt *shipyard.Event, eventType, tag string) bool {
+	if eventType != "" && evt.Type != eventType {
+		return false
+	}
+
+	if tag == "" {
+		return true
+	}
+
+	for _, t := range evt.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (a *Api) purgeEvents(w http.ResponseWriter, r *http.Request) {
@@ -2,11 +2,17 @@ package api
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/samalba/dockerclient"
 	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,6 +40,39 @@ func TestApiGetEvents(t *testing.T) {
 	assert.NotEqual(t, len(events), 0, "expected events; received none")
 }
 
+func TestApiGetEventsFiltered(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.events))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?type=other-event&since=2016-01-02T15:04:05Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	events := []*shipyard.Event{}
+
+	if err := json.NewDecoder(res.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(events), 0, "expected no events of another type")
+
+	for _, q := range []string{"?limit=ten", "?offset=-", "?until=yesterday", "?sort=node", "?order=up"} {
+		res, err := http.Get(ts.URL + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, res.StatusCode, 400, "expected response code 400 for "+q)
+	}
+}
+
 func TestApiPurgeEvents(t *testing.T) {
 	api, err := getTestApi()
 	if err != nil {
@@ -58,3 +97,110 @@ func TestApiPurgeEvents(t *testing.T) {
 
 	assert.Equal(t, res.StatusCode, 204, "expected response code 204")
 }
+
+func TestApiStreamEvents(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.events))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?follow=true&tag=test-tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	assert.Equal(t, res.Header.Get("content-type"), "text/event-stream", "expected event stream")
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(body), "event: test-event\n", "expected test event in stream")
+}
+
+func TestApiResumeEvents(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.events))
+	defer ts.Close()
+
+	token := manager.EventToken(&shipyard.Event{Time: time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)})
+	req, err := http.NewRequest("GET", ts.URL+"?follow=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(body), "retry: 3000\n", "expected the reconnection delay")
+	assert.Contains(t, string(body), "id: "+manager.EventToken(mock_test.TestEvent)+"\nevent: test-event\n", "expected the missed event with its token")
+
+	res, err = http.Get(ts.URL + "?follow=true&resume=yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 400, "expected invalid tokens to be refused")
+}
+
+// scopedEventsManager has accounts restricted to team=web and events of a
+// web and a db container
+type scopedEventsManager struct {
+	scopedUpdatesManager
+}
+
+func (m *scopedEventsManager) Events(query *datastore.EventQuery) ([]*shipyard.Event, error) {
+	return []*shipyard.Event{
+		{Type: "start", ContainerInfo: &dockerclient.ContainerInfo{Id: "db", Config: &dockerclient.ContainerConfig{Labels: map[string]string{"team": "db"}}}},
+		{Type: "start", ContainerInfo: &dockerclient.ContainerInfo{Id: "web", Config: &dockerclient.ContainerConfig{Labels: map[string]string{"team": "web"}}}},
+		{Type: "login", Username: "alice"},
+	}, nil
+}
+
+func TestApiGetEventsScope(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.manager = &scopedEventsManager{}
+
+	req, err := http.NewRequest("GET", "/api/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Access-Token", "alice:token")
+
+	res := httptest.NewRecorder()
+	authenticated(http.HandlerFunc(api.events)).ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code, "expected response code 200")
+
+	events := []*shipyard.Event{}
+	if err := json.NewDecoder(res.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(events), "expected the events of other containers to be left out")
+	assert.Equal(t, "web", events[0].ContainerInfo.Id, "expected the container in scope")
+	assert.Equal(t, "login", events[1].Type, "expected the events without a container")
+}
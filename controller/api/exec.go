@@ -1,11 +1,20 @@
 package api
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
 	"golang.org/x/net/websocket"
 )
 
@@ -16,7 +25,26 @@ func (a *Api) execContainer(ws *websocket.Conn) {
 	ttyWidth := qry.Get("w")
 	ttyHeight := qry.Get("h")
 	token := qry.Get("token")
+	sessionId := qry.Get("session")
 	cmd := strings.Split(command, ",")
+	// read only sessions attach stdout and stderr only
+	readOnly, _ := strconv.ParseBool(qry.Get("readonly"))
+	record, _ := strconv.ParseBool(qry.Get("record"))
+	// framed clients exchange binary frames prefixed with their stream; the
+	// exec has no tty unless asked for so stderr stays apart from stdout
+	framed := qry.Get("protocol") == execProtocolFramed
+	tty := !framed
+	if framed {
+		tty, _ = strconv.ParseBool(qry.Get("tty"))
+	}
+	client := execClient{ws: ws, framed: framed}
+
+	cs, err := a.manager.ConsoleSession(token)
+	if err != nil {
+		ws.Write([]byte("unauthorized"))
+		ws.Close()
+		return
+	}
 
 	if !a.manager.ValidateConsoleSessionToken(containerId, token) {
 		ws.Write([]byte("unauthorized"))
@@ -24,46 +52,368 @@ func (a *Api) execContainer(ws *websocket.Conn) {
 		return
 	}
 
-	log.Debugf("starting exec session: container=%s cmd=%s", containerId, command)
-	clientUrl := a.manager.DockerClient().URL
+	// join a running session as a viewer
+	if sessionId != "" {
+		a.joinExecSession(client, cs, sessionId)
+		return
+	}
+
+	m, err := a.manager.ForCluster(selectedCluster(ws.Request()))
+	if err != nil {
+		ws.Write([]byte("error: " + err.Error()))
+		ws.Close()
+		return
+	}
+
+	policyReadOnly, err := m.AuthorizeExec(cs, containerId, cmd)
+	if err != nil {
+		log.Warnf("exec denied: username=%s container=%s cmd=%s err=%s", cs.Username, containerId, command, err)
+		ws.Write([]byte("unauthorized: " + err.Error()))
+		ws.Close()
+		return
+	}
+
+	if policyReadOnly {
+		readOnly = true
+	}
+
+	log.Debugf("starting exec session: container=%s cmd=%s readonly=%v tty=%v", containerId, command, readOnly, tty)
+	docker := m.DockerClient()
 
 	execConfig := &dockerclient.ExecConfig{
-		AttachStdin:  true,
+		AttachStdin:  !readOnly,
 		AttachStdout: true,
 		AttachStderr: true,
-		Tty:          true,
+		Tty:          tty,
 		Cmd:          cmd,
 		Container:    containerId,
 		Detach:       true,
 	}
 
-	execId, err := a.manager.DockerClient().ExecCreate(execConfig)
+	execId, err := docker.ExecCreate(execConfig)
 	if err != nil {
-		log.Errorf("error calling exec: %s", err)
+		log.Errorf("error creating exec: container=%s err=%s", containerId, err)
+		client.notice("error: " + err.Error())
 		return
 	}
 
-	if err := a.hijack(clientUrl.Host, "POST", "/exec/"+execId+"/start", true, ws, ws, ws, nil, nil); err != nil {
-		log.Errorf("error during hijack: %s", err)
+	conn, output, err := execStart(docker.URL.Host, a.execTLSConfig(docker), execId, tty)
+	if err != nil {
+		log.Errorf("error starting exec: container=%s err=%s", containerId, err)
+		client.notice("error: " + err.Error())
 		return
 	}
 
-	// resize
-	w, err := strconv.Atoi(ttyWidth)
-	if err != nil {
-		log.Error(err)
+	session := newExecSession(execId, containerId, cmd, cs.Username, client)
+	session.Cluster = selectedClusterName(ws.Request())
+	if record || a.execRecord {
+		session.recorder = newExecRecorder(execId, containerId, cmd, cs.Username, session.Started)
+		client.notice("this session is recorded")
+	}
+	a.execSessions.add(session)
+	defer a.endExecSession(session)
+
+	// the session ends when the websocket closes, the process exits or a
+	// session limit is reached; the exec connection is closed either way
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	monitor := newSessionMonitor(a.sessionLimits, time.Now())
+	if a.sessionLimits.enabled() {
+		warn := session.notice
+		stop := func() {
+			log.Infof("terminating exec session: container=%s username=%s", containerId, cs.Username)
+			warn("session terminated")
+			cancel()
+		}
+		go monitor.run(ctx.Done(), warn, stop)
+	}
+
+	resize := func(w, h int) error {
+		// only a tty has a size
+		if !tty {
+			return nil
+		}
+		session.recorder.resize(w, h)
+		return docker.ExecResize(execId, w, h)
+	}
+
+	if w, err := strconv.Atoi(ttyWidth); err == nil {
+		if h, err := strconv.Atoi(ttyHeight); err == nil {
+			if err := resize(w, h); err != nil {
+				log.Errorf("error resizing exec tty: %s", err)
+			}
+		}
+	}
+
+	var stdin io.Writer = conn
+	if readOnly {
+		// input is dropped so it never reaches the process
+		stdin = nil
+	}
+
+	outputErr := make(chan error, 1)
+	go func() {
+		if tty {
+			_, err := io.Copy(session, output)
+			outputErr <- err
+			return
+		}
+		outputErr <- copyExecStreams(session.writeStream, output)
+	}()
+
+	inputErr := make(chan error, 1)
+	go func() {
+		inputErr <- execInput(client, stdin, resize, monitor)
+	}()
+
+	select {
+	case err := <-outputErr:
+		// the process exited unless the connection broke
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("error reading exec output: container=%s err=%s", containerId, err)
+			client.notice("error: " + err.Error())
+		}
+	case err := <-inputErr:
+		if err != nil {
+			log.Debugf("exec websocket closed: container=%s err=%s", containerId, err)
+		}
+	}
+}
+
+// execProtocolFramed is the ?protocol= of clients using stream frames
+const execProtocolFramed = "framed"
+
+// Streams of the framed exec protocol; each binary frame starts with the
+// stream its payload belongs to.  Clients send stdin and control frames
+// (resizes) and receive stdout, stderr and control frames (notices and
+// errors of shipyard)
+const (
+	execStreamStdin byte = iota
+	execStreamStdout
+	execStreamStderr
+	execStreamControl
+)
+
+var errInvalidExecStream = errors.New("invalid exec output stream")
+
+// execClient is a websocket taking part in an exec session
+type execClient struct {
+	ws     *websocket.Conn
+	framed bool
+}
+
+// send writes the payload of the stream; clients of the old protocol
+// receive it as a text frame without the stream
+func (c execClient) send(stream byte, p []byte) error {
+	if !c.framed {
+		_, err := c.ws.Write(p)
+		return err
+	}
+
+	return websocket.Message.Send(c.ws, append([]byte{stream}, p...))
+}
+
+// notice sends a message of shipyard, such as an error or a session limit
+// warning, apart from the output of the process
+func (c execClient) notice(msg string) error {
+	if c.framed {
+		return c.send(execStreamControl, []byte(msg))
+	}
+
+	return c.send(execStreamStdout, []byte(fmt.Sprintf("\r\n*** %s ***\r\n", msg)))
+}
+
+// copyExecStreams splits the output of an exec without a tty, where docker
+// prefixes each chunk with an 8 byte header of its stream and size, into
+// stdout and stderr
+func copyExecStreams(write func(stream byte, p []byte) (int, error), r io.Reader) error {
+	header := make([]byte, 8)
+	buf := make([]byte, 32*1024)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		stream := header[0]
+		switch stream {
+		case execStreamStdin:
+			// docker writes stdin echoes as stdout
+			stream = execStreamStdout
+		case execStreamStdout, execStreamStderr:
+		default:
+			return errInvalidExecStream
+		}
+
+		for size := int(binary.BigEndian.Uint32(header[4:])); size > 0; {
+			n := size
+			if n > len(buf) {
+				n = len(buf)
+			}
+
+			if _, err := io.ReadFull(r, buf[:n]); err != nil {
+				return err
+			}
+
+			if _, err := write(stream, buf[:n]); err != nil {
+				return err
+			}
+			size -= n
+		}
+	}
+}
+
+// execFrame is a websocket message of an exec session
+type execFrame struct {
+	data   []byte
+	binary bool
+}
+
+// execResize is the control message sent by the client when its terminal
+// changes size
+type execResize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// execCodec receives whole frames with their type; text frames are input
+// for the process and binary frames are control messages
+var execCodec = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		f := v.(*execFrame)
+		f.data = data
+		f.binary = payloadType == websocket.BinaryFrame
+		return nil
+	},
+}
+
+// execInput forwards the input of the websocket to stdin and applies the
+// resizes until the websocket closes; input is dropped without stdin.
+// Without the framed protocol text frames are input and binary frames
+// are control messages; framed clients close stdin with an empty stdin
+// frame
+func execInput(c execClient, stdin io.Writer, resize func(w, h int) error, monitor *sessionMonitor) error {
+	for {
+		var f execFrame
+		if err := execCodec.Receive(c.ws, &f); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		stream, payload := execStreamStdin, f.data
+		switch {
+		case c.framed:
+			if !f.binary || len(f.data) == 0 {
+				log.Warnf("invalid exec frame: %q", f.data)
+				continue
+			}
+			stream, payload = f.data[0], f.data[1:]
+		case f.binary:
+			stream = execStreamControl
+		}
+
+		switch stream {
+		case execStreamControl:
+			var r execResize
+			if err := json.Unmarshal(payload, &r); err != nil || r.Width <= 0 || r.Height <= 0 {
+				log.Warnf("invalid exec control message: %q", payload)
+				continue
+			}
+
+			if err := resize(r.Width, r.Height); err != nil {
+				log.Errorf("error resizing exec tty: %s", err)
+			}
+		case execStreamStdin:
+			monitor.touch(time.Now())
+
+			if stdin == nil {
+				continue
+			}
+
+			if c.framed && len(payload) == 0 {
+				if cw, ok := stdin.(interface {
+					CloseWrite() error
+				}); ok {
+					if err := cw.CloseWrite(); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if _, err := stdin.Write(payload); err != nil {
+				return err
+			}
+		default:
+			log.Warnf("invalid exec stream: %d", stream)
+		}
+	}
+}
+
+// joinExecSession attaches the websocket to a running session on the
+// selected cluster as a viewer until either disconnects; anything the
+// viewer sends is dropped
+func (a *Api) joinExecSession(c execClient, cs *shipyard.ConsoleSession, sessionId string) {
+	session := a.execSessions.get(sessionId)
+	if session == nil || session.ContainerID != cs.ContainerID || session.Cluster != selectedClusterName(c.ws.Request()) {
+		c.ws.Write([]byte("exec session not found"))
+		c.ws.Close()
 		return
 	}
 
-	h, err := strconv.Atoi(ttyHeight)
+	m, err := a.manager.ForCluster(selectedCluster(c.ws.Request()))
 	if err != nil {
-		log.Error(err)
+		c.ws.Write([]byte("error: " + err.Error()))
+		c.ws.Close()
 		return
 	}
 
-	if err := a.manager.DockerClient().ExecResize(execId, w, h); err != nil {
-		log.Errorf("error resizing exec tty: %s", err)
+	if _, err := m.AuthorizeExec(cs, session.ContainerID, session.Command); err != nil {
+		log.Warnf("exec join denied: username=%s session=%s err=%s", cs.Username, sessionId, err)
+		c.ws.Write([]byte("unauthorized: " + err.Error()))
+		c.ws.Close()
 		return
 	}
 
+	log.Debugf("joined exec session: session=%s username=%s", sessionId, cs.Username)
+
+	session.addViewer(c, cs.Username)
+	io.Copy(ioutil.Discard, c.ws)
+	session.removeViewer(c)
+}
+
+// endExecSession removes the session and records it once with everyone
+// that took part
+func (a *Api) endExecSession(session *execSession) {
+	participants := session.participants()
+	a.execSessions.remove(session.ID)
+
+	evt := &shipyard.Event{
+		Type:     "exec-session",
+		Time:     time.Now(),
+		Username: session.Writer,
+		Message: fmt.Sprintf("container=%s cmd=%s participants=%s duration=%s",
+			session.ContainerID, strings.Join(session.Command, " "), strings.Join(participants, ","), time.Since(session.Started)),
+		Tags: []string{"exec"},
+	}
+
+	if err := a.manager.SaveEvent(evt); err != nil {
+		log.Errorf("error recording exec session: %s", err)
+	}
+
+	if session.recorder != nil {
+		if err := a.manager.SaveExecRecording(session.recorder.finish(time.Now())); err != nil {
+			log.Errorf("error saving exec recording: session=%s err=%s", session.ID, err)
+		}
+	}
 }
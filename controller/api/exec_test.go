@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// fakeExecStart answers exec starts like docker and echoes the input
+func fakeExecStart() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/exec/exec-0/start" {
+			http.Error(w, "no such exec instance", http.StatusNotFound)
+			return
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n$ "))
+		io.Copy(conn, conn)
+	}))
+}
+
+func TestExecStart(t *testing.T) {
+	ts := fakeExecStart()
+	defer ts.Close()
+
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	conn, output, err := execStart(addr, nil, "exec-0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ls\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(output, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "$ ls\n", string(buf), "expected the prompt followed by the echoed input")
+
+	if _, _, err := execStart(addr, nil, "unknown", true); err == nil || !strings.Contains(err.Error(), "no such exec instance") {
+		t.Fatalf("expected the docker error; received %v", err)
+	}
+}
+
+type lockedBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
+func TestExecInput(t *testing.T) {
+	stdin := &lockedBuffer{}
+	resizes := make(chan execResize, 1)
+	done := make(chan error, 1)
+
+	ts := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		resize := func(w, h int) error {
+			resizes <- execResize{Width: w, Height: h}
+			return nil
+		}
+		done <- execInput(execClient{ws: ws}, stdin, resize, newSessionMonitor(sessionLimits{}, time.Now()))
+	}))
+	defer ts.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1), "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := websocket.Message.Send(ws, "ls\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// binary frames are control messages and never reach the process
+	if err := websocket.Message.Send(ws, []byte(`{"width":120,"height":40}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-resizes:
+		assert.Equal(t, execResize{Width: 120, Height: 40}, r)
+	case <-time.After(time.Second):
+		t.Fatal("expected a resize")
+	}
+
+	ws.Close()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err, "expected the input to end cleanly when the websocket closes")
+	case <-time.After(time.Second):
+		t.Fatal("expected the input to end when the websocket closes")
+	}
+
+	assert.Equal(t, "ls\n", stdin.String(), "expected only the text frames as input")
+}
+
+type closeWriteBuffer struct {
+	lockedBuffer
+	closed chan struct{}
+}
+
+func (b *closeWriteBuffer) CloseWrite() error {
+	close(b.closed)
+	return nil
+}
+
+func TestExecInputFramed(t *testing.T) {
+	stdin := &closeWriteBuffer{closed: make(chan struct{})}
+	resizes := make(chan execResize, 1)
+
+	ts := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		resize := func(w, h int) error {
+			resizes <- execResize{Width: w, Height: h}
+			return nil
+		}
+		execInput(execClient{ws: ws, framed: true}, stdin, resize, newSessionMonitor(sessionLimits{}, time.Now()))
+	}))
+	defer ts.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1), "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// text frames are not part of the framed protocol and are dropped
+	if err := websocket.Message.Send(ws, "dropped"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frame := range [][]byte{
+		append([]byte{execStreamStdin}, 0xff, 0x00, 0xfe),
+		append([]byte{execStreamControl}, `{"width":80,"height":24}`...),
+		{execStreamStdin},
+	} {
+		if err := websocket.Message.Send(ws, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case r := <-resizes:
+		assert.Equal(t, execResize{Width: 80, Height: 24}, r)
+	case <-time.After(time.Second):
+		t.Fatal("expected a resize")
+	}
+
+	select {
+	case <-stdin.closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected an empty stdin frame to close stdin")
+	}
+
+	assert.Equal(t, "\xff\x00\xfe", stdin.String(), "expected the binary input unchanged")
+}
+
+func TestCopyExecStreams(t *testing.T) {
+	output := &bytes.Buffer{}
+	for _, chunk := range []struct {
+		stream byte
+		data   string
+	}{
+		{execStreamStdout, "out\n"},
+		{execStreamStderr, "err\xff\n"},
+		{execStreamStdout, ""},
+	} {
+		output.Write([]byte{chunk.stream, 0, 0, 0, 0, 0, 0, byte(len(chunk.data))})
+		output.WriteString(chunk.data)
+	}
+
+	streams := map[byte]string{}
+	write := func(stream byte, p []byte) (int, error) {
+		streams[stream] += string(p)
+		return len(p), nil
+	}
+
+	if err := copyExecStreams(write, output); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "out\n", streams[execStreamStdout])
+	assert.Equal(t, "err\xff\n", streams[execStreamStderr])
+
+	truncated := bytes.NewReader([]byte{execStreamStdout, 0, 0, 0, 0, 0, 0, 8, 'a'})
+	assert.Equal(t, io.ErrUnexpectedEOF, copyExecStreams(write, truncated), "expected truncated output to fail")
+
+	invalid := bytes.NewReader([]byte{7, 0, 0, 0, 0, 0, 0, 0})
+	assert.Equal(t, errInvalidExecStream, copyExecStreams(write, invalid))
+}
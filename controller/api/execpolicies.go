@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func (a *Api) execPolicies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	policies, err := a.manager.ExecPolicies()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(policies); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) execPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	policy, err := a.manager.ExecPolicy(id)
+	if err != nil {
+		if err == manager.ErrExecPolicyDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) saveExecPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy *auth.ExecPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.SaveExecPolicy(policy); err != nil {
+		log.Errorf("error saving exec policy: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("saved exec policy: role=%s", policy.Role)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) deleteExecPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.DeleteExecPolicy(id); err != nil {
+		if err == manager.ErrExecPolicyDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("error deleting exec policy: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("removed exec policy: id=%s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// execRecordingLimit is the output kept for a recording; what the session
+// writes past it is left out
+const execRecordingLimit = 8 << 20
+
+// execRecorder keeps the timed output of a recorded exec session
+type execRecorder struct {
+	lock *sync.Mutex
+	rec  *shipyard.ExecRecording
+	size int
+	// pending is the start of a utf-8 character split across writes; it
+	// is recorded with the next write
+	pending []byte
+}
+
+func newExecRecorder(id, containerId string, cmd []string, username string, started time.Time) *execRecorder {
+	return &execRecorder{
+		lock: &sync.Mutex{},
+		rec: &shipyard.ExecRecording{
+			ID:          id,
+			Username:    username,
+			ContainerID: containerId,
+			Command:     cmd,
+			StartedAt:   started,
+			Frames:      []*shipyard.ExecRecordingFrame{},
+		},
+	}
+}
+
+// write records the output as a frame; a nil recorder records nothing
+func (r *execRecorder) write(p []byte, now time.Time) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.rec.Truncated {
+		return
+	}
+
+	data := append(r.pending, p...)
+	r.pending = nil
+
+	// hold back a trailing character the next write completes
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				r.pending = append([]byte{}, data[i:]...)
+				data = data[:i]
+			}
+			break
+		}
+	}
+
+	r.add(data, now)
+}
+
+// add appends a frame unless the recording is full
+func (r *execRecorder) add(data []byte, now time.Time) {
+	if len(data) == 0 {
+		return
+	}
+
+	if r.size+len(data) > execRecordingLimit {
+		r.rec.Truncated = true
+		return
+	}
+	r.size += len(data)
+
+	r.rec.Frames = append(r.rec.Frames, &shipyard.ExecRecordingFrame{
+		Time: now.Sub(r.rec.StartedAt).Seconds(),
+		Data: string(data),
+	})
+}
+
+// resize keeps the largest size of the terminal so players show every
+// frame whole
+func (r *execRecorder) resize(w, h int) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if w > r.rec.Width {
+		r.rec.Width = w
+	}
+	if h > r.rec.Height {
+		r.rec.Height = h
+	}
+}
+
+// finish records what is pending and returns the recording
+func (r *execRecorder) finish(now time.Time) *shipyard.ExecRecording {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.rec.Truncated {
+		r.add(r.pending, now)
+	}
+	r.pending = nil
+	r.rec.Duration = now.Sub(r.rec.StartedAt).Seconds()
+
+	return r.rec
+}
+
+// execRecordings lists the recorded exec sessions newest first
+func (a *Api) execRecordings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	recordings, err := a.manager.ExecRecordings()
+	if err != nil {
+		log.Errorf("error listing exec recordings: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(recordings); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// execRecording downloads the recording of an exec session as an
+// asciicast for asciinema
+func (a *Api) execRecording(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	rec, err := a.manager.ExecRecording(id)
+	if err != nil {
+		if err == manager.ErrExecRecordingDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("error getting exec recording: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/x-asciicast")
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%s.cast", rec.ID))
+
+	if err := rec.WriteAsciicast(w); err != nil {
+		log.Errorf("error writing exec recording: %s", err)
+	}
+}
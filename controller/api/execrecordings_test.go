@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func getExecRecordingsRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/exec/sessions/recorded", api.execRecordings).Methods("GET")
+	router.HandleFunc("/api/exec/sessions/{id}/recording", api.execRecording).Methods("GET")
+
+	return router
+}
+
+func TestExecRecorder(t *testing.T) {
+	started := time.Now()
+	r := newExecRecorder("exec-0", "container-0", []string{"sh"}, "admin", started)
+
+	// é is split across the writes
+	r.write([]byte("caf\xc3"), started.Add(time.Second))
+	r.write([]byte("\xa9\r\n"), started.Add(2*time.Second))
+	r.resize(120, 40)
+	r.resize(80, 24)
+	r.write([]byte("\xe2\x82"), started.Add(3*time.Second))
+
+	rec := r.finish(started.Add(4 * time.Second))
+
+	assert.Equal(t, 3, len(rec.Frames))
+	assert.Equal(t, "caf", rec.Frames[0].Data)
+	assert.Equal(t, 1.0, rec.Frames[0].Time)
+	assert.Equal(t, "é\r\n", rec.Frames[1].Data)
+	assert.Equal(t, 4.0, rec.Duration)
+	assert.Equal(t, 120, rec.Width, "expected the largest width")
+	assert.Equal(t, 40, rec.Height, "expected the largest height")
+	assert.False(t, rec.Truncated)
+}
+
+func TestExecRecorderLimit(t *testing.T) {
+	started := time.Now()
+	r := newExecRecorder("exec-0", "container-0", []string{"sh"}, "admin", started)
+
+	r.write([]byte(strings.Repeat("a", execRecordingLimit-1)), started)
+	r.write([]byte("bb"), started)
+	r.write([]byte("c"), started)
+
+	rec := r.finish(started)
+
+	assert.Equal(t, 1, len(rec.Frames), "expected the output past the limit to be left out")
+	assert.True(t, rec.Truncated)
+}
+
+func TestExecRecorderNil(t *testing.T) {
+	var r *execRecorder
+	r.write([]byte("output"), time.Now())
+	r.resize(80, 24)
+}
+
+func TestApiExecRecordings(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getExecRecordingsRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/exec/sessions/recorded")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	recordings := []*shipyard.ExecRecording{}
+	if err := json.NewDecoder(res.Body).Decode(&recordings); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(recordings))
+	assert.Equal(t, "admin", recordings[0].Username)
+	assert.Equal(t, 1.5, recordings[0].Duration)
+	assert.Nil(t, recordings[0].Frames, "expected recordings to be listed without frames")
+}
+
+func TestApiExecRecording(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getExecRecordingsRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/exec/sessions/exec-0/recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+	assert.Equal(t, "application/x-asciicast", res.Header.Get("content-type"))
+
+	scanner := bufio.NewScanner(res.Body)
+	lines := []string{}
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	assert.Equal(t, 3, len(lines), "expected the header and a line per frame")
+
+	header := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2.0, header["version"])
+	assert.Equal(t, "sh", header["command"])
+	assert.Equal(t, `[1.2,"o","ls\r\n"]`, lines[2])
+}
+
+func TestApiExecRecordingNotFound(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getExecRecordingsRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/exec/sessions/exec-1/recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// execSession is a running exec shared between a single writer and
+	// any number of viewers; output is fanned out to every participant
+	execSession struct {
+		ID          string    `json:"id"`
+		Cluster     string    `json:"cluster"`
+		ContainerID string    `json:"container_id"`
+		Command     []string  `json:"command"`
+		Writer      string    `json:"writer"`
+		Viewers     []string  `json:"viewers"`
+		Started     time.Time `json:"started"`
+
+		lock    *sync.Mutex
+		writer  execClient
+		viewers map[execClient]string
+		// recorder keeps the output of recorded sessions
+		recorder *execRecorder
+	}
+
+	execSessions struct {
+		lock     *sync.Mutex
+		sessions map[string]*execSession
+	}
+)
+
+func newExecSession(id, containerId string, cmd []string, writer string, c execClient) *execSession {
+	return &execSession{
+		ID:          id,
+		ContainerID: containerId,
+		Command:     cmd,
+		Writer:      writer,
+		Started:     time.Now(),
+		lock:        &sync.Mutex{},
+		writer:      c,
+		viewers:     map[execClient]string{},
+	}
+}
+
+// Write sends the output of a tty to the writer and all viewers
+func (s *execSession) Write(p []byte) (int, error) {
+	return s.writeStream(execStreamStdout, p)
+}
+
+// writeStream sends exec output of the stream to the writer and all
+// viewers; viewers that cannot be written to are dropped
+func (s *execSession) writeStream(stream byte, p []byte) (int, error) {
+	s.recorder.write(p, time.Now())
+
+	return len(p), s.each(func(c execClient) error {
+		return c.send(stream, p)
+	})
+}
+
+// notice sends a message of shipyard to the writer and all viewers
+func (s *execSession) notice(msg string) {
+	s.each(func(c execClient) error {
+		return c.notice(msg)
+	})
+}
+
+// each calls fn for the viewers and then the writer and returns the error
+// of the writer
+func (s *execSession) each(fn func(c execClient) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for c := range s.viewers {
+		if err := fn(c); err != nil {
+			delete(s.viewers, c)
+		}
+	}
+
+	return fn(s.writer)
+}
+
+func (s *execSession) addViewer(c execClient, username string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.viewers[c] = username
+}
+
+func (s *execSession) removeViewer(c execClient) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.viewers, c)
+}
+
+// participants returns the writer followed by the viewers
+func (s *execSession) participants() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	viewers := []string{}
+	for _, username := range s.viewers {
+		viewers = append(viewers, username)
+	}
+	sort.Strings(viewers)
+
+	return append([]string{s.Writer}, viewers...)
+}
+
+// snapshot returns a copy of the session with the current viewers
+func (s *execSession) snapshot() *execSession {
+	return &execSession{
+		ID:          s.ID,
+		Cluster:     s.Cluster,
+		ContainerID: s.ContainerID,
+		Command:     s.Command,
+		Writer:      s.Writer,
+		Viewers:     s.participants()[1:],
+		Started:     s.Started,
+	}
+}
+
+// close disconnects all viewers
+func (s *execSession) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for c := range s.viewers {
+		c.ws.Close()
+		delete(s.viewers, c)
+	}
+}
+
+func newExecSessions() *execSessions {
+	return &execSessions{
+		lock:     &sync.Mutex{},
+		sessions: map[string]*execSession{},
+	}
+}
+
+func (e *execSessions) add(s *execSession) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.sessions[s.ID] = s
+}
+
+func (e *execSessions) remove(id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if s, ok := e.sessions[id]; ok {
+		s.close()
+		delete(e.sessions, id)
+	}
+}
+
+func (e *execSessions) get(id string) *execSession {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.sessions[id]
+}
+
+func (e *execSessions) list() []*execSession {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	sessions := []*execSession{}
+	for _, s := range e.sessions {
+		sessions = append(sessions, s.snapshot())
+	}
+
+	return sessions
+}
+
+func (a *Api) listExecSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(a.execSessions.list()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestExecSessionParticipants(t *testing.T) {
+	sessions := newExecSessions()
+	s := newExecSession("exec-0", "container-0", []string{"sh"}, "admin", execClient{})
+	sessions.add(s)
+
+	viewer := execClient{ws: &websocket.Conn{}}
+	s.addViewer(viewer, "viewer")
+
+	list := sessions.list()
+	assert.Equal(t, len(list), 1, "expected one session")
+	assert.Equal(t, list[0].Writer, "admin", "expected admin as writer")
+	assert.Equal(t, list[0].Viewers, []string{"viewer"}, "expected one viewer")
+
+	s.removeViewer(viewer)
+	assert.Equal(t, s.participants(), []string{"admin"}, "expected only the writer")
+
+	sessions.remove("exec-0")
+	assert.Nil(t, sessions.get("exec-0"), "expected session to be removed")
+}
+
+func TestJoinExecSessionCluster(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newExecSession("exec-0", "container-0", []string{"sh"}, "admin", execClient{})
+	s.Cluster = "staging"
+	api.execSessions.add(s)
+
+	ts := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		cs := &shipyard.ConsoleSession{ContainerID: "container-0", Username: "viewer"}
+		api.joinExecSession(execClient{ws: ws}, cs, "exec-0")
+	}))
+	defer ts.Close()
+
+	// a session of another cluster is not found
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1), "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	var msg string
+	if err := websocket.Message.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "exec session not found", msg)
+
+	ws, err = websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/?cluster=staging", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	for i := 0; i < 50 && len(s.participants()) == 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []string{"admin", "viewer"}, s.participants(), "expected the viewer to join")
+}
+
+func TestExecSessionStreams(t *testing.T) {
+	received := make(chan []byte, 2)
+	ts := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		for i := 0; i < 2; i++ {
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+	defer ts.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1), "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	s := newExecSession("exec-0", "container-0", []string{"sh"}, "admin", execClient{ws: ws, framed: true})
+	if _, err := s.writeStream(execStreamStderr, []byte("\xfferr")); err != nil {
+		t.Fatal(err)
+	}
+	s.notice("session terminated")
+
+	for _, expected := range []string{"\x02\xfferr", "\x03session terminated"} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, expected, string(msg))
+		case <-time.After(time.Second):
+			t.Fatal("expected a frame")
+		}
+	}
+}
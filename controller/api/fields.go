@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fieldsPaths are the routes whose responses can be trimmed with ?fields=:
+// the shipyard apis and the docker lists and inspects
+var fieldsPaths = regexp.MustCompile(`^/api/|^(/v[0-9.]+)?/(containers|images)/([^/]+/)?json$|^(/v[0-9.]+)?/(networks|volumes)(/[^/]+)?$`)
+
+// parseFields returns the dotted paths of the comma separated fields
+func parseFields(s string) [][]string {
+	paths := [][]string{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		paths = append(paths, strings.Split(f, "."))
+	}
+
+	return paths
+}
+
+// fieldKey returns the key of the object for the field; keys are matched
+// ignoring case as docker uses Names where shipyard uses names
+func fieldKey(obj map[string]interface{}, field string) (string, bool) {
+	if _, ok := obj[field]; ok {
+		return field, true
+	}
+
+	for k := range obj {
+		if strings.EqualFold(k, field) {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// pickField copies the value at the path of src into dst and returns dst;
+// arrays are picked element by element so State.Status or Ports.PublicPort
+// work on lists as well
+func pickField(dst, src interface{}, path []string) interface{} {
+	switch s := src.(type) {
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok || len(d) != len(s) {
+			d = make([]interface{}, len(s))
+		}
+
+		for i := range s {
+			d[i] = pickField(d[i], s[i], path)
+		}
+
+		return d
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			d = map[string]interface{}{}
+		}
+
+		key, ok := fieldKey(s, path[0])
+		if !ok {
+			return d
+		}
+
+		if len(path) == 1 {
+			d[key] = s[key]
+		} else {
+			d[key] = pickField(d[key], s[key], path[1:])
+		}
+
+		return d
+	}
+
+	// the rest of the path is ignored for plain values
+	return src
+}
+
+// selectFields keeps the fields of the objects, or of the objects in the
+// list, of the json response
+func selectFields(data []byte, paths [][]string) ([]byte, bool) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	// keep large numbers (i.e. sizes) exact
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	// streams of json values are not trimmed
+	if _, err := d.Token(); err != io.EOF {
+		return nil, false
+	}
+
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+	default:
+		return nil, false
+	}
+
+	var selected interface{}
+	for _, path := range paths {
+		selected = pickField(selected, v, path)
+	}
+
+	encoded, err := json.Marshal(selected)
+	if err != nil {
+		return nil, false
+	}
+
+	return encoded, true
+}
+
+// fieldsHandler trims successful json responses of GET requests to the
+// fields given with ?fields=name,state,image so list views do not
+// transfer full objects; other responses are passed as they are
+func fieldsHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values, ok := r.URL.Query()["fields"]
+		if !ok || r.Method != "GET" || !fieldsPaths.MatchString(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// streamed responses cannot be buffered
+		for _, param := range []string{"follow", "stream"} {
+			if stream, _ := strconv.ParseBool(r.URL.Query().Get(param)); stream {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		paths := parseFields(strings.Join(values, ","))
+		if len(paths) == 0 {
+			http.Error(w, "fields needs at least one field", http.StatusBadRequest)
+			return
+		}
+
+		rec := &cacheRecorder{header: http.Header{}}
+		h.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		data := rec.body.Bytes()
+		if rec.status == http.StatusOK && rec.header.Get("Content-Encoding") == "" {
+			if selected, ok := selectFields(data, paths); ok {
+				data = selected
+			}
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(rec.status)
+		w.Write(data)
+	})
+}
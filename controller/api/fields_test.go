@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testContainerList = `[
+	{"Id": "abc", "Names": ["/node-1/web"], "Image": "nginx", "State": {"Status": "running", "Pid": 42}, "Ports": [{"PrivatePort": 80, "PublicPort": 8080}], "SizeRw": 12345678901234567},
+	{"Id": "def", "Names": ["/node-2/db"], "Image": "postgres", "State": {"Status": "exited", "Pid": 0}, "Ports": []}
+]`
+
+func getFieldsServer(body string, status int) *httptest.Server {
+	return httptest.NewServer(fieldsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})))
+}
+
+func getFields(t *testing.T, url string) (*http.Response, string) {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res, string(body)
+}
+
+func TestFieldsHandler(t *testing.T) {
+	ts := getFieldsServer(testContainerList, http.StatusOK)
+	defer ts.Close()
+
+	res, body := getFields(t, ts.URL+"/v1.22/containers/json?fields=names,image,state.status,ports.publicport,sizerw")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var containers []map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &containers); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, len(containers))
+	assert.Equal(t, 5, len(containers[0]), "expected the requested fields only")
+	assert.Equal(t, "nginx", containers[0]["Image"])
+	assert.Equal(t, map[string]interface{}{"Status": "running"}, containers[0]["State"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"PublicPort": float64(8080)}}, containers[0]["Ports"])
+	assert.Contains(t, body, "12345678901234567", "expected large numbers to be kept exact")
+	assert.Equal(t, 4, len(containers[1]), "expected missing fields to be left out")
+	assert.Equal(t, int64(len(body)), res.ContentLength, "expected the length of the trimmed body")
+}
+
+func TestFieldsHandlerObject(t *testing.T) {
+	ts := getFieldsServer(`{"Id": "abc", "Config": {"Image": "nginx", "Env": ["A=1"]}, "HostConfig": {}}`, http.StatusOK)
+	defer ts.Close()
+
+	_, body := getFields(t, ts.URL+"/containers/abc/json?fields=id,config.image")
+	assert.JSONEq(t, `{"Id": "abc", "Config": {"Image": "nginx"}}`, body)
+}
+
+func TestFieldsHandlerPassthrough(t *testing.T) {
+	ts := getFieldsServer(testContainerList, http.StatusOK)
+	defer ts.Close()
+
+	// without fields, other routes and streams get the full response
+	for _, path := range []string{"/containers/json", "/containers/abc/logs?fields=image", "/api/events?follow=true&fields=type"} {
+		_, body := getFields(t, ts.URL+path)
+		assert.Equal(t, testContainerList, body, path)
+	}
+
+	errors := getFieldsServer(`{"message": "no such container"}`, http.StatusNotFound)
+	defer errors.Close()
+
+	res, body := getFields(t, errors.URL+"/containers/foo/json?fields=id")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, `{"message": "no such container"}`, body, "expected errors not to be trimmed")
+}
+
+func TestFieldsHandlerEmpty(t *testing.T) {
+	ts := getFieldsServer(testContainerList, http.StatusOK)
+	defer ts.Close()
+
+	res, _ := getFields(t, ts.URL+"/api/stacks?fields=,")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	// maxCreateBody is how much of a container create request is read to
+	// find its environment
+	maxCreateBody = 1024 * 1024
+)
+
+func writeFreezeError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*manager.FreezeError); ok {
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	}
+
+	switch err {
+	case manager.ErrFreezeDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrFreezeNameNeeded, manager.ErrInvalidFreezeRange:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *Api) freezes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	freezes, err := a.manager.Freezes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(freezes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) freeze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	freeze, err := a.manager.Freeze(mux.Vars(r)["id"])
+	if err != nil {
+		writeFreezeError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(freeze); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// saveFreeze creates a freeze or, with an id in the route, replaces it
+func (a *Api) saveFreeze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var freeze *shipyard.Freeze
+	if err := json.NewDecoder(r.Body).Decode(&freeze); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	freeze.ID = mux.Vars(r)["id"]
+	freeze.CreatedBy = getUsername(r)
+
+	if err := a.manager.SaveFreeze(freeze); err != nil {
+		writeFreezeError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(freeze); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteFreeze(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.DeleteFreeze(mux.Vars(r)["id"]); err != nil {
+		writeFreezeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// freezeGuard refuses container creates in a frozen environment; the
+// environment is the label of the container in the create request
+func (a *Api) freezeGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCreateBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+
+		var config struct {
+			Labels map[string]string
+		}
+		// docker reports invalid configs itself
+		json.Unmarshal(data, &config)
+
+		change := "create container " + r.URL.Query().Get("name")
+		if err := a.manager.CheckFreeze(getUsername(r), config.Labels[notification.LabelEnvironment], change); err != nil {
+			writeFreezeError(w, err)
+			return
+		}
+
+		next(w, r)
+	}
+}
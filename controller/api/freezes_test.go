@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func getFreezeRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/freezes", api.freezes).Methods("GET")
+	router.HandleFunc("/api/freezes", api.saveFreeze).Methods("POST")
+	router.HandleFunc("/api/freezes/{id}", api.freeze).Methods("GET")
+	router.HandleFunc("/api/freezes/{id}", api.deleteFreeze).Methods("DELETE")
+
+	return router
+}
+
+func TestApiGetFreezes(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getFreezeRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/freezes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	freezes := []*shipyard.Freeze{}
+	if err := json.NewDecoder(res.Body).Decode(&freezes); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, freezes, 1, "expected the test freeze")
+}
+
+func TestApiSaveFreeze(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getFreezeRouter(api))
+	defer ts.Close()
+
+	data := []byte(`{"name":"release week","start":"2016-01-04T00:00:00Z","end":"2016-01-08T00:00:00Z","environments":["prod"]}`)
+	res, err := http.Post(ts.URL+"/api/freezes", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+}
+
+func TestFreezeGuard(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := false
+	guarded := api.freezeGuard(func(w http.ResponseWriter, r *http.Request) {
+		var config map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			t.Errorf("expected the body to be passed on: %s", err)
+		}
+		created = true
+	})
+
+	tests := []struct {
+		body string
+		// verified is the account the auth middleware verified
+		verified string
+		status   int
+		created  bool
+	}{
+		{`{"Image":"web","Labels":{"com.shipyard.environment":"prod"}}`, "", http.StatusLocked, false},
+		{`{"Image":"web","Labels":{"com.shipyard.environment":"dev"}}`, "", http.StatusOK, true},
+		{`{"Image":"web","Labels":{"com.shipyard.environment":"prod"}}`, "admin", http.StatusOK, true},
+	}
+
+	for _, test := range tests {
+		created = false
+
+		req := httptest.NewRequest("POST", "/containers/create?name=web", bytes.NewBufferString(test.body))
+		// the username of the header is not verified for service keys
+		// and whitelisted addresses, so it cannot override freezes
+		req.Header.Set("X-Access-Token", "admin:forged")
+		if test.verified != "" {
+			mAuth.SetUsername(req, test.verified)
+		}
+		w := httptest.NewRecorder()
+		guarded(w, req)
+
+		assert.Equal(t, test.status, w.Code, "unexpected status for %s", test.body)
+		assert.Equal(t, test.created, created, "unexpected create for %s", test.body)
+	}
+}
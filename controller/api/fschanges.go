@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// containerFsChanges returns the filesystem changes of a container
+// compared to its image; ?unexpected=true only returns the changes
+// outside the paths it is expected to write to
+func (a *Api) containerFsChanges(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	fs, err := a.manager.ContainerFsChanges(id)
+	if err != nil {
+		log.Errorf("error getting filesystem changes of %s: %s", id, err)
+		if err == dockerclient.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("unexpected") == "true" {
+		unexpected := []*manager.FsChange{}
+		for _, c := range fs.Changes {
+			if !c.Expected {
+				unexpected = append(unexpected, c)
+			}
+		}
+		fs.Changes = unexpected
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(fs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiContainerFsChanges(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/containers/{id}/fs-changes", api.containerFsChanges).Methods("GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/containers/" + mock_test.TestContainerId + "/fs-changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var fs *manager.FsChanges
+	if err := json.NewDecoder(res.Body).Decode(&fs); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(fs.Changes))
+	assert.Equal(t, 1, fs.Unexpected)
+
+	res, err = http.Get(ts.URL + "/api/containers/" + mock_test.TestContainerId + "/fs-changes?unexpected=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs = nil
+	if err := json.NewDecoder(res.Body).Decode(&fs); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(fs.Changes))
+	assert.Equal(t, "/usr/bin/curl", fs.Changes[0].Path)
+
+	res, err = http.Get(ts.URL + "/api/containers/unknown/fs-changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// healthz reports whether the controller can serve requests; only the
+// datastore is required as engines come and go without the controller
+// needing a restart
+func (a *Api) healthz(w http.ResponseWriter, r *http.Request) {
+	h := a.manager.Health()
+	writeHealth(w, h, h.Up(manager.HealthDatastore))
+}
+
+// readyz reports whether the controller should receive traffic; every
+// dependency has to be up
+func (a *Api) readyz(w http.ResponseWriter, r *http.Request) {
+	h := a.manager.Health()
+	writeHealth(w, h, h.Status == manager.HealthUp)
+}
+
+func writeHealth(w http.ResponseWriter, h *manager.Health, ok bool) {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(h); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, handler := range map[string]http.HandlerFunc{
+		"/healthz": api.healthz,
+		"/readyz":  api.readyz,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code, path)
+
+		var h *manager.Health
+		if err := json.NewDecoder(res.Body).Decode(&h); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, manager.HealthUp, h.Dependencies[manager.HealthDocker].Status, path)
+	}
+}
+
+func TestWriteHealthDown(t *testing.T) {
+	res := httptest.NewRecorder()
+	writeHealth(res, &manager.Health{
+		Status: manager.HealthDown,
+		Dependencies: map[string]*manager.DependencyHealth{
+			manager.HealthDocker: {Status: manager.HealthDown, Error: "connection refused"},
+		},
+	}, false)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Contains(t, res.Body.String(), `"error":"connection refused"`)
+}
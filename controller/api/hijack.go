@@ -1,14 +1,13 @@
 package api
 
 import (
-	"bytes"
+	"bufio"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"strings"
 	"time"
 
@@ -16,6 +15,10 @@ import (
 	"github.com/samalba/dockerclient"
 )
 
+const (
+	execDialTimeout = 10 * time.Second
+)
+
 func (a *Api) swarmHijack(tlsConfig *tls.Config, addr string, w http.ResponseWriter, r *http.Request) error {
 	if parts := strings.SplitN(addr, "://", 2); len(parts) == 2 {
 		addr = parts[1]
@@ -50,6 +53,20 @@ func (a *Api) swarmHijack(tlsConfig *tls.Config, addr string, w http.ResponseWri
 		return err
 	}
 
+	// attach streams are proxied as is so there is no way to warn the
+	// user in-band; the connection is closed once a limit is reached
+	monitor := newSessionMonitor(a.sessionLimits, time.Now())
+	if a.sessionLimits.enabled() {
+		done := make(chan struct{})
+		defer close(done)
+		go monitor.run(done, nil, func() {
+			log.Infof("terminating hijacked connection: path=%s", r.URL.Path)
+			nc.Close()
+			d.Close()
+		})
+	}
+	in := &activityReader{ReadCloser: nc, monitor: monitor}
+
 	errc := make(chan error, 2)
 	cp := func(dst io.Writer, src io.Reader) {
 		_, err := io.Copy(dst, src)
@@ -60,7 +77,7 @@ func (a *Api) swarmHijack(tlsConfig *tls.Config, addr string, w http.ResponseWri
 		}
 		errc <- err
 	}
-	go cp(d, nc)
+	go cp(d, in)
 	go cp(nc, d)
 	<-errc
 	<-errc
@@ -68,22 +85,38 @@ func (a *Api) swarmHijack(tlsConfig *tls.Config, addr string, w http.ResponseWri
 	return nil
 }
 
-func (a *Api) hijack(addr, method, path string, setRawTerminal bool, in io.ReadCloser, stdout, stderr io.Writer, started chan io.Closer, data interface{}) error {
-	execConfig := &dockerclient.ExecConfig{
-		Tty:    true,
-		Detach: false,
+// execStart starts the exec and returns the raw stream of the process,
+// which docker multiplexes when the exec has no tty; output has to be read
+// from the returned reader as it may hold bytes read along with the
+// response
+func execStart(addr string, tlsConfig *tls.Config, execId string, tty bool) (net.Conn, *bufio.Reader, error) {
+	// long running commands can be quiet for a while; keep alives stop
+	// the connection from being dropped by the network in between
+	dialer := &net.Dialer{
+		Timeout:   execDialTimeout,
+		KeepAlive: 30 * time.Second,
 	}
 
-	buf, err := json.Marshal(execConfig)
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if tlsConfig != nil {
+		log.Debug("using tls for exec")
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
 	if err != nil {
-		return fmt.Errorf("error marshaling exec config: %s", err)
+		return nil, nil, err
 	}
 
-	rdr := bytes.NewReader(buf)
-
-	req, err := http.NewRequest(method, path, rdr)
+	body := strings.NewReader(fmt.Sprintf(`{"Detach":false,"Tty":%t}`, tty))
+	req, err := http.NewRequest("POST", "/exec/"+execId+"/start", body)
 	if err != nil {
-		return fmt.Errorf("error during hijack request: %s", err)
+		conn.Close()
+		return nil, nil, err
 	}
 
 	req.Header.Set("User-Agent", "Docker-Client")
@@ -92,87 +125,39 @@ func (a *Api) hijack(addr, method, path string, setRawTerminal bool, in io.ReadC
 	req.Header.Set("Upgrade", "tcp")
 	req.Host = addr
 
-	var (
-		dial          net.Conn
-		dialErr       error
-		execTLSConfig = a.manager.DockerClient().TLSConfig
-	)
-
-	if a.allowInsecure {
-		execTLSConfig.InsecureSkipVerify = true
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
 	}
 
-	if execTLSConfig == nil {
-		dial, dialErr = net.Dial("tcp", addr)
-	} else {
-		log.Debug("using tls for exec hijack")
-		dial, dialErr = tls.Dial("tcp", addr, execTLSConfig)
-	}
-
-	if dialErr != nil {
-		return dialErr
-	}
-
-	// When we set up a TCP connection for hijack, there could be long periods
-	// of inactivity (a long running command with no output) that in certain
-	// network setups may cause ECONNTIMEOUT, leaving the client in an unknown
-	// state. Setting TCP KeepAlive on the socket connection will prohibit
-	// ECONNTIMEOUT unless the socket connection truly is broken
-	if tcpConn, ok := dial.(*net.TCPConn); ok {
-		tcpConn.SetKeepAlive(true)
-		tcpConn.SetKeepAlivePeriod(30 * time.Second)
-	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, nil, err
 	}
-	clientconn := httputil.NewClientConn(dial, nil)
-	defer clientconn.Close()
-
-	// Server hijacks the connection, error 'connection closed' expected
-	clientconn.Do(req)
-
-	rwc, br := clientconn.Hijack()
-	defer rwc.Close()
 
-	if started != nil {
-		started <- rwc
+	// older engines answer 200 and stream on the same connection
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		conn.Close()
+		return nil, nil, fmt.Errorf("error starting exec: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
 	}
 
-	var receiveStdout chan error
-
-	if stdout != nil || stderr != nil {
-		go func() (err error) {
-			if setRawTerminal && stdout != nil {
-				_, err = io.Copy(stdout, br)
-			}
-			return err
-		}()
-	}
-
-	go func() error {
-		if in != nil {
-			io.Copy(rwc, in)
-		}
+	return conn, br, nil
+}
 
-		if conn, ok := rwc.(interface {
-			CloseWrite() error
-		}); ok {
-			if err := conn.CloseWrite(); err != nil {
-			}
-		}
+// execTLSConfig returns the tls config for exec connections to the docker
+// client; the config of the client is not changed
+func (a *Api) execTLSConfig(client *dockerclient.DockerClient) *tls.Config {
+	if client.TLSConfig == nil {
 		return nil
-	}()
+	}
 
-	if stdout != nil || stderr != nil {
-		if err := <-receiveStdout; err != nil {
-			return err
-		}
+	config := client.TLSConfig.Clone()
+	if a.allowInsecure {
+		config.InsecureSkipVerify = true
 	}
-	go func() {
-		for {
-			fmt.Println(br)
-		}
-	}()
 
-	return nil
+	return config
 }
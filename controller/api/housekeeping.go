@@ -0,0 +1,19 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// housekeeping cleans up the datastore right away and returns what was
+// removed; it also runs once a day
+func (a *Api) housekeeping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	report := a.manager.Housekeeping(getUsername(r))
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiHousekeeping(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.housekeeping))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	report := &manager.HousekeepingReport{}
+	if err := json.NewDecoder(res.Body).Decode(report); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(report.Tasks), "expected the tasks of the report")
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+)
+
+func writeJobError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrJobDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrJobNameNeeded, manager.ErrInvalidJobSchedule, manager.ErrInvalidJobAction:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case manager.ErrJobCreatorNeeded:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *Api) jobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	jobs, err := a.manager.Jobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(jobs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) job(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	job, err := a.manager.Job(mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// saveJob creates a job or, with an id in the route, replaces it; jobs run
+// with the access of the verified account saving them
+func (a *Api) saveJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var job *shipyard.Job
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job.ID = mux.Vars(r)["id"]
+	job.CreatedBy = mAuth.Username(r)
+
+	if err := a.manager.SaveJob(job); err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteJob(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.DeleteJob(mux.Vars(r)["id"]); err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runJob runs the job now and returns the run; a failed run is not an
+// error of the request
+func (a *Api) runJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	run, err := a.manager.RunJob(mux.Vars(r)["id"], mAuth.Username(r))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(run); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func getJobRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/jobs", api.jobs).Methods("GET")
+	router.HandleFunc("/api/jobs", api.saveJob).Methods("POST")
+	router.HandleFunc("/api/jobs/{id}", api.job).Methods("GET")
+	router.HandleFunc("/api/jobs/{id}/run", api.runJob).Methods("POST")
+
+	return router
+}
+
+func TestApiGetJobs(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getJobRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	jobs := []*shipyard.Job{}
+	if err := json.NewDecoder(res.Body).Decode(&jobs); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, jobs, 1, "expected the test job")
+	assert.Equal(t, "0 3 * * *", jobs[0].Schedule)
+}
+
+func TestApiSaveJob(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getJobRouter(api))
+	defer ts.Close()
+
+	data := []byte(`{"name":"prune","schedule":"@daily","action":"prune"}`)
+	res, err := http.Post(ts.URL+"/api/jobs", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+}
+
+func TestApiRunJob(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getJobRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/jobs/0/run", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	run := &shipyard.JobRun{}
+	if err := json.NewDecoder(res.Body).Decode(run); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, shipyard.JobRunSucceeded, run.Status)
+}
@@ -2,12 +2,17 @@ package api
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
 	"github.com/shipyard/shipyard/auth"
 	"github.com/shipyard/shipyard/auth/ldap"
 	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/utils"
 )
 
 func (a *Api) login(w http.ResponseWriter, r *http.Request) {
@@ -17,61 +22,224 @@ func (a *Api) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	remoteAddr := utils.RemoteIP(r.RemoteAddr)
+	if !a.checkLogin(w, r, creds.Username) {
+		return
+	}
+
 	loginSuccessful, err := a.manager.Authenticate(creds.Username, creds.Password)
 	if err != nil {
+		loginFailures.Inc()
+		a.loginFailed(creds.Username, remoteAddr)
 		log.Errorf("error during login for %s from %s: %s", creds.Username, r.RemoteAddr, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if !loginSuccessful {
+		loginFailures.Inc()
+		a.loginFailed(creds.Username, remoteAddr)
 		log.Warnf("invalid login for %s from %s", creds.Username, r.RemoteAddr)
 		http.Error(w, "invalid username/password", http.StatusForbidden)
 		return
 	}
 
+	if err := a.manager.CheckTOTP(creds.Username, creds.Code); err != nil {
+		switch err {
+		case manager.ErrAccountDoesNotExist:
+			// directory users without an account yet have no second factor
+		case manager.ErrTOTPRequired:
+			// clients ask for the code and login again
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case manager.ErrInvalidTOTPCode:
+			loginFailures.Inc()
+			a.loginFailed(creds.Username, remoteAddr)
+			log.Warnf("invalid two-factor code for %s from %s", creds.Username, r.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		default:
+			log.Errorf("error checking two-factor code for %s: %s", creds.Username, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// check for ldap and autocreate for users
 	if a.manager.GetAuthenticator().Name() == "ldap" {
-		if a.manager.GetAuthenticator().(*ldap.LdapAuthenticator).AutocreateUsers {
-			defaultAccessLevel := a.manager.GetAuthenticator().(*ldap.LdapAuthenticator).DefaultAccessLevel
-			log.Debug("ldap: checking for existing user account and creating if necessary")
-			// give default users readonly access to containers
-			acct := &auth.Account{
-				Username: creds.Username,
-				Roles:    []string{defaultAccessLevel},
-			}
-
-			// check for existing account
-			if _, err := a.manager.Account(creds.Username); err != nil {
-				if err == manager.ErrAccountDoesNotExist {
-					log.Debugf("autocreating user for ldap: username=%s access=%s", creds.Username, defaultAccessLevel)
-					if err := a.manager.SaveAccount(acct); err != nil {
-						log.Errorf("error autocreating ldap user %s: %s", creds.Username, err)
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return
-					}
-				} else {
-					log.Errorf("error checking user for autocreate: %s", err)
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-			}
+		ldapAuth := a.manager.GetAuthenticator().(*ldap.LdapAuthenticator)
 
+		// give default users readonly access to containers
+		if err := a.syncExternalAccount("ldap", &auth.Account{Username: creds.Username}, nil, ldapAuth.DefaultAccessLevel, ldapAuth.AutocreateUsers); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 	}
 
-	// return token
-	token, err := a.manager.NewAuthToken(creds.Username, r.UserAgent())
+	token, err := a.loginSucceeded(r, creds.Username, "login")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	// clients prompt for a new password; other requests are refused
+	// until it is changed
+	if acct, err := a.manager.Account(creds.Username); err == nil && acct != nil {
+		token.ForcePasswordChange = acct.ForcePasswordChange
+	}
+
+	// return token
 	if err := json.NewEncoder(w).Encode(token); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// checkLogin refuses logins over the rate limit and to locked accounts;
+// it writes the response when the login is refused
+func (a *Api) checkLogin(w http.ResponseWriter, r *http.Request, username string) bool {
+	err := a.manager.CheckLogin(username, utils.RemoteIP(r.RemoteAddr))
+	if err == nil {
+		return true
+	}
+
+	switch err := err.(type) {
+	case *manager.LoginLimitError:
+		log.Warnf("login rate limit reached for %s from %s", username, r.RemoteAddr)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case *manager.AccountLockedError:
+		log.Warnf("login to locked account %s from %s", username, r.RemoteAddr)
+		http.Error(w, err.Error(), http.StatusLocked)
+	default:
+		log.Errorf("error checking login for %s: %s", username, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	return false
+}
+
+// syncExternalAccount creates the account of a user of an external
+// directory or identity provider on the first login when autocreate is
+// set, with the mapped roles or the default role, and keeps the roles of
+// existing accounts in line with the mapped ones; new accounts keep the
+// username and the OpenID Connect link of external
+func (a *Api) syncExternalAccount(source string, external *auth.Account, mappedRoles []string, defaultRole string, autocreate bool) error {
+	username := external.Username
+	existing, err := a.manager.Account(username)
+	if err != nil && err != manager.ErrAccountDoesNotExist {
+		log.Errorf("error checking user for autocreate: %s", err)
+		return err
+	}
+
+	switch {
+	case existing == nil && autocreate:
+		roles := []string{defaultRole}
+		if len(mappedRoles) > 0 {
+			roles = mappedRoles
+		}
+
+		acct := &auth.Account{
+			Username:    username,
+			Roles:       roles,
+			OIDCIssuer:  external.OIDCIssuer,
+			OIDCSubject: external.OIDCSubject,
+		}
+
+		log.Debugf("autocreating user for %s: username=%s roles=%v", source, username, roles)
+		if err := a.manager.SaveAccount(acct); err != nil {
+			log.Errorf("error autocreating %s user %s: %s", source, username, err)
+			return err
+		}
+	case existing != nil && len(mappedRoles) > 0 && !sameRoles(existing.Roles, mappedRoles):
+		log.Debugf("updating roles from %s groups: username=%s roles=%v", source, username, mappedRoles)
+		existing.Password = ""
+		existing.Roles = mappedRoles
+		if err := a.manager.SaveAccount(existing); err != nil {
+			log.Errorf("error updating %s user %s: %s", source, username, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loginSucceeded clears the failed logins of the user, records the login
+// and returns a new auth token
+func (a *Api) loginSucceeded(r *http.Request, username, message string) (*auth.AuthToken, error) {
+	if err := a.manager.LoginSucceeded(username); err != nil {
+		log.Errorf("error clearing failed logins of %s: %s", username, err)
+	}
+
+	evt := &shipyard.Event{
+		Type:       "login",
+		Time:       time.Now(),
+		Username:   username,
+		RemoteAddr: utils.RemoteIP(r.RemoteAddr),
+		Message:    message,
+		Tags:       []string{"security"},
+	}
+
+	if err := a.manager.SaveEvent(evt); err != nil {
+		log.Errorf("error saving login event: %s", err)
+	}
+
+	return a.manager.NewAuthToken(username, r.UserAgent())
+}
+
+// loginFailed counts the failed login towards the lockout of the account
+func (a *Api) loginFailed(username, remoteAddr string) {
+	if err := a.manager.LoginFailed(username, remoteAddr); err != nil {
+		log.Errorf("error counting failed login of %s: %s", username, err)
+	}
+}
+
+// refreshToken exchanges the still valid token in X-Access-Token for a new
+// one so long running clients do not have to login again
+func (a *Api) refreshToken(w http.ResponseWriter, r *http.Request) {
+	tk, err := auth.GetAccessToken(r.Header.Get("X-Access-Token"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	token, err := a.manager.RefreshAuthToken(tk.Username, tk.Token)
+	if err != nil {
+		switch err {
+		case manager.ErrInvalidAuthToken, manager.ErrAuthTokenExpired, manager.ErrAccountDoesNotExist:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// sameRoles reports whether both lists contain the same roles in any order
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	roles := map[string]bool{}
+	for _, r := range a {
+		roles[r] = true
+	}
+
+	for _, r := range b {
+		if !roles[r] {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (a *Api) changePassword(w http.ResponseWriter, r *http.Request) {
 	session, _ := a.manager.Store().Get(r, a.manager.StoreKey())
 	var creds *Credentials
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// containerLogs returns the logs of a container split into lines. They are
+// streamed as json lines (i.e. {"stream":"stderr","line":"..."}) so
+// browsers do not have to read the docker stream format; with download
+// they are a gzipped text file instead. follow keeps streaming new lines
+// and tail limits the lines returned.
+func (a *Api) containerLogs(w http.ResponseWriter, r *http.Request) {
+	opts := &manager.LogOptions{
+		Follow:     r.FormValue("follow") == "true" || r.FormValue("follow") == "1",
+		Timestamps: r.FormValue("timestamps") == "true" || r.FormValue("timestamps") == "1",
+	}
+
+	download := r.FormValue("download") == "true" || r.FormValue("download") == "1"
+	if download {
+		opts.Follow = false
+	}
+
+	if v := r.FormValue("tail"); v != "" && v != "all" {
+		tail, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || tail < 0 {
+			http.Error(w, "invalid tail: it has to be a number of lines or all", http.StatusBadRequest)
+			return
+		}
+		opts.Tail = tail
+	}
+
+	logs, err := a.manager.ContainerLogs(mux.Vars(r)["id"], opts)
+	if err != nil {
+		if err == dockerclient.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer logs.Close()
+
+	if download {
+		w.Header().Set("content-type", "application/gzip")
+		w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%q", logs.Name+".log.gz"))
+
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+
+		for {
+			line, err := logs.Next()
+			if err != nil {
+				if err != io.EOF {
+					log.Errorf("error reading logs of %s: %s", logs.Name, err)
+				}
+				return
+			}
+
+			if _, err := fmt.Fprintln(gz, line.Line); err != nil {
+				return
+			}
+		}
+	}
+
+	w.Header().Set("content-type", "application/x-ndjson")
+
+	// a followed stream may stay idle, so it is closed when the client
+	// goes away rather than on the next write
+	if cn, ok := w.(http.CloseNotifier); ok && opts.Follow {
+		done := make(chan struct{})
+		defer close(done)
+
+		closed := cn.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				logs.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := logs.Next()
+		if err != nil {
+			if err != io.EOF {
+				log.Debugf("logs of %s ended: %s", logs.Name, err)
+			}
+			return
+		}
+
+		if err := enc.Encode(line); err != nil {
+			return
+		}
+
+		if flusher != nil && opts.Follow {
+			flusher.Flush()
+		}
+	}
+}
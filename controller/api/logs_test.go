@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getLogsRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/containers/{id}/logs", api.containerLogs).Methods("GET")
+
+	return router
+}
+
+func TestApiContainerLogs(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getLogsRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/containers/" + mock_test.TestContainerId + "/logs?tail=500")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+	assert.Equal(t, "application/x-ndjson", res.Header.Get("content-type"))
+
+	lines := []manager.LogLine{}
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var line manager.LogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	assert.Equal(t, []manager.LogLine{{Stream: "stdout", Line: "starting up"}, {Stream: "stderr", Line: "disk full!"}}, lines)
+}
+
+func TestApiContainerLogsDownload(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getLogsRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/containers/" + mock_test.TestContainerId + "/logs?download=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+	assert.Equal(t, `attachment; filename="test-container.log.gz"`, res.Header.Get("content-disposition"))
+
+	// the transport would otherwise decompress a gzip content-encoding;
+	// the file is sent as is
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "starting up\ndisk full!\n", string(data))
+}
+
+func TestApiContainerLogsInvalid(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getLogsRouter(api))
+	defer ts.Close()
+
+	for path, code := range map[string]int{
+		"/api/containers/" + mock_test.TestContainerId + "/logs?tail=last": http.StatusBadRequest,
+		"/api/containers/missing/logs":                                     http.StatusNotFound,
+	} {
+		res, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, code, res.StatusCode, path)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/controller/metrics"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounter("shipyard_http_requests_total",
+		"HTTP requests handled by the controller.", "method", "route", "code")
+	httpRequestDuration = metrics.Default.NewHistogram("shipyard_http_request_duration_seconds",
+		"HTTP request latency in seconds.", metrics.DefaultBuckets, "method", "route")
+	loginFailures = metrics.Default.NewCounter("shipyard_login_failures_total",
+		"Failed logins.")
+	cacheRequests = metrics.Default.NewCounter("shipyard_response_cache_requests_total",
+		"Requests served through the response cache by result.", "result")
+)
+
+// statusRecorder keeps the response status; hijacking, flushing and close
+// notification are passed through for exec, attach and event streams
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) CloseNotify() <-chan bool {
+	if cn, ok := s.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+
+	return make(chan bool)
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+
+	// hijacked connections are reported as switching protocols
+	s.status = http.StatusSwitchingProtocols
+
+	return hj.Hijack()
+}
+
+// instrument records the count and latency of requests to the route; the
+// route template is used so ids do not create new series
+func instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		h.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		httpRequests.Inc(r.Method, route, strconv.Itoa(rec.status))
+		httpRequestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// instrumentRouter instruments every route registered on the router
+func instrumentRouter(router *mux.Router) error {
+	return router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		h := route.GetHandler()
+		if h == nil {
+			return nil
+		}
+
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		route.Handler(instrument(tpl, h))
+
+		return nil
+	})
+}
+
+// registerMetrics adds the gauges computed from the controller state
+func (a *Api) registerMetrics() {
+	metrics.Default.NewGaugeFunc("shipyard_exec_sessions_active", "Active exec sessions.", func() float64 {
+		return float64(len(a.execSessions.list()))
+	})
+
+	metrics.Default.NewGaugeFunc("shipyard_cluster_nodes", "Nodes in the cluster.", func() float64 {
+		nodes, err := a.manager.Nodes()
+		if err != nil {
+			return math.NaN()
+		}
+
+		return float64(len(nodes))
+	})
+
+	metrics.Default.NewGaugeFunc("shipyard_cluster_containers", "Containers in the cluster.", func() float64 {
+		info, err := a.manager.DockerClient().Info()
+		if err != nil {
+			return math.NaN()
+		}
+
+		return float64(info.Containers)
+	})
+
+	metrics.Default.NewGaugeFunc("shipyard_legacy_password_hashes", "Local accounts whose password hash does not match the hash policy; they are upgraded on login.", func() float64 {
+		accounts, err := a.manager.Accounts(&datastore.AccountQuery{})
+		if err != nil {
+			return math.NaN()
+		}
+
+		n := 0
+		for _, acct := range accounts {
+			if acct.Password != "" && auth.NeedsRehash(acct.Password) {
+				n++
+			}
+		}
+
+		return float64(n)
+	})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/metrics"
+)
+
+func TestInstrumentRouter(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}).Methods("GET")
+
+	if err := instrumentRouter(router); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := http.Get(ts.URL + "/api/test/" + id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	metrics.Default.Expose(buf)
+
+	expected := `shipyard_http_requests_total{method="GET",route="/api/test/{id}",code="418"} 2`
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("expected %q in:\n%s", expected, buf.String())
+	}
+}
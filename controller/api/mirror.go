@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeRegistryMirrorError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrRegistryMirrorNotSet:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrInvalidRegistryMirror:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Errorf("error managing the registry mirror: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// registryMirror reports the mirror of the cluster and the nodes whose
+// engine lacks it
+func (a *Api) registryMirror(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	report, err := a.manager.RegistryMirror()
+	if err != nil {
+		writeRegistryMirrorError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) setRegistryMirror(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mirror, err := a.manager.SetRegistryMirror(req.URL, getUsername(r))
+	if err != nil {
+		writeRegistryMirrorError(w, err)
+		return
+	}
+
+	log.Infof("set registry mirror: url=%s", mirror.URL)
+
+	if err := json.NewEncoder(w).Encode(mirror); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) removeRegistryMirror(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.RemoveRegistryMirror(getUsername(r)); err != nil {
+		writeRegistryMirrorError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
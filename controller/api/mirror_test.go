@@ -0,0 +1,45 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryMirror(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/registries/mirror", nil)
+	api.registryMirror(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var report *shipyard.RegistryMirrorReport
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{mock_test.TestNode.Name}, report.Missing)
+
+	for body, status := range map[string]int{
+		`{"url":"https://mirror.local"}`: http.StatusOK,
+		`{"url":""}`:                     http.StatusBadRequest,
+		`not json`:                       http.StatusBadRequest,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/registries/mirror", bytes.NewBufferString(body))
+		api.setRegistryMirror(res, req)
+
+		assert.Equal(t, status, res.Code, body)
+	}
+}
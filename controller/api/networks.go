@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeNetworkError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrNetworkDoesNotExist, dockerclient.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrNetworkInUse:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrNetworkNameRequired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *Api) networks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	networks, err := a.manager.Networks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(networks); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) network(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	network, err := a.manager.Network(id)
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(network); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// createNetwork creates a network with the fields of the engine api
+// (i.e. {"Name": "backend", "Driver": "overlay"})
+func (a *Api) createNetwork(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	config := &dockerclient.NetworkCreate{}
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.manager.CreateNetwork(config, getUsername(r))
+	if err != nil {
+		log.Errorf("error creating network: %s", err)
+		writeNetworkError(w, err)
+		return
+	}
+
+	log.Infof("created network: name=%s id=%s", config.Name, resp.ID)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) removeNetwork(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.RemoveNetwork(id, getUsername(r)); err != nil {
+		log.Errorf("error removing network %s: %s", id, err)
+		writeNetworkError(w, err)
+		return
+	}
+
+	log.Infof("removed network: id=%s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// connectNetwork connects the container of the body
+// (i.e. {"Container": "web"}) to the network
+func (a *Api) connectNetwork(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	connect := &dockerclient.NetworkConnect{}
+	if err := json.NewDecoder(r.Body).Decode(connect); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.ConnectNetwork(id, connect.Container, getUsername(r)); err != nil {
+		log.Errorf("error connecting %s to network %s: %s", connect.Container, id, err)
+		writeNetworkError(w, err)
+		return
+	}
+
+	log.Infof("connected container to network: id=%s container=%s", id, connect.Container)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// disconnectNetwork disconnects the container of the body
+// (i.e. {"Container": "web", "Force": true}) from the network
+func (a *Api) disconnectNetwork(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	disconnect := &dockerclient.NetworkDisconnect{}
+	if err := json.NewDecoder(r.Body).Decode(disconnect); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.DisconnectNetwork(id, disconnect.Container, disconnect.Force, getUsername(r)); err != nil {
+		log.Errorf("error disconnecting %s from network %s: %s", disconnect.Container, id, err)
+		writeNetworkError(w, err)
+		return
+	}
+
+	log.Infof("disconnected container from network: id=%s container=%s", id, disconnect.Container)
+	w.WriteHeader(http.StatusNoContent)
+}
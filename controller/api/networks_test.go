@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getNetworksRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/networks", api.networks).Methods("GET")
+	router.HandleFunc("/api/networks", api.createNetwork).Methods("POST")
+	router.HandleFunc("/api/networks/{id}", api.network).Methods("GET")
+	router.HandleFunc("/api/networks/{id}", api.removeNetwork).Methods("DELETE")
+	router.HandleFunc("/api/networks/{id}/connect", api.connectNetwork).Methods("POST")
+	router.HandleFunc("/api/networks/{id}/disconnect", api.disconnectNetwork).Methods("POST")
+
+	return router
+}
+
+func TestApiNetworks(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNetworksRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/networks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	networks := []*dockerclient.NetworkResource{}
+	if err := json.NewDecoder(res.Body).Decode(&networks); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(networks), "expected 1 network")
+}
+
+func TestApiNetwork(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNetworksRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/networks/" + mock_test.TestNetwork.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	res, err = http.Get(ts.URL + "/api/networks/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+}
+
+func TestApiCreateNetwork(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNetworksRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/networks", "application/json", strings.NewReader(`{"Name":"backend","Driver":"overlay"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected response code 201")
+
+	res, err = http.Post(ts.URL+"/api/networks", "application/json", strings.NewReader(`{"Driver":"overlay"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
+
+func TestApiRemoveNetwork(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNetworksRouter(api))
+	defer ts.Close()
+
+	req, err := http.NewRequest("DELETE", ts.URL+"/api/networks/"+mock_test.TestNetwork.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
+
+func TestApiConnectNetwork(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNetworksRouter(api))
+	defer ts.Close()
+
+	url := ts.URL + "/api/networks/" + mock_test.TestNetwork.ID
+
+	res, err := http.Post(url+"/connect", "application/json", strings.NewReader(`{"Container":"`+mock_test.TestContainerId+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+
+	res, err = http.Post(url+"/disconnect", "application/json", strings.NewReader(`{"Container":"missing","Force":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+}
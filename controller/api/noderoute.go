@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+	"github.com/shipyard/shipyard/utils"
+)
+
+// nodeRouteHeader routes a proxied request straight to the engine of a
+// node instead of swarm; used to debug node specific behavior
+const nodeRouteHeader = "X-Shipyard-Node"
+
+var (
+	errNodeRouteDenied  = errors.New("node routing requires the nodes:direct permission")
+	errNodeRouteUnknown = errors.New("unknown node")
+)
+
+// nodeTarget returns the proxy target for the request; the swarm target
+// unless a permitted user requested a node with the routing header
+func (a *Api) nodeTarget(r *http.Request, target string) (string, int, error) {
+	name := r.Header.Get(nodeRouteHeader)
+	if name == "" {
+		return target, 0, nil
+	}
+
+	// the header is for the controller only
+	r.Header.Del(nodeRouteHeader)
+
+	// the username of the header is not verified for whitelisted
+	// addresses and service keys
+	username := mAuth.Username(r)
+	if username == "" {
+		return "", http.StatusForbidden, errNodeRouteDenied
+	}
+
+	permitted, err := a.manager.HasPermission(username, auth.PermNodesDirect)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	if !permitted {
+		log.Warnf("node routing denied: username=%s node=%s", username, name)
+		return "", http.StatusForbidden, errNodeRouteDenied
+	}
+
+	m, err := a.manager.ForCluster(selectedCluster(r))
+	if err != nil {
+		return "", http.StatusNotFound, err
+	}
+
+	node, err := m.Node(name)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	if node == nil || node.Addr == "" {
+		return "", http.StatusNotFound, errNodeRouteUnknown
+	}
+
+	addr := node.Addr
+	if parts := strings.SplitN(addr, "://", 2); len(parts) == 2 {
+		addr = parts[1]
+	}
+
+	scheme := "http://"
+	if strings.HasPrefix(target, "https://") {
+		scheme = "https://"
+	}
+
+	evt := &shipyard.Event{
+		Type:       "node-route",
+		Time:       time.Now(),
+		Username:   username,
+		RemoteAddr: utils.RemoteIP(r.RemoteAddr),
+		Message:    fmt.Sprintf("node=%s addr=%s method=%s path=%s", name, addr, r.Method, r.URL.Path),
+		Tags:       []string{"security", "node-route"},
+	}
+	if err := a.manager.SaveEvent(evt); err != nil {
+		log.Errorf("error saving node route event: %s", err)
+	}
+
+	return scheme + addr, 0, nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeTarget(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swarm := "https://swarm:3376"
+
+	req, _ := http.NewRequest("GET", "/containers/json", nil)
+	target, _, err := api.nodeTarget(req, swarm)
+	assert.Nil(t, err)
+	assert.Equal(t, target, swarm, "expected swarm target without header")
+
+	req, _ = http.NewRequest("GET", "/containers/json", nil)
+	req.Header.Set(nodeRouteHeader, "testnode")
+	mAuth.SetUsername(req, "other")
+	_, status, err := api.nodeTarget(req, swarm)
+	assert.Equal(t, err, errNodeRouteDenied)
+	assert.Equal(t, status, http.StatusForbidden)
+
+	// the token of whitelisted and service key requests is not verified
+	req, _ = http.NewRequest("GET", "/containers/json", nil)
+	req.Header.Set(nodeRouteHeader, "testnode")
+	req.Header.Set("X-Access-Token", "testuser:forged")
+	_, status, err = api.nodeTarget(req, swarm)
+	assert.Equal(t, err, errNodeRouteDenied)
+	assert.Equal(t, status, http.StatusForbidden)
+
+	req, _ = http.NewRequest("GET", "/containers/json", nil)
+	req.Header.Set(nodeRouteHeader, "testnode")
+	mAuth.SetUsername(req, "testuser")
+	target, _, err = api.nodeTarget(req, swarm)
+	assert.Nil(t, err)
+	assert.Equal(t, target, "https://127.0.0.1:3375", "expected node target")
+	assert.Equal(t, req.Header.Get(nodeRouteHeader), "", "expected routing header to be removed")
+}
@@ -1,20 +1,56 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
 )
 
+// nodes lists the nodes; with missing_plugin (i.e. volume:rexray) only the
+// nodes whose engine lacks the driver are listed
 func (a *Api) nodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
-	nodes, err := a.manager.Nodes()
+	sort, err := sortParams(r, manager.NodeSortFields, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m, ok := a.clusterManager(w, r)
+	if !ok {
+		return
+	}
+
+	var nodes []*shipyard.Node
+	if missing := r.URL.Query().Get("missing_plugin"); missing != "" {
+		parts := strings.SplitN(missing, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "missing_plugin is kind:name (i.e. volume:rexray)", http.StatusBadRequest)
+			return
+		}
+
+		nodes, err = m.NodesMissingPlugin(parts[0], parts[1])
+		if err == manager.ErrUnknownPluginKind {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		nodes, err = m.Nodes()
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	manager.SortNodes(nodes, sort)
 	if err := json.NewEncoder(w).Encode(nodes); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -24,9 +60,14 @@ func (a *Api) nodes(w http.ResponseWriter, r *http.Request) {
 func (a *Api) node(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
+	m, ok := a.clusterManager(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	name := vars["name"]
-	node, err := a.manager.Node(name)
+	node, err := m.Node(name)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -36,3 +77,173 @@ func (a *Api) node(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func writeNodeError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrNodeDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrNodeExists, manager.ErrNodeHasContainers:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrNodeAddrNeeded, manager.ErrInvalidNodeTLS, manager.ErrNodeNotAdded:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// withoutKey returns a copy of the node without its TLS key for responses
+func withoutKey(node *shipyard.ManagedNode) *shipyard.ManagedNode {
+	n := *node
+	n.TLSKey = ""
+	return &n
+}
+
+// addNode registers the engine in the body with its address and TLS
+// material
+func (a *Api) addNode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var node *shipyard.ManagedNode
+	if err := json.NewDecoder(r.Body).Decode(&node); err != nil || node == nil {
+		http.Error(w, "invalid node", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.AddNode(node, getUsername(r)); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(withoutKey(node)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// removeNode removes a node added through Shipyard; nodes running
+// containers are only removed with force=true
+func (a *Api) removeNode(w http.ResponseWriter, r *http.Request) {
+	force, _ := strconv.ParseBool(r.FormValue("force"))
+
+	if err := a.manager.RemoveNode(mux.Vars(r)["name"], getUsername(r), force); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// drainNode drains the node; with migrate=true its containers are
+// recreated on other nodes
+func (a *Api) drainNode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	migrate, _ := strconv.ParseBool(r.FormValue("migrate"))
+
+	result, err := a.manager.DrainNode(mux.Vars(r)["name"], getUsername(r), migrate)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	result.Node = withoutKey(result.Node)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) undrainNode(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.UndrainNode(mux.Vars(r)["name"], getUsername(r)); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// drainGuard adds the constraints keeping containers off drained nodes to
+// container creates sent to swarm; creates routed to a node are left as
+// they are
+func (a *Api) drainGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(nodeRouteHeader) != "" {
+			next(w, r)
+			return
+		}
+
+		constraints, err := a.manager.DrainConstraints()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(constraints) == 0 {
+			next(w, r)
+			return
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCreateBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var config map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(data))
+		// keep large numbers (i.e. memory) exact
+		d.UseNumber()
+		if err := d.Decode(&config); err != nil || config == nil {
+			// docker reports invalid configs itself
+			r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+			next(w, r)
+			return
+		}
+
+		env, _ := config["Env"].([]interface{})
+		for _, c := range constraints {
+			env = append(env, c)
+		}
+		config["Env"] = env
+
+		data, err = json.Marshal(config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		r.ContentLength = int64(len(data))
+		r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+
+		next(w, r)
+	}
+}
+
+// collectInventory collects the plugins of the engines now instead of
+// waiting for the next collection
+func (a *Api) collectInventory(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.CollectInventory(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// nodeDrift reports the engine settings differing between nodes
+func (a *Api) nodeDrift(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	report, err := a.manager.NodeDrift()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -1,13 +1,16 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
 	"github.com/shipyard/shipyard/controller/mock_test"
 	"github.com/stretchr/testify/assert"
 )
@@ -63,3 +66,218 @@ func TestApiGetNodes(t *testing.T) {
 
 	assert.Equal(t, node.Name, mock_test.TestNode.Name, fmt.Sprintf("expected name %s; got %s", mock_test.TestNode.Name, node.Name))
 }
+
+func getNodesRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/nodes", api.addNode).Methods("POST")
+	router.HandleFunc("/api/nodes/{name}", api.removeNode).Methods("DELETE")
+	router.HandleFunc("/api/nodes/{name}/drain", api.drainNode).Methods("POST")
+	router.HandleFunc("/api/nodes/{name}/drain", api.undrainNode).Methods("DELETE")
+
+	return router
+}
+
+func TestApiAddNode(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNodesRouter(api))
+	defer ts.Close()
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"name":"node-2","addr":"10.0.0.2:2376","tls_key":"secret"}`, http.StatusCreated},
+		{`{"name":"testnode","addr":"10.0.0.1:2376"}`, http.StatusConflict},
+		{`{"name":"node-3"}`, http.StatusBadRequest},
+		{`[`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		res, err := http.Post(ts.URL+"/api/nodes", "application/json", bytes.NewBufferString(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.status, res.StatusCode, "unexpected status for %s", test.body)
+
+		if res.StatusCode == http.StatusCreated {
+			node := &shipyard.ManagedNode{}
+			if err := json.NewDecoder(res.Body).Decode(node); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "node-2", node.Name)
+			assert.Equal(t, "", node.TLSKey, "expected the tls key not to be returned")
+		}
+	}
+}
+
+func TestApiRemoveNode(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNodesRouter(api))
+	defer ts.Close()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/nodes/testnode", http.StatusConflict},
+		{"/api/nodes/testnode?force=true", http.StatusNoContent},
+		{"/api/nodes/unknown", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("DELETE", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.status, res.StatusCode, "unexpected status for %s", test.path)
+	}
+}
+
+func TestApiDrainNode(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNodesRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/nodes/testnode/drain?migrate=true", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	result := &manager.DrainResult{}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, result.Migrated, 1, "expected the migrated container")
+	assert.Equal(t, "", result.Node.TLSKey, "expected the tls key not to be returned")
+	assert.NotEqual(t, "", mock_test.TestManagedNode.TLSKey, "expected the stored node to keep its key")
+
+	res, err = http.Post(ts.URL+"/api/nodes/unknown/drain", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+
+	req, err := http.NewRequest("DELETE", ts.URL+"/api/nodes/testnode/drain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
+
+func TestDrainGuard(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Env    []string
+		Memory int64
+	}
+	guarded := api.drainGuard(func(w http.ResponseWriter, r *http.Request) {
+		config.Env = nil
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			t.Errorf("expected the body to be passed on: %s", err)
+		}
+	})
+
+	body := `{"Image":"web","Env":["A=1"],"Memory":9007199254740993}`
+	req := httptest.NewRequest("POST", "/containers/create", bytes.NewBufferString(body))
+	guarded(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"A=1", "constraint:node!=testnode"}, config.Env)
+	assert.Equal(t, int64(9007199254740993), config.Memory, "expected numbers to be kept exact")
+
+	req = httptest.NewRequest("POST", "/containers/create", bytes.NewBufferString(body))
+	req.Header.Set(nodeRouteHeader, "testnode")
+	guarded(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"A=1"}, config.Env, "expected creates routed to a node to be left alone")
+}
+
+func TestApiNodesMissingPlugin(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.nodes))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?missing_plugin=volume:rexray")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	nodes := []*shipyard.Node{}
+	if err := json.NewDecoder(res.Body).Decode(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(nodes))
+
+	for _, q := range []string{"rexray", "disk:rexray"} {
+		res, err := http.Get(ts.URL + "?missing_plugin=" + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400 for "+q)
+	}
+}
+
+func TestApiNodeDrift(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.nodeDrift))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	report := &shipyard.DriftReport{}
+	if err := json.NewDecoder(res.Body).Decode(report); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "version", report.Drift[0].Name)
+	assert.Equal(t, "1.11.2", report.Drift[0].Outliers[mock_test.TestNode.Name])
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeNoteError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrNoteDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrInvalidNoteKind, manager.ErrNoteTargetNeeded, manager.ErrInvalidRunbook:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// notes returns every note or those of a kind
+func (a *Api) notes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	notes, err := a.manager.Notes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if kind := r.FormValue("kind"); kind != "" {
+		filtered := []*shipyard.Note{}
+		for _, note := range notes {
+			if note.Kind == kind {
+				filtered = append(filtered, note)
+			}
+		}
+		notes = filtered
+	}
+
+	if err := json.NewEncoder(w).Encode(notes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) note(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+
+	note, err := a.manager.Note(vars["kind"], vars["target"])
+	if err != nil {
+		writeNoteError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) saveNote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+
+	var note *shipyard.Note
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the route decides what the note is attached to
+	note.Kind = vars["kind"]
+	note.Target = vars["target"]
+	note.UpdatedBy = getUsername(r)
+
+	if err := a.manager.SaveNote(note); err != nil {
+		writeNoteError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteNote(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := a.manager.DeleteNote(vars["kind"], vars["target"]); err != nil {
+		writeNoteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getNoteRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/notes", api.notes).Methods("GET")
+	router.HandleFunc("/api/notes/{kind}/{target}", api.note).Methods("GET")
+	router.HandleFunc("/api/notes/{kind}/{target}", api.saveNote).Methods("PUT")
+	router.HandleFunc("/api/notes/{kind}/{target}", api.deleteNote).Methods("DELETE")
+
+	return router
+}
+
+func TestApiGetNotes(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNoteRouter(api))
+	defer ts.Close()
+
+	for kind, count := range map[string]int{"": 1, shipyard.NoteContainer: 1, shipyard.NoteNode: 0} {
+		res, err := http.Get(ts.URL + "/api/notes?kind=" + kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+		notes := []*shipyard.Note{}
+		if err := json.NewDecoder(res.Body).Decode(&notes); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, notes, count, "unexpected notes for kind %q", kind)
+	}
+}
+
+func TestApiGetNote(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNoteRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/notes/container/" + mock_test.TestContainerName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	note := &shipyard.Note{}
+	if err := json.NewDecoder(res.Body).Decode(&note); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, mock_test.TestNote.Runbooks, note.Runbooks)
+
+	res, err = http.Get(ts.URL + "/api/notes/node/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 404, res.StatusCode, "expected response code 404")
+}
+
+func TestApiSaveNote(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getNoteRouter(api))
+	defer ts.Close()
+
+	data := []byte(`{"text": "drain before rebooting", "runbooks": ["https://wiki.local/nodes"]}`)
+	for kind, status := range map[string]int{shipyard.NoteNode: 200, "volume": 400} {
+		req, err := http.NewRequest("PUT", ts.URL+"/api/notes/"+kind+"/node-1", bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, status, res.StatusCode, "unexpected response code for kind "+kind)
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/notification"
+)
+
+func (a *Api) notifiers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	notifiers, err := a.manager.Notifiers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]*notification.Notifier, len(notifiers))
+	for i, n := range notifiers {
+		result[i] = n.WithoutCredentials()
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) notifier(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	n, err := a.manager.Notifier(id)
+	if err != nil {
+		if err == manager.ErrNotifierDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(n.WithoutCredentials()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) saveNotifier(w http.ResponseWriter, r *http.Request) {
+	var n *notification.Notifier
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.SaveNotifier(n); err != nil {
+		log.Errorf("error saving notifier: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("saved notifier: name=%s type=%s", n.Name, n.Type)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) deleteNotifier(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.DeleteNotifier(id); err != nil {
+		log.Errorf("error deleting notifier: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("removed notifier: id=%s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) notificationRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	rules, err := a.manager.NotificationRules()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) notificationRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	rule, err := a.manager.NotificationRule(id)
+	if err != nil {
+		if err == manager.ErrNotificationRuleDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) saveNotificationRule(w http.ResponseWriter, r *http.Request) {
+	var rule *notification.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.SaveNotificationRule(rule); err != nil {
+		log.Errorf("error saving notification rule: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("saved notification rule: name=%s", rule.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) deleteNotificationRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.DeleteNotificationRule(id); err != nil {
+		log.Errorf("error deleting notification rule: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("removed notification rule: id=%s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) escalations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	escalations, err := a.manager.Escalations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(escalations); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) acknowledgeEscalation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.AcknowledgeEscalation(id, getUsername(r)); err != nil {
+		if err == manager.ErrEscalationDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/shipyard/shipyard/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiNotifiersWithoutCredentials(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/notifiers", api.notifiers).Methods("GET")
+	router.HandleFunc("/api/notifiers/{id}", api.notifier).Methods("GET")
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/notifiers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	notifiers := []*notification.Notifier{}
+	if err := json.NewDecoder(res.Body).Decode(&notifiers); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(notifiers))
+	assert.Equal(t, notification.Redacted, notifiers[0].URL, "expected the webhook url not to be returned")
+	assert.Equal(t, mock_test.TestEmailNotifier.SMTPUsername, notifiers[1].SMTPUsername)
+	assert.Equal(t, notification.Redacted, notifiers[1].SMTPPassword, "expected the smtp password not to be returned")
+
+	res, err = http.Get(ts.URL + "/api/notifiers/" + mock_test.TestEmailNotifier.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &notification.Notifier{}
+	if err := json.NewDecoder(res.Body).Decode(n); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, notification.Redacted, n.SMTPPassword, "expected the smtp password not to be returned")
+	assert.NotEqual(t, notification.Redacted, mock_test.TestEmailNotifier.SMTPPassword, "expected the stored notifier to keep its password")
+	assert.NotEqual(t, notification.Redacted, mock_test.TestNotifier.URL, "expected the stored notifier to keep its url")
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+)
+
+const (
+	offlineHubSearch  = "offline mode: Docker Hub search is disabled; browse local registries with GET /api/registries/{id}/repositories"
+	offlineHubWebhook = "offline mode: Docker Hub webhooks are disabled; push images to a local registry instead"
+)
+
+// onlineOnly replaces handlers that reach the internet when running in
+// offline mode with a clear error
+func (a *Api) onlineOnly(msg string, next http.HandlerFunc) http.HandlerFunc {
+	if !a.offline {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, msg, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlineOnly(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	w := httptest.NewRecorder()
+	api.onlineOnly(offlineHubSearch, ok)(w, httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "expected the handler when online")
+
+	api.offline = true
+	w = httptest.NewRecorder()
+	api.onlineOnly(offlineHubSearch, ok)(w, httptest.NewRequest("GET", "/images/search?term=nginx", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "expected the handler to be disabled offline")
+	assert.Contains(t, w.Body.String(), "/api/registries")
+}
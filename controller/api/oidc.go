@@ -0,0 +1,176 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/auth/oidc"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+const (
+	// oidcCookie holds the state, nonce and PKCE verifier of a login
+	// until the provider redirects back
+	oidcCookie       = "shipyard-oidc"
+	oidcCookieMaxAge = 600
+	oidcCookiePath   = "/auth/oidc"
+)
+
+// oidcSecret returns a random url safe secret
+func oidcSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// oidcAccountLinked reports whether the identity may sign in to the account
+// it names; accounts are linked to the issuer and subject of the identity
+// they were created for, so an identity naming a local account or the
+// account of another identity is refused. Identities without an account
+// get a linked one on their first login.
+func (a *Api) oidcAccountLinked(identity *oidc.Identity) (bool, error) {
+	acct, err := a.manager.Account(identity.Username)
+	if err == manager.ErrAccountDoesNotExist {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if acct == nil {
+		return true, nil
+	}
+
+	return acct.OIDCIssuer == a.oidc.Issuer && acct.OIDCSubject == identity.Subject, nil
+}
+
+// oidcLogin redirects the browser to the identity provider
+func (a *Api) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		http.Error(w, "openid connect is not configured", http.StatusNotFound)
+		return
+	}
+
+	secrets := make([]string, 3)
+	for i := range secrets {
+		s, err := oidcSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		secrets[i] = s
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	u, err := a.oidc.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Errorf("error contacting the openid connect provider: %s", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    strings.Join(secrets, "."),
+		Path:     oidcCookiePath,
+		MaxAge:   oidcCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// the provider redirects back with a top level navigation
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// oidcCallback exchanges the code of the provider, creates or updates the
+// account of the user with the roles of their groups and hands the auth
+// token to the web interface
+func (a *Api) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		http.Error(w, "openid connect is not configured", http.StatusNotFound)
+		return
+	}
+
+	if e := r.FormValue("error"); e != "" {
+		log.Warnf("openid connect login refused from %s: %s %s", r.RemoteAddr, e, r.FormValue("error_description"))
+		http.Error(w, "login refused by the identity provider: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		http.Error(w, "the login expired; sign in again", http.StatusBadRequest)
+		return
+	}
+
+	// the cookie is used once
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: oidcCookiePath, MaxAge: -1})
+
+	secrets := strings.Split(cookie.Value, ".")
+	state := r.FormValue("state")
+	if len(secrets) != 3 || subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(state)) != 1 {
+		log.Warnf("openid connect state mismatch from %s", r.RemoteAddr)
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := a.oidc.Exchange(r.FormValue("code"), secrets[2], secrets[1])
+	if err != nil {
+		loginFailures.Inc()
+		log.Warnf("openid connect login failed from %s: %s", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	linked, err := a.oidcAccountLinked(identity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !linked {
+		loginFailures.Inc()
+		log.Warnf("openid connect login refused from %s: account %s is not linked to subject %s", r.RemoteAddr, identity.Username, identity.Subject)
+		http.Error(w, "the account is not linked to this identity", http.StatusForbidden)
+		return
+	}
+
+	if !a.checkLogin(w, r, identity.Username) {
+		return
+	}
+
+	external := &auth.Account{
+		Username:    identity.Username,
+		OIDCIssuer:  a.oidc.Issuer,
+		OIDCSubject: identity.Subject,
+	}
+
+	if err := a.syncExternalAccount("oidc", external, a.oidc.Roles(identity.Groups), a.oidc.DefaultRole, true); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token, err := a.loginSucceeded(r, identity.Username, "oidc login: subject="+identity.Subject)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the fragment stays in the browser
+	params := url.Values{
+		"username":   {identity.Username},
+		"auth_token": {token.Token},
+	}
+	http.Redirect(w, r, "/#/oidc?"+params.Encode(), http.StatusFound)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/auth/oidc"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+// oidcManager has a local admin account and an account linked to the
+// subject 1234 of the provider
+type oidcManager struct {
+	mock_test.MockManager
+}
+
+func (m *oidcManager) Account(username string) (*auth.Account, error) {
+	switch username {
+	case "admin":
+		return &auth.Account{Username: "admin", Password: "hash", Roles: []string{"admin"}}, nil
+	case "alice":
+		return &auth.Account{Username: "alice", OIDCIssuer: "https://idp.example.com", OIDCSubject: "1234"}, nil
+	}
+
+	return nil, manager.ErrAccountDoesNotExist
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, handler := range map[string]http.HandlerFunc{
+		"/auth/oidc/login":    api.oidcLogin,
+		"/auth/oidc/callback": api.oidcCallback,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusNotFound, res.Code, path)
+	}
+}
+
+func TestOIDCAccountLinked(t *testing.T) {
+	a := &Api{
+		manager: &oidcManager{},
+		oidc:    oidc.NewProvider("https://idp.example.com", "shipyard", "secret", "https://shipyard/auth/oidc/callback"),
+	}
+
+	for _, test := range []struct {
+		identity *oidc.Identity
+		linked   bool
+	}{
+		{&oidc.Identity{Username: "alice", Subject: "1234"}, true},
+		{&oidc.Identity{Username: "bob", Subject: "5678"}, true},
+		{&oidc.Identity{Username: "alice", Subject: "5678"}, false},
+		{&oidc.Identity{Username: "admin", Subject: "admin"}, false},
+	} {
+		linked, err := a.oidcAccountLinked(test.identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.linked, linked, "%+v", test.identity)
+	}
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.oidc = oidc.NewProvider("https://idp.example.com", "shipyard", "secret", "https://shipyard/auth/oidc/callback")
+
+	// without the cookie of the login
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/oidc/callback?code=code-1&state=state-1", nil)
+	api.oidcCallback(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// with the cookie of another login
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/oidc/callback?code=code-1&state=state-1", nil)
+	req.AddCookie(&http.Cookie{Name: oidcCookie, Value: "state-2.nonce.verifier"})
+	api.oidcCallback(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Header().Get("Set-Cookie"), oidcCookie+"=;", "expected the cookie to be cleared")
+
+	// refused by the provider
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/oidc/callback?error=access_denied", nil)
+	api.oidcCallback(res, req)
+
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+}
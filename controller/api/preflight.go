@@ -0,0 +1,26 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// preflight runs the startup checks again and returns the results
+func (a *Api) preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if a.preflightChecks == nil {
+		http.Error(w, "preflight checks are not configured", http.StatusNotFound)
+		return
+	}
+
+	report := a.preflightChecks()
+	if !report.Passed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
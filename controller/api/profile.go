@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeProfileError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrAccountDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrInvalidEmail, manager.ErrEmptyPassword, manager.ErrInvalidResetToken:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case manager.ErrInvalidPassword:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case manager.ErrPasswordUpdateDisabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// profileUsername returns the user of the request; requests with a service
+// key have no account
+func profileUsername(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username := getUsername(r)
+	if username == "" {
+		http.Error(w, "only users have a profile", http.StatusBadRequest)
+		return "", false
+	}
+
+	return username, true
+}
+
+func writeProfile(w http.ResponseWriter, account *auth.Account) {
+	w.Header().Set("content-type", "application/json")
+
+	// the hash of the password is not part of the profile
+	account.Password = ""
+	if err := json.NewEncoder(w).Encode(account); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// profile returns the account of the user of the request
+func (a *Api) profile(w http.ResponseWriter, r *http.Request) {
+	username, ok := profileUsername(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := a.manager.Account(username)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	writeProfile(w, account)
+}
+
+// updateProfile changes the name and email of the user of the request;
+// roles and the other fields of the account are left as they are
+func (a *Api) updateProfile(w http.ResponseWriter, r *http.Request) {
+	username, ok := profileUsername(w, r)
+	if !ok {
+		return
+	}
+
+	var profile *auth.Account
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	account, err := a.manager.UpdateProfile(username, profile)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	writeProfile(w, account)
+}
+
+// changeOwnPassword sets the password of the user of the request, who has
+// to send the current one
+func (a *Api) changeOwnPassword(w http.ResponseWriter, r *http.Request) {
+	username, ok := profileUsername(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Current  string `json:"current_password"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.ChangeOwnPassword(username, body.Current, body.Password); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetPassword returns a one-time token for the user to set a new
+// password with at /auth/reset; it is only shown once
+func (a *Api) resetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	reset, err := a.manager.ResetPassword(mux.Vars(r)["username"], getUsername(r))
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(reset); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// completePasswordReset sets the password of an account with its reset
+// token; it is public since the user cannot login
+func (a *Api) completePasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Username string `json:"username"`
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.CompletePasswordReset(body.Username, body.Token, body.Password); err != nil {
+		log.Warnf("password reset failed: username=%s remote=%s", body.Username, r.RemoteAddr)
+		writeProfileError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getProfileRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/account/me", api.updateProfile).Methods("PUT")
+	router.HandleFunc("/api/account/me/password", api.changeOwnPassword).Methods("PUT")
+	router.HandleFunc("/api/accounts/{username}/reset", api.resetPassword).Methods("POST")
+	router.HandleFunc("/auth/reset", api.completePasswordReset).Methods("POST")
+
+	return router
+}
+
+func TestApiUpdateProfile(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(authenticated(getProfileRouter(api)))
+	defer ts.Close()
+
+	res := totpRequest(t, "PUT", ts.URL+"/api/account/me", "alice", `{"first_name":"Alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	account := &auth.Account{}
+	if err := json.NewDecoder(res.Body).Decode(account); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "alice", account.Username, "expected the profile of the user of the request")
+	assert.Equal(t, "alice@example.com", account.Email, "expected the new email")
+	assert.Equal(t, "", account.Password, "expected no password hash in the profile")
+
+	res = totpRequest(t, "PUT", ts.URL+"/api/account/me", "alice", `{"email":"alice"}`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
+
+func TestApiChangeOwnPassword(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(authenticated(getProfileRouter(api)))
+	defer ts.Close()
+
+	res := totpRequest(t, "PUT", ts.URL+"/api/account/me/password", "testuser", `{"current_password":"wrong","password":"new"}`)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "expected response code 403")
+
+	res = totpRequest(t, "PUT", ts.URL+"/api/account/me/password", "testuser", `{"current_password":"`+mock_test.TestAccount.Password+`","password":"new"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
+
+func TestApiPasswordReset(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(authenticated(getProfileRouter(api)))
+	defer ts.Close()
+
+	res := totpRequest(t, "POST", ts.URL+"/api/accounts/alice/reset", "admin", "")
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected response code 201")
+
+	var reset struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&reset); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = http.Post(ts.URL+"/auth/reset", "application/json", strings.NewReader(`{"username":"alice","token":"guess","password":"new"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+
+	res, err = http.Post(ts.URL+"/auth/reset", "application/json", strings.NewReader(`{"username":"alice","token":"`+reset.Token+`","password":"new"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
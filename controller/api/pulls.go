@@ -0,0 +1,17 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// pulls returns the progress of the image pulls of deploys; deploy (i.e.
+// stack:web) limits them to a deploy
+func (a *Api) pulls(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(a.manager.Pulls(r.FormValue("deploy"))); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPulls(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/pulls?deploy=stack:web", nil)
+	api.pulls(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	pulls := []*manager.PullProgress{}
+	if err := json.NewDecoder(res.Body).Decode(&pulls); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(pulls))
+	assert.Equal(t, 25.0, pulls[0].Layers[0].Percent)
+}
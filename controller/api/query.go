@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// query returns the requested fields of containers and their related
+// resources in one request
+func (a *Api) query(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var q *manager.Query
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := a.manager.Query(q, getUsername(r))
+	if err != nil {
+		if _, ok := err.(*manager.QueryError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err == manager.ErrQueryNotAllowed {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
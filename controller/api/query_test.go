@@ -0,0 +1,42 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiQuery(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/query", api.query).Methods("POST")
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	data := []byte(`{"resource":"containers","fields":["id","name"]}`)
+	res, err := http.Post(ts.URL+"/api/query", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	results := []map[string]interface{}{}
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, results, 1)
+	assert.Equal(t, mock_test.TestContainerName, results[0]["name"])
+}
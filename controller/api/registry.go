@@ -7,14 +7,27 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	registry "github.com/shipyard/shipyard/registry/v2"
 )
 
 func (a *Api) registries(w http.ResponseWriter, r *http.Request) {
+	sort, err := sortParams(r, manager.RegistrySortFields, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	registries, err := a.manager.Registries()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	manager.SortRegistries(registries, sort)
+
+	for _, registry := range registries {
+		registry.Redact()
+	}
 
 	if err := json.NewEncoder(w).Encode(registries); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -30,6 +43,11 @@ func (a *Api) addRegistry(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := a.manager.AddRegistry(registry); err != nil {
+		if err == shipyard.ErrInvalidRegistryCA {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		log.Errorf("error saving registry: %s", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -50,6 +68,7 @@ func (a *Api) registry(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	registry.Redact()
 
 	if err := json.NewEncoder(w).Encode(registry); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -72,6 +91,8 @@ func (a *Api) removeRegistry(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	a.cache.purge("/api/registries/" + id + "/")
 }
 
 func (a *Api) repositories(w http.ResponseWriter, r *http.Request) {
@@ -141,5 +162,117 @@ func (a *Api) deleteRepository(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a.cache.purge("/api/registries/" + id + "/")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registryError returns the status for an error from a registry
+func registryError(err error) int {
+	switch err {
+	case registry.ErrNotFound:
+		return http.StatusNotFound
+	case registry.ErrInvalidDigest, shipyard.ErrRegistryVersionNotSupported:
+		return http.StatusBadRequest
+	case registry.ErrTagShared:
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (a *Api) repositoryTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["registryId"]
+	repoName := vars["repo"]
+
+	reg, err := a.manager.Registry(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := reg.Tags(repoName)
+	if err != nil {
+		http.Error(w, err.Error(), registryError(err))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteRepositoryTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["registryId"]
+	repoName := vars["repo"]
+	tag := vars["tag"]
+
+	reg, err := a.manager.Registry(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := reg.DeleteTag(repoName, tag); err != nil {
+		http.Error(w, err.Error(), registryError(err))
+		return
+	}
+
+	log.Infof("deleted tag: registry=%s repository=%s tag=%s", reg.Name, repoName, tag)
+	a.cache.purge("/api/registries/" + id + "/")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) repositoryManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["registryId"]
+	repoName := vars["repo"]
+	reference := vars["reference"]
+
+	reg, err := a.manager.Registry(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	manifest, err := reg.Manifest(repoName, reference)
+	if err != nil {
+		http.Error(w, err.Error(), registryError(err))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteRepositoryManifest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["registryId"]
+	repoName := vars["repo"]
+	reference := vars["reference"]
+
+	reg, err := a.manager.Registry(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := reg.DeleteManifest(repoName, reference); err != nil {
+		http.Error(w, err.Error(), registryError(err))
+		return
+	}
+
+	a.cache.purge("/api/registries/" + id + "/")
+
 	w.WriteHeader(http.StatusNoContent)
 }
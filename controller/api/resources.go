@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeResourcesError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*manager.FreezeError); ok {
+		writeFreezeError(w, err)
+		return
+	}
+
+	switch err {
+	case dockerclient.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrNoResourceUpdate, manager.ErrInvalidResources:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case manager.ErrResourcesNotAllowed:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// updateContainerResources changes the memory and cpu limits of a running
+// container; the body uses the fields of the engine update api
+// (i.e. {"Memory": 536870912})
+func (a *Api) updateContainerResources(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	update := &manager.ResourceUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.UpdateContainerResources(id, update, getUsername(r)); err != nil {
+		log.Errorf("error updating resources of %s: %s", id, err)
+		writeResourcesError(w, err)
+		return
+	}
+
+	log.Infof("updated container resources: container=%s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// proxyContainerUpdate serves the update api of the engine for docker
+// clients (i.e. docker update) through the manager so the resource limits
+// and restart policies of the roles apply; other fields of the update are
+// not supported by swarm and are ignored
+func (a *Api) proxyContainerUpdate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	username := getUsername(r)
+
+	var update struct {
+		manager.ResourceUpdate
+		RestartPolicy *dockerclient.RestartPolicy
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy := update.RestartPolicy
+	if policy != nil && policy.Name == "" {
+		policy = nil
+	}
+
+	resources := update.ResourceUpdate
+	if err := a.manager.UpdateContainerResources(name, &resources, username); err != nil && (err != manager.ErrNoResourceUpdate || policy == nil) {
+		log.Errorf("error updating resources of %s: %s", name, err)
+		writeResourcesError(w, err)
+		return
+	}
+
+	if policy != nil {
+		if err := a.manager.SetRestartPolicy(name, *policy, username); err != nil {
+			log.Errorf("error setting restart policy of %s: %s", name, err)
+			writeRestartPolicyError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{
+		"Warnings": {},
+	})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getResourcesRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/containers/{id}/update", api.updateContainerResources).Methods("POST")
+
+	return router
+}
+
+func TestApiUpdateContainerResources(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getResourcesRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/containers/"+mock_test.TestContainerId+"/update", "application/json", strings.NewReader(`{"Memory":536870912}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+
+	res, err = http.Post(ts.URL+"/api/containers/missing/update", "application/json", strings.NewReader(`{"Memory":536870912}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+
+	res, err = http.Post(ts.URL+"/api/containers/"+mock_test.TestContainerId+"/update", "application/json", strings.NewReader(`{"Memory":"512m"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeRestartPolicyError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*manager.FreezeError); ok {
+		writeFreezeError(w, err)
+		return
+	}
+
+	switch err {
+	case dockerclient.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case auth.ErrInvalidRestartPolicy, manager.ErrInvalidRetryCount:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case manager.ErrRestartPolicyNotAllowed:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// setRestartPolicy changes the restart policy of a container without
+// recreating it; the body is the restart policy of the engine
+// (i.e. {"Name": "on-failure", "MaximumRetryCount": 3})
+func (a *Api) setRestartPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var policy dockerclient.RestartPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.SetRestartPolicy(id, policy, getUsername(r)); err != nil {
+		log.Errorf("error setting restart policy of %s: %s", id, err)
+		writeRestartPolicyError(w, err)
+		return
+	}
+
+	log.Infof("set restart policy: container=%s policy=%s", id, policy.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getRestartPolicyRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/containers/{id}/restart-policy", api.setRestartPolicy).Methods("PUT")
+
+	return router
+}
+
+func putRestartPolicy(t *testing.T, url, body string) *http.Response {
+	req, err := http.NewRequest("PUT", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestApiSetRestartPolicy(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getRestartPolicyRouter(api))
+	defer ts.Close()
+
+	res := putRestartPolicy(t, ts.URL+"/api/containers/"+mock_test.TestContainerId+"/restart-policy", `{"Name":"always"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+
+	res = putRestartPolicy(t, ts.URL+"/api/containers/missing/restart-policy", `{"Name":"always"}`)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+
+	res = putRestartPolicy(t, ts.URL+"/api/containers/"+mock_test.TestContainerId+"/restart-policy", `{"Name":`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
 )
 
 func (a *Api) roles(w http.ResponseWriter, r *http.Request) {
@@ -31,8 +34,66 @@ func (a *Api) role(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if role == nil {
+		http.Error(w, manager.ErrRoleDoesNotExist.Error(), http.StatusNotFound)
+		return
+	}
 	if err := json.NewEncoder(w).Encode(role); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+func (a *Api) permissions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(auth.Permissions()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) saveRole(w http.ResponseWriter, r *http.Request) {
+	var role *auth.ACL
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, p := range role.Permissions {
+		if !auth.ValidPermission(p) {
+			http.Error(w, fmt.Sprintf("%s: %s", manager.ErrInvalidPermission, p), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := a.manager.SaveRole(role); err != nil {
+		switch err {
+		case manager.ErrRoleIsBuiltin, manager.ErrRoleNameRequired, auth.ErrInvalidLabelScope, auth.ErrInvalidRestartPolicy, auth.ErrInvalidResourceLimit:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) deleteRole(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := a.manager.DeleteRole(name); err != nil {
+		switch err {
+		case manager.ErrRoleDoesNotExist:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case manager.ErrRoleIsBuiltin:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/notification"
 )
 
 func (a *Api) scaleContainer(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +33,18 @@ func (a *Api) scaleContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	container, err := a.manager.Container(containerId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	change := fmt.Sprintf("scale %s by %d", containerId, numInstances)
+	if err := a.manager.CheckFreeze(getUsername(r), container.Config.Labels[notification.LabelEnvironment], change); err != nil {
+		writeFreezeError(w, err)
+		return
+	}
+
 	result := a.manager.ScaleContainer(containerId, numInstances)
 	// If we received any errors, continue to write result to the writer, but return a 500
 	if len(result.Errors) > 0 {
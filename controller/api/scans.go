@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeScanError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrRegistryDoesNotExist, manager.ErrImageScanDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case shipyard.ErrRegistryVersionNotSupported:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case manager.ErrScannerDisabled, manager.ErrImageScanRunning:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// scanTag returns the tag of a scan request; latest by default
+func scanTag(r *http.Request) string {
+	if tag := r.FormValue("tag"); tag != "" {
+		return tag
+	}
+
+	return "latest"
+}
+
+// imageScan returns the latest scan of a tag (i.e. ?tag=1.2) of a
+// repository
+func (a *Api) imageScan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	scan, err := a.manager.ImageScan(vars["registryId"], vars["repo"], scanTag(r))
+	if err != nil {
+		writeScanError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(scan); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// scanImage starts a scan of a tag of a repository; the scan is returned
+// while it runs and its result read from imageScan
+func (a *Api) scanImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	scan, err := a.manager.ScanImage(vars["registryId"], vars["repo"], scanTag(r), getUsername(r))
+	if err != nil {
+		log.Errorf("error scanning %s: %s", vars["repo"], err)
+		writeScanError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(scan); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiImageScan(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/scan", api.imageScan).Methods("GET")
+	router.HandleFunc("/api/registries/{registryId}/repositories/{repo:.*}/scan", api.scanImage).Methods("POST")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/registries/0/repositories/web/scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var scan *shipyard.ImageScan
+	if err := json.NewDecoder(res.Body).Decode(&scan); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, shipyard.SeverityHigh, scan.MaxSeverity)
+	assert.Equal(t, 1, len(scan.Vulnerabilities))
+
+	res, err = http.Get(ts.URL + "/api/registries/0/repositories/web/scan?tag=1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected tags without a scan to be not found")
+
+	res, err = http.Post(ts.URL+"/api/registries/0/repositories/team/web/scan?tag=1.2", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	scan = nil
+	if err := json.NewDecoder(res.Body).Decode(&scan); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "team/web", scan.Repository)
+	assert.Equal(t, "1.2", scan.Tag)
+	assert.Equal(t, shipyard.ScanRunning, scan.Status)
+
+	res, err = http.Post(ts.URL+"/api/registries/unknown/repositories/web/scan", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
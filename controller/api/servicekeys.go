@@ -5,22 +5,38 @@ import (
 	"net/http"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
 	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
 )
 
+// withKeyID returns a copy of the key named by its id, so the key can be
+// addressed without putting it in urls and logs
+func withKeyID(key *auth.ServiceKey) *auth.ServiceKey {
+	k := *key
+	k.ID = auth.KeyID(key.Key)
+	return &k
+}
+
 func (a *Api) addServiceKey(w http.ResponseWriter, r *http.Request) {
 	var k *auth.ServiceKey
 	if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	key, err := a.manager.NewServiceKey(k.Description)
+	k.CreatedBy = getUsername(r)
+	key, err := a.manager.NewServiceKey(k)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch err {
+		case manager.ErrInvalidServiceKeyScope, manager.ErrInvalidServiceKeyExpiry:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	log.Infof("created service key key=%s description=%s", key.Key, key.Description)
-	if err := json.NewEncoder(w).Encode(key); err != nil {
+	if err := json.NewEncoder(w).Encode(withKeyID(key)); err != nil {
 		log.Error(err)
 	}
 }
@@ -34,6 +50,9 @@ func (a *Api) serviceKeys(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	for i, k := range keys {
+		keys[i] = withKeyID(k)
+	}
 	if err := json.NewEncoder(w).Encode(keys); err != nil {
 		log.Error(err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -54,3 +73,41 @@ func (a *Api) removeServiceKey(w http.ResponseWriter, r *http.Request) {
 	log.Infof("removed service key %s", key.Key)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (a *Api) serviceKeyUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	key, err := a.manager.ServiceKeyByID(id)
+	if err != nil {
+		if err == manager.ErrServiceKeyDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := a.manager.ServiceKeyUsage(key.Key)
+	if err != nil {
+		if err == manager.ErrServiceKeyDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the usage is returned by the id of the key, never the key itself
+	u := *usage
+	u.Key = ""
+	u.ID = id
+	if err := json.NewEncoder(w).Encode(&u); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
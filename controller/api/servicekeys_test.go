@@ -7,7 +7,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/mock_test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -61,3 +63,73 @@ func TestApiRemoveServiceKey(t *testing.T) {
 
 	assert.Equal(t, res.StatusCode, 204, "expected response code 204")
 }
+
+func TestApiGetServiceKeyUsage(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/servicekeys/{id}/usage", api.serviceKeyUsage).Methods("GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	id := auth.KeyID(mock_test.TestServiceKey.Key)
+	res, err := http.Get(ts.URL + "/api/servicekeys/" + id + "/usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, res.StatusCode, 200, "expected response code 200")
+	usage := &auth.ServiceKeyUsage{}
+
+	if err := json.NewDecoder(res.Body).Decode(&usage); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, usage.RequestCount, 1, "expected request count 1")
+	assert.Equal(t, id, usage.ID, "expected the usage to name the key by its id")
+	assert.Equal(t, "", usage.Key, "expected the key to be left out")
+
+	res, err = http.Get(ts.URL + "/api/servicekeys/" + mock_test.TestServiceKey.Key + "/usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected the key itself not to address the usage")
+}
+
+func TestApiAddScopedServiceKey(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.addServiceKey))
+	defer ts.Close()
+
+	data := []byte(`{"description": "export", "scopes": ["events:read", "webhook:deploy"], "expires_at": "2030-01-02T15:04:05Z"}`)
+	res, err := http.Post(ts.URL, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	key := &auth.ServiceKey{}
+	if err := json.NewDecoder(res.Body).Decode(key); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, key.Key)
+	assert.Equal(t, []string{"events:read", "webhook:deploy"}, key.Scopes)
+	assert.Equal(t, 2030, key.ExpiresAt.Year())
+
+	res, err = http.Post(ts.URL, "application/json", bytes.NewBufferString(`{"scopes": ["everything"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCheckInterval = 5 * time.Second
+	sessionWarningPeriod = 1 * time.Minute
+)
+
+type (
+	// sessionLimits bound how long an exec or attach connection may stay
+	// open in total and without input; zero disables a limit
+	sessionLimits struct {
+		MaxDuration time.Duration
+		IdleTimeout time.Duration
+	}
+
+	// sessionMonitor tracks the activity of a connection against its limits
+	sessionMonitor struct {
+		limits       sessionLimits
+		started      time.Time
+		lock         *sync.Mutex
+		lastActivity time.Time
+		warnedMax    bool
+		warnedIdle   bool
+	}
+
+	activityReader struct {
+		io.ReadCloser
+		monitor *sessionMonitor
+	}
+)
+
+func (l sessionLimits) enabled() bool {
+	return l.MaxDuration > 0 || l.IdleTimeout > 0
+}
+
+// warningPeriod returns how long before the limit the user is warned
+func warningPeriod(limit time.Duration) time.Duration {
+	if limit < 2*sessionWarningPeriod {
+		return limit / 2
+	}
+
+	return sessionWarningPeriod
+}
+
+func newSessionMonitor(limits sessionLimits, now time.Time) *sessionMonitor {
+	return &sessionMonitor{
+		limits:       limits,
+		started:      now,
+		lock:         &sync.Mutex{},
+		lastActivity: now,
+	}
+}
+
+// touch records activity on the connection
+func (s *sessionMonitor) touch(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastActivity = now
+	s.warnedIdle = false
+}
+
+// check returns a warning to send to the user, if any, and whether the
+// connection must be terminated
+func (s *sessionMonitor) check(now time.Time) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if max := s.limits.MaxDuration; max > 0 {
+		remaining := s.started.Add(max).Sub(now)
+		if remaining <= 0 {
+			return "maximum session duration reached", true
+		}
+
+		if remaining <= warningPeriod(max) && !s.warnedMax {
+			s.warnedMax = true
+			return fmt.Sprintf("session will be terminated in %s (maximum duration %s)", remaining, max), false
+		}
+	}
+
+	if idle := s.limits.IdleTimeout; idle > 0 {
+		remaining := s.lastActivity.Add(idle).Sub(now)
+		if remaining <= 0 {
+			return "session idle timeout reached", true
+		}
+
+		if remaining <= warningPeriod(idle) && !s.warnedIdle {
+			s.warnedIdle = true
+			return fmt.Sprintf("session will be terminated in %s unless there is input (idle timeout %s)", remaining, idle), false
+		}
+	}
+
+	return "", false
+}
+
+// run checks the limits until done is closed; warn is called with
+// messages for the user (it may be nil) and stop when the connection
+// must be terminated
+func (s *sessionMonitor) run(done <-chan struct{}, warn func(string), stop func()) {
+	t := time.NewTicker(sessionCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-t.C:
+			msg, expired := s.check(now)
+			if msg != "" && warn != nil {
+				warn(msg)
+			}
+
+			if expired {
+				stop()
+				return
+			}
+		}
+	}
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.monitor.touch(time.Now())
+	}
+	return n, err
+}
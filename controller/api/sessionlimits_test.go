@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionMonitorIdleTimeout(t *testing.T) {
+	start := time.Now()
+	m := newSessionMonitor(sessionLimits{IdleTimeout: 10 * time.Minute}, start)
+
+	msg, expired := m.check(start.Add(5 * time.Minute))
+	assert.Equal(t, msg, "", "expected no warning")
+	assert.False(t, expired, "expected session to be active")
+
+	msg, expired = m.check(start.Add(9*time.Minute + 30*time.Second))
+	assert.NotEqual(t, msg, "", "expected idle warning")
+	assert.False(t, expired, "expected session to be active")
+
+	// input resets the idle timer
+	m.touch(start.Add(9*time.Minute + 45*time.Second))
+	_, expired = m.check(start.Add(11 * time.Minute))
+	assert.False(t, expired, "expected session to be active after input")
+
+	msg, expired = m.check(start.Add(20 * time.Minute))
+	assert.True(t, expired, "expected idle session to expire")
+}
+
+func TestSessionMonitorMaxDuration(t *testing.T) {
+	start := time.Now()
+	m := newSessionMonitor(sessionLimits{MaxDuration: time.Hour}, start)
+
+	msg, _ := m.check(start.Add(59 * time.Minute))
+	assert.NotEqual(t, msg, "", "expected duration warning")
+
+	// warned once
+	msg, _ = m.check(start.Add(59*time.Minute + 30*time.Second))
+	assert.Equal(t, msg, "", "expected a single warning")
+
+	m.touch(start.Add(59*time.Minute + 50*time.Second))
+	_, expired := m.check(start.Add(time.Hour))
+	assert.True(t, expired, "expected session to expire regardless of activity")
+}
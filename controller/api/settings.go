@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func (a *Api) clusterSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(a.manager.ClusterSettings()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) updateClusterSettings(w http.ResponseWriter, r *http.Request) {
+	var settings *manager.ClusterSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the new endpoint is checked before it replaces the current one
+	if err := a.manager.UpdateCluster(settings, getUsername(r)); err != nil {
+		log.Warnf("error updating cluster endpoint: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if a.proxy != nil {
+		if _, err := a.defaultProxy(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
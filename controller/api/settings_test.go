@@ -0,0 +1,43 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiUpdateClusterSettings(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.updateClusterSettings))
+	defer ts.Close()
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"docker_url": "tcp://10.0.0.2:2375"}`, http.StatusNoContent},
+		{`{"tls_cert": "cert"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", ts.URL, bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, res.StatusCode, tt.status, "unexpected response code for "+tt.body)
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/utils"
+)
+
+// the Docker query parameters guests can set on shared views
+var shareLinkParams = map[string][]string{
+	shipyard.ShareViewLogs:  {"stdout", "stderr", "tail", "timestamps", "since", "follow"},
+	shipyard.ShareViewStats: {"stream"},
+}
+
+type shareLinkRequest struct {
+	ContainerID string   `json:"container_id,omitempty"`
+	Views       []string `json:"views,omitempty"`
+	Description string   `json:"description,omitempty"`
+	// TTL is a duration such as 24h; empty uses the default
+	TTL string `json:"ttl,omitempty"`
+}
+
+type sharedContainer struct {
+	ID     string   `json:"id"`
+	Names  []string `json:"names"`
+	Image  string   `json:"image"`
+	Status string   `json:"status"`
+}
+
+func (a *Api) shareLinks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	links, err := a.manager.ShareLinks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(links); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) addShareLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var req *shareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ttl = d
+	}
+
+	link := &shipyard.ShareLink{
+		ContainerID: req.ContainerID,
+		Views:       req.Views,
+		Description: req.Description,
+		CreatedBy:   getUsername(r),
+	}
+
+	if err := a.manager.NewShareLink(link, ttl); err != nil {
+		log.Errorf("error creating share link: %s", err)
+		switch err {
+		case manager.ErrShareLinkViewDenied:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case dockerclient.ErrNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case manager.ErrShareLinkTTL, manager.ErrShareLinkNoViews, manager.ErrShareLinkNoContainer:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Infof("share link created: id=%s created_by=%s", link.ID, link.CreatedBy)
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(link); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) revokeShareLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := a.manager.RevokeShareLink(id, getUsername(r)); err != nil {
+		if err == manager.ErrShareLinkDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("share link revoked: id=%s", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// useShareLink returns the link of the request token when it grants the
+// view; it writes the error otherwise
+func (a *Api) useShareLink(w http.ResponseWriter, r *http.Request, view string) (*shipyard.ShareLink, bool) {
+	token := mux.Vars(r)["token"]
+
+	link, err := a.manager.UseShareLink(token, view, utils.RemoteIP(r.RemoteAddr))
+	if err != nil {
+		log.Warnf("share link request from %s denied: %s", r.RemoteAddr, err)
+		switch err {
+		case manager.ErrInvalidShareLink:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case manager.ErrShareLinkExpired:
+			http.Error(w, err.Error(), http.StatusGone)
+		case manager.ErrShareLinkViewDenied:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case dockerclient.ErrNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	return link, true
+}
+
+// sharedLink is public; it returns the link of the token without the token
+func (a *Api) sharedLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	link, ok := a.useShareLink(w, r, "")
+	if !ok {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"container_id": link.ContainerID,
+		"views":        link.Views,
+		"description":  link.Description,
+		"expires_at":   link.ExpiresAt,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// sharedContainerView is public; it proxies the Docker logs or stats of the
+// shared container with only the query parameters guests can set
+func (a *Api) sharedContainerView(view string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link, ok := a.useShareLink(w, r, view)
+		if !ok {
+			return
+		}
+
+		query := url.Values{}
+		for _, k := range shareLinkParams[view] {
+			if v := r.URL.Query().Get(k); v != "" {
+				query.Set(k, v)
+			}
+		}
+
+		if view == shipyard.ShareViewLogs && query.Get("stdout") == "" && query.Get("stderr") == "" {
+			query.Set("stdout", "1")
+			query.Set("stderr", "1")
+		}
+
+		proxy, err := a.defaultProxy()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		target, fwd, _ := proxy.target()
+
+		// a new request so no headers of the guest reach docker
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		req.RequestURI = fmt.Sprintf("/containers/%s/%s?%s", url.PathEscape(link.ContainerID), view, query.Encode())
+		req.RemoteAddr = r.RemoteAddr
+
+		fwd.ServeHTTP(w, req)
+	}
+}
+
+// sharedDashboard is public; it returns the nodes and a summary of the
+// containers of the cluster the creator of the link may see
+func (a *Api) sharedDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	link, ok := a.useShareLink(w, r, shipyard.ShareViewDashboard)
+	if !ok {
+		return
+	}
+
+	nodes, err := a.manager.Nodes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	containers, err := a.manager.ShareLinkContainers(link)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summary := []sharedContainer{}
+	for _, c := range containers {
+		summary = append(summary, sharedContainer{
+			ID:     c.Id,
+			Names:  c.Names,
+			Image:  c.Image,
+			Status: c.Status,
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"nodes":      nodes,
+		"containers": summary,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getShareLinkRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/sharelinks", api.shareLinks).Methods("GET")
+	router.HandleFunc("/api/sharelinks", api.addShareLink).Methods("POST")
+	router.HandleFunc("/api/sharelinks/{id}", api.revokeShareLink).Methods("DELETE")
+	router.HandleFunc("/share/{token}", api.sharedLink).Methods("GET")
+	router.HandleFunc("/share/{token}/dashboard", api.sharedDashboard).Methods("GET")
+
+	return router
+}
+
+func TestApiAddShareLink(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getShareLinkRouter(api))
+	defer ts.Close()
+
+	data := []byte(`{"container_id": "abcdefg", "views": ["logs"], "ttl": "2h"}`)
+	res, err := http.Post(ts.URL+"/api/sharelinks", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 201, res.StatusCode, "expected response code 201")
+
+	link := &shipyard.ShareLink{}
+	if err := json.NewDecoder(res.Body).Decode(&link); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, mock_test.TestShareLink.Token, link.Token, "expected the token of the link")
+
+	res, err = http.Post(ts.URL+"/api/sharelinks", "application/json", bytes.NewBufferString(`{"ttl": "forever"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 400, res.StatusCode, "expected response code 400 for an invalid ttl")
+}
+
+func TestApiRevokeShareLink(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getShareLinkRouter(api))
+	defer ts.Close()
+
+	for id, status := range map[string]int{mock_test.TestShareLink.ID: 204, "unknown": 404} {
+		req, err := http.NewRequest("DELETE", ts.URL+"/api/sharelinks/"+id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, status, res.StatusCode, "unexpected response code for "+id)
+	}
+}
+
+func TestApiSharedLink(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getShareLinkRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/share/" + mock_test.TestShareLink.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	info := map[string]interface{}{}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+
+	_, hasToken := info["token"]
+	assert.False(t, hasToken, "expected the token to be left out")
+
+	res, err = http.Get(ts.URL + "/share/invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 404, res.StatusCode, "expected response code 404 for an invalid token")
+
+	// the test link only grants logs
+	res, err = http.Get(ts.URL + "/share/" + mock_test.TestShareLink.Token + "/dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 403, res.StatusCode, "expected response code 403 for a view not granted")
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+const (
+	// maxComposeFile is the size of the largest compose file accepted
+	maxComposeFile = 1024 * 1024
+)
+
+func writeStackError(w http.ResponseWriter, err error) {
+	switch err.(type) {
+	case *manager.ComposeError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case *manager.FreezeError:
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	case *manager.StackLockedError:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case *manager.SmokeTestError:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch err {
+	case manager.ErrStackDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrStackExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrInvalidStackName, manager.ErrInvalidReleaseLink, manager.ErrReleaseNotesTooLong, manager.ErrInvalidStackStepTimeout:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *Api) stacks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	stacks, err := a.manager.Stacks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(stacks); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) stack(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	stack, err := a.manager.Stack(mux.Vars(r)["name"])
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(stack); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// getRelease returns the release notes of a deploy from the notes and link
+// parameters; link can be repeated
+func getRelease(r *http.Request) *shipyard.Release {
+	r.ParseForm()
+
+	notes := r.Form.Get("notes")
+	links := r.Form["link"]
+	if notes == "" && len(links) == 0 {
+		return nil
+	}
+
+	return &shipyard.Release{Notes: notes, Links: links}
+}
+
+// deployStack deploys the docker-compose file in the body as the stack
+// named by the name parameter
+func (a *Api) deployStack(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(w, "you must enter a stack name (param: name)", http.StatusBadRequest)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxComposeFile))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stack, err := a.manager.DeployStack(name, data, getUsername(r), getRelease(r))
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(stack); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) redeployStack(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	stack, err := a.manager.RedeployStack(mux.Vars(r)["name"], getUsername(r), getRelease(r))
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(stack); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// stackDeployments lists the deployments of the stack; deployments of
+// removed stacks and failed first deploys are kept
+func (a *Api) stackDeployments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	deployments, err := a.manager.Deployments(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(deployments); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// stackTop lists the processes of the running containers of the stack
+// with the container and service of each; ps_args are passed to ps on the
+// engines
+func (a *Api) stackTop(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	top, err := a.manager.StackTop(mux.Vars(r)["name"], r.FormValue("ps_args"))
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(top); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) removeStack(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.RemoveStack(mux.Vars(r)["name"], getUsername(r)); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// controlStack stops or starts the stack a service at a time in dependency
+// order; timeout is the duration (i.e. 45s) each service gets. The
+// operation is returned with the status of every step, with a 500 when a
+// step failed or timed out.
+func (a *Api) controlStack(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var timeout time.Duration
+	if v := r.FormValue("timeout"); v != "" {
+		t, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "invalid timeout: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		timeout = t
+	}
+
+	vars := mux.Vars(r)
+	control := a.manager.StartStack
+	if vars["action"] == manager.StackStop {
+		control = a.manager.StopStack
+	}
+
+	op, err := control(vars["name"], timeout, getUsername(r))
+	if err != nil {
+		if _, ok := err.(*manager.StackStepError); !ok || op == nil {
+			writeStackError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	if err := json.NewEncoder(w).Encode(op); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func getStackRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/stacks", api.stacks).Methods("GET")
+	router.HandleFunc("/api/stacks", api.deployStack).Methods("POST")
+	router.HandleFunc("/api/stacks/{name}", api.stack).Methods("GET")
+	router.HandleFunc("/api/stacks/{name}", api.removeStack).Methods("DELETE")
+	router.HandleFunc("/api/stacks/{name}/redeploy", api.redeployStack).Methods("POST")
+	router.HandleFunc("/api/stacks/{name}/deployments", api.stackDeployments).Methods("GET")
+	router.HandleFunc("/api/stacks/{name}/top", api.stackTop).Methods("GET")
+	router.HandleFunc("/api/stacks/{name}/{action:stop|start}", api.controlStack).Methods("POST")
+
+	return router
+}
+
+func TestApiGetStacks(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/stacks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	stacks := []*shipyard.Stack{}
+	if err := json.NewDecoder(res.Body).Decode(&stacks); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, stacks, 1, "expected the test stack")
+
+	res, err = http.Get(ts.URL + "/api/stacks/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 404, res.StatusCode, "expected response code 404")
+}
+
+func TestApiDeployStack(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		compose string
+		status  int
+	}{
+		{"blog", "services:\n  web:\n    image: wordpress\n    depends_on: [db]\n  db:\n    image: mysql\n", 201},
+		{"shop", "services:\n  web:\n    image: nginx\n", 409},
+		{"blog", "services:\n  web:\n    build: .\n", 400},
+		{"", "services:\n  web:\n    image: nginx\n", 400},
+	}
+
+	for _, test := range tests {
+		res, err := http.Post(ts.URL+"/api/stacks?name="+test.name, "application/x-yaml", bytes.NewBufferString(test.compose))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.status, res.StatusCode, "unexpected status for %s", test.name)
+
+		if res.StatusCode == 201 {
+			stack := &shipyard.Stack{}
+			if err := json.NewDecoder(res.Body).Decode(stack); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, []string{"db", "web"}, stack.Services, "expected services in deploy order")
+		}
+	}
+}
+
+func TestApiRemoveStack(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	req, err := http.NewRequest("DELETE", ts.URL+"/api/stacks/shop", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 204, res.StatusCode, "expected response code 204")
+}
+
+func TestApiRedeployLockedStack(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/stacks/checkout/redeploy", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusConflict, res.StatusCode, "expected response code 409")
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(body), "locked by admin (redeploy)", "expected the holder of the lock")
+}
+
+func TestApiRedeployStackSmokeTestFailed(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/stacks/flaky/redeploy", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode, "expected response code 422")
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(body), "rolled back", "expected the deploy to be rolled back")
+
+	res, err = http.Get(ts.URL + "/api/stacks/flaky/deployments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	deployments := []*shipyard.Deployment{}
+	if err := json.NewDecoder(res.Body).Decode(&deployments); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, deployments, 1)
+	assert.Equal(t, shipyard.DeploymentRolledBack, deployments[0].Status)
+	assert.Equal(t, "status 503", deployments[0].SmokeTests[0].Output)
+}
+
+func TestApiRedeployStackInvalidReleaseLink(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/stacks/shop/redeploy?notes=fix&link=ftp://example.com/changelog", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
+
+func TestApiStopStack(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/stacks/shop/stop?timeout=45s", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	op := &shipyard.StackOperation{}
+	if err := json.NewDecoder(res.Body).Decode(op); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "stop", op.Action, "expected a stop")
+	assert.Equal(t, shipyard.StackStepDone, op.Status, "expected the stop to be done")
+	assert.Equal(t, 1, len(op.Steps), "expected a step per service")
+
+	res, err = http.Post(ts.URL+"/api/stacks/shop/stop?timeout=forever", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
+
+func TestApiStartStackStepTimedOut(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/stacks/flaky/start", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode, "expected response code 500")
+
+	op := &shipyard.StackOperation{}
+	if err := json.NewDecoder(res.Body).Decode(op); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, shipyard.StackStepFailed, op.Status, "expected the start to fail")
+	assert.Equal(t, shipyard.StackStepTimedOut, op.Steps[0].Status, "expected the step to time out")
+}
+
+func TestApiStackTop(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStackRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/stacks/shop/top?ps_args=aux")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	top := &manager.StackTop{}
+	if err := json.NewDecoder(res.Body).Decode(top); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(top.Processes), "expected the processes of the running containers")
+	assert.Equal(t, "web", top.Processes[0].Service, "expected the service of the process")
+	assert.Equal(t, 1, len(top.Errors), "expected the container which failed")
+
+	res, err = http.Get(ts.URL + "/api/stacks/unknown/top")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 404, res.StatusCode, "expected response code 404")
+}
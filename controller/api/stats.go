@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// defaultStatsPeriod is returned when no period is given
+const defaultStatsPeriod = time.Hour
+
+// containerStats returns the cpu, memory and network usage of a container
+// over the period (i.e. ?period=30m) oldest sample first
+func (a *Api) containerStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	period := defaultStatsPeriod
+	if v := r.FormValue("period"); v != "" {
+		p, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "invalid period: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		period = p
+	}
+
+	stats, err := a.manager.ContainerStats(id, period)
+	if err != nil {
+		log.Errorf("error getting stats of %s: %s", id, err)
+		switch err {
+		case dockerclient.ErrNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case manager.ErrInvalidStatsPeriod:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
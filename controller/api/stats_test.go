@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getStatsRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/containers/{id}/stats", api.containerStats).Methods("GET")
+
+	return router
+}
+
+func TestApiContainerStats(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getStatsRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/containers/" + mock_test.TestContainerId + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	stats := &shipyard.ContainerStatsHistory{}
+	if err := json.NewDecoder(res.Body).Decode(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "1h0m0s", stats.Period, "expected the default period")
+	assert.Equal(t, 1, len(stats.Samples), "expected the samples of the container")
+
+	res, err = http.Get(ts.URL + "/api/containers/" + mock_test.TestContainerId + "/stats?period=yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+
+	res, err = http.Get(ts.URL + "/api/containers/missing/stats?period=30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+}
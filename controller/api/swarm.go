@@ -2,18 +2,231 @@ package api
 
 import (
 	"bytes"
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mailgun/oxy/forward"
+	"github.com/samalba/dockerclient"
+)
+
+var (
+	// apiVersionPath matches the version docker clients prefix their
+	// requests with (i.e. /v1.24/containers/json)
+	apiVersionPath = regexp.MustCompile(`^/v([0-9]+)\.([0-9]+)/`)
 )
 
+// swarmProxy is the forwarding target for the proxied docker api
+type swarmProxy struct {
+	mu        sync.RWMutex
+	url       string
+	fwd       *forward.Forwarder
+	tlsConfig *tls.Config
+	client    *dockerclient.DockerClient
+	// apiVersion is the newest api version of the target; read on the
+	// first versioned request
+	apiVersion string
+}
+
+func newSwarmProxy(client *dockerclient.DockerClient) (*swarmProxy, error) {
+	p := &swarmProxy{}
+	if err := p.update(client); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// update points the proxy at the client endpoint
+func (p *swarmProxy) update(client *dockerclient.DockerClient) error {
+	// setup redirect target to swarm
+	scheme := "http://"
+
+	fwd, err := forward.New()
+	if err != nil {
+		return err
+	}
+
+	// check if TLS is enabled and configure if so
+	if client.TLSConfig != nil {
+		log.Debug("configuring ssl for swarm redirect")
+		scheme = "https://"
+		// setup custom roundtripper with TLS transport
+		r := forward.RoundTripper(
+			&http.Transport{
+				TLSClientConfig: client.TLSConfig,
+			})
+		fwd, err = forward.New(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	target := fmt.Sprintf("%s%s", scheme, client.URL.Host)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.url = target
+	p.fwd = fwd
+	p.tlsConfig = client.TLSConfig
+	p.client = client
+	p.apiVersion = ""
+
+	log.Debugf("configured docker proxy target: %s", target)
+
+	return nil
+}
+
+// targets reports whether the proxy points at the client endpoint
+func (p *swarmProxy) targets(client *dockerclient.DockerClient) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.client == client
+}
+
+func (p *swarmProxy) target() (string, *forward.Forwarder, *tls.Config) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.url, p.fwd, p.tlsConfig
+}
+
+// backendVersion returns the newest api version of the target from its
+// /version; empty when the target cannot be reached
+func (p *swarmProxy) backendVersion() string {
+	p.mu.RLock()
+	version, client := p.apiVersion, p.client
+	p.mu.RUnlock()
+
+	if version != "" || client == nil {
+		return version
+	}
+
+	v, err := client.Version()
+	if err != nil {
+		log.Warnf("error getting the api version of the docker proxy target: %s", err)
+		return ""
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the target changed while it was asked
+	if p.client == client {
+		p.apiVersion = v.ApiVersion
+	}
+
+	return v.ApiVersion
+}
+
+// negotiateVersion lowers the version of requests from clients newer than
+// the target to the version of the target, which rejects versions it does
+// not know; older versions are served by the target as they are
+func (p *swarmProxy) negotiateVersion(r *http.Request) {
+	match := apiVersionPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		return
+	}
+
+	backend := p.backendVersion()
+	if backend == "" || !apiVersionNewer(match[1]+"."+match[2], backend) {
+		return
+	}
+
+	log.Debugf("docker proxy: negotiated api version %s.%s down to %s: path=%s", match[1], match[2], backend, r.URL.Path)
+
+	r.URL.Path = "/v" + backend + "/" + strings.TrimPrefix(r.URL.Path, match[0])
+	r.URL.RawPath = ""
+	r.RequestURI = r.URL.RequestURI()
+}
+
+// apiVersionNewer reports whether the api version a (i.e. 1.24) is newer
+// than b; versions which cannot be parsed are not newer
+func apiVersionNewer(a, b string) bool {
+	parse := func(v string) (int, int, bool) {
+		parts := strings.SplitN(v, ".", 2)
+		if len(parts) != 2 {
+			return 0, 0, false
+		}
+
+		major, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, false
+		}
+
+		minor, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, false
+		}
+
+		return major, minor, true
+	}
+
+	aMajor, aMinor, ok := parse(a)
+	if !ok {
+		return false
+	}
+
+	bMajor, bMinor, ok := parse(b)
+	if !ok {
+		return false
+	}
+
+	if aMajor != bMajor {
+		return aMajor > bMajor
+	}
+
+	return aMinor > bMinor
+}
+
+// dockerAPIHandler sends the versioned paths of the docker api to the proxy
+// whatever the version and everything else to the mux
+func dockerAPIHandler(proxy http.Handler, mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiVersionPath.MatchString(r.URL.Path) {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
 func (a *Api) swarmRedirect(w http.ResponseWriter, req *http.Request) {
-	var err error
-	req.URL, err = url.ParseRequestURI(a.dUrl)
+	proxy, err := a.clusterProxy(req)
+	if err != nil {
+		writeClusterError(w, err)
+		return
+	}
+	swarm, fwd, _ := proxy.target()
+
+	target, status, err := a.nodeTarget(req, swarm)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	// the header is for the controller only
+	req.Header.Del(clusterHeader)
+
+	// requests routed to a node go to its engine as they are
+	if target == swarm {
+		proxy.negotiateVersion(req)
+	}
+
+	req.URL, err = url.ParseRequestURI(target)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	a.fwd.ServeHTTP(w, req)
+	fwd.ServeHTTP(w, req)
 }
 
 type proxyWriter struct {
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+// getTestSwarm returns a swarm which answers with the uri of the requests
+// it gets and reports the api version
+func getTestSwarm(version string, versionRequests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/version") {
+			*versionRequests++
+			w.Write([]byte(`{"ApiVersion":"` + version + `"}`))
+			return
+		}
+
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+}
+
+func getProxied(t *testing.T, url string) string {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestApiVersionNewer(t *testing.T) {
+	assert.True(t, apiVersionNewer("1.24", "1.22"))
+	assert.True(t, apiVersionNewer("1.100", "1.22"))
+	assert.True(t, apiVersionNewer("2.0", "1.22"))
+	assert.False(t, apiVersionNewer("1.22", "1.22"))
+	assert.False(t, apiVersionNewer("1.18", "1.22"))
+	assert.False(t, apiVersionNewer("1.24", ""))
+}
+
+func TestSwarmRedirectNegotiatesVersion(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versionRequests := 0
+	swarm := getTestSwarm("1.22", &versionRequests)
+	defer swarm.Close()
+
+	client, err := dockerclient.NewDockerClient(swarm.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.proxy, err = newSwarmProxy(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(dockerAPIHandler(http.HandlerFunc(api.swarmRedirect), http.NotFoundHandler()))
+	defer ts.Close()
+
+	assert.Equal(t, "/v1.22/containers/json?all=1", getProxied(t, ts.URL+"/v1.24/containers/json?all=1"), "expected newer clients to use the swarm version")
+	assert.Equal(t, "/v1.18/info", getProxied(t, ts.URL+"/v1.18/info"), "expected older clients to keep their version")
+	assert.Equal(t, "/v1.22/volumes", getProxied(t, ts.URL+"/v1.22/volumes"))
+	assert.Equal(t, 1, versionRequests, "expected the swarm version to be read once")
+
+	res, err := http.Get(ts.URL + "/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected unversioned paths to go to the mux")
+}
+
+func getProxyUpdateRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/v{version:[0-9.]+}/containers/{name:.*}/update", api.proxyContainerUpdate).Methods("POST")
+
+	return router
+}
+
+func TestApiProxyContainerUpdate(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getProxyUpdateRouter(api))
+	defer ts.Close()
+
+	url := ts.URL + "/v1.24/containers/" + mock_test.TestContainerId + "/update"
+
+	res, err := http.Post(url, "application/json", strings.NewReader(`{"Memory":536870912,"BlkioWeight":0,"RestartPolicy":{"Name":"","MaximumRetryCount":0}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	res, err = http.Post(url, "application/json", strings.NewReader(`{"RestartPolicy":{"Name":"always"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	res, err = http.Post(ts.URL+"/v1.24/containers/missing/update", "application/json", strings.NewReader(`{"Memory":536870912}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "expected response code 404")
+
+	res, err = http.Post(url, "application/json", strings.NewReader(`{"Memory":`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*manager.TemplateError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch err {
+	case manager.ErrTemplateDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrTemplateExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrInvalidTTL:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		// deploys fail like stacks
+		writeStackError(w, err)
+	}
+}
+
+// writeBundle sends a bundle as a file download
+func writeBundle(w http.ResponseWriter, bundle *shipyard.TemplateBundle) {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%q", bundle.Metadata.Name+".shipyard.json"))
+
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) templates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	templates, err := a.manager.Templates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(templates); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) template(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	template, err := a.manager.Template(mux.Vars(r)["name"])
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := a.manager.DeleteTemplate(mux.Vars(r)["name"]); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// importTemplate saves the template of the bundle in the body; existing
+// templates are only replaced with replace=true
+func (a *Api) importTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var bundle *shipyard.TemplateBundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	replace, _ := strconv.ParseBool(r.FormValue("replace"))
+
+	template, err := a.manager.ImportTemplate(bundle, getUsername(r), replace)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) exportTemplate(w http.ResponseWriter, r *http.Request) {
+	bundle, err := a.manager.ExportTemplate(mux.Vars(r)["name"])
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	writeBundle(w, bundle)
+}
+
+func (a *Api) exportStack(w http.ResponseWriter, r *http.Request) {
+	bundle, err := a.manager.ExportStack(mux.Vars(r)["name"])
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	writeBundle(w, bundle)
+}
+
+type templateDeployRequest struct {
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
+	// TTL (i.e. 2h) removes the stack once it expires
+	TTL string `json:"ttl,omitempty"`
+}
+
+// deployTemplate deploys the template as a stack with the parameter values
+func (a *Api) deployTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var req *templateDeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		t, err := manager.ParseTTL(req.TTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ttl = t
+	}
+
+	stack, err := a.manager.DeployTemplate(mux.Vars(r)["name"], req.Name, req.Parameters, ttl, getUsername(r))
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(stack); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTemplateRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/stacks/{name}/export", api.exportStack).Methods("GET")
+	router.HandleFunc("/api/templates", api.templates).Methods("GET")
+	router.HandleFunc("/api/templates/import", api.importTemplate).Methods("POST")
+	router.HandleFunc("/api/templates/{name}", api.template).Methods("GET")
+	router.HandleFunc("/api/templates/{name}/export", api.exportTemplate).Methods("GET")
+	router.HandleFunc("/api/templates/{name}/deploy", api.deployTemplate).Methods("POST")
+
+	return router
+}
+
+func TestApiExportTemplate(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getTemplateRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/templates/wordpress/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+	assert.Equal(t, `attachment; filename="wordpress.shipyard.json"`, res.Header.Get("Content-Disposition"))
+
+	bundle := &shipyard.TemplateBundle{}
+	if err := json.NewDecoder(res.Body).Decode(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, shipyard.TemplateBundleVersion, bundle.Version)
+	assert.Equal(t, mock_test.TestTemplate.Parameters, bundle.Parameters)
+	assert.Equal(t, mock_test.TestTemplate.Compose, bundle.Compose)
+
+	res, err = http.Get(ts.URL + "/api/stacks/shop/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected stacks to be exported")
+}
+
+func TestApiImportTemplate(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getTemplateRouter(api))
+	defer ts.Close()
+
+	bundle := mock_test.TestTemplate.Bundle()
+
+	tests := []struct {
+		query   string
+		version int
+		status  int
+	}{
+		{"", shipyard.TemplateBundleVersion, 409},
+		{"?replace=true", shipyard.TemplateBundleVersion, 201},
+		{"?replace=true", 0, 400},
+	}
+
+	for _, test := range tests {
+		bundle.Version = test.version
+
+		data, err := json.Marshal(bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.Post(ts.URL+"/api/templates/import"+test.query, "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.status, res.StatusCode, "unexpected status for version %d %s", test.version, test.query)
+	}
+}
+
+func TestApiDeployTemplate(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getTemplateRouter(api))
+	defer ts.Close()
+
+	data := []byte(`{"name":"blog","parameters":{"PORT":"8081"}}`)
+	for template, status := range map[string]int{"wordpress": 201, "unknown": 404} {
+		res, err := http.Post(ts.URL+"/api/templates/"+template+"/deploy", "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, status, res.StatusCode, "unexpected status for %s", template)
+	}
+
+	for ttl, status := range map[string]int{"2h": 201, "2 hours": 400, "720h": 400} {
+		data := []byte(`{"name":"debug","ttl":"` + ttl + `"}`)
+		res, err := http.Post(ts.URL+"/api/templates/wordpress/deploy", "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, status, res.StatusCode, "unexpected status for ttl %s", ttl)
+	}
+}
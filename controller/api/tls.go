@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/tlsutils"
+)
+
+var (
+	errTLSKeyPair       = errors.New("a tls certificate and key are both required")
+	errTLSRedirect      = errors.New("redirecting http to https requires a tls certificate and key")
+	errTLSClientCA      = errors.New("verifying client certificates requires a tls certificate and key")
+	errInvalidTLSClient = errors.New("no certificates found in the tls client ca")
+)
+
+// validateTLS checks the tls options are consistent
+func validateTLS(config ApiConfig) error {
+	hasTLS := config.TLSCertPath != "" && config.TLSKeyPath != ""
+	if !hasTLS && (config.TLSCertPath != "" || config.TLSKeyPath != "") {
+		return errTLSKeyPair
+	}
+
+	if config.HTTPRedirectAddr != "" && !hasTLS {
+		return errTLSRedirect
+	}
+
+	if config.TLSClientCAPath != "" && !hasTLS {
+		return errTLSClientCA
+	}
+
+	return nil
+}
+
+func (a *Api) tlsEnabled() bool {
+	return a.tlsCertPath != "" && a.tlsKeyPath != ""
+}
+
+// serverTLSConfig returns the tls config of the listener; with a client ca
+// every client has to present a certificate signed by it
+func (a *Api) serverTLSConfig() (*tls.Config, error) {
+	var caCert []byte
+	if a.tlsCACertPath != "" {
+		ca, err := ioutil.ReadFile(a.tlsCACertPath)
+		if err != nil {
+			return nil, err
+		}
+
+		caCert = ca
+	}
+
+	serverCert, err := ioutil.ReadFile(a.tlsCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	serverKey, err := ioutil.ReadFile(a.tlsKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlsutils.GetServerTLSConfig(caCert, serverCert, serverKey, a.allowInsecure)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.tlsClientCAPath != "" {
+		clientCA, err := ioutil.ReadFile(a.tlsClientCAPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// only the client ca is trusted; not the system certificates
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(clientCA) {
+			return nil, errInvalidTLSClient
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+
+		log.Infof("requiring tls client certificates: ca=%s", a.tlsClientCAPath)
+	}
+
+	return tlsConfig, nil
+}
+
+// redirectToTLS sends plain http requests to the https listener
+func (a *Api) redirectToTLS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	if _, port, err := net.SplitHostPort(a.listenAddr); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	target := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+	}
+
+	// 308 keeps the method and body of api requests
+	http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+}
+
+// serveHTTPRedirect runs the plain http listener that redirects to https
+func (a *Api) serveHTTPRedirect() error {
+	log.Infof("redirecting http to https: addr=%s", a.httpRedirectAddr)
+
+	s := &http.Server{
+		Addr:    a.httpRedirectAddr,
+		Handler: http.HandlerFunc(a.redirectToTLS),
+	}
+
+	return s.ListenAndServe()
+}
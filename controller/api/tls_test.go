@@ -0,0 +1,106 @@
+package api
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shipyard/shipyard/tlsutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTLS(t *testing.T) {
+	tests := []struct {
+		config ApiConfig
+		err    error
+	}{
+		{ApiConfig{}, nil},
+		{ApiConfig{TLSCertPath: "cert.pem", TLSKeyPath: "key.pem", HTTPRedirectAddr: ":80", TLSClientCAPath: "ca.pem"}, nil},
+		{ApiConfig{TLSCertPath: "cert.pem"}, errTLSKeyPair},
+		{ApiConfig{HTTPRedirectAddr: ":80"}, errTLSRedirect},
+		{ApiConfig{TLSClientCAPath: "ca.pem"}, errTLSClientCA},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.err, validateTLS(test.config), "test %d", i)
+	}
+}
+
+func TestRedirectToTLS(t *testing.T) {
+	tests := []struct {
+		listenAddr string
+		host       string
+		location   string
+	}{
+		{":8443", "shipyard.local", "https://shipyard.local:8443/api/nodes?all=1"},
+		{":443", "shipyard.local:80", "https://shipyard.local/api/nodes?all=1"},
+		{":8443", "[::1]:80", "https://[::1]:8443/api/nodes?all=1"},
+	}
+
+	for _, test := range tests {
+		api := &Api{listenAddr: test.listenAddr}
+
+		req := httptest.NewRequest("POST", "http://"+test.host+"/api/nodes?all=1", nil)
+		w := httptest.NewRecorder()
+		api.redirectToTLS(w, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, "expected the method to be kept")
+		assert.Equal(t, test.location, w.Header().Get("Location"))
+	}
+}
+
+func TestServerTLSConfigClientCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shipyard-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caCert, caKey, err := tlsutils.GenerateCACertificate("shipyard", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, key, err := tlsutils.GenerateCert([]string{"localhost"}, caCert, caKey, "shipyard", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{"ca.pem": caCert, "cert.pem": cert, "key.pem": key}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := &Api{
+		tlsCertPath: filepath.Join(dir, "cert.pem"),
+		tlsKeyPath:  filepath.Join(dir, "key.pem"),
+	}
+
+	config, err := api.serverTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth, "expected client certificates to be optional")
+
+	api.tlsClientCAPath = filepath.Join(dir, "ca.pem")
+	config, err = api.serverTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth, "expected client certificates to be required")
+	assert.Len(t, config.ClientCAs.Subjects(), 1, "expected only the client ca to be trusted")
+
+	// a file without certificates is refused
+	api.tlsClientCAPath = filepath.Join(dir, "key.pem")
+	if _, err := api.serverTLSConfig(); err != errInvalidTLSClient {
+		t.Fatalf("expected %s; received %v", errInvalidTLSClient, err)
+	}
+}
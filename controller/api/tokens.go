@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func tokenError(err error) int {
+	switch err {
+	case manager.ErrAccountDoesNotExist, manager.ErrAuthTokenDoesNotExist:
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (a *Api) authTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	username := mux.Vars(r)["username"]
+
+	tokens, err := a.manager.AuthTokens(username)
+	if err != nil {
+		http.Error(w, err.Error(), tokenError(err))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) revokeAuthToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	username := vars["username"]
+	id := vars["id"]
+
+	if err := a.manager.RevokeAuthToken(username, id); err != nil {
+		http.Error(w, err.Error(), tokenError(err))
+		return
+	}
+
+	log.Infof("revoked token: username=%s id=%s", username, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) revokeAuthTokens(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	if err := a.manager.RevokeAuthTokens(username); err != nil {
+		http.Error(w, err.Error(), tokenError(err))
+		return
+	}
+
+	log.Infof("revoked all tokens: username=%s", username)
+	w.WriteHeader(http.StatusNoContent)
+}
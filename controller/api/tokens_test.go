@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTokenRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/accounts/{username}/tokens", api.authTokens).Methods("GET")
+	router.HandleFunc("/api/accounts/{username}/tokens", api.revokeAuthTokens).Methods("DELETE")
+	router.HandleFunc("/api/accounts/{username}/tokens/{id}", api.revokeAuthToken).Methods("DELETE")
+
+	return router
+}
+
+func TestApiGetAuthTokens(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getTokenRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/accounts/testuser/tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, res.StatusCode, "expected response code 200")
+
+	tokens := []*auth.AuthToken{}
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(tokens), "expected one token")
+	assert.Equal(t, "", tokens[0].Token, "expected the token to be omitted")
+	assert.Equal(t, auth.TokenID("test-token"), tokens[0].ID)
+}
+
+func TestApiRevokeAuthToken(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getTokenRouter(api))
+	defer ts.Close()
+
+	for id, expected := range map[string]int{
+		auth.TokenID("test-token"): http.StatusNoContent,
+		"unknown":                  http.StatusNotFound,
+	} {
+		req, err := http.NewRequest("DELETE", ts.URL+"/api/accounts/testuser/tokens/"+id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, res.StatusCode, "unexpected status for "+id)
+	}
+}
+
+func TestApiRefreshToken(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.refreshToken))
+	defer ts.Close()
+
+	for header, expected := range map[string]int{
+		"testuser:test-token": http.StatusOK,
+		"testuser:expired":    http.StatusUnauthorized,
+		"":                    http.StatusUnauthorized,
+	} {
+		req, err := http.NewRequest("POST", ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Access-Token", header)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, res.StatusCode, "unexpected status for "+header)
+
+		if res.StatusCode == http.StatusOK {
+			token := &auth.AuthToken{}
+			if err := json.NewDecoder(res.Body).Decode(token); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "refreshed-token", token.Token)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeTOTPError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrAccountDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrTOTPEnabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrTOTPNotEnrolled, manager.ErrTOTPRequired, manager.ErrInvalidTOTPCode:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// canManageTOTP reports whether the user of the request may manage the
+// two-factor authentication of the account; users manage their own and
+// account managers the ones of everyone. Requests without an
+// authenticated user, such as ones with a service key, are refused.
+func (a *Api) canManageTOTP(w http.ResponseWriter, r *http.Request, username string) bool {
+	user := getUsername(r)
+	if user == "" {
+		http.Error(w, "two-factor authentication can only be managed by an authenticated user", http.StatusForbidden)
+		return false
+	}
+
+	if user == username {
+		return true
+	}
+
+	allowed, err := a.manager.HasPermission(user, auth.PermAccountsManage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if !allowed {
+		http.Error(w, "only the user or account managers can manage two-factor authentication", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// enrollTOTP returns a new secret and its provisioning uri; two-factor
+// authentication is enabled once confirmed with a code
+func (a *Api) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	username := mux.Vars(r)["username"]
+	if !a.canManageTOTP(w, r, username) {
+		return
+	}
+
+	enrollment, err := a.manager.EnrollTOTP(username)
+	if err != nil {
+		writeTOTPError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(enrollment); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) confirmTOTP(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if !a.canManageTOTP(w, r, username) {
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.ConfirmTOTP(username, body.Code); err != nil {
+		writeTOTPError(w, err)
+		return
+	}
+
+	log.Infof("enabled two-factor authentication: username=%s", username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// disableTOTP removes the second factor of the account; users disabling
+// their own have to give a code so a stolen token is not enough
+func (a *Api) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if !a.canManageTOTP(w, r, username) {
+		return
+	}
+
+	user := getUsername(r)
+	if user == username {
+		if err := a.manager.CheckTOTP(username, r.URL.Query().Get("code")); err != nil {
+			writeTOTPError(w, err)
+			return
+		}
+	}
+
+	if err := a.manager.DisableTOTP(username, user); err != nil {
+		writeTOTPError(w, err)
+		return
+	}
+
+	log.Infof("disabled two-factor authentication: username=%s", username)
+	w.WriteHeader(http.StatusNoContent)
+}
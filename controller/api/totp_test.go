@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTOTPRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/accounts/{username}/2fa", api.enrollTOTP).Methods("POST")
+	router.HandleFunc("/api/accounts/{username}/2fa", api.confirmTOTP).Methods("PUT")
+	router.HandleFunc("/api/accounts/{username}/2fa", api.disableTOTP).Methods("DELETE")
+
+	return router
+}
+
+func totpRequest(t *testing.T, method, url, user, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Access-Token", user+":token")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestApiEnrollTOTP(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(authenticated(getTOTPRouter(api)))
+	defer ts.Close()
+
+	url := ts.URL + "/api/accounts/" + mock_test.TestAccount.Username + "/2fa"
+
+	res := totpRequest(t, "POST", url, mock_test.TestAccount.Username, "")
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected response code 201")
+
+	enrollment := &manager.TOTPEnrollment{}
+	if err := json.NewDecoder(res.Body).Decode(enrollment); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, mock_test.TestTOTPSecret, enrollment.Secret)
+
+	res = totpRequest(t, "PUT", url, mock_test.TestAccount.Username, `{"code":"000000"}`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+
+	res = totpRequest(t, "PUT", url, mock_test.TestAccount.Username, `{"code":"`+mock_test.TestTOTPCode+`"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
+
+func TestApiTOTPOtherAccount(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(authenticated(getTOTPRouter(api)))
+	defer ts.Close()
+
+	// only account managers manage the second factor of others
+	res := totpRequest(t, "POST", ts.URL+"/api/accounts/"+mock_test.TestAccount.Username+"/2fa", "other", "")
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "expected response code 403")
+
+	res = totpRequest(t, "DELETE", ts.URL+"/api/accounts/other/2fa", mock_test.TestAccount.Username, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "expected response code 204")
+}
+
+func TestApiTOTPWithoutUser(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(authenticated(getTOTPRouter(api)))
+	defer ts.Close()
+
+	url := ts.URL + "/api/accounts/" + mock_test.TestAccount.Username + "/2fa"
+
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "expected response code 403 without a user")
+
+	// the token of a service key request is not checked by the auth middleware
+	req, err = http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Service-Key", "key")
+	req.Header.Set("X-Access-Token", mock_test.TestAccount.Username+":token")
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "expected response code 403 for a service key")
+}
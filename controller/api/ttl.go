@@ -0,0 +1,39 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+// ttlGuard refuses to create containers with an invalid ttl label, which
+// would otherwise never expire
+func (a *Api) ttlGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCreateBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+
+		var config struct {
+			Labels map[string]string
+		}
+		// docker reports invalid configs itself
+		json.Unmarshal(data, &config)
+
+		if ttl, ok := config.Labels[manager.LabelTTL]; ok {
+			if _, err := manager.ParseTTL(ttl); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
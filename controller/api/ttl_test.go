@@ -0,0 +1,38 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLGuard(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := false
+	guarded := api.ttlGuard(func(w http.ResponseWriter, r *http.Request) {
+		created = true
+	})
+
+	for body, status := range map[string]int{
+		`{"Image":"busybox","Labels":{"com.shipyard.ttl":"30m"}}`:  http.StatusOK,
+		`{"Image":"busybox","Labels":{"com.shipyard.ttl":"soon"}}`: http.StatusBadRequest,
+		`{"Image":"busybox","Labels":{"com.shipyard.ttl":"0s"}}`:   http.StatusBadRequest,
+		`{"Image":"busybox"}`: http.StatusOK,
+	} {
+		created = false
+
+		req := httptest.NewRequest("POST", "/containers/create", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		guarded(w, req)
+
+		assert.Equal(t, status, w.Code, "unexpected status for %s", body)
+		assert.Equal(t, status == http.StatusOK, created, "unexpected create for %s", body)
+	}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+const (
+	// maxUsageClients and maxUsageOperations bound the usage kept; the
+	// operations of a client past the limit are counted as usageOther
+	maxUsageClients    = 1000
+	maxUsageOperations = 100
+
+	usageOther = "other"
+
+	usageSortRequests = "requests"
+	usageSortTime     = "time"
+)
+
+type (
+	// operationUsage is how often and how long a client called an
+	// operation, the method and resource of the requests (i.e.
+	// GET /api/containers); hijacked connections, such as websockets and
+	// exec, are counted but not timed
+	operationUsage struct {
+		Operation    string  `json:"operation,omitempty"`
+		Requests     int64   `json:"requests"`
+		ClientErrors int64   `json:"client_errors"`
+		ServerErrors int64   `json:"server_errors"`
+		TimeMs       float64 `json:"time_ms"`
+		AvgLatencyMs float64 `json:"avg_latency_ms"`
+		MaxLatencyMs float64 `json:"max_latency_ms"`
+
+		timed int64
+	}
+
+	// clientUsage is the usage of the api by an account or a service key
+	// since the controller started
+	clientUsage struct {
+		operationUsage
+		Client      string            `json:"client"`
+		Description string            `json:"description,omitempty"`
+		FirstSeen   time.Time         `json:"first_seen"`
+		LastSeen    time.Time         `json:"last_seen"`
+		Operations  []*operationUsage `json:"operations"`
+
+		operations map[string]*operationUsage
+	}
+
+	// usageTracker counts the authenticated requests of every account and
+	// service key; the usage is kept in memory by each controller
+	usageTracker struct {
+		now     func() time.Time
+		mu      sync.Mutex
+		clients map[string]*clientUsage
+	}
+)
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{
+		now:     time.Now,
+		clients: map[string]*clientUsage{},
+	}
+}
+
+func (o *operationUsage) add(status int, latency time.Duration, timed bool) {
+	o.Requests++
+	switch {
+	case status >= 500:
+		o.ServerErrors++
+	case status >= 400:
+		o.ClientErrors++
+	}
+
+	if !timed {
+		return
+	}
+
+	ms := float64(latency) / float64(time.Millisecond)
+	o.timed++
+	o.TimeMs += ms
+	o.AvgLatencyMs = o.TimeMs / float64(o.timed)
+	if ms > o.MaxLatencyMs {
+		o.MaxLatencyMs = ms
+	}
+}
+
+// record counts the request of the client to the operation
+func (u *usageTracker) record(client, operation string, status int, latency time.Duration, timed bool) {
+	now := u.now()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	c, ok := u.clients[client]
+	if !ok {
+		if len(u.clients) >= maxUsageClients {
+			return
+		}
+
+		c = &clientUsage{
+			Client:     client,
+			FirstSeen:  now,
+			operations: map[string]*operationUsage{},
+		}
+		u.clients[client] = c
+	}
+
+	o, ok := c.operations[operation]
+	if !ok {
+		if len(c.operations) >= maxUsageOperations {
+			operation = usageOther
+		}
+
+		if o, ok = c.operations[operation]; !ok {
+			o = &operationUsage{Operation: operation}
+			c.operations[operation] = o
+		}
+	}
+
+	c.LastSeen = now
+	c.add(status, latency, timed)
+	o.add(status, latency, timed)
+}
+
+// list returns the usage of the clients sorted by requests or by time,
+// most first; limit 0 returns every client
+func (u *usageTracker) list(by string, limit int) []*clientUsage {
+	return sortUsage(u.snapshot(), by, limit)
+}
+
+// snapshot copies the usage of the clients
+func (u *usageTracker) snapshot() []*clientUsage {
+	u.mu.Lock()
+	usage := []*clientUsage{}
+	for _, c := range u.clients {
+		cu := *c
+		cu.Operations = []*operationUsage{}
+		for _, o := range c.operations {
+			ou := *o
+			cu.Operations = append(cu.Operations, &ou)
+		}
+		usage = append(usage, &cu)
+	}
+	u.mu.Unlock()
+
+	return usage
+}
+
+// sortUsage sorts the clients and their operations by requests or by time,
+// most first, and keeps the first limit clients
+func sortUsage(usage []*clientUsage, by string, limit int) []*clientUsage {
+	less := func(a, b *operationUsage) bool {
+		if by == usageSortTime && a.TimeMs != b.TimeMs {
+			return a.TimeMs > b.TimeMs
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Operation < b.Operation
+	}
+
+	for _, c := range usage {
+		sort.Slice(c.Operations, func(i, j int) bool {
+			return less(c.Operations[i], c.Operations[j])
+		})
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		a, b := &usage[i].operationUsage, &usage[j].operationUsage
+		if by == usageSortTime && a.TimeMs != b.TimeMs {
+			return a.TimeMs > b.TimeMs
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return usage[i].Client < usage[j].Client
+	})
+
+	if limit > 0 && len(usage) > limit {
+		usage = usage[:limit]
+	}
+
+	return usage
+}
+
+// HandlerFuncWithNext counts the request once it is served; it runs after
+// the authentication so only the accounts and service keys of the
+// controller are tracked
+func (u *usageTracker) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := u.now()
+
+	rw, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+
+	// the client and path are read before the handlers, which may
+	// rewrite them
+	client := requestClient(r)
+	operation := manager.UsageOperation(r.Method, r.URL.Path)
+
+	next(rw, r)
+
+	// hijacked connections last as long as the client keeps them open
+	status := rw.Status()
+	u.record(client, operation, status, u.now().Sub(start), status != 0)
+}
+
+// describeServiceKeys adds the description of the service keys to their
+// usage; keys are named by their id so the secret is not revealed
+func (a *Api) describeServiceKeys(usage []*clientUsage) error {
+	keys, err := a.manager.ServiceKeys()
+	if err != nil {
+		return err
+	}
+
+	descriptions := map[string]string{}
+	for _, k := range keys {
+		descriptions["service-key:"+auth.KeyID(k.Key)] = k.Description
+	}
+
+	for _, c := range usage {
+		c.Description = descriptions[c.Client]
+	}
+
+	return nil
+}
+
+// accountUsage lists the requests and latencies of every account and
+// service key so automation loading the controller can be found; sort=time
+// orders them by the time spent on their requests and limit keeps the
+// first ones
+func (a *Api) accountUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	by := r.FormValue("sort")
+	switch by {
+	case "", usageSortRequests, usageSortTime:
+	default:
+		http.Error(w, "sort has to be requests or time", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if v := r.FormValue("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 0 {
+			http.Error(w, "limit has to be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	usage := a.usage.list(by, limit)
+	if err := a.describeServiceKeys(usage); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageTracker(t *testing.T) {
+	tracker := newUsageTracker()
+	start := time.Now()
+	calls := 0
+	tracker.now = func() time.Time {
+		// the clock moves 10ms every time it is read, once before and
+		// once after the request
+		calls++
+		return start.Add(time.Duration(calls) * 10 * time.Millisecond)
+	}
+
+	n := negroni.New()
+	n.Use(negroni.HandlerFunc(tracker.HandlerFuncWithNext))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/containers/missing/json":
+			http.NotFound(w, r)
+		case "/api/stacks":
+			http.Error(w, "engine unavailable", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	})
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	get := func(path, header, value string) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(header, value)
+
+		if _, err := http.DefaultClient.Do(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get("/api/containers/json", "X-Access-Token", "alice:abc")
+	get("/api/containers/missing/json", "X-Access-Token", "alice:abc")
+	get("/api/stacks", "X-Access-Token", "alice:abc")
+	get("/api/containers/json", "X-Service-Key", "0123456789abcdef")
+
+	usage := tracker.list(usageSortRequests, 0)
+	if len(usage) != 2 {
+		t.Fatalf("expected the usage of 2 clients; received %d", len(usage))
+	}
+
+	alice := usage[0]
+	assert.Equal(t, "user:alice", alice.Client)
+	assert.Equal(t, int64(3), alice.Requests)
+	assert.Equal(t, int64(1), alice.ClientErrors)
+	assert.Equal(t, int64(1), alice.ServerErrors)
+	assert.Equal(t, 30.0, alice.TimeMs)
+	assert.Equal(t, 10.0, alice.AvgLatencyMs)
+	assert.Equal(t, 2, len(alice.Operations))
+	assert.Equal(t, "GET /api/containers", alice.Operations[0].Operation)
+	assert.Equal(t, int64(2), alice.Operations[0].Requests)
+
+	key := usage[1]
+	assert.Equal(t, "service-key:"+auth.KeyID("0123456789abcdef"), key.Client, "expected the key to be named by its id")
+	assert.Equal(t, int64(1), key.Requests)
+	assert.Equal(t, 10.0, key.AvgLatencyMs, "expected the latency of the key to be recorded")
+
+	assert.Equal(t, 1, len(tracker.list(usageSortTime, 1)), "expected the limit to keep the first client")
+}
+
+func TestUsageTrackerBounds(t *testing.T) {
+	tracker := newUsageTracker()
+
+	for i := 0; i < maxUsageOperations+5; i++ {
+		tracker.record("user:alice", "GET /api/"+string(rune('a'+i%26))+string(rune('a'+i/26)), 200, time.Millisecond, true)
+	}
+
+	usage := tracker.list(usageSortRequests, 0)
+	assert.Equal(t, maxUsageOperations+1, len(usage[0].Operations), "expected the operations past the limit to be counted as other")
+	assert.Equal(t, int64(maxUsageOperations+5), usage[0].Requests)
+}
+
+func TestApiAccountUsage(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.usage.record("user:alice", "GET /api/containers", 200, time.Millisecond, true)
+	api.usage.record("user:alice", "GET /api/nodes", 200, time.Millisecond, true)
+	api.usage.record("service-key:"+auth.KeyID(mock_test.TestServiceKey.Key), "GET /api/containers", 200, 5*time.Millisecond, true)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/usage/accounts", api.accountUsage).Methods("GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/usage/accounts?sort=time&limit=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	usage := []*clientUsage{}
+	if err := json.NewDecoder(res.Body).Decode(&usage); err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected the usage of 2 clients; received %d", len(usage))
+	}
+	key := usage[0]
+	assert.Equal(t, "service-key:"+auth.KeyID(mock_test.TestServiceKey.Key), key.Client, "expected the key spending the most time first")
+	assert.Equal(t, mock_test.TestServiceKey.Description, key.Description)
+	assert.Equal(t, 5.0, key.TimeMs)
+	assert.Equal(t, "user:alice", usage[1].Client)
+	assert.Equal(t, "", usage[1].Description)
+
+	res, err = http.Get(ts.URL + "/api/usage/accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(body), mock_test.TestServiceKey.Key, "expected the key to be left out")
+
+	res, err = http.Get(ts.URL + "/api/usage/accounts?sort=size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+func writeVolumeError(w http.ResponseWriter, err error) {
+	switch err {
+	case manager.ErrVolumeDoesNotExist, manager.ErrNodeDoesNotExist:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case manager.ErrVolumeInUse:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case manager.ErrVolumeNodeRequired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// volumes lists the volumes of every node with the node they are on; node
+// limits the list to a node and dangling=true to volumes no container uses
+func (a *Api) volumes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	query := r.URL.Query()
+	volumes, err := a.manager.Volumes(query.Get("node"), query.Get("dangling") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(volumes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) createVolume(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	request := &manager.VolumeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	volume, err := a.manager.CreateVolume(request, getUsername(r))
+	if err != nil {
+		log.Errorf("error creating volume: %s", err)
+		writeVolumeError(w, err)
+		return
+	}
+
+	log.Infof("created volume: node=%s name=%s", volume.Node, volume.Name)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(volume); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) removeVolume(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	node := vars["node"]
+	name := vars["name"]
+
+	if err := a.manager.RemoveVolume(node, name, getUsername(r)); err != nil {
+		log.Errorf("error removing volume %s of %s: %s", name, node, err)
+		writeVolumeError(w, err)
+		return
+	}
+
+	log.Infof("removed volume: node=%s name=%s", node, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pruneVolumes removes the dangling volumes of every node or of the node
+// and returns them
+func (a *Api) pruneVolumes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	node := r.URL.Query().Get("node")
+	removed, err := a.manager.PruneVolumes(node, getUsername(r))
+	if err != nil {
+		log.Errorf("error pruning volumes: %s", err)
+		writeVolumeError(w, err)
+		return
+	}
+
+	log.Infof("pruned volumes: node=%s removed=%d", node, len(removed))
+	if err := json.NewEncoder(w).Encode(removed); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
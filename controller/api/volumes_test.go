@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/mock_test"
+	"github.com/stretchr/testify/assert"
+)
+
+func getVolumesRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/volumes", api.volumes).Methods("GET")
+	router.HandleFunc("/api/volumes", api.createVolume).Methods("POST")
+	router.HandleFunc("/api/volumes/prune", api.pruneVolumes).Methods("POST")
+	router.HandleFunc("/api/volumes/{node}/{name}", api.removeVolume).Methods("DELETE")
+
+	return router
+}
+
+func TestApiVolumes(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getVolumesRouter(api))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/api/volumes?dangling=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+
+	volumes := []*shipyard.Volume{}
+	if err := json.NewDecoder(res.Body).Decode(&volumes); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(volumes), "expected 1 volume")
+	assert.Equal(t, mock_test.TestVolume.Node, volumes[0].Node, "expected the node of the volume")
+}
+
+func TestApiCreateVolume(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getVolumesRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/volumes", "application/json", strings.NewReader(`{"node":"testnode","name":"data"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected response code 201")
+
+	res, err = http.Post(ts.URL+"/api/volumes", "application/json", strings.NewReader(`{"name":"data"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected response code 400")
+}
+
+func TestApiRemoveVolume(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getVolumesRouter(api))
+	defer ts.Close()
+
+	for path, status := range map[string]int{
+		"/api/volumes/testnode/data":    http.StatusNoContent,
+		"/api/volumes/testnode/missing": http.StatusNotFound,
+	} {
+		req, err := http.NewRequest("DELETE", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, status, res.StatusCode, path)
+	}
+}
+
+func TestApiPruneVolumes(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getVolumesRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/volumes/prune", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode, "expected response code 200")
+}
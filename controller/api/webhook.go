@@ -2,14 +2,96 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/ci"
+	"github.com/shipyard/shipyard/controller/manager"
 	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/utils"
 )
 
+const (
+	// maxWebhookBody is the size of the largest webhook read; pushes with
+	// many commits can be large
+	maxWebhookBody = 5 * 1024 * 1024
+	// webhookTestPusher is the pusher of simulated hub webhooks
+	webhookTestPusher = "shipyard-test"
+)
+
+var errWebhookNoRepository = errors.New("the webhook has no repository")
+
+// webhookTest is what a Docker Hub push for a webhook key would do
+type webhookTest struct {
+	Payload *dockerhub.Webhook `json:"payload"`
+	Matched bool               `json:"matched"`
+	// Reason is why the webhook would be refused
+	Reason string                `json:"reason,omitempty"`
+	Image  string                `json:"image,omitempty"`
+	Plan   *manager.RedeployPlan `json:"plan,omitempty"`
+}
+
+// hubWebhookImage returns the image a hub webhook redeploys or an error
+// when the repository does not match the image of the key
+func hubWebhookImage(key *dockerhub.WebhookKey, webhook *dockerhub.Webhook) (string, error) {
+	if webhook.Repository == nil {
+		return "", errWebhookNoRepository
+	}
+
+	if strings.Index(webhook.Repository.RepoName, key.Image) == -1 {
+		return "", fmt.Errorf("webhook key image does not match: repo=%s image=%s", webhook.Repository.RepoName, key.Image)
+	}
+
+	image := webhook.Repository.RepoName
+	if webhook.PushData != nil && webhook.PushData.Tag != "" {
+		image = image + ":" + webhook.PushData.Tag
+	}
+
+	return image, nil
+}
+
+// testHubWebhook returns the payload Docker Hub sends for a push of the
+// image of the key; the tag of the image is pushed, latest without one
+func testHubWebhook(key *dockerhub.WebhookKey, tag string, now time.Time) *dockerhub.Webhook {
+	repo := key.Image
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		if tag == "" {
+			tag = repo[i+1:]
+		}
+		repo = repo[:i]
+	}
+
+	if tag == "" {
+		tag = "latest"
+	}
+
+	namespace, name := "library", repo
+	if i := strings.LastIndex(repo, "/"); i > -1 {
+		namespace, name = repo[:i], repo[i+1:]
+	}
+
+	return &dockerhub.Webhook{
+		PushData: &dockerhub.PushData{
+			PushedAt: int(now.Unix()),
+			Pusher:   webhookTestPusher,
+			Tag:      tag,
+		},
+		Repository: &dockerhub.Repository{
+			Status:    "Active",
+			RepoName:  repo,
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
 func (a *Api) hubWebhook(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -25,11 +107,127 @@ func (a *Api) hubWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if strings.Index(webhook.Repository.RepoName, key.Image) == -1 {
-		log.Errorf("webhook key image does not match: repo=%s image=%s", webhook.Repository.RepoName, key.Image)
+	image, err := hubWebhookImage(key, webhook)
+	if err != nil {
+		log.Error(err)
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 	log.Infof("received webhook notification for %s", webhook.Repository.RepoName)
-	// TODO @ehazlett - redeploy containers
+
+	// pulling can take a while; respond to the hub right away
+	go a.redeploy(key, image)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// testWebhookKey simulates a Docker Hub push for the image of the key and
+// returns whether the webhook would match and what the redeploy would do
+func (a *Api) testWebhookKey(w http.ResponseWriter, r *http.Request) {
+	key, err := a.manager.WebhookKey(mux.Vars(r)["id"])
+	if err != nil {
+		if err == manager.ErrWebhookKeyDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := &webhookTest{Payload: testHubWebhook(key, r.URL.Query().Get("tag"), time.Now())}
+
+	image, err := hubWebhookImage(key, result.Payload)
+	if err != nil {
+		result.Reason = err.Error()
+	} else {
+		plan, err := a.manager.PlanRedeploy(image)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Matched = true
+		result.Image = image
+		result.Plan = plan
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// redeploy redeploys the image of a webhook; keys with a max severity
+// have the image scanned first and refuse it above the severity
+func (a *Api) redeploy(key *dockerhub.WebhookKey, image string) {
+	if key.MaxSeverity != "" {
+		if err := a.manager.CheckImageScan(image, key.MaxSeverity); err != nil {
+			log.Warnf("refusing to redeploy %s: %s", image, err)
+			return
+		}
+	}
+
+	result := a.manager.RedeployImage(image)
+	log.Infof("redeployed %s: containers=%d errors=%d", image, len(result.Redeployed), len(result.Errors))
+	for _, e := range result.Errors {
+		log.Errorf("redeploy error: %s", e)
+	}
+}
+
+// ciWebhook receives the push and pipeline webhooks of GitHub or GitLab
+// and redeploys the image of the webhook key; deliveries have to be
+// signed (GitHub) or carry the token (GitLab) with the secret of the key
+func (a *Api) ciWebhook(provider string) http.HandlerFunc {
+	kindHeader, signatureHeader := ci.GitHubEventHeader, ci.GitHubSignatureHeader
+	if provider == ci.ProviderGitLab {
+		kindHeader, signatureHeader = ci.GitLabEventHeader, ci.GitLabTokenHeader
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		key, err := a.manager.WebhookKey(id)
+		if err != nil {
+			log.Errorf("invalid webook key: id=%s from %s", id, r.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		// keys created before the secret was added cannot be verified
+		if key.Secret == "" {
+			http.Error(w, "the webhook key has no secret; create a new key", http.StatusForbidden)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !ci.Verify(provider, key.Secret, body, r.Header.Get(signatureHeader)) {
+			log.Warnf("invalid %s webhook signature: image=%s from %s", provider, key.Image, utils.RemoteIP(r.RemoteAddr))
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		evt, err := ci.Parse(provider, r.Header.Get(kindHeader), body)
+		if err != nil {
+			log.Errorf("error parsing %s webhook: %s", provider, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !evt.Trigger || (key.Branch != "" && evt.Ref != key.Branch) {
+			log.Debugf("ignoring %s webhook: event=%q ref=%s image=%s", provider, evt.Kind, evt.Ref, key.Image)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		log.Infof("received %s webhook: event=%q ref=%s image=%s", provider, evt.Kind, evt.Ref, key.Image)
+
+		go a.redeploy(key, key.Image)
+
+		w.WriteHeader(http.StatusAccepted)
+	}
 }
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard/ci"
+	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/stretchr/testify/assert"
+)
+
+func getWebhookRouter(api *Api) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/hub/github/{id}", api.ciWebhook(ci.ProviderGitHub)).Methods("POST")
+	router.HandleFunc("/hub/gitlab/{id}", api.ciWebhook(ci.ProviderGitLab)).Methods("POST")
+	router.HandleFunc("/api/webhookkeys/{id}/test", api.testWebhookKey).Methods("POST")
+
+	return router
+}
+
+func githubSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestApiCIWebhook(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getWebhookRouter(api))
+	defer ts.Close()
+
+	master := []byte(`{"ref":"refs/heads/master"}`)
+	dev := []byte(`{"ref":"refs/heads/dev"}`)
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+		body    []byte
+		status  int
+	}{
+		{"/hub/github/abcdefg", map[string]string{ci.GitHubEventHeader: "push", ci.GitHubSignatureHeader: githubSignature("secret", master)}, master, http.StatusAccepted},
+		{"/hub/github/abcdefg", map[string]string{ci.GitHubEventHeader: "push", ci.GitHubSignatureHeader: githubSignature("guess", master)}, master, http.StatusUnauthorized},
+		{"/hub/github/abcdefg", map[string]string{ci.GitHubEventHeader: "push"}, master, http.StatusUnauthorized},
+		{"/hub/github/abcdefg", map[string]string{ci.GitHubEventHeader: "push", ci.GitHubSignatureHeader: githubSignature("secret", dev)}, dev, http.StatusOK},
+		{"/hub/github/abcdefg", map[string]string{ci.GitHubEventHeader: "ping", ci.GitHubSignatureHeader: githubSignature("secret", master)}, master, http.StatusOK},
+		{"/hub/github/unknown", map[string]string{ci.GitHubEventHeader: "push", ci.GitHubSignatureHeader: githubSignature("secret", master)}, master, http.StatusNotFound},
+		{"/hub/gitlab/abcdefg", map[string]string{ci.GitLabEventHeader: "Pipeline Hook", ci.GitLabTokenHeader: "secret"}, []byte(`{"object_attributes":{"ref":"master","status":"success"}}`), http.StatusAccepted},
+		{"/hub/gitlab/abcdefg", map[string]string{ci.GitLabEventHeader: "Push Hook", ci.GitLabTokenHeader: "guess"}, master, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("POST", ts.URL+test.path, bytes.NewBuffer(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, test.status, res.StatusCode, "unexpected status for %s %v", test.path, test.headers)
+	}
+}
+
+func TestApiTestWebhookKey(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(getWebhookRouter(api))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/api/webhookkeys/abcdefg/test?tag=v2", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var result *webhookTest
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, result.Matched, "expected the simulated push to match: %s", result.Reason)
+	assert.Equal(t, "ehazlett/test:v2", result.Image)
+	assert.Equal(t, webhookTestPusher, result.Payload.PushData.Pusher)
+	assert.Equal(t, manager.RedeployActionRedeploy, result.Plan.Containers[0].Action)
+
+	res, err = http.Post(ts.URL+"/api/webhookkeys/unknown/test", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestHubWebhookImage(t *testing.T) {
+	now := time.Now()
+
+	webhook := testHubWebhook(&dockerhub.WebhookKey{Image: "registry.local:5000/web"}, "", now)
+	assert.Equal(t, "registry.local:5000/web", webhook.Repository.RepoName)
+	assert.Equal(t, "registry.local:5000", webhook.Repository.Namespace)
+	assert.Equal(t, "latest", webhook.PushData.Tag)
+
+	image, err := hubWebhookImage(&dockerhub.WebhookKey{Image: "web"}, webhook)
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.local:5000/web:latest", image)
+
+	// the hub sends the repository without the tag so keys of a tagged
+	// image never match
+	key := &dockerhub.WebhookKey{Image: "nginx:1.9"}
+	webhook = testHubWebhook(key, "", now)
+	assert.Equal(t, "1.9", webhook.PushData.Tag)
+
+	_, err = hubWebhookImage(key, webhook)
+	assert.Error(t, err)
+
+	_, err = hubWebhookImage(key, &dockerhub.Webhook{})
+	assert.Equal(t, errWebhookNoRepository, err)
+}
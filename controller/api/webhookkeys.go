@@ -6,6 +6,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/shipyard/shipyard"
 	"github.com/shipyard/shipyard/dockerhub"
 )
 
@@ -45,8 +46,13 @@ func (a *Api) addWebhookKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	key, err := a.manager.NewWebhookKey(k.Image)
+	key, err := a.manager.NewWebhookKey(k.Image, k.Branch, k.MaxSeverity)
 	if err != nil {
+		if err == shipyard.ErrInvalidSeverity {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		log.Errorf("error generating webhook key: %s", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
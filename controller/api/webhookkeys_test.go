@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/shipyard/shipyard/dockerhub"
@@ -37,3 +38,31 @@ func TestApiGetWebhookKeys(t *testing.T) {
 
 	assert.Equal(t, keys[0].Key, key, "expected key %s; received %s", key, keys[0].Key)
 }
+
+func TestApiAddWebhookKeyMaxSeverity(t *testing.T) {
+	api, err := getTestApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(api.addWebhookKey))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL, "application/json", strings.NewReader(`{"image":"registry.local/web","max_severity":"High"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var key *dockerhub.WebhookKey
+	if err := json.NewDecoder(res.Body).Decode(&key); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "High", key.MaxSeverity)
+
+	res, err = http.Post(ts.URL, "application/json", strings.NewReader(`{"image":"registry.local/web","max_severity":"severe"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
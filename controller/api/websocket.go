@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	// wsPingInterval is how often websocket clients are pinged; clients
+	// which sent nothing, pongs included, for wsPongWait are closed
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 2 * wsPingInterval
+
+	errHijackUnsupported = errors.New("the connection cannot be hijacked")
+
+	// pingCodec sends empty ping frames; the codec takes the write lock
+	// of the connection so pings do not interleave with other frames
+	pingCodec = websocket.Codec{Marshal: func(v interface{}) ([]byte, byte, error) {
+		return nil, websocket.PingFrame, nil
+	}}
+)
+
+// liveConn records when the client last sent anything; the websocket
+// package answers pings and discards pongs itself, so the reads of the
+// connection are the only sign of a pong
+type liveConn struct {
+	net.Conn
+	lastRead int64
+}
+
+func (c *liveConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+	}
+
+	return n, err
+}
+
+// idle returns how long the client sent nothing
+func (c *liveConn) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&c.lastRead)))
+}
+
+// liveHijacker hands the websocket package a liveConn when it hijacks the
+// connection
+type liveHijacker struct {
+	http.ResponseWriter
+	conn *liveConn
+}
+
+func (h *liveHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackUnsupported
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.conn = &liveConn{Conn: conn, lastRead: time.Now().UnixNano()}
+
+	// keep what the server already read past the request
+	var r io.Reader = h.conn
+	if n := rw.Reader.Buffered(); n > 0 {
+		buffered, _ := rw.Reader.Peek(n)
+		r = io.MultiReader(bytes.NewReader(append([]byte{}, buffered...)), h.conn)
+	}
+
+	return h.conn, bufio.NewReadWriter(bufio.NewReader(r), bufio.NewWriter(h.conn)), nil
+}
+
+// websocketHandler serves a websocket endpoint pinging its clients and
+// closing the connections of clients which stopped answering so dead
+// sessions do not hold execs open
+func websocketHandler(h func(ws *websocket.Conn)) http.Handler {
+	interval, wait := wsPingInterval, wsPongWait
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker := &liveHijacker{ResponseWriter: w}
+
+		websocket.Handler(func(ws *websocket.Conn) {
+			done := make(chan struct{})
+			defer close(done)
+
+			go keepAlive(ws, hijacker.conn, interval, wait, done)
+
+			h(ws)
+		}).ServeHTTP(hijacker, r)
+	})
+}
+
+// keepAlive pings the client every interval until done is closed and
+// closes the connection once the client sent nothing for wait
+func keepAlive(ws *websocket.Conn, conn *liveConn, interval, wait time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if idle := conn.idle(now); idle > wait {
+				log.Warnf("closing websocket of %s: no pong for %s", ws.Request().RemoteAddr, idle)
+				ws.Close()
+				return
+			}
+
+			if err := pingCodec.Send(ws, nil); err != nil {
+				return
+			}
+		}
+	}
+}
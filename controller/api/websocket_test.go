@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebsocketKeepAlive(t *testing.T) {
+	interval, wait := wsPingInterval, wsPongWait
+	wsPingInterval, wsPongWait = 20*time.Millisecond, 100*time.Millisecond
+	defer func() {
+		wsPingInterval, wsPongWait = interval, wait
+	}()
+
+	closed := make(chan time.Time, 2)
+	ts := httptest.NewServer(websocketHandler(func(ws *websocket.Conn) {
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		closed <- time.Now()
+	}))
+	defer ts.Close()
+
+	url := strings.Replace(ts.URL, "http://", "ws://", 1)
+
+	// a client reading the connection answers the pings
+	live, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	go func() {
+		var msg string
+		for websocket.Message.Receive(live, &msg) == nil {
+		}
+	}()
+
+	// a client which stopped reading does not
+	dead, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dead.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the silent client to be closed")
+	}
+
+	select {
+	case <-closed:
+		t.Fatal("expected the answering client to be kept")
+	case <-time.After(3 * wsPongWait):
+	}
+
+	assert.Nil(t, websocket.Message.Send(live, "ls\n"), "expected the answering client to stay connected")
+}
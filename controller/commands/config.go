@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/codegangsta/cli"
+)
+
+// options reads controller options. The precedence is: command line
+// flags, then environment variables (SHIPYARD_<FLAG>), then the config
+// file and finally the flag defaults.
+type options struct {
+	c       *cli.Context
+	config  map[string]interface{}
+	aliases map[string][]string
+	envVars map[string][]string
+}
+
+// loadConfig reads a TOML config file; environment variables in the file
+// ($VAR or ${VAR}) are expanded. Keys are flag names; keys in tables are
+// joined with the table name (i.e. server in [ldap] is ldap-server).
+func loadConfig(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	if _, err := toml.Decode(os.ExpandEnv(string(data)), &raw); err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{}
+	flattenConfig("", raw, config)
+
+	return config, nil
+}
+
+func flattenConfig(prefix string, raw map[string]interface{}, config map[string]interface{}) {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "-" + k
+		}
+
+		if table, ok := v.(map[string]interface{}); ok {
+			flattenConfig(key, table, config)
+			continue
+		}
+
+		config[key] = v
+	}
+}
+
+func newOptions(c *cli.Context, config map[string]interface{}) *options {
+	aliases := map[string][]string{}
+	envVars := map[string][]string{}
+	for _, f := range c.Command.Flags {
+		names := strings.Split(f.GetName(), ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+		aliases[names[0]] = names
+
+		if env := flagEnvVar(f); env != "" {
+			envVars[names[0]] = strings.Split(env, ",")
+		}
+	}
+
+	return &options{
+		c:       c,
+		config:  config,
+		aliases: aliases,
+		envVars: envVars,
+	}
+}
+
+// flagEnvVar returns the environment variables of the flag
+func flagEnvVar(f cli.Flag) string {
+	switch t := f.(type) {
+	case cli.StringFlag:
+		return t.EnvVar
+	case cli.BoolFlag:
+		return t.EnvVar
+	case cli.BoolTFlag:
+		return t.EnvVar
+	case cli.IntFlag:
+		return t.EnvVar
+	case cli.DurationFlag:
+		return t.EnvVar
+	case cli.StringSliceFlag:
+		return t.EnvVar
+	case cli.IntSliceFlag:
+		return t.EnvVar
+	case cli.Float64Flag:
+		return t.EnvVar
+	}
+
+	return ""
+}
+
+// fromConfig returns the config value for the option unless it was set on
+// the command line or in the environment
+func (o *options) fromConfig(name string) (interface{}, bool) {
+	for _, n := range o.aliases[name] {
+		if o.c.IsSet(n) {
+			return nil, false
+		}
+	}
+
+	for _, env := range o.envVars[name] {
+		if os.Getenv(strings.TrimSpace(env)) != "" {
+			return nil, false
+		}
+	}
+
+	v, ok := o.config[name]
+	return v, ok
+}
+
+func configString(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case int64, float64, bool:
+		return fmt.Sprint(t), nil
+	}
+
+	return "", fmt.Errorf("expected a string; received %v", v)
+}
+
+func configBool(v interface{}) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case string:
+		return strconv.ParseBool(t)
+	}
+
+	return false, fmt.Errorf("expected a boolean; received %v", v)
+}
+
+func configInt(v interface{}) (int, error) {
+	switch t := v.(type) {
+	case int64:
+		return int(t), nil
+	case string:
+		return strconv.Atoi(t)
+	}
+
+	return 0, fmt.Errorf("expected an integer; received %v", v)
+}
+
+func configDuration(v interface{}) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("expected a duration (i.e. \"30m\"); received %v", v)
+	}
+
+	return time.ParseDuration(s)
+}
+
+func configStringSlice(v interface{}) ([]string, error) {
+	switch t := v.(type) {
+	case string:
+		return strings.Split(t, ","), nil
+	case []interface{}:
+		values := []string{}
+		for _, i := range t {
+			s, err := configString(i)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("expected a list of strings; received %v", v)
+}
+
+func (o *options) String(name string) string {
+	if v, ok := o.fromConfig(name); ok {
+		if s, err := configString(v); err == nil {
+			return s
+		}
+	}
+
+	return o.c.String(name)
+}
+
+func (o *options) Bool(name string) bool {
+	if v, ok := o.fromConfig(name); ok {
+		if b, err := configBool(v); err == nil {
+			return b
+		}
+	}
+
+	return o.c.Bool(name)
+}
+
+func (o *options) Int(name string) int {
+	if v, ok := o.fromConfig(name); ok {
+		if i, err := configInt(v); err == nil {
+			return i
+		}
+	}
+
+	return o.c.Int(name)
+}
+
+func (o *options) Duration(name string) time.Duration {
+	if v, ok := o.fromConfig(name); ok {
+		if d, err := configDuration(v); err == nil {
+			return d
+		}
+	}
+
+	return o.c.Duration(name)
+}
+
+func (o *options) StringSlice(name string) []string {
+	if v, ok := o.fromConfig(name); ok {
+		if s, err := configStringSlice(v); err == nil {
+			return s
+		}
+	}
+
+	return o.c.StringSlice(name)
+}
+
+// validateConfig checks every key in the config is a known option with a
+// value of the right type
+func validateConfig(config map[string]interface{}, flags []cli.Flag) []error {
+	known := map[string]cli.Flag{}
+	for _, f := range flags {
+		name := strings.TrimSpace(strings.Split(f.GetName(), ",")[0])
+		known[name] = f
+	}
+
+	keys := []string{}
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	errs := []error{}
+	for _, k := range keys {
+		f, ok := known[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown option", k))
+			continue
+		}
+
+		var err error
+		switch f.(type) {
+		case cli.BoolFlag, cli.BoolTFlag:
+			_, err = configBool(config[k])
+		case cli.IntFlag:
+			_, err = configInt(config[k])
+		case cli.DurationFlag:
+			_, err = configDuration(config[k])
+		case cli.StringSliceFlag:
+			_, err = configStringSlice(config[k])
+		default:
+			_, err = configString(config[k])
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", k, err))
+		}
+	}
+
+	return errs
+}
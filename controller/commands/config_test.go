@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+func TestLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "shipyard-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	os.Setenv("SHIPYARD_TEST_DB", "db:28015")
+	defer os.Unsetenv("SHIPYARD_TEST_DB")
+
+	f.WriteString(`
+listen = ":9090"
+rethinkdb-addr = "${SHIPYARD_TEST_DB}"
+auth-whitelist-cidr = ["10.0.0.0/8", "192.168.0.0/16"]
+
+[ldap]
+server = "ldap.example.com"
+port = 636
+`)
+	f.Close()
+
+	config, err := loadConfig(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config["rethinkdb-addr"] != "db:28015" {
+		t.Fatalf("expected expanded rethinkdb-addr; received %v", config["rethinkdb-addr"])
+	}
+
+	if config["ldap-server"] != "ldap.example.com" {
+		t.Fatalf("expected ldap-server from table; received %v", config["ldap-server"])
+	}
+
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "listen, l"},
+		cli.StringFlag{Name: "rethinkdb-addr"},
+		cli.StringSliceFlag{Name: "auth-whitelist-cidr"},
+		cli.StringFlag{Name: "ldap-server"},
+		cli.IntFlag{Name: "ldap-port"},
+	}
+
+	if errs := validateConfig(config, flags); len(errs) != 0 {
+		t.Fatalf("expected valid config; received %v", errs)
+	}
+
+	config["ldap-port"] = "not-a-port"
+	config["unknown"] = true
+	if errs := validateConfig(config, flags); len(errs) != 2 {
+		t.Fatalf("expected 2 errors; received %v", errs)
+	}
+}
+
+func TestOptionsPrecedence(t *testing.T) {
+	os.Setenv("SHIPYARD_TEST_LISTEN", ":7070")
+	defer os.Unsetenv("SHIPYARD_TEST_LISTEN")
+
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "listen, l", Value: ":8080", EnvVar: "SHIPYARD_TEST_LISTEN"},
+		cli.StringFlag{Name: "rethinkdb-addr", Value: "rethinkdb:28015", EnvVar: "SHIPYARD_TEST_ADDR,SHIPYARD_TEST_STORE_URL"},
+		cli.StringFlag{Name: "rethinkdb-database", Value: "shipyard"},
+	}
+
+	set := flag.NewFlagSet("server", flag.ContinueOnError)
+	for _, f := range flags {
+		f.Apply(set)
+	}
+	if err := set.Parse([]string{"--rethinkdb-database", "cli"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := cli.NewContext(nil, set, nil)
+	c.Command = cli.Command{Name: "server", Flags: flags}
+
+	config := map[string]interface{}{
+		"listen":             ":9090",
+		"rethinkdb-addr":     "config:28015",
+		"rethinkdb-database": "config",
+	}
+	opts := newOptions(c, config)
+
+	if v := opts.String("listen"); v != ":7070" {
+		t.Fatalf("expected environment to override config; received %s", v)
+	}
+
+	if v := opts.String("rethinkdb-addr"); v != "config:28015" {
+		t.Fatalf("expected config to override default; received %s", v)
+	}
+
+	if v := opts.String("rethinkdb-database"); v != "cli" {
+		t.Fatalf("expected flag to override config; received %s", v)
+	}
+}
@@ -1,12 +1,18 @@
 package commands
 
 import (
+	"time"
+
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/shipyard/shipyard/auth"
 	"github.com/shipyard/shipyard/auth/builtin"
 	"github.com/shipyard/shipyard/auth/ldap"
+	"github.com/shipyard/shipyard/auth/oidc"
 	"github.com/shipyard/shipyard/controller/api"
 	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/preflight"
+	"github.com/shipyard/shipyard/geoip"
 	"github.com/shipyard/shipyard/utils"
 	"github.com/shipyard/shipyard/version"
 )
@@ -16,18 +22,51 @@ var (
 )
 
 func CmdServer(c *cli.Context) {
-	rethinkdbAddr := c.String("rethinkdb-addr")
-	rethinkdbDatabase := c.String("rethinkdb-database")
-	rethinkdbAuthKey := c.String("rethinkdb-auth-key")
-	disableUsageInfo := c.Bool("disable-usage-info")
-	listenAddr := c.String("listen")
-	authWhitelist := c.StringSlice("auth-whitelist-cidr")
-	enableCors := c.Bool("enable-cors")
-	ldapServer := c.String("ldap-server")
-	ldapPort := c.Int("ldap-port")
-	ldapBaseDn := c.String("ldap-base-dn")
-	ldapAutocreateUsers := c.Bool("ldap-autocreate-users")
-	ldapDefaultAccessLevel := c.String("ldap-default-access-level")
+	config := map[string]interface{}{}
+	if path := c.String("config"); path != "" {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			log.Fatalf("error loading config %s: %s", path, err)
+		}
+
+		if errs := validateConfig(cfg, c.Command.Flags); len(errs) > 0 {
+			for _, err := range errs {
+				log.Errorf("invalid config: %s", err)
+			}
+			log.Fatalf("invalid config %s", path)
+		}
+
+		config = cfg
+	}
+
+	if c.Bool("validate-config") {
+		log.Info("config is valid")
+		return
+	}
+
+	opts := newOptions(c, config)
+
+	rethinkdbAddr := opts.String("rethinkdb-addr")
+	rethinkdbDatabase := opts.String("rethinkdb-database")
+	rethinkdbAuthKey := opts.String("rethinkdb-auth-key")
+	offline := opts.Bool("offline")
+	disableUsageInfo := opts.Bool("disable-usage-info") || offline
+	listenAddr := opts.String("listen")
+	authWhitelist := opts.StringSlice("auth-whitelist-cidr")
+	corsOrigins := opts.StringSlice("cors-origin")
+	if opts.Bool("enable-cors") && len(corsOrigins) == 0 {
+		log.Warn("--enable-cors is deprecated; use --cors-origin with the allowed origins")
+		corsOrigins = []string{"*"}
+	}
+	ldapServer := opts.String("ldap-server")
+	ldapPort := opts.Int("ldap-port")
+	ldapBaseDn := opts.String("ldap-base-dn")
+	ldapAutocreateUsers := opts.Bool("ldap-autocreate-users")
+	ldapDefaultAccessLevel := opts.String("ldap-default-access-level")
+	geoipDB := opts.String("geoip-db")
+	auditSyslog := opts.String("audit-syslog")
+	auditChain := opts.Bool("audit-hash-chain")
+	preflightStrict := opts.Bool("preflight-strict")
 
 	log.Infof("shipyard version %s", version.Version)
 
@@ -35,17 +74,28 @@ func CmdServer(c *cli.Context) {
 		log.Infof("whitelisting the following subnets: %v", authWhitelist)
 	}
 
-	dockerUrl := c.String("docker")
-	tlsCaCert := c.String("tls-ca-cert")
-	tlsCert := c.String("tls-cert")
-	tlsKey := c.String("tls-key")
-	allowInsecure := c.Bool("allow-insecure")
+	if offline {
+		log.Info("offline mode: outbound internet access is disabled")
+	}
+
+	dockerUrl := opts.String("docker")
+	tlsCaCert := opts.String("tls-ca-cert")
+	tlsCert := opts.String("tls-cert")
+	tlsKey := opts.String("tls-key")
+	allowInsecure := opts.Bool("allow-insecure")
 
 	client, err := utils.GetClient(dockerUrl, tlsCaCert, tlsCert, tlsKey, allowInsecure)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	hashPolicy := auth.DefaultHashPolicy
+	hashPolicy.Algorithm = opts.String("password-hash")
+	hashPolicy.BcryptCost = opts.Int("password-hash-bcrypt-cost")
+	if err := auth.SetHashPolicy(hashPolicy); err != nil {
+		log.Fatalf("invalid password hash policy: %s", err)
+	}
+
 	// default to builtin auth
 	authenticator := builtin.NewAuthenticator("defaultshipyard")
 
@@ -54,26 +104,140 @@ func CmdServer(c *cli.Context) {
 		authenticator = ldap.NewAuthenticator(ldapServer, ldapPort, ldapBaseDn, ldapAutocreateUsers, ldapDefaultAccessLevel)
 	}
 
-	controllerManager, err := manager.NewManager(rethinkdbAddr, rethinkdbDatabase, rethinkdbAuthKey, client, disableUsageInfo, authenticator)
+	var oidcProvider *oidc.Provider
+	if issuer := opts.String("oidc-issuer"); issuer != "" {
+		groupRoles, err := oidc.ParseGroupRoles(opts.StringSlice("oidc-group-role"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		clientID, redirectURL := opts.String("oidc-client-id"), opts.String("oidc-redirect-url")
+		if clientID == "" || redirectURL == "" {
+			log.Fatal("--oidc-client-id and --oidc-redirect-url are required with --oidc-issuer")
+		}
+
+		oidcProvider = oidc.NewProvider(issuer, clientID, opts.String("oidc-client-secret"), redirectURL)
+		if scopes := opts.StringSlice("oidc-scope"); len(scopes) > 0 {
+			oidcProvider.Scopes = scopes
+		}
+		oidcProvider.UsernameClaim = opts.String("oidc-username-claim")
+		oidcProvider.GroupsClaim = opts.String("oidc-groups-claim")
+		oidcProvider.GroupRoles = groupRoles
+		oidcProvider.DefaultRole = opts.String("oidc-default-role")
+	}
+
+	var geoDB *geoip.Database
+	if geoipDB != "" {
+		db, err := geoip.Open(geoipDB)
+		if err != nil {
+			log.Fatalf("error loading geoip database: %s", err)
+		}
+
+		log.Infof("using geoip database: %s", geoipDB)
+		geoDB = db
+	}
+
+	certificates := []string{}
+	for _, cert := range []string{tlsCert, opts.String("shipyard-tls-cert")} {
+		if cert != "" {
+			certificates = append(certificates, cert)
+		}
+	}
+
+	managerConfig := manager.ManagerConfig{
+		Addr:             rethinkdbAddr,
+		Database:         rethinkdbDatabase,
+		AuthKey:          rethinkdbAuthKey,
+		Client:           client,
+		DisableUsageInfo: disableUsageInfo,
+		Authenticator:    authenticator,
+		GeoDB:            geoDB,
+		AuditChain:       auditChain,
+		Certificates:     certificates,
+		// break-glass access lasts an hour unless configured
+		BreakGlassTimeout: opts.Duration("break-glass-timeout"),
+		TokenTTL:          opts.Duration("auth-token-ttl"),
+		ControllerAddr:    listenAddr,
+		Datastore:         opts.String("datastore"),
+		BoltPath:          opts.String("bolt-path"),
+		ShareLinkSecret:   opts.String("share-link-secret"),
+		SwarmDiscovery:    opts.String("swarm-discovery"),
+		DebugImages:       opts.StringSlice("debug-image"),
+		ScannerURL:        opts.String("clair-url"),
+		// a zero threshold uses the default
+		ClockSkewThreshold: opts.Duration("clock-skew-threshold"),
+		LoginRateLimit:     opts.Int("login-rate-limit"),
+		LockoutThreshold:   opts.Int("lockout-threshold"),
+		LockoutDuration:    opts.Duration("lockout-duration"),
+	}
+
+	controllerManager, err := manager.NewManager(managerConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Debugf("connected to docker: url=%s", dockerUrl)
 
-	shipyardTlsCert := c.String("shipyard-tls-cert")
-	shipyardTlsKey := c.String("shipyard-tls-key")
-	shipyardTlsCACert := c.String("shipyard-tls-ca-cert")
+	shipyardTlsCert := opts.String("shipyard-tls-cert")
+	shipyardTlsKey := opts.String("shipyard-tls-key")
+	shipyardTlsCACert := opts.String("shipyard-tls-ca-cert")
+
+	runPreflight := func() *preflight.Report {
+		now := time.Now()
+		results := controllerManager.Preflight()
+		results = append(results,
+			preflight.CheckCertificate("docker tls", tlsCert, tlsKey, now),
+			preflight.CheckCertificate("shipyard tls", shipyardTlsCert, shipyardTlsKey, now),
+			preflight.CheckStaticAssets("static"),
+		)
+
+		return preflight.NewReport(results)
+	}
+
+	report := runPreflight()
+	if report.Passed {
+		log.Infof("preflight checks passed:\n%s", report)
+	} else {
+		if preflightStrict {
+			log.Fatalf("preflight checks failed; refusing to start:\n%s", report)
+		}
+
+		log.Errorf("preflight checks failed:\n%s", report)
+	}
+
+	var apiSunset time.Time
+	if sunset := opts.String("api-sunset"); sunset != "" {
+		apiSunset, err = time.Parse("2006-01-02", sunset)
+		if err != nil {
+			log.Fatalf("invalid api sunset %q; use a date like 2027-01-01: %s", sunset, err)
+		}
+	}
 
 	apiConfig := api.ApiConfig{
 		ListenAddr:         listenAddr,
 		Manager:            controllerManager,
 		AuthWhiteListCIDRs: authWhitelist,
-		EnableCORS:         enableCors,
+		CORSOrigins:        corsOrigins,
 		AllowInsecure:      allowInsecure,
 		TLSCACertPath:      shipyardTlsCACert,
 		TLSCertPath:        shipyardTlsCert,
 		TLSKeyPath:         shipyardTlsKey,
+		TLSClientCAPath:    opts.String("shipyard-tls-client-ca"),
+		HTTPRedirectAddr:   opts.String("http-redirect-listen"),
+		AuditSyslogAddr:    auditSyslog,
+		ExecMaxDuration:    opts.Duration("exec-max-duration"),
+		ExecIdleTimeout:    opts.Duration("exec-idle-timeout"),
+		ExecRecord:         opts.Bool("exec-record"),
+		Preflight:          runPreflight,
+		ResponseCacheTTL:   opts.Duration("response-cache-ttl"),
+		Offline:            offline,
+		APISunset:          apiSunset,
+		OIDC:               oidcProvider,
+		Admin: api.AdminBootstrap{
+			Username:       opts.String("admin-username"),
+			Password:       opts.String("admin-password"),
+			RandomPassword: opts.Bool("admin-password-random"),
+		},
 	}
 
 	shipyardApi, err := api.NewApi(apiConfig)
@@ -0,0 +1,1705 @@
+package datastore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	boltOpenTimeout = 5 * time.Second
+	// watchBuffer is the number of events queued for a slow watcher before
+	// events are dropped
+	watchBuffer = 64
+)
+
+var (
+	bktAccounts    = []byte("accounts")
+	bktRoles       = []byte("roles")
+	bktServiceKeys = []byte("service_keys")
+	bktKeyUsage    = []byte("service_key_usage")
+	bktWebhookKeys = []byte("webhook_keys")
+	bktRegistries  = []byte("registries")
+	bktConsole     = []byte("console")
+	bktRecordings  = []byte("exec_recordings")
+	bktShareLinks  = []byte("share_links")
+	bktNotes       = []byte("notes")
+	bktAudit       = []byte("audit_entries")
+	bktFreezes     = []byte("freezes")
+	bktClientRules = []byte("client_rules")
+	bktJobs        = []byte("jobs")
+	bktStacks      = []byte("stacks")
+	bktDeployments = []byte("deployments")
+	bktTemplates   = []byte("templates")
+	bktNodes       = []byte("managed_nodes")
+	bktClusters    = []byte("clusters")
+	bktImageScans  = []byte("image_scans")
+	bktConfig      = []byte("config")
+	bktEvents      = []byte("events")
+	bktExecPolicy  = []byte("exec_policies")
+	bktBreakGlass  = []byte("break_glass")
+	bktAlerts      = []byte("alerts")
+	bktNotifiers   = []byte("notifiers")
+	bktRules       = []byte("notification_rules")
+	bktEscalations = []byte("escalations")
+	bktAuditLog    = []byte("audit_log")
+
+	buckets = [][]byte{bktAccounts, bktRoles, bktServiceKeys, bktKeyUsage, bktWebhookKeys, bktRegistries, bktConsole, bktRecordings, bktShareLinks, bktNotes, bktFreezes, bktClientRules, bktJobs, bktStacks, bktDeployments, bktTemplates, bktNodes, bktClusters, bktImageScans, bktConfig, bktAudit, bktEvents, bktExecPolicy, bktBreakGlass, bktAlerts, bktNotifiers, bktRules, bktEscalations, bktAuditLog}
+)
+
+type (
+	// boltStore keeps records as json in an embedded bolt database; it
+	// suits a single controller as the file cannot be shared and events
+	// are only watched in process
+	boltStore struct {
+		db       *bolt.DB
+		mu       sync.Mutex
+		watchers map[chan *shipyard.Event]struct{}
+	}
+
+	// storedAccount keeps the tokens, the totp secret and the password
+	// reset which are not part of the json of an account
+	storedAccount struct {
+		*auth.Account
+		Tokens               []*auth.AuthToken `json:"tokens"`
+		TOTPSecret           string            `json:"totp_secret,omitempty"`
+		TOTPLastStep         int64             `json:"totp_last_step,omitempty"`
+		PasswordResetHash    string            `json:"password_reset_hash,omitempty"`
+		PasswordResetExpires time.Time         `json:"password_reset_expires,omitempty"`
+	}
+
+	// storedBreakGlass keeps the credential hash which is not part of the
+	// json of the credential
+	storedBreakGlass struct {
+		*auth.BreakGlass
+		CredentialHash string `json:"credential_hash,omitempty"`
+	}
+)
+
+// NewBolt opens or creates the bolt database at the path
+func NewBolt(path string) (Datastore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, b := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltStore{
+		db:       db,
+		watchers: map[chan *shipyard.Event]struct{}{},
+	}, nil
+}
+
+func (s *boltStore) Name() string {
+	return Bolt
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping fails once the database is closed
+func (s *boltStore) Ping() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+func get(tx *bolt.Tx, bucket []byte, key string, v interface{}) error {
+	data := tx.Bucket(bucket).Get([]byte(key))
+	if data == nil {
+		return ErrNotFound
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func put(tx *bolt.Tx, bucket []byte, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucket).Put([]byte(key), data)
+}
+
+func remove(tx *bolt.Tx, bucket []byte, key string) error {
+	b := tx.Bucket(bucket)
+	if b.Get([]byte(key)) == nil {
+		return ErrNotFound
+	}
+
+	return b.Delete([]byte(key))
+}
+
+// each calls fn with every value of the bucket in key order
+func (s *boltStore) each(bucket []byte, fn func(data []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+			return fn(v)
+		})
+	})
+}
+
+func (s *boltStore) get(bucket []byte, key string, v interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return get(tx, bucket, key, v)
+	})
+}
+
+func (s *boltStore) put(bucket []byte, key string, v interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, bucket, key, v)
+	})
+}
+
+func (s *boltStore) remove(bucket []byte, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return remove(tx, bucket, key)
+	})
+}
+
+func decodeAccount(data []byte) (*auth.Account, error) {
+	stored := &storedAccount{Account: &auth.Account{}}
+	if err := json.Unmarshal(data, stored); err != nil {
+		return nil, err
+	}
+
+	stored.Account.Tokens = stored.Tokens
+	stored.Account.TOTPSecret = stored.TOTPSecret
+	stored.Account.TOTPLastStep = stored.TOTPLastStep
+	stored.Account.PasswordResetHash = stored.PasswordResetHash
+	stored.Account.PasswordResetExpires = stored.PasswordResetExpires
+
+	return stored.Account, nil
+}
+
+func putAccount(tx *bolt.Tx, account *auth.Account) error {
+	return put(tx, bktAccounts, account.Username, &storedAccount{
+		Account:              account,
+		Tokens:               account.Tokens,
+		TOTPSecret:           account.TOTPSecret,
+		TOTPLastStep:         account.TOTPLastStep,
+		PasswordResetHash:    account.PasswordResetHash,
+		PasswordResetExpires: account.PasswordResetExpires,
+	})
+}
+
+func (s *boltStore) Accounts(query *AccountQuery) ([]*auth.Account, error) {
+	accounts := []*auth.Account{}
+	if err := s.each(bktAccounts, func(data []byte) error {
+		account, err := decodeAccount(data)
+		if err != nil {
+			return err
+		}
+
+		if query.Match(account) {
+			accounts = append(accounts, account)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// keys are iterated in byte order which is the order of usernames
+	if query.Descending {
+		for i, j := 0, len(accounts)-1; i < j; i, j = i+1, j-1 {
+			accounts[i], accounts[j] = accounts[j], accounts[i]
+		}
+	}
+
+	start, end := page(len(accounts), query.Offset, query.Limit)
+
+	return accounts[start:end], nil
+}
+
+func (s *boltStore) Account(username string) (*auth.Account, error) {
+	var account *auth.Account
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bktAccounts).Get([]byte(username))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		a, err := decodeAccount(data)
+		account = a
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (s *boltStore) CreateAccount(account *auth.Account) error {
+	if account.ID == "" {
+		account.ID = generateID()
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putAccount(tx, account)
+	})
+}
+
+func (s *boltStore) UpdateAccount(username string, fn func(account *auth.Account) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bktAccounts).Get([]byte(username))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		account, err := decodeAccount(data)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(account); err != nil {
+			return err
+		}
+
+		return putAccount(tx, account)
+	})
+}
+
+func (s *boltStore) DeleteAccount(username string) error {
+	return s.remove(bktAccounts, username)
+}
+
+func (s *boltStore) Roles() ([]*auth.ACL, error) {
+	roles := []*auth.ACL{}
+	if err := s.each(bktRoles, func(data []byte) error {
+		var role *auth.ACL
+		if err := json.Unmarshal(data, &role); err != nil {
+			return err
+		}
+
+		roles = append(roles, role)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (s *boltStore) SaveRole(role *auth.ACL) error {
+	return s.put(bktRoles, role.RoleName, role)
+}
+
+func (s *boltStore) DeleteRole(name string) error {
+	return s.remove(bktRoles, name)
+}
+
+func (s *boltStore) ServiceKeys() ([]*auth.ServiceKey, error) {
+	keys := []*auth.ServiceKey{}
+	if err := s.each(bktServiceKeys, func(data []byte) error {
+		var key *auth.ServiceKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *boltStore) ServiceKey(key string) (*auth.ServiceKey, error) {
+	var k *auth.ServiceKey
+	if err := s.get(bktServiceKeys, key, &k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (s *boltStore) SaveServiceKey(key *auth.ServiceKey) error {
+	return s.put(bktServiceKeys, key.Key, key)
+}
+
+func (s *boltStore) DeleteServiceKey(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bktServiceKeys).Delete([]byte(key)); err != nil {
+			return err
+		}
+
+		return tx.Bucket(bktKeyUsage).Delete([]byte(key))
+	})
+}
+
+func (s *boltStore) DeleteOrphanKeyUsage() (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(bktServiceKeys)
+
+		// the bucket cannot be changed while iterating over it
+		orphans := [][]byte{}
+		if err := tx.Bucket(bktKeyUsage).ForEach(func(k, v []byte) error {
+			if keys.Get(k) == nil {
+				orphans = append(orphans, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range orphans {
+			if err := tx.Bucket(bktKeyUsage).Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(orphans)
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+func (s *boltStore) ServiceKeyUsage(key string) (*auth.ServiceKeyUsage, error) {
+	var usage *auth.ServiceKeyUsage
+	if err := s.get(bktKeyUsage, key, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (s *boltStore) AddServiceKeyUsage(added *auth.ServiceKeyUsage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		usage := &auth.ServiceKeyUsage{
+			Key:       added.Key,
+			SourceIPs: []string{},
+			Routes:    map[string]int{},
+		}
+		if err := get(tx, bktKeyUsage, added.Key, usage); err != nil && err != ErrNotFound {
+			return err
+		}
+
+		if usage.Routes == nil {
+			usage.Routes = map[string]int{}
+		}
+
+		usage.RequestCount += added.RequestCount
+		if added.LastUsed.After(usage.LastUsed) {
+			usage.LastUsed = added.LastUsed
+		}
+		for route, n := range added.Routes {
+			usage.Routes[route] += n
+		}
+
+		for _, ip := range added.SourceIPs {
+			found := false
+			for _, addr := range usage.SourceIPs {
+				if addr == ip {
+					found = true
+					break
+				}
+			}
+			if !found {
+				usage.SourceIPs = append(usage.SourceIPs, ip)
+			}
+		}
+
+		return put(tx, bktKeyUsage, added.Key, usage)
+	})
+}
+
+func (s *boltStore) WebhookKeys() ([]*dockerhub.WebhookKey, error) {
+	keys := []*dockerhub.WebhookKey{}
+	if err := s.each(bktWebhookKeys, func(data []byte) error {
+		var key *dockerhub.WebhookKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].Image < keys[j].Image
+	})
+
+	return keys, nil
+}
+
+func (s *boltStore) WebhookKey(key string) (*dockerhub.WebhookKey, error) {
+	keys, err := s.WebhookKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		if k.Key == key {
+			return k, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *boltStore) SaveWebhookKey(key *dockerhub.WebhookKey) error {
+	if key.ID == "" {
+		key.ID = generateID()
+	}
+
+	return s.put(bktWebhookKeys, key.ID, key)
+}
+
+func (s *boltStore) DeleteWebhookKey(id string) error {
+	return s.remove(bktWebhookKeys, id)
+}
+
+func (s *boltStore) Registries() ([]*shipyard.Registry, error) {
+	regs := []*shipyard.Registry{}
+	if err := s.each(bktRegistries, func(data []byte) error {
+		var reg *shipyard.Registry
+		if err := json.Unmarshal(data, &reg); err != nil {
+			return err
+		}
+
+		regs = append(regs, reg)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(regs, func(i, j int) bool {
+		return regs[i].Name < regs[j].Name
+	})
+
+	return regs, nil
+}
+
+func (s *boltStore) Registry(id string) (*shipyard.Registry, error) {
+	var reg *shipyard.Registry
+	if err := s.get(bktRegistries, id, &reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (s *boltStore) RegistryByAddress(addr string) (*shipyard.Registry, error) {
+	regs, err := s.Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reg := range regs {
+		if reg.Addr == addr {
+			return reg, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *boltStore) SaveRegistry(registry *shipyard.Registry) error {
+	if registry.ID == "" {
+		registry.ID = generateID()
+	}
+
+	return s.put(bktRegistries, registry.ID, registry)
+}
+
+func (s *boltStore) DeleteRegistry(id string) error {
+	return s.remove(bktRegistries, id)
+}
+
+func (s *boltStore) ConsoleSession(token string) (*shipyard.ConsoleSession, error) {
+	var session *shipyard.ConsoleSession
+	if err := s.each(bktConsole, func(data []byte) error {
+		var c *shipyard.ConsoleSession
+		if err := json.Unmarshal(data, &c); err != nil {
+			return err
+		}
+
+		if c.Token == token {
+			session = c
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, ErrNotFound
+	}
+
+	return session, nil
+}
+
+func (s *boltStore) SaveConsoleSession(c *shipyard.ConsoleSession) error {
+	if c.ID == "" {
+		c.ID = generateID()
+	}
+
+	return s.put(bktConsole, c.ID, c)
+}
+
+func (s *boltStore) DeleteConsoleSession(id string) error {
+	return s.remove(bktConsole, id)
+}
+
+func (s *boltStore) ExecPolicies() ([]*auth.ExecPolicy, error) {
+	policies := []*auth.ExecPolicy{}
+	if err := s.each(bktExecPolicy, func(data []byte) error {
+		var policy *auth.ExecPolicy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return err
+		}
+
+		policies = append(policies, policy)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policies[i].Role < policies[j].Role
+	})
+
+	return policies, nil
+}
+
+func (s *boltStore) ExecPolicy(id string) (*auth.ExecPolicy, error) {
+	var policy *auth.ExecPolicy
+	if err := s.get(bktExecPolicy, id, &policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (s *boltStore) SaveExecPolicy(policy *auth.ExecPolicy) error {
+	if policy.ID == "" {
+		policy.ID = generateID()
+	}
+
+	return s.put(bktExecPolicy, policy.ID, policy)
+}
+
+func (s *boltStore) DeleteExecPolicy(id string) error {
+	return s.remove(bktExecPolicy, id)
+}
+
+func getBreakGlass(tx *bolt.Tx) (*auth.BreakGlass, error) {
+	stored := &storedBreakGlass{BreakGlass: &auth.BreakGlass{}}
+	if err := get(tx, bktBreakGlass, breakGlassID, stored); err != nil {
+		return nil, err
+	}
+
+	stored.BreakGlass.ID = breakGlassID
+	stored.BreakGlass.CredentialHash = stored.CredentialHash
+
+	return stored.BreakGlass, nil
+}
+
+func putBreakGlass(tx *bolt.Tx, b *auth.BreakGlass) error {
+	return put(tx, bktBreakGlass, breakGlassID, &storedBreakGlass{
+		BreakGlass:     b,
+		CredentialHash: b.CredentialHash,
+	})
+}
+
+func (s *boltStore) BreakGlass() (*auth.BreakGlass, error) {
+	var b *auth.BreakGlass
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		b, err = getBreakGlass(tx)
+		return err
+	})
+	return b, err
+}
+
+func (s *boltStore) SaveBreakGlass(b *auth.BreakGlass) error {
+	b.ID = breakGlassID
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putBreakGlass(tx, b)
+	})
+}
+
+func (s *boltStore) UseBreakGlass(usedFrom, reason string, usedAt, expiresAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := getBreakGlass(tx)
+		if err != nil {
+			return err
+		}
+
+		if !b.Sealed() {
+			return ErrNotFound
+		}
+
+		b.UsedAt = &usedAt
+		b.UsedFrom = usedFrom
+		b.Reason = reason
+		b.ExpiresAt = &expiresAt
+
+		return putBreakGlass(tx, b)
+	})
+}
+
+func (s *boltStore) Alerts(status string) ([]*shipyard.Alert, error) {
+	alerts := []*shipyard.Alert{}
+	if err := s.each(bktAlerts, func(data []byte) error {
+		var alert *shipyard.Alert
+		if err := json.Unmarshal(data, &alert); err != nil {
+			return err
+		}
+
+		if status == "" || alert.Status == status {
+			alerts = append(alerts, alert)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].CreatedAt.After(alerts[j].CreatedAt)
+	})
+
+	return alerts, nil
+}
+
+func (s *boltStore) Alert(id string) (*shipyard.Alert, error) {
+	var alert *shipyard.Alert
+	if err := s.get(bktAlerts, id, &alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (s *boltStore) SaveAlert(alert *shipyard.Alert) error {
+	if alert.ID == "" {
+		alert.ID = generateID()
+	}
+
+	return s.put(bktAlerts, alert.ID, alert)
+}
+
+func (s *boltStore) UpdateAlert(id string, fn func(alert *shipyard.Alert) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var alert *shipyard.Alert
+		if err := get(tx, bktAlerts, id, &alert); err != nil {
+			return err
+		}
+
+		if err := fn(alert); err != nil {
+			return err
+		}
+
+		return put(tx, bktAlerts, id, alert)
+	})
+}
+
+func (s *boltStore) Notifiers() ([]*notification.Notifier, error) {
+	notifiers := []*notification.Notifier{}
+	if err := s.each(bktNotifiers, func(data []byte) error {
+		var n *notification.Notifier
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+
+		notifiers = append(notifiers, n)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(notifiers, func(i, j int) bool {
+		return notifiers[i].Name < notifiers[j].Name
+	})
+
+	return notifiers, nil
+}
+
+func (s *boltStore) Notifier(id string) (*notification.Notifier, error) {
+	var n *notification.Notifier
+	if err := s.get(bktNotifiers, id, &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (s *boltStore) SaveNotifier(n *notification.Notifier) error {
+	if n.ID == "" {
+		n.ID = generateID()
+	}
+
+	return s.put(bktNotifiers, n.ID, n)
+}
+
+func (s *boltStore) DeleteNotifier(id string) error {
+	return s.remove(bktNotifiers, id)
+}
+
+func (s *boltStore) NotificationRules() ([]*notification.Rule, error) {
+	rules := []*notification.Rule{}
+	if err := s.each(bktRules, func(data []byte) error {
+		var rule *notification.Rule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return err
+		}
+
+		rules = append(rules, rule)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+
+	return rules, nil
+}
+
+func (s *boltStore) NotificationRule(id string) (*notification.Rule, error) {
+	var rule *notification.Rule
+	if err := s.get(bktRules, id, &rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *boltStore) SaveNotificationRule(rule *notification.Rule) error {
+	if rule.ID == "" {
+		rule.ID = generateID()
+	}
+
+	return s.put(bktRules, rule.ID, rule)
+}
+
+func (s *boltStore) DeleteNotificationRule(id string) error {
+	return s.remove(bktRules, id)
+}
+
+func (s *boltStore) Escalations() ([]*notification.Escalation, error) {
+	escalations := []*notification.Escalation{}
+	if err := s.each(bktEscalations, func(data []byte) error {
+		var esc *notification.Escalation
+		if err := json.Unmarshal(data, &esc); err != nil {
+			return err
+		}
+
+		escalations = append(escalations, esc)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(escalations, func(i, j int) bool {
+		return escalations[i].Due.After(escalations[j].Due)
+	})
+
+	return escalations, nil
+}
+
+func (s *boltStore) SaveEscalation(esc *notification.Escalation) error {
+	if esc.ID == "" {
+		esc.ID = generateID()
+	}
+
+	return s.put(bktEscalations, esc.ID, esc)
+}
+
+func (s *boltStore) UpdateEscalation(id string, fn func(esc *notification.Escalation) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var esc *notification.Escalation
+		if err := get(tx, bktEscalations, id, &esc); err != nil {
+			return err
+		}
+
+		if err := fn(esc); err != nil {
+			return err
+		}
+
+		return put(tx, bktEscalations, id, esc)
+	})
+}
+
+func (s *boltStore) DeleteEscalation(id string) error {
+	return s.remove(bktEscalations, id)
+}
+
+func (s *boltStore) ExecRecordings() ([]*shipyard.ExecRecording, error) {
+	recordings := []*shipyard.ExecRecording{}
+	if err := s.each(bktRecordings, func(data []byte) error {
+		var rec *shipyard.ExecRecording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+
+		rec.Frames = nil
+		recordings = append(recordings, rec)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt.After(recordings[j].StartedAt)
+	})
+
+	return recordings, nil
+}
+
+func (s *boltStore) ExecRecording(id string) (*shipyard.ExecRecording, error) {
+	var rec *shipyard.ExecRecording
+	if err := s.get(bktRecordings, id, &rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *boltStore) SaveExecRecording(rec *shipyard.ExecRecording) error {
+	return s.put(bktRecordings, rec.ID, rec)
+}
+
+func (s *boltStore) ShareLinks() ([]*shipyard.ShareLink, error) {
+	links := []*shipyard.ShareLink{}
+	if err := s.each(bktShareLinks, func(data []byte) error {
+		var link *shipyard.ShareLink
+		if err := json.Unmarshal(data, &link); err != nil {
+			return err
+		}
+
+		links = append(links, link)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+
+	return links, nil
+}
+
+func (s *boltStore) ShareLink(id string) (*shipyard.ShareLink, error) {
+	var link *shipyard.ShareLink
+	if err := s.get(bktShareLinks, id, &link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *boltStore) SaveShareLink(link *shipyard.ShareLink) error {
+	if link.ID == "" {
+		link.ID = generateID()
+	}
+
+	// the token is never stored
+	stored := *link
+	stored.Token = ""
+
+	return s.put(bktShareLinks, link.ID, &stored)
+}
+
+func (s *boltStore) DeleteShareLink(id string) error {
+	return s.remove(bktShareLinks, id)
+}
+
+func (s *boltStore) RecordShareLinkAccess(id string, t time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var link *shipyard.ShareLink
+		if err := get(tx, bktShareLinks, id, &link); err != nil {
+			return err
+		}
+
+		link.AccessCount++
+		link.LastAccessed = &t
+
+		return put(tx, bktShareLinks, id, link)
+	})
+}
+
+// Notes are returned in key order which is the id
+func (s *boltStore) Notes() ([]*shipyard.Note, error) {
+	notes := []*shipyard.Note{}
+	if err := s.each(bktNotes, func(data []byte) error {
+		var note *shipyard.Note
+		if err := json.Unmarshal(data, &note); err != nil {
+			return err
+		}
+
+		notes = append(notes, note)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func (s *boltStore) Note(id string) (*shipyard.Note, error) {
+	var note *shipyard.Note
+	if err := s.get(bktNotes, id, &note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+func (s *boltStore) SaveNote(note *shipyard.Note) error {
+	return s.put(bktNotes, note.ID, note)
+}
+
+func (s *boltStore) DeleteNote(id string) error {
+	return s.remove(bktNotes, id)
+}
+
+func (s *boltStore) Freezes() ([]*shipyard.Freeze, error) {
+	freezes := []*shipyard.Freeze{}
+	if err := s.each(bktFreezes, func(data []byte) error {
+		var freeze *shipyard.Freeze
+		if err := json.Unmarshal(data, &freeze); err != nil {
+			return err
+		}
+
+		freezes = append(freezes, freeze)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(freezes, func(i, j int) bool {
+		return freezes[i].Start.Before(freezes[j].Start)
+	})
+
+	return freezes, nil
+}
+
+func (s *boltStore) Freeze(id string) (*shipyard.Freeze, error) {
+	var freeze *shipyard.Freeze
+	if err := s.get(bktFreezes, id, &freeze); err != nil {
+		return nil, err
+	}
+	return freeze, nil
+}
+
+func (s *boltStore) SaveFreeze(freeze *shipyard.Freeze) error {
+	if freeze.ID == "" {
+		freeze.ID = generateID()
+	}
+
+	return s.put(bktFreezes, freeze.ID, freeze)
+}
+
+func (s *boltStore) DeleteFreeze(id string) error {
+	return s.remove(bktFreezes, id)
+}
+
+func (s *boltStore) ClientRules() ([]*shipyard.ClientRule, error) {
+	rules := []*shipyard.ClientRule{}
+	if err := s.each(bktClientRules, func(data []byte) error {
+		var rule *shipyard.ClientRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return err
+		}
+
+		rules = append(rules, rule)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].RoutePrefix < rules[j].RoutePrefix
+	})
+
+	return rules, nil
+}
+
+func (s *boltStore) ClientRule(id string) (*shipyard.ClientRule, error) {
+	var rule *shipyard.ClientRule
+	if err := s.get(bktClientRules, id, &rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *boltStore) SaveClientRule(rule *shipyard.ClientRule) error {
+	if rule.ID == "" {
+		rule.ID = generateID()
+	}
+
+	return s.put(bktClientRules, rule.ID, rule)
+}
+
+func (s *boltStore) DeleteClientRule(id string) error {
+	return s.remove(bktClientRules, id)
+}
+
+func (s *boltStore) Jobs() ([]*shipyard.Job, error) {
+	jobs := []*shipyard.Job{}
+	if err := s.each(bktJobs, func(data []byte) error {
+		var job *shipyard.Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return err
+		}
+
+		jobs = append(jobs, job)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+
+	return jobs, nil
+}
+
+func (s *boltStore) Job(id string) (*shipyard.Job, error) {
+	var job *shipyard.Job
+	if err := s.get(bktJobs, id, &job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *boltStore) SaveJob(job *shipyard.Job) error {
+	if job.ID == "" {
+		job.ID = generateID()
+	}
+
+	return s.put(bktJobs, job.ID, job)
+}
+
+func (s *boltStore) DeleteJob(id string) error {
+	return s.remove(bktJobs, id)
+}
+
+func (s *boltStore) Stacks() ([]*shipyard.Stack, error) {
+	stacks := []*shipyard.Stack{}
+	if err := s.each(bktStacks, func(data []byte) error {
+		var stack *shipyard.Stack
+		if err := json.Unmarshal(data, &stack); err != nil {
+			return err
+		}
+
+		stacks = append(stacks, stack)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return stacks, nil
+}
+
+func (s *boltStore) Stack(name string) (*shipyard.Stack, error) {
+	var stack *shipyard.Stack
+	if err := s.get(bktStacks, name, &stack); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+func (s *boltStore) SaveStack(stack *shipyard.Stack) error {
+	// containers are looked up from docker when a stack is inspected
+	stored := *stack
+	stored.Containers = nil
+
+	return s.put(bktStacks, stack.Name, &stored)
+}
+
+func (s *boltStore) DeleteStack(name string) error {
+	return s.remove(bktStacks, name)
+}
+
+func (s *boltStore) Clusters() ([]*shipyard.Cluster, error) {
+	clusters := []*shipyard.Cluster{}
+	if err := s.each(bktClusters, func(data []byte) error {
+		var cluster *shipyard.Cluster
+		if err := json.Unmarshal(data, &cluster); err != nil {
+			return err
+		}
+
+		clusters = append(clusters, cluster)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+func (s *boltStore) Cluster(name string) (*shipyard.Cluster, error) {
+	var cluster *shipyard.Cluster
+	if err := s.get(bktClusters, name, &cluster); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *boltStore) SaveCluster(cluster *shipyard.Cluster) error {
+	return s.put(bktClusters, cluster.Name, cluster)
+}
+
+func (s *boltStore) DeleteCluster(name string) error {
+	return s.remove(bktClusters, name)
+}
+
+func (s *boltStore) ImageScan(id string) (*shipyard.ImageScan, error) {
+	var scan *shipyard.ImageScan
+	if err := s.get(bktImageScans, id, &scan); err != nil {
+		return nil, err
+	}
+	return scan, nil
+}
+
+func (s *boltStore) SaveImageScan(scan *shipyard.ImageScan) error {
+	return s.put(bktImageScans, scan.ID, scan)
+}
+
+func (s *boltStore) Deployments(stack string) ([]*shipyard.Deployment, error) {
+	deployments := []*shipyard.Deployment{}
+	if err := s.each(bktDeployments, func(data []byte) error {
+		var d *shipyard.Deployment
+		if err := json.Unmarshal(data, &d); err != nil {
+			return err
+		}
+
+		if d.Stack == stack {
+			deployments = append(deployments, d)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(deployments, func(i, j int) bool {
+		return deployments[i].StartedAt.After(deployments[j].StartedAt)
+	})
+
+	return deployments, nil
+}
+
+func (s *boltStore) SaveDeployment(d *shipyard.Deployment) error {
+	return s.put(bktDeployments, d.ID, d)
+}
+
+func (s *boltStore) ManagedNodes() ([]*shipyard.ManagedNode, error) {
+	nodes := []*shipyard.ManagedNode{}
+	if err := s.each(bktNodes, func(data []byte) error {
+		var node *shipyard.ManagedNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			return err
+		}
+
+		nodes = append(nodes, node)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+func (s *boltStore) ManagedNode(name string) (*shipyard.ManagedNode, error) {
+	var node *shipyard.ManagedNode
+	if err := s.get(bktNodes, name, &node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (s *boltStore) SaveManagedNode(node *shipyard.ManagedNode) error {
+	return s.put(bktNodes, node.Name, node)
+}
+
+func (s *boltStore) DeleteManagedNode(name string) error {
+	return s.remove(bktNodes, name)
+}
+
+func (s *boltStore) RegistryMirror() (*shipyard.RegistryMirror, error) {
+	var mirror *shipyard.RegistryMirror
+	if err := s.get(bktConfig, shipyard.RegistryMirrorID, &mirror); err != nil {
+		return nil, err
+	}
+	mirror.ID = shipyard.RegistryMirrorID
+
+	return mirror, nil
+}
+
+func (s *boltStore) SaveRegistryMirror(mirror *shipyard.RegistryMirror) error {
+	mirror.ID = shipyard.RegistryMirrorID
+
+	return s.put(bktConfig, mirror.ID, mirror)
+}
+
+func (s *boltStore) DeleteRegistryMirror() error {
+	return s.remove(bktConfig, shipyard.RegistryMirrorID)
+}
+
+func (s *boltStore) ClusterEndpoint() (*shipyard.ClusterEndpoint, error) {
+	var endpoint *shipyard.ClusterEndpoint
+	if err := s.get(bktConfig, shipyard.ClusterEndpointID, &endpoint); err != nil {
+		return nil, err
+	}
+	endpoint.ID = shipyard.ClusterEndpointID
+
+	return endpoint, nil
+}
+
+func (s *boltStore) SaveClusterEndpoint(endpoint *shipyard.ClusterEndpoint) error {
+	endpoint.ID = shipyard.ClusterEndpointID
+
+	return s.put(bktConfig, endpoint.ID, endpoint)
+}
+
+func (s *boltStore) ContainerDefaults() (*shipyard.ContainerDefaults, error) {
+	var defaults *shipyard.ContainerDefaults
+	if err := s.get(bktConfig, shipyard.ContainerDefaultsID, &defaults); err != nil {
+		return nil, err
+	}
+	defaults.ID = shipyard.ContainerDefaultsID
+
+	return defaults, nil
+}
+
+func (s *boltStore) SaveContainerDefaults(defaults *shipyard.ContainerDefaults) error {
+	defaults.ID = shipyard.ContainerDefaultsID
+
+	return s.put(bktConfig, defaults.ID, defaults)
+}
+
+func (s *boltStore) DeleteContainerDefaults() error {
+	return s.remove(bktConfig, shipyard.ContainerDefaultsID)
+}
+
+func (s *boltStore) Templates() ([]*shipyard.Template, error) {
+	templates := []*shipyard.Template{}
+	if err := s.each(bktTemplates, func(data []byte) error {
+		var template *shipyard.Template
+		if err := json.Unmarshal(data, &template); err != nil {
+			return err
+		}
+
+		templates = append(templates, template)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (s *boltStore) Template(name string) (*shipyard.Template, error) {
+	var template *shipyard.Template
+	if err := s.get(bktTemplates, name, &template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (s *boltStore) SaveTemplate(template *shipyard.Template) error {
+	return s.put(bktTemplates, template.Name, template)
+}
+
+func (s *boltStore) DeleteTemplate(name string) error {
+	return s.remove(bktTemplates, name)
+}
+
+// SaveAuditEntry keys entries by a sequence like events
+func (s *boltStore) SaveAuditEntry(entry *shipyard.AuditEntry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bktAudit)
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		if entry.ID == "" {
+			entry.ID = generateID()
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		return b.Put(key, data)
+	})
+}
+
+func (s *boltStore) AuditEntries(query *AuditQuery) ([]*shipyard.AuditEntry, error) {
+	entries := []*shipyard.AuditEntry{}
+	if err := s.each(bktAudit, func(data []byte) error {
+		var entry *shipyard.AuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+
+		if query.Match(entry) {
+			entries = append(entries, entry)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+	}
+
+	return entries, nil
+}
+
+func (s *boltStore) AnonymizeAuditEntries(username, pseudonym string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bktAudit)
+
+		// the bucket cannot be changed while iterating over it
+		updates := map[string][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			var entry *shipyard.AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			route := ReplaceUsername(entry.Route, username, pseudonym)
+			payload := ReplaceUsername(entry.Payload, username, pseudonym)
+			if entry.Username != username && route == entry.Route && payload == entry.Payload {
+				return nil
+			}
+
+			if entry.Username == username {
+				entry.Username = pseudonym
+				entry.RemoteAddr = ""
+			}
+			entry.Route = route
+			entry.Payload = payload
+
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+
+			updates[string(k)] = data
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for k, data := range updates {
+			if err := b.Put([]byte(k), data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SaveEvent keys events by a sequence so they are kept in write order
+// auditRecordKey orders the records of the audit log by sequence
+func auditRecordKey(sequence int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(sequence))
+	return key
+}
+
+func (s *boltStore) AuditRecords() ([]*shipyard.AuditRecord, error) {
+	records := []*shipyard.AuditRecord{}
+	if err := s.each(bktAuditLog, func(data []byte) error {
+		var rec *shipyard.AuditRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+
+		records = append(records, rec)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *boltStore) LastAuditRecord() (*shipyard.AuditRecord, error) {
+	var rec *shipyard.AuditRecord
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		_, data := tx.Bucket(bktAuditLog).Cursor().Last()
+		if data == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(data, &rec)
+	}); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+func (s *boltStore) AppendAuditRecord(rec *shipyard.AuditRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bktAuditLog)
+		key := auditRecordKey(rec.Sequence)
+		if b.Get(key) != nil {
+			return ErrAuditRecordExists
+		}
+
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+}
+
+func (s *boltStore) RedactAuditRecord(rec *shipyard.AuditRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bktAuditLog)
+		key := auditRecordKey(rec.Sequence)
+		data := b.Get(key)
+		if data == nil {
+			return ErrNotFound
+		}
+
+		var stored *shipyard.AuditRecord
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+
+		stored.Data = rec.Data
+		stored.Redacted = true
+
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+}
+
+func (s *boltStore) SaveEvent(event *shipyard.Event) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bktEvents)
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		return b.Put(key, data)
+	}); err != nil {
+		return err
+	}
+
+	s.publish(event)
+
+	return nil
+}
+
+func (s *boltStore) Events(query *EventQuery) ([]*shipyard.Event, error) {
+	events := []*shipyard.Event{}
+	if err := s.each(bktEvents, func(data []byte) error {
+		var evt *shipyard.Event
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return err
+		}
+
+		if query.Match(evt) {
+			events = append(events, evt)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if !query.Ascending {
+			a, b = b, a
+		}
+
+		switch query.SortBy {
+		case EventSortType:
+			return a.Type < b.Type
+		case EventSortUsername:
+			return a.Username < b.Username
+		}
+		return a.Time.Before(b.Time)
+	})
+
+	start, end := page(len(events), query.Offset, query.Limit)
+
+	return events[start:end], nil
+}
+
+func (s *boltStore) AnonymizeEvents(username, pseudonym string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bktEvents)
+
+		// the bucket cannot be changed while iterating
+		updates := map[string][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			var evt *shipyard.Event
+			if err := json.Unmarshal(v, &evt); err != nil {
+				return err
+			}
+
+			// events of other users, such as adding the account, name
+			// the user in the message
+			message := ReplaceUsername(evt.Message, username, pseudonym)
+			if evt.Username != username && message == evt.Message {
+				return nil
+			}
+
+			if evt.Username == username {
+				evt.Username = pseudonym
+				evt.RemoteAddr = ""
+				evt.Country = ""
+				evt.ASN = ""
+			}
+			evt.Message = message
+
+			data, err := json.Marshal(evt)
+			if err != nil {
+				return err
+			}
+
+			updates[string(k)] = data
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for k, data := range updates {
+			if err := b.Put([]byte(k), data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *boltStore) PurgeEvents() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bktEvents); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucket(bktEvents)
+		return err
+	})
+}
+
+// WatchEvents returns the events saved by this controller; a watcher that
+// does not keep up misses events rather than blocking writes
+func (s *boltStore) WatchEvents(done <-chan struct{}) (<-chan *shipyard.Event, error) {
+	events := make(chan *shipyard.Event, watchBuffer)
+
+	s.mu.Lock()
+	s.watchers[events] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-done
+
+		s.mu.Lock()
+		delete(s.watchers, events)
+		close(events)
+		s.mu.Unlock()
+	}()
+
+	return events, nil
+}
+
+func (s *boltStore) publish(event *shipyard.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for w := range s.watchers {
+		select {
+		case w <- event:
+		default:
+			log.Warnf("event stream is not keeping up; dropped event: type=%s", event.Type)
+		}
+	}
+}
+
+// Check verifies that every bucket exists and that the pages of the file
+// are consistent
+func (s *boltStore) Check() ([]string, error) {
+	problems := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, b := range buckets {
+			if tx.Bucket(b) == nil {
+				problems = append(problems, fmt.Sprintf("bucket %s is missing", b))
+			}
+		}
+
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+
+		return nil
+	})
+
+	return problems, err
+}
+
+// Size returns the size of the file; free pages are only reused by bolt,
+// the file shrinks when it is compacted offline
+func (s *boltStore) Size() (*Size, error) {
+	size := &Size{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		size.Bytes = tx.Size()
+		return nil
+	})
+
+	stats := s.db.Stats()
+	size.FreeBytes = int64(stats.FreePageN+stats.PendingPageN) * int64(s.db.Info().PageSize)
+
+	return size, err
+}
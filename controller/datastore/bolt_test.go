@@ -0,0 +1,1011 @@
+package datastore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/notification"
+)
+
+func newTestBolt(t *testing.T) (Datastore, func()) {
+	dir, err := ioutil.TempDir("", "shipyard-bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewBolt(filepath.Join(dir, "shipyard.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltAccounts(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if _, err := s.Account("admin"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+
+	for _, username := range []string{"zed", "admin"} {
+		if err := s.CreateAccount(&auth.Account{Username: username, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.UpdateAccount("admin", func(acct *auth.Account) error {
+		acct.Tokens = []*auth.AuthToken{{Token: "token", UserAgent: "test"}}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	acct, err := s.Account("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tokens are not part of the json of an account but have to be kept
+	if acct.ID == "" || acct.Password != "hash" || len(acct.Tokens) != 1 || acct.Tokens[0].Token != "token" {
+		t.Fatalf("unexpected account: %+v", acct)
+	}
+
+	accounts, err := s.Accounts(&AccountQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(accounts) != 2 || accounts[0].Username != "admin" {
+		t.Fatalf("expected accounts sorted by username; received %d", len(accounts))
+	}
+
+	if err := s.CreateAccount(&auth.Account{Username: "zara", Roles: []string{"ops"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		query    *AccountQuery
+		expected []string
+	}{
+		{&AccountQuery{Prefix: "z"}, []string{"zara", "zed"}},
+		{&AccountQuery{Role: "ops"}, []string{"zara"}},
+		{&AccountQuery{Offset: 1, Limit: 1}, []string{"zara"}},
+		{&AccountQuery{Offset: 5}, []string{}},
+		{&AccountQuery{Descending: true, Limit: 2}, []string{"zed", "zara"}},
+	} {
+		accounts, err := s.Accounts(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usernames := []string{}
+		for _, acct := range accounts {
+			usernames = append(usernames, acct.Username)
+		}
+
+		if strings.Join(usernames, ",") != strings.Join(c.expected, ",") {
+			t.Fatalf("%+v: expected %v; received %v", c.query, c.expected, usernames)
+		}
+	}
+
+	if err := s.DeleteAccount("zed"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteAccount("zed"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltCheck(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	problems, err := s.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(problems) != 0 {
+		t.Fatalf("expected no problems; received %v", problems)
+	}
+
+	size, err := s.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size.Bytes == 0 || size.FreeBytes > size.Bytes {
+		t.Fatalf("unexpected size %+v", size)
+	}
+}
+
+func TestBoltServiceKeyUsage(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if err := s.SaveServiceKey(&auth.ServiceKey{Key: "key", Description: "ci"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"} {
+		if err := s.AddServiceKeyUsage(&auth.ServiceKeyUsage{
+			Key:          "key",
+			RequestCount: 1,
+			LastUsed:     now,
+			SourceIPs:    []string{ip},
+			Routes:       map[string]int{"/api/containers": 1},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := s.ServiceKeyUsage("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.RequestCount != 3 || len(usage.SourceIPs) != 2 || usage.Routes["/api/containers"] != 3 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	if err := s.DeleteServiceKey("key"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ServiceKeyUsage("key"); err != ErrNotFound {
+		t.Fatalf("expected the usage to be removed with the key; received %v", err)
+	}
+}
+
+func TestBoltWebhookKeysAndRegistries(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	for _, image := range []string{"nginx", "alpine"} {
+		if err := s.SaveWebhookKey(&dockerhub.WebhookKey{Image: image, Key: image + "-key"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := s.WebhookKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0].Image != "alpine" || keys[0].ID == "" {
+		t.Fatalf("expected webhook keys sorted by image with an id; received %+v", keys)
+	}
+
+	key, err := s.WebhookKey("nginx-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteWebhookKey(key.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := &shipyard.Registry{Name: "local", Addr: "https://registry.local:5000"}
+	if err := s.SaveRegistry(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.RegistryByAddress(reg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.ID != reg.ID {
+		t.Fatalf("expected registry %s; received %s", reg.ID, found.ID)
+	}
+}
+
+func TestBoltEvents(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	now := time.Now()
+	events := []*shipyard.Event{
+		{Type: "login", Username: "admin", RemoteAddr: "10.0.0.1", Time: now.Add(-3 * time.Minute)},
+		{Type: "login", Username: "admin", RemoteAddr: "10.0.0.2", Time: now.Add(-1 * time.Minute)},
+		{Type: "delete-container", Username: "other", Message: "removed for admin, not administrator", ContainerInfo: &dockerclient.ContainerInfo{Id: "abcdef"}, Time: now.Add(-2 * time.Minute)},
+	}
+	for _, evt := range events {
+		if err := s.SaveEvent(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := s.Events(&EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 3 || all[0].RemoteAddr != "10.0.0.2" {
+		t.Fatalf("expected events newest first; received %+v", all)
+	}
+
+	logins, err := s.Events(&EventQuery{Type: "login", Username: "admin", Before: now.Add(-time.Minute), Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logins) != 1 || logins[0].RemoteAddr != "10.0.0.1" {
+		t.Fatalf("expected the earlier login only; received %+v", logins)
+	}
+
+	paged, err := s.Events(&EventQuery{Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paged) != 1 || paged[0].Type != "delete-container" {
+		t.Fatalf("expected the second newest event; received %+v", paged)
+	}
+
+	container, err := s.Events(&EventQuery{ContainerId: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(container) != 1 || container[0].Type != "delete-container" {
+		t.Fatalf("expected the event of the container; received %+v", container)
+	}
+
+	limited, err := s.Events(&EventQuery{Limit: 2, Ascending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(limited) != 2 || limited[0].RemoteAddr != "10.0.0.1" {
+		t.Fatalf("expected the 2 oldest events; received %+v", limited)
+	}
+
+	byType, err := s.Events(&EventQuery{SortBy: EventSortType, Ascending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byType) != 3 || byType[0].Type != "delete-container" || byType[1].RemoteAddr != "10.0.0.1" {
+		t.Fatalf("expected events sorted by type; received %+v", byType)
+	}
+
+	if err := s.AnonymizeEvents("admin", "anonymous-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	anonymized, err := s.Events(&EventQuery{Username: "anonymous-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anonymized) != 2 || anonymized[0].RemoteAddr != "" {
+		t.Fatalf("expected 2 anonymized events; received %+v", anonymized)
+	}
+
+	other, err := s.Events(&EventQuery{Username: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(other) != 1 || other[0].Message != "removed for anonymous-1, not administrator" {
+		t.Fatalf("expected the username to be replaced in the message; received %+v", other)
+	}
+
+	if err := s.PurgeEvents(); err != nil {
+		t.Fatal(err)
+	}
+
+	if all, _ := s.Events(&EventQuery{}); len(all) != 0 {
+		t.Fatalf("expected events to be purged; received %d", len(all))
+	}
+}
+
+func TestBoltWatchEvents(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	done := make(chan struct{})
+	events, err := s.WatchEvents(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveEvent(&shipyard.Event{Type: "add-account", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case evt := <-events:
+		if evt.Type != "add-account" {
+			t.Fatalf("expected add-account; received %s", evt.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the saved event")
+	}
+
+	close(done)
+
+	// the channel is closed once done is
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("expected no more events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+func TestBoltShareLinks(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	link := &shipyard.ShareLink{ID: "link", Token: "link.signature", Views: []string{shipyard.ShareViewDashboard}, CreatedAt: time.Now()}
+	if err := s.SaveShareLink(link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RecordShareLinkAccess("link", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.ShareLink("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.Token != "" || found.AccessCount != 1 || found.LastAccessed == nil {
+		t.Fatalf("unexpected share link: %+v", found)
+	}
+
+	if err := s.RecordShareLinkAccess("unknown", time.Now()); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltNotes(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	for _, note := range []*shipyard.Note{
+		{ID: shipyard.NoteID(shipyard.NoteNode, "node-1"), Text: "drain first"},
+		{ID: shipyard.NoteID(shipyard.NoteContainer, "web"), Runbooks: []string{"https://wiki.local/web"}},
+	} {
+		if err := s.SaveNote(note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notes, err := s.Notes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notes) != 2 || notes[0].ID != "container:web" {
+		t.Fatalf("expected notes sorted by id; received %+v", notes)
+	}
+
+	if err := s.DeleteNote("container:web"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Note("container:web"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltAuditEntries(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	now := time.Now()
+	for i, username := range []string{"admin", "ci", "admin"} {
+		entry := &shipyard.AuditEntry{
+			Time:       now.Add(time.Duration(i) * time.Minute),
+			Username:   username,
+			RemoteAddr: "10.0.0.1",
+			Method:     "POST",
+			Route:      "/containers/web/stop",
+		}
+		if err := s.SaveAuditEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := s.AuditEntries(&AuditQuery{Username: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || !entries[0].Time.After(entries[1].Time) {
+		t.Fatalf("expected the entries of admin newest first; received %+v", entries)
+	}
+
+	entries, err = s.AuditEntries(&AuditQuery{After: now, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Username != "admin" {
+		t.Fatalf("expected only the latest entry; received %+v", entries)
+	}
+
+	if err := s.SaveAuditEntry(&shipyard.AuditEntry{
+		Time:     now.Add(30 * time.Second),
+		Username: "admin",
+		Method:   "POST",
+		Route:    "/api/accounts/ci",
+		Payload:  `{"username":"ci"}`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AnonymizeAuditEntries("ci", "anonymous-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = s.AuditEntries(&AuditQuery{Username: "admin", After: now, Before: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Route != "/api/accounts/anonymous-1" || entries[0].Payload != `{"username":"anonymous-1"}` {
+		t.Fatalf("expected the username to be replaced in the route and payload; received %+v", entries)
+	}
+
+	entries, err = s.AuditEntries(&AuditQuery{Username: "anonymous-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].RemoteAddr != "" {
+		t.Fatalf("expected the entry to be anonymized; received %+v", entries)
+	}
+}
+
+func TestBoltClusters(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	for _, name := range []string{"staging", "production"} {
+		if err := s.SaveCluster(&shipyard.Cluster{Name: name, DockerURL: "tcp://" + name + ".local:2376"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusters, err := s.Clusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clusters) != 2 || clusters[0].Name != "production" || clusters[1].Name != "staging" {
+		t.Fatalf("expected the clusters sorted by name; received %+v", clusters)
+	}
+
+	cluster, err := s.Cluster("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cluster.DockerURL != "tcp://staging.local:2376" {
+		t.Fatalf("expected the staging cluster; received %+v", cluster)
+	}
+
+	if err := s.DeleteCluster("staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Cluster("staging"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltImageScans(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	id := shipyard.ImageScanID("local", "team/web", "1.2")
+	if _, err := s.ImageScan(id); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+
+	for _, status := range []string{shipyard.ScanRunning, shipyard.ScanDone} {
+		if err := s.SaveImageScan(&shipyard.ImageScan{ID: id, Registry: "local", Repository: "team/web", Tag: "1.2", Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan, err := s.ImageScan(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scan.Status != shipyard.ScanDone || scan.Repository != "team/web" {
+		t.Fatalf("expected the latest scan; received %+v", scan)
+	}
+}
+
+func TestBoltStacks(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	for _, name := range []string{"shop", "blog"} {
+		stack := &shipyard.Stack{
+			Name:       name,
+			Compose:    "services:\n  web:\n    image: nginx\n",
+			Services:   []string{"web"},
+			Containers: []dockerclient.Container{{Id: "abc"}},
+		}
+		if err := s.SaveStack(stack); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stacks, err := s.Stacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stacks) != 2 || stacks[0].Name != "blog" {
+		t.Fatalf("expected stacks sorted by name; received %+v", stacks)
+	}
+
+	stack, err := s.Stack("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stack.Containers) != 0 {
+		t.Fatalf("expected containers not to be stored; received %+v", stack.Containers)
+	}
+
+	if err := s.DeleteStack("shop"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stack("shop"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltManagedNodes(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	for _, name := range []string{"node-2", "node-1"} {
+		if err := s.SaveManagedNode(&shipyard.ManagedNode{Name: name, Addr: name + ":2376", TLSKey: "key"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nodes, err := s.ManagedNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 || nodes[0].Name != "node-1" {
+		t.Fatalf("expected nodes sorted by name; received %+v", nodes)
+	}
+
+	node, err := s.ManagedNode("node-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.TLSKey != "key" {
+		t.Fatalf("expected the tls key to be stored; received %q", node.TLSKey)
+	}
+
+	if err := s.DeleteManagedNode("node-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ManagedNode("node-2"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltRegistryMirror(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if _, err := s.RegistryMirror(); err != ErrNotFound {
+		t.Fatalf("expected %s without a mirror; received %v", ErrNotFound, err)
+	}
+
+	for _, u := range []string{"https://mirror-1.local", "https://mirror-2.local"} {
+		if err := s.SaveRegistryMirror(&shipyard.RegistryMirror{URL: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mirror, err := s.RegistryMirror()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mirror.URL != "https://mirror-2.local" || mirror.ID != shipyard.RegistryMirrorID {
+		t.Fatalf("expected the mirror to be replaced; received %+v", mirror)
+	}
+
+	if err := s.DeleteRegistryMirror(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteRegistryMirror(); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltClusterEndpoint(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if _, err := s.ClusterEndpoint(); err != ErrNotFound {
+		t.Fatalf("expected %s without an endpoint; received %v", ErrNotFound, err)
+	}
+
+	if err := s.SaveClusterEndpoint(&shipyard.ClusterEndpoint{DockerURL: "tcp://swarm:3376", TLSKey: "key"}); err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint, err := s.ClusterEndpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if endpoint.DockerURL != "tcp://swarm:3376" || endpoint.TLSKey != "key" || endpoint.ID != shipyard.ClusterEndpointID {
+		t.Fatalf("expected the saved endpoint; received %+v", endpoint)
+	}
+}
+
+func TestBoltContainerDefaults(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if _, err := s.ContainerDefaults(); err != ErrNotFound {
+		t.Fatalf("expected %s without defaults; received %v", ErrNotFound, err)
+	}
+
+	if err := s.SaveContainerDefaults(&shipyard.ContainerDefaults{
+		Env: map[string]string{"TZ": "UTC"},
+		Environments: map[string]*shipyard.ContainerDefaultsOverride{
+			"production": {Labels: map[string]string{"tier": "prod"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults, err := s.ContainerDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if defaults.Env["TZ"] != "UTC" || defaults.Environments["production"].Labels["tier"] != "prod" || defaults.ID != shipyard.ContainerDefaultsID {
+		t.Fatalf("expected the saved defaults; received %+v", defaults)
+	}
+
+	if err := s.DeleteContainerDefaults(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteContainerDefaults(); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltTemplates(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	template := &shipyard.Template{
+		Name:       "wordpress",
+		Parameters: []shipyard.TemplateParameter{{Name: "PORT", Default: "8080"}},
+		Compose:    "services:\n  web:\n    image: wordpress\n",
+	}
+	if err := s.SaveTemplate(template); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := s.Template("wordpress")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(saved.Parameters) != 1 || saved.Parameters[0].Default != "8080" {
+		t.Fatalf("expected the parameters to be saved; received %+v", saved.Parameters)
+	}
+
+	if err := s.DeleteTemplate("wordpress"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Template("wordpress"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltPing(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if err := s.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+
+	if err := s.Ping(); err == nil {
+		t.Fatal("expected a closed datastore to fail")
+	}
+}
+
+func TestBoltExecRecordings(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	started := time.Now()
+	for i, id := range []string{"exec-0", "exec-1"} {
+		if err := s.SaveExecRecording(&shipyard.ExecRecording{
+			ID:        id,
+			StartedAt: started.Add(time.Duration(i) * time.Minute),
+			Frames:    []*shipyard.ExecRecordingFrame{{Time: 0.5, Data: "$ "}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recordings, err := s.ExecRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recordings) != 2 || recordings[0].ID != "exec-1" {
+		t.Fatalf("expected the newest recording first; received %+v", recordings)
+	}
+
+	if recordings[0].Frames != nil {
+		t.Fatal("expected recordings to be listed without frames")
+	}
+
+	rec, err := s.ExecRecording("exec-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.Frames) != 1 || rec.Frames[0].Data != "$ " {
+		t.Fatalf("expected the frames of the recording; received %+v", rec.Frames)
+	}
+
+	if _, err := s.ExecRecording("exec-2"); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltDeployments(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	start := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i, stack := range []string{"shop", "blog", "shop"} {
+		if err := s.SaveDeployment(&shipyard.Deployment{
+			ID:        fmt.Sprintf("deploy-%d", i),
+			Stack:     stack,
+			StartedAt: start.Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deployments, err := s.Deployments("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deployments) != 2 || deployments[0].ID != "deploy-2" {
+		t.Fatalf("expected the deployments of the stack newest first; received %+v", deployments)
+	}
+}
+
+func TestBoltBreakGlass(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if _, err := s.BreakGlass(); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+
+	if err := s.SaveBreakGlass(&auth.BreakGlass{CredentialHash: "hash", SealedBy: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := s.UseBreakGlass("10.0.0.1", "outage", now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the credential is single use
+	if err := s.UseBreakGlass("10.0.0.2", "again", now, now.Add(time.Hour)); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+
+	b, err := s.BreakGlass()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.CredentialHash != "hash" || b.UsedFrom != "10.0.0.1" || !b.Active(now) {
+		t.Fatalf("unexpected break-glass credential: %+v", b)
+	}
+}
+
+func TestBoltAlerts(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	start := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i, status := range []string{shipyard.AlertStatusActive, shipyard.AlertStatusResolved, shipyard.AlertStatusActive} {
+		if err := s.SaveAlert(&shipyard.Alert{
+			ID:        fmt.Sprintf("alert-%d", i),
+			Status:    status,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts, err := s.Alerts(shipyard.AlertStatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(alerts) != 2 || alerts[0].ID != "alert-2" {
+		t.Fatalf("expected the active alerts newest first; received %+v", alerts)
+	}
+
+	if err := s.UpdateAlert("alert-0", func(alert *shipyard.Alert) error {
+		alert.AssignedTo = "alice"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	alert, err := s.Alert("alert-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if alert.AssignedTo != "alice" {
+		t.Fatalf("expected the alert to be assigned; received %+v", alert)
+	}
+
+	if err := s.UpdateAlert("unknown", func(alert *shipyard.Alert) error { return nil }); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltNotifications(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	n := &notification.Notifier{Name: "ops", Type: "slack"}
+	if err := s.SaveNotifier(n); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveNotificationRule(&notification.Rule{Name: "critical", Notifiers: []string{n.ID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := s.NotificationRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 1 || rules[0].Notifiers[0] != n.ID {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	esc := &notification.Escalation{RuleID: rules[0].ID, Due: time.Now()}
+	if err := s.SaveEscalation(esc); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateEscalation(esc.ID, func(e *notification.Escalation) error {
+		e.Acknowledged = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	escalations, err := s.Escalations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(escalations) != 1 || !escalations[0].Acknowledged {
+		t.Fatalf("expected the escalation to be acknowledged; received %+v", escalations)
+	}
+
+	if err := s.DeleteNotifier(n.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Notifier(n.ID); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+}
+
+func TestBoltAuditRecords(t *testing.T) {
+	s, cleanup := newTestBolt(t)
+	defer cleanup()
+
+	if _, err := s.LastAuditRecord(); err != ErrNotFound {
+		t.Fatalf("expected %s; received %v", ErrNotFound, err)
+	}
+
+	// sequences beyond one byte keep their order
+	for _, seq := range []int64{1, 2, 256} {
+		if err := s.AppendAuditRecord(&shipyard.AuditRecord{Sequence: seq, Data: fmt.Sprintf("record %d", seq)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.AppendAuditRecord(&shipyard.AuditRecord{Sequence: 2, Data: "rewrite"}); err != ErrAuditRecordExists {
+		t.Fatalf("expected %s; received %v", ErrAuditRecordExists, err)
+	}
+
+	if err := s.RedactAuditRecord(&shipyard.AuditRecord{Sequence: 2, Data: "redacted"}); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := s.LastAuditRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last.Sequence != 256 {
+		t.Fatalf("expected the last record; received %+v", last)
+	}
+
+	records, err := s.AuditRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || records[1].Data != "redacted" || !records[1].Redacted || records[2].Sequence != 256 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestReplaceUsername(t *testing.T) {
+	for _, c := range [][]string{
+		{"username=bob", "username=anonymous-1"},
+		{"bob bob", "anonymous-1 anonymous-1"},
+		{"/api/accounts/bob/export", "/api/accounts/anonymous-1/export"},
+		{"bobby and bob.smith", "bobby and bob.smith"},
+		{"", ""},
+	} {
+		if replaced := ReplaceUsername(c[0], "bob", "anonymous-1"); replaced != c[1] {
+			t.Fatalf("expected %q for %q; received %q", c[1], c[0], replaced)
+		}
+	}
+}
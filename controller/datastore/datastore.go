@@ -0,0 +1,443 @@
+package datastore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	RethinkDB = "rethinkdb"
+	Bolt      = "bolt"
+
+	// breakGlassID keys the single break-glass credential
+	breakGlassID = "breakglass"
+
+	// Fields events can be sorted by other than the time
+	EventSortType     = "type"
+	EventSortUsername = "username"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrUnknownDatastore = errors.New("unknown datastore")
+	// ErrAuditRecordExists is returned when appending a record with the
+	// sequence of an existing one, i.e. by a concurrent append
+	ErrAuditRecordExists = errors.New("audit record exists")
+)
+
+type (
+	// Datastore persists the accounts, roles, keys, registries, console
+	// sessions, exec policies and recordings, break-glass access, share
+	// links, notes, freezes, stacks and their deployments, templates,
+	// alerts, notifications, audit entries, the audit log and events of the
+	// controller; lookups of missing records return ErrNotFound
+	Datastore interface {
+		Name() string
+		Close() error
+		// Ping checks the datastore can be queried
+		Ping() error
+
+		// Accounts are sorted by username
+		Accounts(query *AccountQuery) ([]*auth.Account, error)
+		Account(username string) (*auth.Account, error)
+		CreateAccount(account *auth.Account) error
+		// UpdateAccount saves the changes fn makes to the account
+		UpdateAccount(username string, fn func(account *auth.Account) error) error
+		DeleteAccount(username string) error
+
+		// Roles are the custom roles sorted by name
+		Roles() ([]*auth.ACL, error)
+		// SaveRole creates or replaces the role
+		SaveRole(role *auth.ACL) error
+		DeleteRole(name string) error
+
+		ServiceKeys() ([]*auth.ServiceKey, error)
+		ServiceKey(key string) (*auth.ServiceKey, error)
+		SaveServiceKey(key *auth.ServiceKey) error
+		// DeleteServiceKey also removes the usage of the key
+		DeleteServiceKey(key string) error
+		ServiceKeyUsage(key string) (*auth.ServiceKeyUsage, error)
+		// AddServiceKeyUsage adds the counts, addresses and routes of
+		// the usage to the usage of its key
+		AddServiceKeyUsage(usage *auth.ServiceKeyUsage) error
+		// DeleteOrphanKeyUsage removes the usage of service keys which no
+		// longer exist and returns how many were removed
+		DeleteOrphanKeyUsage() (int, error)
+
+		// WebhookKeys are sorted by image
+		WebhookKeys() ([]*dockerhub.WebhookKey, error)
+		WebhookKey(key string) (*dockerhub.WebhookKey, error)
+		SaveWebhookKey(key *dockerhub.WebhookKey) error
+		DeleteWebhookKey(id string) error
+
+		// Registries are sorted by name
+		Registries() ([]*shipyard.Registry, error)
+		Registry(id string) (*shipyard.Registry, error)
+		RegistryByAddress(addr string) (*shipyard.Registry, error)
+		SaveRegistry(registry *shipyard.Registry) error
+		DeleteRegistry(id string) error
+
+		ConsoleSession(token string) (*shipyard.ConsoleSession, error)
+		SaveConsoleSession(c *shipyard.ConsoleSession) error
+		DeleteConsoleSession(id string) error
+
+		// ExecPolicies are sorted by role
+		ExecPolicies() ([]*auth.ExecPolicy, error)
+		ExecPolicy(id string) (*auth.ExecPolicy, error)
+		// SaveExecPolicy creates or replaces the policy
+		SaveExecPolicy(policy *auth.ExecPolicy) error
+		DeleteExecPolicy(id string) error
+
+		// BreakGlass returns ErrNotFound when no credential was sealed
+		BreakGlass() (*auth.BreakGlass, error)
+		// SaveBreakGlass creates or replaces the credential
+		SaveBreakGlass(b *auth.BreakGlass) error
+		// UseBreakGlass marks the sealed credential used from the address
+		// until it expires; it returns ErrNotFound when the credential is
+		// not sealed or was used already, so it is used once
+		UseBreakGlass(usedFrom, reason string, usedAt, expiresAt time.Time) error
+
+		// Alerts are sorted newest first; an empty status returns every
+		// alert
+		Alerts(status string) ([]*shipyard.Alert, error)
+		Alert(id string) (*shipyard.Alert, error)
+		// SaveAlert creates or replaces the alert
+		SaveAlert(alert *shipyard.Alert) error
+		// UpdateAlert saves the changes fn makes to the alert
+		UpdateAlert(id string, fn func(alert *shipyard.Alert) error) error
+
+		// Notifiers are sorted by name
+		Notifiers() ([]*notification.Notifier, error)
+		Notifier(id string) (*notification.Notifier, error)
+		// SaveNotifier creates or replaces the notifier
+		SaveNotifier(n *notification.Notifier) error
+		DeleteNotifier(id string) error
+
+		// NotificationRules are sorted by name
+		NotificationRules() ([]*notification.Rule, error)
+		NotificationRule(id string) (*notification.Rule, error)
+		// SaveNotificationRule creates or replaces the rule
+		SaveNotificationRule(rule *notification.Rule) error
+		DeleteNotificationRule(id string) error
+
+		// Escalations are sorted by due time, latest first
+		Escalations() ([]*notification.Escalation, error)
+		// SaveEscalation creates or replaces the escalation
+		SaveEscalation(esc *notification.Escalation) error
+		// UpdateEscalation saves the changes fn makes to the escalation
+		UpdateEscalation(id string, fn func(esc *notification.Escalation) error) error
+		DeleteEscalation(id string) error
+
+		// ExecRecordings are sorted newest first and have no frames
+		ExecRecordings() ([]*shipyard.ExecRecording, error)
+		ExecRecording(id string) (*shipyard.ExecRecording, error)
+		SaveExecRecording(rec *shipyard.ExecRecording) error
+
+		// ShareLinks are sorted newest first
+		ShareLinks() ([]*shipyard.ShareLink, error)
+		ShareLink(id string) (*shipyard.ShareLink, error)
+		// SaveShareLink creates or replaces the link
+		SaveShareLink(link *shipyard.ShareLink) error
+		RecordShareLinkAccess(id string, t time.Time) error
+		DeleteShareLink(id string) error
+
+		// Notes are sorted by id
+		Notes() ([]*shipyard.Note, error)
+		Note(id string) (*shipyard.Note, error)
+		// SaveNote creates or replaces the note
+		SaveNote(note *shipyard.Note) error
+		DeleteNote(id string) error
+
+		// Freezes are sorted by start
+		Freezes() ([]*shipyard.Freeze, error)
+		Freeze(id string) (*shipyard.Freeze, error)
+		// SaveFreeze creates or replaces the freeze
+		SaveFreeze(freeze *shipyard.Freeze) error
+		DeleteFreeze(id string) error
+
+		// ClientRules are sorted by route prefix
+		ClientRules() ([]*shipyard.ClientRule, error)
+		ClientRule(id string) (*shipyard.ClientRule, error)
+		// SaveClientRule creates or replaces the rule
+		SaveClientRule(rule *shipyard.ClientRule) error
+		DeleteClientRule(id string) error
+
+		// Jobs are sorted by name
+		Jobs() ([]*shipyard.Job, error)
+		Job(id string) (*shipyard.Job, error)
+		// SaveJob creates or replaces the job
+		SaveJob(job *shipyard.Job) error
+		DeleteJob(id string) error
+
+		// Stacks are sorted by name
+		Stacks() ([]*shipyard.Stack, error)
+		Stack(name string) (*shipyard.Stack, error)
+		// SaveStack creates or replaces the stack
+		SaveStack(stack *shipyard.Stack) error
+		DeleteStack(name string) error
+		// Clusters are sorted by name
+		Clusters() ([]*shipyard.Cluster, error)
+		Cluster(name string) (*shipyard.Cluster, error)
+		// SaveCluster creates or replaces the cluster
+		SaveCluster(cluster *shipyard.Cluster) error
+		DeleteCluster(name string) error
+		ImageScan(id string) (*shipyard.ImageScan, error)
+		// SaveImageScan creates or replaces the scan of the image tag
+		SaveImageScan(scan *shipyard.ImageScan) error
+		// Deployments are the deployments of the stack sorted newest
+		// first
+		Deployments(stack string) ([]*shipyard.Deployment, error)
+		SaveDeployment(d *shipyard.Deployment) error
+
+		// RegistryMirror returns ErrNotFound when no mirror is set
+		RegistryMirror() (*shipyard.RegistryMirror, error)
+		// SaveRegistryMirror creates or replaces the mirror
+		SaveRegistryMirror(mirror *shipyard.RegistryMirror) error
+		DeleteRegistryMirror() error
+
+		// ClusterEndpoint returns ErrNotFound when the endpoint of the
+		// default cluster was not changed
+		ClusterEndpoint() (*shipyard.ClusterEndpoint, error)
+		SaveClusterEndpoint(endpoint *shipyard.ClusterEndpoint) error
+
+		// ContainerDefaults returns ErrNotFound when no defaults are set
+		ContainerDefaults() (*shipyard.ContainerDefaults, error)
+		SaveContainerDefaults(defaults *shipyard.ContainerDefaults) error
+		DeleteContainerDefaults() error
+
+		// ManagedNodes are sorted by name
+		ManagedNodes() ([]*shipyard.ManagedNode, error)
+		ManagedNode(name string) (*shipyard.ManagedNode, error)
+		// SaveManagedNode creates or replaces the node
+		SaveManagedNode(node *shipyard.ManagedNode) error
+		DeleteManagedNode(name string) error
+
+		// Templates are sorted by name
+		Templates() ([]*shipyard.Template, error)
+		Template(name string) (*shipyard.Template, error)
+		// SaveTemplate creates or replaces the template
+		SaveTemplate(template *shipyard.Template) error
+		DeleteTemplate(name string) error
+
+		SaveAuditEntry(entry *shipyard.AuditEntry) error
+		AuditEntries(query *AuditQuery) ([]*shipyard.AuditEntry, error)
+		// AnonymizeAuditEntries replaces the username of the entries of a
+		// user and removes the source address; the username is also
+		// replaced in the route and payload of every entry
+		AnonymizeAuditEntries(username, pseudonym string) error
+
+		// AuditRecords are the records of the hash chained audit log
+		// sorted by sequence
+		AuditRecords() ([]*shipyard.AuditRecord, error)
+		// LastAuditRecord returns ErrNotFound when the log is empty
+		LastAuditRecord() (*shipyard.AuditRecord, error)
+		// AppendAuditRecord returns ErrAuditRecordExists when a record
+		// with the sequence exists, so history is never overwritten
+		AppendAuditRecord(rec *shipyard.AuditRecord) error
+		// RedactAuditRecord replaces the data of the record and marks it
+		// redacted; the hashes are kept
+		RedactAuditRecord(rec *shipyard.AuditRecord) error
+
+		SaveEvent(event *shipyard.Event) error
+		Events(query *EventQuery) ([]*shipyard.Event, error)
+		// AnonymizeEvents replaces the username of the events of a user
+		// and removes the source address and location; the username is
+		// also replaced in the message of every event
+		AnonymizeEvents(username, pseudonym string) error
+		PurgeEvents() error
+		// WatchEvents returns new events until done is closed
+		WatchEvents(done <-chan struct{}) (<-chan *shipyard.Event, error)
+
+		// Check verifies the tables or buckets of the store and its
+		// consistency and returns the problems found
+		Check() ([]string, error)
+		// Size returns the space taken by the store; stores which do not
+		// report it return zeros
+		Size() (*Size, error)
+	}
+
+	// Size is the space taken by a store
+	Size struct {
+		Bytes int64 `json:"bytes"`
+		// FreeBytes are unused space in the store a compaction would
+		// reclaim
+		FreeBytes int64 `json:"free_bytes"`
+	}
+
+	// EventQuery filters events; zero values match every event
+	EventQuery struct {
+		Type     string
+		Username string
+		// ContainerId matches the events of containers with an id
+		// starting with it
+		ContainerId string
+		// After and Before exclude events at the time itself
+		After  time.Time
+		Before time.Time
+		// Offset skips the first matching events
+		Offset int
+		// Limit of zero or less returns every matching event
+		Limit int
+		// Ascending sorts the oldest event first; the default is newest
+		// first
+		Ascending bool
+		// SortBy sorts by EventSortType or EventSortUsername instead of
+		// the time; Ascending applies to them as well
+		SortBy string
+	}
+
+	// AccountQuery filters accounts which are returned sorted by
+	// username; zero values match every account
+	AccountQuery struct {
+		// Role matches the accounts having the role
+		Role string
+		// Prefix matches the usernames starting with it
+		Prefix string
+		// Offset skips the first matching accounts
+		Offset int
+		// Limit of zero or less returns every matching account
+		Limit int
+		// Descending sorts the usernames from z to a
+		Descending bool
+	}
+
+	// AuditQuery filters audit entries which are returned newest first;
+	// zero values match every entry
+	AuditQuery struct {
+		Username string
+		// After and Before exclude entries at the time itself
+		After  time.Time
+		Before time.Time
+		// Limit of zero or less returns every matching entry
+		Limit int
+	}
+)
+
+// Match reports whether the event matches the query filters
+func (q *EventQuery) Match(evt *shipyard.Event) bool {
+	if q.Type != "" && evt.Type != q.Type {
+		return false
+	}
+
+	if q.Username != "" && evt.Username != q.Username {
+		return false
+	}
+
+	if q.ContainerId != "" && (evt.ContainerInfo == nil || !strings.HasPrefix(evt.ContainerInfo.Id, q.ContainerId)) {
+		return false
+	}
+
+	if !q.After.IsZero() && !evt.Time.After(q.After) {
+		return false
+	}
+
+	if !q.Before.IsZero() && !evt.Time.Before(q.Before) {
+		return false
+	}
+
+	return true
+}
+
+// Match reports whether the account matches the query filters
+func (q *AccountQuery) Match(account *auth.Account) bool {
+	if q.Prefix != "" && !strings.HasPrefix(account.Username, q.Prefix) {
+		return false
+	}
+
+	if q.Role == "" {
+		return true
+	}
+
+	for _, role := range account.Roles {
+		if role == q.Role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// page returns the bounds of the page of a list of n results
+func page(n, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > n {
+		offset = n
+	}
+
+	end := n
+	if limit > 0 && offset+limit < n {
+		end = offset + limit
+	}
+
+	return offset, end
+}
+
+// Match reports whether the entry matches the query filters
+func (q *AuditQuery) Match(entry *shipyard.AuditEntry) bool {
+	if q.Username != "" && entry.Username != q.Username {
+		return false
+	}
+
+	if !q.After.IsZero() && !entry.Time.After(q.After) {
+		return false
+	}
+
+	if !q.Before.IsZero() && !entry.Time.Before(q.Before) {
+		return false
+	}
+
+	return true
+}
+
+// isUsernameChar reports whether the byte can be part of a username
+func isUsernameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("._-@", c) >= 0
+}
+
+// ReplaceUsername replaces the username in the text with the pseudonym;
+// only whole occurrences are replaced so "bob" is kept in "bobby"
+func ReplaceUsername(text, username, pseudonym string) string {
+	if username == "" {
+		return text
+	}
+
+	replaced := ""
+	start := 0
+	for {
+		i := strings.Index(text[start:], username)
+		if i < 0 {
+			break
+		}
+
+		i += start
+		end := i + len(username)
+		replaced += text[start:i]
+		if (i == 0 || !isUsernameChar(text[i-1])) && (end == len(text) || !isUsernameChar(text[end])) {
+			replaced += pseudonym
+		} else {
+			replaced += username
+		}
+		start = end
+	}
+
+	return replaced + text[start:]
+}
+
+func generateID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,1156 @@
+package datastore
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/notification"
+	r "gopkg.in/dancannon/gorethink.v2"
+)
+
+const (
+	tblNameEvents      = "events"
+	tblNameAccounts    = "accounts"
+	tblNameRoles       = "roles"
+	tblNameServiceKeys = "service_keys"
+	tblNameWebhookKeys = "webhook_keys"
+	tblNameRegistries  = "registries"
+	tblNameKeyUsage    = "service_key_usage"
+	tblNameConsole     = "console"
+	tblNameRecordings  = "exec_recordings"
+	tblNameShareLinks  = "share_links"
+	tblNameNotes       = "notes"
+	tblNameAudit       = "audit_entries"
+	tblNameFreezes     = "freezes"
+	tblNameClientRules = "client_rules"
+	tblNameJobs        = "jobs"
+	tblNameStacks      = "stacks"
+	tblNameDeployments = "deployments"
+	tblNameTemplates   = "templates"
+	tblNameNodes       = "managed_nodes"
+	tblNameClusters    = "clusters"
+	tblNameImageScans  = "image_scans"
+	tblNameConfig      = "config"
+	tblNameExecPolicy  = "exec_policies"
+	tblNameBreakGlass  = "break_glass"
+	tblNameAlerts      = "alerts"
+	tblNameNotifiers   = "notifiers"
+	tblNameRules       = "notification_rules"
+	tblNameEscalations = "escalations"
+	tblNameAuditLog    = "audit_log"
+)
+
+// tables are the tables of the datastore
+var tables = []string{tblNameEvents, tblNameAccounts, tblNameRoles, tblNameServiceKeys, tblNameWebhookKeys, tblNameRegistries, tblNameKeyUsage, tblNameConsole, tblNameRecordings, tblNameShareLinks, tblNameNotes, tblNameAudit, tblNameFreezes, tblNameClientRules, tblNameJobs, tblNameStacks, tblNameDeployments, tblNameTemplates, tblNameNodes, tblNameClusters, tblNameImageScans, tblNameConfig, tblNameExecPolicy, tblNameBreakGlass, tblNameAlerts, tblNameNotifiers, tblNameRules, tblNameEscalations, tblNameAuditLog}
+
+type (
+	rethinkStore struct {
+		session *r.Session
+	}
+
+	eventChange struct {
+		NewVal *shipyard.Event `gorethink:"new_val"`
+	}
+)
+
+// NewRethinkDB returns a datastore using the tables of the session
+// database; the tables are created by the manager
+func NewRethinkDB(session *r.Session) Datastore {
+	return &rethinkStore{
+		session: session,
+	}
+}
+
+func (s *rethinkStore) Name() string {
+	return RethinkDB
+}
+
+func (s *rethinkStore) Close() error {
+	return s.session.Close()
+}
+
+func (s *rethinkStore) Ping() error {
+	res, err := r.Expr(1).Run(s.session)
+	if err != nil {
+		return err
+	}
+
+	return res.Close()
+}
+
+// one runs a query returning a single document; no document is ErrNotFound
+func (s *rethinkStore) one(t r.Term, v interface{}) error {
+	res, err := t.Run(s.session)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	if res.IsNil() {
+		return ErrNotFound
+	}
+
+	return res.One(v)
+}
+
+func (s *rethinkStore) all(t r.Term, v interface{}) error {
+	res, err := t.Run(s.session)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	return res.All(v)
+}
+
+// delete runs a delete query; nothing deleted is ErrNotFound
+func (s *rethinkStore) delete(t r.Term) error {
+	res, err := t.Delete().RunWrite(s.session)
+	if err != nil {
+		return err
+	}
+
+	if res.Deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *rethinkStore) Accounts(query *AccountQuery) ([]*auth.Account, error) {
+	t := r.Table(tblNameAccounts)
+
+	if query.Role != "" {
+		t = t.Filter(r.Row.Field("roles").Default([]string{}).Contains(query.Role))
+	}
+	if query.Prefix != "" {
+		t = t.Filter(r.Row.Field("username").Match("^" + regexp.QuoteMeta(query.Prefix)))
+	}
+
+	if query.Descending {
+		t = t.OrderBy(r.Desc("username"))
+	} else {
+		t = t.OrderBy(r.Asc("username"))
+	}
+
+	if query.Offset > 0 {
+		t = t.Skip(query.Offset)
+	}
+	if query.Limit > 0 {
+		t = t.Limit(query.Limit)
+	}
+
+	accounts := []*auth.Account{}
+	if err := s.all(t, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (s *rethinkStore) Account(username string) (*auth.Account, error) {
+	var account *auth.Account
+	if err := s.one(r.Table(tblNameAccounts).Filter(map[string]string{"username": username}), &account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *rethinkStore) CreateAccount(account *auth.Account) error {
+	_, err := r.Table(tblNameAccounts).Insert(account).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) UpdateAccount(username string, fn func(account *auth.Account) error) error {
+	account, err := s.Account(username)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(account); err != nil {
+		return err
+	}
+
+	_, err = r.Table(tblNameAccounts).Get(account.ID).Replace(account).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteAccount(username string) error {
+	return s.delete(r.Table(tblNameAccounts).Filter(map[string]string{"username": username}))
+}
+
+func (s *rethinkStore) Roles() ([]*auth.ACL, error) {
+	roles := []*auth.ACL{}
+	if err := s.all(r.Table(tblNameRoles).OrderBy(r.Asc("id")), &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (s *rethinkStore) SaveRole(role *auth.ACL) error {
+	_, err := r.Table(tblNameRoles).Insert(role, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteRole(name string) error {
+	return s.delete(r.Table(tblNameRoles).Get(name))
+}
+
+func (s *rethinkStore) ServiceKeys() ([]*auth.ServiceKey, error) {
+	keys := []*auth.ServiceKey{}
+	if err := s.all(r.Table(tblNameServiceKeys), &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *rethinkStore) ServiceKey(key string) (*auth.ServiceKey, error) {
+	var k *auth.ServiceKey
+	if err := s.one(r.Table(tblNameServiceKeys).Filter(map[string]string{"key": key}), &k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (s *rethinkStore) SaveServiceKey(key *auth.ServiceKey) error {
+	_, err := r.Table(tblNameServiceKeys).Insert(key).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteServiceKey(key string) error {
+	if _, err := r.Table(tblNameServiceKeys).Filter(map[string]string{"key": key}).Delete().RunWrite(s.session); err != nil {
+		return err
+	}
+
+	_, err := r.Table(tblNameKeyUsage).Get(key).Delete().RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteOrphanKeyUsage() (int, error) {
+	res, err := r.Table(tblNameKeyUsage).Filter(func(usage r.Term) r.Term {
+		return r.Table(tblNameServiceKeys).Filter(map[string]interface{}{"key": usage.Field("id")}).IsEmpty()
+	}).Delete().RunWrite(s.session)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.Deleted, nil
+}
+
+func (s *rethinkStore) ServiceKeyUsage(key string) (*auth.ServiceKeyUsage, error) {
+	var usage *auth.ServiceKeyUsage
+	if err := s.one(r.Table(tblNameKeyUsage).Get(key), &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (s *rethinkStore) AddServiceKeyUsage(usage *auth.ServiceKeyUsage) error {
+	_, err := r.Table(tblNameKeyUsage).Get(usage.Key).Replace(func(row r.Term) interface{} {
+		routes := map[string]interface{}{}
+		for route, n := range usage.Routes {
+			routes[route] = row.Field("routes").Field(route).Default(0).Add(n)
+		}
+
+		return r.Branch(row.Eq(nil), usage, row.Merge(map[string]interface{}{
+			"request_count": row.Field("request_count").Add(usage.RequestCount),
+			"last_used":     r.Branch(row.Field("last_used").Lt(usage.LastUsed), usage.LastUsed, row.Field("last_used")),
+			"source_ips":    row.Field("source_ips").SetUnion(usage.SourceIPs),
+			"routes":        row.Field("routes").Merge(routes),
+		}))
+	}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) WebhookKeys() ([]*dockerhub.WebhookKey, error) {
+	keys := []*dockerhub.WebhookKey{}
+	if err := s.all(r.Table(tblNameWebhookKeys).OrderBy(r.Asc("image")), &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *rethinkStore) WebhookKey(key string) (*dockerhub.WebhookKey, error) {
+	var k *dockerhub.WebhookKey
+	if err := s.one(r.Table(tblNameWebhookKeys).Filter(map[string]string{"key": key}), &k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (s *rethinkStore) SaveWebhookKey(key *dockerhub.WebhookKey) error {
+	_, err := r.Table(tblNameWebhookKeys).Insert(key).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteWebhookKey(id string) error {
+	return s.delete(r.Table(tblNameWebhookKeys).Get(id))
+}
+
+func (s *rethinkStore) Registries() ([]*shipyard.Registry, error) {
+	regs := []*shipyard.Registry{}
+	if err := s.all(r.Table(tblNameRegistries).OrderBy(r.Asc("name")), &regs); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
+
+func (s *rethinkStore) Registry(id string) (*shipyard.Registry, error) {
+	var reg *shipyard.Registry
+	if err := s.one(r.Table(tblNameRegistries).Filter(map[string]string{"id": id}), &reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (s *rethinkStore) RegistryByAddress(addr string) (*shipyard.Registry, error) {
+	var reg *shipyard.Registry
+	if err := s.one(r.Table(tblNameRegistries).Filter(map[string]string{"addr": addr}), &reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (s *rethinkStore) SaveRegistry(registry *shipyard.Registry) error {
+	_, err := r.Table(tblNameRegistries).Insert(registry).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteRegistry(id string) error {
+	return s.delete(r.Table(tblNameRegistries).Get(id))
+}
+
+func (s *rethinkStore) ConsoleSession(token string) (*shipyard.ConsoleSession, error) {
+	var c *shipyard.ConsoleSession
+	if err := s.one(r.Table(tblNameConsole).Filter(map[string]string{"token": token}), &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *rethinkStore) SaveConsoleSession(c *shipyard.ConsoleSession) error {
+	_, err := r.Table(tblNameConsole).Insert(c).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteConsoleSession(id string) error {
+	return s.delete(r.Table(tblNameConsole).Get(id))
+}
+
+func (s *rethinkStore) ExecPolicies() ([]*auth.ExecPolicy, error) {
+	policies := []*auth.ExecPolicy{}
+	if err := s.all(r.Table(tblNameExecPolicy).OrderBy(r.Asc("role")), &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (s *rethinkStore) ExecPolicy(id string) (*auth.ExecPolicy, error) {
+	var policy *auth.ExecPolicy
+	if err := s.one(r.Table(tblNameExecPolicy).Get(id), &policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (s *rethinkStore) SaveExecPolicy(policy *auth.ExecPolicy) error {
+	_, err := r.Table(tblNameExecPolicy).Insert(policy, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteExecPolicy(id string) error {
+	return s.delete(r.Table(tblNameExecPolicy).Get(id))
+}
+
+func (s *rethinkStore) BreakGlass() (*auth.BreakGlass, error) {
+	var b *auth.BreakGlass
+	if err := s.one(r.Table(tblNameBreakGlass).Get(breakGlassID), &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *rethinkStore) SaveBreakGlass(b *auth.BreakGlass) error {
+	b.ID = breakGlassID
+	_, err := r.Table(tblNameBreakGlass).Insert(b, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+// UseBreakGlass updates the credential only while it is unused so
+// concurrent uses on several controllers cannot both succeed
+func (s *rethinkStore) UseBreakGlass(usedFrom, reason string, usedAt, expiresAt time.Time) error {
+	res, err := r.Table(tblNameBreakGlass).Get(breakGlassID).Update(r.Branch(
+		r.Row.Field("credential_hash").Default("").Ne("").And(r.Row.Field("used_at").Default(nil).Eq(nil)),
+		map[string]interface{}{
+			"used_at":    usedAt,
+			"used_from":  usedFrom,
+			"reason":     reason,
+			"expires_at": expiresAt,
+		},
+		map[string]interface{}{},
+	)).RunWrite(s.session)
+	if err != nil {
+		return err
+	}
+
+	if res.Replaced == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *rethinkStore) Alerts(status string) ([]*shipyard.Alert, error) {
+	t := r.Table(tblNameAlerts)
+	if status != "" {
+		t = t.Filter(map[string]string{"status": status})
+	}
+
+	alerts := []*shipyard.Alert{}
+	if err := s.all(t.OrderBy(r.Desc("created_at")), &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+func (s *rethinkStore) Alert(id string) (*shipyard.Alert, error) {
+	var alert *shipyard.Alert
+	if err := s.one(r.Table(tblNameAlerts).Get(id), &alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (s *rethinkStore) SaveAlert(alert *shipyard.Alert) error {
+	_, err := r.Table(tblNameAlerts).Insert(alert, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) UpdateAlert(id string, fn func(alert *shipyard.Alert) error) error {
+	alert, err := s.Alert(id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(alert); err != nil {
+		return err
+	}
+
+	_, err = r.Table(tblNameAlerts).Get(id).Replace(alert).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) Notifiers() ([]*notification.Notifier, error) {
+	notifiers := []*notification.Notifier{}
+	if err := s.all(r.Table(tblNameNotifiers).OrderBy(r.Asc("name")), &notifiers); err != nil {
+		return nil, err
+	}
+	return notifiers, nil
+}
+
+func (s *rethinkStore) Notifier(id string) (*notification.Notifier, error) {
+	var n *notification.Notifier
+	if err := s.one(r.Table(tblNameNotifiers).Get(id), &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (s *rethinkStore) SaveNotifier(n *notification.Notifier) error {
+	_, err := r.Table(tblNameNotifiers).Insert(n, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteNotifier(id string) error {
+	return s.delete(r.Table(tblNameNotifiers).Get(id))
+}
+
+func (s *rethinkStore) NotificationRules() ([]*notification.Rule, error) {
+	rules := []*notification.Rule{}
+	if err := s.all(r.Table(tblNameRules).OrderBy(r.Asc("name")), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *rethinkStore) NotificationRule(id string) (*notification.Rule, error) {
+	var rule *notification.Rule
+	if err := s.one(r.Table(tblNameRules).Get(id), &rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *rethinkStore) SaveNotificationRule(rule *notification.Rule) error {
+	_, err := r.Table(tblNameRules).Insert(rule, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteNotificationRule(id string) error {
+	return s.delete(r.Table(tblNameRules).Get(id))
+}
+
+func (s *rethinkStore) Escalations() ([]*notification.Escalation, error) {
+	escalations := []*notification.Escalation{}
+	if err := s.all(r.Table(tblNameEscalations).OrderBy(r.Desc("due")), &escalations); err != nil {
+		return nil, err
+	}
+	return escalations, nil
+}
+
+func (s *rethinkStore) SaveEscalation(esc *notification.Escalation) error {
+	_, err := r.Table(tblNameEscalations).Insert(esc, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) UpdateEscalation(id string, fn func(esc *notification.Escalation) error) error {
+	var esc *notification.Escalation
+	if err := s.one(r.Table(tblNameEscalations).Get(id), &esc); err != nil {
+		return err
+	}
+
+	if err := fn(esc); err != nil {
+		return err
+	}
+
+	_, err := r.Table(tblNameEscalations).Get(id).Replace(esc).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteEscalation(id string) error {
+	return s.delete(r.Table(tblNameEscalations).Get(id))
+}
+
+func (s *rethinkStore) ExecRecordings() ([]*shipyard.ExecRecording, error) {
+	recordings := []*shipyard.ExecRecording{}
+	if err := s.all(r.Table(tblNameRecordings).OrderBy(r.Desc("started_at")).Without("frames"), &recordings); err != nil {
+		return nil, err
+	}
+	return recordings, nil
+}
+
+func (s *rethinkStore) ExecRecording(id string) (*shipyard.ExecRecording, error) {
+	var rec *shipyard.ExecRecording
+	if err := s.one(r.Table(tblNameRecordings).Get(id), &rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *rethinkStore) SaveExecRecording(rec *shipyard.ExecRecording) error {
+	_, err := r.Table(tblNameRecordings).Insert(rec, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) ShareLinks() ([]*shipyard.ShareLink, error) {
+	links := []*shipyard.ShareLink{}
+	if err := s.all(r.Table(tblNameShareLinks).OrderBy(r.Desc("created_at")), &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (s *rethinkStore) ShareLink(id string) (*shipyard.ShareLink, error) {
+	var link *shipyard.ShareLink
+	if err := s.one(r.Table(tblNameShareLinks).Get(id), &link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *rethinkStore) SaveShareLink(link *shipyard.ShareLink) error {
+	_, err := r.Table(tblNameShareLinks).Insert(link, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteShareLink(id string) error {
+	return s.delete(r.Table(tblNameShareLinks).Get(id))
+}
+
+func (s *rethinkStore) RecordShareLinkAccess(id string, t time.Time) error {
+	res, err := r.Table(tblNameShareLinks).Get(id).Update(map[string]interface{}{
+		"access_count":  r.Row.Field("access_count").Default(0).Add(1),
+		"last_accessed": t,
+	}).RunWrite(s.session)
+	if err != nil {
+		return err
+	}
+
+	if res.Replaced == 0 && res.Unchanged == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *rethinkStore) Notes() ([]*shipyard.Note, error) {
+	notes := []*shipyard.Note{}
+	if err := s.all(r.Table(tblNameNotes).OrderBy("id"), &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (s *rethinkStore) Note(id string) (*shipyard.Note, error) {
+	var note *shipyard.Note
+	if err := s.one(r.Table(tblNameNotes).Get(id), &note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+func (s *rethinkStore) SaveNote(note *shipyard.Note) error {
+	_, err := r.Table(tblNameNotes).Insert(note, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteNote(id string) error {
+	return s.delete(r.Table(tblNameNotes).Get(id))
+}
+
+func (s *rethinkStore) Freezes() ([]*shipyard.Freeze, error) {
+	freezes := []*shipyard.Freeze{}
+	if err := s.all(r.Table(tblNameFreezes).OrderBy("start"), &freezes); err != nil {
+		return nil, err
+	}
+	return freezes, nil
+}
+
+func (s *rethinkStore) Freeze(id string) (*shipyard.Freeze, error) {
+	var freeze *shipyard.Freeze
+	if err := s.one(r.Table(tblNameFreezes).Get(id), &freeze); err != nil {
+		return nil, err
+	}
+	return freeze, nil
+}
+
+func (s *rethinkStore) SaveFreeze(freeze *shipyard.Freeze) error {
+	_, err := r.Table(tblNameFreezes).Insert(freeze, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteFreeze(id string) error {
+	return s.delete(r.Table(tblNameFreezes).Get(id))
+}
+
+func (s *rethinkStore) ClientRules() ([]*shipyard.ClientRule, error) {
+	rules := []*shipyard.ClientRule{}
+	if err := s.all(r.Table(tblNameClientRules).OrderBy("route_prefix"), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *rethinkStore) ClientRule(id string) (*shipyard.ClientRule, error) {
+	var rule *shipyard.ClientRule
+	if err := s.one(r.Table(tblNameClientRules).Get(id), &rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *rethinkStore) SaveClientRule(rule *shipyard.ClientRule) error {
+	_, err := r.Table(tblNameClientRules).Insert(rule, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteClientRule(id string) error {
+	return s.delete(r.Table(tblNameClientRules).Get(id))
+}
+
+func (s *rethinkStore) Jobs() ([]*shipyard.Job, error) {
+	jobs := []*shipyard.Job{}
+	if err := s.all(r.Table(tblNameJobs).OrderBy("name"), &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *rethinkStore) Job(id string) (*shipyard.Job, error) {
+	var job *shipyard.Job
+	if err := s.one(r.Table(tblNameJobs).Get(id), &job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *rethinkStore) SaveJob(job *shipyard.Job) error {
+	_, err := r.Table(tblNameJobs).Insert(job, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteJob(id string) error {
+	return s.delete(r.Table(tblNameJobs).Get(id))
+}
+
+func (s *rethinkStore) Stacks() ([]*shipyard.Stack, error) {
+	stacks := []*shipyard.Stack{}
+	if err := s.all(r.Table(tblNameStacks).OrderBy("id"), &stacks); err != nil {
+		return nil, err
+	}
+	return stacks, nil
+}
+
+func (s *rethinkStore) Stack(name string) (*shipyard.Stack, error) {
+	var stack *shipyard.Stack
+	if err := s.one(r.Table(tblNameStacks).Get(name), &stack); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+func (s *rethinkStore) SaveStack(stack *shipyard.Stack) error {
+	_, err := r.Table(tblNameStacks).Insert(stack, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteStack(name string) error {
+	return s.delete(r.Table(tblNameStacks).Get(name))
+}
+
+func (s *rethinkStore) Clusters() ([]*shipyard.Cluster, error) {
+	clusters := []*shipyard.Cluster{}
+	if err := s.all(r.Table(tblNameClusters).OrderBy("id"), &clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+func (s *rethinkStore) Cluster(name string) (*shipyard.Cluster, error) {
+	var cluster *shipyard.Cluster
+	if err := s.one(r.Table(tblNameClusters).Get(name), &cluster); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *rethinkStore) SaveCluster(cluster *shipyard.Cluster) error {
+	_, err := r.Table(tblNameClusters).Insert(cluster, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteCluster(name string) error {
+	return s.delete(r.Table(tblNameClusters).Get(name))
+}
+
+func (s *rethinkStore) ImageScan(id string) (*shipyard.ImageScan, error) {
+	var scan *shipyard.ImageScan
+	if err := s.one(r.Table(tblNameImageScans).Get(id), &scan); err != nil {
+		return nil, err
+	}
+	return scan, nil
+}
+
+func (s *rethinkStore) SaveImageScan(scan *shipyard.ImageScan) error {
+	_, err := r.Table(tblNameImageScans).Insert(scan, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) Deployments(stack string) ([]*shipyard.Deployment, error) {
+	deployments := []*shipyard.Deployment{}
+	if err := s.all(r.Table(tblNameDeployments).Filter(map[string]string{"stack": stack}).OrderBy(r.Desc("started_at")), &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+func (s *rethinkStore) SaveDeployment(d *shipyard.Deployment) error {
+	_, err := r.Table(tblNameDeployments).Insert(d, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) ManagedNodes() ([]*shipyard.ManagedNode, error) {
+	nodes := []*shipyard.ManagedNode{}
+	if err := s.all(r.Table(tblNameNodes).OrderBy("id"), &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+func (s *rethinkStore) ManagedNode(name string) (*shipyard.ManagedNode, error) {
+	var node *shipyard.ManagedNode
+	if err := s.one(r.Table(tblNameNodes).Get(name), &node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (s *rethinkStore) SaveManagedNode(node *shipyard.ManagedNode) error {
+	_, err := r.Table(tblNameNodes).Insert(node, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteManagedNode(name string) error {
+	return s.delete(r.Table(tblNameNodes).Get(name))
+}
+
+func (s *rethinkStore) RegistryMirror() (*shipyard.RegistryMirror, error) {
+	var mirror *shipyard.RegistryMirror
+	if err := s.one(r.Table(tblNameConfig).Get(shipyard.RegistryMirrorID), &mirror); err != nil {
+		return nil, err
+	}
+	return mirror, nil
+}
+
+func (s *rethinkStore) SaveRegistryMirror(mirror *shipyard.RegistryMirror) error {
+	mirror.ID = shipyard.RegistryMirrorID
+
+	_, err := r.Table(tblNameConfig).Insert(mirror, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteRegistryMirror() error {
+	return s.delete(r.Table(tblNameConfig).Get(shipyard.RegistryMirrorID))
+}
+
+func (s *rethinkStore) ClusterEndpoint() (*shipyard.ClusterEndpoint, error) {
+	var endpoint *shipyard.ClusterEndpoint
+	if err := s.one(r.Table(tblNameConfig).Get(shipyard.ClusterEndpointID), &endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *rethinkStore) SaveClusterEndpoint(endpoint *shipyard.ClusterEndpoint) error {
+	endpoint.ID = shipyard.ClusterEndpointID
+
+	_, err := r.Table(tblNameConfig).Insert(endpoint, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) ContainerDefaults() (*shipyard.ContainerDefaults, error) {
+	var defaults *shipyard.ContainerDefaults
+	if err := s.one(r.Table(tblNameConfig).Get(shipyard.ContainerDefaultsID), &defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+func (s *rethinkStore) SaveContainerDefaults(defaults *shipyard.ContainerDefaults) error {
+	defaults.ID = shipyard.ContainerDefaultsID
+
+	_, err := r.Table(tblNameConfig).Insert(defaults, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteContainerDefaults() error {
+	return s.delete(r.Table(tblNameConfig).Get(shipyard.ContainerDefaultsID))
+}
+
+func (s *rethinkStore) Templates() ([]*shipyard.Template, error) {
+	templates := []*shipyard.Template{}
+	if err := s.all(r.Table(tblNameTemplates).OrderBy("id"), &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (s *rethinkStore) Template(name string) (*shipyard.Template, error) {
+	var template *shipyard.Template
+	if err := s.one(r.Table(tblNameTemplates).Get(name), &template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (s *rethinkStore) SaveTemplate(template *shipyard.Template) error {
+	_, err := r.Table(tblNameTemplates).Insert(template, r.InsertOpts{Conflict: "replace"}).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) DeleteTemplate(name string) error {
+	return s.delete(r.Table(tblNameTemplates).Get(name))
+}
+
+func (s *rethinkStore) SaveAuditEntry(entry *shipyard.AuditEntry) error {
+	_, err := r.Table(tblNameAudit).Insert(entry).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) AuditEntries(query *AuditQuery) ([]*shipyard.AuditEntry, error) {
+	t := r.Table(tblNameAudit)
+
+	if query.Username != "" {
+		t = t.Filter(map[string]string{"username": query.Username})
+	}
+	if !query.After.IsZero() {
+		t = t.Filter(r.Row.Field("time").Gt(query.After))
+	}
+	if !query.Before.IsZero() {
+		t = t.Filter(r.Row.Field("time").Lt(query.Before))
+	}
+
+	t = t.OrderBy(r.Desc("time"))
+	if query.Limit > 0 {
+		t = t.Limit(query.Limit)
+	}
+
+	entries := []*shipyard.AuditEntry{}
+	if err := s.all(t, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *rethinkStore) AnonymizeAuditEntries(username, pseudonym string) error {
+	if _, err := r.Table(tblNameAudit).Filter(map[string]string{"username": username}).Update(map[string]interface{}{
+		"username":    pseudonym,
+		"remote_addr": "",
+	}).RunWrite(s.session); err != nil {
+		return err
+	}
+
+	pattern := regexp.QuoteMeta(username)
+	entries := []*shipyard.AuditEntry{}
+	if err := s.all(r.Table(tblNameAudit).Filter(r.Row.Field("route").Match(pattern).Or(r.Row.Field("payload").Default("").Match(pattern))), &entries); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		route := ReplaceUsername(entry.Route, username, pseudonym)
+		payload := ReplaceUsername(entry.Payload, username, pseudonym)
+		if route == entry.Route && payload == entry.Payload {
+			continue
+		}
+
+		if _, err := r.Table(tblNameAudit).Get(entry.ID).Update(map[string]interface{}{
+			"route":   route,
+			"payload": payload,
+		}).RunWrite(s.session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *rethinkStore) AuditRecords() ([]*shipyard.AuditRecord, error) {
+	records := []*shipyard.AuditRecord{}
+	if err := s.all(r.Table(tblNameAuditLog).OrderBy(r.OrderByOpts{Index: r.Asc("id")}), &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *rethinkStore) LastAuditRecord() (*shipyard.AuditRecord, error) {
+	var rec *shipyard.AuditRecord
+	if err := s.one(r.Table(tblNameAuditLog).OrderBy(r.OrderByOpts{Index: r.Desc("id")}).Limit(1), &rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// AppendAuditRecord inserts without replacing; records are keyed by
+// sequence so inserting an existing sequence fails
+func (s *rethinkStore) AppendAuditRecord(rec *shipyard.AuditRecord) error {
+	res, err := r.Table(tblNameAuditLog).Insert(rec).RunWrite(s.session)
+	if res.Errors > 0 {
+		return ErrAuditRecordExists
+	}
+	return err
+}
+
+func (s *rethinkStore) RedactAuditRecord(rec *shipyard.AuditRecord) error {
+	res, err := r.Table(tblNameAuditLog).Get(rec.Sequence).Update(map[string]interface{}{
+		"data":     rec.Data,
+		"redacted": true,
+	}).RunWrite(s.session)
+	if err != nil {
+		return err
+	}
+
+	if res.Skipped > 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *rethinkStore) SaveEvent(event *shipyard.Event) error {
+	_, err := r.Table(tblNameEvents).Insert(event).RunWrite(s.session)
+	return err
+}
+
+func (s *rethinkStore) Events(query *EventQuery) ([]*shipyard.Event, error) {
+	t := r.Table(tblNameEvents)
+
+	filter := map[string]string{}
+	if query.Type != "" {
+		filter["Type"] = query.Type
+	}
+	if query.Username != "" {
+		filter["Username"] = query.Username
+	}
+	if len(filter) > 0 {
+		t = t.Filter(filter)
+	}
+
+	if query.ContainerId != "" {
+		t = t.Filter(r.Row.Field("ContainerInfo").Field("Id").Default("").Match("^" + regexp.QuoteMeta(query.ContainerId)))
+	}
+
+	if !query.After.IsZero() {
+		t = t.Filter(r.Row.Field("Time").Gt(query.After))
+	}
+	if !query.Before.IsZero() {
+		t = t.Filter(r.Row.Field("Time").Lt(query.Before))
+	}
+
+	field := "Time"
+	switch query.SortBy {
+	case EventSortType:
+		field = "Type"
+	case EventSortUsername:
+		field = "Username"
+	}
+
+	if query.Ascending {
+		t = t.OrderBy(r.Asc(field))
+	} else {
+		t = t.OrderBy(r.Desc(field))
+	}
+
+	if query.Offset > 0 {
+		t = t.Skip(query.Offset)
+	}
+	if query.Limit > 0 {
+		t = t.Limit(query.Limit)
+	}
+
+	events := []*shipyard.Event{}
+	if err := s.all(t, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *rethinkStore) AnonymizeEvents(username, pseudonym string) error {
+	if _, err := r.Table(tblNameEvents).Filter(map[string]string{"Username": username}).Update(map[string]interface{}{
+		"Username":   pseudonym,
+		"RemoteAddr": "",
+		"Country":    "",
+		"ASN":        "",
+	}).RunWrite(s.session); err != nil {
+		return err
+	}
+
+	// events have no id field so the generated id is read along with
+	// the message
+	rows := []map[string]interface{}{}
+	if err := s.all(r.Table(tblNameEvents).Filter(r.Row.Field("Message").Default("").Match(regexp.QuoteMeta(username))).Pluck("id", "Message"), &rows); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		message, _ := row["Message"].(string)
+		scrubbed := ReplaceUsername(message, username, pseudonym)
+		if scrubbed == message {
+			continue
+		}
+
+		if _, err := r.Table(tblNameEvents).Get(row["id"]).Update(map[string]interface{}{
+			"Message": scrubbed,
+		}).RunWrite(s.session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *rethinkStore) PurgeEvents() error {
+	_, err := r.Table(tblNameEvents).Delete().RunWrite(s.session)
+	return err
+}
+
+// WatchEvents uses a changefeed so events written by any controller are
+// returned; the channel is closed when the feed ends
+func (s *rethinkStore) WatchEvents(done <-chan struct{}) (<-chan *shipyard.Event, error) {
+	cursor, err := r.Table(tblNameEvents).Changes().Run(s.session)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *shipyard.Event)
+
+	go func() {
+		<-done
+		cursor.Close()
+	}()
+
+	go func() {
+		defer close(events)
+
+		var change eventChange
+		for cursor.Next(&change) {
+			// deletes (i.e. purging events) have no new value
+			if change.NewVal == nil {
+				continue
+			}
+
+			select {
+			case events <- change.NewVal:
+			case <-done:
+				return
+			}
+
+			change = eventChange{}
+		}
+
+		select {
+		case <-done:
+		default:
+			if err := cursor.Err(); err != nil {
+				log.Warnf("event stream ended: %s", err)
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Check verifies that every table exists and that all of its replicas are
+// ready
+func (s *rethinkStore) Check() ([]string, error) {
+	existing := []string{}
+	if err := s.all(r.TableList(), &existing); err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	for _, t := range existing {
+		found[t] = true
+	}
+
+	problems := []string{}
+	for _, t := range tables {
+		if !found[t] {
+			problems = append(problems, fmt.Sprintf("table %s is missing", t))
+			continue
+		}
+
+		var status struct {
+			Status struct {
+				AllReplicasReady bool `gorethink:"all_replicas_ready"`
+			} `gorethink:"status"`
+		}
+		if err := s.one(r.Table(t).Status(), &status); err != nil {
+			return nil, err
+		}
+
+		if !status.Status.AllReplicasReady {
+			problems = append(problems, fmt.Sprintf("table %s has replicas which are not ready", t))
+		}
+	}
+
+	return problems, nil
+}
+
+// Size is not reported; rethinkdb reclaims the space of its tables itself
+func (s *rethinkStore) Size() (*Size, error) {
+	return &Size{}, nil
+}
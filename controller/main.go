@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -24,6 +26,16 @@ func main() {
 		if c.GlobalBool("debug") {
 			log.SetLevel(log.DebugLevel)
 		}
+
+		switch c.GlobalString("log-format") {
+		case "text":
+		case "json":
+			// one object per line for log shippers (i.e. logstash)
+			log.SetFormatter(&log.JSONFormatter{})
+		default:
+			return fmt.Errorf("unknown log format %q; use text or json", c.GlobalString("log-format"))
+		}
+
 		return nil
 	}
 	app.Commands = []cli.Command{
@@ -33,107 +45,358 @@ func main() {
 			Action: commands.CmdServer,
 			Flags: []cli.Flag{
 				cli.StringFlag{
-					Name:  "listen, l",
-					Usage: "listen address",
-					Value: ":8080",
+					Name:   "config, c",
+					Usage:  "TOML config file; options are named as the flags. Flags override SHIPYARD_* environment variables which override the file",
+					EnvVar: "SHIPYARD_CONFIG",
+				},
+				cli.BoolFlag{
+					Name:   "validate-config",
+					Usage:  "validate the config file and exit",
+					EnvVar: "SHIPYARD_VALIDATE_CONFIG",
+				},
+				cli.StringFlag{
+					Name:   "listen, l",
+					Usage:  "listen address",
+					Value:  ":8080",
+					EnvVar: "SHIPYARD_LISTEN",
+				},
+				cli.StringFlag{
+					Name:   "datastore",
+					Usage:  "datastore backend (rethinkdb, bolt); alerts, notifications, exec policies, break-glass access, the audit chain and controller status require rethinkdb",
+					Value:  "rethinkdb",
+					EnvVar: "SHIPYARD_DATASTORE",
+				},
+				cli.StringFlag{
+					Name:   "bolt-path",
+					Usage:  "path of the bolt datastore file",
+					Value:  "/data/shipyard.db",
+					EnvVar: "SHIPYARD_BOLT_PATH",
 				},
 				cli.StringFlag{
-					Name:  "rethinkdb-addr",
-					Usage: "RethinkDB address",
-					Value: "rethinkdb:28015",
+					Name:   "rethinkdb-addr",
+					Usage:  "RethinkDB address",
+					Value:  "rethinkdb:28015",
+					EnvVar: "SHIPYARD_RETHINKDB_ADDR,SHIPYARD_STORE_URL",
 				},
 				cli.StringFlag{
-					Name:  "rethinkdb-auth-key",
-					Usage: "RethinkDB auth key",
-					Value: "",
+					Name:   "rethinkdb-auth-key",
+					Usage:  "RethinkDB auth key",
+					Value:  "",
+					EnvVar: "SHIPYARD_RETHINKDB_AUTH_KEY",
 				},
 				cli.StringFlag{
-					Name:  "rethinkdb-database",
-					Usage: "RethinkDB database name",
-					Value: "shipyard",
+					Name:   "rethinkdb-database",
+					Usage:  "RethinkDB database name",
+					Value:  "shipyard",
+					EnvVar: "SHIPYARD_RETHINKDB_DATABASE",
+				},
+				cli.BoolFlag{
+					Name:   "disable-usage-info",
+					Usage:  "disable anonymous usage reporting",
+					EnvVar: "SHIPYARD_DISABLE_USAGE_INFO",
 				},
 				cli.BoolFlag{
-					Name:  "disable-usage-info",
-					Usage: "disable anonymous usage reporting",
+					Name:   "offline",
+					Usage:  "air-gapped mode; disable usage reporting, Docker Hub search and Docker Hub webhooks",
+					EnvVar: "SHIPYARD_OFFLINE",
 				},
 				cli.StringFlag{
 					Name:   "docker, d",
 					Value:  "tcp://127.0.0.1:2375",
 					Usage:  "docker swarm addr",
-					EnvVar: "DOCKER_HOST",
+					EnvVar: "SHIPYARD_DOCKER,DOCKER_HOST",
+				},
+				cli.StringFlag{
+					Name:   "tls-ca-cert",
+					Value:  "",
+					Usage:  "tls ca certificate",
+					EnvVar: "SHIPYARD_DOCKER_TLS_CA_CERT",
 				},
 				cli.StringFlag{
-					Name:  "tls-ca-cert",
-					Value: "",
-					Usage: "tls ca certificate",
+					Name:   "tls-cert",
+					Value:  "",
+					Usage:  "tls certificate",
+					EnvVar: "SHIPYARD_DOCKER_TLS_CERT",
 				},
 				cli.StringFlag{
-					Name:  "tls-cert",
-					Value: "",
-					Usage: "tls certificate",
+					Name:   "tls-key",
+					Value:  "",
+					Usage:  "tls key",
+					EnvVar: "SHIPYARD_DOCKER_TLS_KEY",
 				},
 				cli.StringFlag{
-					Name:  "tls-key",
-					Value: "",
-					Usage: "tls key",
+					Name:   "shipyard-tls-ca-cert",
+					Usage:  "Shipyard TLS CA Cert",
+					Value:  "",
+					EnvVar: "SHIPYARD_TLS_CA_CERT",
 				},
 				cli.StringFlag{
-					Name:  "shipyard-tls-ca-cert",
-					Usage: "Shipyard TLS CA Cert",
-					Value: "",
+					Name:   "shipyard-tls-cert",
+					Usage:  "Shipyard TLS Cert",
+					Value:  "",
+					EnvVar: "SHIPYARD_TLS_CERT",
 				},
 				cli.StringFlag{
-					Name:  "shipyard-tls-cert",
-					Usage: "Shipyard TLS Cert",
-					Value: "",
+					Name:   "shipyard-tls-key",
+					Usage:  "Shipyard TLS Key",
+					Value:  "",
+					EnvVar: "SHIPYARD_TLS_KEY",
 				},
 				cli.StringFlag{
-					Name:  "shipyard-tls-key",
-					Usage: "Shipyard TLS Key",
-					Value: "",
+					Name:   "shipyard-tls-client-ca",
+					Usage:  "require client certificates signed by this ca (mutual tls)",
+					EnvVar: "SHIPYARD_TLS_CLIENT_CA",
+				},
+				cli.StringFlag{
+					Name:   "http-redirect-listen",
+					Usage:  "listen address for plain http redirected to https (i.e. :80); requires the shipyard tls cert and key",
+					EnvVar: "SHIPYARD_HTTP_REDIRECT_LISTEN",
 				},
 				cli.BoolFlag{
-					Name:  "allow-insecure",
-					Usage: "enable insecure tls communication",
+					Name:   "allow-insecure",
+					Usage:  "enable insecure tls communication",
+					EnvVar: "SHIPYARD_ALLOW_INSECURE",
+				},
+				cli.StringSliceFlag{
+					Name:   "cors-origin",
+					Usage:  "origin allowed to call the apis from a browser (i.e. https://ops.example.com); * allows every origin",
+					Value:  &cli.StringSlice{},
+					EnvVar: "SHIPYARD_CORS_ORIGINS",
 				},
 				cli.BoolFlag{
-					Name:  "enable-cors",
-					Usage: "enable cors with swarm",
+					Name:   "enable-cors",
+					Usage:  "deprecated: allow every origin; use --cors-origin",
+					EnvVar: "SHIPYARD_ENABLE_CORS",
 				},
 				cli.StringFlag{
-					Name:  "ldap-server",
-					Usage: "LDAP server address",
+					Name:   "ldap-server",
+					Usage:  "LDAP server address",
+					EnvVar: "SHIPYARD_LDAP_SERVER",
 				},
 				cli.IntFlag{
-					Name:  "ldap-port",
-					Usage: "LDAP server port",
-					Value: 389,
+					Name:   "ldap-port",
+					Usage:  "LDAP server port",
+					Value:  389,
+					EnvVar: "SHIPYARD_LDAP_PORT",
+				},
+				cli.StringFlag{
+					Name:   "ldap-base-dn",
+					Usage:  "LDAP server base DN",
+					EnvVar: "SHIPYARD_LDAP_BASE_DN",
+				},
+				cli.BoolFlag{
+					Name:   "ldap-autocreate-users",
+					Usage:  "Automatically create a corresponding Shipyard account if missing upon authenticating",
+					EnvVar: "SHIPYARD_LDAP_AUTOCREATE_USERS",
+				},
+				cli.StringFlag{
+					Name:   "ldap-default-access-level",
+					Usage:  "Default access level for auto-created accounts (default: container read-only)",
+					Value:  "containers:ro",
+					EnvVar: "SHIPYARD_LDAP_DEFAULT_ACCESS_LEVEL",
 				},
 				cli.StringFlag{
-					Name:  "ldap-base-dn",
-					Usage: "LDAP server base DN",
+					Name:   "admin-username",
+					Usage:  "username of the admin account created on the first start",
+					Value:  "admin",
+					EnvVar: "SHIPYARD_ADMIN_USERNAME",
+				},
+				cli.StringFlag{
+					Name:   "admin-password",
+					Usage:  "password of the admin account created on the first start; the default has to be changed on first login",
+					Value:  "shipyard",
+					EnvVar: "SHIPYARD_ADMIN_PASSWORD",
 				},
 				cli.BoolFlag{
-					Name:  "ldap-autocreate-users",
-					Usage: "Automatically create a corresponding Shipyard account if missing upon authenticating",
+					Name:   "admin-password-random",
+					Usage:  "generate the admin password and log it once; it has to be changed on first login",
+					EnvVar: "SHIPYARD_ADMIN_PASSWORD_RANDOM",
+				},
+				cli.StringFlag{
+					Name:   "oidc-issuer",
+					Usage:  "OpenID Connect issuer url (i.e. https://keycloak/realms/ops); enables /auth/oidc/login",
+					EnvVar: "SHIPYARD_OIDC_ISSUER",
+				},
+				cli.StringFlag{
+					Name:   "oidc-client-id",
+					Usage:  "OpenID Connect client id",
+					EnvVar: "SHIPYARD_OIDC_CLIENT_ID",
+				},
+				cli.StringFlag{
+					Name:   "oidc-client-secret",
+					Usage:  "OpenID Connect client secret",
+					EnvVar: "SHIPYARD_OIDC_CLIENT_SECRET",
+				},
+				cli.StringFlag{
+					Name:   "oidc-redirect-url",
+					Usage:  "callback url registered with the provider (i.e. https://shipyard.example.com/auth/oidc/callback)",
+					EnvVar: "SHIPYARD_OIDC_REDIRECT_URL",
+				},
+				cli.StringSliceFlag{
+					Name:   "oidc-scope",
+					Usage:  "scope requested besides openid (default: profile and email)",
+					Value:  &cli.StringSlice{},
+					EnvVar: "SHIPYARD_OIDC_SCOPE",
 				},
 				cli.StringFlag{
-					Name:  "ldap-default-access-level",
-					Usage: "Default access level for auto-created accounts (default: container read-only)",
-					Value: "containers:ro",
+					Name:   "oidc-username-claim",
+					Usage:  "claim of the id token used as username; email and sub are used without it",
+					Value:  "preferred_username",
+					EnvVar: "SHIPYARD_OIDC_USERNAME_CLAIM",
+				},
+				cli.StringFlag{
+					Name:   "oidc-groups-claim",
+					Usage:  "claim of the id token listing the groups of the user",
+					Value:  "groups",
+					EnvVar: "SHIPYARD_OIDC_GROUPS_CLAIM",
 				},
 				cli.StringSliceFlag{
-					Name:  "auth-whitelist-cidr",
-					Usage: "whitelist CIDR to bypass auth",
-					Value: &cli.StringSlice{},
+					Name:   "oidc-group-role",
+					Usage:  "map an OpenID Connect group to a role (group=role)",
+					Value:  &cli.StringSlice{},
+					EnvVar: "SHIPYARD_OIDC_GROUP_ROLE",
+				},
+				cli.StringFlag{
+					Name:   "oidc-default-role",
+					Usage:  "role of accounts created on the first OpenID Connect login without a mapped group",
+					Value:  "containers:ro",
+					EnvVar: "SHIPYARD_OIDC_DEFAULT_ROLE",
+				},
+				cli.StringFlag{
+					Name:   "geoip-db",
+					Usage:  "path to a GeoIP CSV database (network,country,asn) used to enrich login and audit records",
+					EnvVar: "SHIPYARD_GEOIP_DB",
+				},
+				cli.StringFlag{
+					Name:   "audit-syslog",
+					Usage:  "stream audit records to a syslog endpoint (udp://host:514, tcp://host:601 or tls://host:6514)",
+					EnvVar: "SHIPYARD_AUDIT_SYSLOG",
+				},
+				cli.BoolFlag{
+					Name:   "audit-hash-chain",
+					Usage:  "keep an append-only, hash chained copy of all events for tamper detection",
+					EnvVar: "SHIPYARD_AUDIT_HASH_CHAIN",
+				},
+				cli.DurationFlag{
+					Name:   "exec-max-duration",
+					Usage:  "maximum duration of exec and attach sessions (i.e. 8h); 0 for no limit",
+					EnvVar: "SHIPYARD_EXEC_MAX_DURATION",
+				},
+				cli.DurationFlag{
+					Name:   "exec-idle-timeout",
+					Usage:  "disconnect exec and attach sessions without input for this long (i.e. 30m); 0 for no limit",
+					EnvVar: "SHIPYARD_EXEC_IDLE_TIMEOUT",
+				},
+				cli.BoolFlag{
+					Name:   "exec-record",
+					Usage:  "record the terminal output of every exec session; sessions can also ask for it with ?record=true",
+					EnvVar: "SHIPYARD_EXEC_RECORD",
+				},
+				cli.StringFlag{
+					Name:   "password-hash",
+					Usage:  "password hash algorithm for local accounts (bcrypt, scrypt, argon2id); existing passwords are rehashed on login",
+					Value:  "bcrypt",
+					EnvVar: "SHIPYARD_PASSWORD_HASH",
+				},
+				cli.IntFlag{
+					Name:   "password-hash-bcrypt-cost",
+					Usage:  "bcrypt cost for password hashes",
+					Value:  10,
+					EnvVar: "SHIPYARD_PASSWORD_HASH_BCRYPT_COST",
+				},
+				cli.DurationFlag{
+					Name:   "auth-token-ttl",
+					Usage:  "how long auth tokens are valid (i.e. 12h); tokens can be renewed with /auth/refresh; 0 never expires",
+					EnvVar: "SHIPYARD_AUTH_TOKEN_TTL",
+				},
+				cli.IntFlag{
+					Name:   "login-rate-limit",
+					Usage:  "logins a source address or username may attempt per minute; 0 disables the limit",
+					Value:  10,
+					EnvVar: "SHIPYARD_LOGIN_RATE_LIMIT",
+				},
+				cli.IntFlag{
+					Name:   "lockout-threshold",
+					Usage:  "failed logins in a row that lock an account; 0 disables lockout",
+					Value:  10,
+					EnvVar: "SHIPYARD_LOCKOUT_THRESHOLD",
+				},
+				cli.DurationFlag{
+					Name:   "lockout-duration",
+					Usage:  "how long accounts stay locked after too many failed logins",
+					Value:  15 * time.Minute,
+					EnvVar: "SHIPYARD_LOCKOUT_DURATION",
+				},
+				cli.DurationFlag{
+					Name:   "response-cache-ttl",
+					Usage:  "cache image search and registry catalog responses for this long; 0 to disable",
+					Value:  10 * time.Second,
+					EnvVar: "SHIPYARD_RESPONSE_CACHE_TTL",
+				},
+				cli.DurationFlag{
+					Name:   "break-glass-timeout",
+					Usage:  "how long break-glass emergency access lasts",
+					Value:  time.Hour,
+					EnvVar: "SHIPYARD_BREAK_GLASS_TIMEOUT",
+				},
+				cli.StringFlag{
+					Name:   "api-sunset",
+					Usage:  "date (i.e. 2027-01-01) announced in the Sunset header of deprecated api routes",
+					Value:  "",
+					EnvVar: "SHIPYARD_API_SUNSET",
+				},
+				cli.DurationFlag{
+					Name:   "clock-skew-threshold",
+					Usage:  "report nodes whose clock is off from the controller by more than this",
+					Value:  2 * time.Second,
+					EnvVar: "SHIPYARD_CLOCK_SKEW_THRESHOLD",
+				},
+				cli.StringFlag{
+					Name:   "share-link-secret",
+					Usage:  "secret to sign share links with; set the same secret on every controller or links break on restart",
+					EnvVar: "SHIPYARD_SHARE_LINK_SECRET",
+				},
+				cli.StringFlag{
+					Name:   "swarm-discovery",
+					Usage:  "swarm discovery backend (i.e. etcd://discovery:4001) joined by nodes added through shipyard",
+					EnvVar: "SHIPYARD_SWARM_DISCOVERY",
+				},
+				cli.StringSliceFlag{
+					Name:   "debug-image",
+					Usage:  "image debug containers can be launched from (i.e. nicolaka/netshoot); the first is the default",
+					Value:  &cli.StringSlice{},
+					EnvVar: "SHIPYARD_DEBUG_IMAGE",
+				},
+				cli.StringFlag{
+					Name:   "clair-url",
+					Usage:  "url of a clair scanner (i.e. http://clair:6060) images of the registries are scanned with",
+					EnvVar: "SHIPYARD_CLAIR_URL",
+				},
+				cli.BoolFlag{
+					Name:   "preflight-strict",
+					Usage:  "refuse to start when a critical preflight check fails",
+					EnvVar: "SHIPYARD_PREFLIGHT_STRICT",
+				},
+				cli.StringSliceFlag{
+					Name:   "auth-whitelist-cidr",
+					Usage:  "whitelist CIDR to bypass auth",
+					Value:  &cli.StringSlice{},
+					EnvVar: "SHIPYARD_AUTH_WHITELIST_CIDR",
 				},
 			},
 		},
 	}
 	app.Flags = []cli.Flag{
 		cli.BoolFlag{
-			Name:  "debug, D",
-			Usage: "enable debug",
+			Name:   "debug, D",
+			Usage:  "enable debug",
+			EnvVar: "SHIPYARD_DEBUG",
+		},
+		cli.StringFlag{
+			Name:   "log-format",
+			Usage:  "log format (text, json)",
+			Value:  "text",
+			EnvVar: "SHIPYARD_LOG_FORMAT",
 		},
 	}
 
@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+// AccountImportResult reports the outcome of ImportAccounts
+type AccountImportResult struct {
+	Imported []string `json:"imported"`
+	Skipped  []string `json:"skipped"`
+	Errors   []string `json:"errors"`
+}
+
+// ImportAccounts creates accounts whose Password is an existing hash (i.e.
+// from htpasswd) so users keep their passwords; existing accounts are
+// skipped. Imported hashes are upgraded to the hash policy on login.
+func (m DefaultManager) ImportAccounts(accounts []*auth.Account) AccountImportResult {
+	result := AccountImportResult{
+		Imported: []string{},
+		Skipped:  []string{},
+		Errors:   []string{},
+	}
+
+	for _, account := range accounts {
+		if account.Username == "" {
+			result.Errors = append(result.Errors, "username is required")
+			continue
+		}
+
+		if !validEmail(account.Email) {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", account.Username, ErrInvalidEmail))
+			continue
+		}
+
+		if auth.HashAlgorithm(account.Password) == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: unsupported password hash", account.Username))
+			continue
+		}
+
+		if _, err := m.Account(account.Username); err != ErrAccountDoesNotExist {
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", account.Username, err))
+				continue
+			}
+
+			result.Skipped = append(result.Skipped, account.Username)
+			continue
+		}
+
+		acct := &auth.Account{
+			Username:  account.Username,
+			FirstName: account.FirstName,
+			LastName:  account.LastName,
+			Email:     account.Email,
+			Password:  account.Password,
+			Roles:     account.Roles,
+		}
+
+		if err := m.db.CreateAccount(acct); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", account.Username, err))
+			continue
+		}
+
+		m.logEvent("import-account", fmt.Sprintf("username=%s hash=%s", account.Username, auth.HashAlgorithm(account.Password)), []string{"security"})
+		result.Imported = append(result.Imported, account.Username)
+	}
+
+	return result
+}
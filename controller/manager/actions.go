@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"errors"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+)
+
+// Resources actions are listed for
+const (
+	ActionResourceContainer = "container"
+	ActionResourceNode      = "node"
+	ActionResourceStack     = "stack"
+)
+
+var (
+	ErrUnknownActionResource = errors.New("actions are listed for a container, node or stack")
+)
+
+// Action is a request the user can make on a resource; UIs render a
+// button for each one
+type Action struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// Actions returns the actions on the resource which apply to its current
+// state and which the roles of the account permit; service keys (an empty
+// username) are not restricted
+func (m DefaultManager) Actions(username, resource, id string) ([]*Action, error) {
+	var (
+		actions []*Action
+		labels  map[string]string
+	)
+
+	switch resource {
+	case ActionResourceContainer:
+		info, err := m.Container(id)
+		if err != nil {
+			return nil, err
+		}
+
+		actions = containerActions(info)
+		if info.Config != nil {
+			labels = info.Config.Labels
+		}
+	case ActionResourceNode:
+		node, err := m.Node(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if node == nil {
+			return nil, ErrNodeDoesNotExist
+		}
+
+		actions = nodeActions(node.Name, node.Drained)
+	case ActionResourceStack:
+		stack, err := m.db.Stack(id)
+		if err != nil {
+			return nil, notFound(err, ErrStackDoesNotExist)
+		}
+
+		actions = stackActions(stack.Name)
+	default:
+		return nil, ErrUnknownActionResource
+	}
+
+	if username == "" {
+		return actions, nil
+	}
+
+	acct, err := m.Account(username)
+	if err != nil {
+		return nil, err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	// containers outside the label scope of the account cannot be changed
+	if resource == ActionResourceContainer && !auth.ContainerScope(acct, acls).Matches(labels) {
+		return []*Action{}, nil
+	}
+
+	return permittedActions(acct, acls, actions), nil
+}
+
+// permittedActions returns the actions any role of the account allows
+func permittedActions(acct *auth.Account, acls []*auth.ACL, actions []*Action) []*Action {
+	permitted := []*Action{}
+	for _, action := range actions {
+		for _, acl := range acls {
+			if acct.HasRole(acl.RoleName) && acl.Allows(action.Path, action.Method) {
+				permitted = append(permitted, action)
+				break
+			}
+		}
+	}
+
+	return permitted
+}
+
+// containerActions returns the actions for the state of the container;
+// paused containers can only be unpaused and stopped ones started or
+// removed
+func containerActions(info *dockerclient.ContainerInfo) []*Action {
+	path := "/containers/" + info.Id
+	actions := []*Action{}
+
+	switch {
+	case info.State != nil && info.State.Paused:
+		actions = append(actions, &Action{"unpause", "POST", path + "/unpause"})
+	case info.State != nil && info.State.Running:
+		actions = append(actions,
+			&Action{"stop", "POST", path + "/stop"},
+			&Action{"restart", "POST", path + "/restart"},
+			&Action{"pause", "POST", path + "/pause"},
+			&Action{"exec", "POST", path + "/exec"},
+		)
+	default:
+		actions = append(actions,
+			&Action{"start", "POST", path + "/start"},
+			&Action{"remove", "DELETE", path},
+		)
+	}
+
+	return append(actions,
+		&Action{"logs", "GET", path + "/logs"},
+		&Action{"scale", "POST", "/api" + path + "/scale"},
+	)
+}
+
+// nodeActions returns the actions for a node; drained nodes can be
+// undrained and the others drained
+func nodeActions(name string, drained bool) []*Action {
+	path := "/api/nodes/" + name
+
+	drain := &Action{"drain", "POST", path + "/drain"}
+	if drained {
+		drain = &Action{"undrain", "DELETE", path + "/drain"}
+	}
+
+	return []*Action{
+		drain,
+		{"remove", "DELETE", path},
+	}
+}
+
+func stackActions(name string) []*Action {
+	path := "/api/stacks/" + name
+
+	return []*Action{
+		{"redeploy", "POST", path + "/redeploy"},
+		{"export", "GET", path + "/export"},
+		{"remove", "DELETE", path},
+	}
+}
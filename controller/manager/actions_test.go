@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func actionNames(actions []*Action) []string {
+	names := []string{}
+	for _, a := range actions {
+		names = append(names, a.Name)
+	}
+
+	return names
+}
+
+func TestContainerActions(t *testing.T) {
+	for _, c := range []struct {
+		state    *dockerclient.State
+		expected string
+	}{
+		{&dockerclient.State{Running: true}, "[stop restart pause exec logs scale]"},
+		{&dockerclient.State{Running: true, Paused: true}, "[unpause logs scale]"},
+		{&dockerclient.State{}, "[start remove logs scale]"},
+		{nil, "[start remove logs scale]"},
+	} {
+		actions := containerActions(&dockerclient.ContainerInfo{Id: "abc", State: c.state})
+		if names := fmt.Sprint(actionNames(actions)); names != c.expected {
+			t.Errorf("%+v: expected %s; received %s", c.state, c.expected, names)
+		}
+	}
+}
+
+func TestPermittedActions(t *testing.T) {
+	acls := []*auth.ACL{
+		{RoleName: "operator", Permissions: []string{auth.PermContainersRead, auth.PermContainersWrite}},
+		{RoleName: "shell", Permissions: []string{auth.PermContainersExec}},
+	}
+
+	actions := containerActions(&dockerclient.ContainerInfo{Id: "abc", State: &dockerclient.State{Running: true}})
+
+	permitted := permittedActions(&auth.Account{Roles: []string{"operator"}}, acls, actions)
+	if names := fmt.Sprint(actionNames(permitted)); names != "[stop restart pause logs scale]" {
+		t.Fatalf("expected the actions without exec; received %s", names)
+	}
+
+	permitted = permittedActions(&auth.Account{Roles: []string{"shell"}}, acls, actions)
+	if names := fmt.Sprint(actionNames(permitted)); names != "[exec]" {
+		t.Fatalf("expected only exec; received %s", names)
+	}
+
+	if permitted := permittedActions(&auth.Account{}, acls, nodeActions("node-1", true)); len(permitted) != 0 {
+		t.Fatalf("expected no actions without roles; received %v", actionNames(permitted))
+	}
+}
@@ -0,0 +1,314 @@
+package manager
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	tblNameAlerts      = "alerts"
+	alertCheckInterval = 1 * time.Minute
+	certExpiryWarning  = 30 * 24 * time.Hour
+	nodeStatusHealthy  = "Healthy"
+)
+
+var (
+	ErrAlertDoesNotExist = errors.New("alert does not exist")
+)
+
+// alertMonitor periodically checks the cluster for conditions that need
+// attention and resolves alerts whose condition has cleared
+func (m DefaultManager) alertMonitor() {
+	t := time.NewTicker(alertCheckInterval).C
+	for {
+		select {
+		case <-t:
+			if !m.isLeader() {
+				continue
+			}
+
+			if err := m.checkNodes(); err != nil {
+				log.Errorf("error checking nodes: %s", err)
+			}
+			if err := m.checkCrashLoops(); err != nil {
+				log.Errorf("error checking containers: %s", err)
+			}
+			if err := m.checkCertificates(time.Now()); err != nil {
+				log.Errorf("error checking certificates: %s", err)
+			}
+			if err := m.checkClockSkew(); err != nil {
+				log.Errorf("error checking node clocks: %s", err)
+			}
+		}
+	}
+}
+
+func (m DefaultManager) checkNodes() error {
+	nodes, err := m.Nodes()
+	if err != nil {
+		return err
+	}
+
+	down := map[string]bool{}
+	for _, n := range nodes {
+		if n.Status == "" || n.Status == nodeStatusHealthy {
+			continue
+		}
+
+		down[n.Name] = true
+		m.raiseAlert(&shipyard.Alert{
+			Type:     shipyard.AlertNodeDown,
+			Subject:  n.Name,
+			Severity: notification.SeverityCritical,
+			Message:  fmt.Sprintf("node %s (%s) is %s", n.Name, n.Addr, strings.ToLower(n.Status)),
+			Notes:    m.notesFor(shipyard.NoteNode, n.Name),
+		})
+	}
+
+	return m.resolveAlerts(shipyard.AlertNodeDown, down)
+}
+
+func (m DefaultManager) checkCrashLoops() error {
+	containers, err := m.DockerClient().ListContainers(true, false, "")
+	if err != nil {
+		return err
+	}
+
+	restarting := map[string]bool{}
+	for _, c := range containers {
+		if !strings.HasPrefix(c.Status, "Restarting") {
+			continue
+		}
+
+		name := c.Id
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		restarting[name] = true
+		m.raiseAlert(&shipyard.Alert{
+			Type:     shipyard.AlertCrashLoop,
+			Subject:  name,
+			Severity: notification.SeverityWarning,
+			Message:  fmt.Sprintf("container %s (%s) is crash looping: %s", name, c.Image, c.Status),
+			Notes:    m.containerNotes(name, c.Labels),
+		})
+	}
+
+	return m.resolveAlerts(shipyard.AlertCrashLoop, restarting)
+}
+
+func (m DefaultManager) checkCertificates(now time.Time) error {
+	expiring := map[string]bool{}
+	for _, path := range m.certificates {
+		expiry, err := certificateExpiry(path)
+		if err != nil {
+			log.Errorf("error reading certificate %s: %s", path, err)
+			continue
+		}
+
+		if expiry.Sub(now) > certExpiryWarning {
+			continue
+		}
+
+		expiring[path] = true
+		m.raiseAlert(&shipyard.Alert{
+			Type:     shipyard.AlertCertExpiring,
+			Subject:  path,
+			Severity: notification.SeverityWarning,
+			Message:  fmt.Sprintf("certificate %s expires %s", path, expiry.Format(time.RFC3339)),
+		})
+	}
+
+	return m.resolveAlerts(shipyard.AlertCertExpiring, expiring)
+}
+
+// certificateExpiry returns the expiry of the first certificate in the
+// pem encoded file
+func certificateExpiry(path string) (time.Time, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("no certificate found")
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return cert.NotAfter, nil
+	}
+}
+
+// unresolvedAlerts returns the active and acknowledged alerts of a type
+func (m DefaultManager) unresolvedAlerts(alertType string) ([]*shipyard.Alert, error) {
+	all, err := m.db.Alerts("")
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []*shipyard.Alert{}
+	for _, a := range all {
+		if a.Type == alertType && a.Status != shipyard.AlertStatusResolved {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts, nil
+}
+
+// raiseAlert adds the alert to the inbox unless there is already an
+// unresolved alert of the same type for the subject
+func (m DefaultManager) raiseAlert(alert *shipyard.Alert) {
+	alerts, err := m.unresolvedAlerts(alert.Type)
+	if err != nil {
+		log.Errorf("error loading alerts: %s", err)
+		return
+	}
+
+	for _, a := range alerts {
+		if a.Subject == alert.Subject {
+			return
+		}
+	}
+
+	alert.Status = shipyard.AlertStatusActive
+	alert.CreatedAt = time.Now()
+	alert.Comments = []*shipyard.AlertComment{}
+
+	if err := m.db.SaveAlert(alert); err != nil {
+		log.Errorf("error saving alert: %s", err)
+		return
+	}
+
+	// the notes of the subject go out with the notifications of the alert
+	evt := &shipyard.Event{
+		Type:    "alert",
+		Time:    alert.CreatedAt,
+		Message: alert.Message,
+		Tags:    []string{"alert", alert.Type, alert.Severity},
+		Notes:   alert.Notes,
+	}
+
+	if err := m.SaveEvent(evt); err != nil {
+		log.Errorf("error saving alert event: %s", err)
+	}
+}
+
+// resolveAlerts resolves the unresolved alerts of a type whose subject no
+// longer has the condition
+func (m DefaultManager) resolveAlerts(alertType string, current map[string]bool) error {
+	alerts, err := m.unresolvedAlerts(alertType)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, a := range alerts {
+		if current[a.Subject] {
+			continue
+		}
+
+		if err := m.db.UpdateAlert(a.ID, func(alert *shipyard.Alert) error {
+			alert.Status = shipyard.AlertStatusResolved
+			alert.ResolvedAt = &now
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		m.logEvent("alert-resolved", a.Message, []string{"alert", a.Type})
+	}
+
+	return nil
+}
+
+// Alerts returns the alerts with the status; an empty status returns the
+// active alerts
+func (m DefaultManager) Alerts(status string) ([]*shipyard.Alert, error) {
+	if status == "" {
+		status = shipyard.AlertStatusActive
+	}
+
+	return m.db.Alerts(status)
+}
+
+func (m DefaultManager) Alert(id string) (*shipyard.Alert, error) {
+	alert, err := m.db.Alert(id)
+	if err != nil {
+		return nil, notFound(err, ErrAlertDoesNotExist)
+	}
+	return alert, nil
+}
+
+func (m DefaultManager) updateAlert(id string, fn func(alert *shipyard.Alert) error) error {
+	return notFound(m.db.UpdateAlert(id, fn), ErrAlertDoesNotExist)
+}
+
+func (m DefaultManager) AcknowledgeAlert(id, username string) error {
+	acknowledged := false
+	if err := m.updateAlert(id, func(alert *shipyard.Alert) error {
+		// resolved alerts stay resolved
+		if alert.Status != shipyard.AlertStatusActive {
+			return nil
+		}
+
+		now := time.Now()
+		alert.Status = shipyard.AlertStatusAcknowledged
+		alert.AcknowledgedBy = username
+		alert.AcknowledgedAt = &now
+		acknowledged = true
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if !acknowledged {
+		return nil
+	}
+
+	m.logEvent("acknowledge-alert", fmt.Sprintf("id=%s username=%s", id, username), []string{"alert"})
+
+	return nil
+}
+
+func (m DefaultManager) AssignAlert(id, username string) error {
+	if err := m.updateAlert(id, func(alert *shipyard.Alert) error {
+		alert.AssignedTo = username
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.logEvent("assign-alert", fmt.Sprintf("id=%s assigned_to=%s", id, username), []string{"alert"})
+
+	return nil
+}
+
+func (m DefaultManager) AddAlertComment(id string, comment *shipyard.AlertComment) error {
+	comment.Time = time.Now()
+
+	return m.updateAlert(id, func(alert *shipyard.Alert) error {
+		alert.Comments = append(alert.Comments, comment)
+		return nil
+	})
+}
@@ -0,0 +1,192 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	anomalyCheckInterval   = 1 * time.Minute
+	anomalyDeleteWindow    = 5 * time.Minute
+	anomalyDeleteThreshold = 10
+	anomalyLoginHistory    = 20
+)
+
+// anomalyDetector periodically inspects new events for unusual activity
+func (m DefaultManager) anomalyDetector() {
+	since := time.Now()
+	t := time.NewTicker(anomalyCheckInterval).C
+	for {
+		select {
+		case <-t:
+			now := time.Now()
+			if m.isLeader() {
+				if err := m.detectAnomalies(since, now); err != nil {
+					log.Errorf("error detecting anomalies: %s", err)
+				}
+			}
+			since = now
+		}
+	}
+}
+
+func (m DefaultManager) eventsBetween(start, end time.Time) ([]*shipyard.Event, error) {
+	return m.db.Events(&datastore.EventQuery{
+		After: start,
+		// include events at the end
+		Before:    end.Add(time.Nanosecond),
+		Ascending: true,
+	})
+}
+
+func (m DefaultManager) detectAnomalies(since, now time.Time) error {
+	events, err := m.eventsBetween(now.Add(-anomalyDeleteWindow), now)
+	if err != nil {
+		return err
+	}
+
+	// mass container deletion; only report users with new deletes since
+	// the last check so a burst is not reported on every tick
+	deletes := countContainerDeletes(events)
+	for _, evt := range events {
+		if evt.Time.After(since) && isContainerDelete(evt) {
+			if n := deletes[evt.Username]; n >= anomalyDeleteThreshold {
+				m.logAnomaly(evt.Username, "", fmt.Sprintf("user deleted %d containers in %s", n, anomalyDeleteWindow))
+				delete(deletes, evt.Username)
+			}
+		}
+	}
+
+	// logins from new locations
+	for _, evt := range events {
+		if evt.Type != "login" || !evt.Time.After(since) || evt.RemoteAddr == "" {
+			continue
+		}
+
+		previous, err := m.db.Events(&datastore.EventQuery{
+			Type:     "login",
+			Username: evt.Username,
+			Before:   evt.Time,
+			Limit:    anomalyLoginHistory,
+		})
+		if err != nil {
+			return err
+		}
+
+		addrs := []string{}
+		countries := []string{}
+		for _, p := range previous {
+			if p.RemoteAddr != "" {
+				addrs = append(addrs, p.RemoteAddr)
+			}
+			if p.Country != "" {
+				countries = append(countries, p.Country)
+			}
+		}
+
+		switch {
+		case evt.Country != "" && isNewCountry(evt.Country, countries):
+			m.logAnomaly(evt.Username, evt.RemoteAddr, fmt.Sprintf("login from new country: country=%s addr=%s", evt.Country, evt.RemoteAddr))
+		case isNewSubnet(evt.RemoteAddr, addrs):
+			m.logAnomaly(evt.Username, evt.RemoteAddr, fmt.Sprintf("login from new network: addr=%s", evt.RemoteAddr))
+		}
+	}
+
+	return nil
+}
+
+func (m DefaultManager) logAnomaly(username, remoteAddr, message string) {
+	log.Warnf("anomaly detected: username=%s %s", username, message)
+
+	evt := &shipyard.Event{
+		Type:       "anomaly",
+		Time:       time.Now(),
+		Username:   username,
+		RemoteAddr: remoteAddr,
+		Message:    message,
+		Tags:       []string{"security", "anomaly"},
+	}
+
+	if err := m.SaveEvent(evt); err != nil {
+		log.Errorf("error logging anomaly: %s", err)
+	}
+}
+
+func isContainerDelete(evt *shipyard.Event) bool {
+	if evt.Type != "api" || !strings.Contains(evt.Message, "/containers/") {
+		return false
+	}
+
+	for _, t := range evt.Tags {
+		if t == "delete" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countContainerDeletes returns the number of container deletions per user
+func countContainerDeletes(events []*shipyard.Event) map[string]int {
+	counts := map[string]int{}
+	for _, evt := range events {
+		if isContainerDelete(evt) {
+			counts[evt.Username]++
+		}
+	}
+
+	return counts
+}
+
+// subnet returns the /24 (ipv4) or /64 (ipv6) network of the address
+func subnet(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
+
+// isNewCountry reports whether country is not among the known countries;
+// with nothing known there is no baseline so it is not new
+func isNewCountry(country string, known []string) bool {
+	if len(known) == 0 {
+		return false
+	}
+
+	for _, k := range known {
+		if k == country {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isNewSubnet reports whether addr is outside the networks of all known
+// addresses; with nothing known there is no baseline so it is not new
+func isNewSubnet(addr string, known []string) bool {
+	if len(known) == 0 {
+		return false
+	}
+
+	s := subnet(addr)
+	for _, k := range known {
+		if subnet(k) == s {
+			return false
+		}
+	}
+
+	return true
+}
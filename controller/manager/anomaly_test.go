@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestIsNewSubnet(t *testing.T) {
+	known := []string{"10.0.1.15", "192.168.0.4"}
+
+	if isNewSubnet("10.0.1.200", known) {
+		t.Fatalf("expected 10.0.1.200 to be a known network")
+	}
+
+	if !isNewSubnet("10.0.2.15", known) {
+		t.Fatalf("expected 10.0.2.15 to be a new network")
+	}
+
+	if isNewSubnet("10.0.2.15", nil) {
+		t.Fatalf("expected no anomaly without a baseline")
+	}
+}
+
+func TestCountContainerDeletes(t *testing.T) {
+	events := []*shipyard.Event{
+		{
+			Type:     "api",
+			Username: "admin",
+			Message:  "/containers/abcdef",
+			Tags:     []string{"api", "containers", "delete"},
+		},
+		{
+			Type:     "api",
+			Username: "admin",
+			Message:  "/v1.20/containers/abcdef",
+			Tags:     []string{"api", "v1.20", "delete"},
+		},
+		{
+			Type:     "api",
+			Username: "admin",
+			Message:  "/containers/abcdef/stop",
+			Tags:     []string{"api", "containers", "post"},
+		},
+	}
+
+	counts := countContainerDeletes(events)
+	if counts["admin"] != 2 {
+		t.Fatalf("expected 2 deletes; received %d", counts["admin"])
+	}
+}
+
+func TestIsNewCountry(t *testing.T) {
+	known := []string{"US", "DE"}
+
+	if isNewCountry("US", known) {
+		t.Fatalf("expected US to be a known country")
+	}
+
+	if !isNewCountry("FR", known) {
+		t.Fatalf("expected FR to be a new country")
+	}
+}
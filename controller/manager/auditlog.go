@@ -0,0 +1,190 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	tblNameAuditEntries = "audit_entries"
+)
+
+var (
+	ErrAuditRecordNotRedactable = errors.New("audit record was written before data hashes and cannot be redacted")
+)
+
+// nextAuditRecord returns the record of the event chained to last
+func nextAuditRecord(last *shipyard.AuditRecord, event *shipyard.Event) (*shipyard.AuditRecord, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &shipyard.AuditRecord{
+		Sequence:     last.Sequence + 1,
+		Data:         string(data),
+		DataHash:     shipyard.HashAuditData(string(data)),
+		PreviousHash: last.Hash,
+	}
+	rec.Hash = rec.ComputeHash()
+
+	return rec, nil
+}
+
+// verifyAuditRecord returns why the record does not follow the record
+// with the previous hash and sequence, or an empty string
+func verifyAuditRecord(rec *shipyard.AuditRecord, sequence int64, previous string) string {
+	switch {
+	case rec.Sequence != sequence:
+		return fmt.Sprintf("missing record: expected sequence %d", sequence)
+	case rec.PreviousHash != previous:
+		return "previous hash does not match"
+	case rec.Hash != rec.ComputeHash():
+		return "record hash does not match contents"
+	case rec.DataHash != "" && !rec.Redacted && rec.DataHash != shipyard.HashAuditData(rec.Data):
+		return "record data does not match its hash"
+	}
+
+	return ""
+}
+
+// redactAuditRecord replaces the user in the event of the record with the
+// pseudonym, removing the source address and location like
+// AnonymizeEvents; it reports whether the record named the user
+func redactAuditRecord(rec *shipyard.AuditRecord, username, pseudonym string) (bool, error) {
+	var evt *shipyard.Event
+	if err := json.Unmarshal([]byte(rec.Data), &evt); err != nil {
+		return false, err
+	}
+
+	message := datastore.ReplaceUsername(evt.Message, username, pseudonym)
+	if evt.Username != username && message == evt.Message {
+		return false, nil
+	}
+
+	if evt.Username == username {
+		evt.Username = pseudonym
+		evt.RemoteAddr = ""
+		evt.Country = ""
+		evt.ASN = ""
+	}
+	evt.Message = message
+
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return false, err
+	}
+
+	if !rec.Redact(string(data)) {
+		return true, ErrAuditRecordNotRedactable
+	}
+
+	return true, nil
+}
+
+// redactAuditLog replaces the user in the records of the hash chained
+// audit log; records written before data hashes were added cannot be
+// redacted without breaking the chain and are left as they are
+func (m DefaultManager) redactAuditLog(username, pseudonym string) error {
+	records, err := m.db.AuditRecords()
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, rec := range records {
+		if !strings.Contains(rec.Data, username) {
+			continue
+		}
+
+		redacted, err := redactAuditRecord(rec, username, pseudonym)
+		switch {
+		case err == ErrAuditRecordNotRedactable:
+			kept++
+			continue
+		case err != nil:
+			return err
+		case !redacted:
+			continue
+		}
+
+		if err := m.db.RedactAuditRecord(rec); err != nil {
+			return err
+		}
+	}
+
+	if kept > 0 {
+		log.Warnf("%d audit log records written before data hashes still name %s", kept, pseudonym)
+	}
+
+	return nil
+}
+
+// appendAuditLog adds the event to the hash chained audit log; records
+// are keyed by sequence so a concurrent append of the same sequence fails
+// instead of overwriting history
+func (m DefaultManager) appendAuditLog(event *shipyard.Event) error {
+	m.auditLock.Lock()
+	defer m.auditLock.Unlock()
+
+	last, err := m.db.LastAuditRecord()
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		last = &shipyard.AuditRecord{}
+	default:
+		return err
+	}
+
+	rec, err := nextAuditRecord(last, event)
+	if err != nil {
+		return err
+	}
+
+	return m.db.AppendAuditRecord(rec)
+}
+
+// SaveAuditEntry records a state-changing request
+func (m DefaultManager) SaveAuditEntry(entry *shipyard.AuditEntry) error {
+	return m.db.SaveAuditEntry(entry)
+}
+
+// AuditEntries returns the audit entries matching the query, newest first
+func (m DefaultManager) AuditEntries(query *datastore.AuditQuery) ([]*shipyard.AuditEntry, error) {
+	return m.db.AuditEntries(query)
+}
+
+func (m DefaultManager) VerifyAuditLog() (*shipyard.AuditVerification, error) {
+	records, err := m.db.AuditRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &shipyard.AuditVerification{
+		Valid: true,
+	}
+
+	previous := ""
+	for _, rec := range records {
+		result.Records++
+		if rec.Redacted {
+			result.Redacted++
+		}
+
+		if result.Message = verifyAuditRecord(rec, result.Records, previous); result.Message != "" {
+			result.Valid = false
+			result.FailedSequence = rec.Sequence
+			return result, nil
+		}
+
+		previous = rec.Hash
+	}
+
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestRedactAuditRecord(t *testing.T) {
+	events := []*shipyard.Event{
+		{Type: "login", Username: "bob", RemoteAddr: "10.0.0.1"},
+		{Type: "add-account", Username: "admin", Message: "username=bob"},
+		{Type: "login", Username: "admin", RemoteAddr: "10.0.0.2"},
+	}
+
+	records := []*shipyard.AuditRecord{}
+	last := &shipyard.AuditRecord{}
+	for _, evt := range events {
+		rec, err := nextAuditRecord(last, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+		last = rec
+	}
+
+	redacted := 0
+	for _, rec := range records {
+		ok, err := redactAuditRecord(rec, "bob", "anonymous-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			redacted++
+		}
+	}
+
+	if redacted != 2 {
+		t.Fatalf("expected 2 records naming bob; received %d", redacted)
+	}
+
+	previous := ""
+	for i, rec := range records {
+		if msg := verifyAuditRecord(rec, int64(i+1), previous); msg != "" {
+			t.Fatalf("expected the chain to stay valid after redaction; record %d: %s", rec.Sequence, msg)
+		}
+		previous = rec.Hash
+
+		if strings.Contains(rec.Data, "bob") || strings.Contains(rec.Data, "10.0.0.1") {
+			t.Fatalf("expected bob and the address to be redacted; received %s", rec.Data)
+		}
+	}
+
+	// changing the data without marking the record redacted is tampering
+	records[2].Data = strings.Replace(records[2].Data, "10.0.0.2", "10.0.0.3", 1)
+	if msg := verifyAuditRecord(records[2], 3, records[1].Hash); msg == "" {
+		t.Fatal("expected changed data to fail verification")
+	}
+
+	legacy := &shipyard.AuditRecord{Sequence: 1, Data: records[0].Data}
+	legacy.Hash = legacy.ComputeHash()
+	if _, err := redactAuditRecord(legacy, "anonymous-1", "anonymous-2"); err != ErrAuditRecordNotRedactable {
+		t.Fatalf("expected %s; received %v", ErrAuditRecordNotRedactable, err)
+	}
+}
+
+func TestAuditLogBolt(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	m.auditLock = &sync.Mutex{}
+	for _, evt := range []*shipyard.Event{
+		{Type: "login", Username: "bob", Message: "bob logged in"},
+		{Type: "login", Username: "alice", Message: "alice logged in"},
+	} {
+		if err := m.appendAuditLog(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.redactAuditLog("bob", "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := m.VerifyAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Valid || result.Records != 2 || result.Redacted != 1 {
+		t.Fatalf("expected a valid chain with a redacted record; received %+v", result)
+	}
+}
@@ -0,0 +1,356 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+// Kinds of records in a backup
+const (
+	BackupAccount    = "account"
+	BackupRole       = "role"
+	BackupServiceKey = "service-key"
+	BackupWebhookKey = "webhook-key"
+	BackupRegistry   = "registry"
+)
+
+// Actions a restore takes for a record
+const (
+	RestoreCreate    = "create"
+	RestoreReplace   = "replace"
+	RestoreUnchanged = "unchanged"
+)
+
+// BackupError is returned for backups which cannot be restored
+type BackupError struct {
+	Reason string
+}
+
+func (e *BackupError) Error() string {
+	return e.Reason
+}
+
+func backupErrorf(format string, args ...interface{}) error {
+	return &BackupError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// RestoreChange is what a restore does to a record
+type RestoreChange struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// RestoreResult lists the changes of a restore; nothing was saved when
+// DryRun is set
+type RestoreResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Changes []*RestoreChange `json:"changes"`
+}
+
+// count returns how many changes took the action
+func (r *RestoreResult) count(action string) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+
+	return n
+}
+
+// sameRecord compares the json of two records
+func sameRecord(a, b interface{}) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ja, jb)
+}
+
+// restoreAction returns the action for a record given the existing one
+// and the error of looking it up
+func restoreAction(existing, restored interface{}, err error) (string, error) {
+	if err == datastore.ErrNotFound {
+		return RestoreCreate, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if sameRecord(existing, restored) {
+		return RestoreUnchanged, nil
+	}
+
+	return RestoreReplace, nil
+}
+
+func backupAccount(a *auth.Account) *shipyard.BackupAccount {
+	acct := *a
+	acct.Tokens = nil
+
+	return &shipyard.BackupAccount{Account: &acct, TOTPSecret: a.TOTPSecret}
+}
+
+// Backup returns the accounts, custom roles, service keys, webhook keys
+// and registries of the controller; sessions are left out
+func (m DefaultManager) Backup() (*shipyard.Backup, error) {
+	b := &shipyard.Backup{
+		Version:   shipyard.BackupVersion,
+		CreatedAt: time.Now(),
+		Datastore: m.db.Name(),
+		Accounts:  []*shipyard.BackupAccount{},
+	}
+
+	accounts, err := m.db.Accounts(&datastore.AccountQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range accounts {
+		b.Accounts = append(b.Accounts, backupAccount(a))
+	}
+
+	if b.Roles, err = m.db.Roles(); err != nil {
+		return nil, err
+	}
+
+	if b.ServiceKeys, err = m.db.ServiceKeys(); err != nil {
+		return nil, err
+	}
+
+	if b.WebhookKeys, err = m.db.WebhookKeys(); err != nil {
+		return nil, err
+	}
+
+	if b.Registries, err = m.db.Registries(); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("backup", fmt.Sprintf("accounts=%d roles=%d service_keys=%d webhook_keys=%d registries=%d",
+		len(b.Accounts), len(b.Roles), len(b.ServiceKeys), len(b.WebhookKeys), len(b.Registries)), []string{"security"})
+
+	return b, nil
+}
+
+// validateBackup checks the format version and that every record can be
+// identified
+func validateBackup(b *shipyard.Backup) error {
+	if b.Version != shipyard.BackupVersion {
+		return backupErrorf("unsupported backup version %d; expected %d", b.Version, shipyard.BackupVersion)
+	}
+
+	for _, a := range b.Accounts {
+		if a == nil || a.Account == nil || a.Username == "" {
+			return backupErrorf("an account of the backup has no username")
+		}
+	}
+
+	for _, r := range b.Roles {
+		if r == nil || r.RoleName == "" {
+			return backupErrorf("a role of the backup has no name")
+		}
+
+		if isBuiltinRole(r.RoleName) {
+			return backupErrorf("role %s of the backup is a built-in role", r.RoleName)
+		}
+	}
+
+	for _, k := range b.ServiceKeys {
+		if k == nil || k.Key == "" {
+			return backupErrorf("a service key of the backup has no key")
+		}
+	}
+
+	for _, k := range b.WebhookKeys {
+		if k == nil || k.Key == "" {
+			return backupErrorf("a webhook key of the backup has no key")
+		}
+	}
+
+	for _, r := range b.Registries {
+		if r == nil || r.ID == "" {
+			return backupErrorf("a registry of the backup has no id")
+		}
+	}
+
+	return nil
+}
+
+// Restore creates the records of the backup which do not exist and
+// replaces the ones which changed; records missing from the backup are
+// kept. With dryRun the changes are only returned.
+func (m DefaultManager) Restore(b *shipyard.Backup, dryRun bool) (*RestoreResult, error) {
+	if err := validateBackup(b); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{DryRun: dryRun, Changes: []*RestoreChange{}}
+
+	// apply records the change and saves it unless it is a dry run
+	apply := func(kind, name, action string, save func() error) error {
+		result.Changes = append(result.Changes, &RestoreChange{Kind: kind, Name: name, Action: action})
+
+		if dryRun || action == RestoreUnchanged {
+			return nil
+		}
+
+		if err := save(); err != nil {
+			return fmt.Errorf("error restoring %s %s: %s", kind, name, err)
+		}
+
+		return nil
+	}
+
+	for _, a := range b.Accounts {
+		restored := *a.Account
+		restored.TOTPSecret = a.TOTPSecret
+		restored.Tokens = nil
+
+		existing, err := m.db.Account(restored.Username)
+		var current *shipyard.BackupAccount
+		if err == nil {
+			current = backupAccount(existing)
+			// the id is kept so the datastore updates the same record
+			restored.ID = existing.ID
+		}
+
+		action, err := restoreAction(current, backupAccount(&restored), err)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := apply(BackupAccount, restored.Username, action, func() error {
+			if action == RestoreCreate {
+				return m.db.CreateAccount(&restored)
+			}
+
+			return m.db.UpdateAccount(restored.Username, func(acct *auth.Account) error {
+				// sessions of the account stay valid
+				tokens := acct.Tokens
+				*acct = restored
+				acct.Tokens = tokens
+				return nil
+			})
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	roles, err := m.db.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range b.Roles {
+		var existing *auth.ACL
+		err := datastore.ErrNotFound
+		for _, r := range roles {
+			if r.RoleName == role.RoleName {
+				existing, err = r, nil
+			}
+		}
+
+		action, err := restoreAction(existing, role, err)
+		if err != nil {
+			return nil, err
+		}
+
+		role := role
+		if err := apply(BackupRole, role.RoleName, action, func() error {
+			return m.db.SaveRole(role)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range b.ServiceKeys {
+		existing, err := m.db.ServiceKey(key.Key)
+		action, err := restoreAction(existing, key, err)
+		if err != nil {
+			return nil, err
+		}
+
+		name := key.Description
+		if name == "" {
+			name = key.Key
+		}
+
+		key := key
+		if err := apply(BackupServiceKey, name, action, func() error {
+			// the key is the only identity of a service key in rethinkdb
+			if action == RestoreReplace {
+				if err := m.db.DeleteServiceKey(key.Key); err != nil {
+					return err
+				}
+			}
+
+			return m.db.SaveServiceKey(key)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range b.WebhookKeys {
+		existing, err := m.db.WebhookKey(key.Key)
+		action, err := restoreAction(existing, key, err)
+		if err != nil {
+			return nil, err
+		}
+
+		key, existing := key, existing
+		if err := apply(BackupWebhookKey, key.Image, action, func() error {
+			if action == RestoreReplace {
+				if err := m.db.DeleteWebhookKey(existing.ID); err != nil {
+					return err
+				}
+			}
+
+			return m.db.SaveWebhookKey(key)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, reg := range b.Registries {
+		existing, err := m.db.Registry(reg.ID)
+		action, err := restoreAction(existing, reg, err)
+		if err != nil {
+			return nil, err
+		}
+
+		reg := reg
+		if err := apply(BackupRegistry, reg.Name, action, func() error {
+			if action == RestoreReplace {
+				if err := m.db.DeleteRegistry(reg.ID); err != nil {
+					return err
+				}
+			}
+
+			return m.db.SaveRegistry(reg)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if !dryRun {
+		m.logEvent("restore", fmt.Sprintf("version=%d created_at=%s created=%d replaced=%d",
+			b.Version, b.CreatedAt.Format(time.RFC3339), result.count(RestoreCreate), result.count(RestoreReplace)), []string{"security"})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/dockerhub"
+)
+
+func TestBackupRestore(t *testing.T) {
+	src, cleanupSrc := newTestManager(t)
+	defer cleanupSrc()
+
+	if err := src.db.CreateAccount(&auth.Account{
+		Username:   "alice",
+		Password:   "hash",
+		Roles:      []string{"ops"},
+		TOTPSecret: "totp",
+		Tokens:     []*auth.AuthToken{{Token: "session"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.db.SaveRole(&auth.ACL{RoleName: "ops", Permissions: []string{auth.PermContainersRead}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.db.SaveServiceKey(&auth.ServiceKey{Key: "service", Description: "ci"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.db.SaveWebhookKey(&dockerhub.WebhookKey{Key: "webhook", Image: "ehazlett/test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.db.SaveRegistry(&shipyard.Registry{ID: "reg-1", Name: "private", Addr: "https://registry.local:5000", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := src.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if backup.Version != shipyard.BackupVersion || len(backup.Accounts) != 1 || backup.Accounts[0].TOTPSecret != "totp" {
+		t.Fatalf("expected the account with its totp secret; received %+v", backup)
+	}
+
+	if backup.Accounts[0].Tokens != nil {
+		t.Fatal("expected sessions to be left out of the backup")
+	}
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	result, err := m.Restore(backup, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Changes) != 5 || result.count(RestoreCreate) != 5 {
+		t.Fatalf("expected every record to be created; received %+v", result.Changes)
+	}
+
+	if _, err := m.db.Account("alice"); err != datastore.ErrNotFound {
+		t.Fatalf("expected the dry run not to save; received %v", err)
+	}
+
+	if _, err := m.Restore(backup, false); err != nil {
+		t.Fatal(err)
+	}
+
+	acct, err := m.db.Account("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if acct.Password != "hash" || acct.TOTPSecret != "totp" || len(acct.Roles) != 1 {
+		t.Fatalf("expected the restored account; received %+v", acct)
+	}
+
+	if reg, err := m.db.Registry("reg-1"); err != nil || reg.Password != "secret" {
+		t.Fatalf("expected the restored registry; received %+v %v", reg, err)
+	}
+
+	// restoring again changes nothing
+	result, err = m.Restore(backup, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.count(RestoreUnchanged) != 5 {
+		t.Fatalf("expected every record to be unchanged; received %+v", result.Changes)
+	}
+
+	backup.Accounts[0].Roles = []string{"admin"}
+	backup.WebhookKeys[0].Branch = "master"
+
+	result, err = m.Restore(backup, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.count(RestoreReplace) != 2 {
+		t.Fatalf("expected the account and webhook key to be replaced; received %+v", result.Changes)
+	}
+
+	keys, err := m.db.WebhookKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 || keys[0].Branch != "master" {
+		t.Fatalf("expected the webhook key to be replaced; received %+v", keys)
+	}
+
+	backup.Version = shipyard.BackupVersion + 1
+	if _, err := m.Restore(backup, true); err == nil {
+		t.Fatal("expected a newer backup to be refused")
+	} else if _, ok := err.(*BackupError); !ok {
+		t.Fatalf("expected a BackupError; received %v", err)
+	}
+}
+
+func TestValidateBackup(t *testing.T) {
+	for name, b := range map[string]*shipyard.Backup{
+		"account":  {Accounts: []*shipyard.BackupAccount{{Account: &auth.Account{}}}},
+		"builtin":  {Roles: []*auth.ACL{{RoleName: "admin"}}},
+		"key":      {ServiceKeys: []*auth.ServiceKey{{Description: "ci"}}},
+		"registry": {Registries: []*shipyard.Registry{{Name: "private"}}},
+	} {
+		b.Version = shipyard.BackupVersion
+		if _, ok := validateBackup(b).(*BackupError); !ok {
+			t.Errorf("%s: expected the backup to be refused", name)
+		}
+	}
+}
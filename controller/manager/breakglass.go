@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	tblNameBreakGlass        = "break_glass"
+	defaultBreakGlassTimeout = 1 * time.Hour
+)
+
+var (
+	ErrBreakGlassNotSealed    = errors.New("break-glass credential is not sealed")
+	ErrInvalidBreakGlass      = errors.New("invalid break-glass credential")
+	ErrBreakGlassReasonNeeded = errors.New("a reason is required for break-glass access")
+)
+
+// BreakGlass returns the state of the break-glass credential
+func (m DefaultManager) BreakGlass() (*auth.BreakGlass, error) {
+	b, err := m.db.BreakGlass()
+	if err == datastore.ErrNotFound {
+		return &auth.BreakGlass{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// SealBreakGlass generates a new break-glass credential replacing any
+// previous one; the credential is only returned here
+func (m DefaultManager) SealBreakGlass(username string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	credential := hex.EncodeToString(buf)
+
+	hash, err := auth.Hash(credential)
+	if err != nil {
+		return "", err
+	}
+
+	b := &auth.BreakGlass{
+		CredentialHash: hash,
+		SealedAt:       time.Now(),
+		SealedBy:       username,
+	}
+
+	if err := m.db.SaveBreakGlass(b); err != nil {
+		return "", err
+	}
+
+	m.logEvent("break-glass-sealed", fmt.Sprintf("sealed_by=%s", username), []string{"security", "break-glass"})
+
+	return credential, nil
+}
+
+// UseBreakGlass unseals the credential and grants temporary admin access
+// through the break-glass account; it is announced as a critical event
+func (m DefaultManager) UseBreakGlass(credential, reason, remoteAddr, userAgent string) (*auth.AuthToken, error) {
+	if reason == "" {
+		return nil, ErrBreakGlassReasonNeeded
+	}
+
+	b, err := m.BreakGlass()
+	if err != nil {
+		return nil, err
+	}
+
+	if !b.Sealed() {
+		m.logEvent("break-glass-denied", fmt.Sprintf("credential not sealed: addr=%s", remoteAddr), []string{"security", "break-glass"})
+		return nil, ErrBreakGlassNotSealed
+	}
+
+	if err := auth.CompareHash(b.CredentialHash, credential); err != nil {
+		m.logEvent("break-glass-denied", fmt.Sprintf("invalid credential: addr=%s", remoteAddr), []string{"security", "break-glass"})
+		return nil, ErrInvalidBreakGlass
+	}
+
+	timeout := m.breakGlassTimeout
+	if timeout == 0 {
+		timeout = defaultBreakGlassTimeout
+	}
+
+	now := time.Now()
+	expires := now.Add(timeout)
+
+	// the credential is single use; it has to be sealed again afterwards
+	if err := m.db.UseBreakGlass(remoteAddr, reason, now, expires); err != nil {
+		return nil, notFound(err, ErrBreakGlassNotSealed)
+	}
+
+	acct := &auth.Account{
+		Username: auth.BreakGlassUsername,
+		Roles:    []string{"admin"},
+	}
+	if err := m.SaveAccount(acct); err != nil {
+		return nil, err
+	}
+
+	token, err := m.NewAuthToken(auth.BreakGlassUsername, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	m.logEvent("break-glass", fmt.Sprintf("break-glass access granted until %s: addr=%s reason=%s", expires.Format(time.RFC3339), remoteAddr, reason), []string{"security", "break-glass", "critical"})
+
+	return token, nil
+}
+
+// EndBreakGlass revokes break-glass access before it expires
+func (m DefaultManager) EndBreakGlass(username string) error {
+	b, err := m.db.BreakGlass()
+	switch err {
+	case nil:
+		now := time.Now()
+		b.ExpiresAt = &now
+		if err := m.db.SaveBreakGlass(b); err != nil {
+			return err
+		}
+	case datastore.ErrNotFound:
+	default:
+		return err
+	}
+
+	if err := m.revokeBreakGlassAccount(); err != nil {
+		return err
+	}
+
+	m.logEvent("break-glass-ended", fmt.Sprintf("ended_by=%s", username), []string{"security", "break-glass"})
+
+	return nil
+}
+
+func (m DefaultManager) revokeBreakGlassAccount() error {
+	if err := m.db.DeleteAccount(auth.BreakGlassUsername); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// verifyBreakGlass checks that break-glass access has not expired and
+// revokes the account once it has
+func (m DefaultManager) verifyBreakGlass() error {
+	b, err := m.BreakGlass()
+	if err != nil {
+		return err
+	}
+
+	if b.Active(time.Now()) {
+		return nil
+	}
+
+	if err := m.revokeBreakGlassAccount(); err != nil {
+		return err
+	}
+
+	m.logEvent("break-glass-expired", "break-glass access expired", []string{"security", "break-glass"})
+
+	return ErrInvalidAuthToken
+}
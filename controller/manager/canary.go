@@ -0,0 +1,530 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+const (
+	defaultCanaryBake = 5 * time.Minute
+	maxCanaryBake     = 24 * time.Hour
+	// canaryPoll is how often the canaries are checked while they bake
+	canaryPoll = 10 * time.Second
+
+	// Decisions ending a canary before its bake period is over
+	CanaryPromote  = "promote"
+	CanaryRollback = "rollback"
+)
+
+var (
+	ErrCanaryDoesNotExist = errors.New("the stack has no canary")
+	ErrCanaryNotBaking    = errors.New("the canary of the stack is no longer baking")
+	ErrCanaryReplicas     = errors.New("a canary needs a service with at least two replicas")
+	ErrCanaryPercent      = errors.New("the percent of canaries has to be between 1 and 99")
+	ErrCanaryBake         = fmt.Errorf("the bake period has to be positive and at most %s", maxCanaryBake)
+	ErrCanaryImage        = errors.New("an image is required")
+	ErrCanaryDecision     = errors.New("a canary can only be promoted or rolled back")
+)
+
+type (
+	// CanaryOptions start a canary of a service of a stack; a zero Bake
+	// bakes for the default period and a zero MaxCPUPercent does not
+	// check the cpu
+	CanaryOptions struct {
+		Service       string        `json:"service"`
+		Image         string        `json:"image"`
+		Percent       int           `json:"percent"`
+		Bake          time.Duration `json:"-"`
+		MaxRestarts   int           `json:"max_restarts"`
+		MaxCPUPercent float64       `json:"max_cpu_percent"`
+	}
+
+	// canaryDecision promotes or rolls back a canary before its bake
+	// period is over
+	canaryDecision struct {
+		action   string
+		username string
+	}
+
+	// canaryRun is a canary with the replicas still on the previous image
+	canaryRun struct {
+		canary *shipyard.Canary
+		// previous are the replicas left on the previous image
+		previous []string
+		decide   chan canaryDecision
+	}
+
+	// canaryState is the part of an inspect checked while a canary bakes;
+	// dockerclient does not decode the health nor the restarts
+	canaryState struct {
+		RestartCount int
+		State        struct {
+			Running bool
+			Health  *struct {
+				Status string
+			}
+		}
+	}
+
+	// canaryTracker keeps the last canary of each stack; a controller
+	// restart forgets them
+	canaryTracker struct {
+		mu   sync.Mutex
+		runs map[string]*canaryRun
+		// poll is how often the canaries are checked
+		poll time.Duration
+	}
+)
+
+func newCanaryTracker() *canaryTracker {
+	return &canaryTracker{
+		runs: map[string]*canaryRun{},
+		poll: canaryPoll,
+	}
+}
+
+func (t *canaryTracker) add(run *canaryRun) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.runs[run.canary.Stack] = run
+}
+
+// get returns a copy of the last canary of the stack
+func (t *canaryTracker) get(stack string) *shipyard.Canary {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	run, ok := t.runs[stack]
+	if !ok {
+		return nil
+	}
+
+	c := *run.canary
+	c.Containers = append([]string{}, run.canary.Containers...)
+
+	return &c
+}
+
+// update changes the canary of the run
+func (t *canaryTracker) update(run *canaryRun, fn func(c *shipyard.Canary)) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+	}
+
+	fn(run.canary)
+}
+
+// decide passes the decision to the canary of the stack while it bakes
+func (t *canaryTracker) decide(stack string, d canaryDecision) error {
+	if t == nil {
+		return ErrCanaryDoesNotExist
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	run, ok := t.runs[stack]
+	if !ok {
+		return ErrCanaryDoesNotExist
+	}
+
+	if run.canary.Status != shipyard.CanaryBaking {
+		return ErrCanaryNotBaking
+	}
+
+	select {
+	case run.decide <- d:
+		return nil
+	default:
+		// a decision is already pending
+		return ErrCanaryNotBaking
+	}
+}
+
+// interval returns how often the canaries are checked
+func (t *canaryTracker) interval() time.Duration {
+	if t == nil {
+		return canaryPoll
+	}
+
+	return t.poll
+}
+
+// canaryCount returns how many of the replicas run the new image; at least
+// one replica runs either image
+func canaryCount(replicas, percent int) int {
+	n := int(math.Ceil(float64(replicas*percent) / 100))
+	if n < 1 {
+		n = 1
+	}
+	if n > replicas-1 {
+		n = replicas - 1
+	}
+
+	return n
+}
+
+// checkCanary returns why the canary container fails the checks; empty
+// when it passes. At the end of the bake a health check still starting
+// fails.
+func checkCanary(c *shipyard.Canary, id string, state *canaryState, stats []*shipyard.ContainerStats, final bool) string {
+	if !state.State.Running {
+		return fmt.Sprintf("canary %s is not running", id)
+	}
+
+	if state.RestartCount > c.MaxRestarts {
+		return fmt.Sprintf("canary %s restarted %d times", id, state.RestartCount)
+	}
+
+	if h := state.State.Health; h != nil {
+		if h.Status == "unhealthy" || (final && h.Status != "healthy") {
+			return fmt.Sprintf("canary %s is %s", id, h.Status)
+		}
+	}
+
+	if c.MaxCPUPercent > 0 && len(stats) > 0 {
+		total := 0.0
+		for _, s := range stats {
+			total += s.CPUPercent
+		}
+
+		if cpu := total / float64(len(stats)); cpu > c.MaxCPUPercent {
+			return fmt.Sprintf("canary %s used %.1f%% cpu on average", id, cpu)
+		}
+	}
+
+	return ""
+}
+
+// inspectCanary returns the state of the canary container
+func (m DefaultManager) inspectCanary(id string) (*canaryState, error) {
+	client := m.DockerClient()
+	resp, err := client.HTTPClient.Get(fmt.Sprintf("%s/%s/containers/%s/json", client.URL.String(), dockerclient.APIVersion, id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error inspecting %s: %s", id, resp.Status)
+	}
+
+	state := &canaryState{}
+	if err := json.NewDecoder(resp.Body).Decode(state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// serviceContainers returns the containers of the service of the stack
+// sorted by name
+func (m DefaultManager) serviceContainers(stack, service string) ([]dockerclient.Container, error) {
+	containers, err := m.stackContainers(stack)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []dockerclient.Container{}
+	for _, c := range containers {
+		if c.Labels[compose.LabelService] == service {
+			matched = append(matched, c)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return strings.Join(matched[i].Names, ",") < strings.Join(matched[j].Names, ",")
+	})
+
+	return matched, nil
+}
+
+// StartCanary recreates a percent of the replicas of the service with the
+// image and checks them for the bake period: a canary which stops, restarts
+// more than allowed, turns unhealthy or uses more cpu than allowed rolls
+// them back; otherwise the other replicas and the compose file of the stack
+// get the image. The stack stays locked until then.
+func (m DefaultManager) StartCanary(stack string, opts *CanaryOptions, username string) (*shipyard.Canary, error) {
+	if opts.Image == "" {
+		return nil, ErrCanaryImage
+	}
+
+	if opts.Percent < 1 || opts.Percent > 99 {
+		return nil, ErrCanaryPercent
+	}
+
+	bake := opts.Bake
+	if bake == 0 {
+		bake = defaultCanaryBake
+	}
+
+	if bake < 0 || bake > maxCanaryBake {
+		return nil, ErrCanaryBake
+	}
+
+	if opts.MaxRestarts < 0 {
+		opts.MaxRestarts = 0
+	}
+
+	s, err := m.db.Stack(stack)
+	if err != nil {
+		return nil, notFound(err, ErrStackDoesNotExist)
+	}
+
+	f, err := compose.Parse([]byte(s.Compose))
+	if err != nil {
+		return nil, &ComposeError{Err: err}
+	}
+
+	svc, ok := f.Services[opts.Service]
+	if !ok {
+		return nil, &ComposeError{Err: fmt.Errorf("%s: %s", opts.Service, compose.ErrNoSuchService)}
+	}
+
+	if err := m.checkStackFreeze(f, username, "canary "+opts.Image+" on stack "+stack); err != nil {
+		return nil, err
+	}
+
+	unlock, err := m.lockStack(stack, username, "canary", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := m.startCanary(stack, svc.Image, bake, opts, username)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	go m.bakeCanary(run, unlock)
+
+	return m.canaries.get(stack), nil
+}
+
+// startCanary pulls the image and recreates the canaries
+func (m DefaultManager) startCanary(stack, previous string, bake time.Duration, opts *CanaryOptions, username string) (*canaryRun, error) {
+	containers, err := m.serviceContainers(stack, opts.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(containers) < 2 {
+		return nil, ErrCanaryReplicas
+	}
+
+	if err := m.pullImage(DeployStack+":"+stack, opts.Image); err != nil {
+		return nil, err
+	}
+
+	n := canaryCount(len(containers), opts.Percent)
+	now := time.Now()
+	run := &canaryRun{
+		canary: &shipyard.Canary{
+			Stack:         stack,
+			Service:       opts.Service,
+			Image:         opts.Image,
+			PreviousImage: previous,
+			Percent:       opts.Percent,
+			Replicas:      len(containers),
+			Containers:    []string{},
+			Weight:        100 * n / len(containers),
+			MaxRestarts:   opts.MaxRestarts,
+			MaxCPUPercent: opts.MaxCPUPercent,
+			Status:        shipyard.CanaryBaking,
+			StartedBy:     username,
+			StartedAt:     now,
+			BakeUntil:     now.Add(bake),
+		},
+		previous: []string{},
+		decide:   make(chan canaryDecision, 1),
+	}
+
+	for i, c := range containers {
+		if i < len(containers)-n {
+			run.previous = append(run.previous, c.Id)
+			continue
+		}
+
+		id, err := m.redeployContainer(c.Id, opts.Image)
+		if err != nil {
+			// the canaries created so far go back to the previous image
+			m.rollbackCanary(run, fmt.Sprintf("error creating canary from %s: %s", c.Id, err))
+			return nil, err
+		}
+		run.canary.Containers = append(run.canary.Containers, id)
+	}
+
+	m.canaries.add(run)
+
+	m.logEvent("canary-start", fmt.Sprintf("stack=%s service=%s image=%s canaries=%d replicas=%d bake=%s username=%s",
+		stack, opts.Service, opts.Image, n, len(containers), bake, username), []string{"deploy", "stack"})
+
+	return run, nil
+}
+
+// bakeCanary checks the canaries until the bake period is over or a
+// decision is made, then promotes or rolls them back and unlocks the stack
+func (m DefaultManager) bakeCanary(run *canaryRun, unlock func()) {
+	defer unlock()
+
+	c := run.canary
+	ticker := time.NewTicker(m.canaries.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case d := <-run.decide:
+			if d.action == CanaryPromote {
+				m.promoteCanary(run, "promoted by "+d.username)
+				return
+			}
+			m.rollbackCanary(run, "rolled back by "+d.username)
+			return
+		case now := <-ticker.C:
+			// the lease of the stack is kept for the whole bake
+			if _, err := m.acquireLease("stack:"+c.Stack, stackLockTimeout); err != nil {
+				log.Errorf("canary: error renewing the lease of stack %s: %s", c.Stack, err)
+			}
+
+			final := !now.Before(c.BakeUntil)
+			for _, id := range c.Containers {
+				state, err := m.inspectCanary(id)
+				if err != nil {
+					m.rollbackCanary(run, fmt.Sprintf("error inspecting canary %s: %s", id, err))
+					return
+				}
+
+				if reason := checkCanary(c, id, state, m.stats.since(id, c.StartedAt), final); reason != "" {
+					m.rollbackCanary(run, reason)
+					return
+				}
+			}
+
+			if final {
+				m.promoteCanary(run, "")
+				return
+			}
+		}
+	}
+}
+
+// promoteCanary recreates the other replicas with the image and saves it
+// in the compose file of the stack
+func (m DefaultManager) promoteCanary(run *canaryRun, reason string) {
+	c := run.canary
+	errs := []string{}
+
+	for _, id := range run.previous {
+		if _, err := m.redeployContainer(id, c.Image); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", id, err))
+		}
+	}
+
+	if err := m.saveStackImage(c.Stack, c.Service, c.Image); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	status := shipyard.CanaryPromoted
+	if len(errs) > 0 {
+		status, reason = shipyard.CanaryFailed, "error promoting: "+strings.Join(errs, "; ")
+	}
+
+	m.finishCanary(run, status, reason)
+}
+
+// rollbackCanary recreates the canaries with the previous image
+func (m DefaultManager) rollbackCanary(run *canaryRun, reason string) {
+	c := run.canary
+	errs := []string{}
+
+	for _, id := range c.Containers {
+		if _, err := m.redeployContainer(id, c.PreviousImage); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", id, err))
+		}
+	}
+
+	status := shipyard.CanaryRolledBack
+	if len(errs) > 0 {
+		status, reason = shipyard.CanaryFailed, reason+"; error rolling back: "+strings.Join(errs, "; ")
+	}
+
+	m.finishCanary(run, status, reason)
+}
+
+func (m DefaultManager) finishCanary(run *canaryRun, status, reason string) {
+	now := time.Now()
+	m.canaries.update(run, func(c *shipyard.Canary) {
+		c.Status = status
+		c.Reason = reason
+		c.FinishedAt = &now
+	})
+
+	c := run.canary
+	log.Infof("canary: stack=%s service=%s image=%s status=%s reason=%s", c.Stack, c.Service, c.Image, status, reason)
+	m.logEvent("canary-"+status, fmt.Sprintf("stack=%s service=%s image=%s reason=%s", c.Stack, c.Service, c.Image, reason), []string{"deploy", "stack"})
+}
+
+// saveStackImage replaces the image of the service in the compose file of
+// the stack
+func (m DefaultManager) saveStackImage(name, service, image string) error {
+	stack, err := m.db.Stack(name)
+	if err != nil {
+		return notFound(err, ErrStackDoesNotExist)
+	}
+
+	data, err := compose.SetImage([]byte(stack.Compose), service, image)
+	if err != nil {
+		return err
+	}
+
+	stack.Compose = string(data)
+	stack.UpdatedBy = "canary"
+	stack.UpdatedAt = time.Now()
+
+	return m.db.SaveStack(stack)
+}
+
+// Canary returns the last canary of the stack
+func (m DefaultManager) Canary(stack string) (*shipyard.Canary, error) {
+	c := m.canaries.get(stack)
+	if c == nil {
+		return nil, ErrCanaryDoesNotExist
+	}
+
+	return c, nil
+}
+
+// DecideCanary promotes or rolls back the canary of the stack without
+// waiting for the end of its bake period
+func (m DefaultManager) DecideCanary(stack, action, username string) error {
+	if action != CanaryPromote && action != CanaryRollback {
+		return ErrCanaryDecision
+	}
+
+	return m.canaries.decide(stack, canaryDecision{action: action, username: username})
+}
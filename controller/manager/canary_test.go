@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestCanaryCount(t *testing.T) {
+	tests := []struct {
+		replicas, percent, expected int
+	}{
+		{10, 10, 1},
+		{10, 25, 3},
+		{4, 1, 1},
+		{2, 90, 1},
+		{3, 99, 2},
+	}
+
+	for _, test := range tests {
+		if n := canaryCount(test.replicas, test.percent); n != test.expected {
+			t.Errorf("expected %d canaries for %d%% of %d replicas; received %d", test.expected, test.percent, test.replicas, n)
+		}
+	}
+}
+
+func TestCheckCanary(t *testing.T) {
+	c := &shipyard.Canary{MaxRestarts: 1, MaxCPUPercent: 50}
+
+	state := func(running bool, restarts int, health string) *canaryState {
+		s := &canaryState{RestartCount: restarts}
+		s.State.Running = running
+		if health != "" {
+			s.State.Health = &struct{ Status string }{Status: health}
+		}
+		return s
+	}
+
+	tests := []struct {
+		state  *canaryState
+		stats  []*shipyard.ContainerStats
+		final  bool
+		reason string
+	}{
+		{state(true, 0, ""), nil, true, ""},
+		{state(true, 1, "healthy"), []*shipyard.ContainerStats{{CPUPercent: 40}, {CPUPercent: 55}}, true, ""},
+		{state(true, 0, "starting"), nil, false, ""},
+		{state(true, 0, "starting"), nil, true, "is starting"},
+		{state(true, 0, "unhealthy"), nil, false, "is unhealthy"},
+		{state(false, 0, ""), nil, false, "is not running"},
+		{state(true, 2, ""), nil, false, "restarted 2 times"},
+		{state(true, 0, ""), []*shipyard.ContainerStats{{CPUPercent: 60}, {CPUPercent: 80}}, false, "70.0% cpu"},
+	}
+
+	for i, test := range tests {
+		reason := checkCanary(c, "web-2", test.state, test.stats, test.final)
+		if test.reason == "" && reason != "" {
+			t.Errorf("%d: expected the canary to pass; received %s", i, reason)
+		}
+
+		if !strings.Contains(reason, test.reason) {
+			t.Errorf("%d: expected %q; received %q", i, test.reason, reason)
+		}
+	}
+}
+
+func TestDecideCanary(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	m.canaries = newCanaryTracker()
+
+	if err := m.DecideCanary("shop", CanaryPromote, "admin"); err != ErrCanaryDoesNotExist {
+		t.Fatalf("expected %s; received %v", ErrCanaryDoesNotExist, err)
+	}
+
+	run := &canaryRun{
+		canary: &shipyard.Canary{Stack: "shop", Status: shipyard.CanaryBaking},
+		decide: make(chan canaryDecision, 1),
+	}
+	m.canaries.add(run)
+
+	if err := m.DecideCanary("shop", "pause", "admin"); err != ErrCanaryDecision {
+		t.Fatalf("expected %s; received %v", ErrCanaryDecision, err)
+	}
+
+	if err := m.DecideCanary("shop", CanaryRollback, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := <-run.decide; d.action != CanaryRollback || d.username != "admin" {
+		t.Fatalf("expected the rollback of admin; received %+v", d)
+	}
+
+	m.finishCanary(run, shipyard.CanaryRolledBack, "rolled back by admin")
+
+	if err := m.DecideCanary("shop", CanaryPromote, "admin"); err != ErrCanaryNotBaking {
+		t.Fatalf("expected %s; received %v", ErrCanaryNotBaking, err)
+	}
+
+	c, err := m.Canary("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Status != shipyard.CanaryRolledBack || c.FinishedAt == nil {
+		t.Fatalf("expected the canary to be rolled back; received %+v", c)
+	}
+}
@@ -0,0 +1,234 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	// LabelOwner is the label of the account a container belongs to
+	LabelOwner = "com.shipyard.owner"
+
+	// kinds of resources in the cleanup report
+	CleanupContainer  = "container"
+	CleanupWebhookKey = "webhook-key"
+	CleanupRegistry   = "registry"
+)
+
+var (
+	ErrCleanupItemDoesNotExist = errors.New("the resource is not in the cleanup report")
+)
+
+type (
+	// CleanupItem is a resource left behind; the action is what the
+	// remediation does
+	CleanupItem struct {
+		Kind   string `json:"kind"`
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+		Action string `json:"action"`
+	}
+
+	// CleanupReport lists the orphaned resources; each one is remediated
+	// with Remediate
+	CleanupReport struct {
+		GeneratedAt time.Time      `json:"generated_at"`
+		Items       []*CleanupItem `json:"items"`
+	}
+)
+
+// imageRepository returns the repository of the image without the tag or
+// digest so references of any version can be compared
+func imageRepository(image string) string {
+	image = normalizeImage(image)
+	if i := strings.Index(image, "@"); i > -1 {
+		return image[:i]
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+
+	return image
+}
+
+// CleanupReport finds containers owned by accounts which no longer exist,
+// webhook keys for images no container runs and registries rejecting
+// their credentials
+func (m DefaultManager) CleanupReport() (*CleanupReport, error) {
+	report := &CleanupReport{
+		GeneratedAt: time.Now(),
+		Items:       []*CleanupItem{},
+	}
+
+	accounts, err := m.db.Accounts(&datastore.AccountQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := map[string]bool{}
+	for _, acct := range accounts {
+		usernames[acct.Username] = true
+	}
+
+	containers, err := m.DockerClient().ListContainers(true, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := map[string]bool{}
+	for _, c := range containers {
+		deployed[imageRepository(c.Image)] = true
+
+		owner := c.Labels[LabelOwner]
+		if owner == "" || usernames[owner] {
+			continue
+		}
+
+		name := c.Id
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		report.Items = append(report.Items, &CleanupItem{
+			Kind:   CleanupContainer,
+			ID:     c.Id,
+			Name:   name,
+			Reason: fmt.Sprintf("the owner %s does not exist", owner),
+			Action: "remove the container",
+		})
+	}
+
+	keys, err := m.db.WebhookKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if deployed[imageRepository(key.Image)] {
+			continue
+		}
+
+		report.Items = append(report.Items, &CleanupItem{
+			Kind:   CleanupWebhookKey,
+			ID:     key.ID,
+			Name:   key.Image,
+			Reason: fmt.Sprintf("no container runs %s", key.Image),
+			Action: "delete the webhook key",
+		})
+	}
+
+	registries, err := m.db.Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, registry := range registries {
+		// unreachable registries are left alone as they are often back
+		// soon
+		if err := m.PingRegistry(registry); err != ErrRegistryAuthFailed {
+			continue
+		}
+
+		report.Items = append(report.Items, &CleanupItem{
+			Kind:   CleanupRegistry,
+			ID:     registry.ID,
+			Name:   registry.Name,
+			Reason: fmt.Sprintf("%s rejects the credentials", registry.Addr),
+			Action: "remove the registry",
+		})
+	}
+
+	return report, nil
+}
+
+// Remediate cleans up a resource of the cleanup report; the report is
+// generated again so resources which are no longer orphaned are kept
+func (m DefaultManager) Remediate(kind, id, username string) error {
+	report, err := m.CleanupReport()
+	if err != nil {
+		return err
+	}
+
+	var item *CleanupItem
+	for _, i := range report.Items {
+		if i.Kind == kind && i.ID == id {
+			item = i
+			break
+		}
+	}
+
+	if item == nil {
+		return ErrCleanupItemDoesNotExist
+	}
+
+	switch kind {
+	case CleanupContainer:
+		err = m.removeOrphanContainer(id, username)
+	case CleanupWebhookKey:
+		err = m.removeWebhookKey(id, item.Name)
+	case CleanupRegistry:
+		err = m.removeRegistry(id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	m.logEvent("cleanup", fmt.Sprintf("kind=%s id=%s name=%s reason=%q username=%s", kind, id, item.Name, item.Reason, username), []string{"cleanup"})
+
+	return nil
+}
+
+// removeWebhookKey deletes the webhook key by its id; the key itself is
+// not listed in the report
+func (m DefaultManager) removeWebhookKey(id, image string) error {
+	if err := m.db.DeleteWebhookKey(id); err != nil {
+		return notFound(err, ErrWebhookKeyDoesNotExist)
+	}
+
+	m.logEvent("delete-webhook-key", fmt.Sprintf("image=%s", image), []string{"webhook"})
+
+	return nil
+}
+
+// removeRegistry removes the registry without connecting to it
+func (m DefaultManager) removeRegistry(id string) error {
+	registry, err := m.db.Registry(id)
+	if err != nil {
+		return notFound(err, ErrRegistryDoesNotExist)
+	}
+
+	return m.RemoveRegistry(registry)
+}
+
+// removeOrphanContainer removes the container unless its environment is
+// frozen
+func (m DefaultManager) removeOrphanContainer(id, username string) error {
+	info, err := m.Container(id)
+	if err != nil {
+		return err
+	}
+
+	environment := ""
+	if info.Config != nil {
+		environment = info.Config.Labels[notification.LabelEnvironment]
+	}
+
+	if err := m.CheckFreeze(username, environment, "remove container "+id); err != nil {
+		return err
+	}
+
+	if err := m.DockerClient().RemoveContainer(id, true, false); err != nil && err != dockerclient.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
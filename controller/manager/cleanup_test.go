@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/dockerhub"
+)
+
+func TestImageRepository(t *testing.T) {
+	repositories := map[string]string{
+		"nginx":                           "nginx",
+		"library/nginx:1.9":               "nginx",
+		"registry.local:5000/web":         "registry.local:5000/web",
+		"registry.local:5000/web:2":       "registry.local:5000/web",
+		"ehazlett/test@sha256:0123456789": "ehazlett/test",
+	}
+
+	for image, repository := range repositories {
+		if r := imageRepository(image); r != repository {
+			t.Fatalf("expected repository %s of %s; received %s", repository, image, r)
+		}
+	}
+}
+
+func TestCleanupReport(t *testing.T) {
+	removed := ""
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[
+				{"Id":"orphan","Names":["/node-1/web"],"Image":"nginx:1.9","Labels":{"com.shipyard.owner":"bob"}},
+				{"Id":"owned","Names":["/node-1/cache"],"Image":"redis","Labels":{"com.shipyard.owner":"alice"}}
+			]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/orphan/json"):
+			w.Write([]byte(`{"Id":"orphan","Config":{"Labels":{"com.shipyard.owner":"bob"}}}`))
+		case r.Method == "DELETE" && strings.HasSuffix(r.URL.Path, "/containers/orphan"):
+			removed = "orphan"
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+
+	authorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer authorized.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	if err := m.db.CreateAccount(&auth.Account{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []*dockerhub.WebhookKey{
+		{ID: "deployed", Image: "library/nginx"},
+		{ID: "stale", Image: "ehazlett/gone"},
+	} {
+		if err := m.db.SaveWebhookKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, registry := range []*shipyard.Registry{
+		{ID: "rejected", Name: "rejected", Addr: unauthorized.URL},
+		{ID: "ok", Name: "ok", Addr: authorized.URL},
+	} {
+		if err := m.db.SaveRegistry(registry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := m.CleanupReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := map[string]string{}
+	for _, item := range report.Items {
+		items[item.Kind] = item.ID
+	}
+
+	expected := map[string]string{
+		CleanupContainer:  "orphan",
+		CleanupWebhookKey: "stale",
+		CleanupRegistry:   "rejected",
+	}
+	if len(report.Items) != len(expected) {
+		t.Fatalf("expected %d items; received %+v", len(expected), items)
+	}
+	for kind, id := range expected {
+		if items[kind] != id {
+			t.Fatalf("expected %s %s in the report; received %+v", kind, id, items)
+		}
+	}
+
+	if err := m.Remediate(CleanupContainer, "owned", "admin"); err != ErrCleanupItemDoesNotExist {
+		t.Fatalf("expected owned containers to be kept; received %v", err)
+	}
+
+	for kind, id := range expected {
+		if err := m.Remediate(kind, id, "admin"); err != nil {
+			t.Fatalf("error cleaning up %s %s: %s", kind, id, err)
+		}
+	}
+
+	if removed != "orphan" {
+		t.Fatal("expected the orphaned container to be removed")
+	}
+
+	if _, err := m.db.WebhookKey("stale"); err != datastore.ErrNotFound {
+		t.Fatalf("expected the stale webhook key to be deleted; received %v", err)
+	}
+
+	if _, err := m.db.Registry("rejected"); err != datastore.ErrNotFound {
+		t.Fatalf("expected the rejected registry to be removed; received %v", err)
+	}
+}
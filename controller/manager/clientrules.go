@@ -0,0 +1,233 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shipyard/shipyard"
+)
+
+const (
+	tblNameClientRules = "client_rules"
+	clientRuleIDLength = 16
+	// clientRulesTTL is how long the rules are cached as every request
+	// is checked against them
+	clientRulesTTL = 30 * time.Second
+)
+
+var (
+	ErrClientRuleDoesNotExist = errors.New("client rule does not exist")
+	ErrInvalidClientRule      = errors.New("a client rule needs a client, a minimum version like 1.2.3 and a route prefix starting with /")
+
+	clientVersion = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*`)
+	// versionedPath matches the version prefixes of the docker and
+	// shipyard apis, which rules ignore
+	versionedPath = regexp.MustCompile(`^(/api)?/v[0-9.]+/`)
+)
+
+// ClientError is returned for clients older than a rule allows
+type ClientError struct {
+	Rule    *shipyard.ClientRule
+	Version string
+}
+
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("%s %s is no longer supported on %s; upgrade to %s or later", e.Rule.Client, e.Version, e.Rule.RoutePrefix, e.Rule.MinVersion)
+}
+
+// clientRuleCache keeps the rules until they expire or change
+type clientRuleCache struct {
+	mu       sync.Mutex
+	rules    []*shipyard.ClientRule
+	loadedAt time.Time
+}
+
+func (m DefaultManager) ClientRules() ([]*shipyard.ClientRule, error) {
+	return m.db.ClientRules()
+}
+
+func (m DefaultManager) ClientRule(id string) (*shipyard.ClientRule, error) {
+	rule, err := m.db.ClientRule(id)
+	if err != nil {
+		return nil, notFound(err, ErrClientRuleDoesNotExist)
+	}
+
+	return rule, nil
+}
+
+// SaveClientRule creates the rule or replaces it when it has an id
+func (m DefaultManager) SaveClientRule(rule *shipyard.ClientRule) error {
+	rule.Client = strings.TrimSpace(rule.Client)
+	rule.MinVersion = strings.TrimPrefix(strings.TrimSpace(rule.MinVersion), "v")
+	if rule.Client == "" || strings.ContainsAny(rule.Client, " /") || !clientVersion.MatchString(rule.MinVersion) || !strings.HasPrefix(rule.RoutePrefix, "/") {
+		return ErrInvalidClientRule
+	}
+
+	if rule.ID == "" {
+		rule.ID = generateId(clientRuleIDLength)
+		rule.CreatedAt = time.Now()
+	} else if _, err := m.ClientRule(rule.ID); err != nil {
+		return err
+	}
+
+	if err := m.db.SaveClientRule(rule); err != nil {
+		return err
+	}
+	m.clientRules.reset()
+
+	m.logEvent("save-client-rule", fmt.Sprintf("id=%s client=%s min_version=%s route_prefix=%s created_by=%s",
+		rule.ID, rule.Client, rule.MinVersion, rule.RoutePrefix, rule.CreatedBy), []string{"security"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteClientRule(id string) error {
+	if err := m.db.DeleteClientRule(id); err != nil {
+		return notFound(err, ErrClientRuleDoesNotExist)
+	}
+	m.clientRules.reset()
+
+	m.logEvent("delete-client-rule", fmt.Sprintf("id=%s", id), []string{"security"})
+
+	return nil
+}
+
+// CheckClient returns a ClientError when the user agent is older than a
+// rule for the path allows
+func (m DefaultManager) CheckClient(userAgent, path string) error {
+	rules, err := m.cachedClientRules()
+	if err != nil {
+		return err
+	}
+
+	path = versionedPath.ReplaceAllStringFunc(path, func(prefix string) string {
+		if strings.HasPrefix(prefix, "/api/") {
+			return "/api/"
+		}
+		return "/"
+	})
+
+	for _, rule := range rules {
+		if !routeHasPrefix(path, rule.RoutePrefix) {
+			continue
+		}
+
+		version, ok := userAgentVersion(userAgent, rule.Client)
+		if !ok {
+			continue
+		}
+
+		if versionOlder(version, rule.MinVersion) {
+			return &ClientError{Rule: rule, Version: version}
+		}
+	}
+
+	return nil
+}
+
+// cachedClientRules returns the rules, loading them when the cache expired;
+// managers without a cache always load them
+func (m DefaultManager) cachedClientRules() ([]*shipyard.ClientRule, error) {
+	c := m.clientRules
+	if c == nil {
+		return m.db.ClientRules()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rules != nil && time.Since(c.loadedAt) < clientRulesTTL {
+		return c.rules, nil
+	}
+
+	rules, err := m.db.ClientRules()
+	if err != nil {
+		return nil, err
+	}
+
+	c.rules = rules
+	c.loadedAt = time.Now()
+
+	return rules, nil
+}
+
+func (c *clientRuleCache) reset() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = nil
+}
+
+// routeHasPrefix reports whether the path is the prefix or below it
+func routeHasPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// userAgentVersion returns the version of the product in the user agent
+// (i.e. 3.1.0 of "shipyard-cli/3.1.0 go1.6"); products are matched
+// ignoring case
+func userAgentVersion(userAgent, product string) (string, bool) {
+	for _, field := range strings.Fields(userAgent) {
+		parts := strings.SplitN(field, "/", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], product) {
+			return strings.TrimPrefix(parts[1], "v"), true
+		}
+	}
+
+	return "", false
+}
+
+// versionOlder compares the numeric parts of dotted versions; suffixes like
+// -rc1 are ignored and versions without numbers are never older
+func versionOlder(version, min string) bool {
+	v := versionParts(version)
+	if v == nil {
+		return false
+	}
+	minParts := versionParts(min)
+
+	for i := 0; i < len(v) || i < len(minParts); i++ {
+		a, b := 0, 0
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(minParts) {
+			b = minParts[i]
+		}
+
+		if a != b {
+			return a < b
+		}
+	}
+
+	return false
+}
+
+func versionParts(version string) []int {
+	match := clientVersion.FindString(version)
+	if match == "" {
+		return nil
+	}
+
+	parts := []int{}
+	for _, p := range strings.Split(match, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, n)
+	}
+
+	return parts
+}
@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestVersionOlder(t *testing.T) {
+	for _, c := range []struct {
+		version, min string
+		older        bool
+	}{
+		{"3.0.9", "3.1.0", true},
+		{"3.1", "3.1.0", false},
+		{"3.1.0-rc1", "3.1.0", false},
+		{"3.10.0", "3.9.2", false},
+		{"2", "3.0.0", true},
+		{"dev", "3.0.0", false},
+	} {
+		if older := versionOlder(c.version, c.min); older != c.older {
+			t.Errorf("versionOlder(%q, %q): expected %v; received %v", c.version, c.min, c.older, older)
+		}
+	}
+}
+
+func TestUserAgentVersion(t *testing.T) {
+	version, ok := userAgentVersion("Shipyard-CLI/v3.1.0 go1.6 (linux)", "shipyard-cli")
+	if !ok || version != "3.1.0" {
+		t.Fatalf("expected 3.1.0; received %q %v", version, ok)
+	}
+
+	if _, ok := userAgentVersion("Go-http-client/1.1", "shipyard-cli"); ok {
+		t.Fatal("expected no version for another client")
+	}
+}
+
+func TestCheckClient(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if err := m.db.SaveClientRule(&shipyard.ClientRule{Client: "shipyard-cli", MinVersion: "3.1.0", RoutePrefix: "/api/stacks"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.clientRules = &clientRuleCache{}
+
+	for _, c := range []struct {
+		userAgent, path string
+		refused         bool
+	}{
+		{"shipyard-cli/3.0.0", "/api/stacks", true},
+		{"shipyard-cli/3.0.0", "/api/stacks/web/redeploy", true},
+		{"shipyard-cli/3.0.0", "/api/v1/stacks", true},
+		{"shipyard-cli/3.0.0", "/api/stacksets", false},
+		{"shipyard-cli/3.0.0", "/api/events", false},
+		{"shipyard-cli/3.1.0", "/api/stacks", false},
+		{"docker/1.10.3", "/api/stacks", false},
+	} {
+		err := m.CheckClient(c.userAgent, c.path)
+		if _, refused := err.(*ClientError); refused != c.refused {
+			t.Errorf("%s %s: expected refused %v; received %v", c.userAgent, c.path, c.refused, err)
+		}
+	}
+}
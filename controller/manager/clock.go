@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	defaultClockSkewThreshold = 2 * time.Second
+)
+
+// nodeClock compares the system time reported by an engine with the
+// midpoint of the request to it; engines before API 1.21 do not report
+// their time and return nil
+func nodeClock(systemTime string, sent, received time.Time, threshold time.Duration) (*shipyard.NodeClock, error) {
+	if systemTime == "" {
+		return nil, nil
+	}
+
+	engineTime, err := time.Parse(time.RFC3339Nano, systemTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system time %q: %s", systemTime, err)
+	}
+
+	uncertainty := received.Sub(sent) / 2
+	skew := engineTime.Sub(sent.Add(uncertainty))
+
+	return &shipyard.NodeClock{
+		Skew:        skew.Seconds(),
+		Uncertainty: uncertainty.Seconds(),
+		Skewed:      math.Abs(skew.Seconds())-uncertainty.Seconds() > threshold.Seconds(),
+		CheckedAt:   received,
+	}, nil
+}
+
+// skewThreshold returns the configured clock skew threshold or the default
+func (m DefaultManager) skewThreshold() time.Duration {
+	if m.clockSkewThreshold == 0 {
+		return defaultClockSkewThreshold
+	}
+
+	return m.clockSkewThreshold
+}
+
+// checkClockSkew raises an alert for every node whose clock is off by more
+// than the threshold
+func (m DefaultManager) checkClockSkew() error {
+	nodes, err := m.Nodes()
+	if err != nil {
+		return err
+	}
+
+	skewed := map[string]bool{}
+	for _, n := range nodes {
+		if n.Clock == nil || !n.Clock.Skewed {
+			continue
+		}
+
+		skewed[n.Name] = true
+		m.raiseAlert(&shipyard.Alert{
+			Type:     shipyard.AlertClockSkew,
+			Subject:  n.Name,
+			Severity: notification.SeverityWarning,
+			Message:  fmt.Sprintf("the clock of node %s (%s) is off by %.1fs; the threshold is %s", n.Name, n.Addr, n.Clock.Skew, m.skewThreshold()),
+			Notes:    m.notesFor(shipyard.NoteNode, n.Name),
+		})
+	}
+
+	return m.resolveAlerts(shipyard.AlertClockSkew, skewed)
+}
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeClock(t *testing.T) {
+	sent := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+	received := sent.Add(200 * time.Millisecond)
+
+	tests := []struct {
+		systemTime string
+		skew       float64
+		skewed     bool
+	}{
+		// the midpoint of the request
+		{"2016-01-02T15:04:05.1Z", 0, false},
+		{"2016-01-02T15:04:07.1Z", 2, false},
+		{"2016-01-02T15:04:08.5Z", 3.4, true},
+		{"2016-01-02T15:04:00Z", -5.1, true},
+		// the skew is only known within half the round trip
+		{"2016-01-02T15:04:07.2Z", 2.1, false},
+		{"2016-01-02T17:04:08.5+02:00", 3.4, true},
+	}
+
+	for _, test := range tests {
+		clock, err := nodeClock(test.systemTime, sent, received, 2*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := clock.Skew - test.skew; diff > 1e-9 || diff < -1e-9 || clock.Skewed != test.skewed {
+			t.Errorf("%s: expected skew %v (skewed %v); received %+v", test.systemTime, test.skew, test.skewed, clock)
+		}
+	}
+
+	if clock, err := nodeClock("", sent, received, time.Second); clock != nil || err != nil {
+		t.Fatalf("expected no clock for engines without a system time; received %+v %v", clock, err)
+	}
+
+	if _, err := nodeClock("yesterday", sent, received, time.Second); err == nil {
+		t.Fatal("expected an error for an invalid system time")
+	}
+}
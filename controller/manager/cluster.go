@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/utils"
+)
+
+var (
+	ErrDockerURLRequired = errors.New("docker url is required")
+)
+
+type (
+	// ClusterSettings is the swarm (or docker) endpoint the controller
+	// manages; tls material is pem encoded and never returned
+	ClusterSettings struct {
+		DockerURL     string `json:"docker_url,omitempty"`
+		TLSCACert     string `json:"tls_ca_cert,omitempty"`
+		TLSCert       string `json:"tls_cert,omitempty"`
+		TLSKey        string `json:"tls_key,omitempty"`
+		AllowInsecure bool   `json:"allow_insecure,omitempty"`
+		TLS           bool   `json:"tls"`
+	}
+
+	// clusterClient holds the docker client so it can be replaced while
+	// the controller is running; updated is the time of the stored
+	// endpoint the client was created for
+	clusterClient struct {
+		mu      sync.RWMutex
+		client  *dockerclient.DockerClient
+		updated time.Time
+	}
+)
+
+func (c *clusterClient) get() *dockerclient.DockerClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.client
+}
+
+func (c *clusterClient) set(client *dockerclient.DockerClient, updated time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.client = client
+	c.updated = updated
+}
+
+func (c *clusterClient) updatedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.updated
+}
+
+// loadClusterEndpoint switches to the endpoint of the default cluster
+// stored in the datastore when it changed; the controllers load it on
+// startup and with each heartbeat so they follow an update made on
+// another controller
+func (m DefaultManager) loadClusterEndpoint() error {
+	endpoint, err := m.db.ClusterEndpoint()
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if endpoint.UpdatedAt.Equal(m.client.updatedAt()) {
+		return nil
+	}
+
+	client, err := utils.NewClient(endpoint.DockerURL, []byte(endpoint.TLSCACert), []byte(endpoint.TLSCert), []byte(endpoint.TLSKey), endpoint.AllowInsecure)
+	if err != nil {
+		return err
+	}
+
+	m.client.set(client, endpoint.UpdatedAt)
+
+	log.Infof("cluster endpoint loaded: url=%s tls=%v updated_by=%s", client.URL, client.TLSConfig != nil, endpoint.UpdatedBy)
+
+	return nil
+}
+
+// ClusterSettings returns the current cluster endpoint
+func (m DefaultManager) ClusterSettings() *ClusterSettings {
+	client := m.DockerClient()
+
+	return &ClusterSettings{
+		DockerURL: client.URL.String(),
+		TLS:       client.TLSConfig != nil,
+	}
+}
+
+// UpdateCluster replaces the docker client with one for the new endpoint;
+// the endpoint must be reachable before it is used. It is stored so it
+// survives a restart and the other controllers switch to it as well.
+func (m DefaultManager) UpdateCluster(settings *ClusterSettings, username string) error {
+	if settings.DockerURL == "" {
+		return ErrDockerURLRequired
+	}
+
+	client, err := utils.NewClient(settings.DockerURL, []byte(settings.TLSCACert), []byte(settings.TLSCert), []byte(settings.TLSKey), settings.AllowInsecure)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Info(); err != nil {
+		return fmt.Errorf("unable to reach %s: %s", settings.DockerURL, err)
+	}
+
+	endpoint := &shipyard.ClusterEndpoint{
+		DockerURL:     settings.DockerURL,
+		TLSCACert:     settings.TLSCACert,
+		TLSCert:       settings.TLSCert,
+		TLSKey:        settings.TLSKey,
+		AllowInsecure: settings.AllowInsecure,
+		UpdatedBy:     username,
+		UpdatedAt:     time.Now(),
+	}
+
+	if err := m.db.SaveClusterEndpoint(endpoint); err != nil {
+		return err
+	}
+
+	m.client.set(client, endpoint.UpdatedAt)
+
+	log.Infof("cluster endpoint changed: url=%s tls=%v", client.URL, client.TLSConfig != nil)
+	m.logEvent("update-cluster", fmt.Sprintf("username=%s url=%s tls=%v", username, client.URL, client.TLSConfig != nil), []string{"security", "cluster"})
+
+	return nil
+}
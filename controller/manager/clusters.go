@@ -0,0 +1,250 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/utils"
+)
+
+const (
+	tblNameClusters = "clusters"
+)
+
+var (
+	ErrClusterDoesNotExist = errors.New("cluster does not exist")
+	ErrClusterExists       = errors.New("cluster already exists")
+	ErrInvalidClusterName  = errors.New("cluster names can only contain lowercase letters, digits, dashes and underscores")
+	ErrDefaultCluster      = errors.New("the default cluster is changed with the cluster settings")
+
+	clusterName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+)
+
+// ClusterUnreachableError is returned when the endpoint of a cluster
+// cannot be reached as it is added or changed
+type ClusterUnreachableError struct {
+	Cluster string
+	Err     error
+}
+
+func (e *ClusterUnreachableError) Error() string {
+	return fmt.Sprintf("unable to reach cluster %s: %s", e.Cluster, e.Err)
+}
+
+type (
+	// clusterViews are the clients of the clusters besides the default
+	// one; they are created when a cluster is first selected and
+	// dropped when it changes
+	clusterViews struct {
+		mu    sync.Mutex
+		views map[string]*clusterView
+	}
+
+	clusterView struct {
+		client    *clusterClient
+		inventory *nodeInventory
+	}
+)
+
+func newClusterViews() *clusterViews {
+	return &clusterViews{
+		views: map[string]*clusterView{},
+	}
+}
+
+func (c *clusterViews) get(name string, create func() (*dockerclient.DockerClient, error)) (*clusterView, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.views[name]; ok {
+		return v, nil
+	}
+
+	client, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &clusterView{
+		client:    &clusterClient{client: client},
+		inventory: newNodeInventory(),
+	}
+	c.views[name] = v
+
+	return v, nil
+}
+
+func (c *clusterViews) drop(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.views, name)
+}
+
+func clusterDockerClient(cluster *shipyard.Cluster) (*dockerclient.DockerClient, error) {
+	return utils.NewClient(cluster.DockerURL, []byte(cluster.TLSCACert), []byte(cluster.TLSCert), []byte(cluster.TLSKey), cluster.AllowInsecure)
+}
+
+func isDefaultCluster(name string) bool {
+	return name == "" || name == shipyard.DefaultClusterName
+}
+
+// defaultCluster describes the cluster the controller was started with
+func (m DefaultManager) defaultCluster() *shipyard.Cluster {
+	return &shipyard.Cluster{
+		Name:      shipyard.DefaultClusterName,
+		DockerURL: m.DockerClient().URL.String(),
+	}
+}
+
+// Clusters returns the default cluster followed by the added ones
+func (m DefaultManager) Clusters() ([]*shipyard.Cluster, error) {
+	clusters, err := m.db.Clusters()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*shipyard.Cluster{m.defaultCluster()}, clusters...), nil
+}
+
+func (m DefaultManager) Cluster(name string) (*shipyard.Cluster, error) {
+	if isDefaultCluster(name) {
+		return m.defaultCluster(), nil
+	}
+
+	cluster, err := m.db.Cluster(name)
+	if err != nil {
+		return nil, notFound(err, ErrClusterDoesNotExist)
+	}
+
+	return cluster, nil
+}
+
+// checkCluster validates the cluster and checks its endpoint is reachable
+func checkCluster(cluster *shipyard.Cluster) error {
+	if isDefaultCluster(cluster.Name) {
+		return ErrDefaultCluster
+	}
+
+	if !clusterName.MatchString(cluster.Name) {
+		return ErrInvalidClusterName
+	}
+
+	if cluster.DockerURL == "" {
+		return ErrDockerURLRequired
+	}
+
+	client, err := clusterDockerClient(cluster)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Info(); err != nil {
+		return &ClusterUnreachableError{Cluster: cluster.Name, Err: err}
+	}
+
+	return nil
+}
+
+// AddCluster adds a cluster the controller manages; its endpoint must be
+// reachable before it is added
+func (m DefaultManager) AddCluster(cluster *shipyard.Cluster, username string) error {
+	if err := checkCluster(cluster); err != nil {
+		return err
+	}
+
+	if _, err := m.db.Cluster(cluster.Name); err == nil {
+		return ErrClusterExists
+	} else if err != datastore.ErrNotFound {
+		return err
+	}
+
+	cluster.CreatedBy = username
+	cluster.CreatedAt = time.Now()
+	cluster.UpdatedBy = ""
+	cluster.UpdatedAt = time.Time{}
+
+	if err := m.db.SaveCluster(cluster); err != nil {
+		return err
+	}
+
+	m.logEvent("add-cluster", fmt.Sprintf("name=%s url=%s username=%s", cluster.Name, cluster.DockerURL, username), []string{"security", "cluster"})
+
+	return nil
+}
+
+// ReplaceCluster changes the endpoint of an added cluster; requests
+// selecting the cluster use the new endpoint from then on
+func (m DefaultManager) ReplaceCluster(cluster *shipyard.Cluster, username string) error {
+	if err := checkCluster(cluster); err != nil {
+		return err
+	}
+
+	current, err := m.db.Cluster(cluster.Name)
+	if err != nil {
+		return notFound(err, ErrClusterDoesNotExist)
+	}
+
+	cluster.CreatedBy = current.CreatedBy
+	cluster.CreatedAt = current.CreatedAt
+	cluster.UpdatedBy = username
+	cluster.UpdatedAt = time.Now()
+
+	if err := m.db.SaveCluster(cluster); err != nil {
+		return err
+	}
+	m.clusters.drop(cluster.Name)
+
+	m.logEvent("replace-cluster", fmt.Sprintf("name=%s url=%s username=%s", cluster.Name, cluster.DockerURL, username), []string{"security", "cluster"})
+
+	return nil
+}
+
+// RemoveCluster removes an added cluster; its containers are left running
+func (m DefaultManager) RemoveCluster(name, username string) error {
+	if isDefaultCluster(name) {
+		return ErrDefaultCluster
+	}
+
+	if err := m.db.DeleteCluster(name); err != nil {
+		return notFound(err, ErrClusterDoesNotExist)
+	}
+	m.clusters.drop(name)
+
+	m.logEvent("remove-cluster", fmt.Sprintf("name=%s username=%s", name, username), []string{"security", "cluster"})
+
+	return nil
+}
+
+// ForCluster returns the manager with the docker client and node
+// inventory of the cluster; the default cluster is the manager itself.
+// Everything else, including the datastore, is shared by the clusters.
+func (m DefaultManager) ForCluster(name string) (Manager, error) {
+	if isDefaultCluster(name) {
+		return m, nil
+	}
+
+	view, err := m.clusters.get(name, func() (*dockerclient.DockerClient, error) {
+		cluster, err := m.db.Cluster(name)
+		if err != nil {
+			return nil, notFound(err, ErrClusterDoesNotExist)
+		}
+
+		return clusterDockerClient(cluster)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v := m
+	v.client = view.client
+	v.inventory = view.inventory
+
+	return v, nil
+}
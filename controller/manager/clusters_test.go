@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+)
+
+// getClusterManager returns a manager on a default engine and a second
+// engine to add as a cluster
+func getClusterManager(t *testing.T) (DefaultManager, string, func()) {
+	newEngine := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(dockerclient.Info{Name: name})
+		}))
+	}
+
+	engine := newEngine("default")
+	staging := newEngine("staging")
+
+	m, closeStore := newTestManager(t)
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		engine.Close()
+		staging.Close()
+		closeStore()
+	}
+
+	m.client = &clusterClient{client: client}
+	m.clusters = newClusterViews()
+
+	return m, staging.URL, cleanup
+}
+
+func TestAddCluster(t *testing.T) {
+	m, url, cleanup := getClusterManager(t)
+	defer cleanup()
+
+	for c, expected := range map[*shipyard.Cluster]error{
+		{Name: shipyard.DefaultClusterName, DockerURL: url}: ErrDefaultCluster,
+		{Name: "Staging", DockerURL: url}:                   ErrInvalidClusterName,
+		{Name: "staging"}:                                   ErrDockerURLRequired,
+	} {
+		if err := m.AddCluster(c, "admin"); err != expected {
+			t.Fatalf("expected %v for %+v; received %v", expected, c, err)
+		}
+	}
+
+	if err := m.AddCluster(&shipyard.Cluster{Name: "staging", DockerURL: "http://127.0.0.1:1"}, "admin"); err == nil {
+		t.Fatal("expected an unreachable cluster not to be added")
+	} else if _, ok := err.(*ClusterUnreachableError); !ok {
+		t.Fatalf("expected a ClusterUnreachableError; received %v", err)
+	}
+
+	if err := m.AddCluster(&shipyard.Cluster{Name: "staging", DockerURL: url}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.AddCluster(&shipyard.Cluster{Name: "staging", DockerURL: url}, "admin"); err != ErrClusterExists {
+		t.Fatalf("expected ErrClusterExists; received %v", err)
+	}
+
+	clusters, err := m.Clusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clusters) != 2 || clusters[0].Name != shipyard.DefaultClusterName || clusters[1].CreatedBy != "admin" {
+		t.Fatalf("expected the default and staging clusters; received %+v", clusters)
+	}
+}
+
+func TestForCluster(t *testing.T) {
+	m, url, cleanup := getClusterManager(t)
+	defer cleanup()
+
+	if _, err := m.ForCluster("staging"); err != ErrClusterDoesNotExist {
+		t.Fatalf("expected ErrClusterDoesNotExist; received %v", err)
+	}
+
+	if err := m.AddCluster(&shipyard.Cluster{Name: "staging", DockerURL: url}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := m.ForCluster("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := staging.DockerClient().Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Name != "staging" {
+		t.Fatalf("expected the staging engine; received %s", info.Name)
+	}
+
+	if def, _ := m.ForCluster(""); def.DockerClient() != m.DockerClient() {
+		t.Fatal("expected the default cluster to be the manager")
+	}
+
+	// the client is recreated once the cluster changes
+	if err := m.ReplaceCluster(&shipyard.Cluster{Name: "staging", DockerURL: url}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	replaced, err := m.ForCluster("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replaced.DockerClient() == staging.DockerClient() {
+		t.Fatal("expected a new client for the replaced cluster")
+	}
+
+	if err := m.RemoveCluster("staging", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.ForCluster("staging"); err != ErrClusterDoesNotExist {
+		t.Fatalf("expected ErrClusterDoesNotExist once removed; received %v", err)
+	}
+
+	if err := m.RemoveCluster(shipyard.DefaultClusterName, "admin"); err != ErrDefaultCluster {
+		t.Fatalf("expected ErrDefaultCluster; received %v", err)
+	}
+}
+
+func TestUpdateClusterShared(t *testing.T) {
+	m, url, cleanup := getClusterManager(t)
+	defer cleanup()
+
+	// another controller on the same datastore
+	other := m
+	other.client = &clusterClient{client: m.DockerClient()}
+
+	if err := m.UpdateCluster(&ClusterSettings{DockerURL: url}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.loadClusterEndpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	if other.DockerClient().URL.String() != m.DockerClient().URL.String() {
+		t.Fatalf("expected the other controller to use %s; received %s", m.DockerClient().URL, other.DockerClient().URL)
+	}
+
+	// an unchanged endpoint keeps the client
+	client := other.DockerClient()
+	if err := other.loadClusterEndpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	if other.DockerClient() != client {
+		t.Fatal("expected the client to be kept")
+	}
+}
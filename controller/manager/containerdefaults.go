@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/notification"
+)
+
+var (
+	ErrContainerDefaultsNotSet  = errors.New("no container defaults are set")
+	ErrInvalidContainerDefaults = errors.New("env var names cannot be empty or contain = or spaces and label names cannot be empty")
+)
+
+// ContainerDefaults returns the env vars and labels added to the containers
+// of stacks and templates
+func (m DefaultManager) ContainerDefaults() (*shipyard.ContainerDefaults, error) {
+	defaults, err := m.db.ContainerDefaults()
+	if err != nil {
+		return nil, notFound(err, ErrContainerDefaultsNotSet)
+	}
+
+	return defaults, nil
+}
+
+// SetContainerDefaults replaces the container defaults; containers get them
+// when their stack is next deployed or redeployed
+func (m DefaultManager) SetContainerDefaults(defaults *shipyard.ContainerDefaults, username string) (*shipyard.ContainerDefaults, error) {
+	valid := validDefaults(defaults.Env, defaults.Labels)
+	for _, o := range defaults.Environments {
+		valid = valid && o != nil && validDefaults(o.Env, o.Labels)
+	}
+	if !valid {
+		return nil, ErrInvalidContainerDefaults
+	}
+
+	defaults.UpdatedBy = username
+	defaults.UpdatedAt = time.Now()
+
+	if err := m.db.SaveContainerDefaults(defaults); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("set-container-defaults", fmt.Sprintf("env=%d labels=%d environments=%d username=%s", len(defaults.Env), len(defaults.Labels), len(defaults.Environments), username), []string{"stack"})
+
+	return defaults, nil
+}
+
+// RemoveContainerDefaults removes the container defaults; running
+// containers keep them until they are recreated
+func (m DefaultManager) RemoveContainerDefaults(username string) error {
+	if err := m.db.DeleteContainerDefaults(); err != nil {
+		return notFound(err, ErrContainerDefaultsNotSet)
+	}
+
+	m.logEvent("remove-container-defaults", fmt.Sprintf("username=%s", username), []string{"stack"})
+
+	return nil
+}
+
+func validDefaults(env, labels map[string]string) bool {
+	for name := range env {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return false
+		}
+	}
+
+	for name := range labels {
+		if strings.TrimSpace(name) == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containerDefaults returns the container defaults or nil when none are set
+func (m DefaultManager) containerDefaults() (*shipyard.ContainerDefaults, error) {
+	defaults, err := m.db.ContainerDefaults()
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+
+	return defaults, err
+}
+
+// applyContainerDefaults adds the defaults, with the overrides of the
+// environment of the container, to the env vars and labels of the config
+// which it does not set itself or exclude with LabelDefaultsExclude
+func applyContainerDefaults(config *dockerclient.ContainerConfig, defaults *shipyard.ContainerDefaults) {
+	if defaults == nil {
+		return
+	}
+
+	excluded := map[string]bool{}
+	for _, name := range strings.Split(config.Labels[shipyard.LabelDefaultsExclude], ",") {
+		excluded[strings.TrimSpace(name)] = true
+	}
+	if excluded["*"] {
+		return
+	}
+
+	env := map[string]string{}
+	labels := map[string]string{}
+	for _, values := range []*shipyard.ContainerDefaultsOverride{
+		{Env: defaults.Env, Labels: defaults.Labels},
+		defaults.Environments[config.Labels[notification.LabelEnvironment]],
+	} {
+		if values == nil {
+			continue
+		}
+		for k, v := range values.Env {
+			env[k] = v
+		}
+		for k, v := range values.Labels {
+			labels[k] = v
+		}
+	}
+
+	set := map[string]bool{}
+	for _, e := range config.Env {
+		set[strings.SplitN(e, "=", 2)[0]] = true
+	}
+
+	names := []string{}
+	for name := range env {
+		if !set[name] && !excluded[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		config.Env = append(config.Env, name+"="+env[name])
+	}
+
+	if config.Labels == nil {
+		config.Labels = map[string]string{}
+	}
+	for name, value := range labels {
+		if _, ok := config.Labels[name]; !ok && !excluded[name] {
+			config.Labels[name] = value
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/notification"
+)
+
+func getTestContainerDefaults() *shipyard.ContainerDefaults {
+	return &shipyard.ContainerDefaults{
+		Env:    map[string]string{"HTTP_PROXY": "http://proxy.local", "TZ": "UTC"},
+		Labels: map[string]string{"team": "platform"},
+		Environments: map[string]*shipyard.ContainerDefaultsOverride{
+			"production": {
+				Env:    map[string]string{"HTTP_PROXY": "http://proxy.prod.local"},
+				Labels: map[string]string{"tier": "prod"},
+			},
+		},
+	}
+}
+
+func TestApplyContainerDefaults(t *testing.T) {
+	for _, c := range []struct {
+		labels map[string]string
+		env    []string
+		expEnv []string
+		expTag string
+	}{
+		// the cluster defaults
+		{nil, nil, []string{"HTTP_PROXY=http://proxy.local", "TZ=UTC"}, "platform"},
+		// the environment overrides the cluster
+		{
+			map[string]string{notification.LabelEnvironment: "production"},
+			nil,
+			[]string{"HTTP_PROXY=http://proxy.prod.local", "TZ=UTC"},
+			"platform",
+		},
+		// the service overrides both
+		{
+			map[string]string{notification.LabelEnvironment: "production", "team": "web"},
+			[]string{"TZ=Europe/Paris"},
+			[]string{"TZ=Europe/Paris", "HTTP_PROXY=http://proxy.prod.local"},
+			"web",
+		},
+		// named defaults are excluded
+		{
+			map[string]string{shipyard.LabelDefaultsExclude: "HTTP_PROXY, team"},
+			nil,
+			[]string{"TZ=UTC"},
+			"",
+		},
+		// all defaults are excluded
+		{
+			map[string]string{shipyard.LabelDefaultsExclude: "*"},
+			[]string{"A=b"},
+			[]string{"A=b"},
+			"",
+		},
+	} {
+		config := &dockerclient.ContainerConfig{Env: c.env, Labels: c.labels}
+		applyContainerDefaults(config, getTestContainerDefaults())
+
+		if !reflect.DeepEqual(config.Env, c.expEnv) {
+			t.Fatalf("expected env %v for labels %v; received %v", c.expEnv, c.labels, config.Env)
+		}
+
+		if config.Labels["team"] != c.expTag {
+			t.Fatalf("expected team %q for labels %v; received %q", c.expTag, c.labels, config.Labels["team"])
+		}
+	}
+
+	config := &dockerclient.ContainerConfig{Env: []string{"A=b"}}
+	applyContainerDefaults(config, nil)
+	if !reflect.DeepEqual(config.Env, []string{"A=b"}) {
+		t.Fatalf("expected no change without defaults; received %v", config.Env)
+	}
+}
+
+func TestSetContainerDefaults(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if _, err := m.ContainerDefaults(); err != ErrContainerDefaultsNotSet {
+		t.Fatalf("expected ErrContainerDefaultsNotSet; received %v", err)
+	}
+
+	for _, d := range []*shipyard.ContainerDefaults{
+		{Env: map[string]string{"A=B": "c"}},
+		{Labels: map[string]string{" ": "c"}},
+		{Environments: map[string]*shipyard.ContainerDefaultsOverride{"production": nil}},
+		{Environments: map[string]*shipyard.ContainerDefaultsOverride{"production": {Env: map[string]string{"": "c"}}}},
+	} {
+		if _, err := m.SetContainerDefaults(d, "admin"); err != ErrInvalidContainerDefaults {
+			t.Fatalf("expected ErrInvalidContainerDefaults for %+v; received %v", d, err)
+		}
+	}
+
+	if _, err := m.SetContainerDefaults(getTestContainerDefaults(), "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults, err := m.ContainerDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if defaults.UpdatedBy != "admin" || defaults.Env["TZ"] != "UTC" {
+		t.Fatalf("expected the saved defaults; received %+v", defaults)
+	}
+
+	if err := m.RemoveContainerDefaults("admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RemoveContainerDefaults("admin"); err != ErrContainerDefaultsNotSet {
+		t.Fatalf("expected ErrContainerDefaultsNotSet; received %v", err)
+	}
+}
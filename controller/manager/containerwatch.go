@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	// Actions of a container delta
+	ContainerCreated   = "create"
+	ContainerStarted   = "start"
+	ContainerDied      = "die"
+	ContainerDestroyed = "destroy"
+	// ContainerResync is sent when the docker events reconnect; events
+	// may have been missed so clients have to reload the containers
+	ContainerResync = "resync"
+
+	// containerWatchRetry is how long the watcher waits before
+	// reconnecting to the docker events
+	containerWatchRetry = 5 * time.Second
+	// containerWatchBuffer is how many deltas a watcher can fall behind
+	// before it is closed
+	containerWatchBuffer = 64
+)
+
+// ContainerDelta is a change of the container list; Container is the
+// container as listed by /containers/json and is not set for destroy
+type ContainerDelta struct {
+	Action    string                  `json:"action"`
+	ID        string                  `json:"id,omitempty"`
+	Container *dockerclient.Container `json:"container,omitempty"`
+	Time      time.Time               `json:"time"`
+}
+
+// containerWatchers are the channels the container deltas are sent to
+type containerWatchers struct {
+	mu       sync.Mutex
+	watchers map[chan *ContainerDelta]struct{}
+}
+
+func newContainerWatchers() *containerWatchers {
+	return &containerWatchers{
+		watchers: map[chan *ContainerDelta]struct{}{},
+	}
+}
+
+func (w *containerWatchers) add() chan *ContainerDelta {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	c := make(chan *ContainerDelta, containerWatchBuffer)
+	w.watchers[c] = struct{}{}
+
+	return c
+}
+
+func (w *containerWatchers) remove(c chan *ContainerDelta) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.watchers[c]; ok {
+		delete(w.watchers, c)
+		close(c)
+	}
+}
+
+func (w *containerWatchers) len() int {
+	if w == nil {
+		return 0
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.watchers)
+}
+
+// send sends the delta to every watcher; watchers which fell too far
+// behind are closed rather than blocking the others
+func (w *containerWatchers) send(d *ContainerDelta) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for c := range w.watchers {
+		select {
+		case c <- d:
+		default:
+			delete(w.watchers, c)
+			close(c)
+		}
+	}
+}
+
+// WatchContainers returns the changes of the container list as they
+// happen until done is closed; the channel is also closed when the reader
+// falls behind, after which the containers have to be reloaded
+func (m DefaultManager) WatchContainers(done <-chan struct{}) <-chan *ContainerDelta {
+	c := m.containerWatchers.add()
+
+	go func() {
+		<-done
+		m.containerWatchers.remove(c)
+	}()
+
+	return c
+}
+
+// containerWatcher follows the docker events and sends the changes of
+// containers to the watchers; it reconnects when the events end
+func (m DefaultManager) containerWatcher() {
+	for {
+		stop := make(chan struct{})
+		events, err := m.DockerClient().MonitorEvents(nil, stop)
+		if err != nil {
+			log.Errorf("error watching docker events: %s", err)
+			close(stop)
+			time.Sleep(containerWatchRetry)
+			continue
+		}
+
+		m.containerWatchers.send(&ContainerDelta{Action: ContainerResync, Time: time.Now()})
+
+		for e := range events {
+			if e.Error != nil {
+				log.Debugf("docker events ended: %s", e.Error)
+				break
+			}
+
+			m.handleContainerEvent(&e.Event)
+		}
+
+		close(stop)
+		go func() {
+			for range events {
+			}
+		}()
+
+		time.Sleep(containerWatchRetry)
+	}
+}
+
+// handleContainerEvent sends the delta of a create, start, die or destroy
+// event; the container is only listed when someone watches
+func (m DefaultManager) handleContainerEvent(e *dockerclient.Event) {
+	// events of older engines have no type and the action as status
+	if e.Type != "" && e.Type != "container" {
+		return
+	}
+
+	action := e.Action
+	if action == "" {
+		action = e.Status
+	}
+
+	switch action {
+	case ContainerCreated, ContainerStarted, ContainerDied, ContainerDestroyed:
+	default:
+		return
+	}
+
+	if m.containerWatchers.len() == 0 {
+		return
+	}
+
+	d := &ContainerDelta{
+		Action: action,
+		ID:     e.ID,
+		Time:   time.Unix(e.Time, 0),
+	}
+
+	if action != ContainerDestroyed {
+		filters := fmt.Sprintf(`{"id":["%s"]}`, e.ID)
+		containers, err := m.DockerClient().ListContainers(true, false, url.QueryEscape(filters))
+		if err != nil {
+			log.Errorf("error listing container %s: %s", e.ID, err)
+		} else if len(containers) > 0 {
+			d.Container = &containers[0]
+		}
+	}
+
+	m.containerWatchers.send(d)
+}
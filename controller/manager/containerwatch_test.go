@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+)
+
+func TestHandleContainerEvent(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/json") {
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode([]dockerclient.Container{{Id: "abc", Names: []string{"/web"}, Status: "Up 1 second"}})
+	}))
+	defer engine.Close()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultManager{client: &clusterClient{client: client}, containerWatchers: newContainerWatchers()}
+
+	done := make(chan struct{})
+	deltas := m.WatchContainers(done)
+
+	m.handleContainerEvent(&dockerclient.Event{Type: "container", Action: "start", ID: "abc"})
+	// older engines send the action as status
+	m.handleContainerEvent(&dockerclient.Event{Status: "destroy", ID: "abc"})
+	// other events are ignored
+	m.handleContainerEvent(&dockerclient.Event{Type: "container", Action: "exec_start", ID: "abc"})
+	m.handleContainerEvent(&dockerclient.Event{Type: "network", Action: "create", ID: "net"})
+
+	d := <-deltas
+	if d.Action != ContainerStarted || d.Container == nil || d.Container.Names[0] != "/web" {
+		t.Fatalf("expected the started container; received %+v", d)
+	}
+
+	d = <-deltas
+	if d.Action != ContainerDestroyed || d.ID != "abc" || d.Container != nil {
+		t.Fatalf("expected the destroyed container; received %+v", d)
+	}
+
+	close(done)
+	for d := range deltas {
+		t.Fatalf("expected no other delta; received %+v", d)
+	}
+}
+
+func TestContainerWatchersSlowWatcher(t *testing.T) {
+	w := newContainerWatchers()
+	slow := w.add()
+
+	for i := 0; i <= containerWatchBuffer; i++ {
+		w.send(&ContainerDelta{Action: ContainerStarted})
+	}
+
+	if w.len() != 0 {
+		t.Fatal("expected the slow watcher to be removed")
+	}
+
+	n := 0
+	for range slow {
+		n++
+	}
+
+	if n != containerWatchBuffer {
+		t.Fatalf("expected the buffered deltas before the channel closes; received %d", n)
+	}
+}
@@ -0,0 +1,234 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/version"
+	r "gopkg.in/dancannon/gorethink.v2"
+)
+
+const (
+	tblNameControllers          = "controllers"
+	tblNameLeases               = "leases"
+	controllerHeartbeatInterval = 10 * time.Second
+	// controllers without a heartbeat for this long are down
+	controllerHeartbeatTimeout = 3 * controllerHeartbeatInterval
+	// down controllers are forgotten after this long
+	controllerExpiry = 24 * time.Hour
+	// leaderLease is held by the controller running the background tasks;
+	// it is renewed with each heartbeat and taken over by another
+	// controller once it expires
+	leaderLease = "leader"
+)
+
+// lease is held by a single controller until it expires or is released
+type lease struct {
+	ID      string    `gorethink:"id"`
+	Holder  string    `gorethink:"holder"`
+	Expires time.Time `gorethink:"expires"`
+}
+
+// leaderFlag is whether this controller holds the leader lease; it is
+// shared by the copies of the manager
+type leaderFlag struct {
+	leading int32
+}
+
+func (f *leaderFlag) set(leading bool) (changed bool) {
+	v := int32(0)
+	if leading {
+		v = 1
+	}
+
+	return atomic.SwapInt32(&f.leading, v) != v
+}
+
+func (f *leaderFlag) get() bool {
+	return atomic.LoadInt32(&f.leading) == 1
+}
+
+// acquireLease takes the lease when it is free or expired, or renews it
+// when this controller holds it, for ttl; the replace is atomic so only
+// one controller gets an expired lease. Without rethinkdb the controller
+// runs alone and always holds it.
+func (m DefaultManager) acquireLease(name string, ttl time.Duration) (bool, error) {
+	if m.session == nil {
+		return true, nil
+	}
+
+	res, err := r.Table(tblNameLeases).Get(name).Replace(func(row r.Term) interface{} {
+		return r.Branch(
+			row.Eq(nil).Or(row.Field("holder").Eq(m.controllerID)).Or(row.Field("expires").Lt(r.Now())),
+			map[string]interface{}{
+				"id":      name,
+				"holder":  m.controllerID,
+				"expires": r.Now().Add(ttl.Seconds()),
+			},
+			row,
+		)
+	}).RunWrite(m.session)
+	if err != nil {
+		return false, err
+	}
+
+	return res.Inserted+res.Replaced > 0, nil
+}
+
+// releaseLease gives up the lease if this controller holds it
+func (m DefaultManager) releaseLease(name string) error {
+	if m.session == nil {
+		return nil
+	}
+
+	_, err := r.Table(tblNameLeases).Get(name).Replace(func(row r.Term) interface{} {
+		return r.Branch(row.Ne(nil).And(row.Field("holder").Eq(m.controllerID)), nil, row)
+	}).RunWrite(m.session)
+
+	return err
+}
+
+// leaseHolder returns the controller holding the lease; empty when it is
+// free or expired
+func (m DefaultManager) leaseHolder(name string) (string, error) {
+	res, err := r.Table(tblNameLeases).Get(name).Run(m.session)
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	if res.IsNil() {
+		return "", nil
+	}
+
+	var l *lease
+	if err := res.One(&l); err != nil {
+		return "", err
+	}
+
+	if time.Now().After(l.Expires) {
+		return "", nil
+	}
+
+	return l.Holder, nil
+}
+
+// elect takes or renews the leader lease; the leader runs the background
+// tasks (jobs, housekeeping, alerts, escalations and anomaly detection)
+// so they are not repeated by every controller
+func (m DefaultManager) elect() {
+	leading, err := m.acquireLease(leaderLease, controllerHeartbeatTimeout)
+	if err != nil {
+		log.Errorf("error renewing the leader lease: %s", err)
+		// the lease may have expired; stop rather than run the tasks
+		// next to a new leader
+		leading = false
+	}
+
+	if !m.leader.set(leading) {
+		return
+	}
+
+	if leading {
+		log.Infof("controller %s is the leader", m.controllerID)
+		m.logEvent("controller-leader", fmt.Sprintf("controller=%s addr=%s", m.controllerID, m.controllerAddr), []string{"controller"})
+		return
+	}
+
+	log.Infof("controller %s is no longer the leader", m.controllerID)
+}
+
+// controllerHeartbeat registers this controller and keeps its heartbeat
+// current so peers sharing the datastore can see it
+func (m DefaultManager) controllerHeartbeat() {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Errorf("error getting hostname: %s", err)
+	}
+
+	c := &shipyard.Controller{
+		ID:       m.controllerID,
+		Hostname: hostname,
+		Addr:     m.controllerAddr,
+		Version:  version.Version,
+		Started:  time.Now(),
+	}
+
+	beat := func() {
+		c.LastHeartbeat = time.Now()
+		if _, err := r.Table(tblNameControllers).Insert(c, r.InsertOpts{Conflict: "replace"}).RunWrite(m.session); err != nil {
+			log.Errorf("error sending controller heartbeat: %s", err)
+		}
+
+		m.elect()
+
+		if err := m.loadClusterEndpoint(); err != nil {
+			log.Errorf("error loading the cluster endpoint: %s", err)
+		}
+
+		if _, err := r.Table(tblNameControllers).Filter(r.Row.Field("last_heartbeat").Lt(time.Now().Add(-controllerExpiry))).Delete().RunWrite(m.session); err != nil {
+			log.Errorf("error removing expired controllers: %s", err)
+		}
+	}
+
+	beat()
+	t := time.NewTicker(controllerHeartbeatInterval).C
+	for {
+		select {
+		case <-t:
+			beat()
+		}
+	}
+}
+
+// setControllerStatus sets the status of each controller from its
+// heartbeat and marks the holder of the leader lease
+func setControllerStatus(controllers []*shipyard.Controller, leaderID string, now time.Time) {
+	for _, c := range controllers {
+		c.Status = shipyard.ControllerStatusUp
+		if now.Sub(c.LastHeartbeat) > controllerHeartbeatTimeout {
+			c.Status = shipyard.ControllerStatusDown
+		}
+
+		c.Leader = c.Status == shipyard.ControllerStatusUp && c.ID == leaderID
+	}
+}
+
+// isLeader reports whether this controller holds the leader lease; a
+// controller without rethinkdb runs alone
+func (m DefaultManager) isLeader() bool {
+	if m.session == nil {
+		return true
+	}
+
+	return m.leader.get()
+}
+
+func (m DefaultManager) Controllers() ([]*shipyard.Controller, error) {
+	// nothing can be configured without rethinkdb
+	if m.session == nil {
+		return []*shipyard.Controller{}, nil
+	}
+
+	res, err := r.Table(tblNameControllers).OrderBy(r.Asc("started")).Run(m.session)
+	if err != nil {
+		return nil, err
+	}
+	controllers := []*shipyard.Controller{}
+	if err := res.All(&controllers); err != nil {
+		return nil, err
+	}
+
+	leaderID, err := m.leaseHolder(leaderLease)
+	if err != nil {
+		return nil, err
+	}
+
+	setControllerStatus(controllers, leaderID, time.Now())
+
+	return controllers, nil
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestSetControllerStatus(t *testing.T) {
+	now := time.Now()
+	controllers := []*shipyard.Controller{
+		{
+			ID:            "a",
+			Started:       now.Add(-2 * time.Hour),
+			LastHeartbeat: now.Add(-time.Hour),
+		},
+		{
+			ID:            "b",
+			Started:       now.Add(-time.Hour),
+			LastHeartbeat: now,
+		},
+		{
+			ID:            "c",
+			Started:       now.Add(-time.Minute),
+			LastHeartbeat: now,
+		},
+	}
+
+	setControllerStatus(controllers, "c", now)
+
+	if controllers[0].Status != shipyard.ControllerStatusDown {
+		t.Fatalf("expected controller a to be down; received %s", controllers[0].Status)
+	}
+
+	if controllers[0].Leader || controllers[1].Leader || !controllers[2].Leader {
+		t.Fatalf("expected controller c, the holder of the lease, to be the only leader")
+	}
+
+	// a down controller is not the leader even if its lease is current
+	setControllerStatus(controllers, "a", now)
+	for _, c := range controllers {
+		if c.Leader {
+			t.Fatalf("expected no leader; received %s", c.ID)
+		}
+	}
+}
+
+func TestLeaderFlag(t *testing.T) {
+	f := &leaderFlag{}
+	if f.get() {
+		t.Fatal("expected a new controller not to lead")
+	}
+
+	if !f.set(true) || !f.get() {
+		t.Fatal("expected the controller to become the leader")
+	}
+
+	if f.set(true) {
+		t.Fatal("expected renewing the lease not to be a change")
+	}
+
+	if !f.set(false) || f.get() {
+		t.Fatal("expected the controller to step down")
+	}
+}
+
+func TestIsLeaderWithoutRethinkDB(t *testing.T) {
+	if !(DefaultManager{}).isLeader() {
+		t.Fatal("expected a controller without rethinkdb to lead")
+	}
+
+	if ok, err := (DefaultManager{}).acquireLease("redeploy:nginx:latest", time.Minute); err != nil || !ok {
+		t.Fatalf("expected the lease; received %v %v", ok, err)
+	}
+}
@@ -0,0 +1,213 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	// LabelDebugTarget is the id of the container a debug container was
+	// launched for
+	LabelDebugTarget = "com.shipyard.debug.target"
+	// LabelDebugUser is the user who launched a debug container
+	LabelDebugUser = "com.shipyard.debug.user"
+
+	// DefaultDebugTTL is how long debug containers live unless asked for
+	DefaultDebugTTL = 30 * time.Minute
+	// MaxDebugTTL is the longest debug containers live
+	MaxDebugTTL = 4 * time.Hour
+)
+
+var (
+	ErrDebugDisabled         = errors.New("no debug images are approved; start the controller with --debug-image")
+	ErrDebugImageNotApproved = errors.New("the image is not an approved debug image")
+	ErrDebugTargetRequired   = errors.New("the container to debug is required")
+	ErrDebugTargetNotRunning = errors.New("the container to debug is not running")
+	ErrDebugNotAllowed       = errors.New("the container is outside the containers of the account")
+	ErrInvalidDebugTTL       = fmt.Errorf("debug containers live at most %s", MaxDebugTTL)
+)
+
+// DebugLaunch asks for a debug container; it joins the network namespace
+// of the container unless a network is given
+type DebugLaunch struct {
+	Container string `json:"container"`
+	// Image is one of the approved debug images; the first by default
+	Image   string `json:"image,omitempty"`
+	Network string `json:"network,omitempty"`
+	// TTL (i.e. 1h) is how long the debug container lives
+	TTL    string `json:"ttl,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// DebugContainer is a launched debug container; it is removed once it
+// expires like any container with a ttl label
+type DebugContainer struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Image     string    `json:"image"`
+	Container string    `json:"container"`
+	Network   string    `json:"network,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// debugImage returns the approved image for the launch
+func (m DefaultManager) debugImage(image string) (string, error) {
+	if len(m.debugImages) == 0 {
+		return "", ErrDebugDisabled
+	}
+
+	if image == "" {
+		return m.debugImages[0], nil
+	}
+
+	for _, approved := range m.debugImages {
+		if image == approved {
+			return image, nil
+		}
+	}
+
+	return "", ErrDebugImageNotApproved
+}
+
+func debugTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultDebugTTL, nil
+	}
+
+	ttl, err := ParseTTL(s)
+	if err != nil || ttl > MaxDebugTTL {
+		return 0, ErrInvalidDebugTTL
+	}
+
+	return ttl, nil
+}
+
+// checkDebugScope checks the container is within the containers the
+// account may see; requests with a service key have no account
+func (m DefaultManager) checkDebugScope(username string, labels map[string]string) error {
+	if username == "" {
+		return nil
+	}
+
+	acct, err := m.Account(username)
+	if err != nil {
+		return err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return err
+	}
+
+	if !auth.ContainerScope(acct, acls).Matches(labels) {
+		return ErrDebugNotAllowed
+	}
+
+	return nil
+}
+
+// LaunchDebugContainer starts an approved debug image next to a running
+// container, in its network namespace or on a network, so it can be
+// troubleshot without changing the container itself; the debug container
+// is stopped and removed once its ttl expires
+func (m DefaultManager) LaunchDebugContainer(launch *DebugLaunch, username string) (*DebugContainer, error) {
+	if launch.Container == "" {
+		return nil, ErrDebugTargetRequired
+	}
+
+	image, err := m.debugImage(launch.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := debugTTL(launch.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := m.Container(launch.Container)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.State == nil || !target.State.Running {
+		return nil, ErrDebugTargetNotRunning
+	}
+
+	labels := map[string]string{}
+	if target.Config != nil && target.Config.Labels != nil {
+		labels = target.Config.Labels
+	}
+
+	if err := m.checkDebugScope(username, labels); err != nil {
+		m.logEvent("debug-denied", fmt.Sprintf("username=%s container=%s reason=scope", username, target.Id), []string{"security", "debug"})
+		return nil, err
+	}
+
+	networkMode := "container:" + target.Id
+	if launch.Network != "" {
+		networkMode = launch.Network
+	}
+
+	config := &dockerclient.ContainerConfig{
+		Image: image,
+		// the image only has to stay up to be exec'd into
+		Cmd: []string{"sleep", strconv.Itoa(int(ttl.Seconds()))},
+		Labels: map[string]string{
+			LabelTTL:         ttl.String(),
+			LabelDebugTarget: target.Id,
+			LabelDebugUser:   username,
+		},
+		HostConfig: dockerclient.HostConfig{
+			NetworkMode: networkMode,
+		},
+	}
+	if environment := labels[notification.LabelEnvironment]; environment != "" {
+		config.Labels[notification.LabelEnvironment] = environment
+	}
+
+	name := fmt.Sprintf("%s-debug-%s", strings.TrimPrefix(target.Name, "/"), generateId(6))
+	// swarm prefixes the names with the node
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	client := m.DockerClient()
+	id, err := client.CreateContainer(config, name, nil)
+	if err == dockerclient.ErrImageNotFound {
+		if err := client.PullImage(image, nil); err != nil {
+			return nil, err
+		}
+		id, err = client.CreateContainer(config, name, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.StartContainer(id, nil); err != nil {
+		client.RemoveContainer(id, true, true)
+		return nil, err
+	}
+
+	debug := &DebugContainer{
+		ID:        id,
+		Name:      name,
+		Image:     image,
+		Container: target.Id,
+		Network:   launch.Network,
+		Username:  username,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	m.logEvent("debug-launch", fmt.Sprintf("username=%s container=%s image=%s network=%s ttl=%s id=%s reason=%q", username, target.Id, image, networkMode, ttl, id, launch.Reason), []string{"security", "debug", "container"})
+
+	return debug, nil
+}
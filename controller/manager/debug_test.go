@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+)
+
+// getDebugManager returns a manager approving busybox and netshoot on an
+// engine with the running container web, labelled team=web, and the
+// stopped container db; created containers are recorded
+func getDebugManager(t *testing.T) (DefaultManager, *[]*dockerclient.ContainerConfig, func()) {
+	var mu sync.Mutex
+	created := []*dockerclient.ContainerConfig{}
+
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		parts := strings.Split(r.URL.Path, "/")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			var config *dockerclient.ContainerConfig
+			json.NewDecoder(r.Body).Decode(&config)
+			created = append(created, config)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"Id": "debug-1"})
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			id := parts[len(parts)-2]
+			if id != "web" && id != "db" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(dockerclient.ContainerInfo{
+				Id:     id,
+				Name:   "/node-1/" + id,
+				State:  &dockerclient.State{Running: id == "web"},
+				Config: &dockerclient.ContainerConfig{Labels: map[string]string{"team": id}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	m, closeStore := newTestManager(t)
+
+	for _, account := range []*auth.Account{
+		{Username: "alice", LabelScope: auth.LabelScope{"team=web"}},
+		{Username: "bob", LabelScope: auth.LabelScope{"team=db"}},
+	} {
+		if err := m.db.CreateAccount(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		engine.Close()
+		closeStore()
+	}
+
+	m.client = &clusterClient{client: client}
+	m.debugImages = []string{"busybox", "nicolaka/netshoot"}
+
+	return m, &created, cleanup
+}
+
+func TestDebugTTL(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"":    DefaultDebugTTL,
+		"10m": 10 * time.Minute,
+		"4h":  MaxDebugTTL,
+	} {
+		ttl, err := debugTTL(s)
+		if err != nil || ttl != expected {
+			t.Fatalf("expected %q to be %s; received %s %v", s, expected, ttl, err)
+		}
+	}
+
+	for _, s := range []string{"5h", "-1m", "soon"} {
+		if _, err := debugTTL(s); err != ErrInvalidDebugTTL {
+			t.Fatalf("expected %q to be invalid; received %v", s, err)
+		}
+	}
+}
+
+func TestLaunchDebugContainer(t *testing.T) {
+	m, created, cleanup := getDebugManager(t)
+	defer cleanup()
+
+	debug, err := m.LaunchDebugContainer(&DebugLaunch{Container: "web", TTL: "10m", Reason: "dns"}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if debug.ID != "debug-1" || debug.Image != "busybox" || debug.Container != "web" || !strings.HasPrefix(debug.Name, "web-debug-") {
+		t.Fatalf("unexpected debug container %+v", debug)
+	}
+
+	config := (*created)[0]
+	if config.HostConfig.NetworkMode != "container:web" {
+		t.Fatalf("expected the network namespace of web; received %q", config.HostConfig.NetworkMode)
+	}
+
+	if config.Labels[LabelTTL] != "10m0s" || config.Labels[LabelDebugTarget] != "web" || config.Labels[LabelDebugUser] != "alice" {
+		t.Fatalf("unexpected labels %v", config.Labels)
+	}
+
+	if strings.Join(config.Cmd, " ") != "sleep 600" {
+		t.Fatalf("expected the container to sleep for its ttl; received %v", config.Cmd)
+	}
+
+	if _, err := m.LaunchDebugContainer(&DebugLaunch{Container: "web", Image: "nicolaka/netshoot", Network: "backend"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if config := (*created)[1]; config.Image != "nicolaka/netshoot" || config.HostConfig.NetworkMode != "backend" {
+		t.Fatalf("expected netshoot on backend; received %s %s", config.Image, config.HostConfig.NetworkMode)
+	}
+}
+
+func TestLaunchDebugContainerErrors(t *testing.T) {
+	m, created, cleanup := getDebugManager(t)
+	defer cleanup()
+
+	for _, c := range []struct {
+		launch   *DebugLaunch
+		username string
+		err      error
+	}{
+		{&DebugLaunch{}, "alice", ErrDebugTargetRequired},
+		{&DebugLaunch{Container: "web", Image: "alpine"}, "alice", ErrDebugImageNotApproved},
+		{&DebugLaunch{Container: "web", TTL: "1d"}, "alice", ErrInvalidDebugTTL},
+		{&DebugLaunch{Container: "db"}, "alice", ErrDebugTargetNotRunning},
+		{&DebugLaunch{Container: "web"}, "bob", ErrDebugNotAllowed},
+		{&DebugLaunch{Container: "cache"}, "alice", dockerclient.ErrNotFound},
+	} {
+		if _, err := m.LaunchDebugContainer(c.launch, c.username); err != c.err {
+			t.Fatalf("expected %v for %+v; received %v", c.err, c.launch, err)
+		}
+	}
+
+	if len(*created) != 0 {
+		t.Fatalf("expected no debug containers; received %d", len(*created))
+	}
+
+	m.debugImages = nil
+	if _, err := m.LaunchDebugContainer(&DebugLaunch{Container: "web"}, "alice"); err != ErrDebugDisabled {
+		t.Fatalf("expected ErrDebugDisabled; received %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package manager
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	demoStack          = "demo"
+	demoPasswordLength = 12
+)
+
+var (
+	ErrDemoSeeded = errors.New("the demo is already seeded; tear it down first")
+
+	demoRoles = []*auth.ACL{
+		{
+			RoleName:    "demo-viewer",
+			Description: "Demo: read-only access to containers, images, nodes, events, stacks and templates",
+			Permissions: []string{auth.PermContainersRead, auth.PermImagesRead, auth.PermNodesRead, auth.PermEventsRead, auth.PermStacksRead, auth.PermTemplatesRead},
+		},
+		{
+			RoleName:    "demo-operator",
+			Description: "Demo: manage containers, images and stacks",
+			Permissions: []string{auth.PermContainersRead, auth.PermContainersWrite, auth.PermImagesRead, auth.PermImagesWrite, auth.PermStacksRead, auth.PermStacksManage, auth.PermTemplatesRead},
+		},
+	}
+
+	// demoAccounts have the role of the same name
+	demoAccounts = []string{"demo-viewer", "demo-operator"}
+
+	demoTemplates = []*shipyard.Template{
+		{
+			Name:        "demo-web",
+			Title:       "Demo web server",
+			Description: "nginx serving its welcome page",
+			Author:      "shipyard",
+			Tags:        []string{"demo"},
+			Parameters: []shipyard.TemplateParameter{
+				{Name: "PORT", Description: "port published on the node", Type: shipyard.TemplateParameterInt, Default: "8080"},
+			},
+			Compose: "version: \"2\"\nservices:\n  web:\n    image: nginx:alpine\n    ports:\n      - \"${PORT}:80\"\n    labels:\n      com.shipyard.environment: demo\n",
+		},
+		{
+			Name:        "demo-cache",
+			Title:       "Demo cache",
+			Description: "redis with a configurable memory limit",
+			Author:      "shipyard",
+			Tags:        []string{"demo"},
+			Parameters: []shipyard.TemplateParameter{
+				{Name: "MAXMEMORY", Description: "memory limit of redis", Pattern: "[0-9]+[kmg]b", Default: "64mb"},
+			},
+			Compose: "version: \"2\"\nservices:\n  redis:\n    image: redis:alpine\n    command: redis-server --maxmemory ${MAXMEMORY}\n    labels:\n      com.shipyard.environment: demo\n",
+		},
+	}
+)
+
+type (
+	DemoAccount struct {
+		Username string   `json:"username"`
+		Password string   `json:"password"`
+		Roles    []string `json:"roles"`
+	}
+
+	// DemoResult lists what was seeded; the passwords are only returned
+	// here
+	DemoResult struct {
+		Accounts  []*DemoAccount `json:"accounts"`
+		Roles     []string       `json:"roles"`
+		Templates []string       `json:"templates"`
+		Stack     string         `json:"stack"`
+	}
+)
+
+// demoSeeded reports whether any of the demo records exist
+func (m DefaultManager) demoSeeded() (bool, error) {
+	for _, username := range demoAccounts {
+		if _, err := m.Account(username); err == nil {
+			return true, nil
+		} else if err != ErrAccountDoesNotExist {
+			return false, err
+		}
+	}
+
+	for _, role := range demoRoles {
+		r, err := m.Role(role.RoleName)
+		if err != nil {
+			return false, err
+		}
+
+		if r != nil {
+			return true, nil
+		}
+	}
+
+	for _, template := range demoTemplates {
+		if _, err := m.db.Template(template.Name); err == nil {
+			return true, nil
+		} else if err != datastore.ErrNotFound {
+			return false, err
+		}
+	}
+
+	if _, err := m.db.Stack(demoStack); err == nil {
+		return true, nil
+	} else if err != datastore.ErrNotFound {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// SeedDemo creates demo roles, accounts with random passwords and
+// templates and deploys the web template as the demo stack so a new
+// install can be explored; anything seeded is removed again on failure
+func (m DefaultManager) SeedDemo(username string) (*DemoResult, error) {
+	seeded, err := m.demoSeeded()
+	if err != nil {
+		return nil, err
+	}
+
+	if seeded {
+		return nil, ErrDemoSeeded
+	}
+
+	result, err := m.seedDemo(username)
+	if err != nil {
+		if tErr := m.TeardownDemo(username); tErr != nil {
+			log.Errorf("demo: error removing the partial demo: %s", tErr)
+		}
+
+		return nil, err
+	}
+
+	m.logEvent("seed-demo", fmt.Sprintf("username=%s", username), []string{"demo"})
+
+	return result, nil
+}
+
+func (m DefaultManager) seedDemo(username string) (*DemoResult, error) {
+	result := &DemoResult{
+		Accounts:  []*DemoAccount{},
+		Roles:     []string{},
+		Templates: []string{},
+	}
+
+	for _, role := range demoRoles {
+		r := *role
+		if err := m.SaveRole(&r); err != nil {
+			return nil, err
+		}
+		result.Roles = append(result.Roles, r.RoleName)
+	}
+
+	for _, name := range demoAccounts {
+		buf := make([]byte, demoPasswordLength)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		password := hex.EncodeToString(buf)
+
+		account := &auth.Account{
+			Username: name,
+			Password: password,
+			Roles:    []string{name},
+		}
+		if err := m.SaveAccount(account); err != nil {
+			return nil, err
+		}
+
+		result.Accounts = append(result.Accounts, &DemoAccount{
+			Username: name,
+			Password: password,
+			Roles:    account.Roles,
+		})
+	}
+
+	for _, template := range demoTemplates {
+		if _, err := m.ImportTemplate(template.Bundle(), username, false); err != nil {
+			return nil, err
+		}
+		result.Templates = append(result.Templates, template.Name)
+	}
+
+	stack, err := m.DeployTemplate(demoTemplates[0].Name, demoStack, nil, 0, username)
+	if err != nil {
+		return nil, err
+	}
+	result.Stack = stack.Name
+
+	return result, nil
+}
+
+// TeardownDemo removes the demo stack, templates, accounts and roles;
+// records already gone are skipped
+func (m DefaultManager) TeardownDemo(username string) error {
+	if err := m.RemoveStack(demoStack, username); err != nil && err != ErrStackDoesNotExist {
+		return err
+	}
+
+	for _, template := range demoTemplates {
+		if err := m.DeleteTemplate(template.Name); err != nil && err != ErrTemplateDoesNotExist {
+			return err
+		}
+	}
+
+	for _, name := range demoAccounts {
+		if err := m.DeleteAccount(&auth.Account{Username: name}); err != nil && err != ErrAccountDoesNotExist {
+			return err
+		}
+	}
+
+	for _, role := range demoRoles {
+		if err := m.DeleteRole(role.RoleName); err != nil && err != ErrRoleDoesNotExist {
+			return err
+		}
+	}
+
+	m.logEvent("teardown-demo", fmt.Sprintf("username=%s", username), []string{"demo"})
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestDemoTemplates(t *testing.T) {
+	for _, template := range demoTemplates {
+		if err := validateBundle(template.Bundle()); err != nil {
+			t.Errorf("%s: %s", template.Name, err)
+		}
+	}
+}
+
+func TestTeardownDemo(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	seeded, err := m.demoSeeded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeded {
+		t.Fatal("expected an empty install not to be seeded")
+	}
+
+	// a partial demo as left by a failed deploy
+	if err := m.SaveRole(demoRoles[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveAccount(&auth.Account{Username: demoAccounts[0], Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ImportTemplate(demoTemplates[1].Bundle(), "admin", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveAccount(&auth.Account{Username: "alice", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.SeedDemo("admin"); err != ErrDemoSeeded {
+		t.Fatalf("expected %s; received %v", ErrDemoSeeded, err)
+	}
+
+	if err := m.TeardownDemo("admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	seeded, err = m.demoSeeded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeded {
+		t.Fatal("expected the demo to be removed")
+	}
+
+	if _, err := m.Account("alice"); err != nil {
+		t.Fatalf("expected other accounts to be kept: %s", err)
+	}
+}
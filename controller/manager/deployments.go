@@ -0,0 +1,306 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+const (
+	tblNameDeployments = "deployments"
+	deploymentIDLength = 16
+	// LabelSmokeTest is set on the containers of smoke tests to the stack
+	LabelSmokeTest = "com.shipyard.smoke-test"
+	// smokeTestInterval is how often a url is requested until it answers
+	// with the expected status
+	smokeTestInterval = 2 * time.Second
+	// smokeTestRequestTimeout is how long a request to a url can take
+	smokeTestRequestTimeout = 10 * time.Second
+	// maxReleaseNotes and maxReleaseLinks keep the release notes short
+	// enough for notifications
+	maxReleaseNotes = 4096
+	maxReleaseLinks = 10
+)
+
+var (
+	ErrReleaseNotesTooLong = errors.New("release notes are limited to 4096 bytes and 10 links")
+	ErrInvalidReleaseLink  = errors.New("release links have to be http or https urls")
+)
+
+// SmokeTestError is returned for deploys whose smoke test failed; the
+// stack is rolled back unless Rollback is set
+type SmokeTestError struct {
+	Test     string
+	Err      error
+	Rollback error
+}
+
+func (e *SmokeTestError) Error() string {
+	if e.Rollback != nil {
+		return fmt.Sprintf("smoke test %s failed: %s; error rolling back: %s", e.Test, e.Err, e.Rollback)
+	}
+
+	return fmt.Sprintf("smoke test %s failed: %s; the deploy was rolled back", e.Test, e.Err)
+}
+
+// Deployments returns the deployments of the stack newest first; they are
+// kept after the stack is removed
+func (m DefaultManager) Deployments(stack string) ([]*shipyard.Deployment, error) {
+	return m.db.Deployments(stack)
+}
+
+func (m DefaultManager) startDeployment(stack, operation, username string, release *shipyard.Release) *shipyard.Deployment {
+	return &shipyard.Deployment{
+		ID:        generateId(deploymentIDLength),
+		Stack:     stack,
+		Operation: operation,
+		Username:  username,
+		StartedAt: time.Now(),
+		Release:   release,
+	}
+}
+
+// validateRelease checks the release notes of a deploy; deploys without
+// notes have a nil release
+func validateRelease(release *shipyard.Release) error {
+	if release == nil {
+		return nil
+	}
+
+	if len(release.Notes) > maxReleaseNotes || len(release.Links) > maxReleaseLinks {
+		return ErrReleaseNotesTooLong
+	}
+
+	for _, link := range release.Links {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidReleaseLink
+		}
+	}
+
+	return nil
+}
+
+// releaseText returns the release notes for the events of a deploy, which
+// notifications include
+func releaseText(release *shipyard.Release) string {
+	if release == nil {
+		return ""
+	}
+
+	text := ""
+	if release.Notes != "" {
+		text += fmt.Sprintf(" notes=%q", release.Notes)
+	}
+	if len(release.Links) > 0 {
+		text += " links=" + strings.Join(release.Links, ",")
+	}
+
+	return text
+}
+
+// finishDeployment saves the deployment with the outcome of the deploy
+func (m DefaultManager) finishDeployment(d *shipyard.Deployment, err error) {
+	d.FinishedAt = time.Now()
+
+	switch e := err.(type) {
+	case nil:
+		d.Status = shipyard.DeploymentSucceeded
+	case *SmokeTestError:
+		d.Status = shipyard.DeploymentRolledBack
+		if e.Rollback != nil {
+			d.Status = shipyard.DeploymentFailed
+		}
+	default:
+		d.Status = shipyard.DeploymentFailed
+	}
+
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	if err := m.db.SaveDeployment(d); err != nil {
+		log.Errorf("stack: error saving deployment of %s: %s", d.Stack, err)
+	}
+}
+
+// smokeTestStack runs the smoke tests of the compose file in order and
+// records them on the deployment; it stops at the first failure
+func (m DefaultManager) smokeTestStack(name string, f *compose.File, d *shipyard.Deployment) error {
+	for _, test := range f.SmokeTests {
+		start := time.Now()
+
+		var output string
+		var err error
+		if test.URL != "" {
+			output, err = m.httpSmokeTest(test)
+		} else {
+			output, err = m.containerSmokeTest(name, test)
+		}
+
+		result := &shipyard.SmokeTestResult{
+			Name:     test.Name,
+			Passed:   err == nil,
+			Output:   output,
+			Duration: time.Since(start).Seconds(),
+		}
+		if err != nil {
+			result.Output = err.Error()
+		}
+		d.SmokeTests = append(d.SmokeTests, result)
+
+		if err != nil {
+			m.logEvent("smoke-test", fmt.Sprintf("stack=%s test=%s error=%s", name, test.Name, err), []string{"deploy", "stack"})
+			return &SmokeTestError{Test: test.Name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// httpSmokeTest requests the url until it answers with the expected status
+// or the test times out
+func (m DefaultManager) httpSmokeTest(test *compose.SmokeTest) (string, error) {
+	client := &http.Client{Timeout: smokeTestRequestTimeout}
+	deadline := time.Now().Add(test.Deadline())
+
+	for {
+		resp, err := client.Get(test.URL)
+		if err == nil {
+			resp.Body.Close()
+
+			passed := resp.StatusCode == test.Status
+			if test.Status == 0 {
+				passed = resp.StatusCode >= 200 && resp.StatusCode < 300
+			}
+
+			if passed {
+				return fmt.Sprintf("status %d", resp.StatusCode), nil
+			}
+
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+
+		if time.Now().Add(smokeTestInterval).After(deadline) {
+			return "", err
+		}
+		time.Sleep(smokeTestInterval)
+	}
+}
+
+// containerSmokeTest runs the container of the test, which has to exit
+// with 0 before the test times out; the container is then removed
+func (m DefaultManager) containerSmokeTest(stack string, test *compose.SmokeTest) (string, error) {
+	config := &dockerclient.ContainerConfig{
+		Image:  test.Image,
+		Cmd:    test.Command,
+		Labels: map[string]string{LabelSmokeTest: stack},
+		HostConfig: dockerclient.HostConfig{
+			NetworkMode: test.NetworkMode,
+		},
+	}
+	if err := m.applyDrainConstraints(config); err != nil {
+		return "", err
+	}
+
+	client := m.DockerClient()
+	id, err := client.CreateContainer(config, "", nil)
+	if err == dockerclient.ErrImageNotFound {
+		if err := m.pullImage(DeployStack+":"+stack, test.Image); err != nil {
+			return "", err
+		}
+
+		id, err = client.CreateContainer(config, "", nil)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if err := client.RemoveContainer(id, true, false); err != nil {
+			log.Errorf("stack: error removing smoke test container %s: %s", id, err)
+		}
+	}()
+
+	if err := client.StartContainer(id, &config.HostConfig); err != nil {
+		return "", err
+	}
+
+	wait := client.Wait(id)
+	select {
+	case res := <-wait:
+		if res.Error != nil {
+			return "", res.Error
+		}
+
+		if res.ExitCode != 0 {
+			return "", fmt.Errorf("exited with %d", res.ExitCode)
+		}
+
+		return "exited with 0", nil
+	case <-time.After(test.Deadline()):
+		// the wait returns once the container is removed
+		go func() { <-wait }()
+		return "", fmt.Errorf("did not exit within %s", test.Deadline())
+	}
+}
+
+// stackImages returns the image ids the containers of the stack run by
+// service so a failed redeploy can be rolled back to them
+func (m DefaultManager) stackImages(name string) (map[string]string, error) {
+	containers, err := m.stackContainers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	images := map[string]string{}
+	for _, c := range containers {
+		service := c.Labels[compose.LabelService]
+		if _, ok := images[service]; ok {
+			continue
+		}
+
+		info, err := m.DockerClient().InspectContainer(c.Id)
+		if err != nil {
+			return nil, err
+		}
+		images[service] = info.Image
+	}
+
+	return images, nil
+}
+
+// rollbackStack replaces the containers of a deploy whose smoke test failed
+// with containers of the previous images; stacks without previous images
+// (i.e. first deploys) are only removed
+func (m DefaultManager) rollbackStack(name string, f *compose.File, previous map[string]string) error {
+	if err := m.removeStackContainers(name); err != nil {
+		return err
+	}
+
+	if len(previous) == 0 {
+		return nil
+	}
+
+	rollback := &compose.File{
+		Version:  f.Version,
+		Services: map[string]*compose.Service{},
+	}
+	for service, svc := range f.Services {
+		s := *svc
+		if image, ok := previous[service]; ok {
+			s.Image = image
+		}
+		rollback.Services[service] = &s
+	}
+
+	return m.createStackContainers(name, rollback)
+}
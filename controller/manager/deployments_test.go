@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+func TestRedeployStackSmokeTestRollback(t *testing.T) {
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	}))
+	defer health.Close()
+
+	var mu sync.Mutex
+	running := []dockerclient.Container{
+		{Id: "web-old", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "web"}},
+	}
+	created := []string{}
+
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			json.NewEncoder(w).Encode(running)
+		case strings.HasSuffix(r.URL.Path, "/web-old/json"):
+			json.NewEncoder(w).Encode(dockerclient.ContainerInfo{Id: "web-old", Image: "sha256:old"})
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			var config dockerclient.ContainerConfig
+			json.NewDecoder(r.Body).Decode(&config)
+			created = append(created, config.Image)
+
+			id := "web-" + config.Image
+			running = append(running, dockerclient.Container{Id: id, Labels: config.Labels})
+			json.NewEncoder(w).Encode(map[string]string{"Id": id})
+		case r.Method == "DELETE":
+			running = []dockerclient.Container{}
+		case strings.HasSuffix(r.URL.Path, "/start"), strings.HasSuffix(r.URL.Path, "/images/create"):
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if err := m.db.SaveStack(&shipyard.Stack{
+		Name:     "shop",
+		Compose:  "services:\n  web:\n    image: nginx:1.12\nx-smoke-tests:\n  - url: " + health.URL + "\n    timeout: 10ms\n",
+		Services: []string{"web"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+	m.stackLocks = newStackLocks()
+
+	release := &shipyard.Release{Notes: "bump nginx", Links: []string{"https://example.com/tickets/42"}}
+	_, err = m.RedeployStack("shop", "alice", release)
+	smoke, ok := err.(*SmokeTestError)
+	if !ok || smoke.Rollback != nil {
+		t.Fatalf("expected the redeploy to be rolled back; received %v", err)
+	}
+
+	if len(created) != 2 || created[0] != "nginx:1.12" || created[1] != "sha256:old" {
+		t.Fatalf("expected the containers to be recreated from the previous image; received %v", created)
+	}
+
+	deployments, err := m.Deployments("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deployments) != 1 || deployments[0].Status != shipyard.DeploymentRolledBack {
+		t.Fatalf("expected a rolled back deployment; received %+v", deployments)
+	}
+
+	d := deployments[0]
+	if d.Username != "alice" || len(d.SmokeTests) != 1 || d.SmokeTests[0].Passed || d.SmokeTests[0].Output != "status 503" {
+		t.Fatalf("expected the failed smoke test on the deployment; received %+v", d)
+	}
+
+	if d.Release == nil || d.Release.Notes != "bump nginx" || len(d.Release.Links) != 1 {
+		t.Fatalf("expected the release notes on the deployment; received %+v", d.Release)
+	}
+}
+
+func TestValidateRelease(t *testing.T) {
+	valid := []*shipyard.Release{
+		nil,
+		{Notes: "fix checkout"},
+		{Links: []string{"https://example.com/tickets/42", "http://example.com/CHANGELOG"}},
+	}
+	for _, r := range valid {
+		if err := validateRelease(r); err != nil {
+			t.Fatalf("expected %+v to be valid; received %s", r, err)
+		}
+	}
+
+	invalid := map[*shipyard.Release]error{
+		{Notes: strings.Repeat("a", maxReleaseNotes+1)}: ErrReleaseNotesTooLong,
+		{Links: []string{"javascript:alert(1)"}}:        ErrInvalidReleaseLink,
+		{Links: []string{"example.com/tickets/42"}}:     ErrInvalidReleaseLink,
+	}
+	for r, expected := range invalid {
+		if err := validateRelease(r); err != expected {
+			t.Fatalf("expected %s for %+v; received %v", expected, r, err)
+		}
+	}
+}
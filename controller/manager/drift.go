@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shipyard/shipyard"
+)
+
+// driftSettings are the compared settings of the engines
+var driftSettings = []struct {
+	name  string
+	value func(c *shipyard.EngineConfig) string
+}{
+	{"version", func(c *shipyard.EngineConfig) string { return c.Version }},
+	{"kernel_version", func(c *shipyard.EngineConfig) string { return c.KernelVersion }},
+	{"operating_system", func(c *shipyard.EngineConfig) string { return c.OperatingSystem }},
+	{"storage_driver", func(c *shipyard.EngineConfig) string { return c.StorageDriver }},
+	{"logging_driver", func(c *shipyard.EngineConfig) string { return c.LoggingDriver }},
+	{"cgroup_driver", func(c *shipyard.EngineConfig) string { return c.CgroupDriver }},
+	{"insecure_registries", func(c *shipyard.EngineConfig) string { return strings.Join(c.InsecureRegistries, ",") }},
+	{"registry_mirrors", func(c *shipyard.EngineConfig) string { return strings.Join(c.RegistryMirrors, ",") }},
+}
+
+// NodeDrift compares the configuration of the engines collected with the
+// inventory and reports the nodes differing from most nodes
+func (m DefaultManager) NodeDrift() (*shipyard.DriftReport, error) {
+	nodes, err := m.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string]*shipyard.EngineConfig{}
+	for _, node := range nodes {
+		configs[node.Name] = m.inventory.config(node.Name)
+	}
+
+	return configDrift(configs), nil
+}
+
+// configDrift compares the configurations of the nodes; the expected value
+// of a setting is the one of most nodes and ties go to the lowest value so
+// reports are stable
+func configDrift(configs map[string]*shipyard.EngineConfig) *shipyard.DriftReport {
+	report := &shipyard.DriftReport{
+		Nodes:   []string{},
+		Unknown: []string{},
+		Drift:   []*shipyard.DriftSetting{},
+	}
+
+	for name, config := range configs {
+		if config == nil {
+			report.Unknown = append(report.Unknown, name)
+			continue
+		}
+		report.Nodes = append(report.Nodes, name)
+	}
+	sort.Strings(report.Nodes)
+	sort.Strings(report.Unknown)
+
+	for _, setting := range driftSettings {
+		values := map[string]string{}
+		counts := map[string]int{}
+		for _, name := range report.Nodes {
+			v := setting.value(configs[name])
+			values[name] = v
+			counts[v]++
+		}
+
+		if len(counts) < 2 {
+			continue
+		}
+
+		distinct := []string{}
+		for v := range counts {
+			distinct = append(distinct, v)
+		}
+		sort.Strings(distinct)
+
+		expected := distinct[0]
+		for _, v := range distinct[1:] {
+			if counts[v] > counts[expected] {
+				expected = v
+			}
+		}
+
+		drift := &shipyard.DriftSetting{
+			Name:     setting.name,
+			Expected: expected,
+			Outliers: map[string]string{},
+		}
+		for name, v := range values {
+			if v != expected {
+				drift.Outliers[name] = v
+			}
+		}
+
+		report.Drift = append(report.Drift, drift)
+	}
+
+	report.Homogeneous = len(report.Drift) == 0
+
+	return report
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestConfigDrift(t *testing.T) {
+	config := func(version, driver string, insecure ...string) *shipyard.EngineConfig {
+		return &shipyard.EngineConfig{
+			Version:            version,
+			StorageDriver:      driver,
+			InsecureRegistries: insecure,
+		}
+	}
+
+	report := configDrift(map[string]*shipyard.EngineConfig{
+		"node-1": config("1.12.6", "overlay2"),
+		"node-2": config("1.12.6", "overlay2"),
+		"node-3": config("1.11.2", "overlay2", "registry.local:5000"),
+		"node-4": nil,
+	})
+
+	if report.Homogeneous {
+		t.Fatal("expected drift")
+	}
+
+	if !reflect.DeepEqual(report.Nodes, []string{"node-1", "node-2", "node-3"}) || !reflect.DeepEqual(report.Unknown, []string{"node-4"}) {
+		t.Fatalf("unexpected nodes %v; unknown %v", report.Nodes, report.Unknown)
+	}
+
+	if len(report.Drift) != 2 {
+		t.Fatalf("expected drift of the version and insecure registries; received %d settings", len(report.Drift))
+	}
+
+	version := report.Drift[0]
+	if version.Name != "version" || version.Expected != "1.12.6" || !reflect.DeepEqual(version.Outliers, map[string]string{"node-3": "1.11.2"}) {
+		t.Fatalf("unexpected version drift %+v", version)
+	}
+
+	insecure := report.Drift[1]
+	if insecure.Name != "insecure_registries" || insecure.Expected != "" || insecure.Outliers["node-3"] != "registry.local:5000" {
+		t.Fatalf("unexpected insecure registries drift %+v", insecure)
+	}
+}
+
+func TestConfigDriftTie(t *testing.T) {
+	report := configDrift(map[string]*shipyard.EngineConfig{
+		"node-1": {StorageDriver: "overlay2"},
+		"node-2": {StorageDriver: "aufs"},
+	})
+
+	// ties go to the lowest value
+	if len(report.Drift) != 1 || report.Drift[0].Expected != "aufs" {
+		t.Fatalf("expected aufs to be expected; received %+v", report.Drift)
+	}
+
+	report = configDrift(map[string]*shipyard.EngineConfig{
+		"node-1": {StorageDriver: "overlay2"},
+		"node-2": {StorageDriver: "overlay2"},
+	})
+
+	if !report.Homogeneous {
+		t.Fatalf("expected homogeneous nodes; received %+v", report.Drift)
+	}
+}
@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/metrics"
+)
+
+var (
+	ErrInvalidEventToken = errors.New("invalid event token; use the id of an event of the stream")
+
+	eventsWritten = metrics.Default.NewCounter("shipyard_events_written_total", "Events written by this controller.")
+)
+
+// EventStream returns new events as they are written until done is closed;
+// the channel is closed when the stream ends. With rethinkdb the events of
+// every controller are returned.
+func (m DefaultManager) EventStream(done <-chan struct{}) (<-chan *shipyard.Event, error) {
+	return m.db.WatchEvents(done)
+}
+
+// EventToken returns the token a stream resumes after the event with
+func EventToken(evt *shipyard.Event) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(evt.Time.UTC().Format(time.RFC3339Nano)))
+}
+
+// ParseEventToken returns the time of the event of the token; streams
+// resume with the events written after it
+func ParseEventToken(token string) (time.Time, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, ErrInvalidEventToken
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		return time.Time{}, ErrInvalidEventToken
+	}
+
+	return t, nil
+}
@@ -0,0 +1,26 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestEventToken(t *testing.T) {
+	now := time.Now()
+	parsed, err := ParseEventToken(EventToken(&shipyard.Event{Time: now}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.Equal(now) {
+		t.Fatalf("expected %s; received %s", now, parsed)
+	}
+
+	for _, token := range []string{"yesterday", "MjAxNg"} {
+		if _, err := ParseEventToken(token); err != ErrInvalidEventToken {
+			t.Errorf("expected %q to be invalid; received %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	tblNameExecPolicies = "exec_policies"
+)
+
+var (
+	ErrExecPolicyDoesNotExist = errors.New("exec policy does not exist")
+	ErrExecNotAllowed         = errors.New("command is not allowed")
+)
+
+func (m DefaultManager) ExecPolicies() ([]*auth.ExecPolicy, error) {
+	return m.db.ExecPolicies()
+}
+
+func (m DefaultManager) ExecPolicy(id string) (*auth.ExecPolicy, error) {
+	p, err := m.db.ExecPolicy(id)
+	if err != nil {
+		return nil, notFound(err, ErrExecPolicyDoesNotExist)
+	}
+	return p, nil
+}
+
+func (m DefaultManager) SaveExecPolicy(policy *auth.ExecPolicy) error {
+	role, err := m.Role(policy.Role)
+	if err != nil {
+		return err
+	}
+
+	if role == nil {
+		return ErrRoleDoesNotExist
+	}
+
+	if err := m.db.SaveExecPolicy(policy); err != nil {
+		return err
+	}
+
+	m.logEvent("save-exec-policy", fmt.Sprintf("role=%s disabled=%v commands=%s", policy.Role, policy.Disabled, strings.Join(policy.AllowedCommands, ",")), []string{"security"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteExecPolicy(id string) error {
+	p, err := m.ExecPolicy(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteExecPolicy(id); err != nil {
+		return notFound(err, ErrExecPolicyDoesNotExist)
+	}
+
+	m.logEvent("delete-exec-policy", fmt.Sprintf("role=%s", p.Role), []string{"security"})
+
+	return nil
+}
+
+// AuthorizeExec reports whether the console session may run the command
+// and whether it must be read only. Sessions of a service key need the
+// containers:exec scope and sessions created from a whitelisted address
+// are allowed, as neither has an account; the others are checked by
+// authorizeAccountExec.
+func (m DefaultManager) AuthorizeExec(cs *shipyard.ConsoleSession, containerId string, cmd []string) (bool, error) {
+	if cs.Username != "" {
+		return m.authorizeAccountExec(cs.Username, containerId, cmd)
+	}
+
+	if cs.ServiceKeyID == "" {
+		return false, nil
+	}
+
+	key, err := m.ServiceKeyByID(cs.ServiceKeyID)
+	if err != nil && err != ErrServiceKeyDoesNotExist {
+		return false, err
+	}
+
+	if key == nil || key.Expired(time.Now()) || !key.Allows("/containers/"+containerId+"/exec", "POST") {
+		m.logEvent("exec-denied", fmt.Sprintf("service_key=%s container=%s permission=%s", cs.ServiceKeyID, containerId, auth.PermContainersExec), []string{"security", "exec"})
+		return false, ErrExecNotAllowed
+	}
+
+	return false, nil
+}
+
+// authorizeAccountExec checks the command against the exec policies of the
+// roles of the account and reports whether the session must be read only;
+// the environment comes from the container labels
+func (m DefaultManager) authorizeAccountExec(username, containerId string, cmd []string) (bool, error) {
+	acct, err := m.Account(username)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := m.Container(containerId)
+	if err != nil {
+		return false, err
+	}
+
+	// the exec websocket is outside the access middleware so the role
+	// permission is checked here
+	acls, err := m.Roles()
+	if err != nil {
+		return false, err
+	}
+
+	permitted := false
+	for _, acl := range acls {
+		if acct.HasRole(acl.RoleName) && acl.Allows("/containers/"+containerId+"/exec", "POST") {
+			permitted = true
+			break
+		}
+	}
+
+	if !permitted {
+		m.logEvent("exec-denied", fmt.Sprintf("username=%s container=%s permission=%s", username, containerId, auth.PermContainersExec), []string{"security", "exec"})
+		return false, ErrExecNotAllowed
+	}
+
+	labels := map[string]string{}
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+
+	if !auth.ContainerScope(acct, acls).Matches(labels) {
+		m.logEvent("exec-denied", fmt.Sprintf("username=%s container=%s reason=scope", username, containerId), []string{"security", "exec"})
+		return false, ErrExecNotAllowed
+	}
+
+	environment := labels[notification.LabelEnvironment]
+
+	policies, err := m.ExecPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	allowed, readOnly := auth.ExecAccess(acct.Roles, policies, environment, cmd)
+	if !allowed {
+		m.logEvent("exec-denied", fmt.Sprintf("username=%s container=%s cmd=%s", username, containerId, strings.Join(cmd, " ")), []string{"security", "exec"})
+		return false, ErrExecNotAllowed
+	}
+
+	return readOnly, nil
+}
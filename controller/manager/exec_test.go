@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestAuthorizeServiceKeyExec(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for _, key := range []*auth.ServiceKey{
+		{Key: "full"},
+		{Key: "exec", Scopes: []string{auth.PermContainersExec}},
+		{Key: "events", Scopes: []string{auth.PermEventsRead}},
+		{Key: "expired", ExpiresAt: time.Now().Add(-time.Hour)},
+	} {
+		if err := m.db.SaveServiceKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		cs      *shipyard.ConsoleSession
+		allowed bool
+	}{
+		{&shipyard.ConsoleSession{ServiceKeyID: auth.KeyID("full")}, true},
+		{&shipyard.ConsoleSession{ServiceKeyID: auth.KeyID("exec")}, true},
+		{&shipyard.ConsoleSession{ServiceKeyID: auth.KeyID("events")}, false},
+		{&shipyard.ConsoleSession{ServiceKeyID: auth.KeyID("expired")}, false},
+		{&shipyard.ConsoleSession{ServiceKeyID: auth.KeyID("deleted")}, false},
+		// sessions created from a whitelisted address have no account
+		{&shipyard.ConsoleSession{}, true},
+	} {
+		_, err := m.AuthorizeExec(test.cs, "web-1", []string{"sh"})
+		if test.allowed && err != nil {
+			t.Fatalf("expected %+v to be allowed; received %v", test.cs, err)
+		}
+		if !test.allowed && err != ErrExecNotAllowed {
+			t.Fatalf("expected %+v to be denied; received %v", test.cs, err)
+		}
+	}
+}
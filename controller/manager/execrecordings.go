@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"errors"
+
+	"github.com/shipyard/shipyard"
+)
+
+const (
+	tblNameExecRecordings = "exec_recordings"
+)
+
+var (
+	ErrExecRecordingDoesNotExist = errors.New("exec recording does not exist")
+)
+
+// ExecRecordings returns the recorded exec sessions newest first without
+// their output
+func (m DefaultManager) ExecRecordings() ([]*shipyard.ExecRecording, error) {
+	return m.db.ExecRecordings()
+}
+
+func (m DefaultManager) ExecRecording(id string) (*shipyard.ExecRecording, error) {
+	rec, err := m.db.ExecRecording(id)
+	if err != nil {
+		return nil, notFound(err, ErrExecRecordingDoesNotExist)
+	}
+
+	return rec, nil
+}
+
+func (m DefaultManager) SaveExecRecording(rec *shipyard.ExecRecording) error {
+	return m.db.SaveExecRecording(rec)
+}
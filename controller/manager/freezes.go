@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	tblNameFreezes = "freezes"
+	freezeIDLength = 16
+)
+
+var (
+	ErrFreezeDoesNotExist = errors.New("freeze does not exist")
+	ErrFreezeNameNeeded   = errors.New("a freeze needs a name")
+	ErrInvalidFreezeRange = errors.New("a freeze has to end after it starts")
+)
+
+// FreezeError is returned for changes refused during a freeze
+type FreezeError struct {
+	Freeze *shipyard.Freeze
+}
+
+func (e *FreezeError) Error() string {
+	return fmt.Sprintf("changes are frozen by %q until %s", e.Freeze.Name, e.Freeze.End.Format(time.RFC3339))
+}
+
+func (m DefaultManager) Freezes() ([]*shipyard.Freeze, error) {
+	return m.db.Freezes()
+}
+
+func (m DefaultManager) Freeze(id string) (*shipyard.Freeze, error) {
+	freeze, err := m.db.Freeze(id)
+	if err != nil {
+		return nil, notFound(err, ErrFreezeDoesNotExist)
+	}
+
+	return freeze, nil
+}
+
+// SaveFreeze creates the freeze or replaces it when it has an id
+func (m DefaultManager) SaveFreeze(freeze *shipyard.Freeze) error {
+	freeze.Name = strings.TrimSpace(freeze.Name)
+	if freeze.Name == "" {
+		return ErrFreezeNameNeeded
+	}
+
+	if !freeze.End.After(freeze.Start) {
+		return ErrInvalidFreezeRange
+	}
+
+	if freeze.ID == "" {
+		freeze.ID = generateId(freezeIDLength)
+		freeze.CreatedAt = time.Now()
+	} else if _, err := m.Freeze(freeze.ID); err != nil {
+		return err
+	}
+
+	if err := m.db.SaveFreeze(freeze); err != nil {
+		return err
+	}
+
+	m.logEvent("save-freeze", fmt.Sprintf("id=%s name=%q start=%s end=%s environments=%s created_by=%s",
+		freeze.ID, freeze.Name, freeze.Start.Format(time.RFC3339), freeze.End.Format(time.RFC3339),
+		strings.Join(freeze.Environments, ","), freeze.CreatedBy), []string{"freeze"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteFreeze(id string) error {
+	if err := m.db.DeleteFreeze(id); err != nil {
+		return notFound(err, ErrFreezeDoesNotExist)
+	}
+
+	m.logEvent("delete-freeze", fmt.Sprintf("id=%s", id), []string{"freeze"})
+
+	return nil
+}
+
+// activeFreeze returns the freeze covering the environment now or nil
+func (m DefaultManager) activeFreeze(environment string) (*shipyard.Freeze, error) {
+	freezes, err := m.db.Freezes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, freeze := range freezes {
+		if freeze.Active(now) && freeze.Applies(environment) {
+			return freeze, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckFreeze returns a FreezeError when a freeze covers the environment
+// of the change; users allowed to override freezes may go ahead and every
+// override is saved as a security event
+func (m DefaultManager) CheckFreeze(username, environment, change string) error {
+	freeze, err := m.activeFreeze(environment)
+	if err != nil || freeze == nil {
+		return err
+	}
+
+	override := false
+	if username != "" {
+		override, err = m.HasPermission(username, auth.PermFreezesOverride)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !override {
+		m.logEvent("freeze-denied", fmt.Sprintf("freeze=%s username=%s environment=%s change=%q", freeze.ID, username, environment, change), []string{"freeze", "deploy"})
+		return &FreezeError{Freeze: freeze}
+	}
+
+	log.Warnf("freeze override: freeze=%q username=%s environment=%s change=%q", freeze.Name, username, environment, change)
+
+	evt := &shipyard.Event{
+		Type:     "freeze-override",
+		Time:     time.Now(),
+		Username: username,
+		Message:  fmt.Sprintf("freeze=%s name=%q environment=%s change=%q", freeze.ID, freeze.Name, environment, change),
+		Tags:     []string{"security", "freeze", "override"},
+	}
+
+	return m.SaveEvent(evt)
+}
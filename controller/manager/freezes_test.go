@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestActiveFreeze(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	now := time.Now()
+	for _, freeze := range []*shipyard.Freeze{
+		{Name: "over", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)},
+		{Name: "release", Start: now.Add(-time.Hour), End: now.Add(time.Hour), Environments: []string{"prod"}},
+	} {
+		if err := m.db.SaveFreeze(freeze); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	freeze, err := m.activeFreeze("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if freeze == nil || freeze.Name != "release" {
+		t.Fatalf("expected prod to be frozen by release; received %+v", freeze)
+	}
+
+	if freeze, err := m.activeFreeze("dev"); err != nil || freeze != nil {
+		t.Fatalf("expected dev not to be frozen; received %+v %v", freeze, err)
+	}
+}
@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// LabelFsWritable lists the paths, separated by commas, a container is
+	// expected to write to besides its volumes and mounts; set on the image
+	// or the container
+	LabelFsWritable = "com.shipyard.fs.writable"
+
+	// Kinds of a filesystem change
+	FsChangeChanged = "changed"
+	FsChangeAdded   = "added"
+	FsChangeDeleted = "deleted"
+)
+
+// fsChangeKinds are the kinds of the changes endpoint of the engine
+var fsChangeKinds = map[int]string{
+	0: FsChangeChanged,
+	1: FsChangeAdded,
+	2: FsChangeDeleted,
+}
+
+// runtimePaths are written by the engine or by most processes and are
+// always expected to change
+var runtimePaths = []string{
+	"/dev",
+	"/etc/hostname",
+	"/etc/hosts",
+	"/etc/mtab",
+	"/etc/resolv.conf",
+	"/proc",
+	"/run",
+	"/sys",
+	"/tmp",
+	"/var/run",
+	"/var/tmp",
+}
+
+// FsChange is a path of the container differing from its image; Writable
+// is the expected path it falls under and is empty for unexpected changes
+type FsChange struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected bool   `json:"expected"`
+	Writable string `json:"writable,omitempty"`
+}
+
+// FsChanges are the filesystem changes of a container compared to its
+// image; Writable are the paths it is expected to write to
+type FsChanges struct {
+	Container  string      `json:"container"`
+	Image      string      `json:"image"`
+	Writable   []string    `json:"writable"`
+	Changes    []*FsChange `json:"changes"`
+	Unexpected int         `json:"unexpected"`
+}
+
+// ContainerFsChanges returns the paths the container added, changed or
+// deleted since it was created from its image; changes outside its
+// volumes, mounts, runtime paths and the paths of its LabelFsWritable
+// label are unexpected and may point at tampering or an app writing
+// where it should not
+func (m DefaultManager) ContainerFsChanges(id string) (*FsChanges, error) {
+	info, err := m.Container(id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := m.DockerClient().ContainerChanges(info.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the config of the container has the volumes and labels of its
+	// image merged in
+	writable := append([]string{}, runtimePaths...)
+	if info.Config != nil {
+		for p := range info.Config.Volumes {
+			writable = append(writable, p)
+		}
+		for _, p := range strings.Split(info.Config.Labels[LabelFsWritable], ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				writable = append(writable, p)
+			}
+		}
+	}
+	for p := range info.Volumes {
+		writable = append(writable, p)
+	}
+	if info.HostConfig != nil {
+		for _, bind := range info.HostConfig.Binds {
+			// host:container[:options]
+			if parts := strings.Split(bind, ":"); len(parts) > 1 {
+				writable = append(writable, parts[1])
+			}
+		}
+		for p := range info.HostConfig.Tmpfs {
+			writable = append(writable, p)
+		}
+	}
+
+	fs := &FsChanges{
+		Container: info.Id,
+		Image:     info.Image,
+		Writable:  cleanPaths(writable),
+		Changes:   []*FsChange{},
+	}
+	if info.Config != nil {
+		fs.Image = info.Config.Image
+	}
+
+	for _, c := range changes {
+		change := &FsChange{
+			Path: c.Path,
+			Kind: fsChangeKinds[c.Kind],
+		}
+		change.Writable = writablePath(fs.Writable, change)
+		change.Expected = change.Writable != ""
+		if !change.Expected {
+			fs.Unexpected++
+		}
+
+		fs.Changes = append(fs.Changes, change)
+	}
+
+	return fs, nil
+}
+
+// cleanPaths returns the absolute paths sorted without duplicates
+func cleanPaths(paths []string) []string {
+	seen := map[string]bool{}
+	clean := []string{}
+	for _, p := range paths {
+		p = path.Clean("/" + p)
+		if !seen[p] {
+			seen[p] = true
+			clean = append(clean, p)
+		}
+	}
+	sort.Strings(clean)
+
+	return clean
+}
+
+// writablePath returns the writable path the change falls under; the
+// engine reports the directories above every change as changed so they
+// are expected for the writable paths below them as well
+func writablePath(writable []string, change *FsChange) string {
+	p := path.Clean(change.Path)
+	for _, w := range writable {
+		if p == w || strings.HasPrefix(p, strings.TrimSuffix(w, "/")+"/") {
+			return w
+		}
+	}
+
+	if change.Kind != FsChangeChanged {
+		return ""
+	}
+
+	for _, w := range writable {
+		if p == "/" || strings.HasPrefix(w, p+"/") {
+			return w
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+)
+
+func TestContainerFsChanges(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/changes"):
+			json.NewEncoder(w).Encode([]*dockerclient.ContainerChanges{
+				{Path: "/var", Kind: 0},
+				{Path: "/var/lib", Kind: 0},
+				{Path: "/var/lib/app", Kind: 1},
+				{Path: "/var/lib/app/cache.db", Kind: 1},
+				{Path: "/tmp/app.pid", Kind: 1},
+				{Path: "/srv/uploads/a.png", Kind: 1},
+				{Path: "/usr", Kind: 0},
+				{Path: "/usr/bin/curl", Kind: 0},
+				{Path: "/etc/passwd", Kind: 2},
+			})
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			json.NewEncoder(w).Encode(dockerclient.ContainerInfo{
+				Id: "web",
+				Config: &dockerclient.ContainerConfig{
+					Image:   "example/web",
+					Volumes: map[string]struct{}{"/data": {}},
+					Labels:  map[string]string{LabelFsWritable: "/var/lib/app/, /tmp"},
+				},
+				HostConfig: &dockerclient.HostConfig{Binds: []string{"/mnt/uploads:/srv/uploads:rw"}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer engine.Close()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultManager{client: &clusterClient{client: client}}
+
+	fs, err := m.ContainerFsChanges("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.Image != "example/web" || len(fs.Changes) != 9 {
+		t.Fatalf("unexpected changes %+v", fs)
+	}
+
+	unexpected := []string{}
+	for _, c := range fs.Changes {
+		if !c.Expected {
+			unexpected = append(unexpected, c.Kind+" "+c.Path)
+		}
+	}
+
+	if strings.Join(unexpected, ",") != "changed /usr,changed /usr/bin/curl,deleted /etc/passwd" || fs.Unexpected != 3 {
+		t.Fatalf("expected the changes of /usr and /etc/passwd to be unexpected; received %v", unexpected)
+	}
+
+	if c := fs.Changes[3]; c.Writable != "/var/lib/app" || c.Kind != FsChangeAdded {
+		t.Fatalf("expected the cache to be under /var/lib/app; received %+v", c)
+	}
+
+	if c := fs.Changes[5]; c.Writable != "/srv/uploads" {
+		t.Fatalf("expected the upload to be under its bind; received %+v", c)
+	}
+}
+
+func TestWritablePath(t *testing.T) {
+	writable := []string{"/data", "/tmp"}
+
+	for p, expected := range map[string]string{
+		"/data/a":  "/data",
+		"/tmp":     "/tmp",
+		"/datadir": "",
+	} {
+		if w := writablePath(writable, &FsChange{Path: p, Kind: FsChangeAdded}); w != expected {
+			t.Fatalf("expected %s to be under %q; received %q", p, expected, w)
+		}
+	}
+
+	// the directories above a writable path only change because of it
+	if w := writablePath([]string{"/var/lib/app"}, &FsChange{Path: "/var", Kind: FsChangeChanged}); w != "/var/lib/app" {
+		t.Fatalf("expected /var to change for /var/lib/app; received %q", w)
+	}
+
+	if w := writablePath([]string{"/var/lib/app"}, &FsChange{Path: "/var", Kind: FsChangeDeleted}); w != "" {
+		t.Fatalf("expected the deletion of /var to be unexpected; received %q", w)
+	}
+}
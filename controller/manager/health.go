@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+
+	// Dependencies of the controller
+	HealthDatastore = "datastore"
+	HealthDocker    = "docker"
+
+	// healthTimeout is how long a dependency has to answer before it is
+	// reported down
+	healthTimeout = 5 * time.Second
+)
+
+var errHealthTimeout = errors.New("no answer within the health check timeout")
+
+type (
+	// DependencyHealth is the result of checking a dependency
+	DependencyHealth struct {
+		Status  string `json:"status"`
+		Latency string `json:"latency"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	// Health is the status of the controller and of each dependency; the
+	// controller is down when any dependency is
+	Health struct {
+		Status       string                       `json:"status"`
+		Dependencies map[string]*DependencyHealth `json:"dependencies"`
+	}
+)
+
+// Up returns whether the dependency is up; unknown dependencies are down
+func (h *Health) Up(dependency string) bool {
+	d, ok := h.Dependencies[dependency]
+	return ok && d.Status == HealthUp
+}
+
+// Health checks the datastore and the docker endpoint at the same time
+func (m DefaultManager) Health() *Health {
+	return checkHealth(map[string]func() error{
+		HealthDatastore: m.db.Ping,
+		HealthDocker: func() error {
+			_, err := m.DockerClient().Version()
+			return err
+		},
+	}, healthTimeout)
+}
+
+func checkHealth(checks map[string]func() error, timeout time.Duration) *Health {
+	type result struct {
+		name   string
+		health *DependencyHealth
+	}
+
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check func() error) {
+			start := time.Now()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- check()
+			}()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(timeout):
+				err = errHealthTimeout
+			}
+
+			d := &DependencyHealth{
+				Status:  HealthUp,
+				Latency: time.Since(start).String(),
+			}
+			if err != nil {
+				d.Status = HealthDown
+				d.Error = err.Error()
+			}
+
+			results <- result{name, d}
+		}(name, check)
+	}
+
+	h := &Health{
+		Status:       HealthUp,
+		Dependencies: map[string]*DependencyHealth{},
+	}
+	for range checks {
+		r := <-results
+		h.Dependencies[r.name] = r.health
+		if r.health.Status != HealthUp {
+			h.Status = HealthDown
+		}
+	}
+
+	return h
+}
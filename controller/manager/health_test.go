@@ -0,0 +1,41 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	h := checkHealth(map[string]func() error{
+		HealthDatastore: func() error { return nil },
+		HealthDocker: func() error {
+			<-block
+			return nil
+		},
+		"registry": func() error { return errors.New("connection refused") },
+	}, 50*time.Millisecond)
+
+	if h.Status != HealthDown {
+		t.Fatalf("expected the controller to be down; received %s", h.Status)
+	}
+
+	if !h.Up(HealthDatastore) {
+		t.Fatalf("expected the datastore to be up; received %+v", h.Dependencies[HealthDatastore])
+	}
+
+	if d := h.Dependencies[HealthDocker]; d.Status != HealthDown || d.Error != errHealthTimeout.Error() {
+		t.Fatalf("expected docker to time out; received %+v", d)
+	}
+
+	if d := h.Dependencies["registry"]; d.Status != HealthDown || d.Error != "connection refused" {
+		t.Fatalf("expected the error of the check; received %+v", d)
+	}
+
+	if h.Up("unknown") {
+		t.Fatal("expected unknown dependencies to be down")
+	}
+}
@@ -0,0 +1,299 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	housekeepingInterval = 24 * time.Hour
+	// shareLinkRetention keeps expired and revoked share links around for
+	// their access counts before they are deleted
+	shareLinkRetention = 30 * 24 * time.Hour
+	// compactionRatio is the share of free space in the store above which
+	// a compaction is suggested
+	compactionRatio = 0.5
+	// compactionMinSize is the size below which a compaction is not worth
+	// suggesting
+	compactionMinSize = 16 * 1024 * 1024
+)
+
+type (
+	// HousekeepingTask is a cleanup of the datastore; the details list
+	// what was removed
+	HousekeepingTask struct {
+		Name    string   `json:"name"`
+		Removed int      `json:"removed"`
+		Details []string `json:"details"`
+		Error   string   `json:"error,omitempty"`
+	}
+
+	// HousekeepingReport lists what housekeeping removed, the problems
+	// the datastore found with itself and hints to keep it healthy
+	HousekeepingReport struct {
+		StartedAt  time.Time           `json:"started_at"`
+		FinishedAt time.Time           `json:"finished_at"`
+		Datastore  string              `json:"datastore"`
+		Size       *datastore.Size     `json:"size,omitempty"`
+		Tasks      []*HousekeepingTask `json:"tasks"`
+		Problems   []string            `json:"problems"`
+		Hints      []string            `json:"hints"`
+	}
+)
+
+// housekeeper runs the housekeeping once a day
+func (m DefaultManager) housekeeper() {
+	t := time.NewTicker(housekeepingInterval).C
+	for range t {
+		if !m.isLeader() {
+			continue
+		}
+
+		report := m.Housekeeping("")
+		for _, task := range report.Tasks {
+			if task.Error != "" {
+				log.Errorf("housekeeping: %s: %s", task.Name, task.Error)
+			}
+		}
+	}
+}
+
+// Housekeeping purges expired tokens and share links, removes records
+// left behind by deleted ones, verifies the datastore and suggests a
+// compaction when much of it is free; the username is empty when it runs
+// on schedule
+func (m DefaultManager) Housekeeping(username string) *HousekeepingReport {
+	now := time.Now()
+	report := &HousekeepingReport{
+		StartedAt: now,
+		Datastore: m.db.Name(),
+		Problems:  []string{},
+		Hints:     []string{},
+	}
+
+	tasks := []struct {
+		name string
+		fn   func(task *HousekeepingTask, now time.Time) error
+	}{
+		{"expired-tokens", m.purgeExpiredTokens},
+		{"expired-share-links", m.purgeShareLinks},
+		{"orphan-key-usage", m.purgeOrphanKeyUsage},
+		{"dangling-roles", m.purgeDanglingRoles},
+		{"stale-drains", m.purgeStaleDrains},
+	}
+
+	removed := 0
+	for _, t := range tasks {
+		task := &HousekeepingTask{Name: t.name, Details: []string{}}
+		if err := t.fn(task, now); err != nil {
+			task.Error = err.Error()
+		}
+		removed += task.Removed
+
+		report.Tasks = append(report.Tasks, task)
+	}
+
+	problems, err := m.db.Check()
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("error checking the datastore: %s", err))
+	}
+	report.Problems = append(report.Problems, problems...)
+
+	size, err := m.db.Size()
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("error getting the size of the datastore: %s", err))
+	} else {
+		report.Size = size
+		if size.Bytes >= compactionMinSize && float64(size.FreeBytes) > float64(size.Bytes)*compactionRatio {
+			report.Hints = append(report.Hints, fmt.Sprintf("%d of %d bytes of the datastore are free; compact it offline (i.e. bolt compact) to reclaim them", size.FreeBytes, size.Bytes))
+		}
+	}
+
+	report.FinishedAt = time.Now()
+
+	m.logEvent("housekeeping", fmt.Sprintf("removed=%d problems=%d scheduled=%v username=%s", removed, len(report.Problems), username == "", username), []string{"datastore"})
+
+	return report
+}
+
+// purgeExpiredTokens removes the expired auth tokens of every account
+func (m DefaultManager) purgeExpiredTokens(task *HousekeepingTask, now time.Time) error {
+	accounts, err := m.db.Accounts(&datastore.AccountQuery{})
+	if err != nil {
+		return err
+	}
+
+	for _, acct := range accounts {
+		expired := 0
+		for _, t := range acct.Tokens {
+			if m.tokenExpired(t, now) {
+				expired++
+			}
+		}
+
+		if expired == 0 {
+			continue
+		}
+
+		purged := 0
+		if err := m.db.UpdateAccount(acct.Username, func(a *auth.Account) error {
+			tokens := []*auth.AuthToken{}
+			for _, t := range a.Tokens {
+				if !m.tokenExpired(t, now) {
+					tokens = append(tokens, t)
+				}
+			}
+			purged = len(a.Tokens) - len(tokens)
+			a.Tokens = tokens
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		task.Removed += purged
+		task.Details = append(task.Details, fmt.Sprintf("%s: %d", acct.Username, purged))
+	}
+
+	return nil
+}
+
+// purgeShareLinks deletes the share links which expired or were revoked
+// before the retention
+func (m DefaultManager) purgeShareLinks(task *HousekeepingTask, now time.Time) error {
+	links, err := m.db.ShareLinks()
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-shareLinkRetention)
+	for _, link := range links {
+		ended := link.ExpiresAt
+		if link.RevokedAt != nil && link.RevokedAt.Before(ended) {
+			ended = *link.RevokedAt
+		}
+
+		if !ended.Before(cutoff) {
+			continue
+		}
+
+		if err := m.db.DeleteShareLink(link.ID); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+
+		task.Removed++
+		task.Details = append(task.Details, link.ID)
+	}
+
+	return nil
+}
+
+// purgeOrphanKeyUsage removes the usage of deleted service keys
+func (m DefaultManager) purgeOrphanKeyUsage(task *HousekeepingTask, now time.Time) error {
+	removed, err := m.db.DeleteOrphanKeyUsage()
+	task.Removed = removed
+
+	return err
+}
+
+// purgeDanglingRoles removes the roles of accounts which no longer exist;
+// they grant nothing but would grant a role created later with the name
+func (m DefaultManager) purgeDanglingRoles(task *HousekeepingTask, now time.Time) error {
+	roles, err := m.Roles()
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, role := range roles {
+		known[role.RoleName] = true
+	}
+
+	accounts, err := m.db.Accounts(&datastore.AccountQuery{})
+	if err != nil {
+		return err
+	}
+
+	for _, acct := range accounts {
+		dangling := []string{}
+		for _, role := range acct.Roles {
+			if !known[role] {
+				dangling = append(dangling, role)
+			}
+		}
+
+		if len(dangling) == 0 {
+			continue
+		}
+
+		if err := m.db.UpdateAccount(acct.Username, func(a *auth.Account) error {
+			kept := []string{}
+			for _, role := range a.Roles {
+				if known[role] {
+					kept = append(kept, role)
+				}
+			}
+			a.Roles = kept
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		task.Removed += len(dangling)
+		for _, role := range dangling {
+			task.Details = append(task.Details, fmt.Sprintf("%s: %s", acct.Username, role))
+		}
+	}
+
+	return nil
+}
+
+// purgeStaleDrains removes the drain records of swarm nodes which left the
+// cluster; nodes added through Shipyard are kept
+func (m DefaultManager) purgeStaleDrains(task *HousekeepingTask, now time.Time) error {
+	managed, err := m.db.ManagedNodes()
+	if err != nil {
+		return err
+	}
+
+	drainOnly := []string{}
+	for _, node := range managed {
+		if !node.Added() {
+			drainOnly = append(drainOnly, node.Name)
+		}
+	}
+
+	if len(drainOnly) == 0 {
+		return nil
+	}
+
+	nodes, err := m.Nodes()
+	if err != nil {
+		return err
+	}
+
+	inCluster := map[string]bool{}
+	for _, node := range nodes {
+		inCluster[node.Name] = true
+	}
+
+	for _, name := range drainOnly {
+		if inCluster[name] {
+			continue
+		}
+
+		if err := m.db.DeleteManagedNode(name); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+
+		task.Removed++
+		task.Details = append(task.Details, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestHousekeeping(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	m.tokenTTL = time.Hour
+	now := time.Now()
+
+	if err := m.db.CreateAccount(&auth.Account{
+		Username: "alice",
+		Roles:    []string{"admin", "deleted-role"},
+		Tokens: []*auth.AuthToken{
+			{Token: "old", CreatedAt: now.Add(-2 * time.Hour)},
+			{Token: "new", CreatedAt: now},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []*shipyard.ShareLink{
+		{ID: "expired", ExpiresAt: now.Add(-2 * shareLinkRetention)},
+		{ID: "recent", ExpiresAt: now.Add(-time.Hour)},
+		{ID: "active", ExpiresAt: now.Add(time.Hour)},
+	} {
+		if err := m.db.SaveShareLink(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.db.SaveServiceKey(&auth.ServiceKey{Key: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"kept", "deleted"} {
+		if err := m.db.AddServiceKeyUsage(&auth.ServiceKeyUsage{Key: key, RequestCount: 1, LastUsed: now, SourceIPs: []string{"127.0.0.1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := m.Housekeeping("admin")
+
+	removed := map[string]int{}
+	for _, task := range report.Tasks {
+		if task.Error != "" {
+			t.Fatalf("%s: %s", task.Name, task.Error)
+		}
+		removed[task.Name] = task.Removed
+	}
+
+	expected := map[string]int{"expired-tokens": 1, "expired-share-links": 1, "orphan-key-usage": 1, "dangling-roles": 1, "stale-drains": 0}
+	for name, n := range expected {
+		if removed[name] != n {
+			t.Errorf("%s: expected %d removed; received %d", name, n, removed[name])
+		}
+	}
+
+	if len(report.Problems) != 0 || report.Size == nil || report.Size.Bytes == 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	acct, err := m.db.Account("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(acct.Tokens) != 1 || acct.Tokens[0].Token != "new" || len(acct.Roles) != 1 || acct.Roles[0] != "admin" {
+		t.Fatalf("unexpected account %+v", acct)
+	}
+
+	if _, err := m.db.ShareLink("recent"); err != nil {
+		t.Fatalf("expected recently expired links to be kept: %s", err)
+	}
+
+	if _, err := m.db.ServiceKeyUsage("kept"); err != nil {
+		t.Fatalf("expected the usage of existing keys to be kept: %s", err)
+	}
+
+	// nothing is left to remove
+	for _, task := range m.Housekeeping("admin").Tasks {
+		if task.Removed != 0 {
+			t.Errorf("%s: expected nothing to be removed; received %d", task.Name, task.Removed)
+		}
+	}
+}
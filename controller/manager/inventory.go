@@ -0,0 +1,294 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	inventoryInterval = 5 * time.Minute
+)
+
+var (
+	ErrUnknownPluginKind = errors.New("plugin kinds are volume, network, authorization, log or storage")
+)
+
+type (
+	// nodeInventory keeps the plugins, configuration and clocks of the
+	// engines between collections
+	nodeInventory struct {
+		mu      sync.RWMutex
+		plugins map[string]*shipyard.NodePlugins
+		configs map[string]*shipyard.EngineConfig
+		clocks  map[string]*shipyard.NodeClock
+	}
+
+	// engineInfo is the part of the info of an engine the client does not
+	// decode
+	engineInfo struct {
+		ServerVersion   string
+		KernelVersion   string
+		OperatingSystem string
+		Driver          string
+		LoggingDriver   string
+		CgroupDriver    string
+		SystemTime      string
+		Plugins         struct {
+			Volume        []string
+			Network       []string
+			Authorization []string
+			Log           []string
+		}
+		RegistryConfig struct {
+			InsecureRegistryCIDRs []string
+			Mirrors               []string
+			IndexConfigs          map[string]struct {
+				Name   string
+				Secure bool
+			}
+		}
+	}
+)
+
+func newNodeInventory() *nodeInventory {
+	return &nodeInventory{
+		plugins: map[string]*shipyard.NodePlugins{},
+		configs: map[string]*shipyard.EngineConfig{},
+		clocks:  map[string]*shipyard.NodeClock{},
+	}
+}
+
+// plugins returns the plugins of the engine
+func (info *engineInfo) plugins() *shipyard.NodePlugins {
+	return &shipyard.NodePlugins{
+		Volume:        nonNil(info.Plugins.Volume),
+		Network:       nonNil(info.Plugins.Network),
+		Authorization: nonNil(info.Plugins.Authorization),
+		Log:           nonNil(info.Plugins.Log),
+		StorageDriver: info.Driver,
+		LoggingDriver: info.LoggingDriver,
+		CollectedAt:   time.Now(),
+	}
+}
+
+// config returns the configuration of the engine; insecure registries and
+// mirrors are sorted so engines can be compared
+func (info *engineInfo) config() *shipyard.EngineConfig {
+	insecure := append([]string{}, info.RegistryConfig.InsecureRegistryCIDRs...)
+	for _, index := range info.RegistryConfig.IndexConfigs {
+		if !index.Secure {
+			insecure = append(insecure, index.Name)
+		}
+	}
+	sort.Strings(insecure)
+
+	mirrors := append([]string{}, info.RegistryConfig.Mirrors...)
+	sort.Strings(mirrors)
+
+	return &shipyard.EngineConfig{
+		Version:            info.ServerVersion,
+		KernelVersion:      info.KernelVersion,
+		OperatingSystem:    info.OperatingSystem,
+		StorageDriver:      info.Driver,
+		LoggingDriver:      info.LoggingDriver,
+		CgroupDriver:       info.CgroupDriver,
+		InsecureRegistries: insecure,
+		RegistryMirrors:    mirrors,
+	}
+}
+
+func (i *nodeInventory) get(name string) *shipyard.NodePlugins {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.plugins[name]
+}
+
+func (i *nodeInventory) set(name string, plugins *shipyard.NodePlugins) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.plugins[name] = plugins
+}
+
+func (i *nodeInventory) config(name string) *shipyard.EngineConfig {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.configs[name]
+}
+
+func (i *nodeInventory) setConfig(name string, config *shipyard.EngineConfig) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.configs[name] = config
+}
+
+func (i *nodeInventory) clock(name string) *shipyard.NodeClock {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.clocks[name]
+}
+
+func (i *nodeInventory) setClock(name string, clock *shipyard.NodeClock) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.clocks[name] = clock
+}
+
+// apply sets the plugins and clocks of the nodes
+func (i *nodeInventory) apply(nodes []*shipyard.Node) {
+	for _, node := range nodes {
+		node.Plugins = i.get(node.Name)
+		node.Clock = i.clock(node.Name)
+	}
+}
+
+// inventoryCollector collects the plugins of the engines at startup and
+// then periodically
+func (m DefaultManager) inventoryCollector() {
+	t := time.NewTicker(inventoryInterval).C
+	for {
+		if err := m.CollectInventory(); err != nil {
+			log.Errorf("error collecting node plugins: %s", err)
+		}
+
+		<-t
+	}
+}
+
+// CollectInventory collects the plugins, drivers and configuration of the
+// engine of every node and compares its clock with the controller; engines
+// added through Shipyard are reached with their TLS material and the others
+// with the one of the cluster
+func (m DefaultManager) CollectInventory() error {
+	nodes, err := m.Nodes()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		sent := time.Now()
+		info, err := m.engineInfo(node)
+		received := time.Now()
+		if err != nil {
+			log.Warnf("error collecting plugins of %s: %s", node.Name, err)
+
+			// keep what was collected before
+			previous := m.inventory.get(node.Name)
+			plugins := &shipyard.NodePlugins{}
+			if previous != nil {
+				*plugins = *previous
+			}
+			plugins.Error = err.Error()
+
+			m.inventory.set(node.Name, plugins)
+			continue
+		}
+
+		m.inventory.set(node.Name, info.plugins())
+		m.inventory.setConfig(node.Name, info.config())
+
+		clock, err := nodeClock(info.SystemTime, sent, received, m.skewThreshold())
+		if err != nil {
+			log.Warnf("error checking the clock of %s: %s", node.Name, err)
+		}
+		m.inventory.setClock(node.Name, clock)
+	}
+
+	return nil
+}
+
+// nodeClient returns a client for the engine of a swarm node
+func (m DefaultManager) nodeClient(node *shipyard.Node) (*dockerclient.DockerClient, error) {
+	managed, err := m.db.ManagedNode(node.Name)
+	switch {
+	case err == nil && managed.Added():
+		return engineClient(managed)
+	case err != nil && err != datastore.ErrNotFound:
+		return nil, err
+	}
+
+	return dockerclient.NewDockerClient(engineURL(node.Addr), m.DockerClient().TLSConfig)
+}
+
+func (m DefaultManager) engineInfo(node *shipyard.Node) (*engineInfo, error) {
+	client, err := m.nodeClient(node)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.HTTPClient.Get(client.URL.String() + "/info")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error getting info of %s: %s", node.Addr, resp.Status)
+	}
+
+	info := &engineInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// NodesMissingPlugin returns the nodes whose engine lacks the driver of the
+// kind so deploys can warn before placing containers; nodes without an
+// inventory yet are not reported
+func (m DefaultManager) NodesMissingPlugin(kind, name string) ([]*shipyard.Node, error) {
+	switch kind {
+	case shipyard.PluginVolume, shipyard.PluginNetwork, shipyard.PluginAuthorization, shipyard.PluginLog, shipyard.PluginStorage:
+	default:
+		return nil, ErrUnknownPluginKind
+	}
+
+	nodes, err := m.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []*shipyard.Node{}
+	for _, node := range nodes {
+		if node.Plugins != nil && node.Plugins.Error == "" && !node.Plugins.Has(kind, name) {
+			missing = append(missing, node)
+		}
+	}
+
+	return missing, nil
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+
+	return list
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+)
+
+func TestEnginePlugins(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(`{"ServerVersion":"1.12.6","Driver":"overlay2","LoggingDriver":"json-file","Plugins":{"Volume":["local","rexray:latest"],"Network":["bridge","overlay"],"Authorization":null,"Log":["json-file","gelf"]},"RegistryConfig":{"InsecureRegistryCIDRs":["127.0.0.0/8"],"IndexConfigs":{"docker.io":{"Name":"docker.io","Secure":true},"registry.local:5000":{"Name":"registry.local:5000","Secure":false}}}}`))
+	}))
+	defer ts.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+	m.inventory = newNodeInventory()
+
+	info, err := m.engineInfo(&shipyard.Node{Name: "node-1", Addr: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugins := info.plugins()
+
+	if plugins.StorageDriver != "overlay2" || len(plugins.Authorization) != 0 {
+		t.Fatalf("unexpected plugins %+v", plugins)
+	}
+
+	if !plugins.Has(shipyard.PluginVolume, "rexray") {
+		t.Fatal("expected the rexray volume plugin")
+	}
+
+	if plugins.Has(shipyard.PluginNetwork, "weave") {
+		t.Fatal("expected no weave network plugin")
+	}
+
+	// the default logging driver is not always listed with the plugins
+	if !plugins.Has(shipyard.PluginLog, "json-file") || !plugins.Has(shipyard.PluginStorage, "overlay2") {
+		t.Fatal("expected the logging and storage drivers of the engine")
+	}
+
+	config := info.config()
+	if config.Version != "1.12.6" || !reflect.DeepEqual(config.InsecureRegistries, []string{"127.0.0.0/8", "registry.local:5000"}) {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	m.inventory.set("node-1", plugins)
+
+	nodes := []*shipyard.Node{{Name: "node-1"}, {Name: "node-2"}}
+	m.inventory.apply(nodes)
+
+	if nodes[0].Plugins != plugins || nodes[1].Plugins != nil {
+		t.Fatalf("expected only node-1 to have plugins; received %+v %+v", nodes[0].Plugins, nodes[1].Plugins)
+	}
+
+	clock := &shipyard.NodeClock{Skew: 5, Skewed: true}
+	m.inventory.setClock("node-2", clock)
+	m.inventory.apply(nodes)
+
+	if nodes[0].Clock != nil || nodes[1].Clock != clock {
+		t.Fatalf("expected only node-2 to have a clock; received %+v %+v", nodes[0].Clock, nodes[1].Clock)
+	}
+}
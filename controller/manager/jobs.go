@@ -0,0 +1,388 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/cron"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	tblNameJobs = "jobs"
+	jobIDLength = 16
+	// LabelJob is set on the containers run by a job to its id
+	LabelJob = "com.shipyard.job"
+	// jobRestartTimeout is how many seconds a restarted container has to
+	// stop
+	jobRestartTimeout = 10
+)
+
+var (
+	ErrJobDoesNotExist    = errors.New("job does not exist")
+	ErrJobNameNeeded      = errors.New("a job needs a name")
+	ErrInvalidJobSchedule = errors.New("a job needs a schedule like \"0 3 * * *\" or @daily that matches")
+	ErrInvalidJobAction   = errors.New("the action of a job has to be run with an image, restart with a container or prune")
+	ErrJobCreatorMissing  = errors.New("the creator of the job no longer exists")
+	ErrJobCreatorNeeded   = errors.New("jobs have to be saved by an account")
+	ErrJobNotAllowed      = errors.New("the roles of the creator of the job no longer allow its action")
+	ErrJobOutOfScope      = errors.New("the container of the job is not in the label scope of its creator")
+
+	// jobPermissions are needed by the creator of a job to run it
+	jobPermissions = map[string]string{
+		shipyard.JobActionRun:     auth.PermContainersWrite,
+		shipyard.JobActionRestart: auth.PermContainersWrite,
+		shipyard.JobActionPrune:   auth.PermContainersDelete,
+	}
+)
+
+func (m DefaultManager) Jobs() ([]*shipyard.Job, error) {
+	return m.db.Jobs()
+}
+
+func (m DefaultManager) Job(id string) (*shipyard.Job, error) {
+	job, err := m.db.Job(id)
+	if err != nil {
+		return nil, notFound(err, ErrJobDoesNotExist)
+	}
+
+	return job, nil
+}
+
+// validateJob checks the job can run and returns its next run
+func validateJob(job *shipyard.Job, now time.Time) (time.Time, error) {
+	if job.Name == "" {
+		return time.Time{}, ErrJobNameNeeded
+	}
+
+	schedule, err := cron.Parse(job.Schedule)
+	if err != nil {
+		return time.Time{}, ErrInvalidJobSchedule
+	}
+
+	next, err := schedule.Next(now)
+	if err != nil {
+		return time.Time{}, ErrInvalidJobSchedule
+	}
+
+	switch job.Action {
+	case shipyard.JobActionRun:
+		if job.Image == "" {
+			return time.Time{}, ErrInvalidJobAction
+		}
+	case shipyard.JobActionRestart:
+		if job.Container == "" {
+			return time.Time{}, ErrInvalidJobAction
+		}
+	case shipyard.JobActionPrune:
+	default:
+		return time.Time{}, ErrInvalidJobAction
+	}
+
+	return next, nil
+}
+
+// SaveJob creates the job or replaces it when it has an id; the last run
+// of a replaced job is kept. The job runs with the access of the account
+// saving it (CreatedBy), so an account cannot take over the access of the
+// creator by replacing the job.
+func (m DefaultManager) SaveJob(job *shipyard.Job) error {
+	if job.CreatedBy == "" {
+		return ErrJobCreatorNeeded
+	}
+
+	job.Name = strings.TrimSpace(job.Name)
+	job.Schedule = strings.TrimSpace(job.Schedule)
+
+	next, err := validateJob(job, time.Now())
+	if err != nil {
+		return err
+	}
+	job.NextRun = next
+
+	if job.ID == "" {
+		job.ID = generateId(jobIDLength)
+		job.CreatedAt = time.Now()
+		job.LastRun = nil
+	} else {
+		existing, err := m.Job(job.ID)
+		if err != nil {
+			return err
+		}
+
+		job.CreatedAt = existing.CreatedAt
+		job.LastRun = existing.LastRun
+	}
+
+	if err := m.db.SaveJob(job); err != nil {
+		return err
+	}
+
+	m.logEvent("save-job", fmt.Sprintf("id=%s name=%q schedule=%q action=%s disabled=%v created_by=%s",
+		job.ID, job.Name, job.Schedule, job.Action, job.Disabled, job.CreatedBy), []string{"job"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteJob(id string) error {
+	if err := m.db.DeleteJob(id); err != nil {
+		return notFound(err, ErrJobDoesNotExist)
+	}
+
+	m.logEvent("delete-job", fmt.Sprintf("id=%s", id), []string{"job"})
+
+	return nil
+}
+
+// RunJob runs the job now, whether it is disabled or not, and waits for
+// the run; its schedule is not changed
+func (m DefaultManager) RunJob(id, username string) (*shipyard.JobRun, error) {
+	job, err := m.Job(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.runJob(job, username), nil
+}
+
+// jobScheduler runs the jobs which are due every minute; only the leader
+// controller runs them so they run once
+func (m DefaultManager) jobScheduler() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		if !m.isLeader() {
+			continue
+		}
+
+		// slow jobs do not delay the jobs due the next minute
+		go func(now time.Time) {
+			if err := m.runDueJobs(now); err != nil {
+				log.Errorf("error running jobs: %s", err)
+			}
+		}(time.Now())
+	}
+}
+
+// runDueJobs runs the enabled jobs whose next run has passed at once and
+// waits for them; the next run is saved first so a slow job does not start
+// again
+func (m DefaultManager) runDueJobs(now time.Time) error {
+	jobs, err := m.db.Jobs()
+	if err != nil {
+		return err
+	}
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	for _, job := range jobs {
+		if job.Disabled || job.NextRun.After(now) {
+			continue
+		}
+
+		next, err := validateJob(job, now)
+		if err != nil {
+			log.Errorf("job %s: %s", job.Name, err)
+			continue
+		}
+		job.NextRun = next
+
+		if err := m.db.SaveJob(job); err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func(job *shipyard.Job) {
+			defer wg.Done()
+			m.runJob(job, "")
+		}(job)
+	}
+
+	return nil
+}
+
+// runJob runs the job and records the run on it; the username is empty
+// for scheduled runs
+func (m DefaultManager) runJob(job *shipyard.Job, username string) *shipyard.JobRun {
+	run := &shipyard.JobRun{
+		StartedAt: time.Now(),
+		Status:    shipyard.JobRunSucceeded,
+		Manual:    username != "",
+	}
+
+	output, err := m.executeJob(job)
+	run.FinishedAt = time.Now()
+	run.Output = output
+	if err != nil {
+		run.Status = shipyard.JobRunFailed
+		run.Error = err.Error()
+	}
+
+	// the job is read again as it could have changed during the run
+	if current, err := m.db.Job(job.ID); err == nil {
+		current.LastRun = run
+		if err := m.db.SaveJob(current); err != nil {
+			log.Errorf("error saving the run of job %s: %s", job.Name, err)
+		}
+	}
+
+	m.logEvent("run-job", fmt.Sprintf("id=%s name=%q action=%s status=%s output=%q error=%q manual=%v username=%s",
+		job.ID, job.Name, job.Action, run.Status, run.Output, run.Error, run.Manual, username), []string{"job"})
+
+	return run
+}
+
+// executeJob runs the action of the job with the access of its creator;
+// the containers of the job are limited to the label scope of the creator
+func (m DefaultManager) executeJob(job *shipyard.Job) (string, error) {
+	allowed, err := m.HasPermission(job.CreatedBy, jobPermissions[job.Action])
+	if err == ErrAccountDoesNotExist {
+		return "", ErrJobCreatorMissing
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !allowed {
+		return "", ErrJobNotAllowed
+	}
+
+	scope, err := m.containerScope(job.CreatedBy)
+	if err != nil {
+		return "", err
+	}
+
+	switch job.Action {
+	case shipyard.JobActionRun:
+		return m.runJobContainer(job, scope)
+	case shipyard.JobActionRestart:
+		return m.restartJobContainer(job, scope)
+	case shipyard.JobActionPrune:
+		return m.pruneJobContainers(job, scope)
+	}
+
+	return "", ErrInvalidJobAction
+}
+
+// runJobContainer starts a container from the image of the job, pulling
+// it when the cluster does not have it
+func (m DefaultManager) runJobContainer(job *shipyard.Job, scope auth.LabelScope) (string, error) {
+	labels := map[string]string{}
+	for k, v := range job.Labels {
+		labels[k] = v
+	}
+	labels[LabelJob] = job.ID
+
+	if !scope.Matches(labels) {
+		return "", ErrJobOutOfScope
+	}
+
+	if err := m.CheckFreeze(job.CreatedBy, labels[notification.LabelEnvironment], "run job "+job.Name); err != nil {
+		return "", err
+	}
+
+	config := &dockerclient.ContainerConfig{
+		Image:  job.Image,
+		Cmd:    job.Command,
+		Env:    job.Env,
+		Labels: labels,
+	}
+	if err := m.applyDrainConstraints(config); err != nil {
+		return "", err
+	}
+
+	client := m.DockerClient()
+	id, err := client.CreateContainer(config, "", nil)
+	if err == dockerclient.ErrImageNotFound {
+		if err := m.pullImage(DeployJob+":"+job.Name, job.Image); err != nil {
+			return "", err
+		}
+
+		id, err = client.CreateContainer(config, "", nil)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := client.StartContainer(id, &config.HostConfig); err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+func (m DefaultManager) restartJobContainer(job *shipyard.Job, scope auth.LabelScope) (string, error) {
+	info, err := m.Container(job.Container)
+	if err != nil {
+		return "", err
+	}
+
+	labels := map[string]string{}
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+
+	if !scope.Matches(labels) {
+		return "", ErrJobOutOfScope
+	}
+
+	environment := labels[notification.LabelEnvironment]
+
+	if err := m.CheckFreeze(job.CreatedBy, environment, "restart "+job.Container); err != nil {
+		return "", err
+	}
+
+	if err := m.DockerClient().RestartContainer(info.Id, jobRestartTimeout); err != nil {
+		return "", err
+	}
+
+	return info.Id, nil
+}
+
+// pruneJobContainers removes the stopped containers with the labels of the
+// job in the scope of its creator and returns their ids
+func (m DefaultManager) pruneJobContainers(job *shipyard.Job, scope auth.LabelScope) (string, error) {
+	filters := map[string][]string{
+		"status": {"exited", "dead"},
+	}
+	for k, v := range job.Labels {
+		filters["label"] = append(filters["label"], k+"="+v)
+	}
+
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return "", err
+	}
+
+	client := m.DockerClient()
+	containers, err := client.ListContainers(true, false, url.QueryEscape(string(data)))
+	if err != nil {
+		return "", err
+	}
+
+	removed := []string{}
+	for _, c := range containers {
+		if !scope.Matches(c.Labels) {
+			continue
+		}
+
+		if err := client.RemoveContainer(c.Id, false, false); err != nil && err != dockerclient.ErrNotFound {
+			return strings.Join(removed, ","), err
+		}
+
+		removed = append(removed, c.Id)
+	}
+
+	return strings.Join(removed, ","), nil
+}
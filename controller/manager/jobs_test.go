@@ -0,0 +1,220 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestValidateJob(t *testing.T) {
+	now := time.Date(2016, 3, 2, 10, 17, 0, 0, time.UTC)
+
+	next, err := validateJob(&shipyard.Job{Name: "prune", Schedule: "@hourly", Action: shipyard.JobActionPrune}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := time.Date(2016, 3, 2, 11, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Fatalf("expected the next run at %s; received %s", expected, next)
+	}
+
+	for _, c := range []struct {
+		job *shipyard.Job
+		err error
+	}{
+		{&shipyard.Job{Schedule: "@daily", Action: shipyard.JobActionPrune}, ErrJobNameNeeded},
+		{&shipyard.Job{Name: "never", Schedule: "0 0 30 2 *", Action: shipyard.JobActionPrune}, ErrInvalidJobSchedule},
+		{&shipyard.Job{Name: "run", Schedule: "@daily", Action: shipyard.JobActionRun}, ErrInvalidJobAction},
+		{&shipyard.Job{Name: "restart", Schedule: "@daily", Action: shipyard.JobActionRestart}, ErrInvalidJobAction},
+		{&shipyard.Job{Name: "other", Schedule: "@daily", Action: "stop"}, ErrInvalidJobAction},
+	} {
+		if _, err := validateJob(c.job, now); err != c.err {
+			t.Errorf("%s: expected %v; received %v", c.job.Name, c.err, err)
+		}
+	}
+}
+
+func TestRunDueJobs(t *testing.T) {
+	filters := ""
+	removed := []string{}
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			filters = r.URL.Query().Get("filters")
+			w.Write([]byte(`[{"Id":"done","Names":["/node-1/backup"],"Image":"busybox"}]`))
+		case r.Method == "DELETE" && strings.HasSuffix(r.URL.Path, "/containers/done"):
+			removed = append(removed, "done")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	for _, acct := range []*auth.Account{
+		{Username: "alice", Roles: []string{"admin"}},
+		{Username: "bob"},
+	} {
+		if err := m.db.CreateAccount(acct); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	for _, job := range []*shipyard.Job{
+		{ID: "due", Name: "prune backups", Schedule: "@hourly", Action: shipyard.JobActionPrune, Labels: map[string]string{"com.shipyard.job": "backup"}, NextRun: now.Add(-time.Minute), CreatedBy: "alice"},
+		{ID: "later", Name: "prune later", Schedule: "@hourly", Action: shipyard.JobActionPrune, NextRun: now.Add(time.Hour), CreatedBy: "alice"},
+		{ID: "disabled", Name: "prune disabled", Schedule: "@hourly", Action: shipyard.JobActionPrune, Disabled: true, NextRun: now.Add(-time.Minute), CreatedBy: "alice"},
+		{ID: "denied", Name: "prune denied", Schedule: "@hourly", Action: shipyard.JobActionPrune, NextRun: now.Add(-time.Minute), CreatedBy: "bob"},
+	} {
+		if err := m.db.SaveJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.runDueJobs(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 {
+		t.Fatalf("expected the stopped container to be pruned once; received %v", removed)
+	}
+
+	if !strings.Contains(filters, `"label":["com.shipyard.job=backup"]`) || !strings.Contains(filters, `"exited"`) {
+		t.Fatalf("expected stopped containers with the labels to be listed; received %s", filters)
+	}
+
+	due, err := m.db.Job("due")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if due.LastRun == nil || due.LastRun.Status != shipyard.JobRunSucceeded || due.LastRun.Output != "done" {
+		t.Fatalf("expected a successful run; received %+v", due.LastRun)
+	}
+
+	if !due.NextRun.After(now) {
+		t.Fatalf("expected the next run to be scheduled; received %s", due.NextRun)
+	}
+
+	for _, id := range []string{"later", "disabled"} {
+		job, err := m.db.Job(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if job.LastRun != nil {
+			t.Fatalf("expected %s not to run", id)
+		}
+	}
+
+	denied, err := m.db.Job("denied")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if denied.LastRun == nil || denied.LastRun.Status != shipyard.JobRunFailed || denied.LastRun.Error != ErrJobNotAllowed.Error() {
+		t.Fatalf("expected the job of bob to fail; received %+v", denied.LastRun)
+	}
+}
+
+func TestRunJobScope(t *testing.T) {
+	removed := []string{}
+	restarted := []string{}
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"web","Labels":{"team":"web"}},{"Id":"db","Labels":{"team":"db"}}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/db/json"):
+			w.Write([]byte(`{"Id":"db","Config":{"Labels":{"team":"db"}}}`))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/restart"):
+			restarted = append(restarted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "DELETE":
+			removed = append(removed, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	if err := m.db.CreateAccount(&auth.Account{Username: "carol", Roles: []string{"admin"}, LabelScope: auth.LabelScope{"team=web"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	prune := &shipyard.Job{Name: "prune", Schedule: "@daily", Action: shipyard.JobActionPrune}
+	if err := m.SaveJob(prune); err != ErrJobCreatorNeeded {
+		t.Fatalf("expected jobs without a creator to be refused; received %v", err)
+	}
+
+	prune.CreatedBy = "carol"
+	if err := m.SaveJob(prune); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := m.RunJob(prune.ID, "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != shipyard.JobRunSucceeded || len(removed) != 1 || removed[0] != "web" {
+		t.Fatalf("expected only the container in the scope to be pruned; received %+v %v", run, removed)
+	}
+
+	restart := &shipyard.Job{Name: "restart", Schedule: "@daily", Action: shipyard.JobActionRestart, Container: "db", CreatedBy: "carol"}
+	if err := m.SaveJob(restart); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err = m.RunJob(restart.ID, "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != shipyard.JobRunFailed || run.Error != ErrJobOutOfScope.Error() || len(restarted) != 0 {
+		t.Fatalf("expected the restart outside the scope to fail; received %+v %v", run, restarted)
+	}
+
+	// replacing the job of another account runs it with the access of
+	// the account replacing it
+	restart.CreatedBy = "dave"
+	if err := m.SaveJob(restart); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := m.Job(restart.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.CreatedBy != "dave" {
+		t.Fatalf("expected the job to run as the account replacing it; received %s", saved.CreatedBy)
+	}
+}
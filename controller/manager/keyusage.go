@@ -0,0 +1,216 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/utils"
+)
+
+const (
+	// keyUsageFlushInterval is how often the usage of service keys is
+	// written to the datastore; requests only update the buffer
+	keyUsageFlushInterval = 30 * time.Second
+)
+
+var (
+	apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+)
+
+// keyUsageBuffer collects the usage of service keys between flushes and
+// the source addresses each key is known from, so requests do not read or
+// write the datastore
+type keyUsageBuffer struct {
+	mu      sync.Mutex
+	pending map[string]*auth.ServiceKeyUsage
+	known   map[string][]string
+}
+
+func newKeyUsageBuffer() *keyUsageBuffer {
+	return &keyUsageBuffer{
+		pending: map[string]*auth.ServiceKeyUsage{},
+		known:   map[string][]string{},
+	}
+}
+
+// take returns the pending usage and empties the buffer
+func (b *keyUsageBuffer) take() map[string]*auth.ServiceKeyUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending := b.pending
+	b.pending = map[string]*auth.ServiceKeyUsage{}
+
+	return pending
+}
+
+// UsageOperation returns the method and resource of a request (i.e.
+// GET /api/containers); ids and the docker api version are left out so
+// the operations of a client stay few
+func UsageOperation(method, path string) string {
+	path = apiVersionPrefix.ReplaceAllString(path, "/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	resource := "/" + parts[0]
+	if parts[0] == "api" && len(parts) > 1 {
+		resource += "/" + parts[1]
+	}
+
+	return method + " " + resource
+}
+
+func containsAddr(addrs []string, ip string) bool {
+	for _, addr := range addrs {
+		if addr == ip {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addKeyUsage adds the counts of usage to total
+func addKeyUsage(total, usage *auth.ServiceKeyUsage) {
+	if total.Routes == nil {
+		total.Routes = map[string]int{}
+	}
+
+	total.RequestCount += usage.RequestCount
+	if usage.LastUsed.After(total.LastUsed) {
+		total.LastUsed = usage.LastUsed
+	}
+	for _, ip := range usage.SourceIPs {
+		if !containsAddr(total.SourceIPs, ip) {
+			total.SourceIPs = append(total.SourceIPs, ip)
+		}
+	}
+	for route, n := range usage.Routes {
+		total.Routes[route] += n
+	}
+}
+
+// RecordServiceKeyUsage counts the request of the key; the usage is
+// buffered and written every keyUsageFlushInterval. Keys used from a new
+// network are reported as an anomaly.
+func (m DefaultManager) RecordServiceKeyUsage(key, remoteAddr, method, path string) error {
+	ip := utils.RemoteIP(remoteAddr)
+
+	known, err := m.knownKeyAddrs(key)
+	if err != nil {
+		return err
+	}
+
+	if isNewSubnet(ip, known) {
+		m.logAnomaly("", ip, fmt.Sprintf("service key used from new network: key=%s addr=%s", auth.KeyID(key), ip))
+	}
+
+	b := m.keyUsage
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !containsAddr(b.known[key], ip) {
+		b.known[key] = append(b.known[key], ip)
+	}
+
+	usage, ok := b.pending[key]
+	if !ok {
+		usage = &auth.ServiceKeyUsage{Key: key}
+		b.pending[key] = usage
+	}
+	addKeyUsage(usage, &auth.ServiceKeyUsage{
+		RequestCount: 1,
+		LastUsed:     time.Now(),
+		SourceIPs:    []string{ip},
+		Routes:       map[string]int{UsageOperation(method, path): 1},
+	})
+
+	return nil
+}
+
+// knownKeyAddrs returns the addresses the key was used from; they are read
+// from the datastore once per key
+func (m DefaultManager) knownKeyAddrs(key string) ([]string, error) {
+	b := m.keyUsage
+	b.mu.Lock()
+	known, ok := b.known[key]
+	b.mu.Unlock()
+	if ok {
+		return known, nil
+	}
+
+	known = []string{}
+	usage, err := m.db.ServiceKeyUsage(key)
+	switch err {
+	case nil:
+		known = usage.SourceIPs
+	case datastore.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// another request may have added addresses meanwhile
+	for _, ip := range b.known[key] {
+		if !containsAddr(known, ip) {
+			known = append(known, ip)
+		}
+	}
+	b.known[key] = known
+
+	return known, nil
+}
+
+// pendingKeyUsage returns a copy of the usage of the key not yet written
+func (m DefaultManager) pendingKeyUsage(key string) *auth.ServiceKeyUsage {
+	b := m.keyUsage
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage, ok := b.pending[key]
+	if !ok {
+		return nil
+	}
+
+	pending := &auth.ServiceKeyUsage{}
+	addKeyUsage(pending, usage)
+
+	return pending
+}
+
+// FlushServiceKeyUsage writes the buffered usage of the service keys;
+// usage which cannot be written is kept for the next flush
+func (m DefaultManager) FlushServiceKeyUsage() error {
+	var failed error
+	for key, usage := range m.keyUsage.take() {
+		if err := m.db.AddServiceKeyUsage(usage); err != nil {
+			failed = err
+
+			b := m.keyUsage
+			b.mu.Lock()
+			if p, ok := b.pending[key]; ok {
+				addKeyUsage(usage, p)
+			}
+			b.pending[key] = usage
+			b.mu.Unlock()
+		}
+	}
+
+	return failed
+}
+
+func (m DefaultManager) keyUsageFlusher() {
+	t := time.NewTicker(keyUsageFlushInterval).C
+	for range t {
+		if err := m.FlushServiceKeyUsage(); err != nil {
+			log.Errorf("error writing service key usage: %s", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+func TestUsageOperation(t *testing.T) {
+	for path, operation := range map[string]string{
+		"/api/containers/abc/logs": "GET /api/containers",
+		"/api":                     "GET /api",
+		"/v1.24/containers/json":   "GET /containers",
+		"/containers/abc/json":     "GET /containers",
+		"/account/changepassword":  "GET /account",
+	} {
+		if op := UsageOperation("GET", path); op != operation {
+			t.Errorf("%s: expected %s; received %s", path, operation, op)
+		}
+	}
+}
+
+func TestRecordServiceKeyUsage(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if err := m.db.SaveServiceKey(&auth.ServiceKey{Key: "key", Description: "ci"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.keyUsage = newKeyUsageBuffer()
+
+	for _, path := range []string{"/api/containers/abc/json", "/api/containers/def/json", "/api/events"} {
+		if err := m.RecordServiceKeyUsage("key", "10.0.0.1:4242", "GET", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the requests are buffered
+	if _, err := m.db.ServiceKeyUsage("key"); err != datastore.ErrNotFound {
+		t.Fatalf("expected no usage to be written before the flush; received %v", err)
+	}
+
+	usage, err := m.ServiceKeyUsage("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.RequestCount != 3 || len(usage.Routes) != 2 || usage.Routes["GET /api/containers"] != 2 {
+		t.Fatalf("expected the buffered usage per operation; received %+v", usage)
+	}
+
+	if err := m.FlushServiceKeyUsage(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordServiceKeyUsage("key", "10.0.0.2:4242", "POST", "/api/events"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FlushServiceKeyUsage(); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err = m.db.ServiceKeyUsage("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.RequestCount != 4 || len(usage.SourceIPs) != 2 || usage.Routes["POST /api/events"] != 1 {
+		t.Fatalf("expected the flushes to add up; received %+v", usage)
+	}
+}
+
+func TestRecordServiceKeyUsageAnomaly(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	key := "0123456789abcdef0123456789abcdef"
+	if err := m.db.SaveServiceKey(&auth.ServiceKey{Key: key, Description: "ci"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.keyUsage = newKeyUsageBuffer()
+
+	for _, addr := range []string{"10.0.0.1:4242", "192.168.1.1:4242"} {
+		if err := m.RecordServiceKeyUsage(key, addr, "GET", "/api/events"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := m.db.Events(&datastore.EventQuery{Type: "anomaly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected an anomaly for the new network; received %d", len(events))
+	}
+
+	if strings.Contains(events[0].Message, key) {
+		t.Fatalf("expected the anomaly to leave out the key; received %q", events[0].Message)
+	}
+	if !strings.Contains(events[0].Message, auth.KeyID(key)) {
+		t.Fatalf("expected the anomaly to name the key by its id; received %q", events[0].Message)
+	}
+}
@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	// loginRateWindow is the period login attempts are counted over
+	loginRateWindow = time.Minute
+	// defaultLockoutDuration is how long accounts stay locked when no
+	// duration is configured
+	defaultLockoutDuration = 15 * time.Minute
+)
+
+// LoginLimitError is returned when a source address or username attempted
+// more logins than the rate limit allows
+type LoginLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLimitError) Error() string {
+	return fmt.Sprintf("too many login attempts; retry in %s", e.RetryAfter)
+}
+
+// AccountLockedError is returned for logins to accounts locked after too
+// many failed logins
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("the account is locked after too many failed logins until %s; an administrator can unlock it", e.Until.Format(time.RFC3339))
+}
+
+// loginLimiter counts the login attempts of each key (a source address or
+// username) in fixed windows
+type loginLimiter struct {
+	limit   int
+	mu      sync.Mutex
+	windows map[string]*loginWindow
+}
+
+type loginWindow struct {
+	start time.Time
+	count int
+}
+
+func newLoginLimiter(limit int) *loginLimiter {
+	return &loginLimiter{
+		limit:   limit,
+		windows: map[string]*loginWindow{},
+	}
+}
+
+// allow records an attempt for each key and returns how long to wait when
+// one of them is over the limit; a nil limiter or a zero limit allows
+// every attempt
+func (l *loginLimiter) allow(now time.Time, keys ...string) time.Duration {
+	if l == nil || l.limit <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for k, w := range l.windows {
+		if now.Sub(w.start) >= loginRateWindow {
+			delete(l.windows, k)
+		}
+	}
+
+	wait := time.Duration(0)
+	for _, k := range keys {
+		w, ok := l.windows[k]
+		if !ok {
+			w = &loginWindow{start: now}
+			l.windows[k] = w
+		}
+
+		w.count++
+		if w.count > l.limit {
+			if remaining := w.start.Add(loginRateWindow).Sub(now); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+
+	return wait
+}
+
+func (l *loginLimiter) reset(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.windows, key)
+}
+
+// CheckLogin returns a LoginLimitError when the address or username
+// attempted too many logins and an AccountLockedError for locked accounts;
+// it is called before the password is checked
+func (m DefaultManager) CheckLogin(username, addr string) error {
+	if wait := m.loginLimiter.allow(time.Now(), "addr:"+addr, "username:"+username); wait > 0 {
+		return &LoginLimitError{RetryAfter: wait}
+	}
+
+	acct, err := m.db.Account(username)
+	if err != nil {
+		// unknown usernames fail with the password like any other
+		return nil
+	}
+
+	if time.Now().Before(acct.LockedUntil) {
+		return &AccountLockedError{Until: acct.LockedUntil}
+	}
+
+	return nil
+}
+
+// LoginFailed counts a failed login of the account and locks it once the
+// failures in a row reach the threshold
+func (m DefaultManager) LoginFailed(username, addr string) error {
+	if m.lockoutThreshold <= 0 {
+		return nil
+	}
+
+	duration := m.lockoutDuration
+	if duration <= 0 {
+		duration = defaultLockoutDuration
+	}
+
+	locked := time.Time{}
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		a.FailedLogins++
+		if a.FailedLogins >= m.lockoutThreshold {
+			a.LockedUntil = time.Now().Add(duration)
+			a.FailedLogins = 0
+			locked = a.LockedUntil
+		}
+		return nil
+	}); err != nil {
+		if err == ErrAccountDoesNotExist {
+			return nil
+		}
+		return err
+	}
+
+	if !locked.IsZero() {
+		m.logEvent("lock-account", fmt.Sprintf("username=%s addr=%s failures=%d until=%s",
+			username, addr, m.lockoutThreshold, locked.Format(time.RFC3339)), []string{"security"})
+	}
+
+	return nil
+}
+
+// LoginSucceeded clears the failed logins of the account
+func (m DefaultManager) LoginSucceeded(username string) error {
+	acct, err := m.db.Account(username)
+	if err != nil || acct.FailedLogins == 0 {
+		return nil
+	}
+
+	return m.updateAccount(username, func(a *auth.Account) error {
+		a.FailedLogins = 0
+		return nil
+	})
+}
+
+// UnlockAccount clears the lockout and failed logins of the account and
+// the rate limit of its username
+func (m DefaultManager) UnlockAccount(username string) error {
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		a.FailedLogins = 0
+		a.LockedUntil = time.Time{}
+		return nil
+	}); err != nil {
+		return err
+	}
+	m.loginLimiter.reset("username:" + username)
+
+	m.logEvent("unlock-account", fmt.Sprintf("username=%s", username), []string{"security"})
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter(2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if wait := l.allow(now, "addr:10.0.0.1", "username:alice"); wait != 0 {
+			t.Fatalf("expected attempt %d to be allowed; received %s", i+1, wait)
+		}
+	}
+
+	if wait := l.allow(now.Add(20*time.Second), "addr:10.0.0.2", "username:alice"); wait != 40*time.Second {
+		t.Fatalf("expected the username to wait for the window; received %s", wait)
+	}
+
+	if wait := l.allow(now.Add(20*time.Second), "addr:10.0.0.2", "username:bob"); wait != 0 {
+		t.Fatalf("expected other addresses and usernames to be allowed; received %s", wait)
+	}
+
+	if wait := l.allow(now.Add(loginRateWindow), "addr:10.0.0.1", "username:alice"); wait != 0 {
+		t.Fatalf("expected a new window to allow the attempt; received %s", wait)
+	}
+
+	var disabled *loginLimiter
+	if wait := disabled.allow(now, "addr:10.0.0.1"); wait != 0 {
+		t.Fatalf("expected no limit; received %s", wait)
+	}
+}
+
+func TestAccountLockout(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if err := m.db.CreateAccount(&auth.Account{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.lockoutThreshold = 3
+	m.lockoutDuration = time.Hour
+
+	for i := 0; i < 2; i++ {
+		if err := m.LoginFailed("alice", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a successful login starts counting again
+	if err := m.LoginSucceeded("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := m.LoginFailed("alice", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.CheckLogin("alice", "10.0.0.1"); err != nil {
+		t.Fatalf("expected the account not to be locked yet; received %v", err)
+	}
+
+	if err := m.LoginFailed("alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.CheckLogin("alice", "10.0.0.1").(*AccountLockedError); !ok {
+		t.Fatal("expected the account to be locked")
+	}
+
+	acct, err := m.Account("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if acct.LockedUntil.Before(time.Now().Add(59 * time.Minute)) {
+		t.Fatalf("expected the account to be locked for an hour; received %s", acct.LockedUntil)
+	}
+
+	// unknown usernames are not locked
+	if err := m.LoginFailed("mallory", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.UnlockAccount("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CheckLogin("alice", "10.0.0.1"); err != nil {
+		t.Fatalf("expected the account to be unlocked; received %v", err)
+	}
+
+	if err := m.UnlockAccount("mallory"); err != ErrAccountDoesNotExist {
+		t.Fatalf("expected ErrAccountDoesNotExist; received %v", err)
+	}
+}
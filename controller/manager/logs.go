@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	// Streams of the lines of a log
+	LogStdout = "stdout"
+	LogStderr = "stderr"
+
+	// maxLogLine is the longest line kept whole; longer lines are split
+	maxLogLine = 64 * 1024
+)
+
+// LogOptions select the logs of a container; a tail of 0 returns every line
+type LogOptions struct {
+	Follow     bool
+	Tail       int64
+	Timestamps bool
+}
+
+// LogLine is a line of the output of a container without its newline
+type LogLine struct {
+	Stream string `json:"stream"`
+	Line   string `json:"line"`
+}
+
+// LogStream reads the logs of a container line by line. Docker sends the
+// logs of containers without a tty as frames with an 8 byte header giving
+// the stream and the size; a frame can end in the middle of a line so each
+// stream keeps its partial line.
+type LogStream struct {
+	// Name of the container without the leading slash
+	Name string
+
+	body    io.ReadCloser
+	r       *bufio.Reader
+	tty     bool
+	partial map[string][]byte
+	lines   []*LogLine
+	err     error
+}
+
+// NewLogStream reads the logs in the body; logs of containers with a tty
+// are not multiplexed and only have stdout
+func NewLogStream(name string, body io.ReadCloser, tty bool) *LogStream {
+	return &LogStream{
+		Name:    strings.TrimPrefix(name, "/"),
+		body:    body,
+		r:       bufio.NewReader(body),
+		tty:     tty,
+		partial: map[string][]byte{},
+	}
+}
+
+// ContainerLogs returns the logs of the container; the stream has to be
+// closed, which also ends a followed stream
+func (m DefaultManager) ContainerLogs(id string, opts *LogOptions) (*LogStream, error) {
+	info, err := m.Container(id)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := m.DockerClient().ContainerLogs(info.Id, &dockerclient.LogOptions{
+		Follow:     opts.Follow,
+		Stdout:     true,
+		Stderr:     true,
+		Timestamps: opts.Timestamps,
+		Tail:       opts.Tail,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tty := info.Config != nil && info.Config.Tty
+	return NewLogStream(info.Name, body, tty), nil
+}
+
+// Next returns the next line; io.EOF is returned once the logs end
+func (s *LogStream) Next() (*LogLine, error) {
+	for len(s.lines) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+
+		s.read()
+	}
+
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, nil
+}
+
+// read adds the lines of the next frame, or the last partial lines when
+// the logs end
+func (s *LogStream) read() {
+	stream := LogStdout
+	var data []byte
+
+	if s.tty {
+		buf := make([]byte, 32*1024)
+		n, err := s.r.Read(buf)
+		data, s.err = buf[:n], err
+	} else {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(s.r, header); err != nil {
+			s.err = err
+		} else {
+			if header[0] == 2 {
+				stream = LogStderr
+			}
+
+			data = make([]byte, binary.BigEndian.Uint32(header[4:]))
+			if _, err := io.ReadFull(s.r, data); err != nil {
+				s.err = err
+			}
+		}
+	}
+
+	if s.err == io.ErrUnexpectedEOF {
+		s.err = io.EOF
+	}
+
+	s.split(stream, data)
+
+	if s.err != nil {
+		for _, name := range []string{LogStdout, LogStderr} {
+			if len(s.partial[name]) > 0 {
+				s.lines = append(s.lines, &LogLine{Stream: name, Line: string(s.partial[name])})
+				s.partial[name] = nil
+			}
+		}
+	}
+}
+
+func (s *LogStream) split(stream string, data []byte) {
+	buf := append(s.partial[stream], data...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		s.lines = append(s.lines, &LogLine{Stream: stream, Line: string(bytes.TrimSuffix(buf[:i], []byte("\r")))})
+		buf = buf[i+1:]
+	}
+
+	for len(buf) > maxLogLine {
+		s.lines = append(s.lines, &LogLine{Stream: stream, Line: string(buf[:maxLogLine])})
+		buf = buf[maxLogLine:]
+	}
+
+	s.partial[stream] = append([]byte{}, buf...)
+}
+
+// Close closes the logs
+func (s *LogStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func logFrame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func readLogs(t *testing.T, s *LogStream) []LogLine {
+	lines := []LogLine{}
+	for {
+		line, err := s.Next()
+		if err == io.EOF {
+			return lines
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, *line)
+	}
+}
+
+func TestLogStreamDemultiplexes(t *testing.T) {
+	var data []byte
+	// lines split over frames and interleaved with the other stream
+	data = append(data, logFrame(1, "GET / 200\nGET /he")...)
+	data = append(data, logFrame(2, "warning: slow\r\n")...)
+	data = append(data, logFrame(1, "alth 200\n")...)
+	data = append(data, logFrame(2, "no newline")...)
+
+	s := NewLogStream("/web", ioutil.NopCloser(bytes.NewReader(data)), false)
+	if s.Name != "web" {
+		t.Fatalf("expected the name without a slash; received %q", s.Name)
+	}
+
+	expected := []LogLine{
+		{LogStdout, "GET / 200"},
+		{LogStderr, "warning: slow"},
+		{LogStdout, "GET /health 200"},
+		{LogStderr, "no newline"},
+	}
+
+	lines := readLogs(t, s)
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %v; received %v", expected, lines)
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("expected %v; received %v", expected[i], lines[i])
+		}
+	}
+}
+
+func TestLogStreamTTY(t *testing.T) {
+	long := strings.Repeat("x", maxLogLine+10)
+	s := NewLogStream("web", ioutil.NopCloser(strings.NewReader("$ ls\nbin\n"+long)), true)
+
+	lines := readLogs(t, s)
+	if len(lines) != 4 || lines[0].Line != "$ ls" || lines[1].Stream != LogStdout {
+		t.Fatalf("expected the tty output as stdout lines; received %d lines", len(lines))
+	}
+
+	if len(lines[2].Line) != maxLogLine || len(lines[3].Line) != 10 {
+		t.Fatal("expected long lines to be split")
+	}
+}
@@ -2,6 +2,8 @@ package manager
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,13 +13,17 @@ import (
 	"sync"
 	"time"
 
-	"crypto/tls"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/sessions"
 	"github.com/samalba/dockerclient"
 	"github.com/shipyard/shipyard"
 	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/clair"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/controller/preflight"
 	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/geoip"
+	"github.com/shipyard/shipyard/notification"
 	"github.com/shipyard/shipyard/version"
 	r "gopkg.in/dancannon/gorethink.v2"
 )
@@ -32,21 +38,31 @@ const (
 	tblNameWebhookKeys = "webhook_keys"
 	tblNameRegistries  = "registries"
 	tblNameConsole     = "console"
+	tblNameKeyUsage    = "service_key_usage"
+	tblNameAuditLog    = "audit_log"
 	storeKey           = "shipyard"
 	trackerHost        = "http://tracker.shipyard-project.com"
 	NodeHealthUp       = "up"
 	NodeHealthDown     = "down"
+	// webhookSecretLength is the random bytes of webhook secrets
+	webhookSecretLength = 20
 )
 
 var (
 	ErrCannotPingRegistry         = errors.New("Cannot ping registry")
+	ErrRegistryAuthFailed         = errors.New("the registry rejected the credentials")
 	ErrLoginFailure               = errors.New("invalid username or password")
 	ErrAccountExists              = errors.New("account already exists")
 	ErrAccountDoesNotExist        = errors.New("account does not exist")
 	ErrRoleDoesNotExist           = errors.New("role does not exist")
 	ErrNodeDoesNotExist           = errors.New("node does not exist")
 	ErrServiceKeyDoesNotExist     = errors.New("service key does not exist")
+	ErrServiceKeyExpired          = errors.New("service key expired")
+	ErrInvalidServiceKeyScope     = errors.New("service key scopes are permissions (i.e. events:read) or webhook:deploy")
+	ErrInvalidServiceKeyExpiry    = errors.New("service keys have to expire in the future")
 	ErrInvalidAuthToken           = errors.New("invalid auth token")
+	ErrAuthTokenDoesNotExist      = errors.New("auth token does not exist")
+	ErrAuthTokenExpired           = errors.New("auth token expired")
 	ErrExtensionDoesNotExist      = errors.New("extension does not exist")
 	ErrWebhookKeyDoesNotExist     = errors.New("webhook key does not exist")
 	ErrRegistryDoesNotExist       = errors.New("registry does not exist")
@@ -60,10 +76,98 @@ type (
 		database         string
 		authKey          string
 		session          *r.Session
+		db               datastore.Datastore
 		authenticator    auth.Authenticator
 		store            *sessions.CookieStore
-		client           *dockerclient.DockerClient
+		client           *clusterClient
 		disableUsageInfo bool
+		geoDB            *geoip.Database
+		auditChain       bool
+		auditLock        *sync.Mutex
+		certificates     []string
+		// breakGlassTimeout is how long break-glass access lasts
+		breakGlassTimeout time.Duration
+		controllerID      string
+		controllerAddr    string
+		leader            *leaderFlag
+		// tokenTTL is how long auth tokens are valid; zero never expires
+		tokenTTL time.Duration
+		// shareLinkKey signs the tokens of share links
+		shareLinkKey []byte
+		// swarmDiscovery is joined by the swarm agents started on
+		// added nodes
+		swarmDiscovery string
+		// debugImages are the approved images of debug containers
+		debugImages []string
+		// scanner scans images for vulnerabilities; nil when disabled
+		scanner    *clair.Client
+		inventory  *nodeInventory
+		stats      *statsHistory
+		pulls      *pullTracker
+		stackLocks *stackLocks
+		canaries   *canaryTracker
+		// containerWatchers get the changes of the container list
+		containerWatchers *containerWatchers
+		// clusters are the clients of the added clusters
+		clusters    *clusterViews
+		keyUsage    *keyUsageBuffer
+		clientRules *clientRuleCache
+		// clockSkewThreshold is how far the clock of an engine can be
+		// off before it is reported
+		clockSkewThreshold time.Duration
+		loginLimiter       *loginLimiter
+		lockoutThreshold   int
+		lockoutDuration    time.Duration
+	}
+
+	ManagerConfig struct {
+		Addr             string
+		Database         string
+		AuthKey          string
+		Client           *dockerclient.DockerClient
+		DisableUsageInfo bool
+		Authenticator    auth.Authenticator
+		GeoDB            *geoip.Database
+		// AuditChain appends every event to the hash chained audit log
+		AuditChain bool
+		// Certificates are the paths of certificates to alert on before
+		// they expire
+		Certificates []string
+		// BreakGlassTimeout is how long break-glass access lasts
+		BreakGlassTimeout time.Duration
+		// ControllerAddr is the address this controller is reachable at
+		// by its peers
+		ControllerAddr string
+		// TokenTTL is how long auth tokens are valid; zero never expires
+		TokenTTL time.Duration
+		// Datastore is rethinkdb (the default) or bolt; the Addr,
+		// Database and AuthKey of rethinkdb are ignored with bolt
+		Datastore string
+		// BoltPath is the database file of the bolt datastore
+		BoltPath string
+		// ShareLinkSecret signs the tokens of share links; a random
+		// secret is used when empty
+		ShareLinkSecret string
+		// DebugImages are the images debug containers can be launched
+		// from; none disables debug containers
+		DebugImages []string
+		// ScannerURL is the url of the clair scanner; none disables
+		// image scans
+		ScannerURL string
+		// SwarmDiscovery is the discovery backend of the swarm cluster
+		// (i.e. etcd://discovery:4001); when set nodes added through
+		// Shipyard get a swarm agent joining it
+		SwarmDiscovery string
+		// ClockSkewThreshold is how far the clocks of engines can be off
+		// from the one of the controller before nodes are reported
+		ClockSkewThreshold time.Duration
+		// LoginRateLimit is how many logins a source address or username
+		// may attempt per minute; zero disables the limit
+		LoginRateLimit int
+		// LockoutThreshold is how many failed logins in a row lock an
+		// account for LockoutDuration; zero disables lockout
+		LockoutThreshold int
+		LockoutDuration  time.Duration
 	}
 
 	ScaleResult struct {
@@ -72,42 +176,188 @@ type (
 	}
 
 	Manager interface {
-		Accounts() ([]*auth.Account, error)
+		Accounts(query *datastore.AccountQuery) ([]*auth.Account, error)
 		Account(username string) (*auth.Account, error)
 		Authenticate(username, password string) (bool, error)
 		GetAuthenticator() auth.Authenticator
 		SaveAccount(account *auth.Account) error
+		EnrollTOTP(username string) (*TOTPEnrollment, error)
+		ConfirmTOTP(username, code string) error
+		DisableTOTP(username, actor string) error
+		CheckTOTP(username, code string) error
+		// CheckLogin, LoginFailed and LoginSucceeded rate limit logins and
+		// lock accounts after too many failed logins
+		CheckLogin(username, addr string) error
+		LoginFailed(username, addr string) error
+		LoginSucceeded(username string) error
+		UnlockAccount(username string) error
 		DeleteAccount(account *auth.Account) error
+		ExportAccount(username string) (*shipyard.AccountExport, error)
+		Backup() (*shipyard.Backup, error)
+		Restore(backup *shipyard.Backup, dryRun bool) (*RestoreResult, error)
+		AnonymizeAccount(username string) (string, error)
+		ImportAccounts(accounts []*auth.Account) AccountImportResult
+		Housekeeping(username string) *HousekeepingReport
+		CleanupReport() (*CleanupReport, error)
+		Remediate(kind, id, username string) error
 		Roles() ([]*auth.ACL, error)
 		Role(name string) (*auth.ACL, error)
+		SaveRole(role *auth.ACL) error
+		DeleteRole(name string) error
+		HasPermission(username, permission string) (bool, error)
+		// Actions lists what the account may do with a resource
+		Actions(username, resource, id string) ([]*Action, error)
+		AccessReport() (*auth.AccessReport, error)
 		Store() *sessions.CookieStore
 		StoreKey() string
 		Container(id string) (*dockerclient.ContainerInfo, error)
+		ContainerLogs(id string, opts *LogOptions) (*LogStream, error)
 		ScaleContainer(id string, numInstances int) ScaleResult
+		SetRestartPolicy(id string, policy dockerclient.RestartPolicy, username string) error
+		ContainerStats(id string, period time.Duration) (*shipyard.ContainerStatsHistory, error)
+		ContainerFsChanges(id string) (*FsChanges, error)
+		UpdateContainerResources(id string, update *ResourceUpdate, username string) error
+		RedeployImage(image string) RedeployResult
+		PlanRedeploy(image string) (*RedeployPlan, error)
 		SaveServiceKey(key *auth.ServiceKey) error
 		RemoveServiceKey(key string) error
 		SaveEvent(event *shipyard.Event) error
-		Events(limit int) ([]*shipyard.Event, error)
+		Events(query *datastore.EventQuery) ([]*shipyard.Event, error)
+		EventStream(done <-chan struct{}) (<-chan *shipyard.Event, error)
+		// WatchContainers returns the changes of the container list
+		// until done is closed
+		WatchContainers(done <-chan struct{}) <-chan *ContainerDelta
 		PurgeEvents() error
+		VerifyAuditLog() (*shipyard.AuditVerification, error)
+		SaveAuditEntry(entry *shipyard.AuditEntry) error
+		Freezes() ([]*shipyard.Freeze, error)
+		Freeze(id string) (*shipyard.Freeze, error)
+		SaveFreeze(freeze *shipyard.Freeze) error
+		DeleteFreeze(id string) error
+		CheckFreeze(username, environment, change string) error
+		ClientRules() ([]*shipyard.ClientRule, error)
+		ClientRule(id string) (*shipyard.ClientRule, error)
+		SaveClientRule(rule *shipyard.ClientRule) error
+		DeleteClientRule(id string) error
+		CheckClient(userAgent, path string) error
+		Jobs() ([]*shipyard.Job, error)
+		Job(id string) (*shipyard.Job, error)
+		SaveJob(job *shipyard.Job) error
+		DeleteJob(id string) error
+		RunJob(id, username string) (*shipyard.JobRun, error)
+		Query(q *Query, username string) ([]map[string]interface{}, error)
+		Stacks() ([]*shipyard.Stack, error)
+		Stack(name string) (*shipyard.Stack, error)
+		// DeployStack and RedeployStack record the release notes, which
+		// can be nil, on the deployment
+		DeployStack(name string, data []byte, username string, release *shipyard.Release) (*shipyard.Stack, error)
+		RedeployStack(name, username string, release *shipyard.Release) (*shipyard.Stack, error)
+		// Deployments returns the deployments of the stack newest first
+		Deployments(stack string) ([]*shipyard.Deployment, error)
+		RemoveStack(name, username string) error
+		// StopStack and StartStack run a step per service in dependency
+		// order; a timeout of 0 is the default step timeout
+		StopStack(name string, timeout time.Duration, username string) (*shipyard.StackOperation, error)
+		StartStack(name string, timeout time.Duration, username string) (*shipyard.StackOperation, error)
+		// StackTop lists the processes of the running containers of the
+		// stack
+		StackTop(name, psArgs string) (*StackTop, error)
+		StartCanary(stack string, opts *CanaryOptions, username string) (*shipyard.Canary, error)
+		Canary(stack string) (*shipyard.Canary, error)
+		DecideCanary(stack, action, username string) error
+		ExportStack(name string) (*shipyard.TemplateBundle, error)
+		Templates() ([]*shipyard.Template, error)
+		Template(name string) (*shipyard.Template, error)
+		ImportTemplate(bundle *shipyard.TemplateBundle, username string, replace bool) (*shipyard.Template, error)
+		ExportTemplate(name string) (*shipyard.TemplateBundle, error)
+		DeleteTemplate(name string) error
+		// DeployTemplate deploys the template as a stack; with a ttl
+		// the stack is removed once it expires
+		DeployTemplate(name, stackName string, values map[string]string, ttl time.Duration, username string) (*shipyard.Stack, error)
+		AuditEntries(query *datastore.AuditQuery) ([]*shipyard.AuditEntry, error)
 		ServiceKey(key string) (*auth.ServiceKey, error)
+		// ServiceKeyByID returns the service key with the id (see
+		// auth.KeyID)
+		ServiceKeyByID(id string) (*auth.ServiceKey, error)
 		ServiceKeys() ([]*auth.ServiceKey, error)
+		ServiceKeyUsage(key string) (*auth.ServiceKeyUsage, error)
+		// RecordServiceKeyUsage counts the request per operation (see
+		// UsageOperation); the counts are written in batches
+		RecordServiceKeyUsage(key, remoteAddr, method, path string) error
 		NewAuthToken(username string, userAgent string) (*auth.AuthToken, error)
 		VerifyAuthToken(username, token string) error
+		AuthTokens(username string) ([]*auth.AuthToken, error)
+		RevokeAuthToken(username, id string) error
+		RevokeAuthTokens(username string) error
+		RefreshAuthToken(username, token string) (*auth.AuthToken, error)
 		VerifyServiceKey(key string) error
-		NewServiceKey(description string) (*auth.ServiceKey, error)
+		// NewServiceKey generates the key of a service key with the
+		// description, scopes and expiry
+		NewServiceKey(key *auth.ServiceKey) (*auth.ServiceKey, error)
 		ChangePassword(username, password string) error
+		// UpdateProfile and ChangeOwnPassword are the changes users make
+		// to their own account
+		UpdateProfile(username string, profile *auth.Account) (*auth.Account, error)
+		ChangeOwnPassword(username, current, password string) error
+		// ResetPassword returns a one-time token the user sets a new
+		// password with using CompletePasswordReset
+		ResetPassword(username, actor string) (*PasswordReset, error)
+		CompletePasswordReset(username, token, password string) error
 		WebhookKey(key string) (*dockerhub.WebhookKey, error)
 		WebhookKeys() ([]*dockerhub.WebhookKey, error)
-		NewWebhookKey(image string) (*dockerhub.WebhookKey, error)
+		NewWebhookKey(image, branch, maxSeverity string) (*dockerhub.WebhookKey, error)
 		SaveWebhookKey(key *dockerhub.WebhookKey) error
 		DeleteWebhookKey(id string) error
 		DockerClient() *dockerclient.DockerClient
+		// Health checks the datastore and the docker endpoint
+		Health() *Health
+		ClusterSettings() *ClusterSettings
+		UpdateCluster(settings *ClusterSettings, username string) error
+		Clusters() ([]*shipyard.Cluster, error)
+		Cluster(name string) (*shipyard.Cluster, error)
+		AddCluster(cluster *shipyard.Cluster, username string) error
+		ReplaceCluster(cluster *shipyard.Cluster, username string) error
+		RemoveCluster(name, username string) error
+		// ForCluster returns the manager operating against the cluster
+		ForCluster(name string) (Manager, error)
+		LaunchDebugContainer(launch *DebugLaunch, username string) (*DebugContainer, error)
 
 		Nodes() ([]*shipyard.Node, error)
 		Node(name string) (*shipyard.Node, error)
+		ManagedNodes() ([]*shipyard.ManagedNode, error)
+		CollectInventory() error
+		NodesMissingPlugin(kind, name string) ([]*shipyard.Node, error)
+		NodeDrift() (*shipyard.DriftReport, error)
+		RegistryMirror() (*shipyard.RegistryMirrorReport, error)
+		SetRegistryMirror(url, username string) (*shipyard.RegistryMirror, error)
+		RemoveRegistryMirror(username string) error
+		// ContainerDefaults are the env vars and labels added to the
+		// containers of stacks and templates
+		ContainerDefaults() (*shipyard.ContainerDefaults, error)
+		SetContainerDefaults(defaults *shipyard.ContainerDefaults, username string) (*shipyard.ContainerDefaults, error)
+		RemoveContainerDefaults(username string) error
+		ManagedNode(name string) (*shipyard.ManagedNode, error)
+		AddNode(node *shipyard.ManagedNode, username string) error
+		RemoveNode(name, username string, force bool) error
+		DrainNode(name, username string, migrate bool) (*DrainResult, error)
+		UndrainNode(name, username string) error
+		DrainConstraints() ([]string, error)
+		Volumes(node string, danglingOnly bool) ([]*shipyard.Volume, error)
+		CreateVolume(request *VolumeRequest, username string) (*shipyard.Volume, error)
+		RemoveVolume(node, name, username string) error
+		PruneVolumes(node, username string) ([]*shipyard.Volume, error)
+		Networks() ([]*dockerclient.NetworkResource, error)
+		Network(id string) (*dockerclient.NetworkResource, error)
+		CreateNetwork(config *dockerclient.NetworkCreate, username string) (*dockerclient.NetworkCreateResponse, error)
+		RemoveNetwork(id, username string) error
+		ConnectNetwork(id, container, username string) error
+		DisconnectNetwork(id, container string, force bool, username string) error
 
 		AddRegistry(registry *shipyard.Registry) error
 		RemoveRegistry(registry *shipyard.Registry) error
+		ImageScan(registryID, repo, tag string) (*shipyard.ImageScan, error)
+		ScanImage(registryID, repo, tag, username string) (*shipyard.ImageScan, error)
+		CheckImageScan(image, maxSeverity string) error
 		Registries() ([]*shipyard.Registry, error)
 		Registry(name string) (*shipyard.Registry, error)
 		RegistryByAddress(addr string) (*shipyard.Registry, error)
@@ -116,33 +366,153 @@ type (
 		RemoveConsoleSession(c *shipyard.ConsoleSession) error
 		ConsoleSession(token string) (*shipyard.ConsoleSession, error)
 		ValidateConsoleSessionToken(containerId, token string) bool
+
+		ShareLinks() ([]*shipyard.ShareLink, error)
+		NewShareLink(link *shipyard.ShareLink, ttl time.Duration) error
+		RevokeShareLink(id, username string) error
+		UseShareLink(token, view, remoteAddr string) (*shipyard.ShareLink, error)
+		// ShareLinkContainers returns the containers in the label scope
+		// of the creator of the link
+		ShareLinkContainers(link *shipyard.ShareLink) ([]dockerclient.Container, error)
+
+		Notes() ([]*shipyard.Note, error)
+		Note(kind, target string) (*shipyard.Note, error)
+		SaveNote(note *shipyard.Note) error
+		DeleteNote(kind, target string) error
+
+		Notifiers() ([]*notification.Notifier, error)
+		Notifier(id string) (*notification.Notifier, error)
+		SaveNotifier(n *notification.Notifier) error
+		DeleteNotifier(id string) error
+		NotificationRules() ([]*notification.Rule, error)
+		NotificationRule(id string) (*notification.Rule, error)
+		SaveNotificationRule(rule *notification.Rule) error
+		DeleteNotificationRule(id string) error
+		Escalations() ([]*notification.Escalation, error)
+		AcknowledgeEscalation(id, username string) error
+
+		Alerts(status string) ([]*shipyard.Alert, error)
+		Alert(id string) (*shipyard.Alert, error)
+		AcknowledgeAlert(id, username string) error
+		AssignAlert(id, username string) error
+		AddAlertComment(id string, comment *shipyard.AlertComment) error
+
+		ExecPolicies() ([]*auth.ExecPolicy, error)
+		ExecPolicy(id string) (*auth.ExecPolicy, error)
+		SaveExecPolicy(policy *auth.ExecPolicy) error
+		DeleteExecPolicy(id string) error
+		// AuthorizeExec checks the exec of the console session against
+		// the roles of its user or the scopes of its service key
+		AuthorizeExec(cs *shipyard.ConsoleSession, containerId string, cmd []string) (bool, error)
+		ExecRecordings() ([]*shipyard.ExecRecording, error)
+		ExecRecording(id string) (*shipyard.ExecRecording, error)
+		SaveExecRecording(rec *shipyard.ExecRecording) error
+
+		BreakGlass() (*auth.BreakGlass, error)
+		SealBreakGlass(username string) (string, error)
+		UseBreakGlass(credential, reason, remoteAddr, userAgent string) (*auth.AuthToken, error)
+		EndBreakGlass(username string) error
+
+		Controllers() ([]*shipyard.Controller, error)
+		Pulls(deploy string) []*PullProgress
+		SeedDemo(username string) (*DemoResult, error)
+		TeardownDemo(username string) error
+		Preflight() []*preflight.Result
 	}
 )
 
-func NewManager(addr string, database string, authKey string, client *dockerclient.DockerClient, disableUsageInfo bool, authenticator auth.Authenticator) (Manager, error) {
-	log.Debug("setting up rethinkdb session")
-	session, err := r.Connect(r.ConnectOpts{
-		Address:  addr,
-		Database: database,
-		AuthKey:  authKey,
-	})
-	if err != nil {
-		return nil, err
+func NewManager(config ManagerConfig) (Manager, error) {
+	var (
+		session *r.Session
+		db      datastore.Datastore
+	)
+
+	switch config.Datastore {
+	case "", datastore.RethinkDB:
+		log.Debug("setting up rethinkdb session")
+		s, err := r.Connect(r.ConnectOpts{
+			Address:  config.Addr,
+			Database: config.Database,
+			AuthKey:  config.AuthKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Info("checking database")
+
+		r.DBCreate(config.Database).Run(s)
+		session = s
+		db = datastore.NewRethinkDB(s)
+	case datastore.Bolt:
+		log.Infof("using bolt datastore: path=%s", config.BoltPath)
+		d, err := datastore.NewBolt(config.BoltPath)
+		if err != nil {
+			return nil, err
+		}
+
+		db = d
+	default:
+		return nil, fmt.Errorf("%s: %s", datastore.ErrUnknownDatastore, config.Datastore)
+	}
+
+	var scanner *clair.Client
+	if config.ScannerURL != "" {
+		c, err := clair.NewClient(config.ScannerURL)
+		if err != nil {
+			return nil, err
+		}
+		scanner = c
 	}
-	log.Info("checking database")
 
-	r.DBCreate(database).Run(session)
 	m := &DefaultManager{
-		database:         database,
-		authKey:          authKey,
+		database:         config.Database,
+		authKey:          config.AuthKey,
 		session:          session,
-		authenticator:    authenticator,
+		db:               db,
+		authenticator:    config.Authenticator,
 		store:            store,
-		client:           client,
+		client:           &clusterClient{client: config.Client},
 		storeKey:         storeKey,
-		disableUsageInfo: disableUsageInfo,
+		disableUsageInfo: config.DisableUsageInfo,
+		geoDB:            config.GeoDB,
+		auditChain:       config.AuditChain,
+		auditLock:        &sync.Mutex{},
+		certificates:     config.Certificates,
+		// zero uses the default timeout
+		breakGlassTimeout: config.BreakGlassTimeout,
+		controllerID:      generateId(16),
+		controllerAddr:    config.ControllerAddr,
+		leader:            &leaderFlag{},
+		tokenTTL:          config.TokenTTL,
+		shareLinkKey:      shareLinkSecret(config.ShareLinkSecret),
+		swarmDiscovery:    config.SwarmDiscovery,
+		debugImages:       config.DebugImages,
+		scanner:           scanner,
+		inventory:         newNodeInventory(),
+		stats:             newStatsHistory(),
+		pulls:             newPullTracker(),
+		stackLocks:        newStackLocks(),
+		canaries:          newCanaryTracker(),
+		containerWatchers: newContainerWatchers(),
+		clusters:          newClusterViews(),
+		keyUsage:          newKeyUsageBuffer(),
+		clientRules:       &clientRuleCache{},
+		// zero uses the default threshold
+		clockSkewThreshold: config.ClockSkewThreshold,
+		loginLimiter:       newLoginLimiter(config.LoginRateLimit),
+		lockoutThreshold:   config.LockoutThreshold,
+		// zero uses the default duration
+		lockoutDuration: config.LockoutDuration,
+	}
+	if session != nil {
+		m.initdb()
+	}
+
+	// an endpoint changed at runtime replaces the one of the flags
+	if err := m.loadClusterEndpoint(); err != nil {
+		log.Errorf("error loading the cluster endpoint; using the one of the flags: %s", err)
 	}
-	m.initdb()
+
 	m.init()
 	return m, nil
 }
@@ -152,7 +522,7 @@ func (m DefaultManager) Store() *sessions.CookieStore {
 }
 
 func (m DefaultManager) DockerClient() *dockerclient.DockerClient {
-	return m.client
+	return m.client.get()
 }
 
 func (m DefaultManager) StoreKey() string {
@@ -161,7 +531,7 @@ func (m DefaultManager) StoreKey() string {
 
 func (m DefaultManager) initdb() {
 	// create tables if needed
-	tables := []string{tblNameConfig, tblNameEvents, tblNameAccounts, tblNameRoles, tblNameConsole, tblNameServiceKeys, tblNameRegistries, tblNameExtensions, tblNameWebhookKeys}
+	tables := []string{tblNameConfig, tblNameEvents, tblNameAccounts, tblNameRoles, tblNameConsole, tblNameServiceKeys, tblNameRegistries, tblNameExtensions, tblNameWebhookKeys, tblNameKeyUsage, tblNameAuditLog, tblNameNotifiers, tblNameNotificationRules, tblNameEscalations, tblNameAlerts, tblNameExecPolicies, tblNameExecRecordings, tblNameBreakGlass, tblNameControllers, tblNameLeases, tblNameShareLinks, tblNameNotes, tblNameAuditEntries, tblNameFreezes, tblNameClientRules, tblNameJobs, tblNameStacks, tblNameDeployments, tblNameTemplates, tblNameNodes, tblNameClusters, tblNameImageScans}
 	for _, tbl := range tables {
 		_, err := r.Table(tbl).Run(m.session)
 		if err != nil {
@@ -170,14 +540,40 @@ func (m DefaultManager) initdb() {
 			}
 		}
 	}
+
+	m.initSchema()
 }
 
 func (m DefaultManager) init() error {
 	// anonymous usage info
 	go m.usageReport()
+	go m.anomalyDetector()
+	go m.inventoryCollector()
+	go m.statsCollector()
+	go m.housekeeper()
+	go m.jobScheduler()
+	go m.containerWatcher()
+	go m.ttlReaper()
+	go m.keyUsageFlusher()
+	go m.alertMonitor()
+	go m.escalationMonitor()
+	if m.session == nil {
+		log.Warnf("controller status requires rethinkdb; running a single controller: datastore=%s", m.db.Name())
+		return nil
+	}
+	go m.controllerHeartbeat()
 	return nil
 }
 
+// notFound returns the error for a record missing from the datastore
+func notFound(err, e error) error {
+	if err == datastore.ErrNotFound {
+		return e
+	}
+
+	return err
+}
+
 func (m DefaultManager) logEvent(eventType, message string, tags []string) {
 	evt := &shipyard.Event{
 		Type:    eventType,
@@ -232,7 +628,7 @@ func (m DefaultManager) uploadUsage() {
 }
 
 func (m DefaultManager) Container(id string) (*dockerclient.ContainerInfo, error) {
-	return m.client.InspectContainer(id)
+	return m.DockerClient().InspectContainer(id)
 }
 
 func (m DefaultManager) ScaleContainer(id string, numInstances int) ScaleResult {
@@ -249,6 +645,11 @@ func (m DefaultManager) ScaleContainer(id string, numInstances int) ScaleResult
 		return result
 	}
 
+	if err := m.applyDrainConstraints(containerInfo.Config); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
 	for i := 0; i < numInstances; i++ {
 		go func(instance int) {
 			log.Debugf("scaling: id=%s #=%d", containerInfo.Id, instance)
@@ -260,12 +661,12 @@ func (m DefaultManager) ScaleContainer(id string, numInstances int) ScaleResult
 
 			lock.Lock()
 			defer lock.Unlock()
-			id, err := m.client.CreateContainer(config, "", nil)
+			id, err := m.DockerClient().CreateContainer(config, "", nil)
 			if err != nil {
 				errChan <- err
 				return
 			}
-			if err := m.client.StartContainer(id, hostConfig); err != nil {
+			if err := m.DockerClient().StartContainer(id, hostConfig); err != nil {
 				errChan <- err
 				return
 			}
@@ -287,17 +688,17 @@ func (m DefaultManager) ScaleContainer(id string, numInstances int) ScaleResult
 }
 
 func (m DefaultManager) SaveServiceKey(key *auth.ServiceKey) error {
-	if _, err := r.Table(tblNameServiceKeys).Insert(key).RunWrite(m.session); err != nil {
+	if err := m.db.SaveServiceKey(key); err != nil {
 		return err
 	}
 
-	m.logEvent("add-service-key", fmt.Sprintf("description=%s", key.Description), []string{"security"})
+	m.logEvent("add-service-key", fmt.Sprintf("description=%s scopes=%s", key.Description, strings.Join(key.Scopes, ",")), []string{"security"})
 
 	return nil
 }
 
 func (m DefaultManager) RemoveServiceKey(key string) error {
-	if _, err := r.Table(tblNameServiceKeys).Filter(map[string]string{"key": key}).Delete().RunWrite(m.session); err != nil {
+	if err := m.db.DeleteServiceKey(key); err != nil {
 		return err
 	}
 
@@ -307,97 +708,121 @@ func (m DefaultManager) RemoveServiceKey(key string) error {
 }
 
 func (m DefaultManager) SaveEvent(event *shipyard.Event) error {
-	if _, err := r.Table(tblNameEvents).Insert(event).RunWrite(m.session); err != nil {
+	// enrich with the source location when available
+	if m.geoDB != nil && event.RemoteAddr != "" && event.Country == "" {
+		if loc := m.geoDB.Lookup(event.RemoteAddr); loc != nil {
+			event.Country = loc.Country
+			event.ASN = loc.ASN
+		}
+	}
+
+	if err := m.db.SaveEvent(event); err != nil {
 		return err
 	}
 
+	eventsWritten.Inc()
+
+	if m.auditChain {
+		if err := m.appendAuditLog(event); err != nil {
+			return err
+		}
+	}
+
+	go m.dispatchNotifications(event)
+
 	return nil
 }
 
-func (m DefaultManager) Events(limit int) ([]*shipyard.Event, error) {
-	t := r.Table(tblNameEvents).OrderBy(r.Desc("Time"))
-	if limit > -1 {
-		t.Limit(limit)
-	}
-	res, err := t.Run(m.session)
-	if err != nil {
-		return nil, err
-	}
-	events := []*shipyard.Event{}
-	if err := res.All(&events); err != nil {
-		return nil, err
-	}
-	return events, nil
+func (m DefaultManager) Events(query *datastore.EventQuery) ([]*shipyard.Event, error) {
+	return m.db.Events(query)
 }
 
 func (m DefaultManager) PurgeEvents() error {
-	if _, err := r.Table(tblNameEvents).Delete().RunWrite(m.session); err != nil {
-		return err
-	}
-	return nil
+	return m.db.PurgeEvents()
 }
 
 func (m DefaultManager) ServiceKey(key string) (*auth.ServiceKey, error) {
-	res, err := r.Table(tblNameServiceKeys).Filter(map[string]string{"key": key}).Run(m.session)
+	k, err := m.db.ServiceKey(key)
 	if err != nil {
-		return nil, err
-
-	}
-	if res.IsNil() {
-		return nil, ErrServiceKeyDoesNotExist
-	}
-	var k *auth.ServiceKey
-	if err := res.One(&k); err != nil {
-		return nil, err
+		return nil, notFound(err, ErrServiceKeyDoesNotExist)
 	}
 	return k, nil
 }
 
-func (m DefaultManager) ServiceKeys() ([]*auth.ServiceKey, error) {
-	res, err := r.Table(tblNameServiceKeys).Run(m.session)
+func (m DefaultManager) ServiceKeyByID(id string) (*auth.ServiceKey, error) {
+	keys, err := m.db.ServiceKeys()
 	if err != nil {
 		return nil, err
 	}
-	keys := []*auth.ServiceKey{}
-	if err := res.All(&keys); err != nil {
-		return nil, err
+
+	for _, k := range keys {
+		if auth.KeyID(k.Key) == id {
+			return k, nil
+		}
 	}
-	return keys, nil
+
+	return nil, ErrServiceKeyDoesNotExist
 }
 
-func (m DefaultManager) Accounts() ([]*auth.Account, error) {
-	res, err := r.Table(tblNameAccounts).OrderBy(r.Asc("username")).Run(m.session)
-	if err != nil {
+func (m DefaultManager) ServiceKeys() ([]*auth.ServiceKey, error) {
+	return m.db.ServiceKeys()
+}
+
+func (m DefaultManager) ServiceKeyUsage(key string) (*auth.ServiceKeyUsage, error) {
+	if _, err := m.ServiceKey(key); err != nil {
 		return nil, err
 	}
-	accounts := []*auth.Account{}
-	if err := res.All(&accounts); err != nil {
+
+	usage, err := m.db.ServiceKeyUsage(key)
+	// keys that have never been used have no usage record
+	if err == datastore.ErrNotFound {
+		usage = &auth.ServiceKeyUsage{
+			Key:       key,
+			SourceIPs: []string{},
+			Routes:    map[string]int{},
+		}
+	} else if err != nil {
 		return nil, err
 	}
-	return accounts, nil
+
+	// the usage since the last flush is not written yet
+	if pending := m.pendingKeyUsage(key); pending != nil {
+		addKeyUsage(usage, pending)
+	}
+
+	return usage, nil
+}
+
+func (m DefaultManager) Accounts(query *datastore.AccountQuery) ([]*auth.Account, error) {
+	return m.db.Accounts(query)
 }
 
 func (m DefaultManager) Account(username string) (*auth.Account, error) {
-	res, err := r.Table(tblNameAccounts).Filter(map[string]string{"username": username}).Run(m.session)
+	account, err := m.db.Account(username)
 	if err != nil {
-		return nil, err
-
-	}
-	if res.IsNil() {
-		return nil, ErrAccountDoesNotExist
-	}
-	var account *auth.Account
-	if err := res.One(&account); err != nil {
-		return nil, err
+		return nil, notFound(err, ErrAccountDoesNotExist)
 	}
 	return account, nil
 }
 
+// updateAccount saves the changes fn makes to the account
+func (m DefaultManager) updateAccount(username string, fn func(account *auth.Account) error) error {
+	return notFound(m.db.UpdateAccount(username, fn), ErrAccountDoesNotExist)
+}
+
 func (m DefaultManager) SaveAccount(account *auth.Account) error {
 	var (
 		hash      string
 		eventType string
 	)
+	if err := account.LabelScope.Validate(); err != nil {
+		return err
+	}
+
+	if !validEmail(account.Email) {
+		return ErrInvalidEmail
+	}
+
 	if account.Password != "" {
 		h, err := auth.Hash(account.Password)
 		if err != nil {
@@ -414,23 +839,31 @@ func (m DefaultManager) SaveAccount(account *auth.Account) error {
 
 	// update
 	if acct != nil {
-		updates := map[string]interface{}{
-			"first_name": account.FirstName,
-			"last_name":  account.LastName,
-			"roles":      account.Roles,
-		}
-		if account.Password != "" {
-			updates["password"] = hash
-		}
-
-		if _, err := r.Table(tblNameAccounts).Filter(map[string]string{"username": account.Username}).Update(updates).RunWrite(m.session); err != nil {
+		if err := m.updateAccount(account.Username, func(a *auth.Account) error {
+			a.FirstName = account.FirstName
+			a.LastName = account.LastName
+			a.Email = account.Email
+			a.Roles = account.Roles
+			a.LabelScope = account.LabelScope
+			a.ForcePasswordChange = account.ForcePasswordChange
+			if account.Password != "" {
+				a.Password = hash
+			}
+			return nil
+		}); err != nil {
 			return err
 		}
 
 		eventType = "update-account"
 	} else {
 		account.Password = hash
-		if _, err := r.Table(tblNameAccounts).Insert(account).RunWrite(m.session); err != nil {
+		// two-factor authentication is enrolled by the user
+		account.TOTPSecret = ""
+		account.TOTPEnabled = false
+		account.PasswordResetHash = ""
+		account.FailedLogins = 0
+		account.LockedUntil = time.Time{}
+		if err := m.db.CreateAccount(account); err != nil {
 			return err
 		}
 
@@ -443,23 +876,152 @@ func (m DefaultManager) SaveAccount(account *auth.Account) error {
 }
 
 func (m DefaultManager) DeleteAccount(account *auth.Account) error {
-	res, err := r.Table(tblNameAccounts).Filter(map[string]string{"id": account.ID}).Delete().Run(m.session)
+	if err := m.db.DeleteAccount(account.Username); err != nil {
+		return notFound(err, ErrAccountDoesNotExist)
+	}
+
+	m.logEvent("delete-account", fmt.Sprintf("username=%s", account.Username), []string{"security"})
+
+	return nil
+}
+
+func (m DefaultManager) ExportAccount(username string) (*shipyard.AccountExport, error) {
+	acct, err := m.Account(username)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if res.IsNil() {
-		return ErrAccountDoesNotExist
+	// never export secrets
+	sessions := []*auth.AuthToken{}
+	for _, t := range acct.Tokens {
+		sessions = append(sessions, &auth.AuthToken{
+			ID:        auth.TokenID(t.Token),
+			UserAgent: t.UserAgent,
+			CreatedAt: t.CreatedAt,
+			LastUsed:  t.LastUsed,
+		})
+	}
+	acct.Password = ""
+	acct.Tokens = nil
+
+	export := &shipyard.AccountExport{
+		ExportedAt:  time.Now(),
+		Account:     acct,
+		Sessions:    sessions,
+		Jobs:        []*shipyard.Job{},
+		ShareLinks:  []*shipyard.ShareLink{},
+		Freezes:     []*shipyard.Freeze{},
+		ServiceKeys: []*auth.ServiceKey{},
+	}
+
+	if export.Events, err = m.db.Events(&datastore.EventQuery{
+		Username:  username,
+		Ascending: true,
+	}); err != nil {
+		return nil, err
 	}
 
-	m.logEvent("delete-account", fmt.Sprintf("username=%s", account.Username), []string{"security"})
+	if export.AuditEntries, err = m.db.AuditEntries(&datastore.AuditQuery{Username: username}); err != nil {
+		return nil, err
+	}
 
-	return nil
+	jobs, err := m.db.Jobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		if j.CreatedBy == username {
+			export.Jobs = append(export.Jobs, j)
+		}
+	}
+
+	links, err := m.db.ShareLinks()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		if l.CreatedBy == username {
+			export.ShareLinks = append(export.ShareLinks, l)
+		}
+	}
+
+	freezes, err := m.db.Freezes()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range freezes {
+		if f.CreatedBy == username {
+			export.Freezes = append(export.Freezes, f)
+		}
+	}
+
+	keys, err := m.db.ServiceKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.CreatedBy != username {
+			continue
+		}
+
+		// keys are named by their id like in the api
+		key := *k
+		key.ID = auth.KeyID(k.Key)
+		key.Key = ""
+		export.ServiceKeys = append(export.ServiceKeys, &key)
+	}
+
+	m.logEvent("export-account", fmt.Sprintf("username=%s", username), []string{"security"})
+
+	return export, nil
+}
+
+// AnonymizeAccount removes the account and replaces its username in events,
+// audit entries and the hash chained audit log with a random pseudonym,
+// scrubbing the source address and location, so the audit history stays
+// consistent without identifying the user; the pseudonym is returned
+func (m DefaultManager) AnonymizeAccount(username string) (string, error) {
+	acct, err := m.Account(username)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := generatePseudonym()
+	if err != nil {
+		return "", err
+	}
+	pseudonym := fmt.Sprintf("anonymous-%s", id)
+
+	if err := m.db.AnonymizeEvents(username, pseudonym); err != nil {
+		return "", err
+	}
+
+	if err := m.db.AnonymizeAuditEntries(username, pseudonym); err != nil {
+		return "", err
+	}
+
+	if err := m.redactAuditLog(username, pseudonym); err != nil {
+		return "", err
+	}
+
+	if err := m.db.DeleteAccount(acct.Username); err != nil {
+		return "", err
+	}
+
+	m.logEvent("anonymize-account", fmt.Sprintf("username=%s", pseudonym), []string{"security"})
+
+	return pseudonym, nil
 }
 
 func (m DefaultManager) Roles() ([]*auth.ACL, error) {
 	roles := auth.DefaultACLs()
-	return roles, nil
+
+	custom, err := m.customRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(roles, custom...), nil
 }
 
 func (m DefaultManager) Role(name string) (*auth.ACL, error) {
@@ -477,6 +1039,20 @@ func (m DefaultManager) Role(name string) (*auth.ACL, error) {
 	return nil, nil
 }
 
+func (m DefaultManager) AccessReport() (*auth.AccessReport, error) {
+	accounts, err := m.Accounts(&datastore.AccountQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewAccessReport(accounts, acls), nil
+}
+
 func (m DefaultManager) GetAuthenticator() auth.Authenticator {
 	return m.authenticator
 }
@@ -500,42 +1076,75 @@ func (m DefaultManager) Authenticate(username, password string) (bool, error) {
 		return false, ErrLoginFailure
 	}
 
+	if passwordHash != "" {
+		m.upgradePasswordHash(username, password, passwordHash)
+	}
+
 	return true, nil
 }
 
-func (m DefaultManager) NewAuthToken(username string, userAgent string) (*auth.AuthToken, error) {
-	tk, err := m.authenticator.GenerateToken()
+// upgradePasswordHash rehashes the password of a successful login when the
+// stored hash does not match the current hash policy
+func (m DefaultManager) upgradePasswordHash(username, password, hash string) {
+	if !auth.NeedsRehash(hash) {
+		return
+	}
+
+	h, err := auth.Hash(password)
 	if err != nil {
-		return nil, err
+		log.Errorf("error rehashing password: username=%s err=%s", username, err)
+		return
 	}
-	acct, err := m.Account(username)
+
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		acct.Password = h
+		return nil
+	}); err != nil {
+		log.Errorf("error saving rehashed password: username=%s err=%s", username, err)
+		return
+	}
+
+	log.Debugf("upgraded password hash: username=%s algorithm=%s", username, auth.CurrentHashPolicy().Algorithm)
+}
+
+func (m DefaultManager) NewAuthToken(username string, userAgent string) (*auth.AuthToken, error) {
+	tk, err := m.authenticator.GenerateToken()
 	if err != nil {
 		return nil, err
 	}
+	now := time.Now()
 	token := &auth.AuthToken{}
-	tokens := acct.Tokens
-	found := false
-	for _, t := range tokens {
-		if t.UserAgent == userAgent {
-			found = true
-			t.Token = tk
-			token = t
-			break
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		// expired tokens are dropped on login
+		tokens := []*auth.AuthToken{}
+		found := false
+		for _, t := range acct.Tokens {
+			if m.tokenExpired(t, now) && t.UserAgent != userAgent {
+				continue
+			}
+			if t.UserAgent == userAgent {
+				found = true
+				t.Token = tk
+				t.CreatedAt = now
+				t.LastUsed = now
+				t.ExpiresAt = m.tokenExpiry(now)
+				token = t
+			}
+			tokens = append(tokens, t)
 		}
-	}
-	if !found {
-		token = &auth.AuthToken{
-			UserAgent: userAgent,
-			Token:     tk,
+		if !found {
+			token = &auth.AuthToken{
+				UserAgent: userAgent,
+				Token:     tk,
+				CreatedAt: now,
+				LastUsed:  now,
+				ExpiresAt: m.tokenExpiry(now),
+			}
+			tokens = append(tokens, token)
 		}
-		tokens = append(tokens, token)
-	}
-	// delete token
-	if _, err := r.Table(tblNameAccounts).Filter(map[string]string{"username": username}).Filter(r.Row.Field("user_agent").Eq(userAgent)).Delete().Run(m.session); err != nil {
-		return nil, err
-	}
-	// add
-	if _, err := r.Table(tblNameAccounts).Filter(map[string]string{"username": username}).Update(map[string]interface{}{"tokens": tokens}).RunWrite(m.session); err != nil {
+		acct.Tokens = tokens
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 	return token, nil
@@ -546,34 +1155,54 @@ func (m DefaultManager) VerifyAuthToken(username, token string) error {
 	if err != nil {
 		return err
 	}
-	found := false
+	if username == auth.BreakGlassUsername {
+		if err := m.verifyBreakGlass(); err != nil {
+			return err
+		}
+	}
 	for _, t := range acct.Tokens {
 		if token == t.Token {
-			found = true
-			break
+			if m.tokenExpired(t, time.Now()) {
+				return ErrAuthTokenExpired
+			}
+			m.touchAuthToken(username, t)
+			return nil
 		}
 	}
-	if !found {
-		return ErrInvalidAuthToken
-	}
-	return nil
+	return ErrInvalidAuthToken
 }
 
 func (m DefaultManager) VerifyServiceKey(key string) error {
-	if _, err := m.ServiceKey(key); err != nil {
+	k, err := m.ServiceKey(key)
+	if err != nil {
 		return err
 	}
+	if k.Expired(time.Now()) {
+		return ErrServiceKeyExpired
+	}
 	return nil
 }
 
-func (m DefaultManager) NewServiceKey(description string) (*auth.ServiceKey, error) {
+func (m DefaultManager) NewServiceKey(key *auth.ServiceKey) (*auth.ServiceKey, error) {
+	for _, scope := range key.Scopes {
+		if !auth.ValidScope(scope) {
+			return nil, ErrInvalidServiceKeyScope
+		}
+	}
+	if !key.ExpiresAt.IsZero() && key.Expired(time.Now()) {
+		return nil, ErrInvalidServiceKeyExpiry
+	}
+
 	k, err := m.authenticator.GenerateToken()
 	if err != nil {
 		return nil, err
 	}
-	key := &auth.ServiceKey{
+	key = &auth.ServiceKey{
 		Key:         k[24:],
-		Description: description,
+		Description: key.Description,
+		Scopes:      key.Scopes,
+		ExpiresAt:   key.ExpiresAt,
+		CreatedBy:   key.CreatedBy,
 	}
 	if err := m.SaveServiceKey(key); err != nil {
 		return nil, err
@@ -591,7 +1220,11 @@ func (m DefaultManager) ChangePassword(username, password string) error {
 		return err
 	}
 
-	if _, err := r.Table(tblNameAccounts).Filter(map[string]string{"username": username}).Update(map[string]string{"password": hash}).Run(m.session); err != nil {
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		acct.Password = hash
+		acct.ForcePasswordChange = false
+		return nil
+	}); err != nil {
 		return err
 	}
 
@@ -601,43 +1234,39 @@ func (m DefaultManager) ChangePassword(username, password string) error {
 }
 
 func (m DefaultManager) WebhookKey(key string) (*dockerhub.WebhookKey, error) {
-	res, err := r.Table(tblNameWebhookKeys).Filter(map[string]string{"key": key}).Run(m.session)
+	k, err := m.db.WebhookKey(key)
 	if err != nil {
-		return nil, err
-
-	}
-
-	if res.IsNil() {
-		return nil, ErrWebhookKeyDoesNotExist
-
-	}
-
-	var k *dockerhub.WebhookKey
-	if err := res.One(&k); err != nil {
-		return nil, err
-
+		return nil, notFound(err, ErrWebhookKeyDoesNotExist)
 	}
 
 	return k, nil
 }
 
 func (m DefaultManager) WebhookKeys() ([]*dockerhub.WebhookKey, error) {
-	res, err := r.Table(tblNameWebhookKeys).OrderBy(r.Asc("image")).Run(m.session)
-	if err != nil {
-		return nil, err
+	return m.db.WebhookKeys()
+}
+
+// NewWebhookKey creates a key redeploying the image with a random secret
+// for the signatures of GitHub and GitLab webhooks; with a maxSeverity
+// images are scanned and refused above it
+func (m DefaultManager) NewWebhookKey(image, branch, maxSeverity string) (*dockerhub.WebhookKey, error) {
+	if maxSeverity != "" && !shipyard.ValidSeverity(maxSeverity) {
+		return nil, shipyard.ErrInvalidSeverity
 	}
-	keys := []*dockerhub.WebhookKey{}
-	if err := res.All(&keys); err != nil {
+
+	k := generateId(16)
+
+	buf := make([]byte, webhookSecretLength)
+	if _, err := rand.Read(buf); err != nil {
 		return nil, err
 	}
-	return keys, nil
-}
 
-func (m DefaultManager) NewWebhookKey(image string) (*dockerhub.WebhookKey, error) {
-	k := generateId(16)
 	key := &dockerhub.WebhookKey{
-		Key:   k,
-		Image: image,
+		Key:         k,
+		Image:       image,
+		Secret:      hex.EncodeToString(buf),
+		Branch:      branch,
+		MaxSeverity: maxSeverity,
 	}
 
 	if err := m.SaveWebhookKey(key); err != nil {
@@ -648,9 +1277,8 @@ func (m DefaultManager) NewWebhookKey(image string) (*dockerhub.WebhookKey, erro
 }
 
 func (m DefaultManager) SaveWebhookKey(key *dockerhub.WebhookKey) error {
-	if _, err := r.Table(tblNameWebhookKeys).Insert(key).RunWrite(m.session); err != nil {
+	if err := m.db.SaveWebhookKey(key); err != nil {
 		return err
-
 	}
 
 	m.logEvent("add-webhook-key", fmt.Sprintf("image=%s", key.Image), []string{"webhook"})
@@ -664,15 +1292,8 @@ func (m DefaultManager) DeleteWebhookKey(id string) error {
 		return err
 
 	}
-	res, err := r.Table(tblNameWebhookKeys).Get(key.ID).Delete().Run(m.session)
-	if err != nil {
-		return err
-
-	}
-
-	if res.IsNil() {
-		return ErrWebhookKeyDoesNotExist
-
+	if err := m.db.DeleteWebhookKey(key.ID); err != nil {
+		return notFound(err, ErrWebhookKeyDoesNotExist)
 	}
 
 	m.logEvent("delete-webhook-key", fmt.Sprintf("image=%s", key.Image), []string{"webhook"})
@@ -681,7 +1302,7 @@ func (m DefaultManager) DeleteWebhookKey(id string) error {
 }
 
 func (m DefaultManager) Nodes() ([]*shipyard.Node, error) {
-	info, err := m.client.Info()
+	info, err := m.DockerClient().Info()
 	if err != nil {
 		return nil, err
 	}
@@ -691,6 +1312,12 @@ func (m DefaultManager) Nodes() ([]*shipyard.Node, error) {
 		return nil, err
 	}
 
+	if err := m.markDrained(nodes); err != nil {
+		return nil, err
+	}
+
+	m.inventory.apply(nodes)
+
 	return nodes, nil
 }
 
@@ -709,23 +1336,48 @@ func (m DefaultManager) Node(name string) (*shipyard.Node, error) {
 	return nil, nil
 }
 
+// PingRegistry checks the registry is reachable; when the registry has no
+// version the v2 api is tried first, then v1, and the version is set
 func (m DefaultManager) PingRegistry(registry *shipyard.Registry) error {
+	if registry.Version == shipyard.RegistryV1 {
+		return pingRegistry(registry, "/v1/_ping")
+	}
 
-	// TODO: Please note the trailing forward slash / which is needed for Artifactory, else you get a 404.
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v2/", registry.Addr), nil)
+	err := pingRegistry(registry, "/v2/")
+	if err == nil || registry.Version == shipyard.RegistryV2 {
+		if err == nil {
+			registry.Version = shipyard.RegistryV2
+		}
 
-	if err != nil {
 		return err
 	}
 
-	req.SetBasicAuth(registry.Username, registry.Password)
+	if v1Err := pingRegistry(registry, "/v1/_ping"); v1Err != nil {
+		return err
+	}
 
-	var tlsConfig *tls.Config
+	registry.Version = shipyard.RegistryV1
 
-	tlsConfig = nil
+	return nil
+}
 
-	if registry.TlsSkipVerify {
-		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+func pingRegistry(registry *shipyard.Registry, path string) error {
+	// TODO: Please note the trailing forward slash / which is needed for Artifactory, else you get a 404.
+	req, err := http.NewRequest("GET", registry.Addr+path, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if registry.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+registry.Token)
+	} else {
+		req.SetBasicAuth(registry.Username, registry.Password)
+	}
+
+	tlsConfig, err := registry.TLSConfig()
+	if err != nil {
+		return err
 	}
 
 	// Create unsecured client
@@ -740,6 +1392,12 @@ func (m DefaultManager) PingRegistry(registry *shipyard.Registry) error {
 	if err != nil {
 		return err
 	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrRegistryAuthFailed
+	}
+
 	if resp.StatusCode != 200 {
 		return errors.New(resp.Status)
 	}
@@ -748,18 +1406,23 @@ func (m DefaultManager) PingRegistry(registry *shipyard.Registry) error {
 }
 
 func (m DefaultManager) AddRegistry(registry *shipyard.Registry) error {
-
-	if err := registry.InitRegistryClient(); err != nil {
+	// an invalid ca bundle would only be reported as a failed ping
+	if _, err := registry.TLSConfig(); err != nil {
 		return err
 	}
 
 	// TODO: consider not doing a test on adding the record, perhaps have a pingRegistry route that does this through API.
+	// the ping also detects the api version used by the client
 	if err := m.PingRegistry(registry); err != nil {
 		log.Error(err)
 		return ErrCannotPingRegistry
 	}
 
-	if _, err := r.Table(tblNameRegistries).Insert(registry).RunWrite(m.session); err != nil {
+	if err := registry.InitRegistryClient(); err != nil {
+		return err
+	}
+
+	if err := m.db.SaveRegistry(registry); err != nil {
 		return err
 	}
 	m.logEvent("add-registry", fmt.Sprintf("name=%s endpoint=%s", registry.Name, registry.Addr), []string{"registry"})
@@ -768,14 +1431,8 @@ func (m DefaultManager) AddRegistry(registry *shipyard.Registry) error {
 }
 
 func (m DefaultManager) RemoveRegistry(registry *shipyard.Registry) error {
-	res, err := r.Table(tblNameRegistries).Get(registry.ID).Delete().Run(m.session)
-	defer res.Close()
-	if err != nil {
-		return err
-	}
-
-	if res.IsNil() {
-		return ErrRegistryDoesNotExist
+	if err := m.db.DeleteRegistry(registry.ID); err != nil {
+		return notFound(err, ErrRegistryDoesNotExist)
 	}
 
 	m.logEvent("delete-registry", fmt.Sprintf("name=%s endpoint=%s", registry.Name, registry.Addr), []string{"registry"})
@@ -784,17 +1441,11 @@ func (m DefaultManager) RemoveRegistry(registry *shipyard.Registry) error {
 }
 
 func (m DefaultManager) Registries() ([]*shipyard.Registry, error) {
-	res, err := r.Table(tblNameRegistries).OrderBy(r.Asc("name")).Run(m.session)
-	defer res.Close()
+	regs, err := m.db.Registries()
 	if err != nil {
 		return nil, err
 	}
 
-	regs := []*shipyard.Registry{}
-	if err := res.All(&regs); err != nil {
-		return nil, err
-	}
-
 	for _, registry := range regs {
 		if err := registry.InitRegistryClient(); err != nil {
 			log.Errorf("%s", err.Error())
@@ -805,18 +1456,9 @@ func (m DefaultManager) Registries() ([]*shipyard.Registry, error) {
 }
 
 func (m DefaultManager) Registry(id string) (*shipyard.Registry, error) {
-	res, err := r.Table(tblNameRegistries).Filter(map[string]string{"id": id}).Run(m.session)
-	defer res.Close()
+	reg, err := m.db.Registry(id)
 	if err != nil {
-		return nil, err
-
-	}
-	if res.IsNil() {
-		return nil, ErrRegistryDoesNotExist
-	}
-	var reg *shipyard.Registry
-	if err := res.One(&reg); err != nil {
-		return nil, err
+		return nil, notFound(err, ErrRegistryDoesNotExist)
 	}
 
 	if err := reg.InitRegistryClient(); err != nil {
@@ -828,17 +1470,12 @@ func (m DefaultManager) Registry(id string) (*shipyard.Registry, error) {
 }
 
 func (m DefaultManager) RegistryByAddress(addr string) (*shipyard.Registry, error) {
-	res, err := r.Table(tblNameRegistries).Filter(map[string]string{"addr": addr}).Run(m.session)
-	defer res.Close()
-	if err != nil {
-		return nil, err
-	}
-	if res.IsNil() {
+	reg, err := m.db.RegistryByAddress(addr)
+	if err == datastore.ErrNotFound {
 		log.Debugf("Could not find registry with address %s", addr)
 		return nil, ErrRegistryDoesNotExist
 	}
-	var reg *shipyard.Registry
-	if err := res.One(&reg); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
@@ -851,7 +1488,7 @@ func (m DefaultManager) RegistryByAddress(addr string) (*shipyard.Registry, erro
 }
 
 func (m DefaultManager) CreateConsoleSession(c *shipyard.ConsoleSession) error {
-	if _, err := r.Table(tblNameConsole).Insert(c).RunWrite(m.session); err != nil {
+	if err := m.db.SaveConsoleSession(c); err != nil {
 		return err
 	}
 
@@ -861,31 +1498,13 @@ func (m DefaultManager) CreateConsoleSession(c *shipyard.ConsoleSession) error {
 }
 
 func (m DefaultManager) RemoveConsoleSession(c *shipyard.ConsoleSession) error {
-	res, err := r.Table(tblNameConsole).Get(c.ID).Delete().Run(m.session)
-	if err != nil {
-		return err
-	}
-
-	if res.IsNil() {
-		return ErrConsoleSessionDoesNotExist
-	}
-
-	return nil
+	return notFound(m.db.DeleteConsoleSession(c.ID), ErrConsoleSessionDoesNotExist)
 }
 
 func (m DefaultManager) ConsoleSession(token string) (*shipyard.ConsoleSession, error) {
-	res, err := r.Table(tblNameConsole).Filter(map[string]string{"token": token}).Run(m.session)
+	c, err := m.db.ConsoleSession(token)
 	if err != nil {
-		return nil, err
-	}
-
-	if res.IsNil() {
-		return nil, ErrConsoleSessionDoesNotExist
-	}
-
-	var c *shipyard.ConsoleSession
-	if err := res.One(&c); err != nil {
-		return nil, err
+		return nil, notFound(err, ErrConsoleSessionDoesNotExist)
 	}
 
 	return c, nil
@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+// newTestManager returns a manager backed by a temporary bolt store and a
+// func to close and remove it
+func newTestManager(t *testing.T) (DefaultManager, func()) {
+	dir, err := ioutil.TempDir("", "shipyard-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := datastore.NewBolt(filepath.Join(dir, "shipyard.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return DefaultManager{db: db}, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// saveAccountData stores an account with events, audit entries and
+// resources of its own and of another account
+func saveAccountData(t *testing.T, m DefaultManager) {
+	if err := m.db.CreateAccount(&auth.Account{Username: "bob", Roles: []string{"admin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, evt := range []*shipyard.Event{
+		{Type: "login", Username: "bob", RemoteAddr: "10.0.0.1", Time: now},
+		{Type: "add-account", Username: "admin", Message: "username=bob", Time: now},
+	} {
+		if err := m.db.SaveEvent(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, username := range []string{"bob", "admin"} {
+		if err := m.db.SaveAuditEntry(&shipyard.AuditEntry{Time: now, Username: username, RemoteAddr: "10.0.0.1", Method: "POST", Route: "/api/jobs"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.db.SaveJob(&shipyard.Job{Name: "prune-" + username, Schedule: "@daily", Action: shipyard.JobActionPrune, CreatedBy: username}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.db.SaveShareLink(&shipyard.ShareLink{Views: []string{shipyard.ShareViewDashboard}, CreatedBy: username, ExpiresAt: now.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.db.SaveFreeze(&shipyard.Freeze{Name: "release-" + username, Start: now, End: now.Add(time.Hour), CreatedBy: username}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.db.SaveServiceKey(&auth.ServiceKey{Key: "key-of-" + username, CreatedBy: username}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExportAccount(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	saveAccountData(t, m)
+
+	export, err := m.ExportAccount("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(export.Events) != 1 || len(export.AuditEntries) != 1 || export.AuditEntries[0].Username != "bob" {
+		t.Fatalf("expected the events and audit entries of bob; received %+v %+v", export.Events, export.AuditEntries)
+	}
+
+	if len(export.Jobs) != 1 || export.Jobs[0].Name != "prune-bob" || len(export.ShareLinks) != 1 || len(export.Freezes) != 1 || export.Freezes[0].Name != "release-bob" {
+		t.Fatalf("expected the resources created by bob; received %+v %+v %+v", export.Jobs, export.ShareLinks, export.Freezes)
+	}
+
+	if len(export.ServiceKeys) != 1 || export.ServiceKeys[0].ID != auth.KeyID("key-of-bob") {
+		t.Fatalf("expected the service key of bob named by its id; received %+v", export.ServiceKeys)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "key-of-bob") {
+		t.Fatalf("expected the service key to be left out; received %s", data)
+	}
+}
+
+func TestAnonymizeAccount(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	saveAccountData(t, m)
+
+	pseudonym, err := m.AnonymizeAccount("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := m.db.Events(&datastore.EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, evt := range events {
+		if evt.Username == "bob" || strings.Contains(evt.Message, "bob") {
+			t.Fatalf("expected bob to be replaced in every event; received %+v", evt)
+		}
+	}
+
+	added, err := m.db.Events(&datastore.EventQuery{Type: "add-account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(added) != 1 || added[0].Message != "username="+pseudonym {
+		t.Fatalf("expected the pseudonym in the message; received %+v", added)
+	}
+
+	entries, err := m.db.AuditEntries(&datastore.AuditQuery{Username: pseudonym})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].RemoteAddr != "" {
+		t.Fatalf("expected the audit entry to be anonymized; received %+v", entries)
+	}
+}
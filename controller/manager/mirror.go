@@ -0,0 +1,117 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shipyard/shipyard"
+)
+
+var (
+	ErrRegistryMirrorNotSet  = errors.New("no registry mirror is set")
+	ErrInvalidRegistryMirror = errors.New("a registry mirror needs an http or https url")
+)
+
+// normalizeMirror returns the mirror url without a trailing slash so the
+// mirrors reported by engines can be compared
+func normalizeMirror(mirror string) string {
+	return strings.TrimRight(strings.ToLower(mirror), "/")
+}
+
+// RegistryMirror returns the mirror of the cluster and which engines use
+// it according to the last inventory
+func (m DefaultManager) RegistryMirror() (*shipyard.RegistryMirrorReport, error) {
+	mirror, err := m.db.RegistryMirror()
+	if err != nil {
+		return nil, notFound(err, ErrRegistryMirrorNotSet)
+	}
+
+	nodes, err := m.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string]*shipyard.EngineConfig{}
+	for _, node := range nodes {
+		configs[node.Name] = m.inventory.config(node.Name)
+	}
+
+	return mirrorReport(mirror, configs), nil
+}
+
+// mirrorReport sorts the nodes by whether their engine uses the mirror
+func mirrorReport(mirror *shipyard.RegistryMirror, configs map[string]*shipyard.EngineConfig) *shipyard.RegistryMirrorReport {
+	report := &shipyard.RegistryMirrorReport{
+		Mirror:       mirror,
+		DaemonConfig: map[string][]string{"registry-mirrors": {mirror.URL}},
+		Configured:   []string{},
+		Missing:      []string{},
+		Unknown:      []string{},
+	}
+
+	expected := normalizeMirror(mirror.URL)
+	for name, config := range configs {
+		if config == nil {
+			report.Unknown = append(report.Unknown, name)
+			continue
+		}
+
+		configured := false
+		for _, m := range config.RegistryMirrors {
+			if normalizeMirror(m) == expected {
+				configured = true
+			}
+		}
+
+		if configured {
+			report.Configured = append(report.Configured, name)
+		} else {
+			report.Missing = append(report.Missing, name)
+		}
+	}
+
+	sort.Strings(report.Configured)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unknown)
+
+	return report
+}
+
+// SetRegistryMirror sets the mirror every engine of the cluster should
+// pull Docker Hub images through
+func (m DefaultManager) SetRegistryMirror(mirrorURL, username string) (*shipyard.RegistryMirror, error) {
+	u, err := url.Parse(mirrorURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidRegistryMirror
+	}
+
+	mirror := &shipyard.RegistryMirror{
+		URL:   mirrorURL,
+		SetBy: username,
+		SetAt: time.Now(),
+	}
+
+	if err := m.db.SaveRegistryMirror(mirror); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("set-registry-mirror", fmt.Sprintf("url=%s username=%s", mirrorURL, username), []string{"registry"})
+
+	return mirror, nil
+}
+
+// RemoveRegistryMirror unsets the mirror of the cluster; the engines keep
+// their own configuration
+func (m DefaultManager) RemoveRegistryMirror(username string) error {
+	if err := m.db.DeleteRegistryMirror(); err != nil {
+		return notFound(err, ErrRegistryMirrorNotSet)
+	}
+
+	m.logEvent("remove-registry-mirror", fmt.Sprintf("username=%s", username), []string{"registry"})
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestMirrorReport(t *testing.T) {
+	report := mirrorReport(&shipyard.RegistryMirror{URL: "https://Mirror.local:5000"}, map[string]*shipyard.EngineConfig{
+		"node-1": {RegistryMirrors: []string{"https://mirror.local:5000/"}},
+		"node-2": {RegistryMirrors: []string{"https://other.local"}},
+		"node-3": {},
+		"node-4": nil,
+	})
+
+	if fmt.Sprint(report.Configured) != "[node-1]" || fmt.Sprint(report.Missing) != "[node-2 node-3]" || fmt.Sprint(report.Unknown) != "[node-4]" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if mirrors := report.DaemonConfig["registry-mirrors"]; len(mirrors) != 1 || mirrors[0] != "https://Mirror.local:5000" {
+		t.Fatalf("expected the daemon configuration of the mirror; received %v", report.DaemonConfig)
+	}
+}
+
+func TestSetRegistryMirror(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for _, u := range []string{"", "mirror.local", "ftp://mirror.local", "https://"} {
+		if _, err := m.SetRegistryMirror(u, "admin"); err != ErrInvalidRegistryMirror {
+			t.Errorf("%q: expected %s; received %v", u, ErrInvalidRegistryMirror, err)
+		}
+	}
+
+	if _, err := m.SetRegistryMirror("https://mirror.local", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror, err := m.db.RegistryMirror()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mirror.URL != "https://mirror.local" || mirror.SetBy != "admin" {
+		t.Fatalf("expected the mirror to be saved; received %+v", mirror)
+	}
+
+	if err := m.RemoveRegistryMirror("admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RemoveRegistryMirror("admin"); err != ErrRegistryMirrorNotSet {
+		t.Fatalf("expected %s; received %v", ErrRegistryMirrorNotSet, err)
+	}
+}
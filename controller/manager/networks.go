@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+var (
+	ErrNetworkDoesNotExist = errors.New("network does not exist")
+	ErrNetworkNameRequired = errors.New("the network needs a name")
+	ErrNetworkInUse        = errors.New("the network has containers connected")
+)
+
+// networkError maps the errors of swarm for a network; missing networks
+// and containers are both 404 so the message tells them apart and missing
+// containers are dockerclient.ErrNotFound as for Container
+func networkError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "no such container"):
+		return dockerclient.ErrNotFound
+	case err == dockerclient.ErrNotFound, strings.Contains(msg, "no such network"), strings.Contains(msg, "not found"):
+		return ErrNetworkDoesNotExist
+	case strings.Contains(msg, "active endpoints"):
+		return ErrNetworkInUse
+	}
+
+	if e, ok := err.(dockerclient.Error); ok && e.StatusCode == http.StatusConflict {
+		return ErrNetworkInUse
+	}
+
+	return err
+}
+
+// Networks lists the networks of the cluster; networks local to a node
+// are named after it (i.e. node-1/bridge)
+func (m DefaultManager) Networks() ([]*dockerclient.NetworkResource, error) {
+	return m.DockerClient().ListNetworks("")
+}
+
+// Network returns the network with its connected containers
+func (m DefaultManager) Network(id string) (*dockerclient.NetworkResource, error) {
+	network, err := m.DockerClient().InspectNetwork(id)
+	if err != nil {
+		return nil, networkError(err)
+	}
+
+	return network, nil
+}
+
+func (m DefaultManager) CreateNetwork(config *dockerclient.NetworkCreate, username string) (*dockerclient.NetworkCreateResponse, error) {
+	if config.Name == "" {
+		return nil, ErrNetworkNameRequired
+	}
+
+	resp, err := m.DockerClient().CreateNetwork(config)
+	if err != nil {
+		return nil, err
+	}
+
+	m.logEvent("create-network", fmt.Sprintf("id=%s name=%s driver=%s username=%s", resp.ID, config.Name, config.Driver, username), []string{"network"})
+
+	return resp, nil
+}
+
+// RemoveNetwork removes the network; networks with containers connected
+// are kept
+func (m DefaultManager) RemoveNetwork(id, username string) error {
+	if err := m.DockerClient().RemoveNetwork(id); err != nil {
+		return networkError(err)
+	}
+
+	m.logEvent("remove-network", fmt.Sprintf("id=%s username=%s", id, username), []string{"network"})
+
+	return nil
+}
+
+func (m DefaultManager) ConnectNetwork(id, container, username string) error {
+	if err := m.DockerClient().ConnectNetwork(id, container); err != nil {
+		return networkError(err)
+	}
+
+	m.logEvent("connect-network", fmt.Sprintf("id=%s container=%s username=%s", id, container, username), []string{"network"})
+
+	return nil
+}
+
+// DisconnectNetwork disconnects the container from the network; force
+// disconnects containers which are not running
+func (m DefaultManager) DisconnectNetwork(id, container string, force bool, username string) error {
+	if err := m.DockerClient().DisconnectNetwork(id, container, force); err != nil {
+		return networkError(err)
+	}
+
+	m.logEvent("disconnect-network", fmt.Sprintf("id=%s container=%s force=%v username=%s", id, container, force, username), []string{"network"})
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+)
+
+func TestNetworkError(t *testing.T) {
+	errs := map[error]error{
+		nil:                                     nil,
+		dockerclient.ErrNotFound:                ErrNetworkDoesNotExist,
+		errors.New("network backend not found"): ErrNetworkDoesNotExist,
+		errors.New("No such container: web"):    dockerclient.ErrNotFound,
+		errors.New("error while removing network: network backend has active endpoints"): ErrNetworkInUse,
+		dockerclient.Error{StatusCode: 409}:                                              ErrNetworkInUse,
+		dockerclient.Error{StatusCode: 500}:                                              dockerclient.Error{StatusCode: 500},
+	}
+
+	for err, expected := range errs {
+		if e := networkError(err); e != expected {
+			t.Fatalf("expected %v for %v; received %v", expected, err, e)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package manager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	tblNameNodes = "managed_nodes"
+
+	swarmAgentImage = "swarm:latest"
+	swarmAgentName  = "shipyard-swarm-agent"
+)
+
+var (
+	ErrNodeExists        = errors.New("a node with the name already exists")
+	ErrNodeAddrNeeded    = errors.New("a node needs the address of its engine")
+	ErrNodeNotAdded      = errors.New("only nodes added through shipyard can be removed")
+	ErrNodeHasContainers = errors.New("the node still runs containers; drain it with migrate or force the removal")
+	ErrInvalidNodeTLS    = errors.New("tls needs the ca certificate, the certificate and the key")
+)
+
+type DrainResult struct {
+	Node     *shipyard.ManagedNode
+	Migrated []string
+	Errors   []string
+}
+
+func (m DefaultManager) ManagedNodes() ([]*shipyard.ManagedNode, error) {
+	return m.db.ManagedNodes()
+}
+
+func (m DefaultManager) ManagedNode(name string) (*shipyard.ManagedNode, error) {
+	node, err := m.db.ManagedNode(name)
+	if err != nil {
+		return nil, notFound(err, ErrNodeDoesNotExist)
+	}
+
+	return node, nil
+}
+
+// engineURL returns the address of an engine as a url for the docker
+// client; engines are usually given as host:port
+func engineURL(addr string) string {
+	if !strings.Contains(addr, "://") {
+		return "tcp://" + addr
+	}
+
+	return addr
+}
+
+// engineClient connects to the engine of the node with its TLS material
+func engineClient(node *shipyard.ManagedNode) (*dockerclient.DockerClient, error) {
+	if node.TLSCACert == "" && node.TLSCert == "" && node.TLSKey == "" {
+		return dockerclient.NewDockerClient(engineURL(node.Addr), nil)
+	}
+
+	if node.TLSCACert == "" || node.TLSCert == "" || node.TLSKey == "" {
+		return nil, ErrInvalidNodeTLS
+	}
+
+	cert, err := tls.X509KeyPair([]byte(node.TLSCert), []byte(node.TLSKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls certificate: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(node.TLSCACert)) {
+		return nil, fmt.Errorf("invalid tls ca certificate")
+	}
+
+	// the engine is verified against the ca it was added with
+	tlsConfig := &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	return dockerclient.NewDockerClient(engineURL(node.Addr), tlsConfig)
+}
+
+// startSwarmAgent runs a swarm agent on the engine joining the discovery
+// backend of the cluster and returns the id of its container
+func (m DefaultManager) startSwarmAgent(client *dockerclient.DockerClient, node *shipyard.ManagedNode) (string, error) {
+	advertise := node.Addr
+	if i := strings.Index(advertise, "://"); i > -1 {
+		advertise = advertise[i+3:]
+	}
+
+	if err := client.PullImage(swarmAgentImage, nil); err != nil {
+		return "", fmt.Errorf("error pulling %s: %s", swarmAgentImage, err)
+	}
+
+	config := &dockerclient.ContainerConfig{
+		Image: swarmAgentImage,
+		Cmd:   []string{"join", "--advertise", advertise, m.swarmDiscovery},
+		HostConfig: dockerclient.HostConfig{
+			RestartPolicy: dockerclient.RestartPolicy{Name: "always"},
+		},
+	}
+
+	id, err := client.CreateContainer(config, swarmAgentName, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating swarm agent: %s", err)
+	}
+
+	if err := client.StartContainer(id, &config.HostConfig); err != nil {
+		client.RemoveContainer(id, true, false)
+		return "", fmt.Errorf("error starting swarm agent: %s", err)
+	}
+
+	return id, nil
+}
+
+// AddNode registers an engine after checking it can be reached with its
+// TLS material; with a swarm discovery configured a swarm agent is started
+// on the engine so it joins the cluster, otherwise the engine has to be
+// joined to swarm separately
+func (m DefaultManager) AddNode(node *shipyard.ManagedNode, username string) error {
+	node.Addr = strings.TrimSpace(node.Addr)
+	if node.Addr == "" {
+		return ErrNodeAddrNeeded
+	}
+
+	client, err := engineClient(node)
+	if err != nil {
+		return err
+	}
+
+	info, err := client.Info()
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %s", node.Addr, err)
+	}
+
+	if node.Name == "" {
+		node.Name = info.Name
+	}
+
+	if _, err := m.db.ManagedNode(node.Name); err == nil {
+		return ErrNodeExists
+	} else if err != datastore.ErrNotFound {
+		return err
+	}
+
+	if m.swarmDiscovery != "" {
+		agent, err := m.startSwarmAgent(client, node)
+		if err != nil {
+			return err
+		}
+		node.Agent = agent
+	}
+
+	node.AddedBy = username
+	node.AddedAt = time.Now()
+	node.Drained = false
+
+	if err := m.db.SaveManagedNode(node); err != nil {
+		return err
+	}
+
+	m.logEvent("add-node", fmt.Sprintf("name=%s addr=%s username=%s", node.Name, node.Addr, username), []string{"cluster"})
+
+	return nil
+}
+
+// RemoveNode removes an engine added through Shipyard; its swarm agent is
+// removed so swarm drops the node. Nodes still running containers are
+// only removed with force.
+func (m DefaultManager) RemoveNode(name, username string, force bool) error {
+	node, err := m.ManagedNode(name)
+	if err != nil {
+		return err
+	}
+
+	if !node.Added() {
+		return ErrNodeNotAdded
+	}
+
+	client, err := engineClient(node)
+	if err != nil {
+		return err
+	}
+
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %s", node.Addr, err)
+	}
+
+	for _, c := range containers {
+		if c.Id != node.Agent && !force {
+			return ErrNodeHasContainers
+		}
+	}
+
+	if node.Agent != "" {
+		if err := client.RemoveContainer(node.Agent, true, false); err != nil {
+			log.Errorf("error removing swarm agent of %s: %s", name, err)
+		}
+	}
+
+	if err := m.db.DeleteManagedNode(name); err != nil {
+		return notFound(err, ErrNodeDoesNotExist)
+	}
+
+	m.logEvent("remove-node", fmt.Sprintf("name=%s force=%v username=%s", name, force, username), []string{"cluster"})
+
+	return nil
+}
+
+// DrainNode stops Shipyard from placing containers on the node; with
+// migrate the containers on the node are recreated elsewhere, leaving the
+// ones in a frozen environment
+func (m DefaultManager) DrainNode(name, username string, migrate bool) (*DrainResult, error) {
+	node, err := m.db.ManagedNode(name)
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		// swarm nodes can be drained without being added to shipyard
+		n, err := m.Node(name)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			return nil, ErrNodeDoesNotExist
+		}
+		node = &shipyard.ManagedNode{Name: name}
+	default:
+		return nil, err
+	}
+
+	if !node.Drained {
+		node.Drained = true
+		node.DrainedBy = username
+		node.DrainedAt = time.Now()
+
+		if err := m.db.SaveManagedNode(node); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DrainResult{
+		Node:     node,
+		Migrated: []string{},
+		Errors:   []string{},
+	}
+
+	if migrate {
+		m.migrateContainers(name, username, result)
+	}
+
+	m.logEvent("drain-node", fmt.Sprintf("name=%s migrate=%v migrated=%d errors=%d username=%s", name, migrate, len(result.Migrated), len(result.Errors), username), []string{"cluster"})
+
+	return result, nil
+}
+
+func (m DefaultManager) migrateContainers(name, username string, result *DrainResult) {
+	containers, err := m.DockerClient().ListContainers(true, false, "")
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+
+	for _, c := range containers {
+		if containerNode(c) != name || containerName(c) == swarmAgentName {
+			continue
+		}
+
+		if err := m.CheckFreeze(username, c.Labels[notification.LabelEnvironment], "migrate off node "+name); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", c.Id, err))
+			continue
+		}
+
+		id, err := m.redeployContainer(c.Id, c.Image)
+		if err != nil {
+			log.Errorf("drain: error migrating %s: %s", c.Id, err)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", c.Id, err))
+			continue
+		}
+
+		result.Migrated = append(result.Migrated, id)
+	}
+}
+
+// UndrainNode lets Shipyard place containers on the node again
+func (m DefaultManager) UndrainNode(name, username string) error {
+	node, err := m.ManagedNode(name)
+	if err != nil {
+		return err
+	}
+
+	if !node.Drained {
+		return nil
+	}
+
+	// drained swarm nodes only have a record for the drain
+	if !node.Added() {
+		if err := m.db.DeleteManagedNode(name); err != nil {
+			return notFound(err, ErrNodeDoesNotExist)
+		}
+	} else {
+		node.Drained = false
+		node.DrainedBy = ""
+		node.DrainedAt = time.Time{}
+
+		if err := m.db.SaveManagedNode(node); err != nil {
+			return err
+		}
+	}
+
+	m.logEvent("undrain-node", fmt.Sprintf("name=%s username=%s", name, username), []string{"cluster"})
+
+	return nil
+}
+
+// containerNode returns the node of a container listed by swarm; swarm
+// prefixes container names with the node name
+func containerNode(c dockerclient.Container) string {
+	for _, n := range c.Names {
+		parts := strings.SplitN(strings.TrimPrefix(n, "/"), "/", 2)
+		if len(parts) == 2 {
+			return parts[0]
+		}
+	}
+
+	return ""
+}
+
+func containerName(c dockerclient.Container) string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+
+	return c.Names[0][strings.LastIndex(c.Names[0], "/")+1:]
+}
+
+// DrainConstraints returns the swarm constraints keeping new containers
+// off drained nodes; they are set as environment of container configs
+func (m DefaultManager) DrainConstraints() ([]string, error) {
+	nodes, err := m.db.ManagedNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	constraints := []string{}
+	for _, node := range nodes {
+		if node.Drained {
+			constraints = append(constraints, "constraint:node!="+node.Name)
+		}
+	}
+
+	return constraints, nil
+}
+
+// applyDrainConstraints adds the drain constraints to the config
+func (m DefaultManager) applyDrainConstraints(config *dockerclient.ContainerConfig) error {
+	constraints, err := m.DrainConstraints()
+	if err != nil {
+		return err
+	}
+
+	config.Env = appendMissing(config.Env, constraints)
+
+	return nil
+}
+
+// appendMissing appends the values not in the list yet
+func appendMissing(list, values []string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// markDrained sets the drained flag of the swarm nodes
+func (m DefaultManager) markDrained(nodes []*shipyard.Node) error {
+	managed, err := m.db.ManagedNodes()
+	if err != nil {
+		return err
+	}
+
+	drained := map[string]bool{}
+	for _, node := range managed {
+		drained[node.Name] = node.Drained
+	}
+
+	for _, node := range nodes {
+		node.Drained = drained[node.Name]
+	}
+
+	return nil
+}
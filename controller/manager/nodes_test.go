@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+)
+
+func TestDrainConstraints(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for _, node := range []*shipyard.ManagedNode{
+		{Name: "node-1", Addr: "10.0.0.1:2376", AddedAt: time.Now()},
+		{Name: "node-2", Drained: true},
+	} {
+		if err := m.db.SaveManagedNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &dockerclient.ContainerConfig{Env: []string{"A=1", "constraint:node!=node-2"}}
+	if err := m.applyDrainConstraints(config); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"A=1", "constraint:node!=node-2"}
+	if !reflect.DeepEqual(config.Env, expected) {
+		t.Fatalf("expected %v; received %v", expected, config.Env)
+	}
+
+	nodes := []*shipyard.Node{{Name: "node-1"}, {Name: "node-2"}, {Name: "node-3"}}
+	if err := m.markDrained(nodes); err != nil {
+		t.Fatal(err)
+	}
+
+	if nodes[0].Drained || !nodes[1].Drained || nodes[2].Drained {
+		t.Fatalf("expected only node-2 to be drained; received %+v %+v %+v", nodes[0], nodes[1], nodes[2])
+	}
+}
+
+func TestContainerNode(t *testing.T) {
+	tests := []struct {
+		names []string
+		node  string
+		name  string
+	}{
+		{[]string{"/node-1/web"}, "node-1", "web"},
+		{[]string{"/node-1/app/db", "/node-1/db"}, "node-1", "db"},
+		{[]string{"/web"}, "", "web"},
+		{nil, "", ""},
+	}
+
+	for _, test := range tests {
+		c := dockerclient.Container{Names: test.names}
+		if node := containerNode(c); node != test.node {
+			t.Errorf("expected node %q for %v; received %q", test.node, test.names, node)
+		}
+		if name := containerName(c); name != test.name {
+			t.Errorf("expected name %q for %v; received %q", test.name, test.names, name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+)
+
+const (
+	tblNameNotes = "notes"
+)
+
+var (
+	ErrNoteDoesNotExist = errors.New("note does not exist")
+	ErrInvalidNoteKind  = errors.New("notes can be attached to a container, stack or node")
+	ErrNoteTargetNeeded = errors.New("a note needs the name of what it is attached to")
+	ErrInvalidRunbook   = errors.New("runbooks have to be http or https links")
+)
+
+func validNoteKind(kind string) bool {
+	switch kind {
+	case shipyard.NoteContainer, shipyard.NoteStack, shipyard.NoteNode:
+		return true
+	}
+
+	return false
+}
+
+func (m DefaultManager) Notes() ([]*shipyard.Note, error) {
+	return m.db.Notes()
+}
+
+func (m DefaultManager) Note(kind, target string) (*shipyard.Note, error) {
+	note, err := m.db.Note(shipyard.NoteID(kind, target))
+	if err != nil {
+		return nil, notFound(err, ErrNoteDoesNotExist)
+	}
+
+	return note, nil
+}
+
+// SaveNote creates or replaces the note of the kind and target of the note
+func (m DefaultManager) SaveNote(note *shipyard.Note) error {
+	if !validNoteKind(note.Kind) {
+		return ErrInvalidNoteKind
+	}
+
+	// container names are reported with a leading slash by docker
+	note.Target = strings.TrimPrefix(note.Target, "/")
+	if note.Target == "" {
+		return ErrNoteTargetNeeded
+	}
+
+	for _, runbook := range note.Runbooks {
+		u, err := url.Parse(runbook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidRunbook
+		}
+	}
+
+	note.ID = shipyard.NoteID(note.Kind, note.Target)
+	note.UpdatedAt = time.Now()
+
+	if err := m.db.SaveNote(note); err != nil {
+		return err
+	}
+
+	m.logEvent("save-note", fmt.Sprintf("kind=%s target=%s updated_by=%s", note.Kind, note.Target, note.UpdatedBy), []string{"notes"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteNote(kind, target string) error {
+	if err := m.db.DeleteNote(shipyard.NoteID(kind, target)); err != nil {
+		return notFound(err, ErrNoteDoesNotExist)
+	}
+
+	m.logEvent("delete-note", fmt.Sprintf("kind=%s target=%s", kind, target), []string{"notes"})
+
+	return nil
+}
+
+// notesFor returns the notes of the targets of a kind that exist
+func (m DefaultManager) notesFor(kind string, targets ...string) []*shipyard.Note {
+	notes := []*shipyard.Note{}
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+
+		note, err := m.Note(kind, target)
+		if err != nil {
+			if err != ErrNoteDoesNotExist {
+				log.Errorf("error loading note: kind=%s target=%s err=%s", kind, target, err)
+			}
+			continue
+		}
+
+		notes = append(notes, note)
+	}
+
+	return notes
+}
+
+// containerNotes returns the notes of a container and of its stack
+func (m DefaultManager) containerNotes(name string, labels map[string]string) []*shipyard.Note {
+	notes := m.notesFor(shipyard.NoteContainer, strings.TrimPrefix(name, "/"))
+
+	stack := labels[shipyard.LabelStack]
+	if stack == "" {
+		stack = labels[shipyard.LabelSwarmStack]
+	}
+
+	return append(notes, m.notesFor(shipyard.NoteStack, stack)...)
+}
+
+// runbookText renders notes for a notification
+func runbookText(notes []*shipyard.Note) string {
+	lines := []string{}
+	for _, note := range notes {
+		if note.Text != "" {
+			lines = append(lines, fmt.Sprintf("%s %s: %s", note.Kind, note.Target, note.Text))
+		}
+
+		lines = append(lines, note.Runbooks...)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,23 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestRunbookText(t *testing.T) {
+	notes := []*shipyard.Note{
+		{Kind: shipyard.NoteContainer, Target: "web", Text: "check the upstream first", Runbooks: []string{"https://wiki.local/web"}},
+		{Kind: shipyard.NoteStack, Target: "shop", Runbooks: []string{"https://wiki.local/shop"}},
+	}
+
+	expected := "container web: check the upstream first\nhttps://wiki.local/web\nhttps://wiki.local/shop"
+	if text := runbookText(notes); text != expected {
+		t.Fatalf("expected %q; received %q", expected, text)
+	}
+
+	if text := runbookText(nil); text != "" {
+		t.Fatalf("expected no runbook without notes; received %q", text)
+	}
+}
@@ -0,0 +1,389 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	tblNameNotifiers         = "notifiers"
+	tblNameNotificationRules = "notification_rules"
+	tblNameEscalations       = "escalations"
+	escalationCheckInterval  = 30 * time.Second
+)
+
+var (
+	ErrNotifierDoesNotExist         = errors.New("notifier does not exist")
+	ErrNotificationRuleDoesNotExist = errors.New("notification rule does not exist")
+	ErrEscalationDoesNotExist       = errors.New("escalation does not exist")
+)
+
+// eventSeverity maps an event to a notification severity
+func eventSeverity(evt *shipyard.Event) string {
+	switch evt.Type {
+	case "anomaly", "oom", "die":
+		return notification.SeverityCritical
+	}
+
+	// alerts carry their severity as a tag
+	for _, t := range evt.Tags {
+		if t == notification.SeverityCritical {
+			return notification.SeverityCritical
+		}
+	}
+
+	for _, t := range evt.Tags {
+		if t == "security" || t == "alert" {
+			return notification.SeverityWarning
+		}
+	}
+
+	return notification.SeverityInfo
+}
+
+// newNotificationMessage builds the message for an event; the environment
+// and team come from the labels of the container the event is about
+func newNotificationMessage(evt *shipyard.Event) *notification.Message {
+	msg := &notification.Message{
+		Type:     evt.Type,
+		Severity: eventSeverity(evt),
+		Text:     fmt.Sprintf("%s: %s", evt.Type, evt.Message),
+		Time:     evt.Time,
+		Tags:     evt.Tags,
+	}
+
+	if evt.Username != "" {
+		msg.Text = fmt.Sprintf("%s (user=%s)", msg.Text, evt.Username)
+	}
+
+	if evt.ContainerInfo != nil && evt.ContainerInfo.Config != nil {
+		labels := evt.ContainerInfo.Config.Labels
+		msg.Environment = labels[notification.LabelEnvironment]
+		msg.Team = labels[notification.LabelTeam]
+	}
+
+	return msg
+}
+
+// dispatchNotifications sends the event to the notifiers of the rules
+// matching it and to the notifiers subscribed to its type; a notifier gets
+// an event once
+func (m DefaultManager) dispatchNotifications(evt *shipyard.Event) {
+	notifiers, err := m.Notifiers()
+	if err != nil {
+		log.Errorf("error loading notifiers: %s", err)
+		return
+	}
+
+	if len(notifiers) == 0 {
+		return
+	}
+
+	rules, err := m.NotificationRules()
+	if err != nil {
+		log.Errorf("error loading notification rules: %s", err)
+		return
+	}
+
+	msg := newNotificationMessage(evt)
+	now := time.Now()
+
+	notes := evt.Notes
+	if len(notes) == 0 && evt.ContainerInfo != nil && evt.ContainerInfo.Config != nil {
+		notes = m.containerNotes(evt.ContainerInfo.Name, evt.ContainerInfo.Config.Labels)
+	}
+	msg.Runbook = runbookText(notes)
+
+	byID := map[string]*notification.Notifier{}
+	for _, n := range notifiers {
+		byID[n.ID] = n
+	}
+
+	sent := map[string]bool{}
+	deliver := func(n *notification.Notifier) {
+		if sent[n.ID] || !n.Accepts(msg) {
+			return
+		}
+		sent[n.ID] = true
+
+		if err := notification.Send(n, msg); err != nil {
+			log.Errorf("error sending notification: notifier=%s err=%s", n.Name, err)
+		}
+	}
+
+	held := []*notification.Rule{}
+	for _, rule := range rules {
+		if !rule.Matches(msg) {
+			continue
+		}
+
+		// only critical notifications are delivered in quiet hours; the
+		// others are held until they end
+		if rule.QuietHours != nil && rule.QuietHours.Active(now) && msg.Severity != notification.SeverityCritical {
+			held = append(held, rule)
+			continue
+		}
+
+		for _, id := range rule.Notifiers {
+			n, ok := byID[id]
+			if !ok {
+				log.Errorf("error loading notifier %s: %s", id, ErrNotifierDoesNotExist)
+				continue
+			}
+
+			deliver(n)
+		}
+
+		if msg.Severity == notification.SeverityCritical && rule.EscalateAfter > 0 && len(rule.EscalationNotifiers) > 0 {
+			esc := &notification.Escalation{
+				RuleID:  rule.ID,
+				Message: msg,
+				Due:     now.Add(time.Duration(rule.EscalateAfter) * time.Minute),
+			}
+			if err := m.db.SaveEscalation(esc); err != nil {
+				log.Errorf("error saving escalation: %s", err)
+			}
+		}
+	}
+
+	// subscriptions do not need a rule
+	for _, n := range notifiers {
+		if len(n.EventTypes) > 0 {
+			deliver(n)
+		}
+	}
+
+	// notifiers which got the message through another rule do not get
+	// it again after the quiet hours
+	for _, rule := range held {
+		ids := []string{}
+		for _, id := range rule.Notifiers {
+			if n, ok := byID[id]; ok && !sent[id] && n.Accepts(msg) {
+				sent[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		esc := &notification.Escalation{
+			RuleID:    rule.ID,
+			Message:   msg,
+			Due:       rule.QuietHours.Until(now),
+			Held:      true,
+			Notifiers: ids,
+		}
+		if err := m.db.SaveEscalation(esc); err != nil {
+			log.Errorf("error holding notification: rule=%s err=%s", rule.Name, err)
+			continue
+		}
+
+		log.Debugf("notification held by quiet hours: rule=%s until=%s", rule.Name, esc.Due)
+	}
+}
+
+func (m DefaultManager) sendNotification(notifierIDs []string, msg *notification.Message) {
+	for _, id := range notifierIDs {
+		n, err := m.Notifier(id)
+		if err != nil {
+			log.Errorf("error loading notifier %s: %s", id, err)
+			continue
+		}
+
+		if err := notification.Send(n, msg); err != nil {
+			log.Errorf("error sending notification: notifier=%s err=%s", n.Name, err)
+		}
+	}
+}
+
+// escalationMonitor sends unacknowledged critical notifications on to the
+// escalation notifiers of their rule once they are due
+func (m DefaultManager) escalationMonitor() {
+	t := time.NewTicker(escalationCheckInterval).C
+	for {
+		select {
+		case <-t:
+			if !m.isLeader() {
+				continue
+			}
+
+			if err := m.escalate(time.Now()); err != nil {
+				log.Errorf("error processing escalations: %s", err)
+			}
+		}
+	}
+}
+
+func (m DefaultManager) escalate(now time.Time) error {
+	escalations, err := m.db.Escalations()
+	if err != nil {
+		return err
+	}
+
+	for _, esc := range escalations {
+		if esc.Acknowledged || esc.Escalated || esc.Due.After(now) {
+			continue
+		}
+
+		// held messages are sent once and are then removed
+		if esc.Held {
+			msg := *esc.Message
+			msg.Text = fmt.Sprintf("held during quiet hours: %s", msg.Text)
+			m.sendNotification(esc.Notifiers, &msg)
+
+			if err := m.db.DeleteEscalation(esc.ID); err != nil && err != datastore.ErrNotFound {
+				return err
+			}
+			continue
+		}
+
+		rule, err := m.NotificationRule(esc.RuleID)
+		if err != nil {
+			log.Errorf("error loading rule for escalation %s: %s", esc.ID, err)
+			continue
+		}
+
+		msg := *esc.Message
+		msg.Text = fmt.Sprintf("ESCALATED (unacknowledged for %d minutes): %s", rule.EscalateAfter, msg.Text)
+		m.sendNotification(rule.EscalationNotifiers, &msg)
+
+		if err := m.db.UpdateEscalation(esc.ID, func(e *notification.Escalation) error {
+			e.Escalated = true
+			return nil
+		}); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m DefaultManager) Notifiers() ([]*notification.Notifier, error) {
+	return m.db.Notifiers()
+}
+
+func (m DefaultManager) Notifier(id string) (*notification.Notifier, error) {
+	n, err := m.db.Notifier(id)
+	if err != nil {
+		return nil, notFound(err, ErrNotifierDoesNotExist)
+	}
+	return n, nil
+}
+
+func (m DefaultManager) SaveNotifier(n *notification.Notifier) error {
+	// credentials are redacted in responses so updates sending them back
+	// keep the saved ones
+	if n.ID != "" {
+		existing, err := m.Notifier(n.ID)
+		switch err {
+		case nil:
+			n.KeepCredentials(existing)
+		case ErrNotifierDoesNotExist:
+		default:
+			return err
+		}
+	}
+
+	if err := n.Validate(); err != nil {
+		return err
+	}
+
+	if err := m.db.SaveNotifier(n); err != nil {
+		return err
+	}
+
+	m.logEvent("save-notifier", fmt.Sprintf("name=%s type=%s", n.Name, n.Type), []string{"notification"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteNotifier(id string) error {
+	n, err := m.Notifier(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteNotifier(id); err != nil {
+		return notFound(err, ErrNotifierDoesNotExist)
+	}
+
+	m.logEvent("delete-notifier", fmt.Sprintf("name=%s", n.Name), []string{"notification"})
+
+	return nil
+}
+
+func (m DefaultManager) NotificationRules() ([]*notification.Rule, error) {
+	return m.db.NotificationRules()
+}
+
+func (m DefaultManager) NotificationRule(id string) (*notification.Rule, error) {
+	rule, err := m.db.NotificationRule(id)
+	if err != nil {
+		return nil, notFound(err, ErrNotificationRuleDoesNotExist)
+	}
+	return rule, nil
+}
+
+func (m DefaultManager) SaveNotificationRule(rule *notification.Rule) error {
+	if rule.QuietHours != nil {
+		if err := rule.QuietHours.Validate(); err != nil {
+			return err
+		}
+	}
+
+	ids := append([]string{}, rule.Notifiers...)
+	ids = append(ids, rule.EscalationNotifiers...)
+	for _, id := range ids {
+		if _, err := m.Notifier(id); err != nil {
+			return fmt.Errorf("notifier %s: %s", id, err)
+		}
+	}
+
+	if err := m.db.SaveNotificationRule(rule); err != nil {
+		return err
+	}
+
+	m.logEvent("save-notification-rule", fmt.Sprintf("name=%s", rule.Name), []string{"notification"})
+
+	return nil
+}
+
+func (m DefaultManager) DeleteNotificationRule(id string) error {
+	rule, err := m.NotificationRule(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteNotificationRule(id); err != nil {
+		return notFound(err, ErrNotificationRuleDoesNotExist)
+	}
+
+	m.logEvent("delete-notification-rule", fmt.Sprintf("name=%s", rule.Name), []string{"notification"})
+
+	return nil
+}
+
+func (m DefaultManager) Escalations() ([]*notification.Escalation, error) {
+	return m.db.Escalations()
+}
+
+func (m DefaultManager) AcknowledgeEscalation(id, username string) error {
+	if err := m.db.UpdateEscalation(id, func(esc *notification.Escalation) error {
+		esc.Acknowledged = true
+		esc.AcknowledgedBy = username
+		return nil
+	}); err != nil {
+		return notFound(err, ErrEscalationDoesNotExist)
+	}
+
+	m.logEvent("acknowledge-escalation", fmt.Sprintf("id=%s username=%s", id, username), []string{"notification"})
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/controller/preflight"
+	r "gopkg.in/dancannon/gorethink.v2"
+)
+
+const (
+	// schemaVersion is the datastore schema this controller works with
+	schemaVersion = 1
+	schemaID      = "schema"
+	maxClockSkew  = 30 * time.Second
+)
+
+type schemaInfo struct {
+	ID      string `gorethink:"id"`
+	Version int    `gorethink:"version"`
+}
+
+// initSchema records the schema version of a new datastore; an existing
+// version is left as is so preflight can detect a mismatch
+func (m DefaultManager) initSchema() {
+	version, err := m.datastoreVersion()
+	if err != nil {
+		log.Errorf("error checking schema version: %s", err)
+		return
+	}
+
+	if version != 0 {
+		return
+	}
+
+	info := &schemaInfo{
+		ID:      schemaID,
+		Version: schemaVersion,
+	}
+
+	if _, err := r.Table(tblNameConfig).Insert(info).RunWrite(m.session); err != nil {
+		log.Errorf("error recording schema version: %s", err)
+	}
+}
+
+func (m DefaultManager) datastoreVersion() (int, error) {
+	res, err := r.Table(tblNameConfig).Get(schemaID).Run(m.session)
+	if err != nil {
+		return 0, err
+	}
+	if res.IsNil() {
+		return 0, nil
+	}
+	var info *schemaInfo
+	if err := res.One(&info); err != nil {
+		return 0, err
+	}
+	return info.Version, nil
+}
+
+// Preflight checks the datastore and swarm the controller depends on
+func (m DefaultManager) Preflight() []*preflight.Result {
+	results := []*preflight.Result{}
+
+	if m.session == nil {
+		results = append(results, preflight.OK("datastore", fmt.Sprintf("using the %s datastore", m.db.Name())))
+	} else {
+		results = append(results, m.preflightRethinkDB()...)
+	}
+
+	info, err := m.DockerClient().Info()
+	if err != nil {
+		results = append(results, preflight.Failed("swarm", true, fmt.Sprintf("cannot reach %s: %s", m.DockerClient().URL, err),
+			"check the --docker address and tls options and that swarm is running"))
+		return results
+	}
+
+	results = append(results, preflight.OK("swarm", fmt.Sprintf("connected to %s (%d containers)", m.DockerClient().URL, info.Containers)))
+
+	if info.SystemTime != "" {
+		if swarmTime, err := time.Parse(time.RFC3339Nano, info.SystemTime); err == nil {
+			results = append(results, preflight.CheckClockSkew("swarm clock", time.Now(), swarmTime, maxClockSkew))
+		}
+	}
+
+	return results
+}
+
+// preflightRethinkDB checks the schema version and clock of rethinkdb
+func (m DefaultManager) preflightRethinkDB() []*preflight.Result {
+	results := []*preflight.Result{}
+
+	version, err := m.datastoreVersion()
+	switch {
+	case err != nil:
+		results = append(results, preflight.Failed("datastore", true, fmt.Sprintf("cannot query rethinkdb: %s", err),
+			"check that rethinkdb is running and reachable at the --rethinkdb-addr address"))
+	case version > schemaVersion:
+		results = append(results, preflight.Failed("datastore", true,
+			fmt.Sprintf("datastore schema version %d is newer than supported version %d", version, schemaVersion),
+			"upgrade the controller to the version that last wrote to this datastore"))
+	case version < schemaVersion:
+		results = append(results, preflight.Warning("datastore", fmt.Sprintf("datastore schema version %d is older than %d", version, schemaVersion),
+			"restart the controller to upgrade the schema"))
+	default:
+		results = append(results, preflight.OK("datastore", fmt.Sprintf("connected to %s (schema version %d)", m.database, version)))
+	}
+
+	if err == nil {
+		res, err := r.Now().Run(m.session)
+		if err == nil {
+			var dbTime time.Time
+			if err := res.One(&dbTime); err == nil {
+				results = append(results, preflight.CheckClockSkew("datastore clock", time.Now(), dbTime, maxClockSkew))
+			}
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	// passwordResetTTL is how long a password reset token can be used
+	passwordResetTTL    = 24 * time.Hour
+	passwordResetLength = 32
+)
+
+var (
+	ErrInvalidEmail           = errors.New("invalid email address")
+	ErrInvalidPassword        = errors.New("the current password is wrong")
+	ErrEmptyPassword          = errors.New("the password cannot be empty")
+	ErrInvalidResetToken      = errors.New("invalid or expired password reset token")
+	ErrPasswordUpdateDisabled = errors.New("passwords are managed by the authenticator")
+	ErrPasswordChangeRequired = errors.New("the password has to be changed first")
+)
+
+// PasswordReset is the one-time token a user sets a new password with; it
+// is only returned when created
+type PasswordReset struct {
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// validEmail reports whether the email is a bare address; accounts
+// without an email are valid
+func validEmail(email string) bool {
+	if email == "" {
+		return true
+	}
+
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// UpdateProfile changes the name and email of the account; the other
+// fields of the profile are ignored so users cannot change their roles
+func (m DefaultManager) UpdateProfile(username string, profile *auth.Account) (*auth.Account, error) {
+	if !validEmail(profile.Email) {
+		return nil, ErrInvalidEmail
+	}
+
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		a.FirstName = profile.FirstName
+		a.LastName = profile.LastName
+		a.Email = profile.Email
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("update-profile", fmt.Sprintf("username=%s", username), []string{"security"})
+
+	return m.Account(username)
+}
+
+// ChangeOwnPassword sets the password of a user who knows the current one
+func (m DefaultManager) ChangeOwnPassword(username, current, password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+
+	if !m.authenticator.IsUpdateSupported() {
+		return ErrPasswordUpdateDisabled
+	}
+
+	if ok, err := m.Authenticate(username, current); !ok || err != nil {
+		return ErrInvalidPassword
+	}
+
+	return m.ChangePassword(username, password)
+}
+
+// ResetPassword creates a one-time token for the user to set a new
+// password with; a new token replaces the previous one
+func (m DefaultManager) ResetPassword(username, actor string) (*PasswordReset, error) {
+	if !m.authenticator.IsUpdateSupported() {
+		return nil, ErrPasswordUpdateDisabled
+	}
+
+	buf := make([]byte, passwordResetLength)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	reset := &PasswordReset{
+		Username:  username,
+		Token:     hex.EncodeToString(buf),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		a.PasswordResetHash = passwordResetHash(reset.Token)
+		a.PasswordResetExpires = reset.ExpiresAt
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("reset-password", fmt.Sprintf("username=%s actor=%s", username, actor), []string{"security"})
+
+	return reset, nil
+}
+
+// CompletePasswordReset sets the password of the account with its reset
+// token; the token is then removed and the sessions of the account end
+func (m DefaultManager) CompletePasswordReset(username, token, password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+
+	hash, err := auth.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		if a.PasswordResetHash == "" || time.Now().After(a.PasswordResetExpires) ||
+			subtle.ConstantTimeCompare([]byte(a.PasswordResetHash), []byte(passwordResetHash(token))) != 1 {
+			return ErrInvalidResetToken
+		}
+
+		a.Password = hash
+		a.PasswordResetHash = ""
+		a.PasswordResetExpires = time.Time{}
+		a.ForcePasswordChange = false
+		a.Tokens = []*auth.AuthToken{}
+		// the user proved they own the account
+		a.FailedLogins = 0
+		a.LockedUntil = time.Time{}
+		return nil
+	}); err != nil {
+		// unknown accounts fail as a wrong token does
+		if err == ErrAccountDoesNotExist {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	m.logEvent("complete-password-reset", fmt.Sprintf("username=%s", username), []string{"security"})
+
+	return nil
+}
+
+func passwordResetHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
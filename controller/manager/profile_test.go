@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/auth/builtin"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if err := m.db.CreateAccount(&auth.Account{Username: "alice", Roles: []string{"containers:ro"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := m.UpdateProfile("alice", &auth.Account{
+		FirstName: "Alice",
+		LastName:  "Liddell",
+		Email:     "alice@example.com",
+		Roles:     []string{"admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Email != "alice@example.com" || account.FirstName != "Alice" || len(account.Roles) != 1 || account.Roles[0] != "containers:ro" {
+		t.Fatalf("expected the name and email to change but not the roles; received %+v", account)
+	}
+
+	for _, email := range []string{"alice", "Alice <alice@example.com>"} {
+		if _, err := m.UpdateProfile("alice", &auth.Account{Email: email}); err != ErrInvalidEmail {
+			t.Fatalf("expected ErrInvalidEmail for %s; received %v", email, err)
+		}
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	hash, err := auth.Hash("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.db.CreateAccount(&auth.Account{
+		Username: "alice",
+		Password: hash,
+		Tokens:   []*auth.AuthToken{{Token: "session"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.authenticator = builtin.NewAuthenticator("")
+
+	if err := m.ChangeOwnPassword("alice", "wrong", "new"); err != ErrInvalidPassword {
+		t.Fatalf("expected ErrInvalidPassword; received %v", err)
+	}
+
+	reset, err := m.ResetPassword("alice", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CompletePasswordReset("alice", "guess", "new"); err != ErrInvalidResetToken {
+		t.Fatalf("expected ErrInvalidResetToken; received %v", err)
+	}
+
+	if err := m.CompletePasswordReset("bob", reset.Token, "new"); err != ErrInvalidResetToken {
+		t.Fatalf("expected ErrInvalidResetToken for an unknown account; received %v", err)
+	}
+
+	if err := m.CompletePasswordReset("alice", reset.Token, "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := m.Authenticate("alice", "new"); !ok {
+		t.Fatal("expected the new password to work")
+	}
+
+	account, err := m.Account("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(account.Tokens) != 0 || account.PasswordResetHash != "" {
+		t.Fatalf("expected the sessions and the token to be removed; received %+v", account)
+	}
+
+	// tokens are used once
+	if err := m.CompletePasswordReset("alice", reset.Token, "other"); err != ErrInvalidResetToken {
+		t.Fatalf("expected ErrInvalidResetToken for a used token; received %v", err)
+	}
+
+	if err := m.ChangeOwnPassword("alice", "new", "newer"); err != nil {
+		t.Fatal(err)
+	}
+
+	// expired tokens are refused
+	reset, err = m.ResetPassword("alice", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.updateAccount("alice", func(a *auth.Account) error {
+		a.PasswordResetExpires = time.Now().Add(-time.Minute)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CompletePasswordReset("alice", reset.Token, "other"); err != ErrInvalidResetToken {
+		t.Fatalf("expected ErrInvalidResetToken for an expired token; received %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	// pullRetention is how long finished pulls are kept for the deploys
+	// polling them
+	pullRetention = 10 * time.Minute
+
+	// Deploys pulling images; the deploy of a pull is the kind and the
+	// name (i.e. stack:web)
+	DeployStack    = "stack"
+	DeployRedeploy = "redeploy"
+	DeployJob      = "job"
+)
+
+// layerSteps is how far a layer is at the start of each status of the
+// progress stream; downloading is the first half and extracting the second
+var layerSteps = map[string]float64{
+	"Pulling fs layer":   0,
+	"Waiting":            0,
+	"Downloading":        0,
+	"Verifying Checksum": 0.5,
+	"Download complete":  0.5,
+	"Extracting":         0.5,
+	"Pull complete":      1,
+	"Already exists":     1,
+}
+
+type (
+	// LayerProgress is the progress of a layer of a pulled image; Current
+	// and Total are the bytes downloaded or extracted
+	LayerProgress struct {
+		ID      string `json:"id"`
+		Status  string `json:"status"`
+		Current int64  `json:"current,omitempty"`
+		Total   int64  `json:"total,omitempty"`
+		// Percent is the progress of the layer; downloading counts for
+		// the first half and extracting for the second
+		Percent float64 `json:"percent"`
+	}
+
+	// PullProgress is the progress of an image pulled for a deploy
+	PullProgress struct {
+		Deploy    string           `json:"deploy"`
+		Image     string           `json:"image"`
+		Status    string           `json:"status"`
+		Percent   float64          `json:"percent"`
+		Layers    []*LayerProgress `json:"layers"`
+		StartedAt time.Time        `json:"started_at"`
+		UpdatedAt time.Time        `json:"updated_at"`
+		Done      bool             `json:"done"`
+		Error     string           `json:"error,omitempty"`
+	}
+
+	// pullMessage is a message of the progress stream of a pull
+	pullMessage struct {
+		ID             string `json:"id"`
+		Status         string `json:"status"`
+		ProgressDetail struct {
+			Current int64 `json:"current"`
+			Total   int64 `json:"total"`
+		} `json:"progressDetail"`
+		Error string `json:"error"`
+	}
+
+	// pullTracker keeps the progress of the running and recent pulls
+	pullTracker struct {
+		mu    sync.RWMutex
+		pulls []*PullProgress
+	}
+)
+
+func newPullTracker() *pullTracker {
+	return &pullTracker{}
+}
+
+// start tracks a new pull and forgets the pulls finished before the
+// retention
+func (t *pullTracker) start(deploy, image string, now time.Time) *PullProgress {
+	p := &PullProgress{
+		Deploy:    deploy,
+		Image:     image,
+		Status:    "Pulling",
+		Layers:    []*LayerProgress{},
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+
+	if t == nil {
+		return p
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	kept := []*PullProgress{}
+	for _, pull := range t.pulls {
+		if !pull.Done || now.Sub(pull.UpdatedAt) < pullRetention {
+			kept = append(kept, pull)
+		}
+	}
+	t.pulls = append(kept, p)
+
+	return p
+}
+
+// update applies a message of the progress stream to the pull
+func (t *pullTracker) update(p *PullProgress, msg *pullMessage, now time.Time) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+	}
+
+	p.UpdatedAt = now
+	if msg.Status != "" {
+		p.Status = msg.Status
+	}
+
+	step, ok := layerSteps[msg.Status]
+	if msg.ID == "" || !ok {
+		return
+	}
+
+	var layer *LayerProgress
+	for _, l := range p.Layers {
+		if l.ID == msg.ID {
+			layer = l
+		}
+	}
+	if layer == nil {
+		layer = &LayerProgress{ID: msg.ID}
+		p.Layers = append(p.Layers, layer)
+	}
+
+	layer.Status = msg.Status
+	layer.Current = msg.ProgressDetail.Current
+	layer.Total = msg.ProgressDetail.Total
+	layer.Percent = 100 * step
+	if (msg.Status == "Downloading" || msg.Status == "Extracting") && layer.Total > 0 {
+		layer.Percent += 50 * float64(layer.Current) / float64(layer.Total)
+	}
+
+	total := 0.0
+	for _, l := range p.Layers {
+		total += l.Percent
+	}
+	p.Percent = total / float64(len(p.Layers))
+}
+
+// finish marks the pull done with the error it failed with
+func (t *pullTracker) finish(p *PullProgress, err error, now time.Time) {
+	if t != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+	}
+
+	p.Done = true
+	p.UpdatedAt = now
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	p.Percent = 100
+}
+
+// list returns copies of the pulls of the deploy, or of every deploy when
+// it is empty, oldest first
+func (t *pullTracker) list(deploy string) []*PullProgress {
+	pulls := []*PullProgress{}
+	if t == nil {
+		return pulls
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, p := range t.pulls {
+		if deploy != "" && p.Deploy != deploy {
+			continue
+		}
+
+		pull := *p
+		pull.Layers = []*LayerProgress{}
+		for _, l := range p.Layers {
+			layer := *l
+			pull.Layers = append(pull.Layers, &layer)
+		}
+		pulls = append(pulls, &pull)
+	}
+
+	return pulls
+}
+
+// Pulls returns the progress of the running pulls and the ones finished in
+// the last minutes; deploy (i.e. stack:web) limits them to a deploy
+func (m DefaultManager) Pulls(deploy string) []*PullProgress {
+	return m.pulls.list(deploy)
+}
+
+// pullStream pulls the image and calls progress with every message of the
+// progress stream; dockerclient only returns once the pull is done
+func pullStream(client *dockerclient.DockerClient, image string, auth *dockerclient.AuthConfig, progress func(msg *pullMessage)) error {
+	v := url.Values{}
+	v.Set("fromImage", image)
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/images/create?%s", client.URL.String(), dockerclient.APIVersion, v.Encode()), nil)
+	if err != nil {
+		return err
+	}
+
+	if auth != nil {
+		buf := &bytes.Buffer{}
+		if err := json.NewEncoder(buf).Encode(auth); err != nil {
+			return err
+		}
+		req.Header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return dockerclient.ErrNotFound
+	}
+
+	if resp.StatusCode >= 400 {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%s", data)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		msg := &pullMessage{}
+		if err := decoder.Decode(msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if msg.Error != "" {
+			return fmt.Errorf("%s", msg.Error)
+		}
+
+		progress(msg)
+	}
+}
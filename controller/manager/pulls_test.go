@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+func TestPullTracker(t *testing.T) {
+	tracker := newPullTracker()
+	now := time.Now()
+
+	p := tracker.start("stack:web", "nginx", now)
+	for _, msg := range []string{
+		`{"status":"Pulling from library/nginx","id":"latest"}`,
+		`{"status":"Pulling fs layer","id":"layer-1"}`,
+		`{"status":"Already exists","id":"layer-2"}`,
+		`{"status":"Downloading","id":"layer-1","progressDetail":{"current":50,"total":100}}`,
+	} {
+		m := &pullMessage{}
+		if err := json.Unmarshal([]byte(msg), m); err != nil {
+			t.Fatal(err)
+		}
+		tracker.update(p, m, now)
+	}
+
+	pulls := tracker.list("stack:web")
+	if len(pulls) != 1 || len(pulls[0].Layers) != 2 {
+		t.Fatalf("expected the pull with its two layers; received %+v", pulls)
+	}
+
+	if pulls[0].Layers[0].Percent != 25 || pulls[0].Percent != 62.5 {
+		t.Fatalf("expected the first layer half downloaded; received %+v", pulls[0])
+	}
+
+	tracker.start("job:backup", "busybox", now)
+	if pulls := tracker.list(""); len(pulls) != 2 {
+		t.Fatalf("expected the pulls of every deploy; received %d", len(pulls))
+	}
+
+	tracker.finish(p, nil, now)
+	if pulls := tracker.list("stack:web"); !pulls[0].Done || pulls[0].Percent != 100 {
+		t.Fatalf("expected the pull to be done; received %+v", pulls[0])
+	}
+
+	// finished pulls are forgotten after the retention
+	tracker.start("stack:db", "postgres", now.Add(pullRetention))
+	if pulls := tracker.list("stack:web"); len(pulls) != 0 {
+		t.Fatalf("expected the finished pull to be forgotten; received %d", len(pulls))
+	}
+
+	var disabled *pullTracker
+	disabled.update(disabled.start("stack:web", "nginx", now), &pullMessage{}, now)
+	if pulls := disabled.list(""); len(pulls) != 0 {
+		t.Fatal("expected no pulls without a tracker")
+	}
+}
+
+func TestPullStream(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fromImage") == "missing" {
+			fmt.Fprintln(w, `{"status":"Pulling repository missing"}`)
+			fmt.Fprintln(w, `{"error":"image not found","errorDetail":{"message":"image not found"}}`)
+			return
+		}
+
+		fmt.Fprintln(w, `{"status":"Pulling fs layer","id":"layer-1"}`)
+		fmt.Fprintln(w, `{"status":"Pull complete","id":"layer-1"}`)
+	}))
+	defer engine.Close()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{}
+	if err := pullStream(client, "nginx", nil, func(msg *pullMessage) {
+		statuses = append(statuses, msg.Status)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(statuses, ",") != "Pulling fs layer,Pull complete" {
+		t.Fatalf("expected every message of the stream; received %v", statuses)
+	}
+
+	if err := pullStream(client, "missing", nil, func(msg *pullMessage) {}); err == nil || err.Error() != "image not found" {
+		t.Fatalf("expected the error of the stream; received %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	QueryContainers = "containers"
+)
+
+var (
+	ErrQueryNotAllowed = errors.New("your roles do not allow the related resources of the query")
+
+	// containerQueryFields are the fields of containers
+	containerQueryFields = []string{"id", "name", "names", "image", "command", "created", "status", "ports", "labels"}
+
+	// queryRelations are the resources related to containers with their
+	// fields and the permission needed to read them
+	queryRelations = map[string]struct {
+		fields     []string
+		permission string
+	}{
+		"node":  {jsonFields(shipyard.Node{}), auth.PermNodesRead},
+		"owner": {[]string{"username", "first_name", "last_name"}, auth.PermAccountsRead},
+		"stats": {jsonFields(shipyard.ContainerStats{}), auth.PermContainersRead},
+	}
+)
+
+// QueryError is returned for queries asking for unknown resources or fields
+type QueryError struct {
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return "invalid query: " + e.Reason
+}
+
+// Query asks for the fields of a resource and of its related resources in
+// one request; fields of related resources are dotted (i.e. node.addr) and
+// the name of a related resource alone asks for every field of it
+type Query struct {
+	Resource string   `json:"resource"`
+	Fields   []string `json:"fields"`
+	// All includes stopped containers
+	All bool `json:"all,omitempty"`
+	// Labels limit the containers to the ones with the labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// Sort is name, created, state or node and Order asc or desc; the
+	// containers are returned newest first without them
+	Sort  string `json:"sort,omitempty"`
+	Order string `json:"order,omitempty"`
+}
+
+// jsonFields returns the json names of the fields of the struct
+func jsonFields(v interface{}) []string {
+	fields := []string{}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
+
+func hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryFields splits the fields of the query into the fields of the
+// resource and the fields of each related resource; an empty list asks for
+// every field of the relation
+func (q *Query) queryFields() ([]string, map[string][]string, error) {
+	if q.Resource != QueryContainers {
+		return nil, nil, &QueryError{fmt.Sprintf("unknown resource %q; use %s", q.Resource, QueryContainers)}
+	}
+
+	if len(q.Fields) == 0 {
+		return nil, nil, &QueryError{"no fields"}
+	}
+
+	fields := []string{}
+	relations := map[string][]string{}
+	for _, f := range q.Fields {
+		parts := strings.SplitN(f, ".", 2)
+		relation, ok := queryRelations[parts[0]]
+		if !ok {
+			if len(parts) > 1 || !hasField(containerQueryFields, f) {
+				return nil, nil, &QueryError{fmt.Sprintf("unknown field %q", f)}
+			}
+			fields = append(fields, f)
+			continue
+		}
+
+		if len(parts) == 1 {
+			relations[f] = relation.fields
+			continue
+		}
+
+		if !hasField(relation.fields, parts[1]) {
+			return nil, nil, &QueryError{fmt.Sprintf("unknown field %q", f)}
+		}
+		relations[parts[0]] = append(relations[parts[0]], parts[1])
+	}
+
+	return fields, relations, nil
+}
+
+// project returns the fields of the value, marshalled as json
+func project(v interface{}, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	for _, f := range fields {
+		result[f] = all[f]
+	}
+
+	return result, nil
+}
+
+// Query returns the requested fields of the containers the account may see
+// with their related resources; each related resource is loaded once for
+// every container instead of a request per container
+func (m DefaultManager) Query(q *Query, username string) ([]map[string]interface{}, error) {
+	fields, relations, err := q.queryFields()
+	if err != nil {
+		return nil, err
+	}
+
+	var sort *Sort
+	if q.Sort != "" || q.Order != "" {
+		if sort, err = ParseSort(q.Sort, q.Order, ContainerSortFields, false); err != nil {
+			return nil, &QueryError{err.(*SortError).Reason}
+		}
+	}
+
+	acct, err := m.Account(username)
+	if err != nil {
+		return nil, err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	for relation := range relations {
+		allowed := false
+		for _, acl := range acls {
+			if acct.HasRole(acl.RoleName) && acl.HasPermission(queryRelations[relation].permission) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return nil, ErrQueryNotAllowed
+		}
+	}
+
+	filters := ""
+	if len(q.Labels) > 0 {
+		labels := []string{}
+		for k, v := range q.Labels {
+			labels = append(labels, k+"="+v)
+		}
+
+		data, err := json.Marshal(map[string][]string{"label": labels})
+		if err != nil {
+			return nil, err
+		}
+		filters = url.QueryEscape(string(data))
+	}
+
+	containers, err := m.DockerClient().ListContainers(q.All, false, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if sort != nil {
+		sortContainers(containers, sort)
+	}
+
+	nodes := map[string]*shipyard.Node{}
+	if _, ok := relations["node"]; ok {
+		list, err := m.Nodes()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range list {
+			nodes[n.Name] = n
+		}
+	}
+
+	// owners are loaded once; nil for deleted accounts
+	owners := map[string]*auth.Account{}
+
+	scope := auth.ContainerScope(acct, acls)
+	results := []map[string]interface{}{}
+	for _, c := range containers {
+		if !scope.Matches(c.Labels) {
+			continue
+		}
+
+		result := containerQueryResult(c, fields)
+
+		for relation, relationFields := range relations {
+			var v interface{}
+			switch relation {
+			case "node":
+				if n, ok := nodes[containerNode(c)]; ok {
+					v = n
+				}
+			case "owner":
+				owner := c.Labels[LabelOwner]
+				if owner == "" {
+					break
+				}
+
+				if _, ok := owners[owner]; !ok {
+					a, err := m.Account(owner)
+					if err != nil && err != ErrAccountDoesNotExist {
+						return nil, err
+					}
+					owners[owner] = a
+				}
+
+				if a := owners[owner]; a != nil {
+					v = map[string]string{"username": a.Username, "first_name": a.FirstName, "last_name": a.LastName}
+				}
+			case "stats":
+				if sample := m.stats.last(c.Id); sample != nil {
+					v = sample.stats
+				}
+			}
+
+			if v == nil {
+				result[relation] = nil
+				continue
+			}
+
+			if result[relation], err = project(v, relationFields); err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func containerQueryResult(c dockerclient.Container, fields []string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, f := range fields {
+		switch f {
+		case "id":
+			result[f] = c.Id
+		case "name":
+			result[f] = containerName(c)
+		case "names":
+			result[f] = c.Names
+		case "image":
+			result[f] = c.Image
+		case "command":
+			result[f] = c.Command
+		case "created":
+			result[f] = c.Created
+		case "status":
+			result[f] = c.Status
+		case "ports":
+			result[f] = c.Ports
+		case "labels":
+			result[f] = c.Labels
+		}
+	}
+
+	return result
+}
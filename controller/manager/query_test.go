@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestQueryFields(t *testing.T) {
+	q := &Query{Resource: QueryContainers, Fields: []string{"id", "node.addr", "stats"}}
+	fields, relations, err := q.queryFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fields) != 1 || fields[0] != "id" {
+		t.Fatalf("expected the id field; received %v", fields)
+	}
+
+	if len(relations["node"]) != 1 || relations["node"][0] != "addr" {
+		t.Fatalf("expected the address of the node; received %v", relations["node"])
+	}
+
+	if len(relations["stats"]) != len(jsonFields(shipyard.ContainerStats{})) {
+		t.Fatalf("expected every field of the stats; received %v", relations["stats"])
+	}
+
+	for _, q := range []*Query{
+		{Resource: "images", Fields: []string{"id"}},
+		{Resource: QueryContainers},
+		{Resource: QueryContainers, Fields: []string{"secret"}},
+		{Resource: QueryContainers, Fields: []string{"node.secret"}},
+		{Resource: QueryContainers, Fields: []string{"id.name"}},
+	} {
+		if _, _, err := q.queryFields(); err == nil {
+			t.Errorf("expected %+v to be invalid", q)
+		} else if _, ok := err.(*QueryError); !ok {
+			t.Errorf("expected a query error; received %v", err)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[
+				{"Id":"web","Names":["/node-1/web"],"Image":"nginx","Labels":{"com.shipyard.owner":"alice","env":"prod"}},
+				{"Id":"cache","Names":["/node-1/cache"],"Image":"redis","Labels":{"env":"dev"}}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := newStatsHistory()
+	stats.add("web", &statsSample{stats: &shipyard.ContainerStats{Time: time.Now(), CPUPercent: 12.5}})
+
+	m.client = &clusterClient{client: client}
+	m.stats = stats
+
+	for _, acct := range []*auth.Account{
+		{Username: "alice", FirstName: "Alice", Roles: []string{"admin"}},
+		{Username: "bob", Roles: []string{"containers:ro"}, LabelScope: auth.LabelScope{"env=prod"}},
+	} {
+		if err := m.db.CreateAccount(acct); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := m.Query(&Query{Resource: QueryContainers, Fields: []string{"name", "owner.first_name", "stats.cpu_percent"}}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected both containers; received %v", results)
+	}
+
+	owner, ok := results[0]["owner"].(map[string]interface{})
+	if !ok || owner["first_name"] != "Alice" || len(owner) != 1 {
+		t.Fatalf("expected the first name of the owner; received %v", results[0]["owner"])
+	}
+
+	stats0, ok := results[0]["stats"].(map[string]interface{})
+	if !ok || stats0["cpu_percent"] != 12.5 {
+		t.Fatalf("expected the cpu of the latest sample; received %v", results[0]["stats"])
+	}
+
+	if results[1]["owner"] != nil || results[1]["stats"] != nil {
+		t.Fatalf("expected no owner and stats for cache; received %v", results[1])
+	}
+
+	results, err = m.Query(&Query{Resource: QueryContainers, Fields: []string{"id"}}, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0]["id"] != "web" {
+		t.Fatalf("expected the scope of bob to limit the containers; received %v", results)
+	}
+
+	if _, err := m.Query(&Query{Resource: QueryContainers, Fields: []string{"owner"}}, "bob"); err != ErrQueryNotAllowed {
+		t.Fatalf("expected the owners to be refused to bob; received %v", err)
+	}
+}
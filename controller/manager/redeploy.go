@@ -0,0 +1,382 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/compose"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	redeployStopTimeout = 10
+	redeployOldSuffix   = "-shipyard-redeploy"
+	// redeployLeaseTimeout bounds how long a redeploy holds its lease if
+	// its controller dies before releasing it
+	redeployLeaseTimeout = 10 * time.Minute
+)
+
+type RedeployResult struct {
+	Image      string
+	Redeployed []string
+	Errors     []string
+}
+
+const (
+	RedeployActionRedeploy = "redeploy"
+	RedeployActionSkip     = "skip"
+)
+
+// RedeployPlan is what RedeployImage would do for the image without
+// pulling it or touching a container
+type RedeployPlan struct {
+	Image string `json:"image"`
+	// Registry is the registry the image is pulled from; empty for the hub
+	Registry string `json:"registry,omitempty"`
+	// RegistryAuth is whether Shipyard has credentials for the registry
+	RegistryAuth bool `json:"registry_auth"`
+	// LeaseHolder is the controller already redeploying the image; the
+	// redeploy would be skipped
+	LeaseHolder string                   `json:"lease_holder,omitempty"`
+	Containers  []*RedeployPlanContainer `json:"containers"`
+}
+
+// RedeployPlanContainer is a container running the image and whether it
+// would be redeployed
+type RedeployPlanContainer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	Environment string `json:"environment,omitempty"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// normalizeImage returns the image reference with a tag and without the
+// default hub registry and namespace so references can be compared
+func normalizeImage(image string) string {
+	image = strings.TrimPrefix(image, "docker.io/")
+	image = strings.TrimPrefix(image, "library/")
+
+	// a colon after the last slash is a tag; before it a registry port
+	if strings.LastIndex(image, ":") <= strings.LastIndex(image, "/") && !strings.Contains(image, "@") {
+		image = image + ":latest"
+	}
+
+	return image
+}
+
+func imageMatches(containerImage, image string) bool {
+	return normalizeImage(containerImage) == normalizeImage(image)
+}
+
+// imageRegistry returns the registry host of the image or "" for images of
+// the hub
+func imageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return ""
+	}
+
+	host := image[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+
+	return ""
+}
+
+// registryAuth returns the credentials of the registry of the image; nil
+// when the registry was not added to Shipyard or has no credentials
+func (m DefaultManager) registryAuth(image string) (*dockerclient.AuthConfig, error) {
+	host := imageRegistry(image)
+	if host == "" {
+		return nil, nil
+	}
+
+	registries, err := m.db.Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range registries {
+		if r.Host() != host {
+			continue
+		}
+
+		if r.Token != "" {
+			return &dockerclient.AuthConfig{RegistryToken: r.Token}, nil
+		}
+
+		if r.Username != "" {
+			return &dockerclient.AuthConfig{Username: r.Username, Password: r.Password}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// pullImage pulls the image with the credentials of its registry and
+// tracks its progress for the deploy
+func (m DefaultManager) pullImage(deploy, image string) error {
+	auth, err := m.registryAuth(image)
+	if err != nil {
+		return err
+	}
+
+	p := m.pulls.start(deploy, image, time.Now())
+	err = pullStream(m.DockerClient(), image, auth, func(msg *pullMessage) {
+		m.pulls.update(p, msg, time.Now())
+	})
+	m.pulls.finish(p, err, time.Now())
+
+	if err != nil {
+		m.logEvent("pull-image", fmt.Sprintf("deploy=%s image=%s error=%s", deploy, image, err), []string{"deploy"})
+		return err
+	}
+
+	m.logEvent("pull-image", fmt.Sprintf("deploy=%s image=%s layers=%d duration=%s", deploy, image, len(p.Layers), p.UpdatedAt.Sub(p.StartedAt)), []string{"deploy"})
+
+	return nil
+}
+
+// RedeployImage pulls the image and recreates every container running it
+// with its original config and host config; containers in a frozen
+// environment are left alone. A webhook delivered to several controllers
+// redeploys once: the image is leased while it is redeployed. Containers
+// of a stack are redeployed holding the lock of the stack, queueing behind
+// a deploy of the stack for up to redeployStackWait.
+func (m DefaultManager) RedeployImage(image string) RedeployResult {
+	result := RedeployResult{
+		Image:      image,
+		Redeployed: []string{},
+		Errors:     []string{},
+	}
+
+	name := "redeploy:" + normalizeImage(image)
+	leased, err := m.acquireLease(name, redeployLeaseTimeout)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("error leasing the redeploy of %s: %s", image, err))
+		return result
+	}
+
+	if !leased {
+		log.Infof("redeploy: %s is being redeployed by another controller", image)
+		result.Errors = append(result.Errors, fmt.Sprintf("%s is being redeployed by another controller", image))
+		return result
+	}
+	defer func() {
+		if err := m.releaseLease(name); err != nil {
+			log.Errorf("redeploy: error releasing the lease of %s: %s", image, err)
+		}
+	}()
+
+	log.Infof("redeploy: pulling %s", image)
+	if err := m.pullImage(DeployRedeploy+":"+image, image); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("error pulling %s: %s", image, err))
+		m.logEvent("redeploy", fmt.Sprintf("image=%s error=%s", image, err), []string{"deploy"})
+		return result
+	}
+
+	containers, err := m.DockerClient().ListContainers(true, false, "")
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	// containers outside of a stack are under the empty stack
+	stacks := []string{}
+	stackContainers := map[string][]dockerclient.Container{}
+	for _, c := range containers {
+		if !imageMatches(c.Image, image) {
+			continue
+		}
+
+		stack := c.Labels[compose.LabelProject]
+		if _, ok := stackContainers[stack]; !ok {
+			stacks = append(stacks, stack)
+		}
+		stackContainers[stack] = append(stackContainers[stack], c)
+	}
+
+	for _, stack := range stacks {
+		unlock := func() {}
+		if stack != "" {
+			u, err := m.lockStack(stack, "", "redeploy "+image, redeployStackWait)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			unlock = u
+		}
+
+		for _, c := range stackContainers[stack] {
+			if err := m.CheckFreeze("", c.Labels[notification.LabelEnvironment], "redeploy "+image); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", c.Id, err))
+				continue
+			}
+
+			id, err := m.redeployContainer(c.Id, image)
+			if err != nil {
+				log.Errorf("redeploy: error redeploying %s: %s", c.Id, err)
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", c.Id, err))
+				continue
+			}
+
+			result.Redeployed = append(result.Redeployed, id)
+		}
+
+		unlock()
+	}
+
+	m.logEvent("redeploy", fmt.Sprintf("image=%s redeployed=%d errors=%d", image, len(result.Redeployed), len(result.Errors)), []string{"deploy"})
+
+	return result
+}
+
+// PlanRedeploy runs the checks of RedeployImage for the image and returns
+// the containers it would redeploy and the ones frozen environments keep;
+// nothing is pulled, recreated or recorded
+func (m DefaultManager) PlanRedeploy(image string) (*RedeployPlan, error) {
+	plan := &RedeployPlan{
+		Image:      image,
+		Registry:   imageRegistry(image),
+		Containers: []*RedeployPlanContainer{},
+	}
+
+	auth, err := m.registryAuth(image)
+	if err != nil {
+		return nil, err
+	}
+	plan.RegistryAuth = auth != nil
+
+	if m.session != nil {
+		holder, err := m.leaseHolder("redeploy:" + normalizeImage(image))
+		if err != nil {
+			return nil, err
+		}
+
+		if holder != m.controllerID {
+			plan.LeaseHolder = holder
+		}
+	}
+
+	containers, err := m.DockerClient().ListContainers(true, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		if !imageMatches(c.Image, image) {
+			continue
+		}
+
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		pc := &RedeployPlanContainer{
+			ID:          c.Id,
+			Name:        name,
+			Image:       c.Image,
+			Environment: c.Labels[notification.LabelEnvironment],
+			Action:      RedeployActionRedeploy,
+		}
+
+		// the freeze is looked up without CheckFreeze so the plan is
+		// not recorded as a denied change
+		freeze, err := m.activeFreeze(pc.Environment)
+		if err != nil {
+			return nil, err
+		}
+
+		if freeze != nil {
+			pc.Action = RedeployActionSkip
+			pc.Reason = (&FreezeError{Freeze: freeze}).Error()
+		}
+
+		plan.Containers = append(plan.Containers, pc)
+	}
+
+	return plan, nil
+}
+
+// redeployContainer replaces the container with a new one from the image;
+// the old container is kept aside until the new one is running and is
+// restored if anything fails
+func (m DefaultManager) redeployContainer(id, image string) (string, error) {
+	info, err := m.DockerClient().InspectContainer(id)
+	if err != nil {
+		return "", err
+	}
+
+	name := info.Name
+	if i := strings.LastIndex(name, "/"); i > -1 {
+		name = name[i+1:]
+	}
+	oldName := name + redeployOldSuffix
+	running := info.State != nil && info.State.Running
+
+	config := info.Config
+	config.Image = image
+	// generated hostnames are the short container id
+	if len(info.Id) >= 12 && config.Hostname == info.Id[:12] {
+		config.Hostname = ""
+	}
+	if info.HostConfig != nil {
+		config.HostConfig = *info.HostConfig
+	}
+	if err := m.applyDrainConstraints(config); err != nil {
+		return "", err
+	}
+
+	restore := func() {
+		if err := m.DockerClient().RenameContainer(oldName, name); err != nil {
+			log.Errorf("redeploy: error restoring name of %s: %s", id, err)
+		}
+		if running {
+			if err := m.DockerClient().StartContainer(id, info.HostConfig); err != nil {
+				log.Errorf("redeploy: error restarting %s: %s", id, err)
+			}
+		}
+	}
+
+	if err := m.DockerClient().RenameContainer(id, oldName); err != nil {
+		return "", err
+	}
+
+	if running {
+		if err := m.DockerClient().StopContainer(id, redeployStopTimeout); err != nil {
+			restore()
+			return "", err
+		}
+	}
+
+	newId, err := m.DockerClient().CreateContainer(config, name, nil)
+	if err != nil {
+		restore()
+		return "", err
+	}
+
+	if running {
+		if err := m.DockerClient().StartContainer(newId, info.HostConfig); err != nil {
+			if err := m.DockerClient().RemoveContainer(newId, true, false); err != nil {
+				log.Errorf("redeploy: error removing %s: %s", newId, err)
+			}
+			restore()
+			return "", err
+		}
+	}
+
+	if err := m.DockerClient().RemoveContainer(id, true, false); err != nil {
+		log.Errorf("redeploy: error removing old container %s: %s", id, err)
+	}
+
+	m.logEvent("redeploy-container", fmt.Sprintf("name=%s image=%s old=%s new=%s", name, image, id, newId), []string{"deploy"})
+
+	return newId, nil
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/notification"
+)
+
+func TestImageRegistry(t *testing.T) {
+	registries := map[string]string{
+		"nginx":                     "",
+		"ehazlett/test":             "",
+		"registry.local:5000/web:2": "registry.local:5000",
+		"localhost/web":             "localhost",
+		"quay.io/coreos/etcd":       "quay.io",
+	}
+
+	for image, registry := range registries {
+		if r := imageRegistry(image); r != registry {
+			t.Errorf("expected registry %q of %s; received %q", registry, image, r)
+		}
+	}
+}
+
+func TestPullImageRegistryAuth(t *testing.T) {
+	auths := map[string]*dockerclient.AuthConfig{}
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/images/create") {
+			http.NotFound(w, r)
+			return
+		}
+
+		auth := &dockerclient.AuthConfig{}
+		if header := r.Header.Get("X-Registry-Auth"); header != "" {
+			data, err := base64.URLEncoding.DecodeString(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := json.Unmarshal(data, auth); err != nil {
+				t.Fatal(err)
+			}
+		}
+		auths[r.URL.Query().Get("fromImage")] = auth
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for _, r := range []*shipyard.Registry{
+		{Name: "private", Addr: "https://registry.local:5000", Username: "deploy", Password: "secret"},
+		{Name: "tokens", Addr: "https://tokens.local", Token: "abc"},
+	} {
+		if err := m.db.SaveRegistry(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	for _, image := range []string{"registry.local:5000/web:2", "tokens.local/api", "nginx"} {
+		if err := m.pullImage(DeployRedeploy+":"+image, image); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a := auths["registry.local:5000/web:2"]; a == nil || a.Username != "deploy" || a.Password != "secret" {
+		t.Fatalf("expected the credentials of the private registry; received %+v", a)
+	}
+
+	if a := auths["tokens.local/api"]; a == nil || a.RegistryToken != "abc" {
+		t.Fatalf("expected the token of the registry; received %+v", a)
+	}
+
+	if a := auths["nginx"]; a == nil || a.Username != "" {
+		t.Fatalf("expected no credentials for the hub; received %+v", a)
+	}
+}
+
+func TestPlanRedeploy(t *testing.T) {
+	pulls := 0
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			json.NewEncoder(w).Encode([]dockerclient.Container{
+				{Id: "web-prod", Names: []string{"/web-prod"}, Image: "registry.local:5000/web", Labels: map[string]string{notification.LabelEnvironment: "prod"}},
+				{Id: "web-dev", Names: []string{"/web-dev"}, Image: "registry.local:5000/web:latest", Labels: map[string]string{notification.LabelEnvironment: "dev"}},
+				{Id: "db", Names: []string{"/db"}, Image: "postgres"},
+			})
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulls++
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	now := time.Now()
+	if err := m.db.SaveFreeze(&shipyard.Freeze{Name: "release", Start: now.Add(-time.Hour), End: now.Add(time.Hour), Environments: []string{"prod"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.db.SaveRegistry(&shipyard.Registry{Name: "private", Addr: "https://registry.local:5000", Username: "deploy", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	plan, err := m.PlanRedeploy("registry.local:5000/web:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.Registry != "registry.local:5000" || !plan.RegistryAuth {
+		t.Fatalf("expected the credentials of the private registry; received %+v", plan)
+	}
+
+	if len(plan.Containers) != 2 {
+		t.Fatalf("expected the containers of the image; received %d", len(plan.Containers))
+	}
+
+	for _, c := range plan.Containers {
+		expected := RedeployActionRedeploy
+		if c.Name == "web-prod" {
+			expected = RedeployActionSkip
+		}
+
+		if c.Action != expected {
+			t.Errorf("expected %s to %s; received %s (%s)", c.Name, expected, c.Action, c.Reason)
+		}
+	}
+
+	if pulls != 0 {
+		t.Fatal("expected the plan not to pull the image")
+	}
+
+	events, err := m.db.Events(&datastore.EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 0 {
+		t.Fatalf("expected the plan not to record events; received %d", len(events))
+	}
+}
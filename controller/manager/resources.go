@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	// minMemory is the smallest memory limit the engine accepts
+	minMemory = 4 * 1024 * 1024
+	// defaultCPUPeriod is the cfs period of the engine when none is set
+	defaultCPUPeriod = 100000
+)
+
+var (
+	ErrNoResourceUpdate    = errors.New("the update needs a memory or cpu limit")
+	ErrInvalidResources    = errors.New("resource limits cannot be negative and memory needs at least 4MB")
+	ErrResourcesNotAllowed = errors.New("your roles do not allow the resources for the container")
+)
+
+// ResourceUpdate is the memory and cpu limits of a container to change
+// with the update api of the engine; unset values are kept
+type ResourceUpdate struct {
+	Memory            int64  `json:"Memory,omitempty"`
+	MemoryReservation int64  `json:"MemoryReservation,omitempty"`
+	MemorySwap        int64  `json:"MemorySwap,omitempty"`
+	CpuShares         int64  `json:"CpuShares,omitempty"`
+	CpuPeriod         int64  `json:"CpuPeriod,omitempty"`
+	CpuQuota          int64  `json:"CpuQuota,omitempty"`
+	CpusetCpus        string `json:"CpusetCpus,omitempty"`
+}
+
+// validate checks the engine accepts the update; a memory swap of -1 is
+// unlimited
+func (u *ResourceUpdate) validate() error {
+	if u.Memory == 0 && u.MemoryReservation == 0 && u.MemorySwap == 0 && u.CpuShares == 0 && u.CpuPeriod == 0 && u.CpuQuota == 0 && u.CpusetCpus == "" {
+		return ErrNoResourceUpdate
+	}
+
+	if u.Memory < 0 || u.MemoryReservation < 0 || u.MemorySwap < -1 || u.CpuShares < 0 || u.CpuPeriod < 0 || u.CpuQuota < 0 {
+		return ErrInvalidResources
+	}
+
+	if u.Memory > 0 && u.Memory < minMemory {
+		return ErrInvalidResources
+	}
+
+	return nil
+}
+
+// resources returns the limits of the container once the update is
+// applied
+func (u *ResourceUpdate) resources(current *dockerclient.HostConfig) auth.Resources {
+	c := dockerclient.HostConfig{}
+	if current != nil {
+		c = *current
+	}
+
+	if u.Memory > 0 {
+		c.Memory = u.Memory
+	}
+	if u.CpuShares > 0 {
+		c.CpuShares = u.CpuShares
+	}
+	if u.CpuPeriod > 0 {
+		c.CpuPeriod = u.CpuPeriod
+	}
+	if u.CpuQuota > 0 {
+		c.CpuQuota = u.CpuQuota
+	}
+
+	r := auth.Resources{
+		Memory:    c.Memory,
+		CPUShares: c.CpuShares,
+	}
+
+	if c.CpuQuota > 0 {
+		period := c.CpuPeriod
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		r.CPUs = float64(c.CpuQuota) / float64(period)
+	}
+
+	return r
+}
+
+// UpdateContainerResources changes the memory and cpu limits of a running
+// container so it does not have to be recreated; the roles of the account
+// cap the resources per environment and every change is an event
+func (m DefaultManager) UpdateContainerResources(id string, update *ResourceUpdate, username string) error {
+	if err := update.validate(); err != nil {
+		return err
+	}
+
+	info, err := m.Container(id)
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{}
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+	environment := labels[notification.LabelEnvironment]
+
+	acct, err := m.Account(username)
+	if err != nil {
+		return err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return err
+	}
+
+	resources := update.resources(info.HostConfig)
+	if !auth.ResourceAccess(acct, acls, environment, resources) {
+		m.logEvent("update-resources-denied", fmt.Sprintf("container=%s memory=%d cpu_shares=%d cpus=%g environment=%s username=%s", id, resources.Memory, resources.CPUShares, resources.CPUs, environment, username), []string{"security"})
+		return ErrResourcesNotAllowed
+	}
+
+	if err := m.CheckFreeze(username, environment, "update resources of "+id); err != nil {
+		return err
+	}
+
+	before := (&ResourceUpdate{}).resources(info.HostConfig)
+
+	if err := m.updateContainer(info.Id, update); err != nil {
+		return err
+	}
+
+	m.logEvent("update-resources", fmt.Sprintf("container=%s memory=%d->%d cpu_shares=%d->%d cpus=%g->%g username=%s", id, before.Memory, resources.Memory, before.CPUShares, resources.CPUShares, before.CPUs, resources.CPUs, username), []string{"docker", "security"})
+
+	return nil
+}
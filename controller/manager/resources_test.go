@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestResourceUpdateValidate(t *testing.T) {
+	tests := []struct {
+		update ResourceUpdate
+		err    error
+	}{
+		{ResourceUpdate{Memory: 512 * 1024 * 1024}, nil},
+		{ResourceUpdate{MemorySwap: -1}, nil},
+		{ResourceUpdate{CpusetCpus: "0,1"}, nil},
+		{ResourceUpdate{}, ErrNoResourceUpdate},
+		{ResourceUpdate{Memory: 1024}, ErrInvalidResources},
+		{ResourceUpdate{CpuShares: -2}, ErrInvalidResources},
+		{ResourceUpdate{MemorySwap: -2}, ErrInvalidResources},
+	}
+
+	for _, test := range tests {
+		if err := test.update.validate(); err != test.err {
+			t.Errorf("%+v: expected %v; received %v", test.update, test.err, err)
+		}
+	}
+}
+
+func TestResourceUpdateResources(t *testing.T) {
+	current := &dockerclient.HostConfig{Memory: 256 * 1024 * 1024, CpuShares: 512}
+
+	update := &ResourceUpdate{CpuQuota: 150000}
+	expected := auth.Resources{Memory: 256 * 1024 * 1024, CPUShares: 512, CPUs: 1.5}
+	if r := update.resources(current); r != expected {
+		t.Fatalf("expected %+v; received %+v", expected, r)
+	}
+
+	update = &ResourceUpdate{Memory: 512 * 1024 * 1024, CpuPeriod: 50000, CpuQuota: 50000}
+	expected = auth.Resources{Memory: 512 * 1024 * 1024, CPUShares: 512, CPUs: 1}
+	if r := update.resources(current); r != expected {
+		t.Fatalf("expected %+v; received %+v", expected, r)
+	}
+}
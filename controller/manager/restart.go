@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	// updateAPIVersion is the first engine api able to update containers
+	updateAPIVersion = "v1.22"
+)
+
+var (
+	ErrInvalidRetryCount       = errors.New("a maximum retry count is only valid for on-failure and cannot be negative")
+	ErrRestartPolicyNotAllowed = errors.New("your roles do not allow the restart policy for the container")
+)
+
+// validateRestartPolicy checks the engine accepts the policy
+func validateRestartPolicy(policy dockerclient.RestartPolicy) error {
+	if !auth.ValidRestartPolicy(policy.Name) {
+		return auth.ErrInvalidRestartPolicy
+	}
+
+	if policy.MaximumRetryCount < 0 || (policy.MaximumRetryCount > 0 && policy.Name != auth.RestartOnFailure) {
+		return ErrInvalidRetryCount
+	}
+
+	return nil
+}
+
+// SetRestartPolicy changes the restart policy of a running container
+// through the update api of the engine so it does not have to be
+// recreated; the roles of the account limit the policies per environment
+func (m DefaultManager) SetRestartPolicy(id string, policy dockerclient.RestartPolicy, username string) error {
+	if err := validateRestartPolicy(policy); err != nil {
+		return err
+	}
+
+	info, err := m.Container(id)
+	if err != nil {
+		return containerNotFound(err)
+	}
+
+	labels := map[string]string{}
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+	environment := labels[notification.LabelEnvironment]
+
+	acct, err := m.Account(username)
+	if err != nil {
+		return err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return err
+	}
+
+	if !auth.RestartPolicyAccess(acct, acls, environment, policy.Name) {
+		m.logEvent("restart-policy-denied", fmt.Sprintf("container=%s policy=%s environment=%s username=%s", id, policy.Name, environment, username), []string{"security"})
+		return ErrRestartPolicyNotAllowed
+	}
+
+	if err := m.CheckFreeze(username, environment, "set restart policy of "+id); err != nil {
+		return err
+	}
+
+	if err := m.updateContainer(info.Id, map[string]interface{}{"RestartPolicy": policy}); err != nil {
+		return err
+	}
+
+	m.logEvent("restart-policy", fmt.Sprintf("container=%s policy=%s retries=%d username=%s", id, policy.Name, policy.MaximumRetryCount, username), []string{"docker"})
+
+	return nil
+}
+
+// containerNotFound maps the error of engines which explain a missing
+// container in the body of the response, which the client returns as is,
+// to dockerclient.ErrNotFound
+func containerNotFound(err error) error {
+	if strings.Contains(err.Error(), "No such container") {
+		return dockerclient.ErrNotFound
+	}
+
+	return err
+}
+
+// updateContainer sends the update to the engine; the client has no
+// update api
+func (m DefaultManager) updateContainer(id string, update interface{}) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	client := m.DockerClient()
+	url := fmt.Sprintf("%s/%s/containers/%s/update", client.URL.String(), updateAPIVersion, id)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return dockerclient.ErrNotFound
+	}
+
+	if resp.StatusCode >= 400 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("error updating %s: %s: %s", id, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestValidateRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy dockerclient.RestartPolicy
+		err    error
+	}{
+		{dockerclient.RestartPolicy{Name: "always"}, nil},
+		{dockerclient.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}, nil},
+		{dockerclient.RestartPolicy{Name: ""}, auth.ErrInvalidRestartPolicy},
+		{dockerclient.RestartPolicy{Name: "always", MaximumRetryCount: 3}, ErrInvalidRetryCount},
+		{dockerclient.RestartPolicy{Name: "on-failure", MaximumRetryCount: -1}, ErrInvalidRetryCount},
+	}
+
+	for _, test := range tests {
+		if err := validateRestartPolicy(test.policy); err != test.err {
+			t.Errorf("%+v: expected %v; received %v", test.policy, test.err, err)
+		}
+	}
+}
+
+func TestUpdateContainer(t *testing.T) {
+	var update struct {
+		RestartPolicy dockerclient.RestartPolicy
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+updateAPIVersion+"/containers/abc/update" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	client, err := dockerclient.NewDockerClient(ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultManager{client: &clusterClient{client: client}}
+
+	policy := dockerclient.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}
+	if err := m.updateContainer("abc", map[string]interface{}{"RestartPolicy": policy}); err != nil {
+		t.Fatal(err)
+	}
+
+	if update.RestartPolicy != policy {
+		t.Fatalf("expected %+v; received %+v", policy, update.RestartPolicy)
+	}
+
+	if err := m.updateContainer("missing", nil); err != dockerclient.ErrNotFound {
+		t.Fatalf("expected ErrNotFound; received %v", err)
+	}
+}
+
+func TestSetRestartPolicyMissingContainer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "No such container: missing", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	client, err := dockerclient.NewDockerClient(ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultManager{client: &clusterClient{client: client}}
+
+	// engines explain missing containers in the body
+	if err := m.SetRestartPolicy("missing", dockerclient.RestartPolicy{Name: "always"}, "admin"); err != dockerclient.ErrNotFound {
+		t.Fatalf("expected ErrNotFound; received %v", err)
+	}
+}
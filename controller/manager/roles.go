@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+var (
+	ErrRoleIsBuiltin     = errors.New("builtin roles cannot be changed")
+	ErrRoleNameRequired  = errors.New("role name is required")
+	ErrInvalidPermission = errors.New("invalid permission")
+)
+
+// customRoles returns the roles created through the api
+func (m DefaultManager) customRoles() ([]*auth.ACL, error) {
+	return m.db.Roles()
+}
+
+func isBuiltinRole(name string) bool {
+	for _, acl := range auth.DefaultACLs() {
+		if acl.RoleName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPermission reports whether any role of the account grants the
+// permission
+func (m DefaultManager) HasPermission(username, permission string) (bool, error) {
+	acct, err := m.Account(username)
+	if err != nil {
+		return false, err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return false, err
+	}
+
+	for _, acl := range acls {
+		if acct.HasRole(acl.RoleName) && acl.HasPermission(permission) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// containerScope returns the containers the account is restricted to (see
+// auth.ContainerScope); nil when unrestricted
+func (m DefaultManager) containerScope(username string) (auth.LabelScope, error) {
+	acct, err := m.Account(username)
+	if err != nil {
+		return nil, err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.ContainerScope(acct, acls), nil
+}
+
+// SaveRole creates or replaces a custom role
+func (m DefaultManager) SaveRole(role *auth.ACL) error {
+	if role.RoleName == "" {
+		return ErrRoleNameRequired
+	}
+
+	if isBuiltinRole(role.RoleName) {
+		return ErrRoleIsBuiltin
+	}
+
+	for _, p := range role.Permissions {
+		if !auth.ValidPermission(p) {
+			return fmt.Errorf("%s: %s", ErrInvalidPermission, p)
+		}
+	}
+
+	if err := role.LabelScope.Validate(); err != nil {
+		return err
+	}
+
+	for _, l := range role.RestartPolicies {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range role.ResourceLimits {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+	}
+
+	role.Builtin = false
+	if err := m.db.SaveRole(role); err != nil {
+		return err
+	}
+
+	m.logEvent("save-role", fmt.Sprintf("role=%s permissions=%s", role.RoleName, strings.Join(role.Permissions, ",")), []string{"security"})
+
+	return nil
+}
+
+// DeleteRole removes a custom role; accounts keep the role name but it no
+// longer grants anything
+func (m DefaultManager) DeleteRole(name string) error {
+	if isBuiltinRole(name) {
+		return ErrRoleIsBuiltin
+	}
+
+	if err := m.db.DeleteRole(name); err != nil {
+		return notFound(err, ErrRoleDoesNotExist)
+	}
+
+	m.logEvent("delete-role", fmt.Sprintf("role=%s", name), []string{"security"})
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/clair"
+)
+
+const (
+	tblNameImageScans = "image_scans"
+
+	// scanTimeout is how long a scan is considered running; scans of
+	// controllers which stopped midway can be restarted after it
+	scanTimeout = 30 * time.Minute
+)
+
+var (
+	ErrScannerDisabled       = errors.New("no image scanner is configured; start the controller with --clair-url")
+	ErrImageScanDoesNotExist = errors.New("the image has not been scanned")
+	ErrImageScanRunning      = errors.New("the image is being scanned")
+	ErrImageNotScannable     = errors.New("only images of registries added to shipyard can be scanned")
+)
+
+// ScanThresholdError is returned for images with vulnerabilities above
+// the highest severity allowed
+type ScanThresholdError struct {
+	Image    string
+	Severity string
+	Max      string
+}
+
+func (e *ScanThresholdError) Error() string {
+	return fmt.Sprintf("%s has %s vulnerabilities; at most %s is allowed", e.Image, e.Severity, e.Max)
+}
+
+// ImageScan returns the latest scan of a tag of a registry
+func (m DefaultManager) ImageScan(registryID, repo, tag string) (*shipyard.ImageScan, error) {
+	scan, err := m.db.ImageScan(shipyard.ImageScanID(registryID, repo, tag))
+	if err != nil {
+		return nil, notFound(err, ErrImageScanDoesNotExist)
+	}
+
+	return scan, nil
+}
+
+// ScanImage starts a scan of a tag of a registry and returns it while it
+// runs; its result replaces the previous scan of the tag
+func (m DefaultManager) ScanImage(registryID, repo, tag, username string) (*shipyard.ImageScan, error) {
+	if m.scanner == nil {
+		return nil, ErrScannerDisabled
+	}
+
+	reg, err := m.Registry(registryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reg.Version == shipyard.RegistryV1 {
+		return nil, shipyard.ErrRegistryVersionNotSupported
+	}
+
+	scan, err := m.startImageScan(reg, repo, tag, username)
+	if err != nil {
+		return nil, err
+	}
+
+	go m.runImageScan(reg, scan)
+
+	return scan, nil
+}
+
+func (m DefaultManager) startImageScan(reg *shipyard.Registry, repo, tag, username string) (*shipyard.ImageScan, error) {
+	id := shipyard.ImageScanID(reg.ID, repo, tag)
+	if prev, err := m.db.ImageScan(id); err == nil && prev.Status == shipyard.ScanRunning && time.Since(prev.StartedAt) < scanTimeout {
+		return nil, ErrImageScanRunning
+	}
+
+	scan := &shipyard.ImageScan{
+		ID:              id,
+		Registry:        reg.ID,
+		Repository:      repo,
+		Tag:             tag,
+		Status:          shipyard.ScanRunning,
+		Vulnerabilities: []*shipyard.Vulnerability{},
+		Counts:          map[string]int{},
+		ScannedBy:       username,
+		StartedAt:       time.Now(),
+	}
+
+	if err := m.db.SaveImageScan(scan); err != nil {
+		return nil, err
+	}
+
+	return scan, nil
+}
+
+// runImageScan submits the layers of the image to the scanner base layer
+// first and saves the vulnerabilities it found
+func (m DefaultManager) runImageScan(reg *shipyard.Registry, scan *shipyard.ImageScan) {
+	err := m.scanLayers(reg, scan)
+
+	scan.FinishedAt = time.Now()
+	scan.Status = shipyard.ScanDone
+	if err != nil {
+		scan.Status = shipyard.ScanFailed
+		scan.Error = err.Error()
+		log.Errorf("error scanning %s: %s", scan.ID, err)
+	}
+
+	if err := m.db.SaveImageScan(scan); err != nil {
+		log.Errorf("error saving scan of %s: %s", scan.ID, err)
+	}
+
+	m.logEvent("scan-image", fmt.Sprintf("registry=%s repository=%s tag=%s status=%s max_severity=%s username=%s", reg.Name, scan.Repository, scan.Tag, scan.Status, scan.MaxSeverity, scan.ScannedBy), []string{"registry", "security"})
+}
+
+func (m DefaultManager) scanLayers(reg *shipyard.Registry, scan *shipyard.ImageScan) error {
+	layers, err := reg.Layers(scan.Repository, scan.Tag)
+	if err != nil {
+		return err
+	}
+
+	if len(layers) == 0 {
+		return fmt.Errorf("the image has no layers")
+	}
+
+	// layers are named by their chain so a layer shared by images with
+	// different parents is analyzed once for each parent
+	parent := ""
+	for _, digest := range layers {
+		url, headers := reg.BlobURL(scan.Repository, digest)
+		name := layerChainID(parent, digest)
+		if err := m.scanner.AnalyzeLayer(&clair.Layer{
+			Name:       name,
+			ParentName: parent,
+			Path:       url,
+			Headers:    headers,
+		}); err != nil {
+			return err
+		}
+		parent = name
+	}
+
+	features, err := m.scanner.Vulnerabilities(parent)
+	if err != nil {
+		return err
+	}
+
+	scan.Digest = layers[len(layers)-1]
+	scan.Vulnerabilities = scanVulnerabilities(features)
+	for _, v := range scan.Vulnerabilities {
+		scan.Counts[v.Severity]++
+		if scan.MaxSeverity == "" || shipyard.SeverityRank(v.Severity) > shipyard.SeverityRank(scan.MaxSeverity) {
+			scan.MaxSeverity = v.Severity
+		}
+	}
+
+	return nil
+}
+
+func layerChainID(parent, digest string) string {
+	if parent == "" {
+		parent = "-"
+	}
+
+	h := sha256.Sum256([]byte(parent + " " + digest))
+	return hex.EncodeToString(h[:])
+}
+
+// scanVulnerabilities returns the vulnerabilities of the features most
+// severe first
+func scanVulnerabilities(features []*clair.Feature) []*shipyard.Vulnerability {
+	vulnerabilities := []*shipyard.Vulnerability{}
+	for _, f := range features {
+		for _, v := range f.Vulnerabilities {
+			vulnerabilities = append(vulnerabilities, &shipyard.Vulnerability{
+				Name:     v.Name,
+				Severity: v.Severity,
+				Package:  f.Name,
+				Version:  f.Version,
+				FixedBy:  v.FixedBy,
+				Link:     v.Link,
+			})
+		}
+	}
+
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		a, b := vulnerabilities[i], vulnerabilities[j]
+		if ra, rb := shipyard.SeverityRank(a.Severity), shipyard.SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		return a.Name < b.Name
+	})
+
+	return vulnerabilities
+}
+
+// splitImage returns the registry host, repository and tag or digest of
+// an image; the tag is latest when not given
+func splitImage(image string) (string, string, string) {
+	host := imageRegistry(image)
+	repo := strings.TrimPrefix(image, host+"/")
+
+	if i := strings.Index(repo, "@"); i > -1 {
+		return host, repo[:i], repo[i+1:]
+	}
+
+	if i := strings.LastIndex(repo, ":"); i > -1 {
+		return host, repo[:i], repo[i+1:]
+	}
+
+	return host, repo, "latest"
+}
+
+// CheckImageScan scans the image, which has to be in a registry added to
+// shipyard, and returns a ScanThresholdError when it has vulnerabilities
+// more severe than maxSeverity; images which cannot be scanned are refused
+// as well
+func (m DefaultManager) CheckImageScan(image, maxSeverity string) error {
+	if m.scanner == nil {
+		return ErrScannerDisabled
+	}
+
+	host, repo, tag := splitImage(image)
+	if host == "" {
+		return ErrImageNotScannable
+	}
+
+	registries, err := m.Registries()
+	if err != nil {
+		return err
+	}
+
+	var reg *shipyard.Registry
+	for _, r := range registries {
+		if r.Host() == host && r.Version != shipyard.RegistryV1 {
+			reg = r
+			break
+		}
+	}
+	if reg == nil {
+		return ErrImageNotScannable
+	}
+
+	if err := reg.InitRegistryClient(); err != nil {
+		return err
+	}
+
+	// the image was just pushed so earlier scans of the tag are stale
+	scan, err := m.startImageScan(reg, repo, tag, "")
+	if err != nil {
+		return err
+	}
+	m.runImageScan(reg, scan)
+
+	if scan.Status != shipyard.ScanDone {
+		return fmt.Errorf("error scanning %s: %s", image, scan.Error)
+	}
+
+	if scan.MaxSeverity != "" && shipyard.SeverityRank(scan.MaxSeverity) > shipyard.SeverityRank(maxSeverity) {
+		m.logEvent("scan-refused", fmt.Sprintf("image=%s max_severity=%s allowed=%s", image, scan.MaxSeverity, maxSeverity), []string{"registry", "security"})
+		return &ScanThresholdError{Image: image, Severity: scan.MaxSeverity, Max: maxSeverity}
+	}
+
+	return nil
+}
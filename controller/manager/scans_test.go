@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/clair"
+)
+
+// getScanManager returns a manager with a registry serving team/web:1.2
+// and a scanner finding severity in its top layer; the layers submitted
+// to the scanner are recorded
+func getScanManager(t *testing.T, severity string) (DefaultManager, *shipyard.Registry, *[]*clair.Layer, func()) {
+	var mu sync.Mutex
+	submitted := []*clair.Layer{}
+
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/team/web/manifests/1.2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"schemaVersion":2,"layers":[{"digest":"sha256:base"},{"digest":"sha256:app"}]}`)
+	}))
+
+	scanner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == "POST" {
+			var body struct{ Layer *clair.Layer }
+			json.NewDecoder(r.Body).Decode(&body)
+			submitted = append(submitted, body.Layer)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		top := submitted[len(submitted)-1].Name
+		if r.URL.Path != "/v1/layers/"+top {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, `{"Layer":{"Name":%q,"Features":[
+			{"Name":"openssl","Version":"1.0.1","Vulnerabilities":[{"Name":"CVE-2","Severity":"Low"},{"Name":"CVE-1","Severity":%q}]},
+			{"Name":"bash","Version":"4.3","Vulnerabilities":[{"Name":"CVE-3","Severity":"Low"}]}]}}`, top, severity)
+	}))
+
+	m, closeStore := newTestManager(t)
+
+	registry := &shipyard.Registry{Name: "local", Addr: reg.URL, Username: "ci", Password: "secret"}
+	if err := m.db.SaveRegistry(registry); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.InitRegistryClient(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := clair.NewClient(scanner.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		reg.Close()
+		scanner.Close()
+		closeStore()
+	}
+
+	m.scanner = c
+
+	return m, registry, &submitted, cleanup
+}
+
+func TestRunImageScan(t *testing.T) {
+	m, reg, submitted, cleanup := getScanManager(t, shipyard.SeverityHigh)
+	defer cleanup()
+
+	scan, err := m.startImageScan(reg, "team/web", "1.2", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.startImageScan(reg, "team/web", "1.2", "alice"); err != ErrImageScanRunning {
+		t.Fatalf("expected ErrImageScanRunning; received %v", err)
+	}
+
+	m.runImageScan(reg, scan)
+
+	if len(*submitted) != 2 || (*submitted)[0].ParentName != "" || (*submitted)[1].ParentName != (*submitted)[0].Name {
+		t.Fatalf("expected the base layer to be the parent of the app layer; received %+v", *submitted)
+	}
+
+	if l := (*submitted)[1]; !strings.HasSuffix(l.Path, "/v2/team/web/blobs/sha256:app") || !strings.HasPrefix(l.Headers["Authorization"], "Basic ") {
+		t.Fatalf("expected the blob url with the credentials of the registry; received %+v", l)
+	}
+
+	saved, err := m.ImageScan(reg.ID, "team/web", "1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.Status != shipyard.ScanDone || saved.Digest != "sha256:app" || saved.MaxSeverity != shipyard.SeverityHigh {
+		t.Fatalf("unexpected scan %+v", saved)
+	}
+
+	if len(saved.Vulnerabilities) != 3 || saved.Vulnerabilities[0].Name != "CVE-1" || saved.Vulnerabilities[0].Package != "openssl" || saved.Counts[shipyard.SeverityLow] != 2 {
+		t.Fatalf("expected the vulnerabilities most severe first; received %+v", saved.Vulnerabilities)
+	}
+
+	if _, err := m.ImageScan(reg.ID, "team/web", "1.3"); err != ErrImageScanDoesNotExist {
+		t.Fatalf("expected ErrImageScanDoesNotExist; received %v", err)
+	}
+}
+
+func TestRunImageScanFailed(t *testing.T) {
+	m, reg, _, cleanup := getScanManager(t, shipyard.SeverityHigh)
+	defer cleanup()
+
+	scan, err := m.startImageScan(reg, "team/web", "missing", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.runImageScan(reg, scan)
+
+	if scan.Status != shipyard.ScanFailed || scan.Error == "" {
+		t.Fatalf("expected the scan to fail; received %+v", scan)
+	}
+}
+
+func TestCheckImageScan(t *testing.T) {
+	m, reg, _, cleanup := getScanManager(t, shipyard.SeverityCritical)
+	defer cleanup()
+
+	image := reg.Host() + "/team/web:1.2"
+
+	err := m.CheckImageScan(image, shipyard.SeverityHigh)
+	if e, ok := err.(*ScanThresholdError); !ok || e.Severity != shipyard.SeverityCritical {
+		t.Fatalf("expected a ScanThresholdError; received %v", err)
+	}
+
+	if err := m.CheckImageScan(image, shipyard.SeverityCritical); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, image := range []string{"nginx:latest", "registry.example.com/team/web:1.2"} {
+		if err := m.CheckImageScan(image, shipyard.SeverityHigh); err != ErrImageNotScannable {
+			t.Fatalf("expected ErrImageNotScannable for %s; received %v", image, err)
+		}
+	}
+
+	if err := (DefaultManager{}).CheckImageScan(image, shipyard.SeverityHigh); err != ErrScannerDisabled {
+		t.Fatalf("expected ErrScannerDisabled; received %v", err)
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	for image, expected := range map[string][3]string{
+		"nginx":                              {"", "nginx", "latest"},
+		"localhost:5000/team/web:1.2":        {"localhost:5000", "team/web", "1.2"},
+		"registry.example.com/web@sha256:ab": {"registry.example.com", "web", "sha256:ab"},
+	} {
+		host, repo, tag := splitImage(image)
+		if [3]string{host, repo, tag} != expected {
+			t.Fatalf("expected %v for %s; received %s %s %s", expected, image, host, repo, tag)
+		}
+	}
+}
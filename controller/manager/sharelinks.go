@@ -0,0 +1,295 @@
+package manager
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	tblNameShareLinks      = "share_links"
+	defaultShareLinkTTL    = 24 * time.Hour
+	maxShareLinkTTL        = 30 * 24 * time.Hour
+	shareLinkSecretLength  = 32
+	shareLinkIDLength      = 16
+	shareLinkTokenSplitter = "."
+)
+
+var (
+	ErrShareLinkDoesNotExist = errors.New("share link does not exist")
+	ErrInvalidShareLink      = errors.New("invalid share link")
+	ErrShareLinkExpired      = errors.New("share link expired or revoked")
+	ErrShareLinkViewDenied   = errors.New("share link does not grant the view")
+	ErrShareLinkTTL          = fmt.Errorf("share links can last at most %s", maxShareLinkTTL)
+	ErrShareLinkNoViews      = errors.New("a share link needs at least one view")
+	ErrShareLinkNoContainer  = errors.New("a container is required for log and stats views")
+
+	// shareViewPermissions are needed by the creator of a link for each
+	// of its views
+	shareViewPermissions = map[string]string{
+		shipyard.ShareViewLogs:      auth.PermContainersRead,
+		shipyard.ShareViewStats:     auth.PermContainersRead,
+		shipyard.ShareViewDashboard: auth.PermNodesRead,
+	}
+)
+
+// shareLinkSecret returns the key share link tokens are signed with; without
+// a configured secret a random one is used which invalidates links when the
+// controller restarts
+func shareLinkSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+
+	log.Warn("no share link secret set; share links will not survive a restart or work across controllers")
+
+	key := make([]byte, shareLinkSecretLength)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+// signShareLink returns the token of the link; the expiry is part of the
+// signature so it cannot be extended without the secret
+func (m DefaultManager) signShareLink(link *shipyard.ShareLink) string {
+	mac := hmac.New(sha256.New, m.shareLinkKey)
+	fmt.Fprintf(mac, "%s|%d", link.ID, link.ExpiresAt.Unix())
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return link.ID + shareLinkTokenSplitter + sig
+}
+
+// shareLink returns the link of a token after checking its signature
+func (m DefaultManager) shareLink(token string) (*shipyard.ShareLink, error) {
+	parts := strings.SplitN(token, shareLinkTokenSplitter, 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, ErrInvalidShareLink
+	}
+
+	link, err := m.db.ShareLink(parts[0])
+	if err != nil {
+		return nil, notFound(err, ErrInvalidShareLink)
+	}
+
+	if !hmac.Equal([]byte(m.signShareLink(link)), []byte(token)) {
+		return nil, ErrInvalidShareLink
+	}
+
+	return link, nil
+}
+
+func (m DefaultManager) ShareLinks() ([]*shipyard.ShareLink, error) {
+	return m.db.ShareLinks()
+}
+
+// shareLinkScope checks the creator of the link may still read every view
+// of it and see its container, and returns the containers the creator is
+// restricted to; links only share what their creator may see, so they are
+// checked when created and on every use
+func (m DefaultManager) shareLinkScope(link *shipyard.ShareLink) (auth.LabelScope, error) {
+	acct, err := m.Account(link.CreatedBy)
+	if err == ErrAccountDoesNotExist {
+		return nil, ErrShareLinkViewDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	acls, err := m.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, view := range link.Views {
+		allowed := false
+		for _, acl := range acls {
+			if acct.HasRole(acl.RoleName) && acl.HasPermission(shareViewPermissions[view]) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return nil, ErrShareLinkViewDenied
+		}
+	}
+
+	scope := auth.ContainerScope(acct, acls)
+	if link.ContainerID == "" {
+		return scope, nil
+	}
+
+	info, err := m.Container(link.ContainerID)
+	if err != nil {
+		return nil, containerNotFound(err)
+	}
+
+	labels := map[string]string{}
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+
+	if !scope.Matches(labels) {
+		return nil, ErrShareLinkViewDenied
+	}
+
+	return scope, nil
+}
+
+// NewShareLink creates the link for the creator who has to be able to read
+// the views themselves, and see the container; the token is set on the link
+func (m DefaultManager) NewShareLink(link *shipyard.ShareLink, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = defaultShareLinkTTL
+	}
+
+	if ttl < 0 || ttl > maxShareLinkTTL {
+		return ErrShareLinkTTL
+	}
+
+	if len(link.Views) == 0 {
+		return ErrShareLinkNoViews
+	}
+
+	for _, view := range link.Views {
+		switch view {
+		case shipyard.ShareViewLogs, shipyard.ShareViewStats:
+			if link.ContainerID == "" {
+				return ErrShareLinkNoContainer
+			}
+		case shipyard.ShareViewDashboard:
+		default:
+			return fmt.Errorf("unknown share link view: %s", view)
+		}
+	}
+
+	if _, err := m.shareLinkScope(link); err != nil {
+		return err
+	}
+
+	buf := make([]byte, shareLinkIDLength)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	link.ID = hex.EncodeToString(buf)
+	link.CreatedAt = now
+	link.ExpiresAt = now.Add(ttl)
+	link.RevokedAt = nil
+	link.RevokedBy = ""
+	link.AccessCount = 0
+	link.LastAccessed = nil
+
+	if err := m.db.SaveShareLink(link); err != nil {
+		return err
+	}
+
+	link.Token = m.signShareLink(link)
+
+	m.logEvent("add-share-link", fmt.Sprintf("id=%s container=%s views=%s created_by=%s expires=%s",
+		link.ID, link.ContainerID, strings.Join(link.Views, ","), link.CreatedBy, link.ExpiresAt.Format(time.RFC3339)), []string{"security", "share"})
+
+	return nil
+}
+
+func (m DefaultManager) RevokeShareLink(id, username string) error {
+	link, err := m.db.ShareLink(id)
+	if err != nil {
+		return notFound(err, ErrShareLinkDoesNotExist)
+	}
+
+	if link.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	link.RevokedAt = &now
+	link.RevokedBy = username
+
+	if err := m.db.SaveShareLink(link); err != nil {
+		return err
+	}
+
+	m.logEvent("revoke-share-link", fmt.Sprintf("id=%s revoked_by=%s", id, username), []string{"security", "share"})
+
+	return nil
+}
+
+// UseShareLink returns the link of the token when it grants the view and
+// records the access; the link is denied once its creator may no longer
+// see what it shares
+func (m DefaultManager) UseShareLink(token, view, remoteAddr string) (*shipyard.ShareLink, error) {
+	link, err := m.shareLink(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !link.Active(now) {
+		return nil, ErrShareLinkExpired
+	}
+
+	// the info of the link is available with any view
+	if view != "" && !link.Allows(view) {
+		return nil, ErrShareLinkViewDenied
+	}
+
+	if _, err := m.shareLinkScope(link); err != nil {
+		return nil, err
+	}
+
+	if err := m.db.RecordShareLinkAccess(link.ID, now); err != nil {
+		return nil, err
+	}
+
+	evt := &shipyard.Event{
+		Type:       "share-link-access",
+		Time:       now,
+		Message:    fmt.Sprintf("id=%s view=%s created_by=%s", link.ID, view, link.CreatedBy),
+		RemoteAddr: remoteAddr,
+		Tags:       []string{"security", "share"},
+	}
+
+	if err := m.SaveEvent(evt); err != nil {
+		log.Errorf("error saving share link access event: %s", err)
+	}
+
+	return link, nil
+}
+
+// ShareLinkContainers returns the containers of the cluster shown by the
+// dashboard of the link, the ones its creator may see
+func (m DefaultManager) ShareLinkContainers(link *shipyard.ShareLink) ([]dockerclient.Container, error) {
+	scope, err := m.shareLinkScope(link)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := m.DockerClient().ListContainers(true, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []dockerclient.Container{}
+	for _, c := range containers {
+		if scope.Matches(c.Labels) {
+			visible = append(visible, c)
+		}
+	}
+
+	return visible, nil
+}
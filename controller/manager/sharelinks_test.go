@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestSignShareLink(t *testing.T) {
+	m := DefaultManager{shareLinkKey: []byte("secret")}
+	link := &shipyard.ShareLink{ID: "abc", ExpiresAt: time.Unix(1700000000, 0)}
+
+	token := m.signShareLink(link)
+	if token != m.signShareLink(link) {
+		t.Fatal("expected the same token for the same link")
+	}
+
+	// extending a link invalidates its token
+	extended := *link
+	extended.ExpiresAt = link.ExpiresAt.Add(time.Hour)
+	if m.signShareLink(&extended) == token {
+		t.Fatal("expected a different token for a different expiry")
+	}
+
+	other := DefaultManager{shareLinkKey: []byte("other")}
+	if other.signShareLink(link) == token {
+		t.Fatal("expected a different token for a different secret")
+	}
+}
+
+func TestNewShareLinkScope(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		id := parts[len(parts)-2]
+		if id != "web" && id != "db" {
+			http.Error(w, "No such container: "+id, http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(dockerclient.ContainerInfo{
+			Id:     id,
+			Config: &dockerclient.ContainerConfig{Labels: map[string]string{"team": id}},
+		})
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	if err := m.db.CreateAccount(&auth.Account{
+		Username:   "alice",
+		Roles:      []string{"containers:ro"},
+		LabelScope: auth.LabelScope{"team=web"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, expected := range map[string]error{
+		"web":  nil,
+		"db":   ErrShareLinkViewDenied,
+		"gone": dockerclient.ErrNotFound,
+	} {
+		link := &shipyard.ShareLink{ContainerID: id, Views: []string{shipyard.ShareViewLogs}, CreatedBy: "alice"}
+		if err := m.NewShareLink(link, time.Hour); err != expected {
+			t.Fatalf("%s: expected %v; received %v", id, expected, err)
+		}
+	}
+}
+
+func TestShareLinkCreatorChecks(t *testing.T) {
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Id":"web","Labels":{"team":"web"}},{"Id":"db","Labels":{"team":"db"}}]`))
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+	m.shareLinkKey = []byte("secret")
+
+	if err := m.db.CreateAccount(&auth.Account{
+		Username:   "alice",
+		Roles:      []string{"nodes:ro"},
+		LabelScope: auth.LabelScope{"team=web"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	link := &shipyard.ShareLink{Views: []string{shipyard.ShareViewDashboard}, CreatedBy: "alice"}
+	if err := m.NewShareLink(link, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := m.ShareLinkContainers(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(containers) != 1 || containers[0].Id != "web" {
+		t.Fatalf("expected the dashboard to show the containers in the scope of the creator; received %+v", containers)
+	}
+
+	if _, err := m.UseShareLink(link.Token, shipyard.ShareViewDashboard, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the link is denied once the creator may no longer read the view
+	if err := m.db.UpdateAccount("alice", func(acct *auth.Account) error {
+		acct.Roles = []string{"containers:ro"}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.UseShareLink(link.Token, shipyard.ShareViewDashboard, "10.0.0.1"); err != ErrShareLinkViewDenied {
+		t.Fatalf("expected the link to be denied without the permission of the creator; received %v", err)
+	}
+
+	if err := m.db.DeleteAccount("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.UseShareLink(link.Token, shipyard.ShareViewDashboard, "10.0.0.1"); err != ErrShareLinkViewDenied {
+		t.Fatalf("expected the link of a removed creator to be denied; received %v", err)
+	}
+}
+
+func TestShareLinkActive(t *testing.T) {
+	now := time.Now()
+	revoked := now.Add(-time.Minute)
+
+	tests := []struct {
+		link   *shipyard.ShareLink
+		active bool
+	}{
+		{&shipyard.ShareLink{ExpiresAt: now.Add(time.Hour)}, true},
+		{&shipyard.ShareLink{ExpiresAt: now.Add(-time.Hour)}, false},
+		{&shipyard.ShareLink{ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+	}
+
+	for i, test := range tests {
+		if active := test.link.Active(now); active != test.active {
+			t.Fatalf("test %d: expected active=%v; received %v", i, test.active, active)
+		}
+	}
+}
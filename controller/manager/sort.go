@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+// Fields lists can be sorted by
+const (
+	SortName     = "name"
+	SortCreated  = "created"
+	SortState    = "state"
+	SortNode     = "node"
+	SortType     = "type"
+	SortUsername = "username"
+	SortAddr     = "addr"
+)
+
+var (
+	// the first field of each list is its default
+	AccountSortFields   = []string{SortName}
+	EventSortFields     = []string{SortCreated, SortType, SortUsername}
+	NodeSortFields      = []string{SortName, SortState, SortAddr}
+	RegistrySortFields  = []string{SortName, SortAddr}
+	ContainerSortFields = []string{SortName, SortCreated, SortState, SortNode}
+)
+
+// SortError is returned for unknown sort fields or orders
+type SortError struct {
+	Reason string
+}
+
+func (e *SortError) Error() string {
+	return "invalid sort: " + e.Reason
+}
+
+// Sort orders a list by one of its fields
+type Sort struct {
+	Field string
+	// Desc reverses the order
+	Desc bool
+}
+
+// ParseSort checks the field (i.e. ?sort=name) is one of the fields and
+// the order (?order=asc or desc) is valid; an empty field sorts by the
+// first field and an empty order uses the default of the list
+func ParseSort(field, order string, fields []string, desc bool) (*Sort, error) {
+	s := &Sort{Field: strings.ToLower(field), Desc: desc}
+	if s.Field == "" {
+		s.Field = fields[0]
+	}
+
+	if !hasField(fields, s.Field) {
+		return nil, &SortError{fmt.Sprintf("cannot sort by %q; use one of %s", field, strings.Join(fields, ", "))}
+	}
+
+	switch strings.ToLower(order) {
+	case "":
+	case "asc":
+		s.Desc = false
+	case "desc":
+		s.Desc = true
+	default:
+		return nil, &SortError{fmt.Sprintf("unknown order %q; use asc or desc", order)}
+	}
+
+	return s, nil
+}
+
+// sortSlice sorts the slice with the less function of the field, keeping
+// the order of equal items
+func (s *Sort) sortSlice(slice interface{}, less func(i, j int) bool) {
+	sort.SliceStable(slice, func(i, j int) bool {
+		if s.Desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+// SortNodes sorts nodes by name, state or address
+func SortNodes(nodes []*shipyard.Node, s *Sort) {
+	s.sortSlice(nodes, func(i, j int) bool {
+		switch s.Field {
+		case SortState:
+			return nodes[i].Status < nodes[j].Status
+		case SortAddr:
+			return nodes[i].Addr < nodes[j].Addr
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+}
+
+// SortRegistries sorts registries by name or address
+func SortRegistries(registries []*shipyard.Registry, s *Sort) {
+	s.sortSlice(registries, func(i, j int) bool {
+		if s.Field == SortAddr {
+			return registries[i].Addr < registries[j].Addr
+		}
+		return strings.ToLower(registries[i].Name) < strings.ToLower(registries[j].Name)
+	})
+}
+
+// sortContainers sorts containers by name, creation, state or node; the
+// state is the status reported by the engine (i.e. Exited or Up) so
+// containers in the same state are next to each other
+func sortContainers(containers []dockerclient.Container, s *Sort) {
+	s.sortSlice(containers, func(i, j int) bool {
+		a, b := containers[i], containers[j]
+		switch s.Field {
+		case SortCreated:
+			return a.Created < b.Created
+		case SortState:
+			return a.Status < b.Status
+		case SortNode:
+			return containerNode(a) < containerNode(b)
+		}
+		return containerName(a) < containerName(b)
+	})
+}
+
+// SortAccountQuery sorts the accounts of the query; they are sorted by the
+// datastore as it pages them
+func SortAccountQuery(q *datastore.AccountQuery, s *Sort) {
+	q.Descending = s.Desc
+}
+
+// SortEventQuery sorts the events of the query; they are sorted by the
+// datastore as it pages them
+func SortEventQuery(q *datastore.EventQuery, s *Sort) {
+	q.Ascending = !s.Desc
+	if s.Field != SortCreated {
+		q.SortBy = s.Field
+	}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+func TestParseSort(t *testing.T) {
+	s, err := ParseSort("", "", NodeSortFields, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Field != SortName || s.Desc {
+		t.Fatalf("expected the default field and order; received %+v", s)
+	}
+
+	if s, err = ParseSort("State", "DESC", NodeSortFields, false); err != nil || s.Field != SortState || !s.Desc {
+		t.Fatalf("expected state descending; received %+v %v", s, err)
+	}
+
+	if s, err = ParseSort(SortType, "asc", EventSortFields, true); err != nil || s.Desc {
+		t.Fatalf("expected the order to replace the default; received %+v %v", s, err)
+	}
+
+	for _, c := range [][]string{{SortNode, ""}, {SortName, "up"}} {
+		if _, err := ParseSort(c[0], c[1], NodeSortFields, false); err == nil {
+			t.Errorf("expected %v to be invalid", c)
+		} else if _, ok := err.(*SortError); !ok {
+			t.Errorf("expected a sort error; received %v", err)
+		}
+	}
+}
+
+func TestSortNodes(t *testing.T) {
+	nodes := []*shipyard.Node{
+		{Name: "node-2", Status: "Healthy"},
+		{Name: "node-1", Status: "Unhealthy"},
+		{Name: "node-3", Status: "Healthy"},
+	}
+
+	SortNodes(nodes, &Sort{Field: SortName})
+	if nodes[0].Name != "node-1" || nodes[2].Name != "node-3" {
+		t.Fatalf("expected nodes sorted by name; received %v %v %v", nodes[0].Name, nodes[1].Name, nodes[2].Name)
+	}
+
+	// equal nodes keep their order
+	SortNodes(nodes, &Sort{Field: SortState, Desc: true})
+	if nodes[0].Name != "node-1" || nodes[1].Name != "node-2" || nodes[2].Name != "node-3" {
+		t.Fatalf("expected unhealthy nodes first; received %v %v %v", nodes[0].Name, nodes[1].Name, nodes[2].Name)
+	}
+}
+
+func TestSortContainers(t *testing.T) {
+	containers := []dockerclient.Container{
+		{Id: "a", Names: []string{"/node-2/web"}, Created: 2},
+		{Id: "b", Names: []string{"/node-1/db"}, Created: 3},
+		{Id: "c", Names: []string{"/node-1/cache"}, Created: 1},
+	}
+
+	for _, c := range []struct {
+		sort     *Sort
+		expected string
+	}{
+		{&Sort{Field: SortName}, "cba"},
+		{&Sort{Field: SortCreated, Desc: true}, "bac"},
+		{&Sort{Field: SortNode}, "bca"},
+	} {
+		sortContainers(containers, c.sort)
+
+		ids := ""
+		for _, container := range containers {
+			ids += container.Id
+		}
+
+		if ids != c.expected {
+			t.Errorf("%+v: expected %s; received %s", c.sort, c.expected, ids)
+		}
+	}
+}
+
+func TestSortEventQuery(t *testing.T) {
+	q := &datastore.EventQuery{}
+	SortEventQuery(q, &Sort{Field: SortCreated, Desc: true})
+	if q.Ascending || q.SortBy != "" {
+		t.Fatalf("expected newest first; received %+v", q)
+	}
+
+	SortEventQuery(q, &Sort{Field: SortUsername})
+	if !q.Ascending || q.SortBy != datastore.EventSortUsername {
+		t.Fatalf("expected usernames from a to z; received %+v", q)
+	}
+}
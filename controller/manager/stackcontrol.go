@@ -0,0 +1,212 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+const (
+	// Actions of a stack operation
+	StackStop  = "stop"
+	StackStart = "start"
+
+	// DefaultStackStepTimeout is how long the containers of a service get
+	// to stop or start
+	DefaultStackStepTimeout = 30 * time.Second
+	maxStackStepTimeout     = 10 * time.Minute
+	// stopKillGrace is added to the timeout of a stop step for docker to
+	// kill the containers which did not stop within the timeout
+	stopKillGrace = 10 * time.Second
+	// startCheckInterval is how often a started container is inspected
+	// until it runs
+	startCheckInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrInvalidStackStepTimeout = errors.New("step timeouts have to be between 1s and 10m")
+)
+
+// StackStepError is returned when a step of a stop or start fails or
+// times out; the steps after it are skipped
+type StackStepError struct {
+	Action  string
+	Service string
+	Err     error
+}
+
+func (e *StackStepError) Error() string {
+	return fmt.Sprintf("error running %s of service %s: %s", e.Action, e.Service, e.Err)
+}
+
+// StopStack stops the containers of the stack a service at a time, the
+// services depending on others first, so consumers stop before the
+// services they use. The operation is returned with the status of every
+// step, also when a step fails.
+func (m DefaultManager) StopStack(name string, timeout time.Duration, username string) (*shipyard.StackOperation, error) {
+	return m.controlStack(name, StackStop, timeout, username)
+}
+
+// StartStack starts the containers of the stack a service at a time in the
+// reverse order of StopStack: services start after their dependencies run
+func (m DefaultManager) StartStack(name string, timeout time.Duration, username string) (*shipyard.StackOperation, error) {
+	return m.controlStack(name, StackStart, timeout, username)
+}
+
+func (m DefaultManager) controlStack(name, action string, timeout time.Duration, username string) (*shipyard.StackOperation, error) {
+	if timeout == 0 {
+		timeout = DefaultStackStepTimeout
+	}
+	if timeout < time.Second || timeout > maxStackStepTimeout {
+		return nil, ErrInvalidStackStepTimeout
+	}
+
+	unlock, err := m.lockStack(name, username, action, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stack, err := m.db.Stack(name)
+	if err != nil {
+		return nil, notFound(err, ErrStackDoesNotExist)
+	}
+
+	f, err := compose.Parse([]byte(stack.Compose))
+	if err != nil {
+		return nil, &ComposeError{Err: err}
+	}
+
+	order, err := f.Order()
+	if err != nil {
+		return nil, &ComposeError{Err: err}
+	}
+
+	if action == StackStop {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	containers, err := m.stackContainers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	services := map[string][]string{}
+	for _, c := range containers {
+		service := c.Labels[compose.LabelService]
+		services[service] = append(services[service], c.Id)
+	}
+
+	op := &shipyard.StackOperation{
+		Stack:     name,
+		Action:    action,
+		Status:    shipyard.StackStepDone,
+		StartedAt: time.Now(),
+	}
+	for _, service := range order {
+		op.Steps = append(op.Steps, &shipyard.StackStep{
+			Service:    service,
+			Containers: append([]string{}, services[service]...),
+		})
+	}
+
+	var stepErr error
+	for _, step := range op.Steps {
+		if stepErr != nil {
+			step.Status = shipyard.StackStepSkipped
+			continue
+		}
+
+		if action == StackStop {
+			stepErr = m.runStackStep(step, action, timeout+stopKillGrace, func(id string) error {
+				return m.DockerClient().StopContainer(id, int(timeout.Seconds()))
+			})
+		} else {
+			stepErr = m.runStackStep(step, action, timeout, m.startStackContainer)
+		}
+	}
+
+	op.FinishedAt = time.Now()
+	if stepErr != nil {
+		op.Status = shipyard.StackStepFailed
+		m.logEvent(action+"-stack", fmt.Sprintf("name=%s username=%s error=%s", name, username, stepErr), []string{"stack"})
+		return op, stepErr
+	}
+
+	m.logEvent(action+"-stack", fmt.Sprintf("name=%s username=%s", name, username), []string{"stack"})
+
+	return op, nil
+}
+
+// runStackStep runs fn for the containers of the step at the same time; a
+// step still running at the timeout is left to finish in the background
+func (m DefaultManager) runStackStep(step *shipyard.StackStep, action string, timeout time.Duration, fn func(id string) error) error {
+	start := time.Now()
+	defer func() { step.Duration = time.Since(start).Seconds() }()
+
+	errs := make(chan error, len(step.Containers))
+	for _, id := range step.Containers {
+		go func(id string) {
+			if err := fn(id); err != nil {
+				errs <- fmt.Errorf("%s: %s", id, err)
+				return
+			}
+			errs <- nil
+		}(id)
+	}
+
+	deadline := time.After(timeout)
+	var err error
+	for range step.Containers {
+		select {
+		case e := <-errs:
+			if e != nil && err == nil {
+				err = e
+			}
+		case <-deadline:
+			step.Status = shipyard.StackStepTimedOut
+			step.Error = fmt.Sprintf("timed out after %s", timeout)
+			return &StackStepError{Action: action, Service: step.Service, Err: errors.New(step.Error)}
+		}
+	}
+
+	if err != nil {
+		step.Status = shipyard.StackStepFailed
+		step.Error = err.Error()
+		return &StackStepError{Action: action, Service: step.Service, Err: err}
+	}
+
+	step.Status = shipyard.StackStepDone
+	return nil
+}
+
+// startStackContainer starts the container and waits until it runs;
+// containers exiting right away fail the step
+func (m DefaultManager) startStackContainer(id string) error {
+	client := m.DockerClient()
+	if err := client.StartContainer(id, nil); err != nil {
+		return err
+	}
+
+	for {
+		info, err := client.InspectContainer(id)
+		if err != nil {
+			return err
+		}
+
+		if info.State == nil || info.State.Running {
+			return nil
+		}
+
+		if !info.State.Restarting {
+			return fmt.Errorf("exited with %d", info.State.ExitCode)
+		}
+
+		time.Sleep(startCheckInterval)
+	}
+}
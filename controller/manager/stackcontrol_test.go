@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+// getStackControlManager returns a manager with the stack shop, whose web
+// service depends on db, on an engine recording the containers stopped and
+// started; containers in exited do not stay up when started
+func getStackControlManager(t *testing.T, exited map[string]bool) (DefaultManager, *[]string, func()) {
+	var mu sync.Mutex
+	calls := []string{}
+
+	containers := []dockerclient.Container{
+		{Id: "web-1", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "web"}},
+		{Id: "db-1", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "db"}},
+		{Id: "web-2", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "web"}},
+	}
+
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		parts := strings.Split(r.URL.Path, "/")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			json.NewEncoder(w).Encode(containers)
+		case strings.HasSuffix(r.URL.Path, "/stop"), strings.HasSuffix(r.URL.Path, "/start"):
+			calls = append(calls, parts[len(parts)-1]+" "+parts[len(parts)-2])
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			id := parts[len(parts)-2]
+			state := &dockerclient.State{Running: !exited[id]}
+			if exited[id] {
+				state.ExitCode = 1
+			}
+			json.NewEncoder(w).Encode(dockerclient.ContainerInfo{Id: id, State: state})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	m, closeStore := newTestManager(t)
+
+	if err := m.db.SaveStack(&shipyard.Stack{
+		Name:     "shop",
+		Compose:  "services:\n  web:\n    image: nginx\n    depends_on: [db]\n  db:\n    image: postgres\n",
+		Services: []string{"db", "web"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		engine.Close()
+		closeStore()
+	}
+
+	m.client = &clusterClient{client: client}
+	m.stackLocks = newStackLocks()
+
+	return m, &calls, cleanup
+}
+
+func TestStopStackOrder(t *testing.T) {
+	m, calls, cleanup := getStackControlManager(t, nil)
+	defer cleanup()
+
+	op, err := m.StopStack("shop", 0, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if op.Status != shipyard.StackStepDone || len(op.Steps) != 2 || op.Steps[0].Service != "web" || op.Steps[1].Service != "db" {
+		t.Fatalf("expected web to stop before db; received %+v", op.Steps)
+	}
+
+	// the replicas of web stop at the same time, in any order
+	if len(*calls) != 3 || (*calls)[2] != "stop db-1" {
+		t.Fatalf("expected db to stop last; received %v", *calls)
+	}
+}
+
+func TestStartStackSkipsDependents(t *testing.T) {
+	m, calls, cleanup := getStackControlManager(t, map[string]bool{"db-1": true})
+	defer cleanup()
+
+	op, err := m.StartStack("shop", 0, "alice")
+	if _, ok := err.(*StackStepError); !ok {
+		t.Fatalf("expected a StackStepError; received %v", err)
+	}
+
+	if op.Status != shipyard.StackStepFailed || op.Steps[0].Service != "db" || op.Steps[0].Status != shipyard.StackStepFailed {
+		t.Fatalf("expected db to fail; received %+v", op.Steps)
+	}
+
+	if op.Steps[1].Status != shipyard.StackStepSkipped || len(*calls) != 1 {
+		t.Fatalf("expected web not to start; received %+v %v", op.Steps[1], *calls)
+	}
+
+	if _, err := m.StartStack("shop", time.Hour, "alice"); err != ErrInvalidStackStepTimeout {
+		t.Fatalf("expected ErrInvalidStackStepTimeout; received %v", err)
+	}
+}
+
+func TestRunStackStepTimeout(t *testing.T) {
+	m := DefaultManager{}
+	step := &shipyard.StackStep{Service: "db", Containers: []string{"db-1"}}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	err := m.runStackStep(step, StackStop, 10*time.Millisecond, func(id string) error {
+		<-done
+		return nil
+	})
+	if _, ok := err.(*StackStepError); !ok || step.Status != shipyard.StackStepTimedOut {
+		t.Fatalf("expected the step to time out; received %v %+v", err, step)
+	}
+}
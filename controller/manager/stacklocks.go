@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard"
+)
+
+const (
+	// stackLockTimeout bounds how long a deploy holds the lease of its
+	// stack if its controller dies before releasing it
+	stackLockTimeout = 10 * time.Minute
+	// stackLockPoll is how often a queued deploy checks the lock again
+	stackLockPoll = time.Second
+	// redeployStackWait is how long webhook redeploys queue behind
+	// another deploy of a stack before giving up
+	redeployStackWait = 5 * time.Minute
+)
+
+// StackLockedError is returned when another deploy holds the lock of the
+// stack
+type StackLockedError struct {
+	Lock *shipyard.StackLock
+}
+
+func (e *StackLockedError) Error() string {
+	l := e.Lock
+	if l.Since.IsZero() {
+		if l.Controller == "" {
+			return fmt.Sprintf("stack %s is being deployed by another controller", l.Stack)
+		}
+		return fmt.Sprintf("stack %s is being deployed by controller %s", l.Stack, l.Controller)
+	}
+
+	holder := l.Operation
+	if l.Username != "" {
+		holder = fmt.Sprintf("%s (%s)", l.Username, l.Operation)
+	}
+
+	return fmt.Sprintf("stack %s is locked by %s since %s", l.Stack, holder, l.Since.Format(time.RFC3339))
+}
+
+// stackLocks are the stacks deployed by this controller
+type stackLocks struct {
+	mu    sync.Mutex
+	locks map[string]*shipyard.StackLock
+	// poll is how often a queued deploy checks the lock again
+	poll time.Duration
+}
+
+func newStackLocks() *stackLocks {
+	return &stackLocks{
+		locks: map[string]*shipyard.StackLock{},
+		poll:  stackLockPoll,
+	}
+}
+
+// acquire takes the lock of the stack unless it is held, in which case
+// the holder is returned; a nil tracker never locks
+func (l *stackLocks) acquire(lock *shipyard.StackLock) *shipyard.StackLock {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if held, ok := l.locks[lock.Stack]; ok {
+		h := *held
+		return &h
+	}
+	l.locks[lock.Stack] = lock
+
+	return nil
+}
+
+func (l *stackLocks) release(stack string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.locks, stack)
+}
+
+// get returns a copy of the lock of the stack; nil when it is free
+func (l *stackLocks) get(stack string) *shipyard.StackLock {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	held, ok := l.locks[stack]
+	if !ok {
+		return nil
+	}
+	h := *held
+
+	return &h
+}
+
+// tryLockStack takes the lock of the stack on this controller and its
+// lease across controllers; the returned func releases both
+func (m DefaultManager) tryLockStack(name, username, operation string) (func(), error) {
+	lock := &shipyard.StackLock{
+		Stack:     name,
+		Username:  username,
+		Operation: operation,
+		Since:     time.Now(),
+	}
+
+	if held := m.stackLocks.acquire(lock); held != nil {
+		return nil, &StackLockedError{Lock: held}
+	}
+
+	lease := "stack:" + name
+	leased, err := m.acquireLease(lease, stackLockTimeout)
+	if err != nil {
+		m.stackLocks.release(name)
+		return nil, err
+	}
+
+	if !leased {
+		m.stackLocks.release(name)
+
+		holder, err := m.leaseHolder(lease)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, &StackLockedError{Lock: &shipyard.StackLock{Stack: name, Controller: holder}}
+	}
+
+	return func() {
+		if err := m.releaseLease(lease); err != nil {
+			log.Errorf("error releasing the lease of stack %s: %s", name, err)
+		}
+		m.stackLocks.release(name)
+	}, nil
+}
+
+// lockStack takes the lock of the stack, queueing for up to wait while
+// another deploy holds it; without a wait it fails right away with the
+// holder of the lock
+func (m DefaultManager) lockStack(name, username, operation string, wait time.Duration) (func(), error) {
+	poll := stackLockPoll
+	if m.stackLocks != nil {
+		poll = m.stackLocks.poll
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		unlock, err := m.tryLockStack(name, username, operation)
+		if _, locked := err.(*StackLockedError); !locked || !time.Now().Before(deadline) {
+			return unlock, err
+		}
+
+		time.Sleep(poll)
+	}
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockStack(t *testing.T) {
+	m := DefaultManager{stackLocks: newStackLocks()}
+
+	unlock, err := m.lockStack("web", "alice", "redeploy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := m.stackLocks.get("web"); l == nil || l.Username != "alice" {
+		t.Fatalf("expected alice to hold the lock; received %+v", l)
+	}
+
+	_, err = m.lockStack("web", "bob", "deploy", 0)
+	locked, ok := err.(*StackLockedError)
+	if !ok {
+		t.Fatalf("expected a StackLockedError; received %v", err)
+	}
+
+	if locked.Lock.Username != "alice" || !strings.Contains(err.Error(), "alice (redeploy)") {
+		t.Fatalf("expected the holder of the lock; received %s", err)
+	}
+
+	// other stacks are not locked
+	unlockAPI, err := m.lockStack("api", "bob", "deploy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockAPI()
+
+	unlock()
+
+	if l := m.stackLocks.get("web"); l != nil {
+		t.Fatalf("expected the lock to be released; received %+v", l)
+	}
+
+	unlock, err = m.lockStack("web", "bob", "deploy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
+
+func TestLockStackQueues(t *testing.T) {
+	locks := newStackLocks()
+	locks.poll = time.Millisecond
+	m := DefaultManager{stackLocks: locks}
+
+	unlock, err := m.lockStack("web", "alice", "redeploy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		unlock()
+	}()
+
+	queued, err := m.lockStack("web", "", "redeploy nginx", time.Second)
+	if err != nil {
+		t.Fatalf("expected the redeploy to wait for the lock; received %s", err)
+	}
+	defer queued()
+
+	if l := m.stackLocks.get("web"); l == nil || l.Operation != "redeploy nginx" {
+		t.Fatalf("expected the queued redeploy to hold the lock; received %+v", l)
+	}
+
+	if _, err := m.lockStack("web", "bob", "deploy", 10*time.Millisecond); err == nil {
+		t.Fatal("expected the wait to time out")
+	}
+}
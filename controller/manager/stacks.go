@@ -0,0 +1,325 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+	"github.com/shipyard/shipyard/controller/datastore"
+	"github.com/shipyard/shipyard/notification"
+)
+
+const (
+	tblNameStacks = "stacks"
+)
+
+var (
+	ErrStackDoesNotExist = errors.New("stack does not exist")
+	ErrStackExists       = errors.New("a stack with the name already exists")
+	ErrInvalidStackName  = errors.New("stack names can only contain lowercase letters, digits, dashes and underscores")
+)
+
+// ComposeError is returned for compose files that cannot be deployed
+type ComposeError struct {
+	Err error
+}
+
+func (e *ComposeError) Error() string {
+	return fmt.Sprintf("invalid compose file: %s", e.Err)
+}
+
+func (m DefaultManager) Stacks() ([]*shipyard.Stack, error) {
+	return m.db.Stacks()
+}
+
+// Stack returns the stack with its containers
+func (m DefaultManager) Stack(name string) (*shipyard.Stack, error) {
+	stack, err := m.db.Stack(name)
+	if err != nil {
+		return nil, notFound(err, ErrStackDoesNotExist)
+	}
+
+	containers, err := m.stackContainers(name)
+	if err != nil {
+		return nil, err
+	}
+	stack.Containers = containers
+	stack.Lock = m.stackLocks.get(name)
+
+	return stack, nil
+}
+
+// stackContainers returns the containers labeled with the stack name
+func (m DefaultManager) stackContainers(name string) ([]dockerclient.Container, error) {
+	filters := fmt.Sprintf(`{"label":["%s=%s"]}`, compose.LabelProject, name)
+	return m.DockerClient().ListContainers(true, false, url.QueryEscape(filters))
+}
+
+// DeployStack creates the containers of the services of a compose file
+// and tracks them under the stack name; when a container cannot be
+// created the containers created so far are removed. Deploys, redeploys
+// and removals of a stack fail with a StackLockedError while another one
+// runs. The smoke tests of the compose file then run and the containers
+// are removed when one fails; every deploy is recorded as a deployment.
+func (m DefaultManager) DeployStack(name string, data []byte, username string, release *shipyard.Release) (stack *shipyard.Stack, err error) {
+	if !compose.ValidName(name) {
+		return nil, ErrInvalidStackName
+	}
+
+	if err := validateRelease(release); err != nil {
+		return nil, err
+	}
+
+	unlock, err := m.lockStack(name, username, "deploy", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if _, err := m.db.Stack(name); err == nil {
+		return nil, ErrStackExists
+	} else if err != datastore.ErrNotFound {
+		return nil, err
+	}
+
+	f, err := compose.Parse(data)
+	if err != nil {
+		return nil, &ComposeError{Err: err}
+	}
+
+	if err := m.checkStackFreeze(f, username, "deploy stack "+name); err != nil {
+		return nil, err
+	}
+
+	d := m.startDeployment(name, "deploy", username, release)
+	defer func() { m.finishDeployment(d, err) }()
+
+	if err := m.pullStackImages(name, f); err != nil {
+		return nil, err
+	}
+
+	if err := m.createStackContainers(name, f); err != nil {
+		m.logEvent("deploy-stack", fmt.Sprintf("name=%s username=%s error=%s%s", name, username, err, releaseText(release)), []string{"deploy", "stack"})
+		return nil, err
+	}
+
+	if err := m.smokeTestStack(name, f, d); err != nil {
+		err.(*SmokeTestError).Rollback = m.rollbackStack(name, f, nil)
+		m.logEvent("deploy-stack", fmt.Sprintf("name=%s username=%s error=%s%s", name, username, err, releaseText(release)), []string{"deploy", "stack"})
+		return nil, err
+	}
+
+	order, _ := f.Order()
+	stack = &shipyard.Stack{
+		Name:      name,
+		Compose:   string(data),
+		Services:  order,
+		CreatedBy: username,
+		CreatedAt: time.Now(),
+	}
+
+	if err := m.db.SaveStack(stack); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("deploy-stack", fmt.Sprintf("name=%s services=%s username=%s%s", name, strings.Join(order, ","), username, releaseText(release)), []string{"deploy", "stack"})
+
+	return stack, nil
+}
+
+// RedeployStack pulls the images of the stack and replaces its containers;
+// when a smoke test fails the containers are recreated from the images
+// they ran before
+func (m DefaultManager) RedeployStack(name, username string, release *shipyard.Release) (stack *shipyard.Stack, err error) {
+	if err := validateRelease(release); err != nil {
+		return nil, err
+	}
+
+	unlock, err := m.lockStack(name, username, "redeploy", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stack, err = m.db.Stack(name)
+	if err != nil {
+		return nil, notFound(err, ErrStackDoesNotExist)
+	}
+
+	f, err := compose.Parse([]byte(stack.Compose))
+	if err != nil {
+		return nil, &ComposeError{Err: err}
+	}
+
+	if err := m.checkStackFreeze(f, username, "redeploy stack "+name); err != nil {
+		return nil, err
+	}
+
+	d := m.startDeployment(name, "redeploy", username, release)
+	defer func() { m.finishDeployment(d, err) }()
+
+	// pull first so a missing image leaves the stack running
+	if err := m.pullStackImages(name, f); err != nil {
+		return nil, err
+	}
+
+	previous, err := m.stackImages(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.removeStackContainers(name); err != nil {
+		return nil, err
+	}
+
+	if err := m.createStackContainers(name, f); err != nil {
+		m.logEvent("redeploy-stack", fmt.Sprintf("name=%s username=%s error=%s%s", name, username, err, releaseText(release)), []string{"deploy", "stack"})
+		return nil, err
+	}
+
+	if err := m.smokeTestStack(name, f, d); err != nil {
+		err.(*SmokeTestError).Rollback = m.rollbackStack(name, f, previous)
+		m.logEvent("redeploy-stack", fmt.Sprintf("name=%s username=%s error=%s%s", name, username, err, releaseText(release)), []string{"deploy", "stack"})
+		return nil, err
+	}
+
+	stack.UpdatedBy = username
+	stack.UpdatedAt = time.Now()
+
+	if err := m.db.SaveStack(stack); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("redeploy-stack", fmt.Sprintf("name=%s username=%s%s", name, username, releaseText(release)), []string{"deploy", "stack"})
+
+	return stack, nil
+}
+
+// RemoveStack removes the containers of the stack and stops tracking it
+func (m DefaultManager) RemoveStack(name, username string) error {
+	unlock, err := m.lockStack(name, username, "remove", 0)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := m.db.Stack(name); err != nil {
+		return notFound(err, ErrStackDoesNotExist)
+	}
+
+	if err := m.removeStackContainers(name); err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteStack(name); err != nil {
+		return notFound(err, ErrStackDoesNotExist)
+	}
+
+	m.logEvent("remove-stack", fmt.Sprintf("name=%s username=%s", name, username), []string{"deploy", "stack"})
+
+	return nil
+}
+
+// checkStackFreeze checks the environments of every service for a freeze
+func (m DefaultManager) checkStackFreeze(f *compose.File, username, change string) error {
+	checked := map[string]bool{}
+	for _, svc := range f.Services {
+		environment := svc.Labels[notification.LabelEnvironment]
+		if checked[environment] {
+			continue
+		}
+		checked[environment] = true
+
+		if err := m.CheckFreeze(username, environment, change); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m DefaultManager) pullStackImages(name string, f *compose.File) error {
+	pulled := map[string]bool{}
+	for _, svc := range f.Services {
+		if pulled[svc.Image] {
+			continue
+		}
+		pulled[svc.Image] = true
+
+		log.Infof("stack: pulling %s", svc.Image)
+		if err := m.pullImage(DeployStack+":"+name, svc.Image); err != nil {
+			return fmt.Errorf("error pulling %s: %s", svc.Image, err)
+		}
+	}
+
+	return nil
+}
+
+func (m DefaultManager) createStackContainers(name string, f *compose.File) error {
+	order, err := f.Order()
+	if err != nil {
+		return &ComposeError{Err: err}
+	}
+
+	defaults, err := m.containerDefaults()
+	if err != nil {
+		return err
+	}
+
+	created := []string{}
+	rollback := func() {
+		for _, id := range created {
+			if err := m.DockerClient().RemoveContainer(id, true, false); err != nil {
+				log.Errorf("stack: error removing %s: %s", id, err)
+			}
+		}
+	}
+
+	for _, service := range order {
+		for n := 1; n <= f.Services[service].Replicas(); n++ {
+			config := f.ContainerConfig(name, service, n)
+			containerName := compose.ContainerName(name, service, n)
+			applyContainerDefaults(config, defaults)
+
+			if err := m.applyDrainConstraints(config); err != nil {
+				rollback()
+				return err
+			}
+
+			id, err := m.DockerClient().CreateContainer(config, containerName, nil)
+			if err != nil {
+				rollback()
+				return fmt.Errorf("error creating %s: %s", containerName, err)
+			}
+			created = append(created, id)
+
+			if err := m.DockerClient().StartContainer(id, &config.HostConfig); err != nil {
+				rollback()
+				return fmt.Errorf("error starting %s: %s", containerName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m DefaultManager) removeStackContainers(name string) error {
+	containers, err := m.stackContainers(name)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if err := m.DockerClient().RemoveContainer(c.Id, true, false); err != nil {
+			return fmt.Errorf("error removing %s: %s", c.Id, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/compose"
+)
+
+// stackTopWorkers is how many containers are listed at once
+const stackTopWorkers = 8
+
+type (
+	// StackProcess is a process of a container of the stack; Values
+	// follow the titles of the stack top
+	StackProcess struct {
+		Container string   `json:"container"`
+		Name      string   `json:"name"`
+		Service   string   `json:"service"`
+		Values    []string `json:"values"`
+	}
+
+	// StackTopError is a container whose processes could not be listed
+	StackTopError struct {
+		Container string `json:"container"`
+		Name      string `json:"name"`
+		Service   string `json:"service"`
+		Error     string `json:"error"`
+	}
+
+	// StackTop are the processes of the running containers of a stack;
+	// Titles are the columns of every engine listed, in order of first
+	// appearance, so engines with other ps columns line up
+	StackTop struct {
+		Stack      string           `json:"stack"`
+		Titles     []string         `json:"titles"`
+		Processes  []*StackProcess  `json:"processes"`
+		Containers int              `json:"containers"`
+		Errors     []*StackTopError `json:"errors"`
+	}
+
+	// containerTop is the response of the top endpoint of the engine
+	containerTop struct {
+		Titles    []string
+		Processes [][]string
+	}
+)
+
+// StackTop lists the processes of every running container of the stack
+// with the container and service they run in; psArgs are passed to ps on
+// the engines and containers which fail are reported in Errors
+func (m DefaultManager) StackTop(name, psArgs string) (*StackTop, error) {
+	if _, err := m.db.Stack(name); err != nil {
+		return nil, notFound(err, ErrStackDoesNotExist)
+	}
+
+	containers, err := m.stackContainers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	running := []dockerclient.Container{}
+	for _, c := range containers {
+		if strings.HasPrefix(c.Status, "Up") {
+			running = append(running, c)
+		}
+	}
+
+	// listed in the order of the containers whatever finishes first
+	tops := make([]*containerTop, len(running))
+	errs := make([]error, len(running))
+
+	idx := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < stackTopWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range idx {
+				tops[i], errs[i] = m.containerTop(running[i].Id, psArgs)
+			}
+		}()
+	}
+
+	for i := range running {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	top := &StackTop{
+		Stack:      name,
+		Titles:     []string{},
+		Processes:  []*StackProcess{},
+		Containers: len(running),
+		Errors:     []*StackTopError{},
+	}
+
+	columns := map[string]int{}
+	for i, c := range running {
+		cname := containerName(c)
+		service := c.Labels[compose.LabelService]
+
+		if errs[i] != nil {
+			top.Errors = append(top.Errors, &StackTopError{
+				Container: c.Id,
+				Name:      cname,
+				Service:   service,
+				Error:     errs[i].Error(),
+			})
+			continue
+		}
+
+		for _, t := range tops[i].Titles {
+			if _, ok := columns[t]; !ok {
+				columns[t] = len(top.Titles)
+				top.Titles = append(top.Titles, t)
+			}
+		}
+
+		for _, p := range tops[i].Processes {
+			values := make([]string, len(top.Titles))
+			for j, t := range tops[i].Titles {
+				if j < len(p) {
+					values[columns[t]] = p[j]
+				}
+			}
+
+			top.Processes = append(top.Processes, &StackProcess{
+				Container: c.Id,
+				Name:      cname,
+				Service:   service,
+				Values:    values,
+			})
+		}
+	}
+
+	// rows of containers listed before a new column was seen are padded
+	for _, p := range top.Processes {
+		for len(p.Values) < len(top.Titles) {
+			p.Values = append(p.Values, "")
+		}
+	}
+
+	sort.SliceStable(top.Processes, func(i, j int) bool {
+		a, b := top.Processes[i], top.Processes[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Name < b.Name
+	})
+
+	return top, nil
+}
+
+// containerTop lists the processes of the container on its engine
+func (m DefaultManager) containerTop(id, psArgs string) (*containerTop, error) {
+	client := m.DockerClient()
+
+	u := fmt.Sprintf("%s/containers/%s/top", client.URL.String(), id)
+	if psArgs != "" {
+		u += "?ps_args=" + url.QueryEscape(psArgs)
+	}
+
+	resp, err := client.HTTPClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	if resp.StatusCode != 200 {
+		// the engine explains invalid ps arguments in the body
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("error listing the processes of %s: %s %s", id, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	top := &containerTop{}
+	if err := json.NewDecoder(resp.Body).Decode(top); err != nil {
+		return nil, err
+	}
+
+	return top, nil
+}
@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+// getStackTopManager returns a manager with the stack shop on an engine
+// where db-1 lists other ps columns, web-2 cannot be listed and web-3 is
+// not running
+func getStackTopManager(t *testing.T) (DefaultManager, func()) {
+	containers := []dockerclient.Container{
+		{Id: "web-1", Names: []string{"/shop_web_1"}, Status: "Up 2 hours", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "web"}},
+		{Id: "db-1", Names: []string{"/shop_db_1"}, Status: "Up 2 hours", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "db"}},
+		{Id: "web-2", Names: []string{"/shop_web_2"}, Status: "Up 1 second", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "web"}},
+		{Id: "web-3", Names: []string{"/shop_web_3"}, Status: "Exited (0) 1 hour ago", Labels: map[string]string{compose.LabelProject: "shop", compose.LabelService: "web"}},
+	}
+
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			json.NewEncoder(w).Encode(containers)
+		case r.URL.Path == "/containers/web-1/top":
+			json.NewEncoder(w).Encode(containerTop{
+				Titles:    []string{"UID", "PID", "CMD"},
+				Processes: [][]string{{"root", "10", "nginx: master process"}, {"nginx", "11", "nginx: worker process"}},
+			})
+		case r.URL.Path == "/containers/db-1/top":
+			if r.URL.Query().Get("ps_args") != "aux" {
+				t.Errorf("expected the ps args to be passed; received %s", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(containerTop{
+				Titles:    []string{"USER", "PID", "CMD"},
+				Processes: [][]string{{"postgres", "20", "postgres"}},
+			})
+		case r.URL.Path == "/containers/web-2/top":
+			http.Error(w, "container web-2 is restarting", http.StatusConflict)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	m, closeStore := newTestManager(t)
+
+	if err := m.db.SaveStack(&shipyard.Stack{Name: "shop", Services: []string{"db", "web"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		engine.Close()
+		closeStore()
+	}
+
+	m.client = &clusterClient{client: client}
+
+	return m, cleanup
+}
+
+func TestStackTop(t *testing.T) {
+	m, cleanup := getStackTopManager(t)
+	defer cleanup()
+
+	top, err := m.StackTop("shop", "aux")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if top.Containers != 3 {
+		t.Fatalf("expected the 3 running containers; received %d", top.Containers)
+	}
+
+	if strings.Join(top.Titles, ",") != "UID,PID,CMD,USER" {
+		t.Fatalf("expected the columns of both engines; received %v", top.Titles)
+	}
+
+	if len(top.Processes) != 3 || top.Processes[0].Service != "db" || top.Processes[0].Name != "shop_db_1" {
+		t.Fatalf("expected the processes sorted by service; received %+v", top.Processes)
+	}
+
+	for _, p := range top.Processes {
+		if len(p.Values) != len(top.Titles) {
+			t.Fatalf("expected a value per column; received %v", p.Values)
+		}
+	}
+
+	if len(top.Errors) != 1 || top.Errors[0].Container != "web-2" || !strings.Contains(top.Errors[0].Error, "restarting") {
+		t.Fatalf("expected web-2 to fail with the engine error; received %+v", top.Errors)
+	}
+
+	if _, err := m.StackTop("unknown", ""); err != ErrStackDoesNotExist {
+		t.Fatalf("expected ErrStackDoesNotExist; received %v", err)
+	}
+}
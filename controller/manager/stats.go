@@ -0,0 +1,284 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+)
+
+const (
+	statsInterval  = 30 * time.Second
+	statsRetention = 24 * time.Hour
+	// statsWorkers is how many containers are sampled at once; engines
+	// take about a second to sample a container
+	statsWorkers = 8
+)
+
+var (
+	ErrInvalidStatsPeriod = fmt.Errorf("the period has to be positive and at most %s", statsRetention)
+	errStatsUnavailable   = errors.New("the engine returned no stats")
+)
+
+type (
+	// statsSample keeps the cpu counters of a sample to compute the usage
+	// between samples
+	statsSample struct {
+		stats       *shipyard.ContainerStats
+		cpuTotal    uint64
+		systemTotal uint64
+	}
+
+	// statsHistory keeps the samples of the containers for the retention
+	statsHistory struct {
+		mu      sync.RWMutex
+		samples map[string][]*statsSample
+	}
+
+	cpuStats struct {
+		CpuUsage struct {
+			TotalUsage  uint64   `json:"total_usage"`
+			PercpuUsage []uint64 `json:"percpu_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs  int    `json:"online_cpus"`
+	}
+
+	// engineStats is a stats sample of the engine; engines before API 1.21
+	// report a single network
+	engineStats struct {
+		Read        time.Time                             `json:"read"`
+		CpuStats    cpuStats                              `json:"cpu_stats"`
+		PreCpuStats cpuStats                              `json:"precpu_stats"`
+		MemoryStats dockerclient.MemoryStats              `json:"memory_stats"`
+		Network     *dockerclient.NetworkStats            `json:"network"`
+		Networks    map[string]*dockerclient.NetworkStats `json:"networks"`
+	}
+)
+
+func newStatsHistory() *statsHistory {
+	return &statsHistory{
+		samples: map[string][]*statsSample{},
+	}
+}
+
+func (h *statsHistory) last(id string) *statsSample {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[id]
+	if len(samples) == 0 {
+		return nil
+	}
+
+	return samples[len(samples)-1]
+}
+
+func (h *statsHistory) add(id string, sample *statsSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[id] = append(h.samples[id], sample)
+}
+
+// since returns the samples of the container after the time
+func (h *statsHistory) since(id string, t time.Time) []*shipyard.ContainerStats {
+	stats := []*shipyard.ContainerStats{}
+	if h == nil {
+		return stats
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, sample := range h.samples[id] {
+		if sample.stats.Time.After(t) {
+			stats = append(stats, sample.stats)
+		}
+	}
+
+	return stats
+}
+
+// prune drops the samples before the time; containers without samples
+// left are forgotten
+func (h *statsHistory) prune(before time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, samples := range h.samples {
+		i := 0
+		for i < len(samples) && !samples[i].stats.Time.After(before) {
+			i++
+		}
+
+		if i == len(samples) {
+			delete(h.samples, id)
+			continue
+		}
+
+		h.samples[id] = samples[i:]
+	}
+}
+
+// sample converts the stats of the engine to a sample at the time of the
+// controller, as the clocks of engines can drift; the cpu usage is computed
+// since the previous sample or, for the first one, since the previous read
+// of the engine
+func (s *engineStats) sample(t time.Time, previous *statsSample) *statsSample {
+	sample := &statsSample{
+		stats: &shipyard.ContainerStats{
+			Time:        t,
+			MemoryLimit: s.MemoryStats.Limit,
+		},
+		cpuTotal:    s.CpuStats.CpuUsage.TotalUsage,
+		systemTotal: s.CpuStats.SystemUsage,
+	}
+
+	// the page cache can be reclaimed so it is not counted as usage
+	sample.stats.MemoryUsage = s.MemoryStats.Usage
+	if cache := s.MemoryStats.Stats["cache"]; cache < sample.stats.MemoryUsage {
+		sample.stats.MemoryUsage -= cache
+	}
+
+	if s.Network != nil {
+		sample.stats.NetworkRxBytes = s.Network.RxBytes
+		sample.stats.NetworkTxBytes = s.Network.TxBytes
+	}
+	for _, n := range s.Networks {
+		sample.stats.NetworkRxBytes += n.RxBytes
+		sample.stats.NetworkTxBytes += n.TxBytes
+	}
+
+	cpuBefore, systemBefore := s.PreCpuStats.CpuUsage.TotalUsage, s.PreCpuStats.SystemUsage
+	if previous != nil {
+		cpuBefore, systemBefore = previous.cpuTotal, previous.systemTotal
+	}
+
+	cpus := s.CpuStats.OnlineCPUs
+	if cpus == 0 {
+		cpus = len(s.CpuStats.CpuUsage.PercpuUsage)
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+
+	// counters are reset when the container restarts
+	if systemBefore > 0 && sample.systemTotal > systemBefore && sample.cpuTotal >= cpuBefore {
+		cpuDelta := float64(sample.cpuTotal - cpuBefore)
+		systemDelta := float64(sample.systemTotal - systemBefore)
+		sample.stats.CPUPercent = cpuDelta / systemDelta * float64(cpus) * 100
+	}
+
+	return sample
+}
+
+// statsCollector samples the running containers until the controller stops
+func (m DefaultManager) statsCollector() {
+	t := time.NewTicker(statsInterval).C
+	for {
+		if err := m.CollectStats(); err != nil {
+			log.Errorf("error collecting container stats: %s", err)
+		}
+
+		<-t
+	}
+}
+
+// CollectStats samples the resource usage of every running container and
+// drops the samples older than the retention
+func (m DefaultManager) CollectStats() error {
+	containers, err := m.DockerClient().ListContainers(false, false, "")
+	if err != nil {
+		return err
+	}
+
+	ids := make(chan string)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < statsWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for id := range ids {
+				stats, err := m.engineStats(id)
+				if err != nil {
+					log.Debugf("error collecting stats of %s: %s", id, err)
+					continue
+				}
+
+				m.stats.add(id, stats.sample(time.Now(), m.stats.last(id)))
+			}
+		}()
+	}
+
+	for _, c := range containers {
+		ids <- c.Id
+	}
+	close(ids)
+	wg.Wait()
+
+	m.stats.prune(time.Now().Add(-statsRetention))
+
+	return nil
+}
+
+// engineStats reads a single stats sample of the container
+func (m DefaultManager) engineStats(id string) (*engineStats, error) {
+	client := m.DockerClient()
+	resp, err := client.HTTPClient.Get(fmt.Sprintf("%s/containers/%s/stats?stream=false", client.URL.String(), id))
+	if err != nil {
+		return nil, err
+	}
+	// engines without stream keep sending samples; only the first one is
+	// read
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error getting stats of %s: %s", id, resp.Status)
+	}
+
+	stats := &engineStats{}
+	if err := json.NewDecoder(resp.Body).Decode(stats); err != nil {
+		return nil, err
+	}
+
+	if stats.Read.IsZero() {
+		return nil, errStatsUnavailable
+	}
+
+	return stats, nil
+}
+
+// ContainerStats returns the samples of the container over the period;
+// containers are sampled every 30 seconds and samples are kept for a day
+func (m DefaultManager) ContainerStats(id string, period time.Duration) (*shipyard.ContainerStatsHistory, error) {
+	if period <= 0 || period > statsRetention {
+		return nil, ErrInvalidStatsPeriod
+	}
+
+	info, err := m.Container(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shipyard.ContainerStatsHistory{
+		ContainerId: info.Id,
+		Period:      period.String(),
+		Interval:    statsInterval.String(),
+		Samples:     m.stats.since(info.Id, time.Now().Add(-period)),
+	}, nil
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+func TestEngineStatsSample(t *testing.T) {
+	stats := &engineStats{}
+	stats.CpuStats.CpuUsage.TotalUsage = 300
+	stats.CpuStats.CpuUsage.PercpuUsage = []uint64{150, 150}
+	stats.CpuStats.SystemUsage = 2000
+	stats.PreCpuStats.CpuUsage.TotalUsage = 100
+	stats.PreCpuStats.SystemUsage = 1000
+	stats.MemoryStats = dockerclient.MemoryStats{Usage: 100, Limit: 1000, Stats: map[string]uint64{"cache": 40}}
+	stats.Networks = map[string]*dockerclient.NetworkStats{
+		"eth0": {RxBytes: 10, TxBytes: 20},
+		"eth1": {RxBytes: 1, TxBytes: 2},
+	}
+
+	now := time.Now()
+	first := stats.sample(now, nil)
+
+	// 200 of 1000 system ticks on 2 cpus
+	if first.stats.CPUPercent != 40 {
+		t.Fatalf("expected 40%% cpu; received %v", first.stats.CPUPercent)
+	}
+
+	if first.stats.MemoryUsage != 60 || first.stats.NetworkRxBytes != 11 || first.stats.NetworkTxBytes != 22 {
+		t.Fatalf("unexpected sample %+v", first.stats)
+	}
+
+	// later samples are computed since the previous sample
+	stats.CpuStats.CpuUsage.TotalUsage = 350
+	stats.CpuStats.SystemUsage = 3000
+	second := stats.sample(now.Add(statsInterval), first)
+
+	if second.stats.CPUPercent != 10 {
+		t.Fatalf("expected 10%% cpu; received %v", second.stats.CPUPercent)
+	}
+
+	// counters restart with the container
+	stats.CpuStats.CpuUsage.TotalUsage = 10
+	if restarted := stats.sample(now, second); restarted.stats.CPUPercent != 0 {
+		t.Fatalf("expected no cpu usage after a restart; received %v", restarted.stats.CPUPercent)
+	}
+}
+
+func TestStatsHistory(t *testing.T) {
+	h := newStatsHistory()
+	now := time.Now()
+
+	stats := &engineStats{}
+	for _, age := range []time.Duration{3 * time.Hour, 90 * time.Minute, 10 * time.Minute} {
+		h.add("abc", stats.sample(now.Add(-age), h.last("abc")))
+	}
+	h.add("old", stats.sample(now.Add(-2*statsRetention), nil))
+
+	if samples := h.since("abc", now.Add(-time.Hour)); len(samples) != 1 {
+		t.Fatalf("expected 1 sample in the last hour; received %d", len(samples))
+	}
+
+	h.prune(now.Add(-2 * time.Hour))
+
+	if samples := h.since("abc", now.Add(-statsRetention)); len(samples) != 2 {
+		t.Fatalf("expected 2 samples after pruning; received %d", len(samples))
+	}
+
+	if h.last("old") != nil {
+		t.Fatal("expected containers without samples to be forgotten")
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"abc"},{"Id":"gone"}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/stats"):
+			w.Write([]byte(`{"read":"2016-01-02T15:04:05Z","memory_stats":{"usage":2048,"limit":4096},"network":{"rx_bytes":5,"tx_bytes":7}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			w.Write([]byte(`{"Id":"abc"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := dockerclient.NewDockerClient(ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultManager{client: &clusterClient{client: client}, stats: newStatsHistory()}
+
+	if err := m.CollectStats(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := m.ContainerStats("abc", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history.Samples) != 1 || history.Samples[0].MemoryUsage != 2048 || history.Samples[0].NetworkTxBytes != 7 {
+		t.Fatalf("unexpected history %+v", history)
+	}
+
+	if m.stats.last("gone") != nil {
+		t.Fatal("expected no samples of a container without stats")
+	}
+
+	if _, err := m.ContainerStats("abc", 2*statsRetention); err != ErrInvalidStatsPeriod {
+		t.Fatalf("expected %s; received %v", ErrInvalidStatsPeriod, err)
+	}
+
+	if _, err := m.ContainerStats("gone", time.Hour); err != dockerclient.ErrNotFound {
+		t.Fatalf("expected %s; received %v", dockerclient.ErrNotFound, err)
+	}
+}
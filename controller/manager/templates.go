@@ -0,0 +1,330 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+	"github.com/shipyard/shipyard/controller/datastore"
+)
+
+const (
+	tblNameTemplates = "templates"
+)
+
+var (
+	ErrTemplateDoesNotExist = errors.New("template does not exist")
+	ErrTemplateExists       = errors.New("a template with the name already exists")
+
+	templateParameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	templatePlaceholder   = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// TemplateError is returned for invalid bundles and parameter values
+type TemplateError struct {
+	Reason string
+}
+
+func (e *TemplateError) Error() string {
+	return e.Reason
+}
+
+func templateErrorf(format string, args ...interface{}) error {
+	return &TemplateError{Reason: fmt.Sprintf(format, args...)}
+}
+
+func (m DefaultManager) Templates() ([]*shipyard.Template, error) {
+	return m.db.Templates()
+}
+
+func (m DefaultManager) Template(name string) (*shipyard.Template, error) {
+	template, err := m.db.Template(name)
+	if err != nil {
+		return nil, notFound(err, ErrTemplateDoesNotExist)
+	}
+
+	return template, nil
+}
+
+// ExportTemplate returns the template as a bundle for another install
+func (m DefaultManager) ExportTemplate(name string) (*shipyard.TemplateBundle, error) {
+	template, err := m.Template(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.Bundle(), nil
+}
+
+// ExportStack returns the compose file of a stack as a bundle without
+// parameters
+func (m DefaultManager) ExportStack(name string) (*shipyard.TemplateBundle, error) {
+	stack, err := m.db.Stack(name)
+	if err != nil {
+		return nil, notFound(err, ErrStackDoesNotExist)
+	}
+
+	template := &shipyard.Template{
+		Name:    stack.Name,
+		Author:  stack.CreatedBy,
+		Compose: stack.Compose,
+	}
+
+	return template.Bundle(), nil
+}
+
+// validateBundle checks the format version, the name and the parameters
+// of a bundle; the compose file is parsed when every parameter has a
+// default
+func validateBundle(bundle *shipyard.TemplateBundle) error {
+	if bundle.Version != shipyard.TemplateBundleVersion {
+		return templateErrorf("unsupported bundle version %d; expected %d", bundle.Version, shipyard.TemplateBundleVersion)
+	}
+
+	if !compose.ValidName(bundle.Metadata.Name) {
+		return templateErrorf("invalid template name %q: %s", bundle.Metadata.Name, compose.ErrInvalidName)
+	}
+
+	if strings.TrimSpace(bundle.Compose) == "" {
+		return templateErrorf("a template needs a compose file")
+	}
+
+	seen := map[string]bool{}
+	complete := true
+	for _, p := range bundle.Parameters {
+		if !templateParameterName.MatchString(p.Name) {
+			return templateErrorf("invalid parameter name %q", p.Name)
+		}
+
+		if seen[p.Name] {
+			return templateErrorf("parameter %s is defined twice", p.Name)
+		}
+		seen[p.Name] = true
+
+		if err := validateParameter(p); err != nil {
+			return err
+		}
+
+		if p.Default == "" {
+			complete = false
+		}
+	}
+
+	// the file can only be checked once all values are known
+	if !complete {
+		return nil
+	}
+
+	data, err := renderTemplate(bundle.Template(), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := compose.Parse(data); err != nil {
+		return templateErrorf("invalid compose file: %s", err)
+	}
+
+	return nil
+}
+
+// validateParameter checks the type, options and pattern of the parameter
+// and that its default is a valid value
+func validateParameter(p shipyard.TemplateParameter) error {
+	switch p.Type {
+	case "", shipyard.TemplateParameterString, shipyard.TemplateParameterInt:
+		if len(p.Options) > 0 {
+			return templateErrorf("parameter %s: only enums have options", p.Name)
+		}
+	case shipyard.TemplateParameterEnum:
+		if len(p.Options) == 0 {
+			return templateErrorf("parameter %s: an enum needs options", p.Name)
+		}
+	default:
+		return templateErrorf("parameter %s: unknown type %q", p.Name, p.Type)
+	}
+
+	if _, err := parameterPattern(p); err != nil {
+		return templateErrorf("parameter %s: invalid pattern: %s", p.Name, err)
+	}
+
+	if p.Default != "" {
+		if err := checkParameterValue(p, p.Default); err != nil {
+			return templateErrorf("parameter %s: invalid default: %s", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// parameterPattern compiles the pattern of the parameter to match whole
+// values; parameters without a pattern return nil
+func parameterPattern(p shipyard.TemplateParameter) (*regexp.Regexp, error) {
+	if p.Pattern == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + p.Pattern + ")$")
+}
+
+// checkParameterValue checks the value against the type, the options and
+// the pattern of the parameter
+func checkParameterValue(p shipyard.TemplateParameter, v string) error {
+	// values cannot add lines to the compose file
+	if strings.ContainsAny(v, "\r\n") {
+		return fmt.Errorf("values cannot span lines")
+	}
+
+	switch p.Type {
+	case shipyard.TemplateParameterInt:
+		if _, err := strconv.Atoi(v); err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+	case shipyard.TemplateParameterEnum:
+		found := false
+		for _, o := range p.Options {
+			if o == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%q is not one of %s", v, strings.Join(p.Options, ", "))
+		}
+	}
+
+	pattern, err := parameterPattern(p)
+	if err != nil {
+		return err
+	}
+
+	if pattern != nil && !pattern.MatchString(v) {
+		return fmt.Errorf("%q does not match %s", v, p.Pattern)
+	}
+
+	return nil
+}
+
+// ImportTemplate saves the template of a bundle; an existing template is
+// only replaced when replace is set
+func (m DefaultManager) ImportTemplate(bundle *shipyard.TemplateBundle, username string, replace bool) (*shipyard.Template, error) {
+	if err := validateBundle(bundle); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.db.Template(bundle.Metadata.Name); err == nil && !replace {
+		return nil, ErrTemplateExists
+	} else if err != nil && err != datastore.ErrNotFound {
+		return nil, err
+	}
+
+	template := bundle.Template()
+	template.ImportedBy = username
+	template.ImportedAt = time.Now()
+
+	if err := m.db.SaveTemplate(template); err != nil {
+		return nil, err
+	}
+
+	m.logEvent("import-template", fmt.Sprintf("name=%s author=%q username=%s", template.Name, template.Author, username), []string{"templates"})
+
+	return template, nil
+}
+
+func (m DefaultManager) DeleteTemplate(name string) error {
+	if err := m.db.DeleteTemplate(name); err != nil {
+		return notFound(err, ErrTemplateDoesNotExist)
+	}
+
+	m.logEvent("delete-template", fmt.Sprintf("name=%s", name), []string{"templates"})
+
+	return nil
+}
+
+// renderTemplate fills the placeholders of the parameters of the template
+// in its compose file; placeholders of undeclared names are kept for the
+// environment of the containers
+func renderTemplate(template *shipyard.Template, values map[string]string) ([]byte, error) {
+	declared := map[string]bool{}
+	resolved := map[string]string{}
+	for _, p := range template.Parameters {
+		declared[p.Name] = true
+
+		v, ok := values[p.Name]
+		if !ok || v == "" {
+			v = p.Default
+		}
+
+		if v == "" && p.Required {
+			return nil, templateErrorf("parameter %s is required", p.Name)
+		}
+
+		// optional parameters can be left empty
+		if v != "" {
+			if err := checkParameterValue(p, v); err != nil {
+				return nil, templateErrorf("parameter %s: %s", p.Name, err)
+			}
+		}
+
+		resolved[p.Name] = v
+	}
+
+	unknown := []string{}
+	for name := range values {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, templateErrorf("unknown parameters: %s", strings.Join(unknown, ", "))
+	}
+
+	rendered := templatePlaceholder.ReplaceAllStringFunc(template.Compose, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		if v, ok := resolved[name]; ok {
+			return v
+		}
+
+		return placeholder
+	})
+
+	return []byte(rendered), nil
+}
+
+// DeployTemplate deploys the template with the parameter values as a
+// stack; with a ttl the stack and its containers are removed once it
+// expires, zero keeps them
+func (m DefaultManager) DeployTemplate(name, stackName string, values map[string]string, ttl time.Duration, username string) (*shipyard.Stack, error) {
+	if err := validTTL(ttl); err != nil {
+		return nil, err
+	}
+
+	template, err := m.Template(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := renderTemplate(template, values)
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err := m.DeployStack(stackName, data, username, nil)
+	if err != nil || ttl == 0 {
+		return stack, err
+	}
+
+	stack.ExpiresAt = stack.CreatedAt.Add(ttl)
+	if err := m.db.SaveStack(stack); err != nil {
+		return nil, err
+	}
+
+	return stack, nil
+}
@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/shipyard/shipyard"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	template := &shipyard.Template{
+		Parameters: []shipyard.TemplateParameter{
+			{Name: "PORT", Default: "8080"},
+			{Name: "IMAGE", Required: true},
+		},
+		Compose: "services:\n  web:\n    image: ${IMAGE}\n    ports: ['${PORT}:80']\n    environment: [HOME=${HOME}]\n",
+	}
+
+	data, err := renderTemplate(template, map[string]string{"IMAGE": "nginx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "services:\n  web:\n    image: nginx\n    ports: ['8080:80']\n    environment: [HOME=${HOME}]\n"
+	if string(data) != expected {
+		t.Fatalf("expected declared parameters to be filled in; received %q", data)
+	}
+
+	for _, values := range []map[string]string{
+		{},
+		{"IMAGE": "nginx\n    privileged: true"},
+		{"IMAGE": "nginx", "HOME": "/root"},
+	} {
+		if _, err := renderTemplate(template, values); err == nil {
+			t.Fatalf("expected %v to be refused", values)
+		} else if _, ok := err.(*TemplateError); !ok {
+			t.Fatalf("expected a template error; received %v", err)
+		}
+	}
+}
+
+func TestTemplateParameterValues(t *testing.T) {
+	template := &shipyard.Template{
+		Parameters: []shipyard.TemplateParameter{
+			{Name: "PORT", Type: shipyard.TemplateParameterInt, Default: "8080"},
+			{Name: "SIZE", Type: shipyard.TemplateParameterEnum, Options: []string{"small", "large"}, Required: true},
+			{Name: "VERSION", Pattern: `[0-9]+\.[0-9]+`},
+		},
+		Compose: "services:\n  web:\n    image: app:${VERSION}\n    ports: ['${PORT}:80']\n    environment: [SIZE=${SIZE}]\n",
+	}
+
+	data, err := renderTemplate(template, map[string]string{"SIZE": "large", "VERSION": "1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "services:\n  web:\n    image: app:1.2\n    ports: ['8080:80']\n    environment: [SIZE=large]\n"
+	if string(data) != expected {
+		t.Fatalf("expected %q; received %q", expected, data)
+	}
+
+	for _, values := range []map[string]string{
+		{"SIZE": "large", "PORT": "http"},
+		{"SIZE": "medium"},
+		{"SIZE": "small", "VERSION": "1.2-beta"},
+		{"SIZE": "small", "VERSION": "x1.2"},
+	} {
+		if _, err := renderTemplate(template, values); err == nil {
+			t.Errorf("expected %v to be refused", values)
+		} else if _, ok := err.(*TemplateError); !ok {
+			t.Errorf("expected a template error; received %v", err)
+		}
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	valid := func() *shipyard.TemplateBundle {
+		return &shipyard.TemplateBundle{
+			Version:    shipyard.TemplateBundleVersion,
+			Metadata:   shipyard.TemplateMetadata{Name: "web"},
+			Parameters: []shipyard.TemplateParameter{{Name: "PORT", Default: "8080"}},
+			Compose:    "services:\n  web:\n    image: nginx\n    ports: ['${PORT}:80']\n",
+		}
+	}
+
+	if err := validateBundle(valid()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []func(b *shipyard.TemplateBundle){
+		func(b *shipyard.TemplateBundle) { b.Version = 2 },
+		func(b *shipyard.TemplateBundle) { b.Metadata.Name = "Web App" },
+		func(b *shipyard.TemplateBundle) { b.Compose = "" },
+		func(b *shipyard.TemplateBundle) { b.Parameters = append(b.Parameters, b.Parameters[0]) },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Name = "port-number" },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Default = "http" },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Type = "float" },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Type = shipyard.TemplateParameterEnum },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Options = []string{"8080"} },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Pattern = "[0-9" },
+		func(b *shipyard.TemplateBundle) { b.Parameters[0].Pattern = "[0-9]{2}" },
+	}
+
+	for i, change := range tests {
+		b := valid()
+		change(b)
+
+		if err := validateBundle(b); err == nil {
+			t.Errorf("test %d: expected the bundle to be refused", i)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	// tokenTouchInterval limits how often the last used time of a token
+	// is written as every api request verifies the token
+	tokenTouchInterval = time.Minute
+)
+
+// tokenExpiry returns the expiry of a token created at the time
+func (m DefaultManager) tokenExpiry(created time.Time) time.Time {
+	if m.tokenTTL <= 0 {
+		return time.Time{}
+	}
+
+	return created.Add(m.tokenTTL)
+}
+
+// tokenExpired reports whether the token has expired; the current ttl also
+// applies to tokens issued before it was lowered or enabled
+func (m DefaultManager) tokenExpired(token *auth.AuthToken, now time.Time) bool {
+	expiry := token.ExpiresAt
+	if e := m.tokenExpiry(token.CreatedAt); !e.IsZero() && (expiry.IsZero() || e.Before(expiry)) {
+		expiry = e
+	}
+
+	return !expiry.IsZero() && now.After(expiry)
+}
+
+// touchAuthToken records the token as used
+func (m DefaultManager) touchAuthToken(username string, token *auth.AuthToken) {
+	now := time.Now()
+	if now.Sub(token.LastUsed) < tokenTouchInterval {
+		return
+	}
+
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		for _, t := range acct.Tokens {
+			if t.Token == token.Token {
+				t.LastUsed = now
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Warnf("unable to update token usage: username=%s err=%s", username, err)
+	}
+}
+
+// AuthTokens returns the active tokens of the account; the tokens
+// themselves are omitted and identified by id
+func (m DefaultManager) AuthTokens(username string) ([]*auth.AuthToken, error) {
+	acct, err := m.Account(username)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []*auth.AuthToken{}
+	for _, t := range acct.Tokens {
+		tokens = append(tokens, &auth.AuthToken{
+			ID:        auth.TokenID(t.Token),
+			UserAgent: t.UserAgent,
+			CreatedAt: t.CreatedAt,
+			LastUsed:  t.LastUsed,
+			ExpiresAt: t.ExpiresAt,
+		})
+	}
+
+	return tokens, nil
+}
+
+// RefreshAuthToken exchanges a valid token for a new one with a new
+// expiry; the old token stops working
+func (m DefaultManager) RefreshAuthToken(username, token string) (*auth.AuthToken, error) {
+	if err := m.VerifyAuthToken(username, token); err != nil {
+		return nil, err
+	}
+
+	tk, err := m.authenticator.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	var refreshed *auth.AuthToken
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		for _, t := range acct.Tokens {
+			if t.Token == token {
+				t.Token = tk
+				t.CreatedAt = now
+				t.LastUsed = now
+				t.ExpiresAt = m.tokenExpiry(now)
+				refreshed = t
+				return nil
+			}
+		}
+
+		// a concurrent refresh or revocation replaced the token
+		return ErrInvalidAuthToken
+	}); err != nil {
+		return nil, err
+	}
+
+	return refreshed, nil
+}
+
+// RevokeAuthToken removes a single token of the account
+func (m DefaultManager) RevokeAuthToken(username, id string) error {
+	acct, err := m.Account(username)
+	if err != nil {
+		return err
+	}
+
+	var token *auth.AuthToken
+	for _, t := range acct.Tokens {
+		if auth.TokenID(t.Token) == id {
+			token = t
+			break
+		}
+	}
+
+	if token == nil {
+		return ErrAuthTokenDoesNotExist
+	}
+
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		tokens := []*auth.AuthToken{}
+		for _, t := range acct.Tokens {
+			if t.Token != token.Token {
+				tokens = append(tokens, t)
+			}
+		}
+		acct.Tokens = tokens
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.logEvent("revoke-token", fmt.Sprintf("username=%s token=%s user_agent=%q", username, id, token.UserAgent), []string{"security"})
+
+	return nil
+}
+
+// RevokeAuthTokens removes every token of the account, ending all of its
+// sessions
+func (m DefaultManager) RevokeAuthTokens(username string) error {
+	if err := m.updateAccount(username, func(acct *auth.Account) error {
+		acct.Tokens = []*auth.AuthToken{}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.logEvent("revoke-tokens", fmt.Sprintf("username=%s", username), []string{"security"})
+
+	return nil
+}
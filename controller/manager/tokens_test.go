@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestTokenExpired(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		ttl     time.Duration
+		token   *auth.AuthToken
+		expired bool
+	}{
+		// no ttl; tokens never expire
+		{0, &auth.AuthToken{CreatedAt: now.Add(-24 * time.Hour)}, false},
+		{time.Hour, &auth.AuthToken{CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(59 * time.Minute)}, false},
+		{time.Hour, &auth.AuthToken{CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}, true},
+		// tokens issued before a ttl was set
+		{time.Hour, &auth.AuthToken{}, true},
+		// a lowered ttl applies to existing tokens
+		{time.Hour, &auth.AuthToken{CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(time.Hour)}, true},
+		// a stored expiry still applies when the ttl is removed
+		{0, &auth.AuthToken{CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}, true},
+	}
+
+	for i, test := range tests {
+		m := DefaultManager{tokenTTL: test.ttl}
+		if expired := m.tokenExpired(test.token, now); expired != test.expired {
+			t.Fatalf("test %d: expected expired=%v; received %v", i, test.expired, expired)
+		}
+	}
+}
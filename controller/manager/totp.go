@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+const (
+	totpIssuer = "Shipyard"
+)
+
+var (
+	ErrTOTPRequired    = errors.New("a two-factor code is required")
+	ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication has not been enrolled")
+	ErrTOTPEnabled     = errors.New("two-factor authentication is already enabled")
+)
+
+// TOTPEnrollment is the secret of an authenticator app; the uri is shown
+// as a QR code
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnrollTOTP generates a new secret for the account; it is only required
+// at login once confirmed with a code
+func (m DefaultManager) EnrollTOTP(username string) (*TOTPEnrollment, error) {
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		if a.TOTPEnabled {
+			return ErrTOTPEnabled
+		}
+
+		a.TOTPSecret = secret
+		a.TOTPLastStep = 0
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPProvisioningURI(totpIssuer, username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the authenticator
+// app returns a valid code for the enrolled secret
+func (m DefaultManager) ConfirmTOTP(username, code string) error {
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		if a.TOTPEnabled {
+			return ErrTOTPEnabled
+		}
+
+		if a.TOTPSecret == "" {
+			return ErrTOTPNotEnrolled
+		}
+
+		step, ok := auth.ValidateTOTP(a.TOTPSecret, code, time.Now(), a.TOTPLastStep)
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+
+		a.TOTPEnabled = true
+		a.TOTPLastStep = step
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.logEvent("enable-2fa", fmt.Sprintf("username=%s", username), []string{"security"})
+
+	return nil
+}
+
+// DisableTOTP removes the secret of the account
+func (m DefaultManager) DisableTOTP(username, actor string) error {
+	if err := m.updateAccount(username, func(a *auth.Account) error {
+		a.TOTPSecret = ""
+		a.TOTPEnabled = false
+		a.TOTPLastStep = 0
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.logEvent("disable-2fa", fmt.Sprintf("username=%s by=%s", username, actor), []string{"security"})
+
+	return nil
+}
+
+// CheckTOTP checks the code of an account with two-factor authentication
+// enabled; accounts without it pass. A code can only be used once.
+func (m DefaultManager) CheckTOTP(username, code string) error {
+	acct, err := m.Account(username)
+	if err != nil {
+		return err
+	}
+
+	if !acct.TOTPEnabled {
+		return nil
+	}
+
+	if code == "" {
+		return ErrTOTPRequired
+	}
+
+	return m.updateAccount(username, func(a *auth.Account) error {
+		step, ok := auth.ValidateTOTP(a.TOTPSecret, code, time.Now(), a.TOTPLastStep)
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+
+		a.TOTPLastStep = step
+		return nil
+	})
+}
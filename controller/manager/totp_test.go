@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shipyard/shipyard/auth"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	if err := m.db.CreateAccount(&auth.Account{Username: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment, err := m.EnrollTOTP("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the secret is only required once confirmed
+	if err := m.CheckTOTP("admin", ""); err != nil {
+		t.Fatalf("expected no code to be required before confirming; received %v", err)
+	}
+
+	if err := m.ConfirmTOTP("admin", "000000"); err != ErrInvalidTOTPCode {
+		t.Fatalf("expected ErrInvalidTOTPCode; received %v", err)
+	}
+
+	now := auth.TOTPStep(time.Now())
+	code, err := auth.TOTPCode(enrollment.Secret, now-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ConfirmTOTP("admin", code); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CheckTOTP("admin", ""); err != ErrTOTPRequired {
+		t.Fatalf("expected ErrTOTPRequired; received %v", err)
+	}
+
+	// the code used to confirm cannot be used again
+	if err := m.CheckTOTP("admin", code); err != ErrInvalidTOTPCode {
+		t.Fatalf("expected ErrInvalidTOTPCode for a used code; received %v", err)
+	}
+
+	code, err = auth.TOTPCode(enrollment.Secret, now+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CheckTOTP("admin", code); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.EnrollTOTP("admin"); err != ErrTOTPEnabled {
+		t.Fatalf("expected ErrTOTPEnabled; received %v", err)
+	}
+
+	if err := m.DisableTOTP("admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CheckTOTP("admin", ""); err != nil {
+		t.Fatalf("expected no code to be required once disabled; received %v", err)
+	}
+}
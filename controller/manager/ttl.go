@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// LabelTTL is how long a container lives (i.e. 2h) after it is
+	// created; it is then stopped and removed
+	LabelTTL = "com.shipyard.ttl"
+	// MaxTTL is the longest ttl of containers and template runs
+	MaxTTL = 7 * 24 * time.Hour
+
+	// ttlReapInterval is how often expired containers and stacks are
+	// removed
+	ttlReapInterval = time.Minute
+	// ttlStopTimeout is the seconds expired containers get to stop
+	// before they are killed
+	ttlStopTimeout = 10
+)
+
+var (
+	ErrInvalidTTL = fmt.Errorf("ttls are durations (i.e. 2h) of at most %s", MaxTTL)
+)
+
+// ParseTTL parses the ttl of a container or template run
+func ParseTTL(s string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, ErrInvalidTTL
+	}
+
+	if err := validTTL(ttl); err != nil || ttl == 0 {
+		return 0, ErrInvalidTTL
+	}
+
+	return ttl, nil
+}
+
+// validTTL checks the ttl; zero never expires
+func validTTL(ttl time.Duration) error {
+	if ttl < 0 || ttl > MaxTTL {
+		return ErrInvalidTTL
+	}
+
+	return nil
+}
+
+// ttlReaper removes the expired containers and stacks
+func (m DefaultManager) ttlReaper() {
+	t := time.NewTicker(ttlReapInterval).C
+	for range t {
+		if !m.isLeader() {
+			continue
+		}
+
+		m.reapExpired(time.Now())
+	}
+}
+
+// reapExpired removes the stacks whose ttl expired and stops and removes
+// the containers whose ttl label expired; it returns what was removed.
+// Failures are retried on the next run.
+func (m DefaultManager) reapExpired(now time.Time) []string {
+	removed := []string{}
+
+	stacks, err := m.db.Stacks()
+	if err != nil {
+		log.Errorf("error listing stacks to expire: %s", err)
+	}
+
+	for _, stack := range stacks {
+		if stack.ExpiresAt.IsZero() || now.Before(stack.ExpiresAt) {
+			continue
+		}
+
+		if err := m.RemoveStack(stack.Name, ""); err != nil {
+			log.Warnf("error removing expired stack %s: %s", stack.Name, err)
+			continue
+		}
+
+		m.logEvent("expire-stack", fmt.Sprintf("name=%s created_by=%s expired_at=%s", stack.Name, stack.CreatedBy, stack.ExpiresAt.Format(time.RFC3339)), []string{"deploy", "stack"})
+		removed = append(removed, "stack/"+stack.Name)
+	}
+
+	filters := fmt.Sprintf(`{"label":["%s"]}`, LabelTTL)
+	containers, err := m.DockerClient().ListContainers(true, false, url.QueryEscape(filters))
+	if err != nil {
+		log.Errorf("error listing containers to expire: %s", err)
+		return removed
+	}
+
+	for _, c := range containers {
+		ttl, err := ParseTTL(c.Labels[LabelTTL])
+		if err != nil {
+			log.Warnf("container %s has an invalid ttl %q: %s", c.Id, c.Labels[LabelTTL], err)
+			continue
+		}
+
+		expires := time.Unix(c.Created, 0).Add(ttl)
+		if now.Before(expires) {
+			continue
+		}
+
+		if err := m.expireContainer(c.Id); err != nil {
+			log.Warnf("error removing expired container %s: %s", c.Id, err)
+			continue
+		}
+
+		name := c.Id
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+
+		m.logEvent("expire-container", fmt.Sprintf("id=%s name=%s image=%s ttl=%s", c.Id, name, c.Image, ttl), []string{"container"})
+		removed = append(removed, "container/"+c.Id)
+	}
+
+	return removed
+}
+
+// expireContainer stops the container, giving it time to exit, and
+// removes it with its anonymous volumes
+func (m DefaultManager) expireContainer(id string) error {
+	client := m.DockerClient()
+
+	// stopped containers cannot be stopped again; the removal fails
+	// itself when the container is gone
+	if err := client.StopContainer(id, ttlStopTimeout); err != nil {
+		log.Debugf("error stopping expired container %s: %s", id, err)
+	}
+
+	return client.RemoveContainer(id, true, true)
+}
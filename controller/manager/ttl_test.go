@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/compose"
+)
+
+func TestParseTTL(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"90m":  90 * time.Minute,
+		"168h": MaxTTL,
+		"169h": 0,
+		"0s":   0,
+		"-1h":  0,
+		"2":    0,
+	} {
+		ttl, err := ParseTTL(s)
+		if expected == 0 && err != ErrInvalidTTL {
+			t.Fatalf("expected ErrInvalidTTL for %s; received %v", s, err)
+		}
+
+		if ttl != expected {
+			t.Fatalf("expected %s for %s; received %s", expected, s, ttl)
+		}
+	}
+}
+
+func TestReapExpired(t *testing.T) {
+	now := time.Now()
+
+	var mu sync.Mutex
+	calls := []string{}
+
+	containers := []dockerclient.Container{
+		{Id: "expired", Created: now.Add(-2 * time.Hour).Unix(), Labels: map[string]string{LabelTTL: "1h"}},
+		{Id: "running", Created: now.Add(-30 * time.Minute).Unix(), Labels: map[string]string{LabelTTL: "1h"}},
+		{Id: "invalid", Created: now.Add(-2 * time.Hour).Unix(), Labels: map[string]string{LabelTTL: "soon"}},
+	}
+
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		parts := strings.Split(r.URL.Path, "/")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			// the containers of stacks are listed by their project
+			if strings.Contains(r.URL.Query().Get("filters"), compose.LabelProject) {
+				json.NewEncoder(w).Encode([]dockerclient.Container{})
+				return
+			}
+			json.NewEncoder(w).Encode(containers)
+		case strings.HasSuffix(r.URL.Path, "/stop"):
+			calls = append(calls, "stop "+parts[len(parts)-2])
+		case r.Method == "DELETE":
+			calls = append(calls, "remove "+parts[len(parts)-1])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	for _, stack := range []*shipyard.Stack{
+		{Name: "debug", ExpiresAt: now.Add(-time.Minute)},
+		{Name: "preview", ExpiresAt: now.Add(time.Hour)},
+		{Name: "shop"},
+	} {
+		if err := m.db.SaveStack(stack); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, err := dockerclient.NewDockerClient(engine.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+	m.stackLocks = newStackLocks()
+
+	removed := m.reapExpired(now)
+	sort.Strings(removed)
+	if !reflect.DeepEqual(removed, []string{"container/expired", "stack/debug"}) {
+		t.Fatalf("expected the expired stack and container to be removed; received %v", removed)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"stop expired", "remove expired"}) {
+		t.Fatalf("expected the expired container to be stopped and removed; received %v", calls)
+	}
+
+	stacks, err := m.db.Stacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stacks) != 2 {
+		t.Fatalf("expected preview and shop to be kept; received %d stacks", len(stacks))
+	}
+}
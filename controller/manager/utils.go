@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
@@ -36,12 +37,24 @@ func generateId(n int) string {
 	return mdStr[:n]
 }
 
+// generatePseudonym returns a random pseudonym; it is not derived from
+// the username so it cannot be reversed by hashing candidate usernames
+func generatePseudonym() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
 func parseClusterNodes(driverStatus [][]string) ([]*shipyard.Node, error) {
 	nodes := []*shipyard.Node{}
 	var node *shipyard.Node
 	nodeComplete := false
 	name := ""
 	addr := ""
+	status := ""
 	containers := ""
 	reservedCPUs := ""
 	reservedMemory := ""
@@ -65,6 +78,8 @@ func parseClusterNodes(driverStatus [][]string) ([]*shipyard.Node, error) {
 
 		// node info like "Containers"
 		switch label {
+		case " └ Status":
+			status = data
 		case " └ Containers":
 			containers = data
 		case " └ Reserved CPUs":
@@ -83,6 +98,7 @@ func parseClusterNodes(driverStatus [][]string) ([]*shipyard.Node, error) {
 			node = &shipyard.Node{
 				Name:           name,
 				Addr:           addr,
+				Status:         status,
 				Containers:     containers,
 				ReservedCPUs:   reservedCPUs,
 				ReservedMemory: reservedMemory,
@@ -93,6 +109,7 @@ func parseClusterNodes(driverStatus [][]string) ([]*shipyard.Node, error) {
 			// reset info
 			name = ""
 			addr = ""
+			status = ""
 			containers = ""
 			reservedCPUs = ""
 			reservedMemory = ""
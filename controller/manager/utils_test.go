@@ -66,3 +66,38 @@ func TestParseDriverStatus(t *testing.T) {
 	}
 
 }
+
+func TestImageMatches(t *testing.T) {
+	matches := [][]string{
+		{"nginx", "nginx:latest"},
+		{"library/nginx:1.9", "nginx:1.9"},
+		{"docker.io/ehazlett/app", "ehazlett/app"},
+		{"registry:5000/app", "registry:5000/app:latest"},
+	}
+	for _, m := range matches {
+		if !imageMatches(m[0], m[1]) {
+			t.Fatalf("expected %s to match %s", m[0], m[1])
+		}
+	}
+
+	if imageMatches("nginx:1.9", "nginx") {
+		t.Fatalf("expected nginx:1.9 to not match nginx:latest")
+	}
+}
+
+func TestGeneratePseudonym(t *testing.T) {
+	a, err := generatePseudonym()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := generatePseudonym()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pseudonyms are random so they cannot be matched to usernames
+	if len(a) != 16 || a == b {
+		t.Fatalf("expected two random pseudonyms; received %s and %s", a, b)
+	}
+}
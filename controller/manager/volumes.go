@@ -0,0 +1,241 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+)
+
+var (
+	ErrVolumeDoesNotExist = errors.New("volume does not exist")
+	ErrVolumeInUse        = errors.New("the volume is used by a container")
+	ErrVolumeNodeRequired = errors.New("volumes are created on a node; the node is required")
+)
+
+// VolumeRequest creates a volume on a node
+type VolumeRequest struct {
+	Node       string            `json:"node"`
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	DriverOpts map[string]string `json:"driver_opts"`
+	Labels     map[string]string `json:"labels"`
+}
+
+// volumeError maps the errors of the engine for a volume
+func volumeError(err error) error {
+	if err == dockerclient.ErrNotFound || err != nil && strings.Contains(strings.ToLower(err.Error()), "no such volume") {
+		return ErrVolumeDoesNotExist
+	}
+
+	if e, ok := err.(dockerclient.Error); ok && e.StatusCode == http.StatusConflict {
+		return ErrVolumeInUse
+	}
+
+	return err
+}
+
+// volumeNode returns the node of the name or an error when it is not in
+// the cluster
+func (m DefaultManager) volumeNode(name string) (*shipyard.Node, error) {
+	if name == "" {
+		return nil, ErrVolumeNodeRequired
+	}
+
+	node, err := m.Node(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, ErrNodeDoesNotExist
+	}
+
+	return node, nil
+}
+
+// nodeVolumes lists the volumes of the engine of the node; engines mark
+// the volumes no container uses as dangling
+func (m DefaultManager) nodeVolumes(node *shipyard.Node) ([]*shipyard.Volume, error) {
+	client, err := m.nodeClient(node)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := client.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := url.QueryEscape(`{"dangling":["true"]}`)
+	resp, err := client.HTTPClient.Get(fmt.Sprintf("%s/volumes?filters=%s", client.URL.String(), filters))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error getting dangling volumes of %s: %s", node.Addr, resp.Status)
+	}
+
+	dangling := &dockerclient.VolumesListResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(dangling); err != nil {
+		return nil, err
+	}
+
+	unused := map[string]bool{}
+	for _, v := range dangling.Volumes {
+		unused[v.Name] = true
+	}
+
+	result := []*shipyard.Volume{}
+	for _, v := range volumes {
+		result = append(result, &shipyard.Volume{
+			Name:       v.Name,
+			Driver:     v.Driver,
+			Mountpoint: v.Mountpoint,
+			Labels:     v.Labels,
+			Node:       node.Name,
+			Dangling:   unused[v.Name],
+		})
+	}
+
+	return result, nil
+}
+
+// Volumes lists the volumes of every node or of the node; nodes whose
+// engine cannot be reached are skipped
+func (m DefaultManager) Volumes(node string, danglingOnly bool) ([]*shipyard.Volume, error) {
+	nodes, err := m.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := []*shipyard.Volume{}
+	for _, n := range nodes {
+		if node != "" && n.Name != node {
+			continue
+		}
+
+		nodeVolumes, err := m.nodeVolumes(n)
+		if err != nil {
+			log.Warnf("error listing volumes of %s: %s", n.Name, err)
+			continue
+		}
+
+		for _, v := range nodeVolumes {
+			if danglingOnly && !v.Dangling {
+				continue
+			}
+
+			volumes = append(volumes, v)
+		}
+	}
+
+	return volumes, nil
+}
+
+// CreateVolume creates the volume on the engine of the node
+func (m DefaultManager) CreateVolume(request *VolumeRequest, username string) (*shipyard.Volume, error) {
+	node, err := m.volumeNode(request.Node)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := m.nodeClient(node)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := client.CreateVolume(&dockerclient.VolumeCreateRequest{
+		Name:       request.Name,
+		Driver:     request.Driver,
+		DriverOpts: request.DriverOpts,
+		Labels:     request.Labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.logEvent("create-volume", fmt.Sprintf("node=%s name=%s driver=%s username=%s", node.Name, v.Name, v.Driver, username), []string{"volume"})
+
+	return &shipyard.Volume{
+		Name:       v.Name,
+		Driver:     v.Driver,
+		Mountpoint: v.Mountpoint,
+		Labels:     v.Labels,
+		Node:       node.Name,
+		Dangling:   true,
+	}, nil
+}
+
+// RemoveVolume removes the volume from the engine of the node; volumes
+// used by a container are kept
+func (m DefaultManager) RemoveVolume(node, name, username string) error {
+	n, err := m.volumeNode(node)
+	if err != nil {
+		return err
+	}
+
+	if err := m.removeVolume(n, name); err != nil {
+		return err
+	}
+
+	m.logEvent("remove-volume", fmt.Sprintf("node=%s name=%s username=%s", n.Name, name, username), []string{"volume"})
+
+	return nil
+}
+
+func (m DefaultManager) removeVolume(node *shipyard.Node, name string) error {
+	client, err := m.nodeClient(node)
+	if err != nil {
+		return err
+	}
+
+	return volumeError(client.RemoveVolume(name))
+}
+
+// PruneVolumes removes the dangling volumes of every node or of the node
+// and returns the removed ones; volumes taken into use meanwhile are kept
+func (m DefaultManager) PruneVolumes(node, username string) ([]*shipyard.Volume, error) {
+	nodes, err := m.Nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []*shipyard.Volume{}
+	for _, n := range nodes {
+		if node != "" && n.Name != node {
+			continue
+		}
+
+		volumes, err := m.nodeVolumes(n)
+		if err != nil {
+			log.Warnf("error listing volumes of %s: %s", n.Name, err)
+			continue
+		}
+
+		for _, v := range volumes {
+			if !v.Dangling {
+				continue
+			}
+
+			if err := m.removeVolume(n, v.Name); err != nil {
+				log.Warnf("error pruning volume %s of %s: %s", v.Name, n.Name, err)
+				continue
+			}
+
+			removed = append(removed, v)
+		}
+	}
+
+	m.logEvent("prune-volumes", fmt.Sprintf("node=%s removed=%d username=%s", node, len(removed), username), []string{"volume"})
+
+	return removed, nil
+}
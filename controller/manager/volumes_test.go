@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard"
+)
+
+func TestNodeVolumes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/volumes") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if strings.Contains(r.URL.Query().Get("filters"), "dangling") {
+			w.Write([]byte(`{"Volumes":[{"Name":"unused","Driver":"local"}]}`))
+			return
+		}
+
+		w.Write([]byte(`{"Volumes":[{"Name":"unused","Driver":"local"},{"Name":"data","Driver":"rexray","Labels":{"team":"payments"}}]}`))
+	}))
+	defer ts.Close()
+
+	m, cleanup := newTestManager(t)
+	defer cleanup()
+
+	client, err := dockerclient.NewDockerClient(ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.client = &clusterClient{client: client}
+
+	volumes, err := m.nodeVolumes(&shipyard.Node{Name: "node-1", Addr: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(volumes) != 2 {
+		t.Fatalf("expected 2 volumes; received %d", len(volumes))
+	}
+
+	for _, v := range volumes {
+		if v.Node != "node-1" {
+			t.Fatalf("expected the volumes of node-1; received %s", v.Node)
+		}
+
+		if v.Dangling != (v.Name == "unused") {
+			t.Fatalf("unexpected dangling state of %s: %v", v.Name, v.Dangling)
+		}
+	}
+}
+
+func TestVolumeError(t *testing.T) {
+	errs := map[error]error{
+		nil:                                    nil,
+		dockerclient.ErrNotFound:               ErrVolumeDoesNotExist,
+		errors.New("get data: no such volume"): ErrVolumeDoesNotExist,
+		dockerclient.Error{StatusCode: 409}:    ErrVolumeInUse,
+		dockerclient.Error{StatusCode: 500}:    dockerclient.Error{StatusCode: 500},
+		dockerclient.ErrConnectionRefused:      dockerclient.ErrConnectionRefused,
+	}
+
+	for err, expected := range errs {
+		if e := volumeError(err); e != expected {
+			t.Fatalf("expected %v for %v; received %v", expected, err, e)
+		}
+	}
+}
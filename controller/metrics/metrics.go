@@ -0,0 +1,289 @@
+// Package metrics is a minimal Prometheus text format exposition for the
+// controller; counters, histograms and gauges computed on scrape.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// DefaultBuckets are the latency buckets in seconds
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// Default is the registry served by Handler
+	Default = NewRegistry()
+)
+
+type (
+	collector interface {
+		write(w io.Writer)
+	}
+
+	// Registry holds the metrics of a process
+	Registry struct {
+		mu         sync.Mutex
+		collectors map[string]collector
+	}
+
+	// Counter is a monotonically increasing value per label set
+	Counter struct {
+		name   string
+		help   string
+		labels []string
+		mu     sync.Mutex
+		values map[string]float64
+	}
+
+	// Histogram counts observations in buckets per label set
+	Histogram struct {
+		name    string
+		help    string
+		labels  []string
+		buckets []float64
+		mu      sync.Mutex
+		values  map[string]*histogramValue
+	}
+
+	histogramValue struct {
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+
+	// GaugeFunc is a value computed when metrics are scraped
+	GaugeFunc struct {
+		name string
+		help string
+		fn   func() float64
+	}
+)
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: map[string]collector{},
+	}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors[name] = c
+}
+
+// NewCounter registers a counter with the label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: map[string]float64{},
+	}
+	r.register(name, c)
+
+	return c
+}
+
+// NewHistogram registers a histogram with the buckets and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  map[string]*histogramValue{},
+	}
+	r.register(name, h)
+
+	return h
+}
+
+// NewGaugeFunc registers a gauge computed by fn on each scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{
+		name: name,
+		help: help,
+		fn:   fn,
+	}
+	r.register(name, g)
+
+	return g
+}
+
+// Expose writes all metrics in the Prometheus text format
+func (r *Registry) Expose(w io.Writer) {
+	r.mu.Lock()
+	names := []string{}
+	for n := range r.collectors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	collectors := []collector{}
+	for _, n := range names {
+		collectors = append(collectors, r.collectors[n])
+	}
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the registry for scraping
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf := &bytes.Buffer{}
+		r.Expose(buf)
+
+		w.Header().Set("content-type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	})
+}
+
+// Handler serves the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// key joins label values; it is split again when writing
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func labelString(names []string, k string, extra ...string) string {
+	pairs := []string{}
+	if len(names) > 0 {
+		for i, v := range strings.Split(k, "\xff") {
+			if i < len(names) {
+				pairs = append(pairs, fmt.Sprintf("%s=%q", names[i], v))
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Add adds v to the counter for the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key(labelValues)] += v
+}
+
+// Inc increments the counter for the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+
+	// an unlabeled counter is always reported
+	if len(c.labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.values[""]))
+		return
+	}
+
+	keys := map[string]bool{}
+	for k := range c.values {
+		keys[k] = true
+	}
+
+	for _, k := range sortedKeys(keys) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, k), formatFloat(c.values[k]))
+	}
+}
+
+// Observe records a value for the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := key(labelValues)
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hv
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+
+	keys := map[string]bool{}
+	for k := range h.values {
+		keys[k] = true
+	}
+
+	for _, k := range sortedKeys(keys) {
+		hv := h.values[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, k, "le", formatFloat(b)), hv.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, k, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelString(h.labels, k), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelString(h.labels, k), hv.count)
+	}
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
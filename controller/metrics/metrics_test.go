@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("test_requests_total", "Requests.", "method", "route")
+	requests.Inc("GET", "/api/events")
+	requests.Inc("GET", "/api/events")
+	requests.Inc("POST", "/api/roles")
+
+	failures := r.NewCounter("test_failures_total", "Failures.")
+
+	latency := r.NewHistogram("test_duration_seconds", "Latency.", []float64{.1, 1}, "route")
+	latency.Observe(.05, "/api/events")
+	latency.Observe(.5, "/api/events")
+
+	r.NewGaugeFunc("test_nodes", "Nodes.", func() float64 { return 3 })
+
+	buf := &bytes.Buffer{}
+	r.Expose(buf)
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{method="GET",route="/api/events"} 2` + "\n",
+		`test_requests_total{method="POST",route="/api/roles"} 1` + "\n",
+		"test_failures_total 0\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="/api/events",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{route="/api/events",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="/api/events",le="+Inf"} 2` + "\n",
+		`test_duration_seconds_sum{route="/api/events"} 0.55` + "\n",
+		`test_duration_seconds_count{route="/api/events"} 2` + "\n",
+		"# TYPE test_nodes gauge\ntest_nodes 3\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("expected %q in output:\n%s", e, out)
+		}
+	}
+
+	failures.Inc()
+	buf.Reset()
+	r.Expose(buf)
+	if !strings.Contains(buf.String(), "test_failures_total 1\n") {
+		t.Fatalf("expected incremented counter:\n%s", buf.String())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/shipyard/shipyard/auth"
 	"github.com/shipyard/shipyard/controller/manager"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
 )
 
 var (
@@ -21,96 +22,125 @@ func defaultDeniedHandler(w http.ResponseWriter, r *http.Request) {
 type AccessRequired struct {
 	deniedHandler http.Handler
 	manager       manager.Manager
-	acls          []*auth.ACL
+	// proxied checks requests to the proxied Docker api against the
+	// level of the route rather than the access rules alone
+	proxied bool
 }
 
 func NewAccessRequired(m manager.Manager) *AccessRequired {
-	acls := auth.DefaultACLs()
 	a := &AccessRequired{
 		deniedHandler: http.HandlerFunc(defaultDeniedHandler),
 		manager:       m,
-		acls:          acls,
 	}
 	return a
 }
 
+// NewProxyAccessRequired returns the access check of the swarm router;
+// writes need the permission of the route and routes without one are
+// admin only
+func NewProxyAccessRequired(m manager.Manager) *AccessRequired {
+	a := NewAccessRequired(m)
+	a.proxied = true
+	return a
+}
+
 func (a *AccessRequired) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		err := a.handleRequest(w, r)
+		acct, err := a.handleRequest(w, r)
 		if err != nil {
 			logger.Warnf("unauthorized request for %s from %s", r.URL.Path, r.RemoteAddr)
 			return
 		}
-		h.ServeHTTP(w, r)
+		a.serve(acct, w, r, h.ServeHTTP)
 	})
 }
 
-func (a *AccessRequired) handleRequest(w http.ResponseWriter, r *http.Request) error {
+// handleRequest checks the roles of the account of the request and returns
+// the account; requests with a service key have no account
+func (a *AccessRequired) handleRequest(w http.ResponseWriter, r *http.Request) (*auth.Account, error) {
+	var acct *auth.Account
 	valid := false
-	authHeader := r.Header.Get("X-Access-Token")
+	authHeader := mAuth.AccessToken(r)
 	parts := strings.Split(authHeader, ":")
-	if len(parts) == 2 {
+	if key := r.Header.Get("X-Service-Key"); key != "" {
+		// service keys take priority as in the auth check; they have no
+		// roles but scoped keys are limited to their scopes
+		k, err := a.manager.ServiceKey(key)
+		valid = err == nil && k.Allows(r.URL.Path, r.Method)
+	} else if len(parts) == 2 {
 		// validate
 		u := parts[0]
 		token := parts[1]
 		if err := a.manager.VerifyAuthToken(u, token); err == nil {
-			acct, err := a.manager.Account(u)
+			acct, err = a.manager.Account(u)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			// check role
 			valid = a.checkAccess(acct, r.URL.Path, r.Method)
 		}
-	} else { // only check access for users; not service keys
+	} else { // whitelisted addresses
 		valid = true
 	}
 
 	if !valid {
 		a.deniedHandler.ServeHTTP(w, r)
-		return fmt.Errorf("access denied %s", r.RemoteAddr)
+		return nil, fmt.Errorf("access denied %s", r.RemoteAddr)
 	}
 
-	return nil
+	return acct, nil
 }
 
-func (a *AccessRequired) checkRule(rule *auth.AccessRule, path, method string) bool {
-	// check wildcard
-	if rule.Path == "*" {
-		return true
+// serve passes the request on; requests of accounts restricted to
+// container labels are checked against the scope first
+func (a *AccessRequired) serve(acct *auth.Account, w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if acct == nil {
+		next(w, r)
+		return
 	}
 
-	// check path
-	if strings.HasPrefix(path, rule.Path) {
-		// check method
-		for _, m := range rule.Methods {
-			if m == method {
-				return true
-			}
-		}
+	acls, err := a.manager.Roles()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return false
+	scope := auth.ContainerScope(acct, acls)
+	if scope == nil {
+		next(w, r)
+		return
+	}
+
+	a.checkScope(scope, w, r, next)
 }
 
-func (a *AccessRequired) checkRole(role string, path, method string) bool {
-	for _, acl := range a.acls {
-		// find role
+func (a *AccessRequired) checkRole(acls []*auth.ACL, role string, path, method string) bool {
+	for _, acl := range acls {
+		// find role; the acl checks both its rules and permissions
 		if acl.RoleName == role {
-			for _, rule := range acl.Rules {
-				if a.checkRule(rule, path, method) {
-					return true
-				}
+			if a.proxied {
+				return acl.AllowsProxied(path, method)
 			}
+
+			return acl.Allows(path, method)
 		}
 	}
 
 	return false
 }
+
 func (a *AccessRequired) checkAccess(acct *auth.Account, path string, method string) bool {
+	// roles include custom roles so they are loaded for each request
+	acls, err := a.manager.Roles()
+	if err != nil {
+		logger.Errorf("error loading roles: %s", err)
+		return false
+	}
+
 	// check roles
 	for _, role := range acct.Roles {
 		// check acls
-		if a.checkRole(role, path, method) {
+		if a.checkRole(acls, role, path, method) {
 			return true
 		}
 	}
@@ -119,7 +149,7 @@ func (a *AccessRequired) checkAccess(acct *auth.Account, path string, method str
 }
 
 func (a *AccessRequired) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	err := a.handleRequest(w, r)
+	acct, err := a.handleRequest(w, r)
 	session, _ := a.manager.Store().Get(r, a.manager.StoreKey())
 	username := session.Values["username"]
 	if err != nil {
@@ -128,6 +158,6 @@ func (a *AccessRequired) HandlerFuncWithNext(w http.ResponseWriter, r *http.Requ
 	}
 
 	if next != nil {
-		next(w, r)
+		a.serve(acct, w, r, next)
 	}
 }
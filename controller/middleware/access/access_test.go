@@ -1,6 +1,10 @@
 package access
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/shipyard/shipyard/auth"
@@ -349,3 +353,191 @@ func TestAccessControlNodesRWRole(t *testing.T) {
 		t.Fatalf("expected denied access for %s %s", testMethod, testPath)
 	}
 }
+
+func TestAccessControlOperatorRole(t *testing.T) {
+	testAcct := &auth.Account{
+		Username: "testuser",
+		Roles:    []string{"operator:ro"},
+	}
+
+	for _, path := range []string{"/v1.20/containers/json", "/images/json", "/api/registries", "/api/nodes"} {
+		if !accessRequired.checkAccess(testAcct, path, "GET") {
+			t.Fatalf("expected valid access for GET %s", path)
+		}
+	}
+
+	if accessRequired.checkAccess(testAcct, "/v1.20/containers/abc/exec", "POST") {
+		t.Fatal("expected denied access for exec")
+	}
+
+	if accessRequired.checkAccess(testAcct, "/api/registries", "POST") {
+		t.Fatal("expected denied access for POST /api/registries")
+	}
+}
+
+func TestContainerTarget(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		target int
+		id     string
+	}{
+		{"GET", "/containers/json", targetList, ""},
+		{"GET", "/v1.21/containers/json", targetList, ""},
+		{"POST", "/containers/create", targetCreate, ""},
+		{"POST", "/containers/abc/start", targetContainer, "abc"},
+		{"GET", "/v1.21/containers/node-1/web/json", targetContainer, "node-1/web"},
+		{"DELETE", "/containers/abc", targetContainer, "abc"},
+		{"GET", "/containers/abc/attach/ws", targetContainer, "abc"},
+		{"POST", "/api/containers/abc/scale", targetContainer, "abc"},
+		{"GET", "/images/json", targetNone, ""},
+	}
+
+	for _, test := range tests {
+		target, id := containerTarget(test.method, test.path)
+		if target != test.target || id != test.id {
+			t.Errorf("%s %s: expected %d %q; received %d %q", test.method, test.path, test.target, test.id, target, id)
+		}
+	}
+}
+
+func TestCheckScopeFiltersContainers(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"Id":"a","SizeRw":12345678901234567,"Labels":{"team":"payments"}},{"Id":"b","Labels":{"team":"search"}},{"Id":"c"}]`))
+	}
+
+	req, _ := http.NewRequest("GET", "/containers/json", nil)
+	res := httptest.NewRecorder()
+	accessRequired.checkScope(auth.LabelScope{"team=payments"}, res, req, next)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected %d; received %d", http.StatusOK, res.Code)
+	}
+
+	var containers []map[string]interface{}
+	d := json.NewDecoder(res.Body)
+	d.UseNumber()
+	if err := d.Decode(&containers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(containers) != 1 || containers[0]["Id"] != "a" {
+		t.Fatalf("expected only the payments container; received %v", containers)
+	}
+
+	if containers[0]["SizeRw"] != json.Number("12345678901234567") {
+		t.Fatalf("expected sizes to be kept; received %v", containers[0]["SizeRw"])
+	}
+}
+
+func TestCheckScopeCreate(t *testing.T) {
+	served := false
+	next := func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}
+
+	scope := auth.LabelScope{"team=payments"}
+
+	req, _ := http.NewRequest("POST", "/containers/create", strings.NewReader(`{"Image":"busybox","Labels":{"team":"search"}}`))
+	res := httptest.NewRecorder()
+	accessRequired.checkScope(scope, res, req, next)
+
+	if res.Code != http.StatusForbidden || served {
+		t.Fatalf("expected %d; received %d", http.StatusForbidden, res.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/containers/create", strings.NewReader(`{"Image":"busybox","Labels":{"team":"payments"}}`))
+	res = httptest.NewRecorder()
+	accessRequired.checkScope(scope, res, req, next)
+
+	if !served {
+		t.Fatalf("expected the create to be served; received %d", res.Code)
+	}
+}
+
+func TestCheckScopeContainerOutOfScope(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("expected the request to be denied")
+	}
+
+	// the test container has no labels
+	req, _ := http.NewRequest("POST", "/containers/"+mock_test.TestContainerId+"/stop", nil)
+	res := httptest.NewRecorder()
+	accessRequired.checkScope(auth.LabelScope{"team=payments"}, res, req, next)
+
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("expected %d; received %d", http.StatusForbidden, res.Code)
+	}
+}
+
+func TestCheckScopeContainerOnUnknownCluster(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("expected the request to be denied")
+	}
+
+	req, _ := http.NewRequest("POST", "/containers/"+mock_test.TestContainerId+"/stop", nil)
+	req.Header.Set(ClusterHeader, "unknown")
+	res := httptest.NewRecorder()
+	accessRequired.checkScope(auth.LabelScope{"team=payments"}, res, req, next)
+
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("expected %d; received %d", http.StatusNotFound, res.Code)
+	}
+}
+
+func TestAccessControlProxied(t *testing.T) {
+	proxyAccess := NewProxyAccessRequired(mockManager)
+
+	testAcct := &auth.Account{
+		Username: "testuser",
+		Roles:    []string{"images:ro", "containers:rw"},
+	}
+
+	if !proxyAccess.checkAccess(testAcct, "/v1.24/images/json", "GET") {
+		t.Fatal("expected reads to be allowed")
+	}
+
+	if !proxyAccess.checkAccess(testAcct, "/containers/create", "POST") {
+		t.Fatal("expected containers:write to create containers")
+	}
+
+	for _, path := range []string{"/images/web/push", "/v1.24/build"} {
+		if proxyAccess.checkAccess(testAcct, path, "POST") {
+			t.Fatalf("expected denied access for POST %s", path)
+		}
+	}
+
+	if proxyAccess.checkAccess(testAcct, "/swarm", "GET") {
+		t.Fatal("expected routes without a permission to be admin only")
+	}
+
+	testAcct.Roles = []string{"admin"}
+	if !proxyAccess.checkAccess(testAcct, "/images/web/push", "POST") {
+		t.Fatal("expected admin to push images")
+	}
+}
+
+func TestAccessControlScopedServiceKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		method string
+		path   string
+		code   int
+	}{
+		{mock_test.TestScopedServiceKey.Key, "GET", "/api/events", http.StatusOK},
+		{mock_test.TestScopedServiceKey.Key, "DELETE", "/api/events", http.StatusForbidden},
+		{mock_test.TestScopedServiceKey.Key, "POST", "/containers/create", http.StatusForbidden},
+		{mock_test.TestServiceKey.Key, "POST", "/containers/create", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		req.Header.Set("X-Service-Key", test.key)
+		res := httptest.NewRecorder()
+
+		if _, err := accessRequired.handleRequest(res, req); (err == nil) != (test.code == http.StatusOK) || res.Code != test.code {
+			t.Errorf("%s %s with %s: expected %d; received %d (%v)", test.method, test.path, test.key, test.code, res.Code, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package access
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/manager"
+)
+
+const (
+	// maxCreateBody is how much of a container create request is read for
+	// its labels
+	maxCreateBody = 1024 * 1024
+
+	// ClusterHeader selects the cluster a request operates against;
+	// websockets use the cluster query parameter and docker clients the
+	// /clusters/<name> path prefix instead
+	ClusterHeader = "X-Shipyard-Cluster"
+)
+
+var (
+	apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+)
+
+// container requests checked against the scope of an account
+const (
+	targetNone = iota
+	targetList
+	targetCreate
+	targetContainer
+)
+
+// SelectedCluster returns the cluster selected by the request; empty for
+// the default cluster
+func SelectedCluster(r *http.Request) string {
+	if name := r.Header.Get(ClusterHeader); name != "" {
+		return name
+	}
+
+	return r.URL.Query().Get("cluster")
+}
+
+// containerTarget returns what a request does with containers and the
+// container it acts on
+func containerTarget(method, path string) (int, string) {
+	path = apiVersionPrefix.ReplaceAllString(path, "/")
+
+	// shipyard actions on a container (i.e. scale)
+	if strings.HasPrefix(path, "/api/containers/") {
+		parts := strings.Split(strings.TrimPrefix(path, "/api/containers/"), "/")
+		return targetContainer, parts[0]
+	}
+
+	if !strings.HasPrefix(path, "/containers/") {
+		return targetNone, ""
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(path, "/containers/"), "/")
+	switch rest {
+	case "json":
+		return targetList, ""
+	case "create":
+		return targetCreate, ""
+	}
+
+	// swarm names contain the node (i.e. node-1/web) so the action is
+	// taken from the end; deletes have no action
+	rest = strings.TrimSuffix(rest, "/attach/ws")
+	if method != "DELETE" {
+		if i := strings.LastIndex(rest, "/"); i > -1 {
+			rest = rest[:i]
+		}
+	}
+
+	return targetContainer, rest
+}
+
+// checkScope serves the request when the containers it acts on are in the
+// scope; container lists are filtered to the scope
+func (a *AccessRequired) checkScope(scope auth.LabelScope, w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	target, id := containerTarget(r.Method, r.URL.Path)
+
+	switch target {
+	case targetList:
+		a.filterContainers(scope, w, r, next)
+		return
+	case targetCreate:
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCreateBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+
+		var config struct {
+			Labels map[string]string
+		}
+		// docker reports invalid configs itself
+		json.Unmarshal(data, &config)
+
+		if !scope.Matches(config.Labels) {
+			http.Error(w, "containers have to carry a label of your scope", http.StatusForbidden)
+			return
+		}
+	case targetContainer:
+		// the container is looked up on the cluster the request goes to
+		m, err := a.manager.ForCluster(SelectedCluster(r))
+		if err == manager.ErrClusterDoesNotExist {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info, err := m.Container(id)
+		if err == dockerclient.ErrNotFound {
+			http.Error(w, "container out of scope", http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		labels := map[string]string{}
+		if info.Config != nil {
+			labels = info.Config.Labels
+		}
+
+		if !scope.Matches(labels) {
+			http.Error(w, "container out of scope", http.StatusForbidden)
+			return
+		}
+	}
+
+	next(w, r)
+}
+
+// bufferedWriter keeps a response to change it before it is sent
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	b.status = status
+}
+
+// filterContainers removes the containers out of the scope from a
+// container list
+func (a *AccessRequired) filterContainers(scope auth.LabelScope, w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	buf := &bufferedWriter{header: http.Header{}, status: http.StatusOK}
+	next(buf, r)
+
+	data := buf.body.Bytes()
+
+	var containers []map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	// keep large numbers (i.e. sizes) exact
+	d.UseNumber()
+	if buf.status == http.StatusOK && d.Decode(&containers) == nil {
+		filtered := []map[string]interface{}{}
+		for _, c := range containers {
+			labels := map[string]string{}
+			if l, ok := c["Labels"].(map[string]interface{}); ok {
+				for k, v := range l {
+					labels[k], _ = v.(string)
+				}
+			}
+
+			if scope.Matches(labels) {
+				filtered = append(filtered, c)
+			}
+		}
+
+		if encoded, err := json.Marshal(filtered); err == nil {
+			data = encoded
+		}
+	}
+
+	for k, v := range buf.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(buf.status)
+	w.Write(data)
+}
@@ -1,7 +1,12 @@
 package audit
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -10,7 +15,18 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/auth"
 	"github.com/shipyard/shipyard/controller/manager"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+	"github.com/shipyard/shipyard/controller/middleware/requestlog"
+	"github.com/shipyard/shipyard/utils"
+	"github.com/shipyard/shipyard/utils/syslog"
+)
+
+const (
+	// maxBodyAudit is how much of a request body is kept for detailed
+	// audit records
+	maxBodyAudit = 64 * 1024
 )
 
 var (
@@ -20,11 +36,12 @@ var (
 type Auditor struct {
 	manager  manager.Manager
 	excludes []string
+	syslog   *syslog.Writer
 }
 
 // parses username from auth token
 func getAuthUsername(r *http.Request) (string, error) {
-	authToken := r.Header.Get("X-Access-Token")
+	authToken := mAuth.AccessToken(r)
 
 	parts := strings.Split(authToken, ":")
 
@@ -44,11 +61,37 @@ func filterURI(uri string) (string, error) {
 	return u.Path, nil
 }
 
-func NewAuditor(m manager.Manager, excludes []string) *Auditor {
+// NewAuditor returns an auditor saving records through the manager; when
+// syslogWriter is not nil records are also streamed to syslog
+func NewAuditor(m manager.Manager, excludes []string, syslogWriter *syslog.Writer) *Auditor {
 	return &Auditor{
 		manager:  m,
 		excludes: excludes,
+		syslog:   syslogWriter,
+	}
+}
+
+// requestDetail describes the request in full for detailed audit records
+// with secrets in the query and body redacted; the body is read and
+// restored for the next handler
+func requestDetail(r *http.Request) string {
+	body := ""
+	if r.Body != nil {
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyAudit))
+		if err != nil {
+			log.Errorf("audit error reading body: %s", err)
+		}
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		body = redactBody(mediaType, data)
+	}
+
+	uri := r.URL.EscapedPath()
+	if query := r.URL.Query(); len(query) > 0 {
+		uri += "?" + redactQuery(query).Encode()
 	}
+
+	return fmt.Sprintf("%s %s user_agent=%q body=%q", r.Method, uri, r.UserAgent(), body)
 }
 
 func (a *Auditor) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
@@ -64,8 +107,15 @@ func (a *Auditor) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, ne
 		log.Errorf("audit path filter error: %s", err)
 	}
 
+	// break-glass access is always audited in full
+	breakGlass := user == auth.BreakGlassUsername
+
 	// check if excluded
 	for _, e := range a.excludes {
+		if breakGlass {
+			break
+		}
+
 		match, err := regexp.MatchString(e, path)
 		if err != nil {
 			log.Errorf("audit exclude error: %s", err)
@@ -77,20 +127,58 @@ func (a *Auditor) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, ne
 		}
 	}
 
+	// state changes are recorded regardless of the excludes
+	var entry *shipyard.AuditEntry
+	if path != "" && mutation(r.Method) {
+		entry = newAuditEntry(r, user, path)
+	}
+
 	if user != "" && path != "" && !skipAudit {
 		tagParts := strings.Split(path, "/")
 		tag := tagParts[1]
 
 		evt := &shipyard.Event{
-			Type:     "api",
-			Time:     time.Now(),
-			Username: user,
-			Message:  path,
-			Tags:     []string{"api", tag, strings.ToLower(r.Method)},
+			Type:       "api",
+			Time:       time.Now(),
+			Username:   user,
+			RemoteAddr: utils.RemoteIP(r.RemoteAddr),
+			Message:    path,
+			Tags:       []string{"api", tag, strings.ToLower(r.Method)},
+			RequestID:  requestlog.RequestID(r),
+		}
+
+		if breakGlass {
+			evt.Message = requestDetail(r)
+			evt.Tags = append(evt.Tags, "security", "break-glass")
 		}
 
 		if err := a.manager.SaveEvent(evt); err != nil {
-			log.Errorf("error saving event: %s", err)
+			requestlog.Entry(r).Errorf("error saving event: %s", err)
+		}
+
+		if a.syslog != nil {
+			data := map[string]string{
+				"username":    evt.Username,
+				"remote_addr": evt.RemoteAddr,
+				"method":      r.Method,
+				"path":        path,
+			}
+			if evt.Country != "" {
+				data["country"] = evt.Country
+			}
+			if evt.ASN != "" {
+				data["asn"] = evt.ASN
+			}
+			if evt.RequestID != "" {
+				data["request_id"] = evt.RequestID
+			}
+
+			a.syslog.Send(&syslog.Message{
+				Time:    evt.Time,
+				MsgID:   evt.Type,
+				Message: fmt.Sprintf("%s %s", r.Method, path),
+				Data:    data,
+			})
 		}
 	}
 
@@ -100,4 +188,11 @@ func (a *Auditor) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, ne
 	if next != nil {
 		next(w, r)
 	}
+
+	if entry != nil {
+		entry.Status = responseStatus(w)
+		if err := a.manager.SaveAuditEntry(entry); err != nil {
+			requestlog.Entry(r).Errorf("error saving audit entry: %s", err)
+		}
+	}
 }
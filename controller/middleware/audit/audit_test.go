@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shipyard/shipyard/controller/middleware/requestlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditAction(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		action string
+	}{
+		{"POST", "/containers/abc/start", "container-start"},
+		{"POST", "/v1.20/containers/abc/stop", "container-stop"},
+		{"DELETE", "/containers/abc", "container-delete"},
+		{"POST", "/containers/create", "container-create"},
+		{"POST", "/images/create", "image-pull"},
+		{"DELETE", "/images/library/nginx", "image-delete"},
+		{"POST", "/images/library/nginx/push", "image-push"},
+		{"POST", "/build", "image-build"},
+		{"POST", "/api/notes/node/node-1", ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.action, auditAction(test.method, test.path), "%s %s", test.method, test.path)
+	}
+}
+
+func TestSummarizePayload(t *testing.T) {
+	body := []byte(`{"username":"admin","password":"secret","roles":["admin"]}`)
+	assert.Equal(t, `password=[redacted] roles=["admin"] username=admin`,
+		summarizePayload(nil, "application/json", int64(len(body)), body))
+
+	query := url.Values{"fromImage": {"nginx"}, "tag": {"latest"}}
+	assert.Equal(t, "fromImage=nginx tag=latest", summarizePayload(query, "", 0, nil))
+
+	assert.Equal(t, "t=web <2048 bytes application/x-tar>",
+		summarizePayload(url.Values{"t": {"web"}}, "application/x-tar", 2048, nil))
+}
+
+func TestRequestDetailRedactsSecrets(t *testing.T) {
+	body := `{"username":"admin","password":"secret","registry":{"auth_token":"abc"},"roles":["admin"]}`
+	req := httptest.NewRequest("POST", "/api/accounts?token=xyz&force=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "curl")
+
+	assert.Equal(t, `POST /api/accounts?force=true&token=%5Bredacted%5D user_agent="curl" body="{\"password\":\"[redacted]\",\"registry\":{\"auth_token\":\"[redacted]\"},\"roles\":[\"admin\"],\"username\":\"admin\"}"`,
+		requestDetail(req))
+
+	data, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(data), "body must be restored for the next handler")
+
+	req = httptest.NewRequest("POST", "/build", strings.NewReader("password=secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.Contains(t, requestDetail(req), `body="<15 bytes application/x-www-form-urlencoded>"`)
+}
+
+func TestNewAuditEntryRequestID(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/stacks", nil)
+	req.Header.Set(requestlog.RequestIDHeader, "abc123")
+
+	entry := newAuditEntry(req, "alice", "/api/stacks")
+	assert.Equal(t, "abc123", entry.RequestID)
+}
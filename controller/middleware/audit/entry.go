@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/negroni"
+	"github.com/shipyard/shipyard"
+	"github.com/shipyard/shipyard/controller/middleware/requestlog"
+	"github.com/shipyard/shipyard/utils"
+)
+
+const (
+	// maxPayloadSummary is the length of a payload summary; longer
+	// summaries are truncated
+	maxPayloadSummary = 512
+	// maxPayloadValue is the length of a single value in a summary
+	maxPayloadValue = 64
+
+	redacted = "[redacted]"
+
+	// serviceKeyUsername is recorded for requests authenticated with a
+	// service key instead of an account
+	serviceKeyUsername = "service-key"
+)
+
+var (
+	apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+
+	// sensitiveKeys are redacted from payload summaries
+	sensitiveKeys = []string{"password", "passwd", "secret", "token", "key", "credential", "auth"}
+)
+
+// mutation reports whether requests with the method change state
+func mutation(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+// auditAction names Docker mutations proxied to swarm (i.e.
+// container-stop or image-delete); requests to the Shipyard api have no
+// action as the route describes them
+func auditAction(method, path string) string {
+	path = apiVersionPrefix.ReplaceAllString(path, "/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch parts[0] {
+	case "", "api", "account":
+		return ""
+	case "build":
+		return "image-build"
+	case "commit":
+		return "container-commit"
+	}
+
+	resource := strings.TrimSuffix(parts[0], "s")
+
+	switch {
+	case method == "DELETE":
+		return resource + "-delete"
+	case len(parts) == 2 && parts[1] == "create" && resource == "image":
+		return "image-pull"
+	case len(parts) == 2:
+		return resource + "-" + parts[1]
+	case len(parts) > 2:
+		// image names can contain slashes so the operation is last
+		return resource + "-" + parts[len(parts)-1]
+	}
+
+	return resource
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n] + "..."
+}
+
+// summarizeValues renders values as sorted key=value pairs redacting
+// anything that looks like a secret
+func summarizeValues(values map[string]string) []string {
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		v := values[k]
+		if sensitive(k) {
+			v = redacted
+		}
+
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, truncate(v, maxPayloadValue)))
+	}
+
+	return pairs
+}
+
+// redactValue replaces the values of sensitive keys in a decoded JSON
+// value, including nested objects
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if sensitive(k) {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactValue(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = redactValue(val)
+		}
+	}
+
+	return v
+}
+
+// redactQuery returns the query with the values of sensitive keys redacted
+func redactQuery(query url.Values) url.Values {
+	values := url.Values{}
+	for k, v := range query {
+		if sensitive(k) {
+			v = []string{redacted}
+		}
+		values[k] = v
+	}
+
+	return values
+}
+
+// redactBody returns a JSON body with the values of sensitive keys
+// redacted; other bodies are described by their size and type as they
+// cannot be filtered
+func redactBody(mediaType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if data, err := json.Marshal(redactValue(v)); err == nil {
+			return string(data)
+		}
+	}
+
+	if mediaType == "" {
+		mediaType = "unknown"
+	}
+
+	return fmt.Sprintf("<%d bytes %s>", len(body), mediaType)
+}
+
+// summarizePayload describes the query and body of a request without
+// secrets; JSON objects are summarized by their top level fields and other
+// bodies by their size and type
+func summarizePayload(query url.Values, mediaType string, size int64, body []byte) string {
+	values := map[string]string{}
+	for k, v := range query {
+		values[k] = strings.Join(v, ",")
+	}
+	parts := summarizeValues(values)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err == nil {
+		values := map[string]string{}
+		for k, v := range fields {
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				s = string(v)
+			}
+			values[k] = s
+		}
+		parts = append(parts, summarizeValues(values)...)
+	} else if size > 0 {
+		if mediaType == "" {
+			mediaType = "unknown"
+		}
+		parts = append(parts, fmt.Sprintf("<%d bytes %s>", size, mediaType))
+	}
+
+	return truncate(strings.Join(parts, " "), maxPayloadSummary)
+}
+
+// newAuditEntry starts the entry of a request; only JSON bodies are read
+// for the summary, and restored for the next handler, so build archives
+// are not buffered
+func newAuditEntry(r *http.Request, username, path string) *shipyard.AuditEntry {
+	if username == "" && r.Header.Get("X-Service-Key") != "" {
+		username = serviceKeyUsername
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	size := r.ContentLength
+
+	var body []byte
+	if r.Body != nil && mediaType == "application/json" {
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyAudit))
+		if err != nil {
+			log.Errorf("audit error reading body: %s", err)
+		}
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+		body = data
+		size = int64(len(data))
+	}
+
+	return &shipyard.AuditEntry{
+		Time:       time.Now(),
+		Username:   username,
+		RemoteAddr: utils.RemoteIP(r.RemoteAddr),
+		Method:     r.Method,
+		Route:      path,
+		Action:     auditAction(r.Method, path),
+		Payload:    summarizePayload(r.URL.Query(), mediaType, size, body),
+		RequestID:  requestlog.RequestID(r),
+	}
+}
+
+// responseStatus returns the status written by the next handlers
+func responseStatus(w http.ResponseWriter) int {
+	if rw, ok := w.(negroni.ResponseWriter); ok && rw.Status() != 0 {
+		return rw.Status()
+	}
+
+	return http.StatusOK
+}
@@ -7,13 +7,51 @@ import (
 	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/context"
 	"github.com/shipyard/shipyard/controller/manager"
 )
 
+type contextKey int
+
+// usernameKey holds the account whose access token was verified
+const usernameKey contextKey = iota
+
 var (
 	logger = logrus.New()
 )
 
+// Username returns the account whose access token the middleware verified
+// for the request; requests with a service key or from a whitelisted
+// address have none.  Authorization has to use it rather than the
+// username in X-Access-Token, which is not verified.
+func Username(r *http.Request) string {
+	if username, ok := context.Get(r, usernameKey).(string); ok {
+		return username
+	}
+
+	return ""
+}
+
+// SetUsername records the verified account of the request (see Username)
+func SetUsername(r *http.Request, username string) {
+	context.Set(r, usernameKey, username)
+}
+
+// AccessToken returns the access token of the request from X-Access-Token;
+// websocket handshakes, which browsers cannot add headers to, can send it
+// as the access_token parameter instead
+func AccessToken(r *http.Request) string {
+	if token := r.Header.Get("X-Access-Token"); token != "" {
+		return token
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+
+	return ""
+}
+
 func defaultDeniedHostHandler(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "unauthorized", http.StatusUnauthorized)
 }
@@ -64,6 +102,25 @@ func (a *AuthRequired) isWhitelisted(addr string) (bool, error) {
 	return false, nil
 }
 
+// passwordChangeRoutes are the only routes of accounts which have to change
+// their password
+var passwordChangeRoutes = map[string]bool{
+	"GET /api/account/me":          true,
+	"PUT /api/account/me/password": true,
+	"POST /account/changepassword": true,
+}
+
+// passwordChangeAllowed reports whether the request is allowed for the
+// user; accounts with a forced password change may only change it
+func (a *AuthRequired) passwordChangeAllowed(user string, r *http.Request) bool {
+	acct, err := a.manager.Account(user)
+	if err != nil || acct == nil || !acct.ForcePasswordChange {
+		return true
+	}
+
+	return passwordChangeRoutes[r.Method+" "+r.URL.Path]
+}
+
 func (a *AuthRequired) handleRequest(w http.ResponseWriter, r *http.Request) error {
 	whitelisted, err := a.isWhitelisted(r.RemoteAddr)
 	if err != nil {
@@ -78,22 +135,42 @@ func (a *AuthRequired) handleRequest(w http.ResponseWriter, r *http.Request) err
 	// service key takes priority
 	serviceKey := r.Header.Get("X-Service-Key")
 	if serviceKey != "" {
-		if err := a.manager.VerifyServiceKey(serviceKey); err == nil {
+		err := a.manager.VerifyServiceKey(serviceKey)
+		switch err {
+		case nil:
 			valid = true
+			if err := a.manager.RecordServiceKeyUsage(serviceKey, r.RemoteAddr, r.Method, r.URL.Path); err != nil {
+				logger.Errorf("error recording service key usage: %s", err)
+			}
+		case manager.ErrServiceKeyExpired:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return fmt.Errorf("expired service key %s", r.RemoteAddr)
 		}
 	} else { // check for authHeader
-		authHeader := r.Header.Get("X-Access-Token")
+		authHeader := AccessToken(r)
 		parts := strings.Split(authHeader, ":")
 		if len(parts) == 2 {
 			// validate
 			user := parts[0]
 			token := parts[1]
-			if err := a.manager.VerifyAuthToken(user, token); err == nil {
+			err := a.manager.VerifyAuthToken(user, token)
+			switch err {
+			case nil:
+				if !a.passwordChangeAllowed(user, r) {
+					http.Error(w, manager.ErrPasswordChangeRequired.Error(), http.StatusForbidden)
+					return fmt.Errorf("password change required for %s", user)
+				}
+
 				valid = true
+				SetUsername(r, user)
 				// set current user
 				session, _ := a.manager.Store().Get(r, a.manager.StoreKey())
 				session.Values["username"] = user
 				session.Save(r, w)
+			case manager.ErrAuthTokenExpired:
+				// tell clients to login again rather than a generic denial
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return fmt.Errorf("expired token %s", r.RemoteAddr)
 			}
 		}
 	}
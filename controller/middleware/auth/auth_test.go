@@ -4,6 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/controller/mock_test"
 )
 
 var testHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -18,7 +22,7 @@ func TestNoAuthToken(t *testing.T) {
 	a.Handler(testHandler).ServeHTTP(res, req)
 
 	if res.Code != http.StatusUnauthorized {
-		t.Fatalf("expected 401; got %s", res.Code)
+		t.Fatalf("expected 401; got %d", res.Code)
 	}
 }
 
@@ -55,3 +59,113 @@ func TestWhiteListInvalid(t *testing.T) {
 		t.Fatalf("expected 401; got %d", res.Code)
 	}
 }
+
+func TestAccessToken(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/ws/containers?access_token=admin:abc", nil)
+	if token := AccessToken(req); token != "" {
+		t.Fatalf("expected the parameter to be ignored without a websocket handshake; got %s", token)
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	if token := AccessToken(req); token != "admin:abc" {
+		t.Fatalf("expected the token of the parameter; got %s", token)
+	}
+
+	req.Header.Set("X-Access-Token", "admin:def")
+	if token := AccessToken(req); token != "admin:def" {
+		t.Fatalf("expected the header to take priority; got %s", token)
+	}
+}
+
+// passwordChangeManager is a manager whose accounts have to change their
+// password
+type passwordChangeManager struct {
+	mock_test.MockManager
+}
+
+func (m passwordChangeManager) Account(username string) (*auth.Account, error) {
+	return &auth.Account{Username: username, ForcePasswordChange: true}, nil
+}
+
+func (m passwordChangeManager) Store() *sessions.CookieStore {
+	return sessions.NewCookieStore([]byte("testing"))
+}
+
+func (m passwordChangeManager) StoreKey() string {
+	return "shipyard"
+}
+
+func TestForcePasswordChange(t *testing.T) {
+	a := NewAuthRequired(passwordChangeManager{}, []string{})
+
+	for _, test := range []struct {
+		method, path string
+		code         int
+	}{
+		{"GET", "/api/containers", http.StatusForbidden},
+		{"GET", "/api/account/me", http.StatusOK},
+		{"PUT", "/api/account/me/password", http.StatusOK},
+		{"POST", "/account/changepassword", http.StatusOK},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		req.Header.Set("X-Access-Token", "admin:abc")
+
+		a.Handler(testHandler).ServeHTTP(res, req)
+
+		if res.Code != test.code {
+			t.Fatalf("expected %d for %s %s; got %d", test.code, test.method, test.path, res.Code)
+		}
+	}
+}
+
+// sessionManager is a manager verifying every token with a session store
+type sessionManager struct {
+	mock_test.MockManager
+}
+
+func (m sessionManager) Store() *sessions.CookieStore {
+	return sessions.NewCookieStore([]byte("testing"))
+}
+
+func (m sessionManager) StoreKey() string {
+	return "shipyard"
+}
+
+func TestUsername(t *testing.T) {
+	var username string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username = Username(r)
+	})
+
+	for _, test := range []struct {
+		name      string
+		whitelist []string
+		header    string
+		value     string
+		username  string
+	}{
+		{"verified token", []string{}, "X-Access-Token", "alice:abc", "alice"},
+		{"whitelisted address", []string{"0.0.0.0/0"}, "X-Access-Token", "admin:forged", ""},
+		{"service key", []string{}, "X-Service-Key", "key", ""},
+	} {
+		username = "unset"
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/containers", nil)
+		req.RemoteAddr = "10.0.0.1:4242"
+		req.Header.Set(test.header, test.value)
+		if test.header == "X-Service-Key" {
+			// service keys are checked before the access token
+			req.Header.Set("X-Access-Token", "admin:forged")
+		}
+
+		NewAuthRequired(sessionManager{}, test.whitelist).Handler(handler).ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200; got %d", test.name, res.Code)
+		}
+		if username != test.username {
+			t.Fatalf("%s: expected username %q; got %q", test.name, test.username, username)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package requestlog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/negroni"
+	mAuth "github.com/shipyard/shipyard/controller/middleware/auth"
+	"github.com/shipyard/shipyard/utils"
+)
+
+const (
+	// RequestIDHeader carries the id of a request; ids sent by clients or
+	// load balancers are kept so requests can be followed between them
+	RequestIDHeader = "X-Request-Id"
+)
+
+var (
+	validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+	// quietPaths are polled by load balancers and scrapers; they are
+	// only logged at debug level
+	quietPaths = map[string]bool{
+		"/healthz": true,
+		"/readyz":  true,
+		"/metrics": true,
+	}
+)
+
+// RequestLogger logs every request once it is served with its id, which
+// is also returned to the client and passed on to the next handlers
+type RequestLogger struct {
+	now func() time.Time
+}
+
+func NewRequestLogger() *RequestLogger {
+	return &RequestLogger{
+		now: time.Now,
+	}
+}
+
+// RequestID returns the id of the request set by the RequestLogger
+func RequestID(r *http.Request) string {
+	return r.Header.Get(RequestIDHeader)
+}
+
+// Entry returns a log entry with the id of the request for the handlers
+// of the request to log with
+func Entry(r *http.Request) *log.Entry {
+	return log.WithField("request_id", RequestID(r))
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// username returns the user of the access token of the request; requests
+// with a service key or without a token have none
+func username(r *http.Request) string {
+	parts := strings.SplitN(mAuth.AccessToken(r), ":", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	return parts[0]
+}
+
+func (l *RequestLogger) HandlerFuncWithNext(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := l.now()
+
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	r.Header.Set(RequestIDHeader, id)
+	w.Header().Set(RequestIDHeader, id)
+
+	rw, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+
+	// the path is read before the handlers, which may rewrite it
+	path := r.URL.Path
+
+	next(rw, r)
+
+	status := rw.Status()
+	if status == 0 {
+		// hijacked connections (i.e. websockets and exec) write their
+		// status themselves
+		status = http.StatusOK
+	}
+
+	entry := log.WithFields(log.Fields{
+		"request_id":  id,
+		"method":      r.Method,
+		"path":        path,
+		"status":      status,
+		"latency_ms":  float64(l.now().Sub(start)) / float64(time.Millisecond),
+		"user":        username(r),
+		"remote_addr": utils.RemoteIP(r.RemoteAddr),
+		"size":        rw.Size(),
+	})
+
+	if quietPaths[path] {
+		entry.Debug("request")
+		return
+	}
+
+	entry.Info("request")
+}
@@ -0,0 +1,83 @@
+package requestlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	defer func() {
+		log.SetOutput(out)
+		log.SetFormatter(&log.TextFormatter{})
+	}()
+
+	start := time.Now()
+	calls := 0
+	l := &RequestLogger{now: func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls-1) * 25 * time.Millisecond)
+	}}
+
+	var seen string
+	req := httptest.NewRequest("POST", "/api/stacks?x=1", nil)
+	req.Header.Set("X-Access-Token", "alice:token")
+	res := httptest.NewRecorder()
+	l.HandlerFuncWithNext(res, req, func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	id := res.Header().Get(RequestIDHeader)
+	if id == "" || id != seen {
+		t.Fatalf("expected the request id to be returned and passed on; received %q and %q", id, seen)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a json log entry: %s: %s", err, buf.String())
+	}
+
+	for field, expected := range map[string]interface{}{
+		"request_id": id,
+		"method":     "POST",
+		"path":       "/api/stacks",
+		"status":     float64(http.StatusCreated),
+		"latency_ms": float64(25),
+		"user":       "alice",
+	} {
+		if entry[field] != expected {
+			t.Fatalf("expected %s to be %v; received %v", field, expected, entry[field])
+		}
+	}
+}
+
+func TestRequestLoggerKeepsRequestID(t *testing.T) {
+	l := NewRequestLogger()
+	next := func(w http.ResponseWriter, r *http.Request) {}
+
+	for sent, kept := range map[string]bool{
+		"lb-1234.abc": true,
+		"":            false,
+		"bad id\n":    false,
+	} {
+		req := httptest.NewRequest("GET", "/api/nodes", nil)
+		req.Header.Set(RequestIDHeader, sent)
+		res := httptest.NewRecorder()
+		l.HandlerFuncWithNext(res, req, next)
+
+		id := res.Header().Get(RequestIDHeader)
+		if (id == sent) != kept || id == "" {
+			t.Fatalf("expected %q to be kept=%v; received %q", sent, kept, id)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package mock_test
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
 	"github.com/gorilla/sessions"
 	"github.com/samalba/dockerclient"
 	"github.com/shipyard/shipyard"
 	"github.com/shipyard/shipyard/auth"
+	"github.com/shipyard/shipyard/compose"
+	"github.com/shipyard/shipyard/controller/datastore"
 	"github.com/shipyard/shipyard/controller/manager"
+	"github.com/shipyard/shipyard/controller/preflight"
 	"github.com/shipyard/shipyard/dockerhub"
+	"github.com/shipyard/shipyard/notification"
 	registry "github.com/shipyard/shipyard/registry/v1"
 )
 
@@ -16,10 +26,119 @@ func (m MockManager) Container(id string) (*dockerclient.ContainerInfo, error) {
 	return getTestContainerInfo(TestContainerId, TestContainerName, TestContainerImage), nil
 }
 
+func (m MockManager) ContainerLogs(id string, opts *manager.LogOptions) (*manager.LogStream, error) {
+	if id != TestContainerId {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	return manager.NewLogStream(TestContainerName, ioutil.NopCloser(bytes.NewReader(TestContainerLogs)), false), nil
+}
+
 func (m MockManager) DockerClient() *dockerclient.DockerClient {
 	return nil
 }
 
+func (m MockManager) Health() *manager.Health {
+	return &manager.Health{
+		Status: manager.HealthUp,
+		Dependencies: map[string]*manager.DependencyHealth{
+			manager.HealthDatastore: {Status: manager.HealthUp},
+			manager.HealthDocker:    {Status: manager.HealthUp},
+		},
+	}
+}
+
+func (m MockManager) ClusterSettings() *manager.ClusterSettings {
+	return &manager.ClusterSettings{
+		DockerURL: "tcp://127.0.0.1:2375",
+	}
+}
+
+func (m MockManager) UpdateCluster(settings *manager.ClusterSettings, username string) error {
+	if settings.DockerURL == "" {
+		return manager.ErrDockerURLRequired
+	}
+
+	return nil
+}
+
+func (m MockManager) Clusters() ([]*shipyard.Cluster, error) {
+	return []*shipyard.Cluster{
+		{Name: shipyard.DefaultClusterName, DockerURL: "tcp://127.0.0.1:2375"},
+		TestCluster,
+	}, nil
+}
+
+func (m MockManager) Cluster(name string) (*shipyard.Cluster, error) {
+	if name != TestCluster.Name {
+		return nil, manager.ErrClusterDoesNotExist
+	}
+
+	return TestCluster, nil
+}
+
+func (m MockManager) AddCluster(cluster *shipyard.Cluster, username string) error {
+	if cluster.DockerURL == "" {
+		return manager.ErrDockerURLRequired
+	}
+
+	if cluster.Name == TestCluster.Name {
+		return manager.ErrClusterExists
+	}
+
+	return nil
+}
+
+func (m MockManager) ReplaceCluster(cluster *shipyard.Cluster, username string) error {
+	if cluster.Name != TestCluster.Name {
+		return manager.ErrClusterDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) RemoveCluster(name, username string) error {
+	if name == shipyard.DefaultClusterName {
+		return manager.ErrDefaultCluster
+	}
+
+	if name != TestCluster.Name {
+		return manager.ErrClusterDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) ForCluster(name string) (manager.Manager, error) {
+	if name != "" && name != shipyard.DefaultClusterName && name != TestCluster.Name {
+		return nil, manager.ErrClusterDoesNotExist
+	}
+
+	return m, nil
+}
+
+func (m MockManager) LaunchDebugContainer(launch *manager.DebugLaunch, username string) (*manager.DebugContainer, error) {
+	if launch.Container == "" {
+		return nil, manager.ErrDebugTargetRequired
+	}
+
+	if launch.Container != TestContainerId {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	if launch.Image != "" && launch.Image != TestDebugImage {
+		return nil, manager.ErrDebugImageNotApproved
+	}
+
+	return &manager.DebugContainer{
+		ID:        "debug-" + TestContainerId,
+		Image:     TestDebugImage,
+		Container: TestContainerId,
+		Username:  username,
+		ExpiresAt: time.Now().Add(manager.DefaultDebugTTL),
+	}, nil
+}
+
 func (m MockManager) SaveServiceKey(key *auth.ServiceKey) error {
 	return nil
 }
@@ -32,28 +151,422 @@ func (m MockManager) SaveEvent(event *shipyard.Event) error {
 	return nil
 }
 
-func (m MockManager) Events(limit int) ([]*shipyard.Event, error) {
-	return getTestEvents(), nil
+func (m MockManager) Events(query *datastore.EventQuery) ([]*shipyard.Event, error) {
+	events := []*shipyard.Event{}
+	for _, evt := range getTestEvents() {
+		if query.Match(evt) {
+			events = append(events, evt)
+		}
+	}
+
+	return events, nil
+}
+
+func (m MockManager) WatchContainers(done <-chan struct{}) <-chan *manager.ContainerDelta {
+	deltas := make(chan *manager.ContainerDelta)
+	go func() {
+		defer close(deltas)
+		for _, action := range []string{manager.ContainerStarted, manager.ContainerDied} {
+			select {
+			case deltas <- &manager.ContainerDelta{Action: action, ID: TestContainerId}:
+			case <-done:
+				return
+			}
+		}
+		<-done
+	}()
+
+	return deltas
+}
+
+func (m MockManager) EventStream(done <-chan struct{}) (<-chan *shipyard.Event, error) {
+	events := make(chan *shipyard.Event)
+	go func() {
+		defer close(events)
+		for _, evt := range getTestEvents() {
+			select {
+			case events <- evt:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return events, nil
 }
 
 func (m MockManager) PurgeEvents() error {
 	return nil
 }
 
+func (m MockManager) VerifyAuditLog() (*shipyard.AuditVerification, error) {
+	return &shipyard.AuditVerification{Valid: true}, nil
+}
+
+func (m MockManager) SaveAuditEntry(entry *shipyard.AuditEntry) error {
+	return nil
+}
+
+func (m MockManager) AuditEntries(query *datastore.AuditQuery) ([]*shipyard.AuditEntry, error) {
+	entries := []*shipyard.AuditEntry{}
+	if query.Match(TestAuditEntry) {
+		entries = append(entries, TestAuditEntry)
+	}
+
+	return entries, nil
+}
+
+func (m MockManager) Freezes() ([]*shipyard.Freeze, error) {
+	return []*shipyard.Freeze{
+		TestFreeze,
+	}, nil
+}
+
+func (m MockManager) Freeze(id string) (*shipyard.Freeze, error) {
+	return TestFreeze, nil
+}
+
+func (m MockManager) SaveFreeze(freeze *shipyard.Freeze) error {
+	return nil
+}
+
+func (m MockManager) DeleteFreeze(id string) error {
+	return nil
+}
+
+// CheckFreeze refuses changes to the environments of the test freeze for
+// everyone but admin
+func (m MockManager) CheckFreeze(username, environment, change string) error {
+	if username != "admin" && TestFreeze.Applies(environment) {
+		return &manager.FreezeError{Freeze: TestFreeze}
+	}
+
+	return nil
+}
+
+func (m MockManager) ClientRules() ([]*shipyard.ClientRule, error) {
+	return []*shipyard.ClientRule{
+		TestClientRule,
+	}, nil
+}
+
+func (m MockManager) ClientRule(id string) (*shipyard.ClientRule, error) {
+	return TestClientRule, nil
+}
+
+func (m MockManager) SaveClientRule(rule *shipyard.ClientRule) error {
+	return nil
+}
+
+func (m MockManager) DeleteClientRule(id string) error {
+	return nil
+}
+
+func (m MockManager) Jobs() ([]*shipyard.Job, error) {
+	return []*shipyard.Job{
+		TestJob,
+	}, nil
+}
+
+func (m MockManager) Job(id string) (*shipyard.Job, error) {
+	return TestJob, nil
+}
+
+func (m MockManager) SaveJob(job *shipyard.Job) error {
+	return nil
+}
+
+func (m MockManager) DeleteJob(id string) error {
+	return nil
+}
+
+func (m MockManager) RunJob(id, username string) (*shipyard.JobRun, error) {
+	return TestJob.LastRun, nil
+}
+
+// Query returns the fields of the test container
+func (m MockManager) Query(q *manager.Query, username string) ([]map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for _, f := range q.Fields {
+		switch f {
+		case "id":
+			result[f] = TestContainerId
+		case "name":
+			result[f] = TestContainerName
+		}
+	}
+
+	return []map[string]interface{}{result}, nil
+}
+
+// CheckClient refuses version 1.0.0 of the client of the test rule on its
+// routes
+func (m MockManager) CheckClient(userAgent, path string) error {
+	if userAgent == TestClientRule.Client+"/1.0.0" && strings.HasPrefix(path, TestClientRule.RoutePrefix) {
+		return &manager.ClientError{Rule: TestClientRule, Version: "1.0.0"}
+	}
+
+	return nil
+}
+
+func (m MockManager) Stacks() ([]*shipyard.Stack, error) {
+	return []*shipyard.Stack{
+		TestStack,
+	}, nil
+}
+
+func (m MockManager) Stack(name string) (*shipyard.Stack, error) {
+	if name != TestStack.Name {
+		return nil, manager.ErrStackDoesNotExist
+	}
+
+	return TestStack, nil
+}
+
+func (m MockManager) DeployStack(name string, data []byte, username string, release *shipyard.Release) (*shipyard.Stack, error) {
+	if name == TestStack.Name {
+		return nil, manager.ErrStackExists
+	}
+
+	f, err := compose.Parse(data)
+	if err != nil {
+		return nil, &manager.ComposeError{Err: err}
+	}
+
+	order, _ := f.Order()
+	return &shipyard.Stack{
+		Name:      name,
+		Compose:   string(data),
+		Services:  order,
+		CreatedBy: username,
+	}, nil
+}
+
+func (m MockManager) RedeployStack(name, username string, release *shipyard.Release) (*shipyard.Stack, error) {
+	if release != nil && len(release.Links) > 0 && !strings.HasPrefix(release.Links[0], "http") {
+		return nil, manager.ErrInvalidReleaseLink
+	}
+	if name == TestStackLock.Stack {
+		return nil, &manager.StackLockedError{Lock: TestStackLock}
+	}
+	if name == TestDeployment.Stack {
+		return nil, &manager.SmokeTestError{Test: TestDeployment.SmokeTests[0].Name, Err: errors.New(TestDeployment.SmokeTests[0].Output)}
+	}
+	return m.Stack(name)
+}
+
+func (m MockManager) Deployments(stack string) ([]*shipyard.Deployment, error) {
+	if stack != TestDeployment.Stack {
+		return []*shipyard.Deployment{}, nil
+	}
+
+	return []*shipyard.Deployment{TestDeployment}, nil
+}
+
+func (m MockManager) RemoveStack(name, username string) error {
+	_, err := m.Stack(name)
+	return err
+}
+
+func (m MockManager) StopStack(name string, timeout time.Duration, username string) (*shipyard.StackOperation, error) {
+	return m.controlStack(name, manager.StackStop, timeout)
+}
+
+func (m MockManager) StartStack(name string, timeout time.Duration, username string) (*shipyard.StackOperation, error) {
+	return m.controlStack(name, manager.StackStart, timeout)
+}
+
+func (m MockManager) StackTop(name, psArgs string) (*manager.StackTop, error) {
+	if name != TestStack.Name {
+		return nil, manager.ErrStackDoesNotExist
+	}
+
+	return &manager.StackTop{
+		Stack:  TestStack.Name,
+		Titles: []string{"UID", "PID", "CMD"},
+		Processes: []*manager.StackProcess{
+			{Container: TestContainerId, Name: "shop_web_1", Service: "web", Values: []string{"root", "4242", "nginx: master process"}},
+		},
+		Containers: 2,
+		Errors: []*manager.StackTopError{
+			{Container: "web-2", Name: "shop_web_2", Service: "web", Error: "no such container"},
+		},
+	}, nil
+}
+
+func (m MockManager) controlStack(name, action string, timeout time.Duration) (*shipyard.StackOperation, error) {
+	if timeout < 0 || timeout > 10*time.Minute {
+		return nil, manager.ErrInvalidStackStepTimeout
+	}
+	if name == TestDeployment.Stack {
+		op := &shipyard.StackOperation{
+			Stack:  name,
+			Action: action,
+			Status: shipyard.StackStepFailed,
+			Steps: []*shipyard.StackStep{
+				{Service: "web", Containers: []string{"flaky-web-1"}, Status: shipyard.StackStepTimedOut, Error: "timed out after 30s"},
+			},
+		}
+		return op, &manager.StackStepError{Action: action, Service: "web", Err: errors.New("timed out after 30s")}
+	}
+
+	stack, err := m.Stack(name)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &shipyard.StackOperation{Stack: name, Action: action, Status: shipyard.StackStepDone}
+	for _, service := range stack.Services {
+		op.Steps = append(op.Steps, &shipyard.StackStep{Service: service, Containers: []string{}, Status: shipyard.StackStepDone})
+	}
+
+	return op, nil
+}
+
+func (m MockManager) StartCanary(stack string, opts *manager.CanaryOptions, username string) (*shipyard.Canary, error) {
+	if _, err := m.Stack(stack); err != nil {
+		return nil, err
+	}
+
+	if opts.Percent < 1 || opts.Percent > 99 {
+		return nil, manager.ErrCanaryPercent
+	}
+
+	c := *TestCanary
+	c.Image = opts.Image
+	c.Percent = opts.Percent
+	c.StartedBy = username
+	return &c, nil
+}
+
+func (m MockManager) Canary(stack string) (*shipyard.Canary, error) {
+	if stack != TestCanary.Stack {
+		return nil, manager.ErrCanaryDoesNotExist
+	}
+	return TestCanary, nil
+}
+
+func (m MockManager) DecideCanary(stack, action, username string) error {
+	if action != manager.CanaryPromote && action != manager.CanaryRollback {
+		return manager.ErrCanaryDecision
+	}
+	_, err := m.Canary(stack)
+	return err
+}
+
+func (m MockManager) ExportStack(name string) (*shipyard.TemplateBundle, error) {
+	stack, err := m.Stack(name)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &shipyard.Template{Name: stack.Name, Compose: stack.Compose}
+	return template.Bundle(), nil
+}
+
+func (m MockManager) Templates() ([]*shipyard.Template, error) {
+	return []*shipyard.Template{
+		TestTemplate,
+	}, nil
+}
+
+func (m MockManager) Template(name string) (*shipyard.Template, error) {
+	if name != TestTemplate.Name {
+		return nil, manager.ErrTemplateDoesNotExist
+	}
+
+	return TestTemplate, nil
+}
+
+func (m MockManager) ImportTemplate(bundle *shipyard.TemplateBundle, username string, replace bool) (*shipyard.Template, error) {
+	if bundle.Version != shipyard.TemplateBundleVersion {
+		return nil, &manager.TemplateError{Reason: "unsupported bundle version"}
+	}
+
+	if bundle.Metadata.Name == TestTemplate.Name && !replace {
+		return nil, manager.ErrTemplateExists
+	}
+
+	template := bundle.Template()
+	template.ImportedBy = username
+	return template, nil
+}
+
+func (m MockManager) ExportTemplate(name string) (*shipyard.TemplateBundle, error) {
+	template, err := m.Template(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.Bundle(), nil
+}
+
+func (m MockManager) DeleteTemplate(name string) error {
+	_, err := m.Template(name)
+	return err
+}
+
+func (m MockManager) DeployTemplate(name, stackName string, values map[string]string, ttl time.Duration, username string) (*shipyard.Stack, error) {
+	if _, err := m.Template(name); err != nil {
+		return nil, err
+	}
+
+	if ttl < 0 || ttl > manager.MaxTTL {
+		return nil, manager.ErrInvalidTTL
+	}
+
+	stack := &shipyard.Stack{
+		Name:      stackName,
+		Services:  []string{"web"},
+		CreatedBy: username,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		stack.ExpiresAt = stack.CreatedAt.Add(ttl)
+	}
+
+	return stack, nil
+}
+
 func (m MockManager) ServiceKey(key string) (*auth.ServiceKey, error) {
+	if key == TestScopedServiceKey.Key {
+		return TestScopedServiceKey, nil
+	}
 	return TestServiceKey, nil
 }
 
+func (m MockManager) ServiceKeyByID(id string) (*auth.ServiceKey, error) {
+	for _, k := range []*auth.ServiceKey{TestServiceKey, TestScopedServiceKey} {
+		if auth.KeyID(k.Key) == id {
+			return k, nil
+		}
+	}
+	return nil, manager.ErrServiceKeyDoesNotExist
+}
+
 func (m MockManager) ServiceKeys() ([]*auth.ServiceKey, error) {
 	return []*auth.ServiceKey{
 		TestServiceKey,
 	}, nil
 }
 
-func (m MockManager) Accounts() ([]*auth.Account, error) {
-	return []*auth.Account{
-		TestAccount,
-	}, nil
+func (m MockManager) ServiceKeyUsage(key string) (*auth.ServiceKeyUsage, error) {
+	return TestServiceKeyUsage, nil
+}
+
+func (m MockManager) RecordServiceKeyUsage(key, remoteAddr, method, path string) error {
+	return nil
+}
+
+func (m MockManager) Accounts(query *datastore.AccountQuery) ([]*auth.Account, error) {
+	accounts := []*auth.Account{}
+	for _, acct := range []*auth.Account{TestAccount} {
+		if query.Match(acct) {
+			accounts = append(accounts, acct)
+		}
+	}
+
+	return accounts, nil
 }
 
 func (m MockManager) Account(username string) (*auth.Account, error) {
@@ -64,10 +577,124 @@ func (m MockManager) SaveAccount(account *auth.Account) error {
 	return nil
 }
 
+func (m MockManager) EnrollTOTP(username string) (*manager.TOTPEnrollment, error) {
+	if username != TestAccount.Username {
+		return nil, manager.ErrAccountDoesNotExist
+	}
+
+	return &manager.TOTPEnrollment{
+		Secret: TestTOTPSecret,
+		URI:    auth.TOTPProvisioningURI("Shipyard", username, TestTOTPSecret),
+	}, nil
+}
+
+func (m MockManager) ConfirmTOTP(username, code string) error {
+	if code != TestTOTPCode {
+		return manager.ErrInvalidTOTPCode
+	}
+
+	return nil
+}
+
+func (m MockManager) DisableTOTP(username, actor string) error {
+	return nil
+}
+
+func (m MockManager) CheckTOTP(username, code string) error {
+	return nil
+}
+
+func (m MockManager) CheckLogin(username, addr string) error {
+	if username == TestLockedUsername {
+		return &manager.AccountLockedError{Until: time.Now().Add(time.Minute)}
+	}
+
+	return nil
+}
+
+func (m MockManager) LoginFailed(username, addr string) error {
+	return nil
+}
+
+func (m MockManager) LoginSucceeded(username string) error {
+	return nil
+}
+
+func (m MockManager) UnlockAccount(username string) error {
+	return nil
+}
+
 func (m MockManager) DeleteAccount(account *auth.Account) error {
 	return nil
 }
 
+func (m MockManager) ExportAccount(username string) (*shipyard.AccountExport, error) {
+	if username != TestAccount.Username {
+		return nil, manager.ErrAccountDoesNotExist
+	}
+
+	return &shipyard.AccountExport{
+		Account: TestAccount,
+		Events:  getTestEvents(),
+	}, nil
+}
+
+func (m MockManager) Backup() (*shipyard.Backup, error) {
+	return &shipyard.Backup{
+		Version:     shipyard.BackupVersion,
+		Datastore:   "bolt",
+		Accounts:    []*shipyard.BackupAccount{{Account: TestAccount}},
+		Roles:       []*auth.ACL{},
+		ServiceKeys: []*auth.ServiceKey{TestServiceKey},
+		WebhookKeys: []*dockerhub.WebhookKey{TestWebhookKey},
+		Registries:  []*shipyard.Registry{TestRegistry},
+	}, nil
+}
+
+func (m MockManager) Restore(backup *shipyard.Backup, dryRun bool) (*manager.RestoreResult, error) {
+	if backup.Version != shipyard.BackupVersion {
+		return nil, &manager.BackupError{Reason: "unsupported backup version"}
+	}
+
+	return &manager.RestoreResult{
+		DryRun: dryRun,
+		Changes: []*manager.RestoreChange{
+			{Kind: manager.BackupAccount, Name: TestAccount.Username, Action: manager.RestoreUnchanged},
+		},
+	}, nil
+}
+
+func (m MockManager) AnonymizeAccount(username string) (string, error) {
+	return "anonymous-0123456789ab", nil
+}
+
+func (m MockManager) Housekeeping(username string) *manager.HousekeepingReport {
+	return &manager.HousekeepingReport{
+		Datastore: "bolt",
+		Tasks: []*manager.HousekeepingTask{
+			{Name: "expired-tokens", Removed: 1, Details: []string{TestAccount.Username + ": 1"}},
+		},
+		Problems: []string{},
+		Hints:    []string{},
+	}
+}
+
+func (m MockManager) CleanupReport() (*manager.CleanupReport, error) {
+	return &manager.CleanupReport{
+		Items: []*manager.CleanupItem{
+			{Kind: manager.CleanupWebhookKey, ID: TestWebhookKey.ID, Name: TestWebhookKey.Image},
+		},
+	}, nil
+}
+
+func (m MockManager) Remediate(kind, id, username string) error {
+	if kind != manager.CleanupWebhookKey || id != TestWebhookKey.ID {
+		return manager.ErrCleanupItemDoesNotExist
+	}
+
+	return nil
+}
+
 func (m MockManager) Roles() ([]*auth.ACL, error) {
 	return auth.DefaultACLs(), nil
 }
@@ -77,6 +704,37 @@ func (m MockManager) Role(name string) (*auth.ACL, error) {
 	return roles[0], err
 }
 
+func (m MockManager) SaveRole(role *auth.ACL) error {
+	return nil
+}
+
+func (m MockManager) DeleteRole(name string) error {
+	return nil
+}
+
+func (m MockManager) HasPermission(username, permission string) (bool, error) {
+	return username == TestAccount.Username, nil
+}
+
+func (m MockManager) Actions(username, resource, id string) ([]*manager.Action, error) {
+	if resource != manager.ActionResourceContainer {
+		return nil, manager.ErrUnknownActionResource
+	}
+
+	if id != TestContainerId {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	return []*manager.Action{
+		{Name: "stop", Method: "POST", Path: "/containers/" + id + "/stop"},
+	}, nil
+}
+
+func (m MockManager) AccessReport() (*auth.AccessReport, error) {
+	accounts, _ := m.Accounts(&datastore.AccountQuery{})
+	return auth.NewAccessReport(accounts, auth.DefaultACLs()), nil
+}
+
 func (m MockManager) Authenticate(username, password string) (bool, error) {
 	return false, nil
 }
@@ -89,30 +747,135 @@ func (m MockManager) VerifyAuthToken(username, token string) error {
 	return nil
 }
 
+func (m MockManager) AuthTokens(username string) ([]*auth.AuthToken, error) {
+	if username != TestAccount.Username {
+		return nil, manager.ErrAccountDoesNotExist
+	}
+
+	return []*auth.AuthToken{
+		{
+			ID:        auth.TokenID("test-token"),
+			UserAgent: "test-agent",
+		},
+	}, nil
+}
+
+func (m MockManager) RevokeAuthToken(username, id string) error {
+	if id != auth.TokenID("test-token") {
+		return manager.ErrAuthTokenDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) ImportAccounts(accounts []*auth.Account) manager.AccountImportResult {
+	result := manager.AccountImportResult{
+		Imported: []string{},
+		Skipped:  []string{},
+		Errors:   []string{},
+	}
+
+	for _, a := range accounts {
+		if a.Username == TestAccount.Username {
+			result.Skipped = append(result.Skipped, a.Username)
+			continue
+		}
+
+		result.Imported = append(result.Imported, a.Username)
+	}
+
+	return result
+}
+
+func (m MockManager) RevokeAuthTokens(username string) error {
+	return nil
+}
+
+func (m MockManager) RefreshAuthToken(username, token string) (*auth.AuthToken, error) {
+	if token != "test-token" {
+		return nil, manager.ErrInvalidAuthToken
+	}
+
+	return &auth.AuthToken{
+		Token:     "refreshed-token",
+		UserAgent: "test-agent",
+	}, nil
+}
+
 func (m MockManager) VerifyServiceKey(key string) error {
 	return nil
 }
 
-func (m MockManager) NewServiceKey(description string) (*auth.ServiceKey, error) {
-	return nil, nil
+func (m MockManager) NewServiceKey(key *auth.ServiceKey) (*auth.ServiceKey, error) {
+	for _, scope := range key.Scopes {
+		if !auth.ValidScope(scope) {
+			return nil, manager.ErrInvalidServiceKeyScope
+		}
+	}
+
+	k := *key
+	k.Key = TestServiceKey.Key
+	return &k, nil
 }
 
 func (m MockManager) ChangePassword(username, password string) error {
 	return nil
 }
 
+func (m MockManager) UpdateProfile(username string, profile *auth.Account) (*auth.Account, error) {
+	if profile.Email != "" && !strings.Contains(profile.Email, "@") {
+		return nil, manager.ErrInvalidEmail
+	}
+
+	account := *TestAccount
+	account.Username = username
+	account.FirstName = profile.FirstName
+	account.LastName = profile.LastName
+	account.Email = profile.Email
+
+	return &account, nil
+}
+
+func (m MockManager) ChangeOwnPassword(username, current, password string) error {
+	if current != TestAccount.Password {
+		return manager.ErrInvalidPassword
+	}
+
+	return nil
+}
+
+func (m MockManager) ResetPassword(username, actor string) (*manager.PasswordReset, error) {
+	return &manager.PasswordReset{Username: username, Token: TestPasswordResetToken}, nil
+}
+
+func (m MockManager) CompletePasswordReset(username, token, password string) error {
+	if token != TestPasswordResetToken {
+		return manager.ErrInvalidResetToken
+	}
+
+	return nil
+}
+
 func (m MockManager) WebhookKeys() ([]*dockerhub.WebhookKey, error) {
 	return []*dockerhub.WebhookKey{
 		TestWebhookKey,
 	}, nil
 }
 
-func (m MockManager) NewWebhookKey(image string) (*dockerhub.WebhookKey, error) {
-	return nil, nil
+func (m MockManager) NewWebhookKey(image, branch, maxSeverity string) (*dockerhub.WebhookKey, error) {
+	if maxSeverity != "" && !shipyard.ValidSeverity(maxSeverity) {
+		return nil, shipyard.ErrInvalidSeverity
+	}
+
+	return &dockerhub.WebhookKey{Image: image, Branch: branch, MaxSeverity: maxSeverity}, nil
 }
 
 func (m MockManager) WebhookKey(key string) (*dockerhub.WebhookKey, error) {
-	return nil, nil
+	if key != TestWebhookKey.Key {
+		return nil, manager.ErrWebhookKeyDoesNotExist
+	}
+
+	return TestWebhookKey, nil
 }
 
 func (m MockManager) SaveWebhookKey(key *dockerhub.WebhookKey) error {
@@ -148,6 +911,33 @@ func (m MockManager) Registry(name string) (*shipyard.Registry, error) {
 func (m MockManager) RemoveRegistry(registry *shipyard.Registry) error {
 	return nil
 }
+
+func (m MockManager) ImageScan(registryID, repo, tag string) (*shipyard.ImageScan, error) {
+	if shipyard.ImageScanID(registryID, repo, tag) != TestImageScan.ID {
+		return nil, manager.ErrImageScanDoesNotExist
+	}
+
+	return TestImageScan, nil
+}
+
+func (m MockManager) ScanImage(registryID, repo, tag, username string) (*shipyard.ImageScan, error) {
+	if registryID != TestRegistry.ID {
+		return nil, manager.ErrRegistryDoesNotExist
+	}
+
+	return &shipyard.ImageScan{
+		ID:         shipyard.ImageScanID(registryID, repo, tag),
+		Registry:   registryID,
+		Repository: repo,
+		Tag:        tag,
+		Status:     shipyard.ScanRunning,
+		ScannedBy:  username,
+	}, nil
+}
+
+func (m MockManager) CheckImageScan(image, maxSeverity string) error {
+	return nil
+}
 func (m MockManager) RegistryByAddress(addr string) (*shipyard.Registry, error){
 	return nil, nil
 }
@@ -175,6 +965,211 @@ func (m MockManager) Node(name string) (*shipyard.Node, error) {
 	return TestNode, nil
 }
 
+func (m MockManager) CollectInventory() error {
+	return nil
+}
+
+func (m MockManager) NodesMissingPlugin(kind, name string) ([]*shipyard.Node, error) {
+	if kind != shipyard.PluginVolume {
+		return nil, manager.ErrUnknownPluginKind
+	}
+
+	return []*shipyard.Node{
+		TestNode,
+	}, nil
+}
+
+func (m MockManager) Volumes(node string, danglingOnly bool) ([]*shipyard.Volume, error) {
+	return []*shipyard.Volume{
+		TestVolume,
+	}, nil
+}
+
+func (m MockManager) CreateVolume(request *manager.VolumeRequest, username string) (*shipyard.Volume, error) {
+	if request.Node == "" {
+		return nil, manager.ErrVolumeNodeRequired
+	}
+
+	return TestVolume, nil
+}
+
+func (m MockManager) RemoveVolume(node, name, username string) error {
+	if node != TestVolume.Node || name != TestVolume.Name {
+		return manager.ErrVolumeDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) PruneVolumes(node, username string) ([]*shipyard.Volume, error) {
+	return []*shipyard.Volume{
+		TestVolume,
+	}, nil
+}
+
+func (m MockManager) Networks() ([]*dockerclient.NetworkResource, error) {
+	return []*dockerclient.NetworkResource{
+		TestNetwork,
+	}, nil
+}
+
+func (m MockManager) Network(id string) (*dockerclient.NetworkResource, error) {
+	if id != TestNetwork.ID {
+		return nil, manager.ErrNetworkDoesNotExist
+	}
+
+	return TestNetwork, nil
+}
+
+func (m MockManager) CreateNetwork(config *dockerclient.NetworkCreate, username string) (*dockerclient.NetworkCreateResponse, error) {
+	if config.Name == "" {
+		return nil, manager.ErrNetworkNameRequired
+	}
+
+	return &dockerclient.NetworkCreateResponse{ID: TestNetwork.ID}, nil
+}
+
+func (m MockManager) RemoveNetwork(id, username string) error {
+	if id != TestNetwork.ID {
+		return manager.ErrNetworkDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) ConnectNetwork(id, container, username string) error {
+	if id != TestNetwork.ID {
+		return manager.ErrNetworkDoesNotExist
+	}
+
+	if container != TestContainerId {
+		return dockerclient.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m MockManager) DisconnectNetwork(id, container string, force bool, username string) error {
+	return m.ConnectNetwork(id, container, username)
+}
+
+func (m MockManager) NodeDrift() (*shipyard.DriftReport, error) {
+	return &shipyard.DriftReport{
+		Nodes:   []string{TestNode.Name},
+		Unknown: []string{},
+		Drift: []*shipyard.DriftSetting{
+			{Name: "version", Expected: "1.12.6", Outliers: map[string]string{TestNode.Name: "1.11.2"}},
+		},
+	}, nil
+}
+
+func (m MockManager) RegistryMirror() (*shipyard.RegistryMirrorReport, error) {
+	return &shipyard.RegistryMirrorReport{
+		Mirror:       &shipyard.RegistryMirror{URL: "https://mirror.local"},
+		DaemonConfig: map[string][]string{"registry-mirrors": {"https://mirror.local"}},
+		Configured:   []string{},
+		Missing:      []string{TestNode.Name},
+		Unknown:      []string{},
+	}, nil
+}
+
+func (m MockManager) SetRegistryMirror(url, username string) (*shipyard.RegistryMirror, error) {
+	if url == "" {
+		return nil, manager.ErrInvalidRegistryMirror
+	}
+
+	return &shipyard.RegistryMirror{URL: url, SetBy: username}, nil
+}
+
+func (m MockManager) RemoveRegistryMirror(username string) error {
+	return nil
+}
+
+func (m MockManager) ContainerDefaults() (*shipyard.ContainerDefaults, error) {
+	return TestContainerDefaults, nil
+}
+
+func (m MockManager) SetContainerDefaults(defaults *shipyard.ContainerDefaults, username string) (*shipyard.ContainerDefaults, error) {
+	if _, ok := defaults.Env[""]; ok {
+		return nil, manager.ErrInvalidContainerDefaults
+	}
+
+	defaults.UpdatedBy = username
+	return defaults, nil
+}
+
+func (m MockManager) RemoveContainerDefaults(username string) error {
+	return nil
+}
+
+func (m MockManager) ManagedNodes() ([]*shipyard.ManagedNode, error) {
+	return []*shipyard.ManagedNode{
+		TestManagedNode,
+	}, nil
+}
+
+func (m MockManager) ManagedNode(name string) (*shipyard.ManagedNode, error) {
+	if name != TestManagedNode.Name {
+		return nil, manager.ErrNodeDoesNotExist
+	}
+
+	return TestManagedNode, nil
+}
+
+func (m MockManager) AddNode(node *shipyard.ManagedNode, username string) error {
+	if node.Addr == "" {
+		return manager.ErrNodeAddrNeeded
+	}
+
+	if node.Name == TestManagedNode.Name {
+		return manager.ErrNodeExists
+	}
+
+	node.AddedBy = username
+	return nil
+}
+
+func (m MockManager) RemoveNode(name, username string, force bool) error {
+	if name != TestManagedNode.Name {
+		return manager.ErrNodeDoesNotExist
+	}
+
+	if !force {
+		return manager.ErrNodeHasContainers
+	}
+
+	return nil
+}
+
+func (m MockManager) DrainNode(name, username string, migrate bool) (*manager.DrainResult, error) {
+	if name != TestManagedNode.Name {
+		return nil, manager.ErrNodeDoesNotExist
+	}
+
+	result := &manager.DrainResult{
+		Node:     TestManagedNode,
+		Migrated: []string{},
+		Errors:   []string{},
+	}
+	if migrate {
+		result.Migrated = append(result.Migrated, TestContainerId)
+	}
+
+	return result, nil
+}
+
+func (m MockManager) UndrainNode(name, username string) error {
+	if name != TestManagedNode.Name {
+		return manager.ErrNodeDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) DrainConstraints() ([]string, error) {
+	return []string{"constraint:node!=" + TestManagedNode.Name}, nil
+}
+
 func (m MockManager) CreateConsoleSession(c *shipyard.ConsoleSession) error {
 	return nil
 }
@@ -191,6 +1186,74 @@ func (m MockManager) ValidateConsoleSessionToken(containerId, token string) bool
 	return true
 }
 
+func (m MockManager) ShareLinks() ([]*shipyard.ShareLink, error) {
+	return []*shipyard.ShareLink{TestShareLink}, nil
+}
+
+func (m MockManager) NewShareLink(link *shipyard.ShareLink, ttl time.Duration) error {
+	if len(link.Views) == 0 {
+		return manager.ErrShareLinkNoViews
+	}
+
+	link.ID = TestShareLink.ID
+	link.Token = TestShareLink.Token
+	return nil
+}
+
+func (m MockManager) RevokeShareLink(id, username string) error {
+	if id != TestShareLink.ID {
+		return manager.ErrShareLinkDoesNotExist
+	}
+
+	return nil
+}
+
+func (m MockManager) ShareLinkContainers(link *shipyard.ShareLink) ([]dockerclient.Container, error) {
+	return []dockerclient.Container{
+		{Id: TestContainerId, Names: []string{"/" + TestContainerName}, Image: TestContainerImage},
+	}, nil
+}
+
+func (m MockManager) UseShareLink(token, view, remoteAddr string) (*shipyard.ShareLink, error) {
+	if token != TestShareLink.Token {
+		return nil, manager.ErrInvalidShareLink
+	}
+
+	if view != "" && !TestShareLink.Allows(view) {
+		return nil, manager.ErrShareLinkViewDenied
+	}
+
+	return TestShareLink, nil
+}
+
+func (m MockManager) Notes() ([]*shipyard.Note, error) {
+	return []*shipyard.Note{TestNote}, nil
+}
+
+func (m MockManager) Note(kind, target string) (*shipyard.Note, error) {
+	if shipyard.NoteID(kind, target) != TestNote.ID {
+		return nil, manager.ErrNoteDoesNotExist
+	}
+
+	return TestNote, nil
+}
+
+func (m MockManager) SaveNote(note *shipyard.Note) error {
+	if note.Kind != shipyard.NoteContainer && note.Kind != shipyard.NoteStack && note.Kind != shipyard.NoteNode {
+		return manager.ErrInvalidNoteKind
+	}
+
+	return nil
+}
+
+func (m MockManager) DeleteNote(kind, target string) error {
+	if shipyard.NoteID(kind, target) != TestNote.ID {
+		return manager.ErrNoteDoesNotExist
+	}
+
+	return nil
+}
+
 func (m MockManager) GetAuthenticator() auth.Authenticator {
 	return nil
 }
@@ -198,3 +1261,235 @@ func (m MockManager) GetAuthenticator() auth.Authenticator {
 func (m MockManager) ScaleContainer(id string, numInstances int) manager.ScaleResult {
 	return manager.ScaleResult{Scaled: []string{"9c3c7dd2199a95cce29950b612ecf918ae278a42e53e10f6cccb752b6fbcd8b3"}, Errors: []string{"500 Internal Server Error: no resources available to schedule container"}}
 }
+
+func (m MockManager) SetRestartPolicy(id string, policy dockerclient.RestartPolicy, username string) error {
+	if id != TestContainerId {
+		return dockerclient.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m MockManager) ContainerStats(id string, period time.Duration) (*shipyard.ContainerStatsHistory, error) {
+	if id != TestContainerId {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	return &shipyard.ContainerStatsHistory{
+		ContainerId: TestContainerId,
+		Period:      period.String(),
+		Samples:     []*shipyard.ContainerStats{TestContainerStats},
+	}, nil
+}
+
+func (m MockManager) ContainerFsChanges(id string) (*manager.FsChanges, error) {
+	if id != TestContainerId {
+		return nil, dockerclient.ErrNotFound
+	}
+
+	return &manager.FsChanges{
+		Container: TestContainerId,
+		Image:     TestContainerImage,
+		Writable:  []string{"/tmp"},
+		Changes: []*manager.FsChange{
+			{Path: "/tmp/app.pid", Kind: manager.FsChangeAdded, Expected: true, Writable: "/tmp"},
+			{Path: "/usr/bin/curl", Kind: manager.FsChangeChanged},
+		},
+		Unexpected: 1,
+	}, nil
+}
+
+func (m MockManager) UpdateContainerResources(id string, update *manager.ResourceUpdate, username string) error {
+	if id != TestContainerId {
+		return dockerclient.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m MockManager) Notifiers() ([]*notification.Notifier, error) {
+	return []*notification.Notifier{
+		TestNotifier,
+		TestEmailNotifier,
+	}, nil
+}
+
+func (m MockManager) Notifier(id string) (*notification.Notifier, error) {
+	if id == TestEmailNotifier.ID {
+		return TestEmailNotifier, nil
+	}
+
+	return TestNotifier, nil
+}
+
+func (m MockManager) SaveNotifier(n *notification.Notifier) error {
+	return nil
+}
+
+func (m MockManager) DeleteNotifier(id string) error {
+	return nil
+}
+
+func (m MockManager) NotificationRules() ([]*notification.Rule, error) {
+	return []*notification.Rule{
+		TestNotificationRule,
+	}, nil
+}
+
+func (m MockManager) NotificationRule(id string) (*notification.Rule, error) {
+	return TestNotificationRule, nil
+}
+
+func (m MockManager) SaveNotificationRule(rule *notification.Rule) error {
+	return nil
+}
+
+func (m MockManager) DeleteNotificationRule(id string) error {
+	return nil
+}
+
+func (m MockManager) Escalations() ([]*notification.Escalation, error) {
+	return []*notification.Escalation{}, nil
+}
+
+func (m MockManager) AcknowledgeEscalation(id, username string) error {
+	return nil
+}
+
+func (m MockManager) Alerts(status string) ([]*shipyard.Alert, error) {
+	return []*shipyard.Alert{
+		TestAlert,
+	}, nil
+}
+
+func (m MockManager) Alert(id string) (*shipyard.Alert, error) {
+	return TestAlert, nil
+}
+
+func (m MockManager) AcknowledgeAlert(id, username string) error {
+	return nil
+}
+
+func (m MockManager) AssignAlert(id, username string) error {
+	return nil
+}
+
+func (m MockManager) AddAlertComment(id string, comment *shipyard.AlertComment) error {
+	return nil
+}
+
+func (m MockManager) ExecPolicies() ([]*auth.ExecPolicy, error) {
+	return []*auth.ExecPolicy{
+		TestExecPolicy,
+	}, nil
+}
+
+func (m MockManager) ExecPolicy(id string) (*auth.ExecPolicy, error) {
+	return TestExecPolicy, nil
+}
+
+func (m MockManager) SaveExecPolicy(policy *auth.ExecPolicy) error {
+	return nil
+}
+
+func (m MockManager) DeleteExecPolicy(id string) error {
+	return nil
+}
+
+func (m MockManager) AuthorizeExec(cs *shipyard.ConsoleSession, containerId string, cmd []string) (bool, error) {
+	return false, nil
+}
+
+func (m MockManager) ExecRecordings() ([]*shipyard.ExecRecording, error) {
+	rec := *TestExecRecording
+	rec.Frames = nil
+	return []*shipyard.ExecRecording{&rec}, nil
+}
+
+func (m MockManager) ExecRecording(id string) (*shipyard.ExecRecording, error) {
+	if id != TestExecRecording.ID {
+		return nil, manager.ErrExecRecordingDoesNotExist
+	}
+	return TestExecRecording, nil
+}
+
+func (m MockManager) SaveExecRecording(rec *shipyard.ExecRecording) error {
+	return nil
+}
+
+func (m MockManager) BreakGlass() (*auth.BreakGlass, error) {
+	return &auth.BreakGlass{}, nil
+}
+
+func (m MockManager) SealBreakGlass(username string) (string, error) {
+	return "test-credential", nil
+}
+
+func (m MockManager) UseBreakGlass(credential, reason, remoteAddr, userAgent string) (*auth.AuthToken, error) {
+	return &auth.AuthToken{}, nil
+}
+
+func (m MockManager) EndBreakGlass(username string) error {
+	return nil
+}
+
+func (m MockManager) SeedDemo(username string) (*manager.DemoResult, error) {
+	return &manager.DemoResult{
+		Accounts:  []*manager.DemoAccount{{Username: "demo-viewer", Password: "secret", Roles: []string{"demo-viewer"}}},
+		Roles:     []string{"demo-viewer"},
+		Templates: []string{"demo-web"},
+		Stack:     "demo",
+	}, nil
+}
+
+func (m MockManager) TeardownDemo(username string) error {
+	return nil
+}
+
+func (m MockManager) Pulls(deploy string) []*manager.PullProgress {
+	return []*manager.PullProgress{
+		{
+			Deploy:  manager.DeployStack + ":web",
+			Image:   TestContainerImage,
+			Status:  "Downloading",
+			Percent: 25,
+			Layers: []*manager.LayerProgress{
+				{ID: "a3ed95caeb02", Status: "Downloading", Current: 50, Total: 100, Percent: 25},
+			},
+		},
+	}
+}
+
+func (m MockManager) Controllers() ([]*shipyard.Controller, error) {
+	return []*shipyard.Controller{
+		{
+			ID:      "0",
+			Version: "test",
+			Status:  shipyard.ControllerStatusUp,
+			Leader:  true,
+		},
+	}, nil
+}
+
+func (m MockManager) Preflight() []*preflight.Result {
+	return []*preflight.Result{
+		preflight.OK("datastore", "connected"),
+	}
+}
+
+func (m MockManager) RedeployImage(image string) manager.RedeployResult {
+	return manager.RedeployResult{
+		Image:      image,
+		Redeployed: []string{TestContainerId},
+		Errors:     []string{},
+	}
+}
+
+func (m MockManager) PlanRedeploy(image string) (*manager.RedeployPlan, error) {
+	return &manager.RedeployPlan{
+		Image: image,
+		Containers: []*manager.RedeployPlanContainer{
+			{ID: TestContainerId, Name: TestContainerName, Image: image, Action: manager.RedeployActionRedeploy},
+		},
+	}, nil
+}
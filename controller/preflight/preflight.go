@@ -0,0 +1,157 @@
+package preflight
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusFailed  = "failed"
+
+	certExpiryWarning = 30 * 24 * time.Hour
+)
+
+type (
+	// Result is the outcome of a single check; Remedy tells the operator
+	// what to do about a warning or failure
+	Result struct {
+		Name     string `json:"name,omitempty"`
+		Status   string `json:"status,omitempty"`
+		Critical bool   `json:"critical"`
+		Message  string `json:"message,omitempty"`
+		Remedy   string `json:"remedy,omitempty"`
+	}
+
+	Report struct {
+		Time    time.Time `json:"time,omitempty"`
+		Passed  bool      `json:"passed"`
+		Results []*Result `json:"results,omitempty"`
+	}
+)
+
+func OK(name, message string) *Result {
+	return &Result{
+		Name:    name,
+		Status:  StatusOK,
+		Message: message,
+	}
+}
+
+func Warning(name, message, remedy string) *Result {
+	return &Result{
+		Name:    name,
+		Status:  StatusWarning,
+		Message: message,
+		Remedy:  remedy,
+	}
+}
+
+func Failed(name string, critical bool, message, remedy string) *Result {
+	return &Result{
+		Name:     name,
+		Status:   StatusFailed,
+		Critical: critical,
+		Message:  message,
+		Remedy:   remedy,
+	}
+}
+
+// NewReport builds a report from the results; it passes unless a
+// critical check failed
+func NewReport(results []*Result) *Report {
+	report := &Report{
+		Time:    time.Now(),
+		Passed:  true,
+		Results: []*Result{},
+	}
+
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+
+		if r.Status == StatusFailed && r.Critical {
+			report.Passed = false
+		}
+
+		report.Results = append(report.Results, r)
+	}
+
+	return report
+}
+
+// String returns the report as text for the controller log
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	for _, res := range r.Results {
+		fmt.Fprintf(&buf, "[%s] %s: %s\n", res.Status, res.Name, res.Message)
+		if res.Remedy != "" {
+			fmt.Fprintf(&buf, "    -> %s\n", res.Remedy)
+		}
+	}
+
+	return buf.String()
+}
+
+// CheckCertificate verifies the certificate and key load, match and are
+// not expired; it returns nil when no certificate is configured
+func CheckCertificate(name, certPath, keyPath string, now time.Time) *Result {
+	if certPath == "" && keyPath == "" {
+		return nil
+	}
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return Failed(name, true, fmt.Sprintf("cannot load %s and %s: %s", certPath, keyPath, err),
+			"check the certificate and key paths and that the key belongs to the certificate")
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return Failed(name, true, fmt.Sprintf("cannot parse %s: %s", certPath, err), "provide a PEM encoded x509 certificate")
+	}
+
+	switch {
+	case now.After(cert.NotAfter):
+		return Failed(name, true, fmt.Sprintf("%s expired %s", certPath, cert.NotAfter.Format(time.RFC3339)), "renew the certificate")
+	case now.Before(cert.NotBefore):
+		return Failed(name, true, fmt.Sprintf("%s is not valid until %s", certPath, cert.NotBefore.Format(time.RFC3339)),
+			"check the system clock or reissue the certificate")
+	case cert.NotAfter.Sub(now) < certExpiryWarning:
+		return Warning(name, fmt.Sprintf("%s expires %s", certPath, cert.NotAfter.Format(time.RFC3339)), "renew the certificate")
+	}
+
+	return OK(name, fmt.Sprintf("%s valid until %s", certPath, cert.NotAfter.Format(time.RFC3339)))
+}
+
+// CheckStaticAssets verifies the web ui is present in dir
+func CheckStaticAssets(dir string) *Result {
+	index := filepath.Join(dir, "index.html")
+	if _, err := os.Stat(index); err != nil {
+		return Failed("static assets", false, fmt.Sprintf("%s not found; the web ui will not load", index),
+			"run the controller from the directory containing the built static assets")
+	}
+
+	return OK("static assets", fmt.Sprintf("found %s", index))
+}
+
+// CheckClockSkew compares the local clock with a remote one
+func CheckClockSkew(name string, local, remote time.Time, max time.Duration) *Result {
+	skew := local.Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > max {
+		return Warning(name, fmt.Sprintf("clock differs by %s", skew), "synchronize the clocks with ntp")
+	}
+
+	return OK(name, fmt.Sprintf("clock differs by %s", skew))
+}
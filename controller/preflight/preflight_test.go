@@ -0,0 +1,48 @@
+package preflight
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewReport(t *testing.T) {
+	report := NewReport([]*Result{
+		OK("datastore", "connected"),
+		nil,
+		Failed("static assets", false, "missing", ""),
+	})
+
+	if !report.Passed {
+		t.Fatalf("expected report to pass with only non critical failures")
+	}
+
+	if len(report.Results) != 2 {
+		t.Fatalf("expected 2 results; received %d", len(report.Results))
+	}
+
+	report = NewReport([]*Result{
+		Failed("swarm", true, "unreachable", ""),
+	})
+
+	if report.Passed {
+		t.Fatalf("expected report to fail with a critical failure")
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Now()
+
+	if r := CheckClockSkew("clock", now, now.Add(-2*time.Second), 30*time.Second); r.Status != StatusOK {
+		t.Fatalf("expected ok; received %s", r.Status)
+	}
+
+	if r := CheckClockSkew("clock", now, now.Add(5*time.Minute), 30*time.Second); r.Status != StatusWarning {
+		t.Fatalf("expected warning; received %s", r.Status)
+	}
+}
+
+func TestCheckCertificateNotConfigured(t *testing.T) {
+	if r := CheckCertificate("tls", "", "", time.Now()); r != nil {
+		t.Fatalf("expected no result without a certificate")
+	}
+}
@@ -45,7 +45,7 @@
                 roles: vm.userRoles
             }
             $http
-                .post('/api/accounts', vm.request)
+                .post('/api/v1/accounts', vm.request)
                 .success(function(data, status, headers, config) {
                     $state.transitionTo('dashboard.accounts');
                 })
@@ -40,6 +40,22 @@
                     });
                 return promise;
             },
+            note: function(kind, target) {
+                var promise = $http
+                    .get('/api/v1/notes/' + kind + '/' + encodeURIComponent(target))
+                    .then(function(response) {
+                        return response.data;
+                    });
+                return promise;
+            },
+            saveNote: function(kind, target, note) {
+                var promise = $http
+                    .put('/api/v1/notes/' + kind + '/' + encodeURIComponent(target), note)
+                    .then(function(response) {
+                        return response.data;
+                    });
+                return promise;
+            },
             stats: function(containerId) {
                 var promise = $http
                     .get('/containers/' + containerId + '/stats')
@@ -90,7 +106,7 @@
             },
             scale: function(containerId, numOfInstances) {
                 var promise = $http
-                    .post('/api/containers/' + containerId + '/scale?n=' + numOfInstances)
+                    .post('/api/v1/containers/' + containerId + '/scale?n=' + numOfInstances)
                     .then(function(response) {
                         return response.data;
                     });
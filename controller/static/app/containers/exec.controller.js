@@ -16,6 +16,22 @@
             var term;
             var websocket;
 
+            // binary messages are control messages for the session
+            function sendResize(cols, rows) {
+                if (websocket != null && websocket.readyState === WebSocket.OPEN) {
+                    websocket.send(new Blob([JSON.stringify({width: cols, height: rows})]));
+                }
+            }
+
+            $(window).on('resize.exec', function() {
+                if (term == null) {
+                    return;
+                }
+                var cols = Math.round($(window).width() / 7.5);
+                term.resize(cols, term.rows);
+                sendResize(cols, term.rows);
+            });
+
             function connect() {
                 var termWidth = Math.round($(window).width() / 7.5);
                 var termHeight = 30;
@@ -34,7 +50,7 @@
                 // as authentication to make sure the user has console access
                 // for this exec session
                 $http
-                    .get('/api/consolesession/' + vm.id)
+                    .get('/api/v1/consolesession/' + vm.id)
                     .success(function(data, status, headers, config) {
                         vm.token = data.token;
                         vm.addr = wsScheme + "://" + window.location.hostname + ":" + window.location.port + "/exec?id=" + vm.id + "&cmd=" + cmd + "&h=" + termHeight + "&w=" + termWidth + "&token=" + vm.token;
@@ -66,6 +82,7 @@
                             websocket.onclose = function(evt) {
                                 term.write("Session terminated");
                                 term.destroy();
+                                term = null;
                             }
                             websocket.onerror = function(evt) {
                                 if (typeof console.log == "function") {
@@ -20,6 +20,12 @@
         vm.restartContainer = restartContainer;
         vm.parseLinkingString = parseLinkingString;
         vm.isEmptyObject = isEmptyObject;
+        vm.saveNote = saveNote;
+        vm.containerName = vm.container.Name.split("/")[1];
+        vm.stackName = vm.container.Config.Labels ? (vm.container.Config.Labels['com.docker.compose.project'] || vm.container.Config.Labels['com.docker.stack.namespace']) : '';
+        vm.note = {text: '', runbooks: []};
+        vm.noteRunbooks = '';
+        vm.stackNote = null;
         vm.top;
         vm.stats;
         vm.links = parseContainerLinks(vm.container.HostConfig.Links);
@@ -30,6 +36,34 @@
             }, null);
         }
 
+        // notes that are missing are not an error
+        ContainerService.note('container', vm.containerName).then(function(data) {
+            vm.note = data;
+            vm.noteRunbooks = (data.runbooks || []).join('\n');
+        }, null);
+
+        if(vm.stackName) {
+            ContainerService.note('stack', vm.stackName).then(function(data) {
+                vm.stackNote = data;
+            }, null);
+        }
+
+        function saveNote() {
+            vm.note.runbooks = vm.noteRunbooks.split('\n').map(function(l) {
+                return l.trim();
+            }).filter(function(l) {
+                return l !== '';
+            });
+            ContainerService.saveNote('container', vm.containerName, vm.note)
+                .then(function(data) {
+                    vm.note = data;
+                    vm.editingNote = false;
+                    vm.noteError = null;
+                }, function(data) {
+                    vm.noteError = data.data;
+                });
+        }
+
         function parseContainerLinks(links) {
             var l = [];
             if (links == null) {
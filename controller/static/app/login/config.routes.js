@@ -23,6 +23,12 @@
                     controllerAs: 'vm',
                     authenticate: true
                 })
+                .state('oidc', {
+                    url: '/oidc?username&auth_token',
+                    controller: 'OIDCController',
+                    controllerAs: 'vm',
+                    authenticate: false
+                })
                 .state('logout', {
                     url: '/logout',
                     controller: 'LogoutController',
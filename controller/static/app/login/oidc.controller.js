@@ -0,0 +1,19 @@
+(function(){
+	'use strict';
+
+	angular
+		.module('shipyard.login')
+		.controller('OIDCController', OIDCController);
+
+    OIDCController.$inject = ['AuthService', '$state', '$stateParams'];
+	function OIDCController(AuthService, $state, $stateParams) {
+            var vm = this;
+            if (!$stateParams.username || !$stateParams.auth_token) {
+                $state.transitionTo('login');
+                return;
+            }
+            AuthService.setToken($stateParams.username, $stateParams.auth_token);
+            // keep the token out of the history
+            $state.transitionTo('dashboard.containers', {}, {location: 'replace'});
+        }
+})();
@@ -35,7 +35,7 @@
                 tls_skip_verify: vm.tls.tlsSkipVerify
             };
             $http
-                .post('/api/registries', vm.request)
+                .post('/api/v1/registries', vm.request)
                 .success(function(data, status, headers, config) {
                     $state.transitionTo('dashboard.registry');
                 })
@@ -10,7 +10,7 @@
         return {
             list: function() {
                 var promise = $http
-                    .get('/api/registries')
+                    .get('/api/v1/registries')
                     .then(function(response) {
                         return response.data;
                     });
@@ -18,7 +18,7 @@
             },
             inspectRegistry: function(name) {
                 var promise = $http
-                    .get('/api/registries/'+name)
+                    .get('/api/v1/registries/'+name)
                     .then(function(response) {
                         return response.data;
                     });
@@ -26,7 +26,7 @@
             },
             inspectRepository: function(registryId, repositoryName, repositoryTag) {
                 var promise = $http
-                    .get('/api/registries/'+registryId +'/repositories/'+repositoryName+':'+repositoryTag)
+                    .get('/api/v1/registries/'+registryId +'/repositories/'+repositoryName+':'+repositoryTag)
                     .then(function(response) {
                         return response.data;
                     });
@@ -34,7 +34,7 @@
             },
             listRepositories: function(name) {
                 var promise = $http
-                    .get('/api/registries/'+name+'/repositories')
+                    .get('/api/v1/registries/'+name+'/repositories')
                     .then(function(response) {
                         return response.data;
                     });
@@ -42,7 +42,7 @@
             },
             removeRegistry: function(registry) {
                 var promise = $http
-                    .delete('/api/registries/'+registry.id)
+                    .delete('/api/v1/registries/'+registry.id)
                     .then(function(response) {
                         return response.data;
                     });
@@ -51,7 +51,7 @@
             removeRepository: function(registryId, repo) {
                 var tag = repo.tag ? ":" + repo.tag : "";
                 var promise = $http
-                    .delete('/api/registries/'+registryId+'/repositories/'+repo.name+tag)
+                    .delete('/api/v1/registries/'+registryId+'/repositories/'+repo.name+tag)
                     .then(function(response) {
                         return response.data;
                     });
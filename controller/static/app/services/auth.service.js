@@ -21,6 +21,9 @@
                         return response.data;
                     });
             },
+            setToken: function(username, token) {
+                localStorage.setItem('X-Access-Token', username + ':' + token);
+            },
             logout: function() {
                 localStorage.removeItem('X-Access-Token');
             },
@@ -0,0 +1,24 @@
+package shipyard
+
+import (
+	"time"
+)
+
+const (
+	ControllerStatusUp   = "up"
+	ControllerStatusDown = "down"
+)
+
+type (
+	// Controller is a shipyard controller process sharing the datastore
+	Controller struct {
+		ID            string    `json:"id,omitempty" gorethink:"id"`
+		Hostname      string    `json:"hostname,omitempty" gorethink:"hostname"`
+		Addr          string    `json:"addr,omitempty" gorethink:"addr"`
+		Version       string    `json:"version,omitempty" gorethink:"version"`
+		Started       time.Time `json:"started,omitempty" gorethink:"started"`
+		LastHeartbeat time.Time `json:"last_heartbeat,omitempty" gorethink:"last_heartbeat"`
+		Status        string    `json:"status,omitempty" gorethink:"-"`
+		Leader        bool      `json:"leader" gorethink:"-"`
+	}
+)
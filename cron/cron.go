@@ -0,0 +1,202 @@
+// Package cron parses the five field cron expressions (minute, hour, day of
+// month, month and day of week) used to schedule jobs
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchLimit bounds the search for the next activation; expressions like
+// 0 0 30 2 * never match
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+var (
+	ErrNoActivation = errors.New("the expression never matches")
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	months = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	days   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+type field struct {
+	name     string
+	min, max int
+	// names are the names of the values from min
+	names []string
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: months},
+	// 7 is sunday as well
+	{name: "day of week", min: 0, max: 7, names: days},
+}
+
+// Schedule is a parsed expression; the bits of each field are the values
+// it matches
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// a restricted day of month or day of week matches either, as in cron
+	domStar, dowStar bool
+}
+
+// Parse parses an expression like "*/15 2-4 * * mon-fri" or a descriptor
+// like @daily
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields in %q; received %d", len(fields), spec, len(parts))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := f.parse(parts[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+
+	// sunday is 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*" || parts[2] == "?",
+		dowStar: parts[4] == "*" || parts[4] == "?",
+	}, nil
+}
+
+// parse parses a comma separated list of values, ranges and steps
+func (f field) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, item)
+			}
+			rangeExpr, step = item[:i], s
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := f.value(rangeExpr)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			// a single value with a step runs to the end as in cron
+			if step == 1 {
+				end = v
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid range in %s %q", f.name, item)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// value parses a number or a name of the field
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q; expected %d-%d", f.name, s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// Next returns the first activation after the time in its location
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(searchLimit)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, nil
+	}
+
+	return time.Time{}, ErrNoActivation
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
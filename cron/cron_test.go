@@ -0,0 +1,59 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// a wednesday
+	now := time.Date(2016, 3, 2, 10, 17, 30, 0, time.UTC)
+
+	for _, c := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2016, 3, 2, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2016, 3, 2, 10, 30, 0, 0, time.UTC)},
+		{"0 2-4 * * *", time.Date(2016, 3, 3, 2, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2016, 3, 3, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2016, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"5,10 10 * * *", time.Date(2016, 3, 3, 10, 5, 0, 0, time.UTC)},
+	} {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %s", c.spec, err)
+		}
+
+		next, err := s.Next(now)
+		if err != nil {
+			t.Fatalf("%s: %s", c.spec, err)
+		}
+
+		if !next.Equal(c.next) {
+			t.Errorf("%s: expected %s; received %s", c.spec, c.next, next)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Next(time.Now()); err != ErrNoActivation {
+		t.Fatalf("expected ErrNoActivation; received %v", err)
+	}
+}
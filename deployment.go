@@ -0,0 +1,45 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// Statuses of a deployment
+const (
+	DeploymentSucceeded = "succeeded"
+	DeploymentFailed    = "failed"
+	// DeploymentRolledBack is set when a smoke test failed and the stack
+	// was put back as it was before the deploy
+	DeploymentRolledBack = "rolled-back"
+)
+
+// Deployment is a deploy or redeploy of a stack with the outcome of its
+// smoke tests
+type Deployment struct {
+	ID         string             `json:"id" gorethink:"id"`
+	Stack      string             `json:"stack" gorethink:"stack"`
+	Operation  string             `json:"operation" gorethink:"operation"`
+	Username   string             `json:"username,omitempty" gorethink:"username,omitempty"`
+	StartedAt  time.Time          `json:"started_at" gorethink:"started_at"`
+	FinishedAt time.Time          `json:"finished_at" gorethink:"finished_at"`
+	Status     string             `json:"status" gorethink:"status"`
+	Error      string             `json:"error,omitempty" gorethink:"error,omitempty"`
+	Release    *Release           `json:"release,omitempty" gorethink:"release,omitempty"`
+	SmokeTests []*SmokeTestResult `json:"smoke_tests,omitempty" gorethink:"smoke_tests,omitempty"`
+}
+
+// Release tells what a deploy changes: free-form notes and links (i.e. to
+// the ticket or the changelog)
+type Release struct {
+	Notes string   `json:"notes,omitempty" gorethink:"notes,omitempty"`
+	Links []string `json:"links,omitempty" gorethink:"links,omitempty"`
+}
+
+// SmokeTestResult is the outcome of a smoke test of a deployment
+type SmokeTestResult struct {
+	Name   string `json:"name" gorethink:"name"`
+	Passed bool   `json:"passed" gorethink:"passed"`
+	// Output is the status of the url or the exit code of the container
+	Output   string  `json:"output,omitempty" gorethink:"output,omitempty"`
+	Duration float64 `json:"duration" gorethink:"duration"`
+}
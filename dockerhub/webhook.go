@@ -5,9 +5,20 @@ type (
 		PushData   *PushData   `json:"push_data,omitempty"`
 		Repository *Repository `json:"repository,omitempty"`
 	}
+	// WebhookKey maps the webhooks of Docker Hub, GitHub and GitLab to
+	// the image they redeploy
 	WebhookKey struct {
 		ID    string `json:"id,omitempty" gorethink:"id,omitempty"`
 		Image string `json:"image,omitempty" gorethink:"image"`
 		Key   string `json:"key,omitempty" gorethink:"key"`
+		// Secret validates the signatures of GitHub and the tokens of
+		// GitLab
+		Secret string `json:"secret,omitempty" gorethink:"secret,omitempty"`
+		// Branch limits GitHub and GitLab webhooks to a branch or tag;
+		// any ref triggers when empty
+		Branch string `json:"branch,omitempty" gorethink:"branch,omitempty"`
+		// MaxSeverity is the most severe vulnerability images may have
+		// to be redeployed; images are not scanned when empty
+		MaxSeverity string `json:"max_severity,omitempty" gorethink:"max_severity,omitempty"`
 	}
 )
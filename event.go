@@ -12,5 +12,13 @@ type Event struct {
 	Time          time.Time                   `json:"time,omitempty"`
 	Message       string                      `json:"message,omitempty"`
 	Username      string                      `json:"username,omitempty"`
+	RemoteAddr    string                      `json:"remote_addr,omitempty"`
+	Country       string                      `json:"country,omitempty"`
+	ASN           string                      `json:"asn,omitempty"`
 	Tags          []string                    `json:"tags,omitempty"`
+	// RequestID is the id of the api request the event was recorded for
+	RequestID string `json:"request_id,omitempty"`
+	// Notes are the runbooks of what the event is about; they are
+	// included in notifications
+	Notes []*Note `json:"notes,omitempty"`
 }
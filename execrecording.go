@@ -0,0 +1,74 @@
+package shipyard
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExecRecording is the terminal output of a recorded exec session; it is
+// kept under the id of the session
+type ExecRecording struct {
+	ID          string    `json:"id,omitempty" gorethink:"id,omitempty"`
+	Username    string    `json:"username,omitempty" gorethink:"username"`
+	ContainerID string    `json:"container_id,omitempty" gorethink:"container_id"`
+	Command     []string  `json:"command,omitempty" gorethink:"command"`
+	Width       int       `json:"width" gorethink:"width"`
+	Height      int       `json:"height" gorethink:"height"`
+	StartedAt   time.Time `json:"started_at,omitempty" gorethink:"started_at"`
+	// Duration is the length of the session in seconds
+	Duration float64 `json:"duration" gorethink:"duration"`
+	// Truncated is set when the output went past the size kept for a
+	// recording; the frames stop there
+	Truncated bool                  `json:"truncated,omitempty" gorethink:"truncated,omitempty"`
+	Frames    []*ExecRecordingFrame `json:"frames,omitempty" gorethink:"frames,omitempty"`
+}
+
+// ExecRecordingFrame is output written Time seconds into the session
+type ExecRecordingFrame struct {
+	Time float64 `json:"time" gorethink:"time"`
+	Data string  `json:"data" gorethink:"data"`
+}
+
+// asciicastHeader is the first line of an asciicast v2 file
+type asciicastHeader struct {
+	Version   int     `json:"version"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Timestamp int64   `json:"timestamp"`
+	Duration  float64 `json:"duration"`
+	Command   string  `json:"command,omitempty"`
+	Title     string  `json:"title,omitempty"`
+}
+
+// WriteAsciicast writes the recording in the asciicast v2 format played by
+// asciinema: a header line followed by a [time, "o", data] line per frame
+func (r *ExecRecording) WriteAsciicast(w io.Writer) error {
+	width, height := r.Width, r.Height
+	// players need a size; sessions without one used the docker default
+	if width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&asciicastHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.StartedAt.Unix(),
+		Duration:  r.Duration,
+		Command:   strings.Join(r.Command, " "),
+		Title:     r.Username + "@" + r.ContainerID,
+	}); err != nil {
+		return err
+	}
+
+	for _, f := range r.Frames {
+		if err := enc.Encode([]interface{}{f.Time, "o", f.Data}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
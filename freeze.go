@@ -0,0 +1,39 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// Freeze is a window of the change calendar during which deploys to its
+// environments are refused unless the caller may override freezes; a
+// freeze without environments applies to every environment
+type Freeze struct {
+	ID           string    `json:"id,omitempty" gorethink:"id,omitempty"`
+	Name         string    `json:"name" gorethink:"name"`
+	Reason       string    `json:"reason,omitempty" gorethink:"reason,omitempty"`
+	Start        time.Time `json:"start" gorethink:"start"`
+	End          time.Time `json:"end" gorethink:"end"`
+	Environments []string  `json:"environments,omitempty" gorethink:"environments"`
+	CreatedBy    string    `json:"created_by,omitempty" gorethink:"created_by"`
+	CreatedAt    time.Time `json:"created_at,omitempty" gorethink:"created_at"`
+}
+
+// Active reports whether the window includes the time
+func (f *Freeze) Active(now time.Time) bool {
+	return !now.Before(f.Start) && now.Before(f.End)
+}
+
+// Applies reports whether the freeze covers the environment
+func (f *Freeze) Applies(environment string) bool {
+	if len(f.Environments) == 0 {
+		return true
+	}
+
+	for _, e := range f.Environments {
+		if e == environment {
+			return true
+		}
+	}
+
+	return false
+}
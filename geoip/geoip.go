@@ -0,0 +1,120 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+type (
+	Location struct {
+		Country string `json:"country,omitempty"`
+		ASN     string `json:"asn,omitempty"`
+	}
+
+	// Database is a local GeoIP database loaded from a CSV file with one
+	// network per line: network,country,asn (i.e. 8.8.8.0/24,US,AS15169)
+	Database struct {
+		networks []*network
+	}
+
+	network struct {
+		ipNet    *net.IPNet
+		size     int
+		location *Location
+	}
+)
+
+// bySize sorts networks from most to least specific
+type bySize []*network
+
+func (s bySize) Len() int           { return len(s) }
+func (s bySize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySize) Less(i, j int) bool { return s[i].size > s[j].size }
+
+// Open loads the database at path
+func Open(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Load reads a database from r
+func Load(r io.Reader) (*Database, error) {
+	rdr := csv.NewReader(r)
+	rdr.Comment = '#'
+	rdr.FieldsPerRecord = -1
+
+	db := &Database{
+		networks: []*network{},
+	}
+
+	for {
+		rec, err := rdr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("invalid geoip record: %v", rec)
+		}
+
+		cidr := strings.TrimSpace(rec[0])
+		// skip header
+		if cidr == "network" {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		size, _ := ipNet.Mask.Size()
+		loc := &Location{
+			Country: strings.TrimSpace(rec[1]),
+		}
+		if len(rec) > 2 {
+			loc.ASN = strings.TrimSpace(rec[2])
+		}
+
+		db.networks = append(db.networks, &network{
+			ipNet:    ipNet,
+			size:     size,
+			location: loc,
+		})
+	}
+
+	// most specific networks first
+	sort.Stable(bySize(db.networks))
+
+	return db, nil
+}
+
+// Lookup returns the location of the most specific network containing
+// addr or nil if it is unknown
+func (d *Database) Lookup(addr string) *Location {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+
+	for _, n := range d.networks {
+		if n.ipNet.Contains(ip) {
+			return n.location
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package geoip
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDatabase = `network,country,asn
+# comment
+10.0.0.0/8,US,AS1
+10.1.0.0/16,DE,AS2
+2001:db8::/32,FR,AS3
+`
+
+func TestLookup(t *testing.T) {
+	db, err := Load(strings.NewReader(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc := db.Lookup("10.2.3.4")
+	if loc == nil || loc.Country != "US" {
+		t.Fatalf("expected US; received %v", loc)
+	}
+
+	loc = db.Lookup("10.1.3.4")
+	if loc == nil || loc.Country != "DE" || loc.ASN != "AS2" {
+		t.Fatalf("expected DE AS2; received %v", loc)
+	}
+
+	loc = db.Lookup("2001:db8::1")
+	if loc == nil || loc.Country != "FR" {
+		t.Fatalf("expected FR; received %v", loc)
+	}
+
+	if loc := db.Lookup("192.168.1.1"); loc != nil {
+		t.Fatalf("expected unknown location; received %v", loc)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := Load(strings.NewReader("not-a-network,US\n")); err == nil {
+		t.Fatalf("expected error for invalid network")
+	}
+}
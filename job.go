@@ -0,0 +1,52 @@
+package shipyard
+
+import (
+	"time"
+)
+
+const (
+	// JobActionRun runs a one-off container from the image
+	JobActionRun = "run"
+	// JobActionRestart restarts the container
+	JobActionRestart = "restart"
+	// JobActionPrune removes the stopped containers with the labels
+	JobActionPrune = "prune"
+
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// Job is an action run by the controller on a cron expression (i.e.
+// "0 3 * * *" or @daily); it runs with the access of its creator
+type Job struct {
+	ID       string `json:"id,omitempty" gorethink:"id,omitempty"`
+	Name     string `json:"name" gorethink:"name"`
+	Schedule string `json:"schedule" gorethink:"schedule"`
+	Action   string `json:"action" gorethink:"action"`
+	// Image, Command and Env are the container run by run jobs
+	Image   string   `json:"image,omitempty" gorethink:"image,omitempty"`
+	Command []string `json:"command,omitempty" gorethink:"command,omitempty"`
+	Env     []string `json:"env,omitempty" gorethink:"env,omitempty"`
+	// Container is restarted by restart jobs
+	Container string `json:"container,omitempty" gorethink:"container,omitempty"`
+	// Labels are set on the containers of run jobs and limit the
+	// containers removed by prune jobs
+	Labels    map[string]string `json:"labels,omitempty" gorethink:"labels,omitempty"`
+	Disabled  bool              `json:"disabled" gorethink:"disabled"`
+	NextRun   time.Time         `json:"next_run,omitempty" gorethink:"next_run"`
+	LastRun   *JobRun           `json:"last_run,omitempty" gorethink:"last_run,omitempty"`
+	CreatedBy string            `json:"created_by,omitempty" gorethink:"created_by"`
+	CreatedAt time.Time         `json:"created_at,omitempty" gorethink:"created_at"`
+}
+
+// JobRun is the outcome of a run of a job; the output is the container of
+// run jobs or the containers removed by prune jobs
+type JobRun struct {
+	StartedAt  time.Time `json:"started_at" gorethink:"started_at"`
+	FinishedAt time.Time `json:"finished_at" gorethink:"finished_at"`
+	Status     string    `json:"status" gorethink:"status"`
+	Output     string    `json:"output,omitempty" gorethink:"output,omitempty"`
+	Error      string    `json:"error,omitempty" gorethink:"error,omitempty"`
+	// Manual is set for runs requested through the api
+	Manual bool `json:"manual,omitempty" gorethink:"manual,omitempty"`
+}
@@ -1,12 +1,168 @@
 package shipyard
 
+import (
+	"time"
+)
+
 type Node struct {
 	ID             string   `json:"id,omitempty" gorethink:"id,omitempty"`
 	Name           string   `json:"name,omitempty" gorethink:"name,omitempty"`
 	Addr           string   `json:"addr,omitempty" gorethink:"addr,omitempty"`
+	Status         string   `json:"status,omitempty" gorethink:"status,omitempty"`
 	Containers     string   `json:"containers,omitempty"`
 	ReservedCPUs   string   `json:"reserved_cpus,omitempty"`
 	ReservedMemory string   `json:"reserved_memory,omitempty"`
 	Labels         []string `json:"labels,omitempty"`
 	ResponseTime   float64  `json:"response_time" gorethink:"response_time,omitempty"`
+	// Drained nodes get no new containers from Shipyard
+	Drained bool `json:"drained,omitempty" gorethink:"-"`
+	// Plugins are the drivers installed on the engine of the node
+	Plugins *NodePlugins `json:"plugins,omitempty" gorethink:"-"`
+	// Clock is the clock of the engine compared with the controller
+	Clock *NodeClock `json:"clock,omitempty" gorethink:"-"`
+}
+
+// Kinds of engine plugins
+const (
+	PluginVolume        = "volume"
+	PluginNetwork       = "network"
+	PluginAuthorization = "authorization"
+	PluginLog           = "log"
+	PluginStorage       = "storage"
+)
+
+// NodePlugins is the inventory of the plugins and drivers of an engine;
+// it is collected periodically
+type NodePlugins struct {
+	Volume        []string  `json:"volume"`
+	Network       []string  `json:"network"`
+	Authorization []string  `json:"authorization"`
+	Log           []string  `json:"log"`
+	StorageDriver string    `json:"storage_driver,omitempty"`
+	LoggingDriver string    `json:"logging_driver,omitempty"`
+	CollectedAt   time.Time `json:"collected_at"`
+	// Error is set when the engine could not be reached; the plugins
+	// are the ones collected before
+	Error string `json:"error,omitempty"`
+}
+
+// NodeClock is the offset of the clock of an engine from the one of the
+// controller; clocks apart break TLS, the order of logs and scheduling
+type NodeClock struct {
+	// Skew is how many seconds the engine is ahead of the controller;
+	// it is negative when the engine is behind
+	Skew float64 `json:"skew"`
+	// Uncertainty is half the round trip of the request in seconds; the
+	// skew is only known within it
+	Uncertainty float64 `json:"uncertainty"`
+	// Skewed is set when the skew exceeds the threshold by more than the
+	// uncertainty
+	Skewed    bool      `json:"skewed"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// EngineConfig is the configuration of the engine of a node compared
+// between nodes for drift
+type EngineConfig struct {
+	Version            string   `json:"version"`
+	KernelVersion      string   `json:"kernel_version"`
+	OperatingSystem    string   `json:"operating_system"`
+	StorageDriver      string   `json:"storage_driver"`
+	LoggingDriver      string   `json:"logging_driver"`
+	CgroupDriver       string   `json:"cgroup_driver"`
+	InsecureRegistries []string `json:"insecure_registries"`
+	RegistryMirrors    []string `json:"registry_mirrors"`
+}
+
+// RegistryMirrorID is the id of the registry mirror setting
+const RegistryMirrorID = "registry-mirror"
+
+// RegistryMirror is the pull-through cache every engine of the cluster
+// should pull Docker Hub images through
+type RegistryMirror struct {
+	ID    string    `json:"-" gorethink:"id"`
+	URL   string    `json:"url" gorethink:"url"`
+	SetBy string    `json:"set_by,omitempty" gorethink:"set_by,omitempty"`
+	SetAt time.Time `json:"set_at,omitempty" gorethink:"set_at,omitempty"`
+}
+
+// RegistryMirrorReport lists the nodes whose engine uses the mirror and
+// the ones missing it; engines read their mirrors from their daemon
+// configuration so DaemonConfig is what has to be added to daemon.json of
+// the missing ones before restarting them
+type RegistryMirrorReport struct {
+	Mirror       *RegistryMirror     `json:"mirror"`
+	DaemonConfig map[string][]string `json:"daemon_config,omitempty"`
+	Configured   []string            `json:"configured"`
+	Missing      []string            `json:"missing"`
+	// Unknown are the nodes without a collected configuration
+	Unknown []string `json:"unknown"`
+}
+
+// DriftSetting is a setting of the engines which differs between nodes;
+// the expected value is the one of most nodes
+type DriftSetting struct {
+	Name     string            `json:"name"`
+	Expected string            `json:"expected"`
+	Outliers map[string]string `json:"outliers"`
+}
+
+// DriftReport lists the settings differing between the engines; nodes
+// without a collected configuration are unknown
+type DriftReport struct {
+	Nodes       []string        `json:"nodes"`
+	Unknown     []string        `json:"unknown"`
+	Drift       []*DriftSetting `json:"drift"`
+	Homogeneous bool            `json:"homogeneous"`
+}
+
+// Has reports whether the engine has the driver of the kind (i.e. a
+// volume plugin); the storage kind is the storage driver of the engine
+func (p *NodePlugins) Has(kind, name string) bool {
+	var names []string
+	switch kind {
+	case PluginVolume:
+		names = p.Volume
+	case PluginNetwork:
+		names = p.Network
+	case PluginAuthorization:
+		names = p.Authorization
+	case PluginLog:
+		// the default logging driver is not always listed
+		names = append([]string{p.LoggingDriver}, p.Log...)
+	case PluginStorage:
+		names = []string{p.StorageDriver}
+	}
+
+	for _, n := range names {
+		// plugins can be listed with their tag (i.e. rexray:latest)
+		if n == name || n == name+":latest" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ManagedNode is a node known to Shipyard rather than only to swarm;
+// engines added through Shipyard keep their address and TLS material, and
+// any swarm node gets a record once it is drained
+type ManagedNode struct {
+	Name      string    `json:"name" gorethink:"id"`
+	Addr      string    `json:"addr,omitempty" gorethink:"addr,omitempty"`
+	TLSCACert string    `json:"tls_ca_cert,omitempty" gorethink:"tls_ca_cert,omitempty"`
+	TLSCert   string    `json:"tls_cert,omitempty" gorethink:"tls_cert,omitempty"`
+	TLSKey    string    `json:"tls_key,omitempty" gorethink:"tls_key,omitempty"`
+	AddedBy   string    `json:"added_by,omitempty" gorethink:"added_by,omitempty"`
+	AddedAt   time.Time `json:"added_at,omitempty" gorethink:"added_at,omitempty"`
+	// Agent is the id of the swarm agent container started on the engine
+	Agent     string    `json:"agent,omitempty" gorethink:"agent,omitempty"`
+	Drained   bool      `json:"drained" gorethink:"drained"`
+	DrainedBy string    `json:"drained_by,omitempty" gorethink:"drained_by,omitempty"`
+	DrainedAt time.Time `json:"drained_at,omitempty" gorethink:"drained_at,omitempty"`
+}
+
+// Added reports whether the engine was added through Shipyard
+func (n *ManagedNode) Added() bool {
+	return !n.AddedAt.IsZero()
 }
@@ -0,0 +1,35 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// What a note can be attached to
+const (
+	NoteContainer = "container"
+	NoteStack     = "stack"
+	NoteNode      = "node"
+
+	// LabelStack is the label of the compose project or swarm stack a
+	// container belongs to
+	LabelStack      = "com.docker.compose.project"
+	LabelSwarmStack = "com.docker.stack.namespace"
+)
+
+// Note is free-form text and runbook links attached to a container (by
+// name), a stack or a node; they are shown next to alerts about it
+type Note struct {
+	ID        string    `json:"id,omitempty" gorethink:"id,omitempty"`
+	Kind      string    `json:"kind,omitempty" gorethink:"kind"`
+	Target    string    `json:"target,omitempty" gorethink:"target"`
+	Text      string    `json:"text,omitempty" gorethink:"text"`
+	Runbooks  []string  `json:"runbooks,omitempty" gorethink:"runbooks"`
+	UpdatedBy string    `json:"updated_by,omitempty" gorethink:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" gorethink:"updated_at"`
+}
+
+// NoteID returns the id of the note of a target; there is one note per
+// target
+func NoteID(kind, target string) string {
+	return kind + ":" + target
+}
@@ -0,0 +1,372 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+
+	TypeSlack     = "slack"
+	TypePagerDuty = "pagerduty"
+	TypeWebhook   = "webhook"
+	TypeEmail     = "email"
+
+	// container labels used to route notifications
+	LabelEnvironment = "com.shipyard.environment"
+	LabelTeam        = "com.shipyard.team"
+
+	pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	sendTimeout  = 10 * time.Second
+
+	// Redacted replaces the credentials of notifiers in responses
+	Redacted = "********"
+)
+
+var (
+	ErrUnknownNotifierType = errors.New("unknown notifier type")
+	ErrInvalidQuietHours   = errors.New("quiet hours must be in 15:04 format")
+	ErrRedactedCredential  = errors.New("redacted credentials can only be kept when updating a notifier")
+)
+
+type (
+	// Notifier is a destination notifications are delivered to. With
+	// event types the notifier gets every event of the types (i.e. die,
+	// add-account) without a rule and rules only deliver those types to it;
+	// a trailing "*" matches a prefix (i.e. redeploy*).
+	Notifier struct {
+		ID         string   `json:"id,omitempty" gorethink:"id,omitempty"`
+		Name       string   `json:"name,omitempty" gorethink:"name"`
+		Type       string   `json:"type,omitempty" gorethink:"type"`
+		URL        string   `json:"url,omitempty" gorethink:"url"`
+		RoutingKey string   `json:"routing_key,omitempty" gorethink:"routing_key"`
+		EventTypes []string `json:"event_types,omitempty" gorethink:"event_types"`
+		// SMTP settings of email notifiers; the credentials are optional
+		SMTPAddr     string   `json:"smtp_addr,omitempty" gorethink:"smtp_addr"`
+		SMTPUsername string   `json:"smtp_username,omitempty" gorethink:"smtp_username"`
+		SMTPPassword string   `json:"smtp_password,omitempty" gorethink:"smtp_password"`
+		From         string   `json:"from,omitempty" gorethink:"from"`
+		To           []string `json:"to,omitempty" gorethink:"to"`
+	}
+
+	// QuietHours is a daily window (i.e. 22:00 to 07:00) in which only
+	// critical notifications are delivered
+	QuietHours struct {
+		Start string `json:"start,omitempty" gorethink:"start"`
+		End   string `json:"end,omitempty" gorethink:"end"`
+	}
+
+	// Rule routes notifications matching the environments, teams and
+	// minimum severity to notifiers; an empty list matches anything
+	Rule struct {
+		ID                  string      `json:"id,omitempty" gorethink:"id,omitempty"`
+		Name                string      `json:"name,omitempty" gorethink:"name"`
+		Environments        []string    `json:"environments,omitempty" gorethink:"environments"`
+		Teams               []string    `json:"teams,omitempty" gorethink:"teams"`
+		MinSeverity         string      `json:"min_severity,omitempty" gorethink:"min_severity"`
+		Notifiers           []string    `json:"notifiers,omitempty" gorethink:"notifiers"`
+		QuietHours          *QuietHours `json:"quiet_hours,omitempty" gorethink:"quiet_hours"`
+		EscalateAfter       int         `json:"escalate_after,omitempty" gorethink:"escalate_after"`
+		EscalationNotifiers []string    `json:"escalation_notifiers,omitempty" gorethink:"escalation_notifiers"`
+	}
+
+	Message struct {
+		Type        string    `json:"type,omitempty" gorethink:"type"`
+		Severity    string    `json:"severity,omitempty" gorethink:"severity"`
+		Environment string    `json:"environment,omitempty" gorethink:"environment"`
+		Team        string    `json:"team,omitempty" gorethink:"team"`
+		Text        string    `json:"text,omitempty" gorethink:"text"`
+		Time        time.Time `json:"time,omitempty" gorethink:"time"`
+		// Tags are the tags of the event (i.e. the type of an alert)
+		Tags []string `json:"tags,omitempty" gorethink:"tags,omitempty"`
+		// Runbook is the notes and runbook links of what the message is
+		// about
+		Runbook string `json:"runbook,omitempty" gorethink:"runbook,omitempty"`
+	}
+
+	// Escalation tracks a critical notification until it is acknowledged;
+	// EscalateAfter minutes (in the rule) after Time it is sent on to the
+	// escalation notifiers. Held escalations are messages which arrived
+	// in the quiet hours of their rule; they are sent to Notifiers when
+	// the quiet hours end (Due) unless acknowledged before.
+	Escalation struct {
+		ID             string    `json:"id,omitempty" gorethink:"id,omitempty"`
+		RuleID         string    `json:"rule_id,omitempty" gorethink:"rule_id"`
+		Message        *Message  `json:"message,omitempty" gorethink:"message"`
+		Due            time.Time `json:"due,omitempty" gorethink:"due"`
+		Acknowledged   bool      `json:"acknowledged" gorethink:"acknowledged"`
+		AcknowledgedBy string    `json:"acknowledged_by,omitempty" gorethink:"acknowledged_by"`
+		Escalated      bool      `json:"escalated" gorethink:"escalated"`
+		Held           bool      `json:"held,omitempty" gorethink:"held,omitempty"`
+		Notifiers      []string  `json:"notifiers,omitempty" gorethink:"notifiers,omitempty"`
+	}
+)
+
+// SeverityLevel orders severities; unknown severities are info
+func SeverityLevel(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func matchesAny(value string, values []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches reports whether the message should be routed by the rule; rules
+// without a minimum severity only route warnings and above
+func (r *Rule) Matches(msg *Message) bool {
+	min := r.MinSeverity
+	if min == "" {
+		min = SeverityWarning
+	}
+
+	if SeverityLevel(msg.Severity) < SeverityLevel(min) {
+		return false
+	}
+
+	return matchesAny(msg.Environment, r.Environments) && matchesAny(msg.Team, r.Teams)
+}
+
+// Validate checks the quiet hours format
+func (q *QuietHours) Validate() error {
+	if _, err := time.Parse("15:04", q.Start); err != nil {
+		return ErrInvalidQuietHours
+	}
+	if _, err := time.Parse("15:04", q.End); err != nil {
+		return ErrInvalidQuietHours
+	}
+	return nil
+}
+
+// Active reports whether t falls in the quiet hours window
+func (q *QuietHours) Active(t time.Time) bool {
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	s := start.Hour()*60 + start.Minute()
+	e := end.Hour()*60 + end.Minute()
+
+	// window spanning midnight
+	if s > e {
+		return now >= s || now < e
+	}
+
+	return now >= s && now < e
+}
+
+// Until returns when the quiet hours active at t end
+func (q *QuietHours) Until(t time.Time) time.Time {
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return t
+	}
+
+	until := time.Date(t.Year(), t.Month(), t.Day(), end.Hour(), end.Minute(), 0, 0, t.Location())
+	if !until.After(t) {
+		until = until.AddDate(0, 0, 1)
+	}
+
+	return until
+}
+
+// Validate checks the notifier has what its type requires
+func (n *Notifier) Validate() error {
+	for _, credential := range []string{n.URL, n.RoutingKey, n.SMTPPassword} {
+		if credential == Redacted {
+			return ErrRedactedCredential
+		}
+	}
+
+	switch n.Type {
+	case TypeSlack, TypeWebhook:
+		if n.URL == "" {
+			return fmt.Errorf("url is required for %s notifiers", n.Type)
+		}
+	case TypePagerDuty:
+		if n.RoutingKey == "" {
+			return fmt.Errorf("routing key is required for %s notifiers", n.Type)
+		}
+	case TypeEmail:
+		if n.SMTPAddr == "" || n.From == "" || len(n.To) == 0 {
+			return fmt.Errorf("smtp address, from and to are required for %s notifiers", n.Type)
+		}
+	default:
+		return ErrUnknownNotifierType
+	}
+
+	return nil
+}
+
+// WithoutCredentials returns a copy of the notifier with the url, routing
+// key and smtp password replaced by Redacted; the urls of slack and
+// webhook notifiers carry their token
+func (n *Notifier) WithoutCredentials() *Notifier {
+	c := *n
+	for _, credential := range []*string{&c.URL, &c.RoutingKey, &c.SMTPPassword} {
+		if *credential != "" {
+			*credential = Redacted
+		}
+	}
+
+	return &c
+}
+
+// KeepCredentials takes the credentials of the stored notifier for the
+// ones sent back redacted; an empty smtp password keeps the stored one too
+func (n *Notifier) KeepCredentials(stored *Notifier) {
+	if n.URL == Redacted {
+		n.URL = stored.URL
+	}
+
+	if n.RoutingKey == Redacted {
+		n.RoutingKey = stored.RoutingKey
+	}
+
+	if n.SMTPPassword == Redacted || n.SMTPPassword == "" {
+		n.SMTPPassword = stored.SMTPPassword
+	}
+}
+
+// Accepts reports whether the notifier takes the message; the event types
+// are matched against the type and the tags of the event
+func (n *Notifier) Accepts(msg *Message) bool {
+	if len(n.EventTypes) == 0 {
+		return true
+	}
+
+	values := append([]string{msg.Type}, msg.Tags...)
+	for _, t := range n.EventTypes {
+		for _, v := range values {
+			if t == v || (strings.HasSuffix(t, "*") && strings.HasPrefix(v, strings.TrimSuffix(t, "*"))) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// emailMessage returns the mail of the message
+func emailMessage(n *Notifier, msg *Message) []byte {
+	body := msg.Text
+	if msg.Runbook != "" {
+		body = fmt.Sprintf("%s\n\nrunbook:\n%s", body, msg.Runbook)
+	}
+
+	headers := []string{
+		"From: " + n.From,
+		"To: " + strings.Join(n.To, ", "),
+		fmt.Sprintf("Subject: [shipyard] [%s] %s", msg.Severity, msg.Type),
+		"Date: " + msg.Time.Format(time.RFC1123Z),
+		"Content-Type: text/plain; charset=utf-8",
+	}
+
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.Replace(body, "\n", "\r\n", -1) + "\r\n")
+}
+
+func sendEmail(n *Notifier, msg *Message) error {
+	var a smtp.Auth
+	if n.SMTPUsername != "" {
+		host := n.SMTPAddr
+		if i := strings.LastIndex(host, ":"); i > -1 {
+			host = host[:i]
+		}
+		a = smtp.PlainAuth("", n.SMTPUsername, n.SMTPPassword, host)
+	}
+
+	if err := smtp.SendMail(n.SMTPAddr, a, n.From, n.To, emailMessage(n, msg)); err != nil {
+		return fmt.Errorf("error sending notification to %s: %s", n.Name, err)
+	}
+
+	return nil
+}
+
+// Send delivers the message to the notifier
+func Send(n *Notifier, msg *Message) error {
+	var payload interface{}
+	url := n.URL
+
+	switch n.Type {
+	case TypeSlack:
+		text := fmt.Sprintf("[%s] %s", msg.Severity, msg.Text)
+		if msg.Runbook != "" {
+			text = fmt.Sprintf("%s\nrunbook:\n%s", text, msg.Runbook)
+		}
+		payload = map[string]string{
+			"text": text,
+		}
+	case TypePagerDuty:
+		if url == "" {
+			url = pagerDutyURL
+		}
+		details := map[string]interface{}{
+			"summary":  msg.Text,
+			"source":   "shipyard",
+			"severity": msg.Severity,
+		}
+		if msg.Runbook != "" {
+			details["custom_details"] = map[string]string{"runbook": msg.Runbook}
+		}
+		payload = map[string]interface{}{
+			"routing_key":  n.RoutingKey,
+			"event_action": "trigger",
+			"payload":      details,
+		}
+	case TypeWebhook:
+		payload = msg
+	case TypeEmail:
+		return sendEmail(n, msg)
+	default:
+		return ErrUnknownNotifierType
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: sendTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending notification to %s: %s", n.Name, resp.Status)
+	}
+
+	return nil
+}
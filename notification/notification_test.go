@@ -0,0 +1,226 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleMatches(t *testing.T) {
+	rule := &Rule{
+		Environments: []string{"prod"},
+	}
+
+	msg := &Message{
+		Severity:    SeverityCritical,
+		Environment: "prod",
+	}
+	if !rule.Matches(msg) {
+		t.Fatalf("expected rule to match critical prod message")
+	}
+
+	msg.Environment = "dev"
+	if rule.Matches(msg) {
+		t.Fatalf("expected rule to not match dev message")
+	}
+
+	msg = &Message{
+		Severity:    SeverityInfo,
+		Environment: "prod",
+	}
+	if rule.Matches(msg) {
+		t.Fatalf("expected rule without minimum severity to ignore info messages")
+	}
+
+	rule.MinSeverity = SeverityInfo
+	if !rule.Matches(msg) {
+		t.Fatalf("expected rule to match info message")
+	}
+}
+
+func TestQuietHoursActive(t *testing.T) {
+	q := &QuietHours{
+		Start: "22:00",
+		End:   "07:00",
+	}
+
+	night := time.Date(2016, 1, 1, 23, 30, 0, 0, time.UTC)
+	if !q.Active(night) {
+		t.Fatalf("expected quiet hours to be active at 23:30")
+	}
+
+	morning := time.Date(2016, 1, 1, 6, 59, 0, 0, time.UTC)
+	if !q.Active(morning) {
+		t.Fatalf("expected quiet hours to be active at 06:59")
+	}
+
+	day := time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC)
+	if q.Active(day) {
+		t.Fatalf("expected quiet hours to be inactive at 12:00")
+	}
+}
+
+func TestNotifierValidate(t *testing.T) {
+	n := &Notifier{
+		Type: TypeSlack,
+	}
+	if err := n.Validate(); err == nil {
+		t.Fatalf("expected error for slack notifier without url")
+	}
+
+	n = &Notifier{
+		Type: "unknown",
+	}
+	if err := n.Validate(); err != ErrUnknownNotifierType {
+		t.Fatalf("expected unknown notifier type error; received %v", err)
+	}
+
+	n = &Notifier{
+		Type:     TypeEmail,
+		SMTPAddr: "smtp.example.com:587",
+		From:     "shipyard@example.com",
+	}
+	if err := n.Validate(); err == nil {
+		t.Fatalf("expected error for email notifier without recipients")
+	}
+
+	n.To = []string{"ops@example.com"}
+	if err := n.Validate(); err != nil {
+		t.Fatalf("expected valid email notifier; received %v", err)
+	}
+}
+
+func TestNotifierCredentials(t *testing.T) {
+	stored := &Notifier{
+		Type:         TypePagerDuty,
+		URL:          "https://hooks.slack.com/services/T0/B0/token",
+		RoutingKey:   "routing-key",
+		SMTPPassword: "secret",
+	}
+
+	redacted := stored.WithoutCredentials()
+	for _, credential := range []string{redacted.URL, redacted.RoutingKey, redacted.SMTPPassword} {
+		if credential != Redacted {
+			t.Fatalf("expected the credentials to be redacted; received %+v", redacted)
+		}
+	}
+
+	if stored.RoutingKey != "routing-key" {
+		t.Fatal("expected the stored notifier to keep its credentials")
+	}
+
+	if err := redacted.Validate(); err != ErrRedactedCredential {
+		t.Fatalf("expected redacted credentials to be refused; received %v", err)
+	}
+
+	redacted.URL = "https://hooks.slack.com/services/T0/B0/other"
+	redacted.KeepCredentials(stored)
+	if redacted.URL != "https://hooks.slack.com/services/T0/B0/other" || redacted.RoutingKey != stored.RoutingKey || redacted.SMTPPassword != stored.SMTPPassword {
+		t.Fatalf("expected the redacted credentials to be kept and the new url taken; received %+v", redacted)
+	}
+
+	if err := redacted.Validate(); err != nil {
+		t.Fatalf("expected the kept credentials to be valid; received %v", err)
+	}
+}
+
+func TestNotifierAccepts(t *testing.T) {
+	n := &Notifier{
+		EventTypes: []string{"die", "add-account", "redeploy*", "node-down"},
+	}
+
+	tests := []struct {
+		msg    *Message
+		accept bool
+	}{
+		{&Message{Type: "die"}, true},
+		{&Message{Type: "add-account"}, true},
+		{&Message{Type: "redeploy-stack"}, true},
+		{&Message{Type: "alert", Tags: []string{"alert", "node-down", "critical"}}, true},
+		{&Message{Type: "alert", Tags: []string{"alert", "crash-loop"}}, false},
+		{&Message{Type: "login"}, false},
+	}
+
+	for _, test := range tests {
+		if accept := n.Accepts(test.msg); accept != test.accept {
+			t.Errorf("%s %v: expected %v; received %v", test.msg.Type, test.msg.Tags, test.accept, accept)
+		}
+	}
+
+	if !(&Notifier{}).Accepts(&Message{Type: "login"}) {
+		t.Fatalf("expected notifiers without event types to accept any event")
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	n := &Notifier{
+		Type: TypeEmail,
+		From: "shipyard@example.com",
+		To:   []string{"ops@example.com", "dev@example.com"},
+	}
+
+	msg := &Message{
+		Type:     "die",
+		Severity: SeverityCritical,
+		Text:     "die: web exited",
+		Runbook:  "restart it",
+		Time:     time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	mail := string(emailMessage(n, msg))
+
+	for _, expected := range []string{
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [shipyard] [critical] die\r\n",
+		"\r\n\r\ndie: web exited\r\n\r\nrunbook:\r\nrestart it\r\n",
+	} {
+		if !strings.Contains(mail, expected) {
+			t.Fatalf("expected %q in %q", expected, mail)
+		}
+	}
+}
+
+func TestSendSlackRunbook(t *testing.T) {
+	var payload map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	n := &Notifier{Name: "ops", Type: TypeSlack, URL: ts.URL}
+	msg := &Message{
+		Severity: SeverityCritical,
+		Text:     "alert: node node-1 is down",
+		Runbook:  "https://wiki.local/runbooks/node-down",
+	}
+
+	if err := Send(n, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(payload["text"], "runbook:\nhttps://wiki.local/runbooks/node-down") {
+		t.Fatalf("expected the runbook in the message; received %q", payload["text"])
+	}
+}
+
+func TestQuietHoursUntil(t *testing.T) {
+	q := &QuietHours{
+		Start: "22:00",
+		End:   "07:00",
+	}
+
+	night := time.Date(2016, 1, 1, 23, 30, 0, 0, time.UTC)
+	if until := q.Until(night); !until.Equal(time.Date(2016, 1, 2, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the quiet hours to end the next morning; received %s", until)
+	}
+
+	morning := time.Date(2016, 1, 2, 6, 0, 0, 0, time.UTC)
+	if until := q.Until(morning); !until.Equal(time.Date(2016, 1, 2, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the quiet hours to end the same morning; received %s", until)
+	}
+}
@@ -2,48 +2,115 @@ package shipyard
 
 import (
 	"crypto/tls"
-	registry "github.com/shipyard/shipyard/registry/v2"
+	"crypto/x509"
+	"errors"
+	"net/url"
 	"strings"
+
+	v1 "github.com/shipyard/shipyard/registry/v1"
+	registry "github.com/shipyard/shipyard/registry/v2"
+)
+
+const (
+	RegistryV1 = "v1"
+	RegistryV2 = "v2"
+)
+
+var (
+	ErrRegistryVersionNotSupported = errors.New("not supported by v1 registries")
+	ErrInvalidRegistryCA           = errors.New("the ca bundle of the registry has no PEM certificates")
 )
 
 type Registry struct {
-	ID             string                   `json:"id,omitempty" gorethink:"id,omitempty"`
-	Name           string                   `json:"name,omitempty" gorethink:"name,omitempty"`
-	Addr           string                   `json:"addr,omitempty" gorethink:"addr,omitempty"`
-	Username       string                   `json:"username,omitempty" gorethink:"username,omitempty"`
-	Password       string                   `json:"password,omitempty" gorethink:"password,omitempty"`
-	TlsSkipVerify  bool                     `json:"tls_skip_verify,omitempty" gorethink:"tls_skip_verify,omitempty"`
+	ID            string `json:"id,omitempty" gorethink:"id,omitempty"`
+	Name          string `json:"name,omitempty" gorethink:"name,omitempty"`
+	Addr          string `json:"addr,omitempty" gorethink:"addr,omitempty"`
+	Username      string `json:"username,omitempty" gorethink:"username,omitempty"`
+	Password      string `json:"password,omitempty" gorethink:"password,omitempty"`
+	TlsSkipVerify bool   `json:"tls_skip_verify,omitempty" gorethink:"tls_skip_verify,omitempty"`
+	// Token is used instead of the username and password when set
+	Token string `json:"token,omitempty" gorethink:"token,omitempty"`
+	// CACert is a PEM bundle of the certificate authorities of a registry
+	// with a private certificate
+	CACert string `json:"ca_cert,omitempty" gorethink:"ca_cert,omitempty"`
+	// Version is the registry api version (v1 or v2); registries saved
+	// without a version are v2
+	Version        string                   `json:"version,omitempty" gorethink:"version,omitempty"`
 	registryClient *registry.RegistryClient `json:"-" gorethink:"-"`
+	v1Client       *v1.RegistryClient       `json:"-" gorethink:"-"`
 }
 
 func NewRegistry(id, name, addr, username, password string, tls_skip_verify bool) (*Registry, error) {
-	var tlsConfig *tls.Config
-
-	if tls_skip_verify {
-		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	r := &Registry{
+		ID:            id,
+		Name:          name,
+		Addr:          addr,
+		Username:      username,
+		Password:      password,
+		TlsSkipVerify: tls_skip_verify,
 	}
 
-	rClient, err := registry.NewRegistryClient(addr, tlsConfig, username, password)
-	if err != nil {
+	if err := r.InitRegistryClient(); err != nil {
 		return nil, err
 	}
 
-	return &Registry{
-		ID:             id,
-		Name:           name,
-		Addr:           addr,
-		Username:       username,
-		Password:       password,
-		TlsSkipVerify:  tls_skip_verify,
-		registryClient: rClient,
-	}, nil
+	return r, nil
+}
+
+func (r *Registry) isV1() bool {
+	return r.Version == RegistryV1
+}
+
+// TLSConfig returns the tls config for the registry; nil uses the system
+// certificate authorities
+func (r *Registry) TLSConfig() (*tls.Config, error) {
+	if !r.TlsSkipVerify && r.CACert == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: r.TlsSkipVerify}
+	if r.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(r.CACert)) {
+			return nil, ErrInvalidRegistryCA
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// Host returns the host and port of the registry as used in image names
+func (r *Registry) Host() string {
+	u, err := url.Parse(r.Addr)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(r.Addr, "/")
+	}
+
+	return u.Host
+}
+
+// Redact clears the secrets of the registry before it is returned by the
+// api
+func (r *Registry) Redact() {
+	r.Password = ""
+	r.Token = ""
 }
 
 func (r *Registry) InitRegistryClient() error {
-	var tlsConfig *tls.Config
+	tlsConfig, err := r.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if r.isV1() {
+		rClient, err := v1.NewRegistryClient(r.Addr, tlsConfig)
+		if err != nil {
+			return err
+		}
 
-	if r.TlsSkipVerify {
-		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+		r.v1Client = rClient
+		return nil
 	}
 
 	rClient, err := registry.NewRegistryClient(r.Addr, tlsConfig, r.Username, r.Password)
@@ -51,12 +118,54 @@ func (r *Registry) InitRegistryClient() error {
 		return err
 	}
 
+	rClient.Token = r.Token
 	r.registryClient = rClient
 
 	return nil
 }
 
+// v1Error returns the v2 error for not found so callers handle both
+// versions the same
+func v1Error(err error) error {
+	if err == v1.ErrNotFound {
+		return registry.ErrNotFound
+	}
+
+	return err
+}
+
+// fromV1 converts a v1 repository to one entry per tag
+func (r *Registry) fromV1(repo *v1.Repository) []*registry.Repository {
+	repos := []*registry.Repository{}
+	for _, t := range repo.Tags {
+		repos = append(repos, &registry.Repository{
+			Name:         repo.Name,
+			Tag:          t.Name,
+			Digest:       t.ID,
+			RegistryUrl:  r.Addr,
+			RegistryName: r.Name,
+			Size:         repo.Size,
+		})
+	}
+
+	return repos
+}
+
 func (r *Registry) Repositories() ([]*registry.Repository, error) {
+	if r.isV1() {
+		res, err := r.v1Client.Search("", 1, 100)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+
+		repos := []*registry.Repository{}
+		for _, repo := range res.Results {
+			repos = append(repos, r.fromV1(repo)...)
+		}
+
+		return repos, nil
+	}
+
 	res, err := r.registryClient.Search("")
 	if err != nil {
 		return nil, err
@@ -73,9 +182,92 @@ func (r *Registry) Repository(name string) (*registry.Repository, error) {
 		repoPath = parts[0]
 		tag = parts[1]
 	}
+
+	if r.isV1() {
+		repo, err := r.v1Client.Repository(repoPath)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+
+		for _, t := range r.fromV1(repo) {
+			if t.Tag == tag {
+				return t, nil
+			}
+		}
+
+		return nil, registry.ErrNotFound
+	}
+
 	return r.registryClient.Repository(r.Addr, repoPath, tag)
 }
 
 func (r *Registry) DeleteRepository(name string) error {
+	if r.isV1() {
+		return v1Error(r.v1Client.DeleteRepository(name))
+	}
+
 	return r.registryClient.DeleteRepository(name)
 }
+
+// Tags returns the tags of a repository
+func (r *Registry) Tags(name string) ([]string, error) {
+	if r.isV1() {
+		repo, err := r.v1Client.Repository(name)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+
+		tags := []string{}
+		for _, t := range repo.Tags {
+			tags = append(tags, t.Name)
+		}
+
+		return tags, nil
+	}
+
+	return r.registryClient.Tags(name)
+}
+
+// DeleteTag deletes a tag of a repository; tags of v2 registries sharing
+// their manifest with other tags are not deleted
+func (r *Registry) DeleteTag(name, tag string) error {
+	if r.isV1() {
+		return v1Error(r.v1Client.DeleteTag(name, tag))
+	}
+
+	return r.registryClient.DeleteTagOnly(name, tag)
+}
+
+// Manifest returns the manifest of a repository tag or digest
+func (r *Registry) Manifest(name, reference string) (*registry.ManifestInfo, error) {
+	if r.isV1() {
+		return nil, ErrRegistryVersionNotSupported
+	}
+
+	return r.registryClient.Manifest(name, reference)
+}
+
+// DeleteManifest deletes a manifest by digest; v1 registries delete tags
+// instead
+func (r *Registry) DeleteManifest(name, reference string) error {
+	if r.isV1() {
+		return v1Error(r.v1Client.DeleteTag(name, reference))
+	}
+
+	return r.registryClient.DeleteManifest(name, reference)
+}
+
+// Layers returns the digests of the layers of an image base layer first
+func (r *Registry) Layers(name, reference string) ([]string, error) {
+	if r.isV1() {
+		return nil, ErrRegistryVersionNotSupported
+	}
+
+	return r.registryClient.Layers(name, reference)
+}
+
+// BlobURL returns the url of a blob of a v2 registry and the headers to
+// download it with
+func (r *Registry) BlobURL(name, digest string) (string, map[string]string) {
+	return r.registryClient.BlobURL(name, digest), r.registryClient.AuthHeaders()
+}
@@ -0,0 +1,93 @@
+package v2
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// imageManifest holds the layers of the manifest formats; fsLayers are
+// schema 1 and listed newest first, layers the others listed base first
+type imageManifest struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	MediaType     string    `json:"mediaType"`
+	FsLayers      []FsLayer `json:"fsLayers"`
+	Layers        []struct {
+		Digest string `json:"digest"`
+	} `json:"layers"`
+	// Manifests are the images of a manifest list or index
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// Layers returns the digests of the layers of an image base layer first;
+// the linux/amd64 image, or the first, of manifest lists is used
+func (client *RegistryClient) Layers(repo, reference string) ([]string, error) {
+	info, err := client.Manifest(repo, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	var m imageManifest
+	if err := json.Unmarshal(info.Manifest, &m); err != nil {
+		return nil, err
+	}
+
+	if len(m.Manifests) > 0 {
+		digest := m.Manifests[0].Digest
+		for _, image := range m.Manifests {
+			if image.Platform.OS == "linux" && image.Platform.Architecture == "amd64" {
+				digest = image.Digest
+				break
+			}
+		}
+
+		return client.Layers(repo, digest)
+	}
+
+	layers := []string{}
+	if m.SchemaVersion == 1 {
+		for i := len(m.FsLayers) - 1; i >= 0; i-- {
+			// schema 1 repeats the empty layer for each instruction
+			// without a filesystem change
+			if n := len(layers); n > 0 && layers[n-1] == m.FsLayers[i].BlobSum {
+				continue
+			}
+			layers = append(layers, m.FsLayers[i].BlobSum)
+		}
+
+		return layers, nil
+	}
+
+	for _, l := range m.Layers {
+		layers = append(layers, l.Digest)
+	}
+
+	return layers, nil
+}
+
+// BlobURL returns the url a blob of the repository is downloaded from
+func (client *RegistryClient) BlobURL(repo, digest string) string {
+	return fmt.Sprintf("%s/v2/%s/blobs/%s", strings.TrimSuffix(client.URL.String(), "/"), repo, digest)
+}
+
+// AuthHeaders returns the headers authenticating requests to the registry
+// for others downloading from it (i.e. a scanner)
+func (client *RegistryClient) AuthHeaders() map[string]string {
+	if client.Token != "" {
+		return map[string]string{"Authorization": "Bearer " + client.Token}
+	}
+
+	if client.Username == "" {
+		return map[string]string{}
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(client.Username + ":" + client.Password))
+	return map[string]string{"Authorization": "Basic " + auth}
+}
@@ -0,0 +1,52 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/manifests/multi"):
+			fmt.Fprint(w, `{"schemaVersion":2,"manifests":[{"digest":"sha256:arm","platform":{"architecture":"arm64","os":"linux"}},{"digest":"sha256:amd","platform":{"architecture":"amd64","os":"linux"}}]}`)
+		case strings.HasSuffix(r.URL.Path, "/manifests/sha256:amd"), strings.HasSuffix(r.URL.Path, "/manifests/latest"):
+			fmt.Fprint(w, `{"schemaVersion":2,"layers":[{"digest":"sha256:base"},{"digest":"sha256:app"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/manifests/v1"):
+			fmt.Fprint(w, `{"schemaVersion":1,"fsLayers":[{"blobSum":"sha256:app"},{"blobSum":"sha256:empty"},{"blobSum":"sha256:empty"},{"blobSum":"sha256:base"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewRegistryClient(srv.URL, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"latest": {"sha256:base", "sha256:app"},
+		"multi":  {"sha256:base", "sha256:app"},
+		"v1":     {"sha256:base", "sha256:empty", "sha256:app"},
+	}
+
+	for reference, expected := range tests {
+		layers, err := client.Layers("web", reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(layers, expected) {
+			t.Fatalf("expected %v for %s; received %v", expected, reference, layers)
+		}
+	}
+
+	if url := client.BlobURL("team/web", "sha256:app"); url != srv.URL+"/v2/team/web/blobs/sha256:app" {
+		t.Fatalf("unexpected blob url %s", url)
+	}
+}
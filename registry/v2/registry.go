@@ -18,7 +18,19 @@ import (
 
 var (
 	ErrNotFound        = errors.New("Not found")
+	ErrInvalidDigest   = errors.New("invalid digest; expected a content digest (i.e. sha256:...)")
+	ErrTagShared       = errors.New("other tags point to the manifest of the tag; delete the manifest by digest to remove them all")
 	defaultHTTPTimeout = 30 * time.Second
+
+	// manifestMediaTypes are the manifest formats accepted from the registry
+	manifestMediaTypes = []string{
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.oci.image.index.v1+json",
+	}
+
+	catalogPageSize = 100
 )
 
 type RegistryClient struct {
@@ -27,6 +39,9 @@ type RegistryClient struct {
 	httpClient *http.Client
 	Username   string
 	Password   string
+	// Token is sent as a bearer token instead of the username and
+	// password when set
+	Token string
 }
 
 type Repo struct {
@@ -38,6 +53,16 @@ type TagList struct {
 	Tags []string `json:"tags"`
 }
 
+// ManifestInfo is a manifest as stored in the registry
+type ManifestInfo struct {
+	Name      string          `json:"name"`
+	Reference string          `json:"reference"`
+	Digest    string          `json:"digest"`
+	MediaType string          `json:"media_type"`
+	Size      int64           `json:"size"`
+	Manifest  json.RawMessage `json:"manifest"`
+}
+
 func newHTTPClient(u *url.URL, tlsConfig *tls.Config, timeout time.Duration) *http.Client {
 	httpTransport := &http.Transport{
 		TLSClientConfig: tlsConfig,
@@ -74,7 +99,11 @@ func (client *RegistryClient) doRequest(method string, path string, body []byte,
 		return nil, nil, err
 	}
 
-	req.SetBasicAuth(client.Username, client.Password)
+	if client.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.Token)
+	} else {
+		req.SetBasicAuth(client.Username, client.Password)
+	}
 
 	req.Header.Add("Content-Type", "application/json")
 	if headers != nil {
@@ -84,27 +113,125 @@ func (client *RegistryClient) doRequest(method string, path string, body []byte,
 	}
 
 	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer resp.Body.Close()
 
 	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, ErrNotFound
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, nil, Error{StatusCode: resp.StatusCode, Status: resp.Status, msg: string(data)}
+	}
 
 	return data, resp.Header, nil
 }
 
-func (client *RegistryClient) Search(query string) ([]*Repository, error) {
-	type repo struct {
+// Catalog returns the names of all repositories following the catalog
+// pagination
+func (client *RegistryClient) Catalog() ([]string, error) {
+	type catalog struct {
 		Repositories []string `json:"repositories"`
 	}
 
-	uri := fmt.Sprintf("/_catalog")
-	data, _, err := client.doRequest("GET", uri, nil, nil)
+	names := []string{}
+	uri := fmt.Sprintf("/_catalog?n=%d", catalogPageSize)
+	for uri != "" {
+		data, hdr, err := client.doRequest("GET", uri, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res := &catalog{}
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, err
+		}
+
+		names = append(names, res.Repositories...)
+		uri = nextPage(hdr.Get("Link"))
+	}
+
+	return names, nil
+}
+
+// nextPage returns the path of the next page from a Link header
+// (i.e. </v2/_catalog?last=b&n=100>; rel="next") relative to /v2
+func nextPage(link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	next := link[start+1 : end]
+	if u, err := url.Parse(next); err == nil {
+		next = u.RequestURI()
+	}
+
+	return strings.TrimPrefix(next, "/v2")
+}
+
+// Tags returns the tags of the repository
+func (client *RegistryClient) Tags(repo string) ([]string, error) {
+	tl, err := client.getTags(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return tl.Tags, nil
+}
+
+// Manifest returns the manifest for a tag or digest
+func (client *RegistryClient) Manifest(repo, reference string) (*ManifestInfo, error) {
+	headers := map[string]string{
+		"Accept": strings.Join(manifestMediaTypes, ", "),
+	}
+
+	uri := fmt.Sprintf("/%s/manifests/%s", repo, reference)
+	data, hdr, err := client.doRequest("GET", uri, nil, headers)
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
 
-	res := &repo{}
-	if err := json.Unmarshal(data, &res); err != nil {
+	return &ManifestInfo{
+		Name:      repo,
+		Reference: reference,
+		Digest:    hdr.Get("Docker-Content-Digest"),
+		MediaType: hdr.Get("Content-Type"),
+		Size:      int64(len(data)),
+		Manifest:  json.RawMessage(data),
+	}, nil
+}
+
+// DeleteManifest deletes a manifest by digest; every tag pointing to it
+// is removed
+func (client *RegistryClient) DeleteManifest(repo, digest string) error {
+	if !strings.Contains(digest, ":") {
+		return ErrInvalidDigest
+	}
+
+	uri := fmt.Sprintf("/%s/manifests/%s", repo, digest)
+	if _, _, err := client.doRequest("DELETE", uri, nil, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (client *RegistryClient) Search(query string) ([]*Repository, error) {
+	names, err := client.Catalog()
+	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
@@ -112,7 +239,7 @@ func (client *RegistryClient) Search(query string) ([]*Repository, error) {
 	repos := []*Repository{}
 
 	// simple filter for list
-	for _, k := range res.Repositories {
+	for _, k := range names {
 		if strings.Index(k, query) == 0 {
 			tl, err := client.getTags(k)
 			if err != nil {
@@ -163,17 +290,61 @@ func (client *RegistryClient) DeleteRepository(repo string) error {
 }
 
 func (client *RegistryClient) DeleteTag(repo string, tag string) error {
-	r, err := client.Repository(client.URL.String(), repo, tag)
+	// the digest must be of the manifest as pushed, not the schema 1
+	// manifest returned without an accept header
+	m, err := client.Manifest(repo, tag)
 	if err != nil {
 		return err
 	}
 
-	uri := fmt.Sprintf("/%s/manifests/%s", repo, r.Digest)
-	if _, _, err := client.doRequest("DELETE", uri, nil, nil); err != nil {
+	return client.DeleteManifest(repo, m.Digest)
+}
+
+// TagDigest returns the digest of the manifest the tag points to
+func (client *RegistryClient) TagDigest(repo, tag string) (string, error) {
+	headers := map[string]string{
+		"Accept": strings.Join(manifestMediaTypes, ", "),
+	}
+
+	uri := fmt.Sprintf("/%s/manifests/%s", repo, tag)
+	_, hdr, err := client.doRequest("HEAD", uri, nil, headers)
+	if err != nil {
+		return "", err
+	}
+
+	return hdr.Get("Docker-Content-Digest"), nil
+}
+
+// DeleteTagOnly deletes the tag when no other tag of the repository points
+// to its manifest; the registry can only delete manifests, which would
+// remove the other tags as well
+func (client *RegistryClient) DeleteTagOnly(repo, tag string) error {
+	digest, err := client.TagDigest(repo, tag)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	tags, err := client.Tags(repo)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		if t == tag {
+			continue
+		}
+
+		d, err := client.TagDigest(repo, t)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		if d == digest {
+			return ErrTagShared
+		}
+	}
+
+	return client.DeleteManifest(repo, digest)
 }
 
 func (client *RegistryClient) Repository(registryUrl, name, tag string) (*Repository, error) {
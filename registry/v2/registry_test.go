@@ -0,0 +1,137 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNextPage(t *testing.T) {
+	tests := map[string]string{
+		``: "",
+		`</v2/_catalog?last=b&n=100>; rel="next"`:                      "/_catalog?last=b&n=100",
+		`<https://reg.example.com/v2/_catalog?last=b&n=2>; rel="next"`: "/_catalog?last=b&n=2",
+		`</v2/_catalog?last=b&n=100>; rel="prev"`:                      "",
+	}
+
+	for link, expected := range tests {
+		if next := nextPage(link); next != expected {
+			t.Fatalf("expected %q for %q; received %q", expected, link, next)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=busybox&n=%d>; rel="next"`, catalogPageSize))
+			fmt.Fprint(w, `{"repositories":["alpine","busybox"]}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"repositories":["nginx"]}`)
+	}))
+	defer srv.Close()
+
+	client, err := NewRegistryClient(srv.URL, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := client.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"alpine", "busybox", "nginx"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v; received %v", expected, names)
+	}
+}
+
+func TestDeleteManifestInvalidDigest(t *testing.T) {
+	client, err := NewRegistryClient("http://127.0.0.1:1", nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteManifest("busybox", "latest"); err != ErrInvalidDigest {
+		t.Fatalf("expected ErrInvalidDigest; received %v", err)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	authorization := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"repositories":[]}`)
+	}))
+	defer srv.Close()
+
+	client, err := NewRegistryClient(srv.URL, nil, "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Token = "abc"
+
+	if _, err := client.Catalog(); err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Bearer abc" {
+		t.Fatalf("expected the token to replace the password; received %q", authorization)
+	}
+}
+
+func TestDeleteTagOnly(t *testing.T) {
+	deleted := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/web/tags/list":
+			fmt.Fprint(w, `{"tags":["1","2","latest"]}`)
+		case r.Method == "HEAD":
+			digests := map[string]string{
+				"/v2/web/manifests/1":      "sha256:aaa",
+				"/v2/web/manifests/2":      "sha256:bbb",
+				"/v2/web/manifests/latest": "sha256:bbb",
+			}
+
+			digest, ok := digests[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+		case r.Method == "DELETE":
+			deleted = r.URL.Path
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewRegistryClient(srv.URL, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteTagOnly("web", "2"); err != ErrTagShared {
+		t.Fatalf("expected ErrTagShared; received %v", err)
+	}
+
+	if err := client.DeleteTagOnly("web", "3"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound; received %v", err)
+	}
+
+	if deleted != "" {
+		t.Fatalf("expected no manifest to be deleted; received %s", deleted)
+	}
+
+	if err := client.DeleteTagOnly("web", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != "/v2/web/manifests/sha256:aaa" {
+		t.Fatalf("expected the manifest of the tag to be deleted; received %s", deleted)
+	}
+}
@@ -26,7 +26,7 @@ type (
 
 	Signature struct {
 		Header    Header `json:"header"`
-		Signature string `json:"signature`
+		Signature string `json:"signature"`
 		Protected string `json:"protected"`
 	}
 
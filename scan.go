@@ -0,0 +1,86 @@
+package shipyard
+
+import (
+	"errors"
+	"time"
+)
+
+// Severities of vulnerabilities as reported by Clair, lowest first
+const (
+	SeverityUnknown    = "Unknown"
+	SeverityNegligible = "Negligible"
+	SeverityLow        = "Low"
+	SeverityMedium     = "Medium"
+	SeverityHigh       = "High"
+	SeverityCritical   = "Critical"
+	SeverityDefcon1    = "Defcon1"
+
+	// Statuses of an image scan
+	ScanRunning = "running"
+	ScanDone    = "done"
+	ScanFailed  = "failed"
+)
+
+var (
+	ErrInvalidSeverity = errors.New("severities are Unknown, Negligible, Low, Medium, High, Critical or Defcon1")
+
+	severities = []string{SeverityUnknown, SeverityNegligible, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical, SeverityDefcon1}
+)
+
+// SeverityRank orders the severities; unknown names rank as Unknown
+func SeverityRank(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// ValidSeverity reports whether the severity is one of Clair's
+func ValidSeverity(severity string) bool {
+	for _, s := range severities {
+		if s == severity {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Vulnerability is a vulnerability of a package installed in an image
+type Vulnerability struct {
+	Name     string `json:"name" gorethink:"name"`
+	Severity string `json:"severity" gorethink:"severity"`
+	Package  string `json:"package" gorethink:"package"`
+	Version  string `json:"version" gorethink:"version"`
+	// FixedBy is the version of the package fixing the vulnerability
+	FixedBy string `json:"fixed_by,omitempty" gorethink:"fixed_by,omitempty"`
+	Link    string `json:"link,omitempty" gorethink:"link,omitempty"`
+}
+
+// ImageScan is the latest scan of an image tag of a registry; its ID is
+// the registry id, repository and tag (i.e. <registry>/web:1.2)
+type ImageScan struct {
+	ID         string `json:"id" gorethink:"id"`
+	Registry   string `json:"registry" gorethink:"registry"`
+	Repository string `json:"repository" gorethink:"repository"`
+	Tag        string `json:"tag" gorethink:"tag"`
+	// Digest is the digest of the top layer of the scanned image
+	Digest          string           `json:"digest,omitempty" gorethink:"digest,omitempty"`
+	Status          string           `json:"status" gorethink:"status"`
+	Error           string           `json:"error,omitempty" gorethink:"error,omitempty"`
+	Vulnerabilities []*Vulnerability `json:"vulnerabilities" gorethink:"vulnerabilities"`
+	// Counts are the number of vulnerabilities of each severity
+	Counts      map[string]int `json:"counts" gorethink:"counts"`
+	MaxSeverity string         `json:"max_severity,omitempty" gorethink:"max_severity,omitempty"`
+	ScannedBy   string         `json:"scanned_by,omitempty" gorethink:"scanned_by,omitempty"`
+	StartedAt   time.Time      `json:"started_at" gorethink:"started_at"`
+	FinishedAt  time.Time      `json:"finished_at,omitempty" gorethink:"finished_at,omitempty"`
+}
+
+// ImageScanID returns the id of the scan of a tag
+func ImageScanID(registry, repository, tag string) string {
+	return registry + "/" + repository + ":" + tag
+}
@@ -0,0 +1,46 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// Views a share link can grant
+const (
+	ShareViewLogs      = "logs"
+	ShareViewStats     = "stats"
+	ShareViewDashboard = "dashboard"
+)
+
+// ShareLink grants read-only access to views of a container or the
+// dashboard to someone without an account until it expires or is revoked;
+// the token is only returned when the link is created
+type ShareLink struct {
+	ID           string     `json:"id,omitempty" gorethink:"id,omitempty"`
+	Token        string     `json:"token,omitempty" gorethink:"-"`
+	ContainerID  string     `json:"container_id,omitempty" gorethink:"container_id,omitempty"`
+	Views        []string   `json:"views,omitempty" gorethink:"views"`
+	Description  string     `json:"description,omitempty" gorethink:"description,omitempty"`
+	CreatedBy    string     `json:"created_by,omitempty" gorethink:"created_by"`
+	CreatedAt    time.Time  `json:"created_at,omitempty" gorethink:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at,omitempty" gorethink:"expires_at"`
+	RevokedBy    string     `json:"revoked_by,omitempty" gorethink:"revoked_by,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" gorethink:"revoked_at,omitempty"`
+	AccessCount  int        `json:"access_count" gorethink:"access_count"`
+	LastAccessed *time.Time `json:"last_accessed,omitempty" gorethink:"last_accessed,omitempty"`
+}
+
+// Active reports whether the link can be used
+func (l *ShareLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt)
+}
+
+// Allows reports whether the link grants the view
+func (l *ShareLink) Allows(view string) bool {
+	for _, v := range l.Views {
+		if v == view {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package shipyard
+
+import (
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+// Stack is an application deployed from a docker-compose file; its
+// containers carry the compose project label with the stack name
+type Stack struct {
+	Name      string    `json:"name" gorethink:"id"`
+	Compose   string    `json:"compose" gorethink:"compose"`
+	Services  []string  `json:"services" gorethink:"services"`
+	CreatedBy string    `json:"created_by,omitempty" gorethink:"created_by"`
+	CreatedAt time.Time `json:"created_at,omitempty" gorethink:"created_at"`
+	UpdatedBy string    `json:"updated_by,omitempty" gorethink:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" gorethink:"updated_at,omitempty"`
+	// ExpiresAt is when the stack of a template run with a ttl is removed
+	ExpiresAt time.Time `json:"expires_at,omitempty" gorethink:"expires_at,omitempty"`
+
+	// Containers and Lock are only set when a stack is inspected
+	Containers []dockerclient.Container `json:"containers,omitempty" gorethink:"-"`
+	Lock       *StackLock               `json:"lock,omitempty" gorethink:"-"`
+}
+
+// StackLock is held by the deploy changing the containers of a stack so
+// deploys of the same stack do not interleave
+type StackLock struct {
+	Stack     string    `json:"stack"`
+	Username  string    `json:"username,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	// Controller is set when the deploy runs on another controller; the
+	// rest of the lock is then unknown
+	Controller string `json:"controller,omitempty"`
+}
+
+// Statuses of a stack operation and of its steps
+const (
+	StackStepDone     = "done"
+	StackStepFailed   = "failed"
+	StackStepTimedOut = "timed-out"
+	// StackStepSkipped is set on the steps after a failed step; they are
+	// not run since their services depend on it (start) or it on them
+	// (stop)
+	StackStepSkipped = "skipped"
+)
+
+// StackOperation is a stop or start of a stack which runs a step per
+// service in dependency order
+type StackOperation struct {
+	Stack      string       `json:"stack"`
+	Action     string       `json:"action"`
+	Status     string       `json:"status"`
+	Steps      []*StackStep `json:"steps"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+}
+
+// StackStep stops or starts the containers of a service
+type StackStep struct {
+	Service    string   `json:"service"`
+	Containers []string `json:"containers"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	// Duration is in seconds
+	Duration float64 `json:"duration"`
+}
@@ -0,0 +1,27 @@
+package shipyard
+
+import (
+	"time"
+)
+
+type (
+	// ContainerStats is a sample of the resource usage of a container;
+	// network bytes are counted since the container started
+	ContainerStats struct {
+		Time           time.Time `json:"time"`
+		CPUPercent     float64   `json:"cpu_percent"`
+		MemoryUsage    uint64    `json:"memory_usage"`
+		MemoryLimit    uint64    `json:"memory_limit"`
+		NetworkRxBytes uint64    `json:"network_rx_bytes"`
+		NetworkTxBytes uint64    `json:"network_tx_bytes"`
+	}
+
+	// ContainerStatsHistory is the usage of a container over a period,
+	// oldest sample first
+	ContainerStatsHistory struct {
+		ContainerId string            `json:"container_id"`
+		Period      string            `json:"period"`
+		Interval    string            `json:"interval"`
+		Samples     []*ContainerStats `json:"samples"`
+	}
+)
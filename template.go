@@ -0,0 +1,94 @@
+package shipyard
+
+import (
+	"time"
+)
+
+// TemplateBundleVersion is the version of the bundle format written by
+// this release; bundles of other versions are refused on import
+const TemplateBundleVersion = 1
+
+// Types of template parameters; parameters without a type are strings
+const (
+	TemplateParameterString = "string"
+	TemplateParameterInt    = "int"
+	TemplateParameterEnum   = "enum"
+)
+
+type (
+	// Template is a vetted application blueprint: a docker-compose file with
+	// ${PARAMETER} placeholders filled in when it is deployed as a stack
+	Template struct {
+		Name        string              `json:"name" gorethink:"id"`
+		Title       string              `json:"title,omitempty" gorethink:"title,omitempty"`
+		Description string              `json:"description,omitempty" gorethink:"description,omitempty"`
+		Author      string              `json:"author,omitempty" gorethink:"author,omitempty"`
+		Tags        []string            `json:"tags,omitempty" gorethink:"tags"`
+		Parameters  []TemplateParameter `json:"parameters,omitempty" gorethink:"parameters"`
+		Compose     string              `json:"compose" gorethink:"compose"`
+		ImportedBy  string              `json:"imported_by,omitempty" gorethink:"imported_by"`
+		ImportedAt  time.Time           `json:"imported_at,omitempty" gorethink:"imported_at"`
+	}
+
+	// TemplateParameter is a value asked for when a template is deployed;
+	// values are checked against the type, the options of enums and the
+	// pattern before they are filled in
+	TemplateParameter struct {
+		Name        string   `json:"name" gorethink:"name"`
+		Description string   `json:"description,omitempty" gorethink:"description,omitempty"`
+		Type        string   `json:"type,omitempty" gorethink:"type,omitempty"`
+		Options     []string `json:"options,omitempty" gorethink:"options,omitempty"`
+		// Pattern is a regular expression the whole value has to match
+		Pattern  string `json:"pattern,omitempty" gorethink:"pattern,omitempty"`
+		Default  string `json:"default,omitempty" gorethink:"default,omitempty"`
+		Required bool   `json:"required,omitempty" gorethink:"required,omitempty"`
+	}
+
+	// TemplateBundle is the format templates are shared in between
+	// installs
+	TemplateBundle struct {
+		Version    int                 `json:"version"`
+		Metadata   TemplateMetadata    `json:"metadata"`
+		Parameters []TemplateParameter `json:"parameters,omitempty"`
+		Compose    string              `json:"compose"`
+	}
+
+	TemplateMetadata struct {
+		Name        string    `json:"name"`
+		Title       string    `json:"title,omitempty"`
+		Description string    `json:"description,omitempty"`
+		Author      string    `json:"author,omitempty"`
+		Tags        []string  `json:"tags,omitempty"`
+		ExportedAt  time.Time `json:"exported_at,omitempty"`
+	}
+)
+
+// Bundle returns the template in the bundle format
+func (t *Template) Bundle() *TemplateBundle {
+	return &TemplateBundle{
+		Version: TemplateBundleVersion,
+		Metadata: TemplateMetadata{
+			Name:        t.Name,
+			Title:       t.Title,
+			Description: t.Description,
+			Author:      t.Author,
+			Tags:        t.Tags,
+			ExportedAt:  time.Now(),
+		},
+		Parameters: t.Parameters,
+		Compose:    t.Compose,
+	}
+}
+
+// Template returns the template of the bundle
+func (b *TemplateBundle) Template() *Template {
+	return &Template{
+		Name:        b.Metadata.Name,
+		Title:       b.Metadata.Title,
+		Description: b.Metadata.Description,
+		Author:      b.Metadata.Author,
+		Tags:        b.Metadata.Tags,
+		Parameters:  b.Parameters,
+		Compose:     b.Compose,
+	}
+}
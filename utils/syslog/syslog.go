@@ -0,0 +1,199 @@
+package syslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// facility 13 is "log audit"
+	facilityLogAudit = 13
+	severityInfo     = 6
+	// private enterprise number reserved for documentation (RFC 5612)
+	sdID         = "shipyard@32473"
+	appName      = "shipyard"
+	nilValue     = "-"
+	queueSize    = 1024
+	writeTimeout = 5 * time.Second
+)
+
+type (
+	// Message is a single RFC5424 record; Data is emitted as structured data
+	Message struct {
+		Time    time.Time
+		MsgID   string
+		Message string
+		Data    map[string]string
+	}
+
+	// Writer streams messages to a remote syslog endpoint in the background
+	Writer struct {
+		network   string
+		addr      string
+		tlsConfig *tls.Config
+		hostname  string
+		conn      net.Conn
+		queue     chan *Message
+	}
+)
+
+// NewWriter returns a writer for the endpoint; addr is a url of the form
+// udp://host:514, tcp://host:601 or tls://host:6514
+func NewWriter(addr string, tlsConfig *tls.Config) (*Writer, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	network := u.Scheme
+	switch network {
+	case "udp", "tcp":
+	case "tls":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported syslog protocol: %s", u.Scheme)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = nilValue
+	}
+
+	w := &Writer{
+		network:   network,
+		addr:      u.Host,
+		tlsConfig: tlsConfig,
+		hostname:  hostname,
+		queue:     make(chan *Message, queueSize),
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// Send queues the message for delivery; messages are dropped when the
+// endpoint cannot keep up so callers never block
+func (w *Writer) Send(msg *Message) {
+	select {
+	case w.queue <- msg:
+	default:
+		log.Warnf("syslog queue full; dropping message: %s", msg.Message)
+	}
+}
+
+func (w *Writer) run() {
+	for msg := range w.queue {
+		if err := w.write(msg); err != nil {
+			log.Errorf("error sending syslog message: %s", err)
+		}
+	}
+}
+
+func (w *Writer) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+
+	var (
+		c   net.Conn
+		err error
+	)
+
+	if w.network == "tls" {
+		c, err = tls.Dial("tcp", w.addr, w.tlsConfig)
+	} else {
+		c, err = net.Dial(w.network, w.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.conn = c
+	return nil
+}
+
+func (w *Writer) write(msg *Message) error {
+	if err := w.connect(); err != nil {
+		return err
+	}
+
+	data := Format(msg, w.hostname)
+	// stream transports use octet counting framing (RFC 6587)
+	if w.network != "udp" {
+		data = fmt.Sprintf("%d %s", len(data), data)
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := w.conn.Write([]byte(data)); err != nil {
+		// reconnect on the next message
+		w.conn.Close()
+		w.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// Format renders the message in RFC5424 format
+func Format(msg *Message, hostname string) string {
+	pri := facilityLogAudit*8 + severityInfo
+
+	msgID := msg.MsgID
+	if msgID == "" {
+		msgID = nilValue
+	}
+
+	if hostname == "" {
+		hostname = nilValue
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		pri,
+		msg.Time.UTC().Format(time.RFC3339Nano),
+		hostname,
+		appName,
+		os.Getpid(),
+		msgID,
+		structuredData(msg.Data),
+		msg.Message,
+	)
+}
+
+func structuredData(data map[string]string) string {
+	if len(data) == 0 {
+		return nilValue
+	}
+
+	keys := []string{}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("[" + sdID)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, " %s=\"%s\"", k, escape(data[k]))
+	}
+	buf.WriteString("]")
+
+	return buf.String()
+}
+
+// escape quotes the characters RFC5424 reserves in param values
+func escape(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return r.Replace(v)
+}
@@ -0,0 +1,43 @@
+package syslog
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	msg := &Message{
+		Time:    time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		MsgID:   "api",
+		Message: "/api/accounts",
+		Data: map[string]string{
+			"username": "admin",
+			"path":     `/api/"quoted"]`,
+		},
+	}
+
+	expected := fmt.Sprintf(`<110>1 2016-01-02T03:04:05Z host shipyard %d api [shipyard@32473 path="/api/\"quoted\"\]" username="admin"] /api/accounts`, os.Getpid())
+	if s := Format(msg, "host"); s != expected {
+		t.Fatalf("expected %q; received %q", expected, s)
+	}
+}
+
+func TestFormatNoData(t *testing.T) {
+	msg := &Message{
+		Time:    time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message: "test",
+	}
+
+	expected := fmt.Sprintf("<110>1 2016-01-02T03:04:05Z - shipyard %d - - test", os.Getpid())
+	if s := Format(msg, ""); s != expected {
+		t.Fatalf("expected %q; received %q", expected, s)
+	}
+}
+
+func TestNewWriterInvalidProtocol(t *testing.T) {
+	if _, err := NewWriter("http://localhost:514", nil); err == nil {
+		t.Fatalf("expected error for unsupported protocol")
+	}
+}
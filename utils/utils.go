@@ -3,7 +3,9 @@ package utils
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -66,22 +68,22 @@ func GetClient(dockerUrl, tlsCaCert, tlsCert, tlsKey string, allowInsecure bool)
 		log.Debug("using tls for communication with docker")
 		caCert, err := ioutil.ReadFile(tlsCaCert)
 		if err != nil {
-			log.Fatalf("error loading tls ca cert: %s", err)
+			return nil, fmt.Errorf("error loading tls ca cert: %s", err)
 		}
 
 		cert, err := ioutil.ReadFile(tlsCert)
 		if err != nil {
-			log.Fatalf("error loading tls cert: %s", err)
+			return nil, fmt.Errorf("error loading tls cert: %s", err)
 		}
 
 		key, err := ioutil.ReadFile(tlsKey)
 		if err != nil {
-			log.Fatalf("error loading tls key: %s", err)
+			return nil, fmt.Errorf("error loading tls key: %s", err)
 		}
 
 		cfg, err := GetTLSConfig(caCert, cert, key, allowInsecure)
 		if err != nil {
-			log.Fatalf("error configuring tls: %s", err)
+			return nil, fmt.Errorf("error configuring tls: %s", err)
 		}
 		tlsConfig = cfg
 	}
@@ -94,6 +96,30 @@ func GetClient(dockerUrl, tlsCaCert, tlsCert, tlsKey string, allowInsecure bool)
 	return client, nil
 }
 
+// NewClient returns a docker client using pem encoded tls material; tls is
+// used when the ca, cert and key are all set
+func NewClient(dockerUrl string, caCert, cert, key []byte, allowInsecure bool) (*dockerclient.DockerClient, error) {
+	var tlsConfig *tls.Config
+	if len(caCert) > 0 && len(cert) > 0 && len(key) > 0 {
+		cfg, err := GetTLSConfig(caCert, cert, key, allowInsecure)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring tls: %s", err)
+		}
+		tlsConfig = cfg
+	}
+
+	return dockerclient.NewDockerClient(dockerUrl, tlsConfig)
+}
+
+// RemoteIP returns the host portion of a request remote address
+func RemoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+
+	return remoteAddr
+}
+
 // utility for specifying a timeout channel
 func ChanTimeout(timeoutInSeconds int) <-chan bool {
 	timeout := make(chan bool, 1)
@@ -0,0 +1,13 @@
+package shipyard
+
+// Volume is a volume on the engine of a node; the same name can exist on
+// several nodes
+type Volume struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Node       string            `json:"node"`
+	// Dangling volumes are not used by any container
+	Dangling bool `json:"dangling"`
+}